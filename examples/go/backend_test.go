@@ -0,0 +1,127 @@
+package flaky
+
+import (
+	"math/rand/v2"
+	"sort"
+	"testing"
+)
+
+// randBackend is a source of uniform draws in [0, 1)
+type randBackend interface {
+	Float64() float64
+}
+
+// backend names an RNG implementation and constructs it from a seed
+type backend struct {
+	name string
+	new  func(seed int64) randBackend
+}
+
+// randBackends lists the RNG backends scenarios can be simulated under
+var randBackends = []backend{
+	{name: "pcg", new: func(seed int64) randBackend { return rand.New(PCG(seed)) }},
+	{name: "chacha8", new: func(seed int64) randBackend { return rand.New(ChaCha8(seed)) }},
+	{name: "splitmix64", new: func(seed int64) randBackend { return newSplitMix64(seed) }},
+}
+
+// splitMix64 is a small PRNG whose output is stable across Go releases
+type splitMix64 struct {
+	state uint64
+}
+
+func newSplitMix64(seed int64) *splitMix64 {
+	return &splitMix64{state: uint64(seed)}
+}
+
+func (s *splitMix64) next() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Float64 returns a uniform draw in [0, 1) from the top 53 bits
+func (s *splitMix64) Float64() float64 {
+	return float64(s.next()>>11) / (1 << 53)
+}
+
+// BackendDivergence records a scenario whose outcome differs between backends
+type BackendDivergence struct {
+	Scenario string
+	Failed   map[string]bool // backend name -> whether the scenario failed
+}
+
+// CompareBackends runs the suite once under every RNG backend with the same
+// seed and returns the scenarios whose outcomes differ, sorted by name
+func CompareBackends(seed int64, cfg FlakyConfig) []BackendDivergence {
+	outcomes := make(map[string]map[string]bool, len(randBackends))
+	for _, b := range randBackends {
+		outcomes[b.name] = simulateRun(b.new(seed), cfg)
+	}
+
+	var divergences []BackendDivergence
+	for _, scenario := range cfg.scenarioNames() {
+		failed := make(map[string]bool, len(randBackends))
+		distinct := make(map[bool]bool)
+		for _, b := range randBackends {
+			failed[b.name] = outcomes[b.name][scenario]
+			distinct[failed[b.name]] = true
+		}
+		if len(distinct) > 1 {
+			divergences = append(divergences, BackendDivergence{Scenario: scenario, Failed: failed})
+		}
+	}
+	sort.Slice(divergences, func(i, j int) bool { return divergences[i].Scenario < divergences[j].Scenario })
+	return divergences
+}
+
+// TestCompareBackendsForcedScenariosNeverDiverge verifies config-forced scenarios
+// agree across backends while draw-dependent ones diverge for some seeds
+func TestCompareBackendsForcedScenariosNeverDiverge(t *testing.T) {
+	cfg := FlakyConfig{FailureRates: map[string]float64{
+		"ConcurrentAccess":  0.5,
+		"OrderDependency":   0.5,
+		"RandomFailure":     0,
+		"NetworkSimulation": 1,
+	}}
+
+	diverged := make(map[string]int)
+	for seed := int64(0); seed < 50; seed++ {
+		for _, d := range CompareBackends(seed, cfg) {
+			diverged[d.Scenario]++
+			if len(d.Failed) != len(randBackends) {
+				t.Errorf("seed %d: expected an outcome per backend, got %v", seed, d.Failed)
+			}
+		}
+	}
+
+	for _, forced := range []string{"RandomFailure", "NetworkSimulation"} {
+		if diverged[forced] != 0 {
+			t.Errorf("%s is forced by config but diverged for %d seeds", forced, diverged[forced])
+		}
+	}
+	for _, drawn := range []string{"ConcurrentAccess", "OrderDependency"} {
+		if diverged[drawn] == 0 {
+			t.Errorf("%s depends on draw order but never diverged across 50 seeds", drawn)
+		}
+	}
+}
+
+// TestSplitMix64IsStable pins the first outputs so backend results cannot drift
+func TestSplitMix64IsStable(t *testing.T) {
+	want := []uint64{2454886589211414944, 3778200017661327597, 2205171434679333405}
+	s := newSplitMix64(12345)
+	for i, w := range want {
+		if got := s.next(); got != w {
+			t.Errorf("output %d: expected %d, got %d", i, w, got)
+		}
+	}
+
+	r := newSplitMix64(12345)
+	for i := 0; i < 1000; i++ {
+		if x := r.Float64(); x < 0 || x >= 1 {
+			t.Fatalf("draw %d: %v outside [0, 1)", i, x)
+		}
+	}
+}
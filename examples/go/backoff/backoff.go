@@ -0,0 +1,217 @@
+// Package backoff computes the waits between retries, with exponential
+// growth and jitter drawn from a seeded source, so retry logic in
+// production code can be replayed exactly in tests
+//
+// Production code draws from its own seeded source:
+//
+//	p := backoff.Policy{Initial: 100 * time.Millisecond, Max: 10 * time.Second, Jitter: backoff.Full}
+//	b := p.New(backoff.NewRand(time.Now().UnixNano()))
+//	err := backoff.Retry(ctx, b, nil, 5, call)
+//
+// and its tests hand it the test's seeded RNG, flaky.Rand(t) or
+// flaky.NewBackoff(t, p), and a clock.FakeClock, so the same seed waits the
+// same and costs no real time against the flaky injectors
+package backoff
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/example/flaky-test-example/clock"
+)
+
+// Rand is the source jitter is drawn from, such as a *rand.Rand of
+// math/rand/v2, flaky.Rand(t) or a *flaky.Injector
+type Rand interface {
+	// Float64 returns a draw in [0.0, 1.0)
+	Float64() float64
+}
+
+// NewRand returns a PCG source seeded by seed, the algorithm flaky.Rand
+// defaults to
+func NewRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewPCG(uint64(seed), 0))
+}
+
+// Jitter is how a Policy randomizes its waits
+type Jitter int
+
+const (
+	// None waits exactly Initial times Multiplier to the retry, up to Max
+	None Jitter = iota
+	// Proportional adds a random extra wait of up to Fraction of the
+	// exponential wait, which may take it past Max
+	Proportional
+	// Full waits a uniform draw between zero and the exponential wait, which
+	// spreads retries of many clients the most
+	Full
+	// Decorrelated waits a uniform draw between Initial and three times the
+	// previous wait, up to Max, so waits grow without a fixed schedule
+	Decorrelated
+)
+
+var jitterNames = map[Jitter]string{
+	None:         "none",
+	Proportional: "proportional",
+	Full:         "full",
+	Decorrelated: "decorrelated",
+}
+
+func (j Jitter) String() string {
+	if name, ok := jitterNames[j]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Policy sets the waits between the retries of an operation
+type Policy struct {
+	// Initial is the wait before the first retry
+	Initial time.Duration
+	// Max caps every wait, 0 meaning no cap
+	Max time.Duration
+	// Multiplier grows the exponential wait after each retry, 0 meaning 2;
+	// Decorrelated ignores it
+	Multiplier float64
+	Jitter     Jitter
+	// Fraction is the most extra wait Proportional jitter adds, as a share
+	// of the exponential wait
+	Fraction float64
+}
+
+// Exponential returns the wait before retry number retry, 0-based, without
+// jitter
+func (p Policy) Exponential(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	wait := float64(p.Initial) * math.Pow(multiplier, float64(retry))
+	return p.cap(wait)
+}
+
+// cap limits wait to Max and to the longest time.Duration
+func (p Policy) cap(wait float64) time.Duration {
+	if p.Max > 0 && wait > float64(p.Max) {
+		return p.Max
+	}
+	if wait >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(wait)
+}
+
+// New returns the waits of one operation's retries, drawing the jitter from
+// r; a Policy without jitter never draws, and r may be nil
+func (p Policy) New(r Rand) *Backoff {
+	return &Backoff{policy: p, rand: r}
+}
+
+// Backoff is the sequence of waits of one operation's retries
+// It is not safe for concurrent use; give every operation its own
+type Backoff struct {
+	policy Policy
+	rand   Rand
+	retry  int
+	prev   time.Duration
+}
+
+// Policy returns the policy the waits follow
+func (b *Backoff) Policy() Policy {
+	return b.policy
+}
+
+// Retries returns how many waits Next has returned since the last Reset
+func (b *Backoff) Retries() int {
+	return b.retry
+}
+
+// Next returns the wait before the next retry
+func (b *Backoff) Next() time.Duration {
+	p := b.policy
+	exp := p.Exponential(b.retry)
+	b.retry++
+	var wait time.Duration
+	switch p.Jitter {
+	case Proportional:
+		wait = time.Duration(float64(exp) * (1 + p.Fraction*b.rand.Float64()))
+	case Full:
+		wait = time.Duration(float64(exp) * b.rand.Float64())
+	case Decorrelated:
+		prev := max(b.prev, p.Initial)
+		wait = p.cap(float64(p.Initial) + b.rand.Float64()*float64(3*prev-p.Initial))
+	default:
+		wait = exp
+	}
+	b.prev = wait
+	return wait
+}
+
+// Reset starts the sequence over, as after an operation succeeded
+func (b *Backoff) Reset() {
+	b.retry, b.prev = 0, 0
+}
+
+// Retry calls op until it returns nil or attempts calls have failed and
+// returns the last error, waiting b.Next between calls with clk.Sleep, or
+// on a timer ctx cancels when clk is nil
+// It stops early when ctx is done, returning the last error joined with
+// the context's, or when op returns an error Permanent wrapped
+func Retry(ctx context.Context, b *Backoff, clk clock.Clock, attempts int, op func(context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := op(ctx)
+		if err == nil {
+			return nil
+		}
+		var stop *permanentError
+		if errors.As(err, &stop) {
+			return stop.err
+		}
+		if attempt >= attempts {
+			return err
+		}
+		if ctx.Err() == nil {
+			sleep(ctx, clk, b.Next())
+		}
+		if ctx.Err() != nil {
+			return errors.Join(err, ctx.Err())
+		}
+	}
+}
+
+// sleep waits d on clk, or on a timer until ctx is done when clk is nil
+func sleep(ctx context.Context, clk clock.Clock, d time.Duration) {
+	if clk != nil {
+		clk.Sleep(d)
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// Permanent wraps err so Retry returns it without retrying
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,136 @@
+package backoff_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/backoff"
+	"github.com/example/flaky-test-example/clock"
+)
+
+func waits(b *backoff.Backoff, n int) []time.Duration {
+	out := make([]time.Duration, n)
+	for i := range out {
+		out[i] = b.Next()
+	}
+	return out
+}
+
+func TestExponential(t *testing.T) {
+	b := backoff.Policy{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond}.New(nil)
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond}
+	if got := waits(b, 5); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected waits %v, got %v", want, got)
+	}
+	if b.Retries() != 5 {
+		t.Errorf("Expected 5 retries, got %d", b.Retries())
+	}
+	b.Reset()
+	if got := b.Next(); got != 10*time.Millisecond {
+		t.Errorf("Expected Reset to start over, got %v", got)
+	}
+	huge := backoff.Policy{Initial: time.Hour, Multiplier: 10}
+	if got := huge.Exponential(100); got <= 0 {
+		t.Errorf("Expected an uncapped wait to saturate, got %v", got)
+	}
+}
+
+func TestJitterBounds(t *testing.T) {
+	const initial, limit = 10 * time.Millisecond, time.Second
+	for _, tt := range []struct {
+		jitter backoff.Jitter
+		bounds func(retry int, prev time.Duration) (lo, hi time.Duration)
+	}{
+		{backoff.Proportional, func(retry int, _ time.Duration) (time.Duration, time.Duration) {
+			exp := backoff.Policy{Initial: initial, Max: limit}.Exponential(retry)
+			return exp, exp + exp/2
+		}},
+		{backoff.Full, func(retry int, _ time.Duration) (time.Duration, time.Duration) {
+			return 0, backoff.Policy{Initial: initial, Max: limit}.Exponential(retry)
+		}},
+		{backoff.Decorrelated, func(_ int, prev time.Duration) (time.Duration, time.Duration) {
+			return initial, min(3*max(prev, initial), limit)
+		}},
+	} {
+		t.Run(tt.jitter.String(), func(t *testing.T) {
+			p := backoff.Policy{Initial: initial, Max: limit, Jitter: tt.jitter, Fraction: 0.5}
+			b := p.New(backoff.NewRand(42))
+			var prev time.Duration
+			for retry := 0; retry < 50; retry++ {
+				wait := b.Next()
+				if lo, hi := tt.bounds(retry, prev); wait < lo || wait > hi {
+					t.Fatalf("Retry %d: wait %v outside [%v, %v]", retry, wait, lo, hi)
+				}
+				prev = wait
+			}
+			if a, b := waits(p.New(backoff.NewRand(7)), 10), waits(p.New(backoff.NewRand(7)), 10); !reflect.DeepEqual(a, b) {
+				t.Errorf("Expected the same seed to wait the same, got %v and %v", a, b)
+			}
+			if a, b := waits(p.New(backoff.NewRand(7)), 10), waits(p.New(backoff.NewRand(8)), 10); reflect.DeepEqual(a, b) {
+				t.Errorf("Expected different seeds to wait differently, got %v twice", a)
+			}
+		})
+	}
+}
+
+// flakyCall fails with the injector's draws and returns the waits Retry
+// slept on a fake clock before it succeeded or gave up
+func flakyCall(seed int64) (attempts int, slept time.Duration, err error) {
+	inj := flaky.NewInjector(flaky.WithSeed(seed))
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	start := fake.Now()
+	b := backoff.Policy{Initial: 100 * time.Millisecond, Max: 5 * time.Second, Jitter: backoff.Decorrelated}.New(inj)
+	err = backoff.Retry(context.Background(), b, fake, 6, func(context.Context) error {
+		attempts++
+		return inj.MaybeFail(0.6)
+	})
+	return attempts, fake.Since(start), err
+}
+
+func TestRetryReplaysAgainstInjector(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		attempts, slept, err := flakyCall(seed)
+		again, sleptAgain, errAgain := flakyCall(seed)
+		if attempts != again || slept != sleptAgain || (err == nil) != (errAgain == nil) {
+			t.Fatalf("Seed %d: expected a replay, got %d attempts over %v then %d over %v", seed, attempts, slept, again, sleptAgain)
+		}
+		if err != nil && (attempts != 6 || !errors.Is(err, flaky.ErrInjected)) {
+			t.Errorf("Seed %d: expected the last injected error after 6 attempts, got %v after %d", seed, err, attempts)
+		}
+		if attempts == 1 && slept != 0 {
+			t.Errorf("Seed %d: expected no wait before a first success, slept %v", seed, slept)
+		}
+	}
+}
+
+func TestRetryStops(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	p := backoff.Policy{Initial: time.Second}
+	denied := errors.New("denied")
+	calls := 0
+	err := backoff.Retry(context.Background(), p.New(nil), fake, 5, func(context.Context) error {
+		calls++
+		return backoff.Permanent(denied)
+	})
+	if err != denied || calls != 1 {
+		t.Errorf("Expected a permanent error to stop at once, got %v after %d call(s)", err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls = 0
+	err = backoff.Retry(ctx, p.New(nil), nil, 5, func(context.Context) error {
+		calls++
+		cancel()
+		return denied
+	})
+	if !errors.Is(err, denied) || !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("Expected a cancelled context to stop retrying, got %v after %d call(s)", err, calls)
+	}
+	if backoff.Permanent(nil) != nil {
+		t.Error("Expected Permanent(nil) to be nil")
+	}
+}
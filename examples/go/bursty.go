@@ -0,0 +1,72 @@
+package flaky
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// BurstyFailer models correlated failures as a two-state Markov chain
+// A step fails with probability pFailGivenPass after a passing step and
+// pFailGivenFail after a failing one, so pFailGivenFail above pFailGivenPass
+// makes failures come in bursts, like a bad node that stays bad for a while
+// It starts in the passing state and is safe for concurrent use
+type BurstyFailer struct {
+	mu             sync.Mutex
+	pFailGivenPass float64
+	pFailGivenFail float64
+	failed         bool
+}
+
+// NewBurstyFailer returns a chain with the given transition probabilities
+// It panics if either probability is outside [0, 1]
+func NewBurstyFailer(pFailGivenPass, pFailGivenFail float64) *BurstyFailer {
+	for _, p := range []float64{pFailGivenPass, pFailGivenFail} {
+		if p < 0 || p > 1 || math.IsNaN(p) {
+			panic(fmt.Sprintf("flaky: bursty failure probability %v outside [0, 1]", p))
+		}
+	}
+	return &BurstyFailer{pFailGivenPass: pFailGivenPass, pFailGivenFail: pFailGivenFail}
+}
+
+// Next advances the chain one step with a draw from inj and returns an error
+// wrapping ErrInjected if the step fails
+func (b *BurstyFailer) Next(inj *Injector) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rate := b.pFailGivenPass
+	if b.failed {
+		rate = b.pFailGivenFail
+	}
+	err := inj.MaybeFail(rate)
+	b.failed = err != nil
+	return err
+}
+
+// Failed reports whether the last step failed
+func (b *BurstyFailer) Failed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failed
+}
+
+// StationaryRate returns the long-run fraction of failing steps
+func (b *BurstyFailer) StationaryRate() float64 {
+	return stationaryRate(b.pFailGivenPass, b.pFailGivenFail)
+}
+
+// MeanBurstLength returns the expected number of consecutive failing steps
+// once a burst starts, +Inf if the chain never recovers
+func (b *BurstyFailer) MeanBurstLength() float64 {
+	return 1 / (1 - b.pFailGivenFail)
+}
+
+// stationaryRate solves pi = pi*P for the two-state chain; a chain that never
+// leaves its passing start state fails at rate 0
+func stationaryRate(pFailGivenPass, pFailGivenFail float64) float64 {
+	leave := pFailGivenPass + (1 - pFailGivenFail)
+	if leave == 0 {
+		return 0
+	}
+	return pFailGivenPass / leave
+}
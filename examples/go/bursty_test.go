@@ -0,0 +1,92 @@
+package flaky
+
+import (
+	"math"
+	"testing"
+)
+
+// TestBurstyFailerExtremes verifies the chain follows its transition
+// probabilities at 0 and 1
+func TestBurstyFailerExtremes(t *testing.T) {
+	inj := NewInjector(WithSeed(1))
+	never := NewBurstyFailer(0, 1)
+	for i := 0; i < 100; i++ {
+		if err := never.Next(inj); err != nil {
+			t.Fatalf("Step %d: a chain that never leaves the passing state failed: %v", i, err)
+		}
+	}
+
+	stuck := NewBurstyFailer(1, 1)
+	for i := 0; i < 100; i++ {
+		if stuck.Next(inj) == nil || !stuck.Failed() {
+			t.Fatalf("Step %d: a chain that never recovers passed", i)
+		}
+	}
+
+	alternating := NewBurstyFailer(1, 0)
+	for i := 0; i < 10; i++ {
+		if failed := alternating.Next(inj) != nil; failed != (i%2 == 0) {
+			t.Fatalf("Step %d: expected failed=%v", i, i%2 == 0)
+		}
+	}
+}
+
+// TestBurstyFailerMatchesStationaryRate verifies the long-run failure rate and
+// burst length of a simulated chain
+func TestBurstyFailerMatchesStationaryRate(t *testing.T) {
+	chain := NewBurstyFailer(0.05, 0.8)
+	if got := chain.StationaryRate(); math.Abs(got-0.2) > 1e-9 {
+		t.Errorf("Expected stationary rate 0.2, got %v", got)
+	}
+	if got := chain.MeanBurstLength(); math.Abs(got-5) > 1e-9 {
+		t.Errorf("Expected mean burst length 5, got %v", got)
+	}
+
+	inj := NewInjector(WithSeed(7))
+	const steps = 200000
+	failures, bursts := 0, 0
+	prev := false
+	for i := 0; i < steps; i++ {
+		failed := chain.Next(inj) != nil
+		if failed {
+			failures++
+			if !prev {
+				bursts++
+			}
+		}
+		prev = failed
+	}
+	if rate := float64(failures) / steps; math.Abs(rate-0.2) > 0.01 {
+		t.Errorf("Expected a failure rate near 0.2, got %.4f", rate)
+	}
+	if burst := float64(failures) / float64(bursts); math.Abs(burst-5) > 0.25 {
+		t.Errorf("Expected bursts of about 5 failures, got %.2f", burst)
+	}
+}
+
+// TestNewBurstyFailerRejectsInvalidProbabilities verifies out-of-range
+// probabilities panic
+func TestNewBurstyFailerRejectsInvalidProbabilities(t *testing.T) {
+	for _, p := range [][2]float64{{-0.1, 0.5}, {0.5, 1.5}, {math.NaN(), 0}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewBurstyFailer(%v, %v) did not panic", p[0], p[1])
+				}
+			}()
+			NewBurstyFailer(p[0], p[1])
+		}()
+	}
+}
+
+// TestBurstyScenarioEffectiveFailureRate verifies the registry reports the
+// long-run rate of bursty scenarios
+func TestBurstyScenarioEffectiveFailureRate(t *testing.T) {
+	sc, ok := DefaultScenarios().Get("BurstyFailure")
+	if !ok {
+		t.Fatal("Expected a default BurstyFailure scenario")
+	}
+	if got := sc.EffectiveFailureRate(); math.Abs(got-0.2) > 1e-9 {
+		t.Errorf("Expected effective rate 0.2, got %v", got)
+	}
+}
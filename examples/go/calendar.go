@@ -0,0 +1,128 @@
+package flaky
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/example/flaky-test-example/clock"
+)
+
+// Hazard is a wall-clock condition that trips naive time handling
+type Hazard string
+
+const (
+	// MidnightUTC is just before a UTC day change
+	MidnightUTC Hazard = "midnight-utc"
+	// EndOfMonth is just before the end of a month in the location
+	EndOfMonth Hazard = "end-of-month"
+	// DSTTransition is just before a daylight saving change in the location
+	DSTTransition Hazard = "dst-transition"
+	// LeapSecond is just before the end of a UTC day that had a leap second
+	LeapSecond Hazard = "leap-second"
+)
+
+// leapSecondDays are the UTC days that ended in a positive leap second
+var leapSecondDays = []string{
+	"1972-06-30", "1972-12-31", "1973-12-31", "1974-12-31", "1975-12-31", "1976-12-31",
+	"1977-12-31", "1978-12-31", "1979-12-31", "1981-06-30", "1982-06-30", "1983-06-30",
+	"1985-06-30", "1987-12-31", "1989-12-31", "1990-12-31", "1992-06-30", "1993-06-30",
+	"1994-06-30", "1995-12-31", "1997-06-30", "1998-12-31", "2005-12-31", "2008-12-31",
+	"2012-06-30", "2015-06-30", "2016-12-31",
+}
+
+// LeapSeconds are the midnights UTC that a leap second preceded, in order
+var LeapSeconds = func() []time.Time {
+	instants := make([]time.Time, len(leapSecondDays))
+	for i, day := range leapSecondDays {
+		d, err := time.Parse(time.DateOnly, day)
+		if err != nil {
+			panic(err)
+		}
+		instants[i] = d.AddDate(0, 0, 1)
+	}
+	return instants
+}()
+
+// hazardYears is the range years are drawn from
+const (
+	firstHazardYear = 2000
+	hazardYears     = 38
+)
+
+// HazardTime draws an instant 1ms to 1s before h, in loc (UTC when nil)
+// DSTTransition fails for a location without daylight saving in the drawn
+// year
+func (i *Injector) HazardTime(h Hazard, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	lead := time.Duration(1+i.Intn(1000)) * time.Millisecond
+	year := firstHazardYear + i.Intn(hazardYears)
+	var boundary time.Time
+	switch h {
+	case MidnightUTC:
+		boundary = time.Date(year, 1, 1+i.Intn(365), 0, 0, 0, 0, time.UTC)
+	case EndOfMonth:
+		boundary = time.Date(year, time.Month(2+i.Intn(12)), 1, 0, 0, 0, 0, loc)
+	case DSTTransition:
+		transitions := dstTransitions(loc, year)
+		if len(transitions) == 0 {
+			return time.Time{}, fmt.Errorf("flaky: %s has no daylight saving transition in %d", loc, year)
+		}
+		boundary = transitions[i.Intn(len(transitions))]
+	case LeapSecond:
+		boundary = LeapSeconds[i.Intn(len(LeapSeconds))]
+	default:
+		return time.Time{}, fmt.Errorf("flaky: unknown hazard %q", h)
+	}
+	return boundary.Add(-lead).In(loc), nil
+}
+
+// WallClock returns a fake clock that starts just before h with probability
+// rate, and otherwise at noon on the 15th of a drawn month, clear of every
+// hazard
+func (i *Injector) WallClock(h Hazard, loc *time.Location, rate float64) (*clock.FakeClock, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	if i.Float64() < rate {
+		start, err := i.HazardTime(h, loc)
+		if err != nil {
+			return nil, err
+		}
+		return clock.NewFake(start), nil
+	}
+	year, month := firstHazardYear+i.Intn(hazardYears), time.Month(1+i.Intn(12))
+	return clock.NewFake(time.Date(year, month, 15, 12, 0, 0, 0, loc)), nil
+}
+
+// RepeatLeapSecond steps clk back one second if a leap second fell after
+// since, the way a kernel without leap smearing repeats 23:59:59
+func RepeatLeapSecond(clk *clock.FakeClock, since time.Time) {
+	now := clk.Now()
+	for _, leap := range LeapSeconds {
+		if leap.After(since) && !leap.After(now) {
+			clk.Set(now.Add(-time.Second))
+			return
+		}
+	}
+}
+
+// dstTransitions returns the instants in year at which loc's offset changes
+// Zone bounds also end where the zone table switches to its rule for later
+// years, so only bounds with an actual offset change count
+func dstTransitions(loc *time.Location, year int) []time.Time {
+	var transitions []time.Time
+	t := time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+	for {
+		_, end := t.ZoneBounds()
+		if end.IsZero() || end.Year() != year {
+			return transitions
+		}
+		_, before := t.Zone()
+		if _, after := end.Zone(); after != before {
+			transitions = append(transitions, end)
+		}
+		t = end
+	}
+}
@@ -0,0 +1,171 @@
+package flaky
+
+import (
+	"math"
+	"testing"
+	"time"
+	_ "time/tzdata" // make zone lookups independent of the host's zoneinfo
+
+	"github.com/example/flaky-test-example/clock"
+)
+
+// calendarClock returns the scenario's wall clock, starting just before h
+// with probability rate
+func calendarClock(t *testing.T, h Hazard, loc *time.Location, rate float64) *clock.FakeClock {
+	t.Helper()
+	clk, err := ForTest(t).WallClock(h, loc, rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clk
+}
+
+// TestMidnightRollover demonstrates a batch keyed by the UTC day it started on
+// This simulates a job that straddles midnight and checks its results against
+// the wrong day
+func TestMidnightRollover(t *testing.T) {
+	sc := scenario(t, "MidnightRollover")
+	clk := calendarClock(t, MidnightUTC, time.UTC, sc.FailureRate)
+
+	day := clk.Now().Format(time.DateOnly)
+	clk.Sleep(time.Second) // the batch takes a second
+
+	// Fails when the batch started within a second of midnight UTC
+	if finished := clk.Now().Format(time.DateOnly); finished != day {
+		t.Errorf("%s: batch for %s finished on %s", sc.Message, day, finished)
+	}
+}
+
+// TestEndOfMonth demonstrates month arithmetic that overflows short months
+// This simulates billing code computing next month's date with AddDate on the
+// last day of a month
+func TestEndOfMonth(t *testing.T) {
+	sc := scenario(t, "EndOfMonth")
+	clk := calendarClock(t, EndOfMonth, time.UTC, sc.FailureRate)
+
+	now := clk.Now()
+	next := now.AddDate(0, 1, 0)
+
+	// Fails on month ends the next month is shorter than, such as Jan 31
+	if want := now.Month()%12 + 1; next.Month() != want {
+		t.Errorf("%s: one month after %s is %s", sc.Message, now.Format("Jan 2"), next.Format("Jan 2"))
+	}
+}
+
+// TestDSTTransition demonstrates treating a day as 24 hours
+// This simulates scheduling "same time tomorrow" with Add(24*time.Hour) in a
+// zone with daylight saving time
+func TestDSTTransition(t *testing.T) {
+	sc := scenario(t, "DSTTransition")
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := calendarClock(t, DSTTransition, loc, sc.FailureRate)
+
+	now := clk.Now()
+	tomorrow := now.Add(24 * time.Hour)
+
+	// Fails when a daylight saving change falls within the next day
+	if tomorrow.Hour() != now.Hour() {
+		t.Errorf("%s: 24h after %s is %s", sc.Message, now.Format(time.DateTime+" MST"), tomorrow.Format(time.DateTime+" MST"))
+	}
+}
+
+// TestLeapSecond demonstrates durations computed from wall-clock timestamps
+// This simulates an operation timed with stored Unix timestamps while the
+// kernel repeats 23:59:59 for a leap second
+func TestLeapSecond(t *testing.T) {
+	sc := scenario(t, "LeapSecond")
+	clk := calendarClock(t, LeapSecond, time.UTC, sc.FailureRate)
+
+	start := clk.Now()
+	startedAt := start.Unix()
+	clk.Sleep(time.Second) // the operation takes a second
+	RepeatLeapSecond(clk, start)
+
+	// Fails when the operation spans a leap second
+	if elapsed := clk.Now().Unix() - startedAt; elapsed < 1 {
+		t.Errorf("%s: a 1s operation starting %s took %ds", sc.Message, start.Format(time.DateTime), elapsed)
+	}
+}
+
+// TestHazardTimeIsJustBeforeTheHazard verifies every hazard lands within a
+// second before its boundary
+func TestHazardTimeIsJustBeforeTheHazard(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inj := NewInjector(WithSeed(1))
+	for i := 0; i < 200; i++ {
+		at, err := inj.HazardTime(MidnightUTC, ny)
+		if next := at.Add(time.Second).UTC(); err != nil || at.UTC().Day() == next.Day() {
+			t.Fatalf("MidnightUTC: %v is not within 1s of midnight UTC (%v)", at, err)
+		}
+		at, _ = inj.HazardTime(EndOfMonth, ny)
+		if next := at.Add(time.Second); at.Location() != ny || at.Month() == next.Month() {
+			t.Fatalf("EndOfMonth: %v is not within 1s of a month end in %s", at, ny)
+		}
+		at, _ = inj.HazardTime(DSTTransition, ny)
+		if _, before := at.Zone(); func() bool { _, after := at.Add(time.Second).Zone(); return before == after }() {
+			t.Fatalf("DSTTransition: %v is not within 1s of an offset change", at)
+		}
+		at, _ = inj.HazardTime(LeapSecond, nil)
+		clk := clock.NewFake(at)
+		clk.Sleep(time.Second)
+		RepeatLeapSecond(clk, at)
+		if clk.Since(at) != 0 {
+			t.Fatalf("LeapSecond: %v is not within 1s of a leap second", at)
+		}
+	}
+}
+
+// TestHazardTimeErrors verifies zones without daylight saving and unknown
+// hazards are reported
+func TestHazardTimeErrors(t *testing.T) {
+	inj := NewInjector(WithSeed(1))
+	if _, err := inj.HazardTime(DSTTransition, time.UTC); err == nil {
+		t.Error("Expected an error for a DST transition in UTC")
+	}
+	if _, err := inj.HazardTime("solar-eclipse", nil); err == nil {
+		t.Error("Expected an error for an unknown hazard")
+	}
+}
+
+// TestWallClockRates verifies rate 0 never starts near the hazard and rate 1
+// always does
+func TestWallClockRates(t *testing.T) {
+	inj := NewInjector(WithSeed(2))
+	for i := 0; i < 100; i++ {
+		safe, _ := inj.WallClock(MidnightUTC, nil, 0)
+		if now := safe.Now(); now.Hour() != 12 || now.Day() != 15 {
+			t.Fatalf("Rate 0 started at %v, expected noon on the 15th", now)
+		}
+		risky, _ := inj.WallClock(MidnightUTC, nil, 1)
+		if now := risky.Now(); now.Hour() != 23 || now.Minute() != 59 {
+			t.Fatalf("Rate 1 started at %v, expected just before midnight", now)
+		}
+	}
+}
+
+// TestEndOfMonthTripRate verifies the scenario's trip rate is the share of
+// month ends whose next month is shorter, making its effective rate ~4%
+func TestEndOfMonthTripRate(t *testing.T) {
+	inj := NewInjector(WithSeed(3))
+	const draws = 1200
+	overflows := 0
+	for i := 0; i < draws; i++ {
+		at, _ := inj.HazardTime(EndOfMonth, time.UTC)
+		if at.AddDate(0, 1, 0).Month() != at.Month()%12+1 {
+			overflows++
+		}
+	}
+	sc, _ := DefaultScenarios().Get("EndOfMonth")
+	if got := float64(overflows) / draws; math.Abs(got-sc.TripRate) > 0.05 {
+		t.Errorf("Expected ~%.3f of month ends to overflow, got %.3f", sc.TripRate, got)
+	}
+	if got := sc.EffectiveFailureRate(); math.Abs(got-0.1*5/12) > 1e-9 {
+		t.Errorf("Expected an effective rate of %.4f, got %.4f", 0.1*5/12, got)
+	}
+}
@@ -0,0 +1,90 @@
+package flaky
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ScenarioInfo describes one scenario for tooling that lists or validates
+// scenarios without reading their tests
+type ScenarioInfo struct {
+	Name string `json:"name"`
+	// Category is the scenario's class, such as "timing" or "network"
+	Category string `json:"category,omitempty"`
+	// Parameters holds the settings of the scenario besides its failure
+	// rate, under the keys a scenario file sets them with, such as
+	// "timeout" or "latency"
+	Parameters map[string]any `json:"parameters,omitempty"`
+	// FailureRate is the configured rate and EffectiveRate the probability
+	// that a run fails, as EffectiveFailureRate returns it
+	FailureRate   float64 `json:"failure_rate"`
+	EffectiveRate float64 `json:"effective_rate"`
+	Message       string  `json:"message,omitempty"`
+	// Disabled is set on scenarios the preset or selection leaves out
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// ListScenarios describes the built-in scenarios and those of registered
+// FaultInjectors at their default rates, sorted by name
+func ListScenarios() []ScenarioInfo {
+	return DefaultScenarios().List()
+}
+
+// List describes the registry's scenarios, sorted by name
+func (r *Registry) List() []ScenarioInfo {
+	infos := make([]ScenarioInfo, 0, len(r.scenarios))
+	for _, name := range r.Names() {
+		s := r.scenarios[name]
+		infos = append(infos, ScenarioInfo{
+			Name:          s.Name,
+			Category:      s.Class,
+			Parameters:    s.parameters(),
+			FailureRate:   s.FailureRate,
+			EffectiveRate: s.EffectiveFailureRate(),
+			Message:       s.Message,
+			Disabled:      s.Disabled,
+		})
+	}
+	return infos
+}
+
+// parameters returns the scenario's settings as a scenario file writes
+// them, leaving out the fields ScenarioInfo has its own for
+func (s Scenario) parameters() map[string]any {
+	data, err := json.Marshal(s)
+	if err != nil {
+		panic(fmt.Sprintf("flaky: scenario %s: %v", s.Name, err))
+	}
+	var params map[string]any
+	if err := json.Unmarshal(data, &params); err != nil {
+		panic(fmt.Sprintf("flaky: scenario %s: %v", s.Name, err))
+	}
+	for _, key := range []string{"name", "class", "failure_rate", "message"} {
+		delete(params, key)
+	}
+	if len(params) == 0 {
+		return nil
+	}
+	return params
+}
+
+// FormatParameters writes params as sorted key=value pairs, nested settings
+// as dotted keys such as latency.max=5ms
+func FormatParameters(params map[string]any) string {
+	var pairs []string
+	var walk func(prefix string, m map[string]any)
+	walk = func(prefix string, m map[string]any) {
+		for k, v := range m {
+			if nested, ok := v.(map[string]any); ok {
+				walk(prefix+k+".", nested)
+				continue
+			}
+			pairs = append(pairs, fmt.Sprintf("%s%s=%v", prefix, k, v))
+		}
+	}
+	walk("", params)
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
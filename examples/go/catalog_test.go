@@ -0,0 +1,68 @@
+package flaky
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+)
+
+func TestListScenarios(t *testing.T) {
+	infos := ListScenarios()
+	if len(infos) != len(DefaultScenarios().Names()) {
+		t.Fatalf("Expected every default scenario, got %d", len(infos))
+	}
+	if !sort.SliceIsSorted(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name }) {
+		t.Error("Expected scenarios sorted by name")
+	}
+	byName := make(map[string]ScenarioInfo)
+	for _, info := range infos {
+		byName[info.Name] = info
+	}
+
+	random := byName["RandomFailure"]
+	if random.Category != "random" || random.FailureRate != 0.3 || random.EffectiveRate != 0.3 || random.Parameters != nil {
+		t.Errorf("Unexpected RandomFailure: %+v", random)
+	}
+	timing := byName["TimingDependent"]
+	if timing.Category != "timing" || timing.Parameters["timeout"] != "4ms" {
+		t.Errorf("Expected TimingDependent's timeout, got %+v", timing)
+	}
+	if timing.EffectiveRate <= 0 || timing.EffectiveRate >= 1 {
+		t.Errorf("Expected a rate derived from the latency, got %v", timing.EffectiveRate)
+	}
+	if got := FormatParameters(timing.Parameters); got != "latency.max=5ms latency.min=1ms latency.step=1ms timeout=4ms" {
+		t.Errorf("Unexpected TimingDependent parameters %q", got)
+	}
+	if got := FormatParameters(byName["DriftingFailure"].Parameters); got != "drift.runs=100 drift.to=0.4 drift.type=ramp" {
+		t.Errorf("Unexpected DriftingFailure parameters %q", got)
+	}
+	if _, err := json.Marshal(infos); err != nil {
+		t.Errorf("Expected the list to marshal, got %v", err)
+	}
+}
+
+func TestRegistryListDisabled(t *testing.T) {
+	path := writeConfig(t, "flaky.yaml", `
+select:
+  include: [network]
+scenarios:
+  - name: NetworkSimulation
+    failure_rate: 0.05
+`)
+	r, err := LoadScenarios(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range r.List() {
+		switch info.Name {
+		case "NetworkSimulation":
+			if info.Disabled || info.FailureRate != 0.05 {
+				t.Errorf("Expected the configured rate, got %+v", info)
+			}
+		case "RandomFailure":
+			if !info.Disabled || info.EffectiveRate != 0 {
+				t.Errorf("Expected RandomFailure disabled, got %+v", info)
+			}
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Package clock abstracts time so timing-dependent code can be driven by a
+// fake clock in tests
+//
+// A FakeClock only moves when told to: Advance and Tick move it explicitly,
+// and Sleep moves it by the slept duration instead of blocking, so simulated
+// latency costs no real time and is the same on every run
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the subset of package time that timing-dependent code needs
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker delivers ticks on C until stopped
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Timer is a pending AfterFunc call
+type Timer interface {
+	// Stop cancels the call, reporting whether it had not run yet
+	Stop() bool
+}
+
+// Real returns a Clock backed by package time
+func Real() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+type realTicker struct{ t *time.Ticker }
+
+func (r realTicker) C() <-chan time.Time { return r.t.C }
+func (r realTicker) Stop()               { r.t.Stop() }
+
+// FakeClock is a Clock whose time only moves when advanced
+// It is safe for concurrent use
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// fakeTimer is a pending After channel, ticker or AfterFunc call; period is
+// zero for After and AfterFunc, and fn is set only for AfterFunc
+type fakeTimer struct {
+	when   time.Time
+	period time.Duration
+	ch     chan time.Time
+	fn     func()
+}
+
+// NewFake returns a FakeClock reading start
+func NewFake(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the fake time
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Since returns the fake time elapsed since t
+func (f *FakeClock) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// Sleep advances the clock by d instead of blocking
+func (f *FakeClock) Sleep(d time.Duration) {
+	f.Advance(d)
+}
+
+// After returns a channel that receives the fake time once the clock has
+// advanced by d
+func (f *FakeClock) After(d time.Duration) <-chan time.Time {
+	return f.schedule(d, 0).ch
+}
+
+// NewTicker returns a Ticker that ticks every d of fake time
+// Like time.Ticker it drops ticks a slow receiver misses
+func (f *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return &fakeTicker{clock: f, timer: f.schedule(d, d)}
+}
+
+// AfterFunc calls f once the clock has advanced by d
+// The Advance, Sleep or Tick that reaches it calls f itself, before
+// returning, so f has finished by the time Sleep returns to the code that
+// slept past d; f may use the clock
+func (f *FakeClock) AfterFunc(d time.Duration, fn func()) Timer {
+	if d <= 0 {
+		fn()
+		return &fakeFunc{}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTimer{when: f.now.Add(d), fn: fn}
+	f.timers = append(f.timers, t)
+	return &fakeFunc{clock: f, timer: t}
+}
+
+func (f *FakeClock) schedule(d, period time.Duration) *fakeTimer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTimer{when: f.now.Add(d), period: period, ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.ch <- f.now
+		if period == 0 {
+			return t
+		}
+		t.when = f.now.Add(period)
+	}
+	f.timers = append(f.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, firing every timer and tick that
+// falls due on the way in deadline order
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.advanceTo(f.now.Add(d))
+}
+
+// Set steps the clock to t, forward or backward, like an NTP correction or a
+// repeated leap second
+// Pending timers keep their remaining durations, as time.Timer does because
+// it runs on the monotonic clock; FakeClock has no separate monotonic reading,
+// so Since sees the step
+func (f *FakeClock) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	step := t.Sub(f.now)
+	for _, timer := range f.timers {
+		timer.when = timer.when.Add(step)
+	}
+	f.now = t
+}
+
+// Tick advances the clock to the next pending timer or tick, fires it and
+// returns how far the clock moved; it returns 0 when nothing is pending
+func (f *FakeClock) Tick() time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.timers) == 0 {
+		return 0
+	}
+	f.sortTimers()
+	start := f.now
+	f.advanceTo(f.timers[0].when)
+	return f.now.Sub(start)
+}
+
+// Pending returns the number of timers and tickers waiting to fire
+func (f *FakeClock) Pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.timers)
+}
+
+func (f *FakeClock) advanceTo(target time.Time) {
+	for {
+		f.sortTimers()
+		if len(f.timers) == 0 || f.timers[0].when.After(target) {
+			break
+		}
+		t := f.timers[0]
+		f.now = t.when
+		if t.fn != nil {
+			f.timers = f.timers[1:]
+			f.mu.Unlock()
+			t.fn()
+			f.mu.Lock()
+			continue
+		}
+		select {
+		case t.ch <- t.when:
+		default:
+		}
+		if t.period > 0 {
+			t.when = t.when.Add(t.period)
+		} else {
+			f.timers = f.timers[1:]
+		}
+	}
+	if target.After(f.now) {
+		f.now = target
+	}
+}
+
+func (f *FakeClock) sortTimers() {
+	sort.SliceStable(f.timers, func(i, j int) bool { return f.timers[i].when.Before(f.timers[j].when) })
+}
+
+// remove drops t from the pending timers, reporting whether it was pending
+func (f *FakeClock) remove(t *fakeTimer) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, pending := range f.timers {
+		if pending == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTicker struct {
+	clock *FakeClock
+	timer *fakeTimer
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.timer.ch }
+func (t *fakeTicker) Stop()               { t.clock.remove(t.timer) }
+
+type fakeFunc struct {
+	clock *FakeClock
+	timer *fakeTimer
+}
+
+func (t *fakeFunc) Stop() bool {
+	if t.clock == nil {
+		return false
+	}
+	return t.clock.remove(t.timer)
+}
@@ -0,0 +1,214 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeSleepAdvancesInstantly(t *testing.T) {
+	clk := NewFake(epoch)
+	start := time.Now()
+	clk.Sleep(time.Hour)
+	if time.Since(start) > time.Second {
+		t.Error("Expected Sleep not to block")
+	}
+	if got := clk.Since(epoch); got != time.Hour {
+		t.Errorf("Expected 1h elapsed, got %v", got)
+	}
+}
+
+func TestFakeAfterFiresOnAdvance(t *testing.T) {
+	clk := NewFake(epoch)
+	ch := clk.After(10 * time.Millisecond)
+
+	clk.Advance(9 * time.Millisecond)
+	select {
+	case <-ch:
+		t.Fatal("After fired early")
+	default:
+	}
+
+	clk.Advance(time.Millisecond)
+	select {
+	case got := <-ch:
+		if want := epoch.Add(10 * time.Millisecond); !got.Equal(want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	default:
+		t.Fatal("After did not fire")
+	}
+	if clk.Pending() != 0 {
+		t.Errorf("Expected no pending timers, got %d", clk.Pending())
+	}
+}
+
+func TestFakeAfterNonPositiveFiresImmediately(t *testing.T) {
+	clk := NewFake(epoch)
+	select {
+	case <-clk.After(0):
+	default:
+		t.Error("Expected After(0) to fire immediately")
+	}
+}
+
+func TestFakeTickerDropsMissedTicks(t *testing.T) {
+	clk := NewFake(epoch)
+	ticker := clk.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	clk.Advance(3500 * time.Millisecond)
+	if got := <-ticker.C(); !got.Equal(epoch.Add(time.Second)) {
+		t.Errorf("Expected the first tick to be kept, got %v", got)
+	}
+	select {
+	case got := <-ticker.C():
+		t.Errorf("Expected missed ticks to be dropped, got %v", got)
+	default:
+	}
+
+	clk.Advance(500 * time.Millisecond)
+	if got := <-ticker.C(); !got.Equal(epoch.Add(4 * time.Second)) {
+		t.Errorf("Expected a tick at 4s, got %v", got)
+	}
+}
+
+func TestFakeTickMovesToNextDeadline(t *testing.T) {
+	clk := NewFake(epoch)
+	late := clk.After(5 * time.Second)
+	early := clk.After(2 * time.Second)
+
+	if d := clk.Tick(); d != 2*time.Second {
+		t.Errorf("Expected Tick to advance 2s, got %v", d)
+	}
+	select {
+	case <-early:
+	default:
+		t.Error("Expected the 2s timer to fire")
+	}
+	if d := clk.Tick(); d != 3*time.Second {
+		t.Errorf("Expected Tick to advance 3s, got %v", d)
+	}
+	<-late
+	if d := clk.Tick(); d != 0 {
+		t.Errorf("Expected Tick with nothing pending to return 0, got %v", d)
+	}
+}
+
+func TestFakeTickerStop(t *testing.T) {
+	clk := NewFake(epoch)
+	ticker := clk.NewTicker(time.Second)
+	ticker.Stop()
+	clk.Advance(time.Minute)
+	select {
+	case <-ticker.C():
+		t.Error("Expected a stopped ticker not to tick")
+	default:
+	}
+}
+
+func TestFakeSetStepsWithoutFiringTimers(t *testing.T) {
+	clk := NewFake(epoch)
+	ch := clk.After(2 * time.Second)
+
+	clk.Set(epoch.Add(time.Hour))
+	if got := clk.Since(epoch); got != time.Hour {
+		t.Errorf("Expected the clock to read 1h after epoch, got %v", got)
+	}
+	select {
+	case <-ch:
+		t.Fatal("Set forward fired a timer")
+	default:
+	}
+
+	clk.Set(epoch.Add(-time.Second))
+	clk.Advance(2 * time.Second)
+	select {
+	case got := <-ch:
+		if want := epoch.Add(time.Second); !got.Equal(want) {
+			t.Errorf("Expected the timer to fire 2s after it was set, at %v, got %v", want, got)
+		}
+	default:
+		t.Fatal("Timer did not keep its remaining duration across steps")
+	}
+}
+
+func TestFakeAfterFuncRunsBeforeSleepReturns(t *testing.T) {
+	clk := NewFake(epoch)
+	var at time.Duration
+	var ran bool
+	clk.AfterFunc(10*time.Millisecond, func() {
+		ran = true
+		at = clk.Since(epoch)
+	})
+	stopped := clk.AfterFunc(5*time.Millisecond, func() { t.Error("Stopped func ran") })
+	if !stopped.Stop() {
+		t.Error("Expected Stop to cancel a pending func")
+	}
+
+	clk.Sleep(9 * time.Millisecond)
+	if ran {
+		t.Fatal("AfterFunc ran early")
+	}
+	clk.Sleep(5 * time.Millisecond)
+	if !ran || at != 10*time.Millisecond {
+		t.Errorf("Expected the func to run at 10ms during Sleep, got %v, %v", ran, at)
+	}
+	if clk.Pending() != 0 || stopped.Stop() {
+		t.Errorf("Expected nothing left pending, got %d", clk.Pending())
+	}
+}
+
+func TestSkewedClockReadsOffsetAndDrift(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ref := NewFake(start)
+	fast := NewSkewed(ref, 2*time.Second, 0.001)
+	if got := fast.Now(); !got.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("Expected the skew at creation, got %v", got)
+	}
+	ref.Advance(1000 * time.Second)
+	if got := fast.Offset(); got != 3*time.Second {
+		t.Errorf("Expected 2s skew plus 1s of drift after 1000s, got %v", got)
+	}
+
+	slow := NewSkewed(ref, -time.Second, -0.5)
+	slow.Sleep(10 * time.Second)
+	if got := ref.Now().Sub(start); got != 1020*time.Second {
+		t.Errorf("Expected 10s on a clock at half speed to take 20s of true time, got %v", got-1000*time.Second)
+	}
+	if got := slow.Since(start.Add(1000 * time.Second)); got != 9*time.Second {
+		t.Errorf("Expected the slow clock 1s behind after its 10s sleep, got %v", got)
+	}
+}
+
+func TestSkewedClockTimersUseItsOwnTime(t *testing.T) {
+	ref := NewFake(time.Unix(0, 0))
+	slow := NewSkewed(ref, 0, -0.5)
+	after := slow.After(time.Second)
+	ticker := slow.NewTicker(time.Second)
+	defer ticker.Stop()
+	fired := false
+	slow.AfterFunc(time.Second, func() { fired = true })
+
+	ref.Advance(1500 * time.Millisecond)
+	select {
+	case <-after:
+		t.Fatal("After fired before the slow clock reached 1s")
+	default:
+	}
+	ref.Advance(500 * time.Millisecond)
+	if got := <-after; !got.Equal(time.Unix(1, 0)) {
+		t.Errorf("Expected After to receive the component's time 1s, got %v", got)
+	}
+	if !fired {
+		t.Error("Expected AfterFunc to run at 1s of the component's time")
+	}
+	if got := <-ticker.C(); !got.Equal(time.Unix(1, 0)) {
+		t.Errorf("Expected a tick at 1s, got %v", got)
+	}
+	ref.Advance(2 * time.Second)
+	if got := <-ticker.C(); !got.Equal(time.Unix(2, 0)) {
+		t.Errorf("Expected the next tick at 2s, got %v", got)
+	}
+}
@@ -0,0 +1,115 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// SkewedClock is one component's view of a shared FakeClock: it reads off by
+// a fixed skew and runs fast or slow by a drift rate, as the clock of a host
+// that NTP has not corrected does
+// The FakeClock is true time; sleeping on a SkewedClock advances it, and
+// timers set on one fire when the component's own clock reaches them
+type SkewedClock struct {
+	ref   *FakeClock
+	start time.Time
+	skew  time.Duration
+	drift float64
+}
+
+// NewSkewed returns a clock reading ref off by skew from now on, gaining
+// drift seconds per second of ref time; 50e-6 is a clock 50ppm fast
+// It panics if drift is -1 or less, which would stop or reverse the clock
+func NewSkewed(ref *FakeClock, skew time.Duration, drift float64) *SkewedClock {
+	if drift <= -1 {
+		panic("clock: drift of -1 or less for NewSkewed")
+	}
+	return &SkewedClock{ref: ref, start: ref.Now(), skew: skew, drift: drift}
+}
+
+// Now returns the component's reading of the time
+func (s *SkewedClock) Now() time.Time {
+	elapsed := s.ref.Now().Sub(s.start)
+	return s.start.Add(s.skew + elapsed + time.Duration(float64(elapsed)*s.drift))
+}
+
+// Since returns the component's time elapsed since t
+func (s *SkewedClock) Since(t time.Time) time.Duration {
+	return s.Now().Sub(t)
+}
+
+// Offset returns how far the component's clock is ahead of true time, or
+// behind it when negative
+func (s *SkewedClock) Offset() time.Duration {
+	return s.Now().Sub(s.ref.Now())
+}
+
+// Sleep advances true time until the component's clock has moved by d
+func (s *SkewedClock) Sleep(d time.Duration) {
+	s.ref.Advance(s.toRef(d))
+}
+
+// After returns a channel that receives the component's time once its clock
+// has moved by d
+func (s *SkewedClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	s.ref.AfterFunc(s.toRef(d), func() { ch <- s.Now() })
+	return ch
+}
+
+// NewTicker returns a Ticker that ticks every d of the component's time
+// Like time.Ticker it drops ticks a slow receiver misses
+func (s *SkewedClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	t := &skewedTicker{clock: s, period: d, ch: make(chan time.Time, 1)}
+	t.schedule()
+	return t
+}
+
+// AfterFunc calls f once the component's clock has moved by d
+func (s *SkewedClock) AfterFunc(d time.Duration, f func()) Timer {
+	return s.ref.AfterFunc(s.toRef(d), f)
+}
+
+// toRef converts a duration of the component's time to true time
+func (s *SkewedClock) toRef(d time.Duration) time.Duration {
+	return time.Duration(float64(d) / (1 + s.drift))
+}
+
+type skewedTicker struct {
+	clock  *SkewedClock
+	period time.Duration
+	ch     chan time.Time
+
+	mu      sync.Mutex
+	timer   Timer
+	stopped bool
+}
+
+func (t *skewedTicker) schedule() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped {
+		return
+	}
+	t.timer = t.clock.AfterFunc(t.period, func() {
+		select {
+		case t.ch <- t.clock.Now():
+		default:
+		}
+		t.schedule()
+	})
+}
+
+func (t *skewedTicker) C() <-chan time.Time { return t.ch }
+
+func (t *skewedTicker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
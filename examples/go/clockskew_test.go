@@ -0,0 +1,75 @@
+package flaky_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/clock"
+)
+
+// tokenSkew is how far the clocks of the token issuer and verifier can be
+// off when they are unsynced
+var tokenSkew = flaky.SkewProfile{MaxSkew: 2 * time.Second, MaxDrift: 100e-6}
+
+// token is a bearer token valid from IssuedAt until ExpiresAt
+type token struct {
+	IssuedAt, ExpiresAt time.Time
+}
+
+// issueToken issues a token valid for ttl from now on the issuer's clock
+func issueToken(c clock.Clock, ttl time.Duration) token {
+	now := c.Now()
+	return token{IssuedAt: now, ExpiresAt: now.Add(ttl)}
+}
+
+// verifyToken checks tok against the verifier's clock, allowing leeway
+// either side of its validity window
+func verifyToken(c clock.Clock, tok token, leeway time.Duration) error {
+	now := c.Now()
+	if now.Add(leeway).Before(tok.IssuedAt) {
+		return fmt.Errorf("token used before it was issued (%v early)", tok.IssuedAt.Sub(now))
+	}
+	if !now.Add(-leeway).Before(tok.ExpiresAt) {
+		return fmt.Errorf("token expired %v ago", now.Sub(tok.ExpiresAt))
+	}
+	return nil
+}
+
+// issueAndVerify issues a token on one component's clock and verifies it on
+// another's one network hop later
+func issueAndVerify(t *testing.T, leeway time.Duration) error {
+	sc := flaky.ScenarioForTest(t, "ClockSkew")
+	profile := tokenSkew
+	profile.Unsynced = sc.FailureRate
+	ref := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	clocks := flaky.ForTest(t).SkewedClocks(ref, 2, profile)
+	issuer, verifier := clocks[0], clocks[1]
+
+	tok := issueToken(issuer, 5*time.Minute)
+	ref.Advance(100 * time.Millisecond)
+	if err := verifyToken(verifier, tok, leeway); err != nil {
+		return fmt.Errorf("%s: %w (issuer off by %v, verifier by %v)", sc.Message, err, issuer.Offset(), verifier.Offset())
+	}
+	return nil
+}
+
+// TestClockSkew demonstrates a token rejected because the issuer and the
+// verifier compare its validity on clocks that disagree
+// Each clock is unsynced 30% of the time by default, by up to 2s; the test
+// fails about a quarter of the time, whenever the verifier's clock is more
+// than the 100ms hop behind the issuer's
+func TestClockSkew(t *testing.T) {
+	if err := issueAndVerify(t, 0); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestClockSkewFixed is the reliable variant of TestClockSkew
+// The verifier allows a leeway of twice the skew any one clock can have
+func TestClockSkewFixed(t *testing.T) {
+	if err := issueAndVerify(t, 2*tokenSkew.MaxSkew); err != nil {
+		t.Error(err)
+	}
+}
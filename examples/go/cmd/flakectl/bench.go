@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func runBench(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	runs := fs.Int("runs", 10, "number of times to run the benchmarks")
+	seed := fs.Int64("seed", 1, "seed of the first run; run i uses seed+i")
+	bench := fs.String("bench", ".", "only run benchmarks matching this regex")
+	benchtime := fs.String("benchtime", "", "go test -benchtime of each run, such as 1s or 1000x")
+	dir := fs.String("dir", "", "directory to run go test in")
+	threshold := fs.Float64("threshold", 0.05, "coefficient of variation above which a benchmark is flagged unstable")
+	packages, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *threshold < 0 {
+		return fmt.Errorf("--threshold must not be negative, got %v", *threshold)
+	}
+	cfg := runner.Config{Packages: packages, Runs: *runs, Seed: *seed, Dir: *dir}
+	if *benchtime != "" {
+		cfg.Args = []string{"-benchtime=" + *benchtime}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := runner.DetectBench(ctx, cfg, *bench)
+	if err != nil {
+		return err
+	}
+	if len(report.Benchmarks) == 0 {
+		return fmt.Errorf("no benchmarks matched %q", *bench)
+	}
+	return printBench(stdout, report, *threshold)
+}
+
+// printBench writes the spread of each benchmark across runs and names the
+// ones whose coefficient of variation exceeds threshold
+func printBench(w io.Writer, report *runner.BenchReport, threshold float64) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BENCHMARK\tMEAN NS/OP\tSTDDEV\tCV\tMIN\tMAX\tOUTLIER RUNS\tVERDICT")
+	var unstable []string
+	for _, b := range report.Benchmarks {
+		sp := b.Spread()
+		verdict := "stable"
+		if b.Unstable(threshold) {
+			verdict = "unstable"
+			unstable = append(unstable, b.Benchmark)
+		}
+		outliers := "-"
+		if runs := b.OutlierRuns(); len(runs) > 0 {
+			outliers = strings.Trim(fmt.Sprint(runs), "[]")
+		}
+		fmt.Fprintf(tw, "%s\t%.1f\t%.1f\t%.1f%%\t%.1f\t%.1f\t%s\t%s\n",
+			b.Benchmark, sp.Mean, sp.StdDev, sp.CV*100, sp.Min, sp.Max, outliers, verdict)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(unstable) > 0 {
+		fmt.Fprintf(w, "\n%d benchmark(s) swung more than %.0f%% between %d runs: %s\n",
+			len(unstable), threshold*100, report.Runs, strings.Join(unstable, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestPrintBench(t *testing.T) {
+	report := &runner.BenchReport{Runs: 5, Benchmarks: []*runner.BenchStats{
+		{Benchmark: "BenchmarkSteady-8", NsPerOp: []float64{100, 102, 99, 101, 100}, SampleRuns: []int{0, 1, 2, 3, 4}},
+		{Benchmark: "BenchmarkSwing-8", NsPerOp: []float64{100, 101, 99, 100, 300}, SampleRuns: []int{0, 1, 2, 3, 4}},
+	}}
+	var out bytes.Buffer
+	if err := printBench(&out, report, 0.05); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	// The last two columns are the outlier runs and the verdict
+	steady, swing := strings.Fields(lines[1]), strings.Fields(lines[2])
+	if steady[0] != "BenchmarkSteady-8" || strings.Join(steady[6:], " ") != "- stable" {
+		t.Errorf("Expected the steady benchmark stable without outliers, got %q", lines[1])
+	}
+	if swing[0] != "BenchmarkSwing-8" || strings.Join(swing[6:], " ") != "4 unstable" {
+		t.Errorf("Expected run 4 as the outlier of an unstable benchmark, got %q", lines[2])
+	}
+	if want := "1 benchmark(s) swung more than 5% between 5 runs: BenchmarkSwing-8"; !strings.Contains(out.String(), want) {
+		t.Errorf("Expected %q in:\n%s", want, out.String())
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/example/flaky-test-example/internal/bisect"
+)
+
+func runBisectOrder(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("bisect-order", flag.ContinueOnError)
+	shuffles := fs.Int("shuffles", 20, "number of shuffled orders to try")
+	firstShuffle := fs.Int64("first-shuffle", 1, "-shuffle seed of the first order; order i uses first-shuffle+i")
+	seed := fs.Int64("seed", 1, "GO_TEST_SEED used by every run, so only the order varies")
+	dir := fs.String("dir", "", "directory to run go test in")
+	exhaustive := fs.Bool("exhaustive", false, "run the tests in every order (every ordered pair above --max-permuted tests) instead of shuffling")
+	runRegex := fs.String("run", "", "only order tests matching this regex (with --exhaustive)")
+	maxPermuted := fs.Int("max-permuted", bisect.DefaultMaxPermuted, "most tests --exhaustive runs in every order")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return errors.New("usage: flakectl bisect-order [package] [--shuffles N | --exhaustive]")
+	}
+	pkg := "."
+	if len(positional) == 1 {
+		pkg = positional[0]
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg := bisect.Config{
+		Package:      pkg,
+		Dir:          *dir,
+		Seed:         *seed,
+		Shuffles:     *shuffles,
+		FirstShuffle: *firstShuffle,
+		Run:          *runRegex,
+		MaxPermuted:  *maxPermuted,
+	}
+	if *exhaustive {
+		res, err := bisect.Exhaustive(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		printExhaustive(stdout, pkg, res)
+		return nil
+	}
+	if *runRegex != "" {
+		return errors.New("--run only applies with --exhaustive")
+	}
+	res, err := bisect.Run(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	printBisect(stdout, pkg, res)
+	return nil
+}
+
+// maxListedOrders is how many failing orders printExhaustive lists
+const maxListedOrders = 10
+
+// printExhaustive writes the failing orderings and what explains them
+func printExhaustive(w io.Writer, pkg string, res *bisect.ExhaustiveResult) {
+	failing := res.FailingOrderings()
+	kind := "orders"
+	if res.Pairs {
+		kind = "ordered pairs"
+	}
+	fmt.Fprintf(w, "Ran all %d %s of %d tests in %s, %d failed\n", len(res.Orderings), kind, len(res.Tests), pkg, len(failing))
+	for i, o := range failing {
+		if i == maxListedOrders {
+			fmt.Fprintf(w, "  ... and %d more\n", len(failing)-i)
+			break
+		}
+		fmt.Fprintf(w, "  -shuffle %d: %s (failed: %s)\n", o.Shuffle, strings.Join(o.Tests, ", "), strings.Join(o.Failed, ", "))
+	}
+	printCulprits(w, pkg, res.Culprits, res.Unresolved)
+}
+
+// printBisect writes the failing orders and the culprits found for them
+func printBisect(w io.Writer, pkg string, res *bisect.Result) {
+	failing := res.FailingOrders()
+	fmt.Fprintf(w, "Ran %d shuffled orders of %s, %d failed\n", len(res.Orders), pkg, len(failing))
+	for _, o := range failing {
+		fmt.Fprintf(w, "  -shuffle %d: %s\n", o.Shuffle, strings.Join(o.Failed, ", "))
+	}
+
+	printCulprits(w, pkg, res.Culprits, res.Unresolved)
+}
+
+// printCulprits writes the order-dependent failures and the ones that could
+// not be attributed to ordering
+func printCulprits(w io.Writer, pkg string, culprits []bisect.Culprit, unresolved []bisect.Unresolved) {
+	if len(culprits) == 0 && len(unresolved) == 0 {
+		fmt.Fprintln(w, "\nNo order-dependent failures found")
+		return
+	}
+	if len(culprits) > 0 {
+		fmt.Fprintln(w, "\nOrder-dependent failures:")
+		for _, c := range culprits {
+			fmt.Fprintf(w, "  %s -> %s\n", strings.Join(c.Polluters, " + "), c.Victim)
+			fmt.Fprintf(w, "    go test %s -run '%s' -shuffle %d\n", pkg, c.Pattern(), c.Shuffle)
+		}
+	}
+	if len(unresolved) > 0 {
+		fmt.Fprintln(w, "\nFailed in some orders but not attributable to ordering:")
+		for _, u := range unresolved {
+			fmt.Fprintf(w, "  %s: %s\n", u.Test, u.Reason)
+		}
+	}
+}
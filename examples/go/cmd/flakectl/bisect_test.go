@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/bisect"
+)
+
+func TestPrintBisect(t *testing.T) {
+	res := &bisect.Result{
+		Orders: []bisect.Order{
+			{Shuffle: 1, Tests: []string{"TestVictim", "TestPolluter"}},
+			{Shuffle: 2, Tests: []string{"TestPolluter", "TestVictim"}, Failed: []string{"TestVictim"}},
+		},
+		Culprits:   []bisect.Culprit{{Victim: "TestVictim", Polluters: []string{"TestPolluter"}, Shuffle: 2}},
+		Unresolved: []bisect.Unresolved{{Test: "TestRace", Reason: "fails when run alone"}},
+	}
+	var out bytes.Buffer
+	printBisect(&out, "./pkg", res)
+	for _, want := range []string{
+		"Ran 2 shuffled orders of ./pkg, 1 failed",
+		"-shuffle 2: TestVictim",
+		"TestPolluter -> TestVictim",
+		"go test ./pkg -run '^(TestPolluter|TestVictim)$' -shuffle 2",
+		"TestRace: fails when run alone",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestPrintExhaustive(t *testing.T) {
+	res := &bisect.ExhaustiveResult{
+		Tests: []string{"TestVictim", "TestPolluter"},
+		Orderings: []bisect.Ordering{
+			{Shuffle: 1, Tests: []string{"TestVictim", "TestPolluter"}},
+			{Shuffle: 3, Tests: []string{"TestPolluter", "TestVictim"}, Failed: []string{"TestVictim"}},
+		},
+		Culprits: []bisect.Culprit{{Victim: "TestVictim", Polluters: []string{"TestPolluter"}, Shuffle: 3}},
+	}
+	var out bytes.Buffer
+	printExhaustive(&out, "./pkg", res)
+	for _, want := range []string{
+		"Ran all 2 orders of 2 tests in ./pkg, 1 failed",
+		"-shuffle 3: TestPolluter, TestVictim (failed: TestVictim)",
+		"TestPolluter -> TestVictim",
+		"go test ./pkg -run '^(TestPolluter|TestVictim)$' -shuffle 3",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+
+	res.Pairs = true
+	out.Reset()
+	printExhaustive(&out, "./pkg", res)
+	if !strings.Contains(out.String(), "Ran all 2 ordered pairs of 2 tests") {
+		t.Errorf("Expected pair mode in the header:\n%s", out.String())
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/example/flaky-test-example/internal/checks"
+	reportfmt "github.com/example/flaky-test-example/internal/report"
+)
+
+func runChecks(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("checks", flag.ContinueOnError)
+	reportPath := fs.String("report", "", "JSON flake report to publish, as written by flakectl detect --json")
+	repo := fs.String("repo", os.Getenv("GITHUB_REPOSITORY"), "repository as owner/name (default $GITHUB_REPOSITORY)")
+	sha := fs.String("sha", os.Getenv("GITHUB_SHA"), "commit to attach the check run to (default $GITHUB_SHA)")
+	token := fs.String("token", os.Getenv("GITHUB_TOKEN"), "token allowed to write checks (default $GITHUB_TOKEN)")
+	apiURL := fs.String("api-url", envOr("GITHUB_API_URL", checks.DefaultAPIBase), "GitHub API base URL (default $GITHUB_API_URL)")
+	name := fs.String("name", "flaky tests", "name of the check run")
+	dir := fs.String("dir", "", "directory the report's packages were tested in, to locate their tests")
+	packages, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *reportPath == "" {
+		return errors.New("usage: flakectl checks --report flake-report.json [--repo owner/name] [--sha commit] [packages]")
+	}
+	if *token == "" {
+		return errors.New("checks needs --token or GITHUB_TOKEN")
+	}
+	r, err := reportfmt.LoadJSON(*reportPath)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	locate, err := reportfmt.LocateTests(ctx, *dir, packages)
+	if err != nil {
+		return err
+	}
+	p := &checks.Publisher{Repo: *repo, Token: *token, Name: *name, APIBase: *apiURL}
+	run, err := p.Publish(ctx, *sha, r, locate)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Published %s check run with %d annotation(s): %s\n", checks.Conclusion(r), len(checks.Annotations(r, locate)), run.HTMLURL)
+	return nil
+}
+
+// envOr returns the environment variable key, or def when it is unset or
+// empty
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/checks"
+	reportfmt "github.com/example/flaky-test-example/internal/report"
+)
+
+func TestRunChecksAnnotatesTestDeclarations(t *testing.T) {
+	var posted struct {
+		HeadSHA string        `json:"head_sha"`
+		Output  checks.Output `json:"output"`
+	}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/o/r/check-runs" || r.Header.Get("Authorization") != "Bearer token" {
+			http.NotFound(w, r)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &posted)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"id": 1, "html_url": "https://github.com/o/r/runs/1"}`)
+	}))
+	defer api.Close()
+
+	report := &reportfmt.JSONReport{
+		Runs:    4,
+		Summary: reportfmt.JSONSummary{Tests: 1, Flaky: 1},
+		Tests: []reportfmt.JSONTest{{
+			Package: "github.com/example/flaky-test-example", Test: "TestRandomFailure", Classification: "flaky",
+			Runs: 4, Passed: 3, Failed: 1, FlakeRate: 0.25,
+		}},
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	args := []string{"--report", path, "--repo", "o/r", "--sha", "abc123", "--token", "token", "--api-url", api.URL, "--dir", "../..", "."}
+	if err := runChecks(args, &out); err != nil {
+		t.Fatalf("runChecks failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Published neutral check run with 1 annotation(s): https://github.com/o/r/runs/1") {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+	if posted.HeadSHA != "abc123" || len(posted.Output.Annotations) != 1 {
+		t.Fatalf("Unexpected check run: %+v", posted)
+	}
+	if a := posted.Output.Annotations[0]; !strings.HasSuffix(a.Path, "flaky_test.go") || a.StartLine == 0 {
+		t.Errorf("Expected TestRandomFailure annotated in flaky_test.go, got %+v", a)
+	}
+
+	if err := runChecks([]string{"--report", path, "--token", ""}, &out); err == nil {
+		t.Error("Expected a missing token to be rejected")
+	}
+}
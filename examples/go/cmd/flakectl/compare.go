@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/example/flaky-test-example/internal/compare"
+	"github.com/example/flaky-test-example/internal/history"
+)
+
+func runCompare(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	base := fs.String("base", "", "commit to compare against")
+	head := fs.String("head", "HEAD", "commit to compare")
+	runs := fs.Int("runs", 20, "number of times to run the suite at a commit without stored results")
+	seed := fs.Int64("seed", 1, "seed of the first run; run i uses seed+i")
+	runRegex := fs.String("run", "", "only run tests matching this regex")
+	dir := fs.String("dir", "", "directory inside the git checkout to run go test in")
+	historyFile := fs.String("history", history.DefaultFile, "history database to load stored results from and record new runs in (empty to disable)")
+	rerun := fs.Bool("rerun", false, "run both commits even when the history has results for them")
+	alpha := fs.Float64("alpha", 0.05, "significance level of a flake-rate change")
+	packages, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *base == "" {
+		return errors.New("usage: flakectl compare --base <commit> [--head <commit>] [packages] [--runs 20] [--alpha 0.05]")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	res, err := compare.Run(ctx, compare.Config{
+		Base:     *base,
+		Head:     *head,
+		Packages: packages,
+		Runs:     *runs,
+		Seed:     *seed,
+		Run:      *runRegex,
+		Dir:      *dir,
+		History:  *historyFile,
+		Rerun:    *rerun,
+		Alpha:    *alpha,
+	})
+	if err != nil {
+		return err
+	}
+	return printCompare(stdout, res)
+}
+
+// compareOrder lists the statuses printCompare shows, in order
+var compareOrder = []compare.Status{compare.NewlyFlaky, compare.Worse, compare.Fixed, compare.Better}
+
+// printCompare describes where each commit's results came from, then lists
+// every test whose flake rate changed
+func printCompare(w io.Writer, res *compare.Result) error {
+	for _, side := range []struct {
+		name string
+		side compare.Side
+	}{{"Base", res.Base}, {"Head", res.Head}} {
+		source := fmt.Sprintf("ran %d times", side.side.Report.Runs)
+		if side.side.Stored {
+			source = fmt.Sprintf("%d runs from history", side.side.Report.Runs)
+		}
+		fmt.Fprintf(w, "%s %s: %s\n", side.name, shortCommit(side.side.Commit), source)
+	}
+	fmt.Fprintln(w)
+
+	rank := make(map[compare.Status]int)
+	for i, status := range compareOrder {
+		rank[status] = i + 1
+	}
+	var changed []compare.Change
+	for _, c := range res.Changes {
+		if rank[c.Status] > 0 {
+			changed = append(changed, c)
+		}
+	}
+	if len(changed) == 0 {
+		fmt.Fprintf(w, "No flake-rate changes across %d test(s)\n", len(res.Changes))
+		return nil
+	}
+	sort.SliceStable(changed, func(i, j int) bool { return rank[changed[i].Status] < rank[changed[j].Status] })
+
+	fmt.Fprintf(w, "%d of %d test(s) changed:\n\n", len(changed), len(res.Changes))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TEST\tSTATUS\tBASE\tHEAD\tP-VALUE\tFAILURE")
+	for _, c := range changed {
+		baseRate := "new"
+		if c.Base.Runs() > 0 {
+			baseRate = fmt.Sprintf("%.1f%% (%d/%d)", c.Base.FlakeRate()*100, c.Base.Failed, c.Base.Runs())
+		}
+		pValue := fmt.Sprintf("%.3f", c.PValue)
+		if c.Significant {
+			pValue += " *"
+		}
+		failure := c.Message
+		if failure == "" {
+			failure = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.1f%% (%d/%d)\t%s\t%s\n",
+			c.Test, c.Status, baseRate, c.Head.FlakeRate()*100, c.Head.Failed, c.Head.Runs(), pValue, failure)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n* significant at p < %g (Fisher's exact test)\n", res.Alpha)
+	return nil
+}
+
+// shortCommit abbreviates a commit hash the way git log --oneline does
+func shortCommit(commit string) string {
+	return commit[:min(len(commit), 7)]
+}
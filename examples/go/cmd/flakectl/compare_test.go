@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/compare"
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestPrintCompare(t *testing.T) {
+	res := &compare.Result{
+		Base:  compare.Side{Commit: "0123456789abcdef", Stored: true, Report: &runner.Report{Runs: 20}},
+		Head:  compare.Side{Commit: "fedcba9876543210", Report: &runner.Report{Runs: 20}},
+		Alpha: 0.05,
+		Changes: []compare.Change{
+			{Test: "TestFixed", Base: history.Counts{Passed: 15, Failed: 5}, Head: history.Counts{Passed: 20}, PValue: 0.047, Significant: true, Status: compare.Fixed},
+			{Test: "TestSame", Base: history.Counts{Passed: 20}, Head: history.Counts{Passed: 20}, PValue: 1, Status: compare.Unchanged},
+			{Test: "TestNew", Head: history.Counts{Passed: 19, Failed: 1}, PValue: 1, Status: compare.NewlyFlaky, Message: "a_test.go:1: boom"},
+		},
+	}
+	var out bytes.Buffer
+	if err := printCompare(&out, res); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{"Base 0123456: 20 runs from history", "Head fedcba9: ran 20 times", "2 of 3 test(s) changed",
+		"25.0% (5/20)", "0.047 *", "a_test.go:1: boom", "significant at p < 0.05"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "TestSame") {
+		t.Errorf("Expected unchanged tests to be left out:\n%s", got)
+	}
+	if strings.Index(got, "TestNew") > strings.Index(got, "TestFixed") {
+		t.Errorf("Expected newly flaky tests before fixed ones:\n%s", got)
+	}
+}
+
+func TestRunCompareRequiresBase(t *testing.T) {
+	if err := runCompare([]string{"--head", "HEAD"}, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Errorf("Expected a usage error without --base, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/metrics"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// serveMetrics serves exporter on addr at /metrics until the returned stop
+// function is called
+func serveMetrics(addr string, exporter *metrics.Exporter, stdout io.Writer) (func(), error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("serve metrics: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(ln)
+	fmt.Fprintf(stdout, "Serving metrics on http://%s/metrics\n", ln.Addr())
+	return func() { srv.Close() }, nil
+}
+
+// detectForever runs sweeps of cfg.Runs runs until ctx is cancelled, each
+// starting at the seed after the previous sweep's last, so every sweep
+// tries new seeds
+// Each sweep is recorded in the history at historyFile, when set, with the
+// changes it makes sent to notes, and summarized in one line
+func detectForever(ctx context.Context, stdout io.Writer, cfg runner.Config, interval time.Duration, historyFile string, notes *notifications) error {
+	for sweep := 1; ; sweep++ {
+		report, err := runner.Detect(ctx, cfg)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if historyFile != "" {
+			if err := notes.record(ctx, stdout, historyFile, report, history.Commit(cfg.Dir)); err != nil {
+				return err
+			}
+		}
+		printSweepSummary(stdout, sweep, cfg.Seed, report)
+		cfg.Seed += int64(report.Runs)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// printSweepSummary writes one line counting the tests of a daemon sweep by
+// classification
+func printSweepSummary(w io.Writer, sweep int, seed int64, report *runner.Report) {
+	counts := make(map[runner.Classification]int)
+	for _, s := range report.Tests {
+		counts[s.Classify()]++
+	}
+	fmt.Fprintf(w, "%s sweep %d (seeds %d-%d): %d tests, %d stable, %d flaky, %d failing\n",
+		time.Now().Format(time.TimeOnly), sweep, seed, seed+int64(report.Runs)-1, len(report.Tests),
+		counts[runner.Stable], counts[runner.Flaky], counts[runner.Failing])
+}
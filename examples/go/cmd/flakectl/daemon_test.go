@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/metrics"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestServeMetrics(t *testing.T) {
+	exporter := metrics.NewExporter()
+	exporter.Observe([]runner.Result{{Package: "p", Test: "TestA", Outcome: runner.Fail}})
+	var out bytes.Buffer
+	stop, err := serveMetrics("127.0.0.1:0", exporter, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	url := regexp.MustCompile(`http://\S+`).FindString(out.String())
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), `test_failures_total{package="p",test="TestA"} 1`) {
+		t.Errorf("Unexpected metrics from %s:\n%s", url, body)
+	}
+}
+
+func TestPrintSweepSummary(t *testing.T) {
+	report := runner.Aggregate(10, []runner.Result{
+		{Package: "p", Test: "TestA", Outcome: runner.Pass},
+		{Package: "p", Test: "TestA", Outcome: runner.Fail},
+		{Package: "p", Test: "TestB", Outcome: runner.Pass},
+	})
+	var out bytes.Buffer
+	printSweepSummary(&out, 3, 21, report)
+	if want := "sweep 3 (seeds 21-30): 2 tests, 1 stable, 1 flaky, 0 failing\n"; !strings.HasSuffix(out.String(), want) {
+		t.Errorf("Expected a summary ending in %q, got %q", want, out.String())
+	}
+}
+
+func TestRunDetectRejectsDaemonReports(t *testing.T) {
+	if err := runDetect([]string{"--daemon", "--json", "out.json"}, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "--daemon") {
+		t.Errorf("Expected --daemon with --json to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,744 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/internal/attach"
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/metrics"
+	"github.com/example/flaky-test-example/internal/notify"
+	"github.com/example/flaky-test-example/internal/owners"
+	reportfmt "github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/internal/tracing"
+	"github.com/example/flaky-test-example/quarantine"
+	"github.com/example/flaky-test-example/stats"
+)
+
+func runDetect(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("detect", flag.ContinueOnError)
+	runs := fs.Int("runs", 10, "number of times to run the suite")
+	seed := fs.Int64("seed", 1, "seed of the first run; run i uses seed+i")
+	runRegex := fs.String("run", "", "only run tests matching this regex")
+	dir := fs.String("dir", "", "directory to run go test in")
+	junitPath := fs.String("junit", "", "also write a JUnit XML flake report to this file")
+	jsonPath := fs.String("json", "", "also write a JSON flake report to this file")
+	sarifPath := fs.String("sarif", "", "also write a SARIF flake report for code scanning to this file")
+	buildkitePath := fs.String("buildkite", "", "also write every run in the Buildkite Test Analytics JSON format to this file")
+	circleciPath := fs.String("circleci", "", "also write every run as JUnit XML for CircleCI test insights to this file")
+	allureDir := fs.String("allure", "", "also write every run as Allure results into this directory, such as allure-results")
+	csvPath := fs.String("csv", "", "also write every run as a CSV row to this file, for analysis in a spreadsheet or notebook")
+	parquetPath := fs.String("parquet", "", "also write every run as a Parquet row to this file, for analysis in pandas, DuckDB or Spark")
+	dumpsDir := fs.String("dumps", "flaky-dumps", "directory to write the goroutine dumps of hung runs to (empty to disable)")
+	attachmentsDir := fs.String("attachments", attach.DefaultDir, "directory to store the output of every failing run in, for flakectl show (empty to disable)")
+	confidence := fs.Float64("confidence", 0.95, "confidence level of the reported flake-rate intervals")
+	adaptive := fs.Bool("adaptive", false, "rerun each test only until its interval classifies it; --runs becomes the minimum")
+	maxRuns := fs.Int("max-runs", 100, "maximum runs per test in --adaptive mode")
+	rerunFailed := fs.Int("rerun-failed", 0, "after the other runs, rerun only the tests that failed at least once this many more times")
+	tolerance := fs.Float64("tolerance", 0.05, "flake rate below which a never-failing test counts as stable in --adaptive mode")
+	sprt := fs.Bool("sprt", false, "decide --adaptive verdicts with a sequential probability ratio test of --tolerance against --flaky-rate; implies --adaptive")
+	flakyRate := fs.Float64("flaky-rate", 0.2, "flake rate --sprt tests --tolerance against; rates between the two take the most runs")
+	maxDuration := fs.Duration("max-duration", 0, "stop starting runs after this long, such as 10m, leaving undecided tests undecided")
+	flakyExitRate := fs.Float64("flaky-exit-rate", 0, "exit 2 when a test's flake rate is above this but below --broken-rate")
+	brokenRate := fs.Float64("broken-rate", 1, "exit 1 when a test's flake rate is at least this, as for a test that fails every run")
+	quarantineBelow := fs.Float64("quarantine-below", 0.95, "suggest quarantining tests whose pass rate is below this")
+	quarantineFile := fs.String("quarantine", "", "quarantine list to check suggestions against (default: "+quarantine.FileEnv+", or "+quarantine.DefaultFile+" in --dir)")
+	historyFile := fs.String("history", history.DefaultFile, "history database to record this run in (empty to disable)")
+	priorWeight := fs.Float64("prior-weight", 50, "most runs of --history a failing test's flake-rate prior is worth (0 to ignore history)")
+	slackWebhook := fs.String("slack-webhook", os.Getenv("SLACK_WEBHOOK_URL"), "Slack incoming webhook to notify of newly flaky and recovered quarantined tests (default $SLACK_WEBHOOK_URL)")
+	webhook := fs.String("webhook", "", "URL to POST newly flaky and recovered quarantined tests to as JSON")
+	notifyFlakeRate := fs.Float64("notify-flake-rate", 0.05, "flake rate above which a test counts as flaky for notifications")
+	notifyPassingRuns := fs.Int("notify-passing-runs", 20, "passing runs in a row after which a quarantined test counts as recovered")
+	ownersFile := fs.String("owners", owners.DefaultFile, "owners file assigning tests to teams, for the per-owner digest and the owners in --json reports and notifications")
+	owner := fs.String("owner", "", "only report, notify about and exit on the tests this team of --owners owns, such as @payments")
+	runQuarantined := fs.Bool("run-quarantined", false, "run quarantined tests instead of letting flaky.SkipIfQuarantined skip them")
+	profile := fs.String("profile", "", "run the scenarios under this chaos preset ("+strings.Join(flaky.PresetNames(), ", ")+") instead of $FLAKY_PROFILE or the config's profile")
+	scenarios := fs.String("scenarios", "", "only run the scenarios whose name or class matches these comma-separated globs, ! excluding, such as network,timing; sets $FLAKY_SCENARIOS")
+	isolate := fs.Bool("isolate", false, "rerun each failing test in a go test process of its own under the same seeds and flag those that only fail alongside others")
+	parallel := fs.Int("parallel", 1, "go test processes each run keeps going at once; above 1 every package runs in a process of its own")
+	perTestTimeout := fs.Duration("per-test-timeout", 0, "kill a top-level test that runs longer than this, such as 2m, fail it as a timeout and run the rest of its package again")
+	race := fs.Bool("race", false, "build and run the tests with the race detector")
+	rulesFile := fs.String("rules", "", "YAML file of failure classification rules tried before the defaults")
+	metricsAddr := fs.String("metrics", "", "serve Prometheus metrics on this address, such as :9100")
+	daemon := fs.Bool("daemon", false, "keep running sweeps of --runs runs with new seeds until interrupted")
+	interval := fs.Duration("interval", 0, "pause between --daemon sweeps")
+	traceRuns := fs.Bool("trace", false, "export each run as a trace over OTLP/HTTP, configured by the OTEL_EXPORTER_OTLP_* variables")
+	checkpointFile := fs.String("checkpoint", runner.DefaultCheckpointFile, "file to save progress to while running, removed once the sweep finishes (empty to disable)")
+	checkpointInterval := fs.Duration("checkpoint-interval", 30*time.Second, "least time between checkpoints; 0 saves after every run")
+	progressMode := fs.String("progress", "auto", "show the sweep on stderr while it runs: live redraws a view of rolling pass rates, the current run, an ETA and recent failures, log writes a line per run, auto is live on a terminal and log elsewhere, off shows nothing")
+	resume := fs.Bool("resume", false, "continue the interrupted sweep saved in --checkpoint instead of starting over")
+	packages, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	*quarantineFile = quarantinePath(*quarantineFile, *dir)
+
+	var classifier *reportfmt.Classifier
+	if *rulesFile != "" {
+		if classifier, err = reportfmt.LoadClassifier(*rulesFile); err != nil {
+			return err
+		}
+	}
+
+	// SIGTERM too, as a preempted machine sends, so an interrupted sweep
+	// saves its checkpoint
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg := runner.Config{
+		Packages:       packages,
+		Runs:           *runs,
+		Seed:           *seed,
+		Run:            *runRegex,
+		Dir:            *dir,
+		RerunFailed:    *rerunFailed,
+		MaxDuration:    *maxDuration,
+		Parallel:       *parallel,
+		PerTestTimeout: *perTestTimeout,
+	}
+	if *rerunFailed < 0 {
+		return fmt.Errorf("--rerun-failed must not be negative, got %d", *rerunFailed)
+	}
+	if *parallel < 1 {
+		return fmt.Errorf("--parallel must be at least 1, got %d", *parallel)
+	}
+	if *perTestTimeout < 0 {
+		return fmt.Errorf("--per-test-timeout must not be negative, got %v", *perTestTimeout)
+	}
+	if *brokenRate <= 0 || *brokenRate > 1 || *flakyExitRate < 0 || *flakyExitRate >= *brokenRate {
+		return fmt.Errorf("--flaky-exit-rate and --broken-rate must satisfy 0 <= flaky < broken <= 1, got %v and %v", *flakyExitRate, *brokenRate)
+	}
+	if *race {
+		cfg.Args = []string{"-race"}
+	}
+	// judge gives the verdicts in the report even without --adaptive
+	judge := &runner.Adaptive{Confidence: *confidence, Tolerance: *tolerance, MaxRuns: *maxRuns}
+	if *sprt {
+		// The error rates follow --confidence, so 0.95 means 5% of stable
+		// tests get called flaky and 5% of flaky ones stable
+		judge.SPRT = &stats.SPRT{P0: *tolerance, P1: *flakyRate, Alpha: 1 - *confidence, Beta: 1 - *confidence}
+		if err := judge.SPRT.Validate(); err != nil {
+			return err
+		}
+	}
+	if *adaptive || *sprt {
+		cfg.Adaptive = judge
+	}
+	ownerMap, err := loadOwners(*ownersFile, *owner)
+	if err != nil {
+		return err
+	}
+	notes := newNotifications(*slackWebhook, *webhook, notify.Rule{FlakeRate: *notifyFlakeRate, PassingRuns: *notifyPassingRuns}, *quarantineFile)
+	if notes != nil && *historyFile == "" {
+		return errors.New("--slack-webhook and --webhook need --history to tell new changes from known ones")
+	}
+	if notes != nil {
+		notes.owners, notes.owner = ownerMap, *owner
+	}
+	if *runQuarantined {
+		// An empty list quarantines nothing
+		cfg.Env = append(cfg.Env, quarantine.FileEnv+"="+os.DevNull)
+	}
+	if *profile != "" {
+		if _, ok := flaky.LookupPreset(*profile); !ok {
+			return fmt.Errorf("unknown --profile %q (want one of %s)", *profile, strings.Join(flaky.PresetNames(), ", "))
+		}
+		cfg.Env = append(cfg.Env, flaky.ProfileEnv+"="+*profile)
+	}
+	if *scenarios != "" {
+		if _, err := flaky.ParseSelection(*scenarios); err != nil {
+			return fmt.Errorf("--scenarios: %w", err)
+		}
+		cfg.Env = append(cfg.Env, flaky.ScenariosEnv+"="+*scenarios)
+	}
+	if *daemon && (*junitPath != "" || *jsonPath != "" || *sarifPath != "" || *buildkitePath != "" || *circleciPath != "" || *allureDir != "" || *csvPath != "" || *parquetPath != "") {
+		return errors.New("--daemon does not write --junit, --json, --sarif, --buildkite, --circleci, --allure, --csv or --parquet reports; scrape --metrics or read the history instead")
+	}
+	if *daemon && *isolate {
+		return errors.New("--daemon does not support --isolate; run detect --isolate once on the failing tests instead")
+	}
+	if *resume && (*daemon || *checkpointFile == "") {
+		return errors.New("--resume needs a --checkpoint file and does not support --daemon")
+	}
+	if *attachmentsDir != "" {
+		cfg.Attachments = attach.New(*attachmentsDir)
+	}
+	if !*daemon && *checkpointFile != "" {
+		cfg.Checkpoint, cfg.CheckpointInterval = *checkpointFile, *checkpointInterval
+	}
+	if *resume {
+		cp, err := runner.LoadCheckpoint(*checkpointFile)
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("no checkpoint at %s to resume", *checkpointFile)
+		}
+		if err != nil {
+			return err
+		}
+		if err := cp.Matches(cfg); err != nil {
+			return fmt.Errorf("%s: %w; rerun with the flags of the interrupted sweep", *checkpointFile, err)
+		}
+		cfg.Resume = cp
+		printResuming(stdout, cp)
+	}
+	var observers []func(int, []runner.Result)
+	if *metricsAddr != "" {
+		exporter := metrics.NewExporter()
+		observers = append(observers, func(_ int, results []runner.Result) { exporter.Observe(results) })
+		stopMetrics, err := serveMetrics(*metricsAddr, exporter, stdout)
+		if err != nil {
+			return err
+		}
+		defer stopMetrics()
+	}
+	if *traceRuns {
+		tp, err := tracing.NewProvider(ctx, "flakectl")
+		if err != nil {
+			return err
+		}
+		defer shutdownTracing(tp)
+		observers = append(observers, tracing.NewRecorder(tp, classifier).Observer(ctx, cfg))
+	}
+	if !*daemon {
+		// A daemon's sweeps never end, so it reports through --metrics
+		view, stopProgress, err := newProgress(*progressMode, cfg, os.Stderr)
+		if err != nil {
+			return err
+		}
+		defer stopProgress()
+		if view != nil {
+			observers = append(observers, view.Observe)
+		}
+	}
+	cfg.Observe = observeAll(observers)
+	if *daemon {
+		return detectForever(ctx, stdout, cfg, *interval, *historyFile, notes)
+	}
+
+	var priors map[[2]string]history.Prior
+	if *historyFile != "" && *priorWeight > 0 {
+		// Read before this sweep is recorded, so it is not its own prior
+		if priors, err = loadPriors(*historyFile, *priorWeight); err != nil {
+			return err
+		}
+	}
+	report, err := runner.Detect(ctx, cfg)
+	if err != nil {
+		if ctx.Err() != nil && cfg.Checkpoint != "" {
+			return fmt.Errorf("%w; continue the sweep with --resume", err)
+		}
+		return err
+	}
+	if *historyFile != "" {
+		if err := notes.record(ctx, stdout, *historyFile, report, history.Commit(*dir)); err != nil {
+			return err
+		}
+	}
+	// The history keeps every test, for every team's reports
+	report = ownedReport(report, ownerMap, *owner)
+	if *junitPath != "" {
+		if err := writeFile(*junitPath, func(w io.Writer) error { return reportfmt.WriteJUnit(w, report, classifier) }); err != nil {
+			return err
+		}
+	}
+	if *jsonPath != "" {
+		r := reportfmt.NewJSONReport(report, *confidence, classifier)
+		r.SetOwners(ownerMap.Owners)
+		if err := writeFile(*jsonPath, func(w io.Writer) error { return writeJSONReport(w, r) }); err != nil {
+			return err
+		}
+	}
+	var locate reportfmt.Locator
+	if *sarifPath != "" || *buildkitePath != "" || *circleciPath != "" {
+		if locate, err = reportfmt.LocateTests(ctx, *dir, packages); err != nil {
+			return err
+		}
+	}
+	if *sarifPath != "" {
+		if err := writeFile(*sarifPath, func(w io.Writer) error {
+			return reportfmt.WriteSARIF(w, report, *confidence, classifier, locate)
+		}); err != nil {
+			return err
+		}
+	}
+	if *buildkitePath != "" {
+		if err := writeFile(*buildkitePath, func(w io.Writer) error { return reportfmt.WriteBuildkite(w, report, classifier, locate) }); err != nil {
+			return err
+		}
+	}
+	if *circleciPath != "" {
+		if err := writeFile(*circleciPath, func(w io.Writer) error { return reportfmt.WriteCircleCI(w, report, classifier, locate) }); err != nil {
+			return err
+		}
+	}
+	if *allureDir != "" {
+		if err := reportfmt.WriteAllure(*allureDir, report, classifier); err != nil {
+			return err
+		}
+	}
+	if *csvPath != "" || *parquetPath != "" {
+		records := reportfmt.Records(report, classifier, history.Commit(*dir))
+		if *csvPath != "" {
+			if err := writeFile(*csvPath, func(w io.Writer) error { return reportfmt.WriteCSV(w, records) }); err != nil {
+				return err
+			}
+		}
+		if *parquetPath != "" {
+			if err := writeFile(*parquetPath, func(w io.Writer) error { return reportfmt.WriteParquet(w, records) }); err != nil {
+				return err
+			}
+		}
+	}
+	var dumps []string
+	if *dumpsDir != "" {
+		if dumps, err = runner.WriteDumps(*dumpsDir, report.Results); err != nil {
+			return err
+		}
+	}
+	if err := printReport(stdout, report, judge); err != nil {
+		return err
+	}
+	printOutOfTime(stdout, report, *maxDuration)
+	printReran(stdout, report, *rerunFailed)
+	printFingerprint(stdout, report)
+	if err := printPosteriors(stdout, report, priors, *priorWeight, *tolerance, *confidence); err != nil {
+		return err
+	}
+	printFailureCategories(stdout, report, classifier)
+	printCrashes(stdout, report)
+	printFailureSignatures(stdout, report, classifier)
+	printFlakyCorpus(stdout, report)
+	printCommonCauses(stdout, report)
+	printDumps(stdout, dumps)
+	printAttachments(stdout, report, *attachmentsDir)
+	printOwnerDigest(stdout, report, ownerMap)
+	if *isolate {
+		dependent, err := runner.DetectIsolated(ctx, cfg, report)
+		if err != nil {
+			return err
+		}
+		printStateDependent(stdout, report, dependent)
+	}
+	list, err := quarantine.Load(*quarantineFile)
+	if err != nil {
+		return err
+	}
+	printQuarantineSuggestions(stdout, report, list, *quarantineBelow)
+	return detectExit(report, *flakyExitRate, *brokenRate)
+}
+
+// quarantinePath returns the quarantine list detect checks: flagged, or the
+// one the tests in dir read, from FLAKY_QUARANTINE_FILE or the default
+// relative to dir
+func quarantinePath(flagged, dir string) string {
+	if flagged != "" {
+		return flagged
+	}
+	path := quarantine.File()
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return path
+}
+
+// detectExit returns nil when every test that ran is stable, an exitError
+// of code 1 naming the broken tests when a flake rate reaches brokenRate,
+// and one of code 2 naming the flaky tests when a flake rate only exceeds
+// flakyRate, so CI can tell a flaky suite from a broken one
+// A parent that only failed through subtests is left to them
+func detectExit(report *runner.Report, flakyRate, brokenRate float64) error {
+	var flaky, broken []string
+	for _, s := range report.Tests {
+		if s.Classify() == runner.Skipped || s.FailsThroughSubtests() {
+			continue
+		}
+		// Compare with a little slack so a rate equal to --broken-rate
+		// reaches it despite rounding
+		switch rate := s.FlakeRate(); {
+		case rate >= brokenRate-1e-9:
+			broken = append(broken, s.Test)
+		case rate > flakyRate:
+			flaky = append(flaky, s.Test)
+		}
+	}
+	if len(broken) > 0 {
+		return &exitError{code: 1, err: fmt.Errorf("%d broken test(s): %s", len(broken), strings.Join(broken, ", "))}
+	}
+	if len(flaky) > 0 {
+		return &exitError{code: 2, err: fmt.Errorf("%d flaky test(s): %s", len(flaky), strings.Join(flaky, ", "))}
+	}
+	return nil
+}
+
+// printDumps lists the goroutine dumps WriteDumps saved for hung runs
+func printDumps(w io.Writer, files []string) {
+	if len(files) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nGoroutine dumps of %d hung run(s):\n", len(files))
+	for _, f := range files {
+		fmt.Fprintf(w, "  %s\n", f)
+	}
+}
+
+// printAttachments says where the output of the failing runs went and how
+// to read one back
+func printAttachments(w io.Writer, report *runner.Report, dir string) {
+	var runs int
+	var example *runner.Result
+	for i, r := range report.Results {
+		if len(r.Attachments) > 0 {
+			runs++
+			if example == nil {
+				example = &report.Results[i]
+			}
+		}
+	}
+	if runs == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nOutput of %d failing run(s) stored in %s; show one with:\n", runs, dir)
+	fmt.Fprintf(w, "  flakectl show %s --seed %d --attachments %s\n", example.Test, example.Seed, dir)
+}
+
+// observeAll combines runner.Config.Observe functions, returning nil for
+// none
+func observeAll(observers []func(int, []runner.Result)) func(int, []runner.Result) {
+	if len(observers) == 0 {
+		return nil
+	}
+	return func(run int, results []runner.Result) {
+		for _, observe := range observers {
+			observe(run, results)
+		}
+	}
+}
+
+// shutdownTracing flushes the spans tp has yet to export, giving up after
+// a few seconds so an unreachable collector cannot hang the command
+func shutdownTracing(tp *sdktrace.TracerProvider) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tp.Shutdown(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "flakectl: export traces: %v\n", err)
+	}
+}
+
+// recordHistory appends report to the history database at path
+func recordHistory(path string, report *runner.Report, commit string) error {
+	db, err := history.Open(path)
+	if err != nil {
+		return err
+	}
+	if err := db.Add(history.NewSession(report, commit)); err != nil {
+		db.Close()
+		return err
+	}
+	return db.Close()
+}
+
+// loadPriors reads the flake-rate priors of every test in the history
+// database at path, or none when there is no history yet
+func loadPriors(path string, weight float64) (map[[2]string]history.Prior, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	db, err := history.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	sessions, err := db.Sessions(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	return history.Priors(sessions, weight), nil
+}
+
+// printPosteriors lists every test that failed this sweep with its flake
+// rate after updating its prior from history with the sweep's runs, so one
+// failure of a test with a long clean record reads differently from one of
+// a chronically flaky test
+// Nothing is printed without history
+func printPosteriors(w io.Writer, report *runner.Report, priors map[[2]string]history.Prior, weight, tolerance, confidence float64) error {
+	if len(priors) == 0 {
+		return nil
+	}
+	var failing []*runner.TestStats
+	for _, s := range report.Tests {
+		if s.Failed > 0 && !s.FailsThroughSubtests() {
+			failing = append(failing, s)
+		}
+	}
+	if len(failing) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "\nFlake rates with history as a prior (worth up to %g runs):\n", weight)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "  TEST\tTHIS SWEEP\tHISTORY\tPOSTERIOR %.0f%% CI\tP(RATE > %.1f%%)\n", confidence*100, tolerance*100)
+	for _, s := range failing {
+		past := "-"
+		if prior, ok := priors[[2]string{s.Package, s.Test}]; ok {
+			past = fmt.Sprintf("%d of %d", prior.History.Failed, prior.History.Runs())
+		}
+		post := history.Posterior(priors, s.Package, s.Test, s.Passed, s.Failed)
+		iv := post.CredibleInterval(confidence)
+		fmt.Fprintf(tw, "  %s\t%d of %d\t%s\t%.1f%% [%.1f, %.1f]\t%.2f\n",
+			s.Test, s.Failed, s.Passed+s.Failed, past, post.Mean()*100, iv.Lower*100, iv.Upper*100, post.ProbAbove(tolerance))
+	}
+	return tw.Flush()
+}
+
+// printQuarantineSuggestions lists tests below the pass-rate threshold that
+// are not quarantined yet
+func printQuarantineSuggestions(w io.Writer, report *runner.Report, list *quarantine.List, threshold float64) {
+	var suggestions []*runner.TestStats
+	for _, stats := range report.BelowPassRate(threshold) {
+		if !list.Contains(stats.Test) {
+			suggestions = append(suggestions, stats)
+		}
+	}
+	if len(suggestions) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nSuggested quarantine (pass rate below %.1f%%):\n", threshold*100)
+	for _, stats := range suggestions {
+		fmt.Fprintf(w, "  flakectl quarantine add %s --reason %q\n",
+			stats.Test, fmt.Sprintf("%s, pass rate %.1f%%", stats.Classify(), stats.PassRate()*100))
+	}
+}
+
+// printOutOfTime says when --max-duration cut the sweep short, since the
+// run counts are then lower than asked for
+func printOutOfTime(w io.Writer, report *runner.Report, budget time.Duration) {
+	if !report.OutOfTime {
+		return
+	}
+	fmt.Fprintf(w, "\nStopped after %d runs: the --max-duration budget of %v ran out\n", report.Runs, budget)
+}
+
+// printFingerprint describes the environment the sweep ran in, which
+// flakectl report --fingerprint filters on
+func printFingerprint(w io.Writer, report *runner.Report) {
+	if fp := report.Fingerprint; fp.GOOS != "" {
+		fmt.Fprintf(w, "\nEnvironment %s: %s\n", fp.ID(), fp)
+	}
+}
+
+// printReran names the tests --rerun-failed ran again, since their run
+// counts no longer match the rest of the table
+func printReran(w io.Writer, report *runner.Report, reruns int) {
+	if len(report.Reran) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nReran %d failing test(s) %d more times: %s\n", len(report.Reran), reruns, strings.Join(report.Reran, ", "))
+}
+
+// printFailureCategories lists the tests with failures other than
+// assertions, since a race, panic, timeout, crash or network error usually
+// needs a different fix than an assertion that flakes
+func printFailureCategories(w io.Writer, report *runner.Report, c *reportfmt.Classifier) {
+	categories := c.Categories(report)
+	header := false
+	for _, stats := range report.Tests {
+		counts := categories[[2]string{stats.Package, stats.Test}]
+		var parts []string
+		for _, category := range reportfmt.SortedCategories(counts) {
+			if category != reportfmt.CategoryAssertion {
+				parts = append(parts, fmt.Sprintf("%d %s", counts[category], category))
+			}
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		if !header {
+			fmt.Fprintln(w, "\nFailures that were not assertions:")
+			header = true
+		}
+		fmt.Fprintf(w, "  %s: %s of %d failing runs\n", stats.Test, strings.Join(parts, ", "), stats.Failed)
+	}
+}
+
+// printCrashes lists the tests whose test binary crashed while they ran,
+// with how it ended, since the crash also cut short the tests it
+// interrupted, which ran again
+func printCrashes(w io.Writer, report *runner.Report) {
+	header, killed := false, false
+	for _, stats := range report.Tests {
+		if len(stats.Crashes) == 0 {
+			continue
+		}
+		exits := make([]string, 0, len(stats.Crashes))
+		for exit := range stats.Crashes {
+			exits = append(exits, exit)
+		}
+		sort.Slice(exits, func(i, j int) bool {
+			if stats.Crashes[exits[i]] != stats.Crashes[exits[j]] {
+				return stats.Crashes[exits[i]] > stats.Crashes[exits[j]]
+			}
+			return exits[i] < exits[j]
+		})
+		var parts []string
+		for _, exit := range exits {
+			parts = append(parts, fmt.Sprintf("%d %s", stats.Crashes[exit], exit))
+			killed = killed || exit == "SIGKILL"
+		}
+		if !header {
+			fmt.Fprintln(w, "\nCrashed test binaries (counted against the test that started last):")
+			header = true
+		}
+		fmt.Fprintf(w, "  %s: %s, seeds %s\n", stats.Test, strings.Join(parts, ", "), crashSeeds(report, stats))
+	}
+	if killed {
+		fmt.Fprintln(w, "  A SIGKILL flakectl did not send is usually the kernel's out-of-memory killer; check the memory limit")
+	}
+}
+
+// crashSeeds lists the seeds of the runs a test crashed its binary in
+func crashSeeds(report *runner.Report, stats *runner.TestStats) string {
+	var seeds []string
+	for _, r := range report.Results {
+		if r.Kind == runner.Crash && r.Package == stats.Package && r.Test == stats.Test {
+			seeds = append(seeds, strconv.FormatInt(r.Seed, 10))
+		}
+	}
+	return strings.Join(seeds, ", ")
+}
+
+// printFailureSignatures lists the distinct failures of every failing test,
+// with messages that differ only in values grouped into one signature, how
+// many runs failed with it and a seed that reproduces it
+// A test that only failed through its subtests is left to them
+func printFailureSignatures(w io.Writer, report *runner.Report, c *reportfmt.Classifier) {
+	failures := reportfmt.FailureSignatures(report.Results, c)
+	header := false
+	for _, stats := range report.Tests {
+		signatures := failures[[2]string{stats.Package, stats.Test}]
+		if len(signatures) == 0 || stats.FailsThroughSubtests() {
+			continue
+		}
+		if !header {
+			fmt.Fprintln(w, "\nFailure signatures:")
+			header = true
+		}
+		fmt.Fprintf(w, "  %s: %d signature(s) in %d failing run(s)\n", stats.Test, len(signatures), stats.Failed)
+		for i, f := range signatures {
+			if i == maxListedFailures {
+				fmt.Fprintf(w, "    +%d more\n", len(signatures)-i)
+				break
+			}
+			fmt.Fprintf(w, "    %dx %s (seed %d)\n", f.Count, f.Signature, f.Seed)
+		}
+	}
+}
+
+// printFlakyCorpus lists the fuzz seed corpus entries whose outcome depended
+// on the seed, with where each is defined and a seed it failed with
+func printFlakyCorpus(w io.Writer, report *runner.Report) {
+	flaky := report.FlakyCorpus()
+	if len(flaky) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nFlaky fuzz corpus entries:")
+	for _, stats := range flaky {
+		entry, _ := runner.ParseCorpusEntry(stats.Test)
+		fmt.Fprintf(w, "  %s (%s): failed %d of %d run(s)\n", stats.Test, entry.Source(), stats.Failed, stats.Passed+stats.Failed)
+		fmt.Fprintf(w, "    flakectl reproduce %s --seed %d\n", stats.Test, stats.FailingSeeds[0])
+	}
+}
+
+// printCommonCauses lists the groups of tests that fail in the same runs,
+// whose failures likely share a cause outside each test
+func printCommonCauses(w io.Writer, report *runner.Report) {
+	causes := reportfmt.CommonCauses(report)
+	if len(causes) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nCorrelated failures (likely a common cause, such as shared infrastructure):")
+	for _, c := range causes {
+		fmt.Fprintf(w, "  %s: %d run(s) with several failing (phi >= %.2f, p <= %.2g)\n", c.Names(), len(c.Seeds), c.Phi, c.P)
+		fmt.Fprintf(w, "    seeds %s\n", formatSeeds(c.Seeds))
+	}
+}
+
+// printStateDependent lists the failing tests that passed every run in a
+// process of their own, whose failures come from state or order shared with
+// the rest of their package rather than from their seed
+func printStateDependent(w io.Writer, report *runner.Report, dependent []runner.StateDependence) {
+	if len(dependent) == 0 {
+		for _, stats := range report.Tests {
+			if stats.Failed > 0 {
+				fmt.Fprintln(w, "\nEvery failing test also failed in a process of its own")
+				return
+			}
+		}
+		return
+	}
+	fmt.Fprintf(w, "\nOrder/state-dependent: %d test(s) fail alongside others but pass isolated under the same seeds:\n", len(dependent))
+	for _, d := range dependent {
+		fmt.Fprintf(w, "  %s: failed %d of %d runs together, 0 of %d isolated\n", d.Test, d.Together.Failed, d.Together.Runs(), d.Isolated.Runs())
+	}
+	fmt.Fprintln(w, "Find the tests they need with: flakectl minimize <test> <package>")
+}
+
+// writeFile creates path and fills it with write
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// printResuming says how much of a resumed sweep is done
+func printResuming(w io.Writer, cp *runner.Checkpoint) {
+	fmt.Fprintf(w, "Resuming after %d run(s) from %s", cp.Completed, cp.Saved.Format(time.RFC3339))
+	if left := cp.Remaining; left.Len() > 0 {
+		fmt.Fprintf(w, ", seeds %d-%d left", left.First, left.Last)
+	}
+	fmt.Fprintln(w)
+}
+
+// printReport writes the per-test table for a detection report, with the
+// Wilson interval of each flake rate and the verdict judge gives it
+func printReport(w io.Writer, report *runner.Report, judge *runner.Adaptive) error {
+	confidence := judge.Confidence
+	failures := reportfmt.FailureSignatures(report.Results, nil)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "TEST\tPASS RATE\tFLAKE RATE %.0f%% CI\tVERDICT\tRUNS\tMEAN DURATION\tFAILURE\n", confidence*100)
+	for _, s := range report.Tests {
+		failure := "-"
+		if s.FailsThroughSubtests() {
+			failure = "failed in subtests"
+		} else if signatures := failures[[2]string{s.Package, s.Test}]; len(signatures) > 0 {
+			failure = signatures[0].Message
+			if extra := len(signatures) - 1; extra > 0 {
+				failure += fmt.Sprintf(" (+%d more)", extra)
+			}
+		}
+		est := stats.EstimateCounts(s.Passed, s.Failed, confidence)
+		fmt.Fprintf(tw, "%s\t%.1f%%\t%.1f%% [%.1f, %.1f]\t%s\t%d\t%v\t%s\n",
+			s.Test, s.PassRate()*100, est.Rate*100, est.Wilson.Lower*100, est.Wilson.Upper*100,
+			judge.Verdict(s), s.Runs(), s.MeanDuration(), failure)
+	}
+	return tw.Flush()
+}
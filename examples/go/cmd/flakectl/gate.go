@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"text/tabwriter"
+
+	reportfmt "github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func runGate(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("gate", flag.ContinueOnError)
+	baselinePath := fs.String("baseline", "", "JSON flake report to compare against, as written by flakectl detect --json")
+	reportPath := fs.String("report", "", "JSON flake report of the current run (default: run the suite now)")
+	budget := fs.Float64("max-flake-rate", 0.02, "largest flake-rate increase over the baseline a test may have")
+	runs := fs.Int("runs", 20, "number of times to run the suite when there is no --report")
+	seed := fs.Int64("seed", 1, "seed of the first run; run i uses seed+i")
+	runRegex := fs.String("run", "", "only run tests matching this regex")
+	dir := fs.String("dir", "", "directory to run go test in")
+	confidence := fs.Float64("confidence", 0.95, "confidence level of the reported flake-rate intervals")
+	packages, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *baselinePath == "" {
+		return errors.New("usage: flakectl gate --baseline baseline.json [--report current.json | packages] [--max-flake-rate 0.02]")
+	}
+	if *budget < 0 || *budget >= 1 {
+		return fmt.Errorf("--max-flake-rate must be in [0, 1), got %v", *budget)
+	}
+	baseline, err := reportfmt.LoadJSON(*baselinePath)
+	if err != nil {
+		return err
+	}
+
+	var current *reportfmt.JSONReport
+	if *reportPath != "" {
+		if current, err = reportfmt.LoadJSON(*reportPath); err != nil {
+			return err
+		}
+	} else {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		r, err := runner.Detect(ctx, runner.Config{Packages: packages, Runs: *runs, Seed: *seed, Run: *runRegex, Dir: *dir})
+		if err != nil {
+			return err
+		}
+		current = reportfmt.NewJSONReport(r, *confidence, nil)
+	}
+
+	regressions, checked := gateRegressions(baseline, current, *budget)
+	if err := printGate(stdout, regressions, checked, *budget); err != nil {
+		return err
+	}
+	if len(regressions) > 0 {
+		return fmt.Errorf("%d test(s) exceed the flake budget", len(regressions))
+	}
+	return nil
+}
+
+// regression is a test whose flake rate rose more than the budget allows
+type regression struct {
+	Test reportfmt.JSONTest
+	// Baseline is the baseline flake rate, or -1 for a test the baseline
+	// did not run
+	Baseline float64
+}
+
+// increase is the rise in flake rate, counting a new test from 0
+func (r regression) increase() float64 {
+	return r.Test.FlakeRate - max(r.Baseline, 0)
+}
+
+// gateRegressions compares every test that ran in current with baseline and
+// returns the tests whose flake rate rose by more than budget, largest rise
+// first, and how many tests were checked
+// Tests missing from the baseline count from a flake rate of 0, so a new
+// flaky test fails the gate too
+func gateRegressions(baseline, current *reportfmt.JSONReport, budget float64) ([]regression, int) {
+	type key struct{ pkg, test string }
+	base := make(map[key]float64)
+	for _, t := range baseline.Tests {
+		if t.Classification != string(runner.Skipped) {
+			base[key{t.Package, t.Test}] = t.FlakeRate
+		}
+	}
+
+	var regressions []regression
+	checked := 0
+	for _, t := range current.Tests {
+		if t.Classification == string(runner.Skipped) {
+			continue
+		}
+		checked++
+		r := regression{Test: t, Baseline: -1}
+		if rate, ok := base[key{t.Package, t.Test}]; ok {
+			r.Baseline = rate
+		}
+		// Compare with a little slack so a rise of exactly the budget passes
+		if r.increase() > budget+1e-9 {
+			regressions = append(regressions, r)
+		}
+	}
+	sort.SliceStable(regressions, func(i, j int) bool { return regressions[i].increase() > regressions[j].increase() })
+	return regressions, checked
+}
+
+// printGate reports the gate's verdict and a table of the regressions
+func printGate(w io.Writer, regressions []regression, checked int, budget float64) error {
+	if len(regressions) == 0 {
+		fmt.Fprintf(w, "Gate passed: %d test(s) within the flake budget of +%.1f%% over the baseline\n", checked, budget*100)
+		return nil
+	}
+	fmt.Fprintf(w, "Gate failed: %d of %d test(s) exceed the flake budget of +%.1f%% over the baseline\n\n", len(regressions), checked, budget*100)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TEST\tBASELINE\tCURRENT\tCHANGE\tRUNS\tFAILURE")
+	for _, r := range regressions {
+		baseline := "new"
+		if r.Baseline >= 0 {
+			baseline = fmt.Sprintf("%.1f%%", r.Baseline*100)
+		}
+		failure := "-"
+		if len(r.Test.FailureMessages) > 0 {
+			failure = r.Test.FailureMessages[0]
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.1f%%\t+%.1f%%\t%d\t%s\n",
+			r.Test.Test, baseline, r.Test.FlakeRate*100, r.increase()*100, r.Test.Runs, failure)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	reportfmt "github.com/example/flaky-test-example/internal/report"
+)
+
+func gateReport(tests ...reportfmt.JSONTest) *reportfmt.JSONReport {
+	for i := range tests {
+		tests[i].Package = "p"
+		if tests[i].Classification == "" {
+			tests[i].Classification = "flaky"
+		}
+	}
+	return &reportfmt.JSONReport{Tests: tests}
+}
+
+func TestGateRegressions(t *testing.T) {
+	baseline := gateReport(
+		reportfmt.JSONTest{Test: "TestSteady", FlakeRate: 0.30},
+		reportfmt.JSONTest{Test: "TestWorse", FlakeRate: 0.10},
+		reportfmt.JSONTest{Test: "TestFixed", FlakeRate: 0.20},
+		reportfmt.JSONTest{Test: "TestWasSkipped", Classification: "skipped"},
+	)
+	current := gateReport(
+		reportfmt.JSONTest{Test: "TestSteady", FlakeRate: 0.32},
+		reportfmt.JSONTest{Test: "TestWorse", FlakeRate: 0.25},
+		reportfmt.JSONTest{Test: "TestFixed", FlakeRate: 0},
+		reportfmt.JSONTest{Test: "TestNew", FlakeRate: 0.05},
+		reportfmt.JSONTest{Test: "TestWasSkipped", FlakeRate: 0.01},
+		reportfmt.JSONTest{Test: "TestSkipped", Classification: "skipped"},
+	)
+	regressions, checked := gateRegressions(baseline, current, 0.02)
+	if checked != 5 {
+		t.Errorf("Expected 5 tests checked, got %d", checked)
+	}
+	if len(regressions) != 2 || regressions[0].Test.Test != "TestWorse" || regressions[1].Test.Test != "TestNew" {
+		t.Fatalf("Expected TestWorse then TestNew to regress, got %+v", regressions)
+	}
+	if regressions[1].Baseline != -1 {
+		t.Errorf("Expected TestNew to have no baseline, got %v", regressions[1].Baseline)
+	}
+}
+
+func TestPrintGate(t *testing.T) {
+	var out bytes.Buffer
+	regressions := []regression{{Test: reportfmt.JSONTest{Test: "TestNew", FlakeRate: 0.1, Runs: 20, FailureMessages: []string{"a_test.go:1: boom"}}, Baseline: -1}}
+	if err := printGate(&out, regressions, 3, 0.02); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Gate failed: 1 of 3 test(s) exceed the flake budget of +2.0%", "TestNew", "new", "+10.0%", "a_test.go:1: boom"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunGateComparesReportFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, r *reportfmt.JSONReport) string {
+		data, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	baseline := write("baseline.json", gateReport(reportfmt.JSONTest{Test: "TestA", FlakeRate: 0.1}))
+	within := write("within.json", gateReport(reportfmt.JSONTest{Test: "TestA", FlakeRate: 0.12}))
+	beyond := write("beyond.json", gateReport(reportfmt.JSONTest{Test: "TestA", FlakeRate: 0.2}))
+
+	var out bytes.Buffer
+	if err := runGate([]string{"--baseline", baseline, "--report", within}, &out); err != nil {
+		t.Errorf("Expected a rise of 2%% to pass, got %v", err)
+	}
+	if !strings.Contains(out.String(), "Gate passed: 1 test(s)") {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+	if err := runGate([]string{"--baseline", baseline, "--report", beyond}, &out); err == nil {
+		t.Error("Expected a rise of 10% to fail the gate")
+	}
+	if err := runGate([]string{"--report", beyond}, &out); err == nil {
+		t.Error("Expected a missing --baseline to be rejected")
+	}
+}
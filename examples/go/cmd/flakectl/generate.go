@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	reportfmt "github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/internal/synthetic"
+)
+
+func runGenerate(args []string, stdout io.Writer) error {
+	if len(args) > 0 && args[0] == "score" {
+		return runGenerateScore(args[1:], stdout)
+	}
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	tests := fs.Int("tests", 200, "number of tests to generate")
+	flakyTests := fs.Int("flaky", 15, "number of them that are flaky")
+	broken := fs.Int("broken", 0, "number of them that fail on every seed")
+	rates := fs.String("rates", "0.01,0.05,0.3", "comma-separated flake rates assigned to the flaky tests in turn")
+	categories := fs.String("categories", strings.Join(synthetic.Categories, ","), "comma-separated failure categories assigned to the flaky and broken tests in turn")
+	seed := fs.Int64("seed", 1, "seed that picks which tests are flaky or broken")
+	out := fs.String("out", "synthetic", "directory to write the package and its "+synthetic.TruthFile+" to")
+	pkg := fs.String("package", "", "package name (default the base name of --out)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errors.New("usage: flakectl generate [--tests N] [--flaky N] [--rates r,...] [--out dir]")
+	}
+
+	spec := synthetic.Spec{Package: *pkg, Tests: *tests, Flaky: *flakyTests, Broken: *broken, Seed: *seed}
+	if spec.Package == "" {
+		spec.Package = packageName(filepath.Base(*out))
+	}
+	if spec.Rates, err = parseFloatList("--rates", *rates); err != nil {
+		return err
+	}
+	for _, c := range strings.Split(*categories, ",") {
+		spec.Categories = append(spec.Categories, strings.TrimSpace(c))
+	}
+	truth, err := synthetic.Write(*out, spec)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Wrote %d test(s), %d flaky and %d broken, to package %s in %s\n",
+		len(truth.Tests), spec.Flaky, spec.Broken, truth.Package, *out)
+	fmt.Fprintf(stdout, "Score a detection run with: flakectl generate score --truth %s --report <report.json>\n",
+		filepath.Join(*out, synthetic.TruthFile))
+	return nil
+}
+
+// runGenerateScore grades a JSON flake report of a generated suite against
+// its ground truth
+func runGenerateScore(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("generate score", flag.ContinueOnError)
+	truthPath := fs.String("truth", filepath.Join("synthetic", synthetic.TruthFile), "ground truth written by flakectl generate")
+	reportPath := fs.String("report", "", "JSON flake report of the suite, as written by flakectl detect --json")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 || *reportPath == "" {
+		return errors.New("usage: flakectl generate score --report report.json [--truth synthetic/" + synthetic.TruthFile + "]")
+	}
+	truth, err := synthetic.LoadTruth(*truthPath)
+	if err != nil {
+		return err
+	}
+	report, err := reportfmt.LoadJSON(*reportPath)
+	if err != nil {
+		return err
+	}
+	printScore(stdout, truth, synthetic.Evaluate(truth, report))
+	return nil
+}
+
+// printScore writes the detection quality and run budget of a score
+func printScore(w io.Writer, truth *synthetic.Truth, s synthetic.Score) {
+	flakyTests := s.TruePositives + s.FalseNegatives
+	fmt.Fprintf(w, "%d test(s), %d flaky, %d broken\n\n", len(truth.Tests), flakyTests, s.Broken)
+	fmt.Fprintf(w, "Precision:  %.3f (%d of %d reported flaky)\n", s.Precision(), s.TruePositives, s.TruePositives+s.FalsePositives)
+	fmt.Fprintf(w, "Recall:     %.3f (%d of %d flaky found)\n", s.Recall(), s.TruePositives, flakyTests)
+	fmt.Fprintf(w, "F1:         %.3f\n", s.F1())
+	if s.Broken > 0 {
+		fmt.Fprintf(w, "Broken:     %d of %d reported failing\n", s.BrokenFound, s.Broken)
+	}
+	if s.TruePositives > 0 {
+		fmt.Fprintf(w, "Rate error: %.1f points on average\n", 100*s.RateError)
+		fmt.Fprintf(w, "Categories: %d of %d right\n", s.CategoryHits, s.TruePositives)
+		fmt.Fprintf(w, "Budget:     %d run(s), %.0f per flaky test found\n", s.Runs, s.RunsPerFlake)
+	} else {
+		fmt.Fprintf(w, "Budget:     %d run(s)\n", s.Runs)
+	}
+
+	rates := make(map[string]float64, len(truth.Tests))
+	for _, t := range truth.Tests {
+		rates[t.Test] = t.FlakeRate
+	}
+	if len(s.Missed) > 0 {
+		fmt.Fprintln(w, "\nMissed:")
+		for _, test := range s.Missed {
+			fmt.Fprintf(w, "  %s (flakes %.1f%%)\n", test, 100*rates[test])
+		}
+	}
+	if len(s.FalseAlarms) > 0 {
+		fmt.Fprintln(w, "\nFalse alarms:")
+		for _, test := range s.FalseAlarms {
+			kind := runner.Stable
+			if rates[test] == 1 {
+				kind = runner.Failing
+			}
+			fmt.Fprintf(w, "  %s (%s)\n", test, kind)
+		}
+	}
+}
+
+// parseFloatList parses a comma-separated list of numbers such as
+// "0.01,0.05,0.3"
+func parseFloatList(flagName, s string) ([]float64, error) {
+	var list []float64
+	for _, field := range strings.Split(s, ",") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q, want comma-separated numbers", flagName, s)
+		}
+		list = append(list, f)
+	}
+	return list, nil
+}
+
+// packageName turns a directory name into a Go package name, dropping the
+// characters an identifier cannot hold
+func packageName(dir string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(dir) {
+		if r == '_' || r >= 'a' && r <= 'z' || b.Len() > 0 && r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "synthetic"
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/internal/synthetic"
+)
+
+func TestRunGenerate(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "bench-suite")
+	var stdout bytes.Buffer
+	err := runGenerate([]string{"--tests", "12", "--flaky", "3", "--broken", "1", "--rates", "0.1, 0.2", "--out", out}, &stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truth, err := synthetic.LoadTruth(filepath.Join(out, synthetic.TruthFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truth.Package != "benchsuite" || len(truth.Tests) != 12 {
+		t.Errorf("Expected 12 tests in package benchsuite, got %+v", truth)
+	}
+	src, err := os.ReadFile(filepath.Join(out, "synthetic_test.go"))
+	if err != nil || !strings.Contains(string(src), "package benchsuite") {
+		t.Errorf("Expected the test file in package benchsuite, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "12 test(s), 3 flaky and 1 broken") {
+		t.Errorf("Unexpected output: %s", stdout.String())
+	}
+
+	for _, args := range [][]string{{"--rates", "0.1,x"}, {"--categories", "race"}, {"--tests", "2", "--flaky", "3"}} {
+		if err := runGenerate(append(args, "--out", out), &stdout); err == nil {
+			t.Errorf("Expected %v to fail", args)
+		}
+	}
+}
+
+func TestPrintScore(t *testing.T) {
+	truth := &synthetic.Truth{Tests: []synthetic.TruthTest{
+		{Test: "TestA", Classification: runner.Flaky, FlakeRate: 0.01},
+		{Test: "TestB", Classification: runner.Failing, FlakeRate: 1},
+	}}
+	s := synthetic.Score{FalsePositives: 1, FalseNegatives: 1, Missed: []string{"TestA"}, FalseAlarms: []string{"TestB"}, Broken: 1, Runs: 20}
+	var out bytes.Buffer
+	printScore(&out, truth, s)
+	for _, want := range []string{"Precision:  0.000 (0 of 1 reported flaky)", "Recall:     0.000 (0 of 1 flaky found)", "Broken:     0 of 1", "TestA (flakes 1.0%)", "TestB (failing)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	for dir, want := range map[string]string{"synthetic": "synthetic", "Bench-Suite2": "benchsuite2", "2x": "x", "---": "synthetic"} {
+		if got := packageName(dir); got != want {
+			t.Errorf("packageName(%q) = %q, want %q", dir, got, want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+
+	"github.com/example/flaky-test-example/internal/hunt"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func runHunt(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("hunt", flag.ContinueOnError)
+	budget := fs.Int("budget", 200, "maximum number of seeds to probe")
+	seeds := fs.String("seeds", fmt.Sprintf("0-%d", math.MaxInt32), "inclusive seed space to search, as start-end")
+	radius := fs.Int64("radius", 2, "seeds probed on either side of a seed with a new failure (negative to disable)")
+	workers := fs.Int("workers", 4, "number of probes run concurrently")
+	randSeed := fs.Int64("rand", 1, "seed of the random sampler")
+	dir := fs.String("dir", "", "directory to run go test in")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 1 || len(positional) > 2 {
+		return errors.New("usage: flakectl hunt <TestName> [package] [--budget N]")
+	}
+	cfg := hunt.Config{Test: positional[0], Dir: *dir, Budget: *budget, Radius: *radius, Workers: *workers, Rand: *randSeed}
+	if len(positional) == 2 {
+		cfg.Package = positional[1]
+	}
+	if cfg.Min, cfg.Max, err = parseSeedRange(*seeds); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	res, err := hunt.Run(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	printHunt(stdout, cfg, res)
+	return nil
+}
+
+// printHunt writes each distinct failure with its seeds and the minimal seeds
+// that reproduce them all
+func printHunt(w io.Writer, cfg hunt.Config, res *hunt.Result) {
+	fmt.Fprintf(w, "Probed %d seeds of %s in %d-%d, %d failed\n", res.Probes, cfg.Test, cfg.Min, cfg.Max, res.Failed)
+	if len(res.Failures) == 0 {
+		fmt.Fprintln(w, "\nNo failing seeds found; raise --budget or widen --seeds")
+		return
+	}
+	fmt.Fprintf(w, "\n%d distinct failures:\n", len(res.Failures))
+	for _, f := range res.Failures {
+		fmt.Fprintf(w, "  %s\n    found at probe %d, seeds: %s\n", f.Message, f.Found, formatSeeds(f.Seeds))
+	}
+
+	pkg := cfg.Package
+	if pkg == "" {
+		pkg = "."
+	}
+	fmt.Fprintf(w, "\nMinimal reproducing seeds: %s\n", formatSeeds(res.Minimal))
+	for _, seed := range res.Minimal {
+		fmt.Fprintf(w, "  %s=%d go test %s -run '%s'\n", runner.SeedEnv, seed, pkg, runner.TestPattern(cfg.Test))
+	}
+}
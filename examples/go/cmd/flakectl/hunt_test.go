@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/hunt"
+)
+
+func TestPrintHunt(t *testing.T) {
+	cfg := hunt.Config{Test: "TestBoundaryCondition", Min: 0, Max: 999}
+	res := &hunt.Result{
+		Probes: 50,
+		Failed: 3,
+		Failures: []hunt.Failure{
+			{Message: "Value 101 exceeds threshold 100", Seeds: []int64{12, 40}, Found: 2},
+			{Message: "Value 102 exceeds threshold 100", Seeds: []int64{7}, Found: 9},
+		},
+		Minimal: []int64{7, 12},
+	}
+	var out bytes.Buffer
+	printHunt(&out, cfg, res)
+	for _, want := range []string{
+		"Probed 50 seeds of TestBoundaryCondition in 0-999, 3 failed",
+		"2 distinct failures:\n  Value 101 exceeds threshold 100\n    found at probe 2, seeds: 12, 40",
+		"Minimal reproducing seeds: 7, 12",
+		"  GO_TEST_SEED=7 go test . -run '^TestBoundaryCondition$'",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	printHunt(&out, cfg, &hunt.Result{Probes: 50})
+	if !strings.Contains(out.String(), "No failing seeds found") {
+		t.Errorf("Expected a no-failures note:\n%s", out.String())
+	}
+}
@@ -0,0 +1,117 @@
+// Command flakectl detects flaky Go tests by rerunning them with varying seeds
+//
+// Usage:
+//
+//	flakectl <command> [arguments]
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// command is a flakectl subcommand
+type command struct {
+	summary string
+	run     func(args []string, stdout io.Writer) error
+}
+
+var commands = map[string]command{
+	"bench":             {summary: "rerun benchmarks and flag those whose results swing between runs", run: runBench},
+	"bisect-order":      {summary: "shuffle test order and bisect failures to polluter/victim pairs", run: runBisectOrder},
+	"checks":            {summary: "publish a JSON flake report as a GitHub check run annotating flaky tests", run: runChecks},
+	"compare":           {summary: "compare flake rates of two commits and test the changes for significance", run: runCompare},
+	"generate":          {summary: "generate a suite of synthetic tests with known flake rates and score detection runs against it", run: runGenerate},
+	"hunt":              {summary: "search the seed space for seeds that reproduce each failure of a test", run: runHunt},
+	"detect":            {summary: "rerun the suite N times and report per-test pass rates", run: runDetect},
+	"gate":              {summary: "fail when a test's flake rate rose beyond a budget over a baseline report", run: runGate},
+	"matrix":            {summary: "run the suite across GOMAXPROCS, -parallel, -race, -count, TZ and LANG settings and show which expose each flake", run: runMatrix},
+	"minimize":          {summary: "shrink the tests a failure needs to a minimal set with delta debugging", run: runMinimize},
+	"parallel":          {summary: "run the suite at a low and a high -parallel and flag tests that are not safe to run in parallel", run: runParallel},
+	"quarantine":        {summary: "add, remove or list quarantined tests, and verify which have recovered", run: runQuarantine},
+	"recommend-retries": {summary: "compute the retries each flaky test needs for a target chance of failing CI and write a retry policy", run: runRecommendRetries},
+	"report":            {summary: "show flake-rate trends from the detection history", run: runReport},
+	"reproduce":         {summary: "rerun one test with a recorded failing seed", run: runReproduce},
+	"scenarios":         {summary: "list the failure scenarios with their categories, parameters and default or configured rates", run: runScenarios},
+	"serve":             {summary: "run flake detection as a service that queues jobs submitted over an HTTP API", run: runServe},
+	"show":              {summary: "print the stored stdout, stderr and logs of one failing run of a test", run: runShow},
+	"sweep":             {summary: "shard a large seed range across local workers or serverless endpoints, optionally over a grid of scenario parameters", run: runSweep},
+	"watch":             {summary: "rerun affected packages on file changes and show live flake rates", run: runWatch},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "flakectl: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	if err := cmd.run(args[1:], stdout); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 2
+		}
+		fmt.Fprintf(stderr, "flakectl %s: %v\n", args[0], err)
+		var exit *exitError
+		if errors.As(err, &exit) {
+			return exit.code
+		}
+		return 1
+	}
+	return 0
+}
+
+// exitError is an error that exits flakectl with code rather than 1
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: flakectl <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-17s %s\n", name, commands[name].summary)
+	}
+}
+
+// parseArgs parses flags that may appear before, after or between positional
+// arguments, as in "flakectl detect ./... --runs 50"
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/fingerprint"
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/quarantine"
+)
+
+func TestParseArgsInterspersed(t *testing.T) {
+	fs := flag.NewFlagSet("detect", flag.ContinueOnError)
+	runs := fs.Int("runs", 10, "")
+	positional, err := parseArgs(fs, []string{"./...", "--runs", "50", "./other"})
+	if err != nil {
+		t.Fatalf("parseArgs failed: %v", err)
+	}
+	if *runs != 50 {
+		t.Errorf("Expected runs=50, got %d", *runs)
+	}
+	if strings.Join(positional, " ") != "./... ./other" {
+		t.Errorf("Unexpected positional arguments: %v", positional)
+	}
+}
+
+func TestRunUnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"bogus"}, &stdout, &stderr); code != 2 {
+		t.Errorf("Expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), `unknown command "bogus"`) {
+		t.Errorf("Unexpected stderr: %q", stderr.String())
+	}
+}
+
+func TestRunExitError(t *testing.T) {
+	commands["exit-test"] = command{run: func([]string, io.Writer) error {
+		return &exitError{code: 2, err: errors.New("1 flaky test(s): TestA")}
+	}}
+	defer delete(commands, "exit-test")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"exit-test"}, &stdout, &stderr); code != 2 {
+		t.Errorf("Expected exit code 2, got %d", code)
+	}
+	if want := "flakectl exit-test: 1 flaky test(s): TestA\n"; stderr.String() != want {
+		t.Errorf("Expected %q, got %q", want, stderr.String())
+	}
+}
+
+func TestQuarantinePath(t *testing.T) {
+	t.Setenv(quarantine.FileEnv, "")
+	if got := quarantinePath("", "pkg"); got != filepath.Join("pkg", quarantine.DefaultFile) {
+		t.Errorf("Expected the default list in --dir, got %s", got)
+	}
+	t.Setenv(quarantine.FileEnv, "ci/quarantine.txt")
+	if got := quarantinePath("", "pkg"); got != filepath.Join("pkg", "ci", "quarantine.txt") {
+		t.Errorf("Expected %s in --dir, got %s", quarantine.FileEnv, got)
+	}
+	if got := quarantinePath("", ""); got != filepath.Join("ci", "quarantine.txt") {
+		t.Errorf("Expected %s without --dir, got %s", quarantine.FileEnv, got)
+	}
+	if got := quarantinePath("other.txt", "pkg"); got != "other.txt" {
+		t.Errorf("Expected --quarantine as given, got %s", got)
+	}
+}
+
+func TestDetectExit(t *testing.T) {
+	var results []runner.Result
+	for run := 0; run < 10; run++ {
+		flaky, broken := runner.Pass, runner.Fail
+		if run < 2 {
+			flaky = runner.Fail
+		}
+		results = append(results,
+			runner.Result{Package: "p", Test: "TestStable", Run: run, Outcome: runner.Pass},
+			runner.Result{Package: "p", Test: "TestFlaky", Run: run, Outcome: flaky},
+			runner.Result{Package: "p", Test: "TestBroken", Run: run, Outcome: broken},
+			runner.Result{Package: "p", Test: "TestSkipped", Run: run, Outcome: runner.Skip})
+	}
+	report := runner.Aggregate(10, results)
+	var unbroken []runner.Result
+	for _, r := range results {
+		if r.Test != "TestBroken" {
+			unbroken = append(unbroken, r)
+		}
+	}
+	for _, tc := range []struct {
+		name              string
+		report            *runner.Report
+		flakyRate, broken float64
+		code              int
+		message           string
+	}{
+		{"broken", report, 0, 1, 1, "1 broken test(s): TestBroken"},
+		// TestFlaky fails 20% of runs
+		{"broken at 20%", report, 0, 0.2, 1, "2 broken test(s): TestBroken, TestFlaky"},
+		{"flaky", runner.Aggregate(10, unbroken), 0, 1, 2, "1 flaky test(s): TestFlaky"},
+		{"tolerated", runner.Aggregate(10, unbroken), 0.25, 1, 0, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := detectExit(tc.report, tc.flakyRate, tc.broken)
+			var exit *exitError
+			switch {
+			case tc.code == 0 && err != nil:
+				t.Errorf("Expected no error, got %v", err)
+			case tc.code != 0 && (!errors.As(err, &exit) || exit.code != tc.code):
+				t.Errorf("Expected exit code %d, got %v", tc.code, err)
+			case tc.message != "" && err.Error() != tc.message:
+				t.Errorf("Expected %q, got %q", tc.message, err)
+			}
+		})
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	report := runner.Aggregate(2, []runner.Result{
+		{Package: "p", Test: "TestA", Outcome: runner.Pass, Duration: time.Millisecond},
+		{Package: "p", Test: "TestA", Outcome: runner.Fail, Duration: 3 * time.Millisecond, Output: "    a_test.go:1: boom\n"},
+	})
+	var out bytes.Buffer
+	if err := printReport(&out, report, &runner.Adaptive{Confidence: 0.95, Tolerance: 0.05}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"TestA", "50.0%", "[9.5, 90.5]", "flaky", "2ms", "a_test.go:1: boom"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestPrintReportSubtests(t *testing.T) {
+	report := runner.Aggregate(1, []runner.Result{
+		{Package: "p", Test: "TestTable", Outcome: runner.Fail},
+		{Package: "p", Test: "TestTable/case", Outcome: runner.Fail, Output: "    t_test.go:9: stale\n"},
+	})
+	var out bytes.Buffer
+	if err := printReport(&out, report, &runner.Adaptive{Confidence: 0.95, Tolerance: 0.05}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	if !strings.HasSuffix(lines[1], "failed in subtests") || !strings.HasSuffix(lines[2], "t_test.go:9: stale") {
+		t.Errorf("Expected the parent's failure left to its case:\n%s", out.String())
+	}
+
+	out.Reset()
+	printFailureSignatures(&out, report, nil)
+	if strings.Contains(out.String(), "TestTable:") || !strings.Contains(out.String(), "TestTable/case:") {
+		t.Errorf("Expected signatures of the case only, got %q", out.String())
+	}
+}
+
+func TestPrintFailureCategories(t *testing.T) {
+	report := runner.Aggregate(4, []runner.Result{
+		{Package: "p", Test: "TestA", Outcome: runner.Fail, Kind: runner.Assertion},
+		{Package: "p", Test: "TestB", Outcome: runner.Fail, Kind: runner.Crash},
+		{Package: "p", Test: "TestB", Outcome: runner.Fail, Kind: runner.Race},
+		{Package: "p", Test: "TestB", Outcome: runner.Fail, Kind: runner.Assertion, Output: "connection refused"},
+		{Package: "p", Test: "TestB", Outcome: runner.Fail, Kind: runner.Assertion, Output: "connection refused"},
+	})
+	var out bytes.Buffer
+	printFailureCategories(&out, report, nil)
+	want := "\nFailures that were not assertions:\n  TestB: 2 network, 1 crash, 1 race of 4 failing runs\n"
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+}
+
+func TestPrintCrashes(t *testing.T) {
+	report := runner.Aggregate(4, []runner.Result{
+		{Package: "p", Test: "TestA", Outcome: runner.Fail, Kind: runner.Assertion},
+		{Package: "p", Test: "TestB", Seed: 2, Outcome: runner.Fail, Kind: runner.Crash, Signal: "SIGSEGV", ExitCode: 2},
+		{Package: "p", Test: "TestB", Seed: 3, Outcome: runner.Fail, Kind: runner.Crash, Signal: "SIGKILL"},
+		{Package: "p", Test: "TestB", Seed: 5, Outcome: runner.Fail, Kind: runner.Crash, Signal: "SIGKILL"},
+		{Package: "p", Test: "TestC", Seed: 4, Outcome: runner.Fail, Kind: runner.Crash},
+	})
+	var out bytes.Buffer
+	printCrashes(&out, report)
+	want := "\nCrashed test binaries (counted against the test that started last):\n" +
+		"  TestB: 2 SIGKILL, 1 SIGSEGV, seeds 2, 3, 5\n" +
+		"  TestC: 1 unknown exit, seeds 4\n" +
+		"  A SIGKILL flakectl did not send is usually the kernel's out-of-memory killer; check the memory limit\n"
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+
+	out.Reset()
+	printCrashes(&out, runner.Aggregate(1, []runner.Result{{Package: "p", Test: "TestA", Outcome: runner.Pass}}))
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing without crashes, got %q", out.String())
+	}
+}
+
+func TestPrintReran(t *testing.T) {
+	var out bytes.Buffer
+	printReran(&out, &runner.Report{}, 20)
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing without reruns, got %q", out.String())
+	}
+	printReran(&out, &runner.Report{Reran: []string{"TestA", "TestB"}}, 20)
+	if want := "\nReran 2 failing test(s) 20 more times: TestA, TestB\n"; out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+}
+
+func TestPrintFingerprint(t *testing.T) {
+	var out bytes.Buffer
+	printFingerprint(&out, &runner.Report{})
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing for an unfingerprinted report, got %q", out.String())
+	}
+	fp := fingerprint.Fingerprint{GOOS: "linux", GOARCH: "amd64", GoVersion: "go1.22.5", CPUs: 2}
+	printFingerprint(&out, &runner.Report{Fingerprint: fp})
+	if want := "\nEnvironment " + fp.ID() + ": linux/amd64 go1.22.5, 2 CPUs\n"; out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+}
+
+func TestPrintPosteriors(t *testing.T) {
+	historyFile := filepath.Join(t.TempDir(), "history.db")
+	if priors, err := loadPriors(historyFile, 50); err != nil || priors != nil {
+		t.Fatalf("Expected no priors without history, got %v, %v", priors, err)
+	}
+	var past []runner.Result
+	for run := 0; run < 100; run++ {
+		chronic := runner.Pass
+		if run%10 == 0 {
+			chronic = runner.Fail
+		}
+		past = append(past,
+			runner.Result{Package: "p", Test: "TestStable", Run: run, Outcome: runner.Pass},
+			runner.Result{Package: "p", Test: "TestChronic", Run: run, Outcome: chronic})
+	}
+	if err := recordHistory(historyFile, runner.Aggregate(100, past), "abc"); err != nil {
+		t.Fatal(err)
+	}
+	priors, err := loadPriors(historyFile, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sweep []runner.Result
+	for run := 0; run < 5; run++ {
+		outcome := runner.Pass
+		if run == 0 {
+			outcome = runner.Fail
+		}
+		for _, test := range []string{"TestStable", "TestChronic", "TestNew", "TestPassing"} {
+			if test == "TestPassing" {
+				outcome = runner.Pass
+			}
+			sweep = append(sweep, runner.Result{Package: "p", Test: test, Run: run, Outcome: outcome})
+		}
+	}
+	var out bytes.Buffer
+	if err := printPosteriors(&out, runner.Aggregate(5, sweep), priors, 50, 0.05, 0.95); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\nFlake rates with history as a prior (worth up to 50 runs):\n",
+		"  TEST         THIS SWEEP  HISTORY    POSTERIOR 95% CI   P(RATE > 5.0%)\n",
+		"  TestChronic  1 of 5      10 of 100  11.3% [4.4, 20.8]  0.96\n",
+		"  TestNew      1 of 5      -          25.0% [2.3, 62.9]  0.92\n",
+		"  TestStable   1 of 5      0 of 100   2.3% [0.1, 7.5]    0.10\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "TestPassing") {
+		t.Errorf("Expected only tests that failed this sweep:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := printPosteriors(&out, runner.Aggregate(5, sweep), nil, 50, 0.05, 0.95); err != nil || out.Len() != 0 {
+		t.Errorf("Expected nothing without history, got %q", out.String())
+	}
+}
+
+func TestPrintOutOfTime(t *testing.T) {
+	var out bytes.Buffer
+	printOutOfTime(&out, &runner.Report{Runs: 3}, 10*time.Minute)
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing while in budget, got %q", out.String())
+	}
+	printOutOfTime(&out, &runner.Report{Runs: 3, OutOfTime: true}, 10*time.Minute)
+	if want := "\nStopped after 3 runs: the --max-duration budget of 10m0s ran out\n"; out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+}
+
+func TestPrintStateDependent(t *testing.T) {
+	report := runner.Aggregate(2, []runner.Result{
+		{Package: "p", Test: "TestA", Outcome: runner.Fail},
+		{Package: "p", Test: "TestA", Outcome: runner.Pass},
+	})
+	var out bytes.Buffer
+	printStateDependent(&out, report, nil)
+	if want := "\nEvery failing test also failed in a process of its own\n"; out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+
+	out.Reset()
+	isolated := &runner.TestStats{Package: "p", Test: "TestA", Passed: 2}
+	printStateDependent(&out, report, []runner.StateDependence{{Package: "p", Test: "TestA", Together: report.Tests[0], Isolated: isolated}})
+	want := "\nOrder/state-dependent: 1 test(s) fail alongside others but pass isolated under the same seeds:\n" +
+		"  TestA: failed 1 of 2 runs together, 0 of 2 isolated\n" +
+		"Find the tests they need with: flakectl minimize <test> <package>\n"
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+}
+
+func TestPrintCommonCauses(t *testing.T) {
+	var results []runner.Result
+	for run := 0; run < 20; run++ {
+		outcome := runner.Pass
+		if run%5 == 0 {
+			outcome = runner.Fail
+		}
+		for _, test := range []string{"TestDB", "TestCache"} {
+			results = append(results, runner.Result{Package: "p", Test: test, Run: run, Seed: int64(run + 1), Outcome: outcome})
+		}
+	}
+	var out bytes.Buffer
+	printCommonCauses(&out, runner.Aggregate(20, results))
+	want := "\nCorrelated failures (likely a common cause, such as shared infrastructure):\n" +
+		"  TestCache, TestDB: 4 run(s) with several failing (phi >= 1.00, p <= 0.00021)\n" +
+		"    seeds 1, 6, 11, 16\n"
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+
+	out.Reset()
+	printCommonCauses(&out, runner.Aggregate(1, results[:2]))
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing without correlated failures, got %q", out.String())
+	}
+}
+
+func TestPrintFailureSignatures(t *testing.T) {
+	report := runner.Aggregate(4, []runner.Result{
+		{Package: "p", Test: "TestA", Seed: 1, Outcome: runner.Pass},
+		{Package: "p", Test: "TestB", Seed: 1, Outcome: runner.Fail, Output: "    b_test.go:9: got 0.812\n"},
+		{Package: "p", Test: "TestB", Seed: 2, Outcome: runner.Fail, Output: "    b_test.go:9: got 0.743\n"},
+		{Package: "p", Test: "TestB", Seed: 3, Outcome: runner.Fail, Output: "    b_test.go:14: connection refused\n"},
+	})
+	var out bytes.Buffer
+	printFailureSignatures(&out, report, nil)
+	want := "\nFailure signatures:\n  TestB: 2 signature(s) in 3 failing run(s)\n    2x b_test.go:N: got N (seed 1)\n    1x b_test.go:N: connection refused (seed 3)\n"
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+}
+
+func TestPrintResuming(t *testing.T) {
+	var out bytes.Buffer
+	saved := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	printResuming(&out, &runner.Checkpoint{Completed: 40, Saved: saved, Remaining: runner.SeedRange{First: 41, Last: 100}})
+	if want := "Resuming after 40 run(s) from 2024-05-01T12:00:00Z, seeds 41-100 left\n"; out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+}
+
+func TestPrintDumps(t *testing.T) {
+	var out bytes.Buffer
+	printDumps(&out, nil)
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing without dumps, got %q", out.String())
+	}
+	printDumps(&out, []string{"flaky-dumps/example_com_pkg.TestHangs.seed3.txt"})
+	if want := "\nGoroutine dumps of 1 hung run(s):\n  flaky-dumps/example_com_pkg.TestHangs.seed3.txt\n"; out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+}
+
+func TestDetectResumeNeedsCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.json")
+	if err := runDetect([]string{"--resume", "--checkpoint", path}, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "no checkpoint") {
+		t.Errorf("Expected an error for a missing checkpoint, got %v", err)
+	}
+	if err := runDetect([]string{"--resume", "--daemon"}, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "--daemon") {
+		t.Errorf("Expected --resume to be rejected with --daemon, got %v", err)
+	}
+
+	cp := &runner.Checkpoint{Packages: []string{"./other"}, Runs: 10, Seed: 1}
+	if err := cp.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := runDetect([]string{"--resume", "--checkpoint", path, "./pkg"}, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "flags of the interrupted sweep") {
+		t.Errorf("Expected a mismatched checkpoint to be rejected, got %v", err)
+	}
+}
+
+func TestPrintFlakyCorpus(t *testing.T) {
+	report := runner.Aggregate(3, []runner.Result{
+		{Package: "p", Test: "FuzzParse/seed#2", Run: 0, Seed: 1, Outcome: runner.Pass},
+		{Package: "p", Test: "FuzzParse/seed#2", Run: 1, Seed: 2, Outcome: runner.Fail},
+		{Package: "p", Test: "FuzzParse/seed#2", Run: 2, Seed: 3, Outcome: runner.Fail},
+		{Package: "p", Test: "FuzzParse/broken", Run: 0, Seed: 1, Outcome: runner.Fail},
+	})
+	var out bytes.Buffer
+	printFlakyCorpus(&out, report)
+	want := "\nFlaky fuzz corpus entries:\n  FuzzParse/seed#2 (f.Add call 3): failed 2 of 3 run(s)\n    flakectl reproduce FuzzParse/seed#2 --seed 2\n"
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+}
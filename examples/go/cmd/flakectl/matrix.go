@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/example/flaky-test-example/internal/matrix"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func runMatrix(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("matrix", flag.ContinueOnError)
+	procs := fs.String("gomaxprocs", "1,2,8", "comma-separated GOMAXPROCS values to run with")
+	parallel := fs.String("parallel", "", "comma-separated go test -parallel values for tests that call t.Parallel (empty to inherit)")
+	race := fs.Bool("race", false, "run every configuration both without and with the race detector")
+	counts := fs.String("count", "1", "comma-separated go test -count values, repeating each test in one process")
+	zones := fs.String("tz", "", "comma-separated TZ timezones to run in, such as UTC,Asia/Tokyo (empty to inherit)")
+	langs := fs.String("lang", "", "comma-separated LANG locales to run in, such as C.UTF-8,de_DE.UTF-8 (empty to inherit)")
+	alpha := fs.Float64("alpha", 0.05, "significance level of a flake-rate difference between timezones or locales")
+	runs := fs.Int("runs", 5, "number of times to run the suite per configuration")
+	seed := fs.Int64("seed", 1, "seed of the first run; run i uses seed+i in every configuration")
+	runRegex := fs.String("run", "", "only run tests matching this regex")
+	dir := fs.String("dir", "", "directory to run go test in")
+	packages, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	cfg := matrix.Config{Base: runner.Config{Packages: packages, Runs: *runs, Seed: *seed, Run: *runRegex, Dir: *dir}}
+	if cfg.GOMAXPROCS, err = parseIntList("--gomaxprocs", *procs); err != nil {
+		return err
+	}
+	if *parallel != "" {
+		if cfg.Parallel, err = parseIntList("--parallel", *parallel); err != nil {
+			return err
+		}
+	}
+	if cfg.Count, err = parseIntList("--count", *counts); err != nil {
+		return err
+	}
+	if *race {
+		cfg.Race = []bool{false, true}
+	}
+	if *zones != "" {
+		if cfg.TZ, err = parseStringList("--tz", *zones); err != nil {
+			return err
+		}
+	}
+	if *langs != "" {
+		if cfg.Lang, err = parseStringList("--lang", *langs); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	res, err := matrix.Run(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	return printMatrix(stdout, res, *runs, *alpha)
+}
+
+// parseIntList parses a comma-separated list of integers such as "1,2,8"
+func parseIntList(flagName, s string) ([]int, error) {
+	var list []int
+	for _, field := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q, want comma-separated integers", flagName, s)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+// parseStringList parses a comma-separated list of non-empty values such as
+// "UTC,Asia/Tokyo"
+func parseStringList(flagName, s string) ([]string, error) {
+	var list []string
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			return nil, fmt.Errorf("invalid %s %q, want comma-separated values", flagName, s)
+		}
+		list = append(list, field)
+	}
+	return list, nil
+}
+
+// printMatrix writes the flake rate of every test that failed anywhere in
+// each configuration, then the configurations that expose each of them and
+// the tests that follow the timezone or locale
+func printMatrix(w io.Writer, res *matrix.Result, runs int, alpha float64) error {
+	fmt.Fprintf(w, "%d configuration(s), %d run(s) each\n", len(res.Cells), runs)
+	exposures := res.Exposures()
+	if len(exposures) == 0 {
+		fmt.Fprintln(w, "\nNo test failed in any configuration")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "\nTEST")
+	for _, cell := range res.Cells {
+		fmt.Fprintf(tw, "\t%s", cell)
+	}
+	fmt.Fprintln(tw)
+	for _, e := range exposures {
+		fmt.Fprint(tw, e.Test)
+		for _, s := range e.Stats {
+			if s.Runs() == 0 {
+				fmt.Fprint(tw, "\t-")
+				continue
+			}
+			fmt.Fprintf(tw, "\t%.1f%%", s.FlakeRate()*100)
+		}
+		fmt.Fprintln(tw)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	for _, e := range exposures {
+		exposed := e.Exposed(res.Cells)
+		if len(exposed) == len(res.Cells) {
+			fmt.Fprintf(w, "%s fails in every configuration\n", e.Test)
+			continue
+		}
+		names := make([]string, len(exposed))
+		for i, cell := range exposed {
+			names[i] = cell.String()
+		}
+		fmt.Fprintf(w, "%s only fails with %s\n", e.Test, strings.Join(names, "; "))
+	}
+	printEnvironmentSensitive(w, res.EnvironmentSensitive(alpha), alpha)
+	return nil
+}
+
+// printEnvironmentSensitive lists the tests whose flake rate follows the
+// timezone or locale, marking those that pass or fail every run of a
+// setting, which no number of reruns on one machine reveals
+func printEnvironmentSensitive(w io.Writer, sensitive []matrix.EnvironmentSensitivity, alpha float64) {
+	if len(sensitive) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nEnvironment-sensitive: %d test(s) fail at different rates across TZ/LANG (p < %g, Fisher's exact test):\n", len(sensitive), alpha)
+	for _, e := range sensitive {
+		fmt.Fprintf(w, "  %s: fails with %s", e.Test, strings.Join(e.Failing(), "; "))
+		if passing := e.Passing(); len(passing) > 0 {
+			fmt.Fprintf(w, ", passes with %s", strings.Join(passing, "; "))
+		}
+		fmt.Fprintln(w)
+		if e.Deterministic {
+			fmt.Fprintln(w, "    same outcome on every run of a setting: reruns in one environment never show it")
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/matrix"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestPrintMatrix(t *testing.T) {
+	report := func(timing runner.Outcome) *runner.Report {
+		return runner.Aggregate(2, []runner.Result{
+			{Package: "p", Test: "TestTiming", Outcome: timing},
+			{Package: "p", Test: "TestTiming", Outcome: runner.Pass},
+			{Package: "p", Test: "TestBroken", Outcome: runner.Fail},
+			{Package: "p", Test: "TestBroken", Outcome: runner.Fail},
+			{Package: "p", Test: "TestFine", Outcome: runner.Pass},
+		})
+	}
+	res := &matrix.Result{
+		Cells:   []matrix.Cell{{GOMAXPROCS: 1, Race: true, Count: 1}, {GOMAXPROCS: 8, Count: 1}},
+		Reports: []*runner.Report{report(runner.Fail), report(runner.Pass)},
+	}
+	var out bytes.Buffer
+	if err := printMatrix(&out, res, 2, 0.05); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"2 configuration(s), 2 run(s) each",
+		"TestTiming  50.0%",
+		"TestBroken fails in every configuration",
+		"TestTiming only fails with GOMAXPROCS=1 -race",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "TestFine") {
+		t.Errorf("Expected a test that never failed to be left out:\n%s", got)
+	}
+}
+
+func TestPrintMatrixEnvironmentSensitive(t *testing.T) {
+	report := func(outcome runner.Outcome) *runner.Report {
+		var results []runner.Result
+		for run := 0; run < 5; run++ {
+			results = append(results, runner.Result{Package: "p", Test: "TestLocale", Run: run, Outcome: outcome})
+		}
+		return runner.Aggregate(5, results)
+	}
+	res := &matrix.Result{
+		Cells:   []matrix.Cell{{Count: 1, Lang: "C.UTF-8"}, {Count: 1, Lang: "de_DE.UTF-8"}},
+		Reports: []*runner.Report{report(runner.Pass), report(runner.Fail)},
+	}
+	var out bytes.Buffer
+	if err := printMatrix(&out, res, 5, 0.05); err != nil {
+		t.Fatal(err)
+	}
+	want := "\nEnvironment-sensitive: 1 test(s) fail at different rates across TZ/LANG (p < 0.05, Fisher's exact test):\n" +
+		"  TestLocale: fails with LANG=de_DE.UTF-8, passes with LANG=C.UTF-8\n" +
+		"    same outcome on every run of a setting: reruns in one environment never show it\n"
+	if got := out.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("Expected the output to end with %q, got:\n%s", want, got)
+	}
+	if _, err := parseStringList("--tz", "UTC,,Asia/Tokyo"); err == nil {
+		t.Error("Expected an empty timezone to be rejected")
+	}
+}
+
+func TestParseIntList(t *testing.T) {
+	if got, err := parseIntList("--gomaxprocs", "1, 2,8"); err != nil || len(got) != 3 || got[2] != 8 {
+		t.Errorf("Expected [1 2 8], got %v, %v", got, err)
+	}
+	if _, err := parseIntList("--gomaxprocs", "1,,2"); err == nil {
+		t.Error("Expected an empty entry to be rejected")
+	}
+}
+
+func TestPrintParallelSafety(t *testing.T) {
+	safety := []matrix.ParallelSafety{
+		{Test: "TestFine", Serial: runner.TestStats{Passed: 10}, Parallel: runner.TestStats{Passed: 10}, PValue: 1},
+		{Test: "TestShared", Serial: runner.TestStats{Passed: 10}, Parallel: runner.TestStats{Passed: 2, Failed: 8}, PValue: 0.0007, Unsafe: true},
+		{Test: "TestFlaky", Serial: runner.TestStats{Passed: 7, Failed: 3}, Parallel: runner.TestStats{Passed: 6, Failed: 4}, PValue: 1},
+	}
+	var out bytes.Buffer
+	if err := printParallelSafety(&out, safety, 1, 8, 10, 0.05); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"10 run(s) at -parallel=1 and -parallel=8",
+		"TestShared  0.0% (0/10)   80.0% (8/10)  0.001 *",
+		"TestFlaky   30.0% (3/10)  40.0% (4/10)  1.000\n",
+		"1 test(s) are not safe to run in parallel",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "TestFine") {
+		t.Errorf("Expected a test that never failed to be left out:\n%s", got)
+	}
+
+	out.Reset()
+	if err := printParallelSafety(&out, safety[:1], 1, 8, 10, 0.05); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "No test failed at either -parallel across 1 test(s)") {
+		t.Errorf("Expected no failures reported, got:\n%s", out.String())
+	}
+}
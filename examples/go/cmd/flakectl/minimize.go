@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/example/flaky-test-example/internal/minimize"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func runMinimize(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("minimize", flag.ContinueOnError)
+	seed := fs.Int64("seed", 1, "GO_TEST_SEED of every run, such as a failing seed from detect")
+	tries := fs.Int("tries", 1, "runs of each selection; the failure reproduces if the test fails in any")
+	shuffle := fs.String("shuffle", "", "go test -shuffle value of every run, for failures that need an order")
+	race := fs.Bool("race", false, "build and run the tests with the race detector")
+	dir := fs.String("dir", "", "directory to run go test in")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 1 || len(positional) > 2 {
+		return errors.New("usage: flakectl minimize <TestName> [package] [--seed N] [--tries N]")
+	}
+	cfg := minimize.Config{Package: ".", Dir: *dir, Test: positional[0], Seed: *seed, Tries: *tries}
+	if len(positional) == 2 {
+		cfg.Package = positional[1]
+	}
+	if *tries < 1 {
+		return fmt.Errorf("--tries must be at least 1, got %d", *tries)
+	}
+	if *shuffle != "" {
+		cfg.Args = append(cfg.Args, "-shuffle", *shuffle)
+	}
+	if *race {
+		cfg.Args = append(cfg.Args, "-race")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	res, err := minimize.Run(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	printMinimize(stdout, cfg, res)
+	return nil
+}
+
+// printMinimize writes the minimal set of tests and the command that
+// reproduces the failure with them
+func printMinimize(w io.Writer, cfg minimize.Config, res *minimize.Result) {
+	fmt.Fprintf(w, "%s fails with all %d tests of %s selected (%s=%d)\n", cfg.Test, len(res.Suite), cfg.Package, runner.SeedEnv, cfg.Seed)
+	if res.Alone {
+		fmt.Fprintf(w, "\n%s also fails on its own; no other test is needed to reproduce it\n", cfg.Test)
+	} else {
+		fmt.Fprintf(w, "\nMinimized to %d of %d other test(s) in %d probe(s):\n", len(res.Minimal), len(res.Suite)-1, len(res.Probes))
+		for _, test := range res.Minimal {
+			fmt.Fprintf(w, "  %s\n", test)
+		}
+	}
+	args := append([]string{"-count=1", "-run", "'" + res.Pattern(cfg.Test) + "'"}, cfg.Args...)
+	fmt.Fprintf(w, "\nReproduce with:\n  %s=%d go test %s %s\n", runner.SeedEnv, cfg.Seed, strings.Join(args, " "), cfg.Package)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/minimize"
+)
+
+func TestPrintMinimize(t *testing.T) {
+	cfg := minimize.Config{Package: "./pkg", Test: "TestVictim", Seed: 7, Args: []string{"-shuffle", "3"}}
+	res := &minimize.Result{
+		Suite:   []string{"TestA", "TestB", "TestC", "TestVictim"},
+		Minimal: []string{"TestA", "TestC"},
+		Probes:  make([]minimize.Probe, 5),
+	}
+	var out bytes.Buffer
+	printMinimize(&out, cfg, res)
+	for _, want := range []string{
+		"TestVictim fails with all 4 tests of ./pkg selected (GO_TEST_SEED=7)",
+		"Minimized to 2 of 3 other test(s) in 5 probe(s):\n  TestA\n  TestC\n",
+		"GO_TEST_SEED=7 go test -count=1 -run '^(TestA|TestC|TestVictim)$' -shuffle 3 ./pkg",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in output:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	printMinimize(&out, cfg, &minimize.Result{Suite: res.Suite, Alone: true})
+	if !strings.Contains(out.String(), "also fails on its own") || !strings.Contains(out.String(), "-run '^(TestVictim)$'") {
+		t.Errorf("Unexpected output for a failure alone:\n%s", out.String())
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/notify"
+	"github.com/example/flaky-test-example/internal/owners"
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/quarantine"
+)
+
+// notifications sends the changes each sweep makes to the history
+type notifications struct {
+	notifier       notify.Notifier
+	rule           notify.Rule
+	quarantineFile string
+	// owners assigns the events to teams, and only the events of owner
+	// are sent unless it is empty
+	owners *owners.Map
+	owner  string
+}
+
+// newNotifications returns notifications to the given Slack and generic
+// webhooks, or nil when neither is set
+func newNotifications(slackURL, webhookURL string, rule notify.Rule, quarantineFile string) *notifications {
+	var m notify.Multi
+	if slackURL != "" {
+		m = append(m, &notify.Slack{WebhookURL: slackURL})
+	}
+	if webhookURL != "" {
+		m = append(m, &notify.Webhook{URL: webhookURL})
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return &notifications{notifier: m, rule: rule, quarantineFile: quarantineFile, owners: &owners.Map{}}
+}
+
+// record adds report to the history at historyFile and notifies about the
+// changes it makes there
+// A notification that cannot be delivered is reported on stderr, so an
+// unreachable webhook does not fail the sweep
+func (n *notifications) record(ctx context.Context, stdout io.Writer, historyFile string, report *runner.Report, commit string) error {
+	if n == nil {
+		return recordHistory(historyFile, report, commit)
+	}
+	db, err := history.Open(historyFile)
+	if err != nil {
+		return err
+	}
+	session := history.NewSession(report, commit)
+	past, err := db.Sessions(time.Time{})
+	if err == nil {
+		err = db.Add(session)
+	}
+	if err != nil {
+		db.Close()
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	list, err := quarantine.Load(n.quarantineFile)
+	if err != nil {
+		return err
+	}
+	events := ownEvents(notify.Events(past, session, list, n.rule), n.owners, n.owner)
+	if len(events) == 0 {
+		return nil
+	}
+	if err := n.notifier.Notify(ctx, events); err != nil {
+		fmt.Fprintf(os.Stderr, "flakectl: notify: %v\n", err)
+		return nil
+	}
+	fmt.Fprintf(stdout, "Sent %d notification(s)\n", len(events))
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/notify"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestNotificationsSendEachChangeOnce(t *testing.T) {
+	var received []notify.Event
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Events []notify.Event `json:"events"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		received = append(received, payload.Events...)
+	}))
+	defer hook.Close()
+
+	dir := t.TempDir()
+	notes := newNotifications("", hook.URL, notify.Rule{FlakeRate: 0.05, PassingRuns: 20}, filepath.Join(dir, "quarantine.txt"))
+	historyFile := filepath.Join(dir, "history.db")
+	report := runner.Aggregate(2, []runner.Result{
+		{Package: "p", Test: "TestFlaky", Run: 0, Outcome: runner.Pass},
+		{Package: "p", Test: "TestFlaky", Run: 1, Outcome: runner.Fail, Output: "    f_test.go:3: boom\n"},
+	})
+
+	var out bytes.Buffer
+	for sweep := 0; sweep < 2; sweep++ {
+		if err := notes.record(context.Background(), &out, historyFile, report, "abc"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(received) != 1 || received[0].Kind != notify.NewlyFlaky || received[0].Test != "TestFlaky" || received[0].Commit != "abc" {
+		t.Errorf("Expected one newly flaky event, got %+v", received)
+	}
+	if strings.Count(out.String(), "Sent 1 notification(s)") != 1 {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+}
+
+func TestDetectNotificationsNeedHistory(t *testing.T) {
+	err := runDetect([]string{"--webhook", "http://localhost/hook", "--history", ""}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "--history") {
+		t.Errorf("Expected notifications without history to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/notify"
+	"github.com/example/flaky-test-example/internal/owners"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// loadOwners reads the owners file at path, which --owner needs rules in
+// to filter by
+func loadOwners(path, owner string) (*owners.Map, error) {
+	m, err := owners.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if owner != "" && len(m.Rules) == 0 {
+		return nil, fmt.Errorf("--owner %s needs an owners file, and %s has no rules", owner, path)
+	}
+	return m, nil
+}
+
+// ownedReport returns the part of report about the tests owner owns, or
+// report itself when owner is empty
+func ownedReport(report *runner.Report, m *owners.Map, owner string) *runner.Report {
+	if owner == "" {
+		return report
+	}
+	owned := *report
+	owned.Results, owned.Tests = nil, nil
+	for _, r := range report.Results {
+		if m.Owns(owner, r.Package, r.Test) {
+			owned.Results = append(owned.Results, r)
+		}
+	}
+	for _, s := range report.Tests {
+		if m.Owns(owner, s.Package, s.Test) {
+			owned.Tests = append(owned.Tests, s)
+		}
+	}
+	return &owned
+}
+
+// ownedSessions returns sessions with only the records of the tests owner
+// owns, or sessions themselves when owner is empty
+func ownedSessions(sessions []history.Session, m *owners.Map, owner string) []history.Session {
+	if owner == "" {
+		return sessions
+	}
+	owned := make([]history.Session, len(sessions))
+	for i, s := range sessions {
+		owned[i] = s
+		owned[i].Results = nil
+		for _, r := range s.Results {
+			if m.Owns(owner, r.Package, r.Test) {
+				owned[i].Results = append(owned[i].Results, r)
+			}
+		}
+	}
+	return owned
+}
+
+// ownEvents sets the owners of every event and keeps those of the tests
+// owner owns, or all of them when owner is empty
+func ownEvents(events []notify.Event, m *owners.Map, owner string) []notify.Event {
+	var owned []notify.Event
+	for _, e := range events {
+		e.Owners = m.Owners(e.Package, e.Test)
+		if owner == "" || m.Owns(owner, e.Package, e.Test) {
+			owned = append(owned, e)
+		}
+	}
+	return owned
+}
+
+// ownerLine is a digest line of one owner's test
+type ownerLine struct {
+	owner, line string
+}
+
+// printOwners writes the lines under a heading per owner, owners in name
+// order and the unowned tests last, so each team finds its own tests
+func printOwners(w io.Writer, title string, lines []ownerLine) {
+	if len(lines) == 0 {
+		return
+	}
+	byOwner := make(map[string][]string)
+	var names []string
+	for _, l := range lines {
+		if byOwner[l.owner] == nil {
+			names = append(names, l.owner)
+		}
+		byOwner[l.owner] = append(byOwner[l.owner], l.line)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == owners.Unowned) != (names[j] == owners.Unowned) {
+			return names[j] == owners.Unowned
+		}
+		return names[i] < names[j]
+	})
+	fmt.Fprintf(w, "\n%s:\n", title)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s: %d test(s)\n    %s\n", name, len(byOwner[name]), strings.Join(byOwner[name], "\n    "))
+	}
+}
+
+// printOwnerDigest lists the flaky and failing tests of a sweep per owner,
+// when an owners file assigns tests to any
+func printOwnerDigest(w io.Writer, report *runner.Report, m *owners.Map) {
+	if len(m.Rules) == 0 {
+		return
+	}
+	var lines []ownerLine
+	for _, s := range report.Tests {
+		class := s.Classify()
+		if (class != runner.Flaky && class != runner.Failing) || s.FailsThroughSubtests() {
+			continue
+		}
+		line := fmt.Sprintf("%s: %s, failed %d of %d run(s)", s.Test, class, s.Failed, s.Passed+s.Failed)
+		for _, team := range m.Teams(s.Package, s.Test) {
+			lines = append(lines, ownerLine{team, line})
+		}
+	}
+	printOwners(w, "Flaky and failing tests by owner ("+m.Path+")", lines)
+}
+
+// printTrendOwners lists the tests that failed in the window per owner,
+// when an owners file assigns tests to any
+func printTrendOwners(w io.Writer, trends []history.Trend, m *owners.Map) {
+	if len(m.Rules) == 0 {
+		return
+	}
+	var lines []ownerLine
+	for _, t := range trends {
+		if t.Window.Failed == 0 {
+			continue
+		}
+		line := fmt.Sprintf("%s: %.1f%% over %d runs, %s", t.Test, t.Window.FlakeRate()*100, t.Window.Runs(), t.Status)
+		for _, team := range m.Teams(t.Package, t.Test) {
+			lines = append(lines, ownerLine{team, line})
+		}
+	}
+	printOwners(w, "Failing tests by owner ("+m.Path+")", lines)
+}
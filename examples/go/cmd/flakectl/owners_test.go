@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/notify"
+	"github.com/example/flaky-test-example/internal/owners"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// writeOwnersFile writes an owners file giving TestPay* to @payments and
+// TestShared* to both teams
+func writeOwnersFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "owners.txt")
+	content := "TestPay*    @payments\nTestShared* @payments @web\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func ownersReport() *runner.Report {
+	var results []runner.Result
+	for run := 0; run < 4; run++ {
+		flaky := runner.Pass
+		if run == 0 {
+			flaky = runner.Fail
+		}
+		results = append(results,
+			runner.Result{Package: "p", Test: "TestPayRefund", Run: run, Outcome: flaky, Output: "    pay_test.go:3: boom\n"},
+			runner.Result{Package: "p", Test: "TestSharedDB", Run: run, Outcome: runner.Fail, Output: "    db_test.go:3: down\n"},
+			runner.Result{Package: "p", Test: "TestCart", Run: run, Outcome: flaky, Output: "    cart_test.go:3: boom\n"},
+			runner.Result{Package: "p", Test: "TestStable", Run: run, Outcome: runner.Pass},
+		)
+	}
+	return runner.Aggregate(4, results)
+}
+
+func TestOwnedReport(t *testing.T) {
+	m, err := loadOwners(writeOwnersFile(t), "@web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := ownersReport()
+	if ownedReport(report, m, "") != report {
+		t.Error("Expected no --owner to keep the whole report")
+	}
+	owned := ownedReport(report, m, "@web")
+	if len(owned.Tests) != 1 || owned.Tests[0].Test != "TestSharedDB" || len(owned.Results) != 4 || owned.Runs != 4 {
+		t.Errorf("Expected only TestSharedDB, got %+v", owned.Tests)
+	}
+	if len(report.Tests) != 4 {
+		t.Error("Expected the full report to be left alone")
+	}
+}
+
+func TestPrintOwnerDigest(t *testing.T) {
+	m, err := loadOwners(writeOwnersFile(t), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	printOwnerDigest(&out, ownersReport(), m)
+	got := out.String()
+	for _, want := range []string{
+		"  @payments: 2 test(s)\n    TestPayRefund: flaky, failed 1 of 4 run(s)\n    TestSharedDB: failing, failed 4 of 4 run(s)\n",
+		"  @web: 1 test(s)\n    TestSharedDB: failing",
+		"  " + owners.Unowned + ": 1 test(s)\n    TestCart: flaky",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected the digest to contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "TestStable") || strings.Index(got, "@web") > strings.Index(got, owners.Unowned) {
+		t.Errorf("Expected stable tests left out and unowned ones last:\n%s", got)
+	}
+
+	out.Reset()
+	printOwnerDigest(&out, ownersReport(), &owners.Map{})
+	if out.Len() != 0 {
+		t.Errorf("Expected no digest without an owners file, got %q", out.String())
+	}
+}
+
+func TestOwnEvents(t *testing.T) {
+	m, err := loadOwners(writeOwnersFile(t), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []notify.Event{{Package: "p", Test: "TestPayRefund"}, {Package: "p", Test: "TestCart"}}
+	all := ownEvents(events, m, "")
+	if len(all) != 2 || len(all[0].Owners) != 1 || all[0].Owners[0] != "@payments" || all[1].Owners != nil {
+		t.Errorf("Expected every event with its owners, got %+v", all)
+	}
+	if owned := ownEvents(events, m, "@PAYMENTS"); len(owned) != 1 || owned[0].Test != "TestPayRefund" {
+		t.Errorf("Expected only the payments event, got %+v", owned)
+	}
+}
+
+func TestRunReportOwner(t *testing.T) {
+	historyFile := filepath.Join(t.TempDir(), "history.db")
+	if err := recordHistory(historyFile, ownersReport(), "abc"); err != nil {
+		t.Fatal(err)
+	}
+	ownersFile := writeOwnersFile(t)
+	var out bytes.Buffer
+	if err := runReport([]string{"--history", historyFile, "--owners", ownersFile, "--owner", "@web"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, "TestSharedDB") || strings.Contains(got, "TestCart") || strings.Contains(got, "TestPayRefund") {
+		t.Errorf("Expected only the @web tests:\n%s", got)
+	}
+	err := runReport([]string{"--history", historyFile, "--owners", filepath.Join(t.TempDir(), "none.txt"), "--owner", "@web"}, &out)
+	if err == nil || !strings.Contains(err.Error(), "no rules") {
+		t.Errorf("Expected --owner without an owners file to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+
+	"github.com/example/flaky-test-example/internal/matrix"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func runParallel(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("parallel", flag.ContinueOnError)
+	serial := fs.Int("serial", 1, "go test -parallel of the baseline runs")
+	parallel := fs.Int("parallel", 8, "go test -parallel to compare the baseline with")
+	runs := fs.Int("runs", 20, "number of times to run the suite at each -parallel")
+	seed := fs.Int64("seed", 1, "seed of the first run; run i uses seed+i at both values")
+	runRegex := fs.String("run", "", "only run tests matching this regex")
+	dir := fs.String("dir", "", "directory to run go test in")
+	alpha := fs.Float64("alpha", 0.05, "significance level of a flake-rate difference")
+	packages, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *serial < 1 || *parallel <= *serial {
+		return fmt.Errorf("want 1 <= --serial < --parallel, got %d and %d", *serial, *parallel)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	res, err := matrix.Run(ctx, matrix.Config{
+		Base:     runner.Config{Packages: packages, Runs: *runs, Seed: *seed, Run: *runRegex, Dir: *dir},
+		Parallel: []int{*serial, *parallel},
+	})
+	if err != nil {
+		return err
+	}
+	return printParallelSafety(stdout, res.ParallelSafety(*alpha), *serial, *parallel, *runs, *alpha)
+}
+
+// printParallelSafety lists the flake rate at both -parallel values of every
+// test that failed at either, then the tests that are parallel-unsafe
+func printParallelSafety(w io.Writer, safety []matrix.ParallelSafety, serial, parallel, runs int, alpha float64) error {
+	fmt.Fprintf(w, "%d run(s) at -parallel=%d and -parallel=%d\n\n", runs, serial, parallel)
+	var failed []matrix.ParallelSafety
+	unsafe := 0
+	for _, s := range safety {
+		if s.Serial.Failed > 0 || s.Parallel.Failed > 0 {
+			failed = append(failed, s)
+		}
+		if s.Unsafe {
+			unsafe++
+		}
+	}
+	if len(failed) == 0 {
+		fmt.Fprintf(w, "No test failed at either -parallel across %d test(s)\n", len(safety))
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "TEST\t-parallel=%d\t-parallel=%d\tP-VALUE\n", serial, parallel)
+	for _, s := range failed {
+		pValue := fmt.Sprintf("%.3f", s.PValue)
+		if s.Unsafe {
+			pValue += " *"
+		}
+		fmt.Fprintf(tw, "%s\t%.1f%% (%d/%d)\t%.1f%% (%d/%d)\t%s\n", s.Test,
+			s.Serial.FlakeRate()*100, s.Serial.Failed, s.Serial.Passed+s.Serial.Failed,
+			s.Parallel.FlakeRate()*100, s.Parallel.Failed, s.Parallel.Passed+s.Parallel.Failed, pValue)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n* fails significantly more at -parallel=%d (p < %g, Fisher's exact test)\n", parallel, alpha)
+	if unsafe == 0 {
+		fmt.Fprintln(w, "No test's flake rate depends on -parallel")
+		return nil
+	}
+	fmt.Fprintf(w, "%d test(s) are not safe to run in parallel: they share state with the tests running beside them\n", unsafe)
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/progress"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// progressRefresh is how often the live view redraws between runs
+const progressRefresh = time.Second
+
+// newProgress returns the progress view mode asks for on w, nil for "off",
+// and a function that stops its refreshes
+// "auto" is the live view on a terminal and the log anywhere else
+func newProgress(mode string, cfg runner.Config, w io.Writer) (*progress.View, func(), error) {
+	var live bool
+	switch mode {
+	case "off":
+		return nil, func() {}, nil
+	case "live":
+		live = true
+	case "log":
+	case "auto":
+		live = isTerminal(w)
+	default:
+		return nil, nil, fmt.Errorf("unknown --progress %q (want auto, live, log or off)", mode)
+	}
+
+	runs := cfg.Runs
+	if cfg.Adaptive != nil && cfg.Adaptive.MaxRuns > runs {
+		runs = cfg.Adaptive.MaxRuns
+	}
+	view := progress.New(w, progress.Config{Runs: runs + cfg.RerunFailed, Seed: cfg.Seed, Live: live})
+	if !live {
+		return view, func() {}, nil
+	}
+	ticker := time.NewTicker(progressRefresh)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				view.Refresh()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return view, func() { ticker.Stop(); close(done) }, nil
+}
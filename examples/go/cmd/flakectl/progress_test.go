@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestNewProgress(t *testing.T) {
+	cfg := runner.Config{Runs: 10, RerunFailed: 5, Adaptive: &runner.Adaptive{MaxRuns: 50}}
+	for mode, wantView := range map[string]bool{"off": false, "log": true, "live": true, "auto": true} {
+		view, stop, err := newProgress(mode, cfg, &bytes.Buffer{})
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if (view != nil) != wantView {
+			t.Errorf("%s: expected a view %v, got %v", mode, wantView, view != nil)
+		}
+		stop()
+	}
+	if _, _, err := newProgress("fancy", cfg, &bytes.Buffer{}); err == nil {
+		t.Error("Expected an unknown --progress mode to be rejected")
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/example/flaky-test-example/quarantine"
+)
+
+const quarantineUsage = "usage: flakectl quarantine add|remove|list|status|verify [TestName] [--reason text] [--file path]"
+
+func runQuarantine(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(quarantineUsage)
+	}
+	action := args[0]
+	switch action {
+	case "verify":
+		return runQuarantineVerify(args[1:], stdout)
+	case "status":
+		return runQuarantineStatus(args[1:], stdout)
+	}
+
+	fs := flag.NewFlagSet("quarantine "+action, flag.ContinueOnError)
+	file := fs.String("file", quarantine.File(), "quarantine list (.json for JSON, otherwise one test per line)")
+	reason := fs.String("reason", "", "why the test is quarantined (add only)")
+	positional, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return err
+	}
+
+	list, err := quarantine.Load(*file)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "list":
+		for _, e := range list.Entries {
+			if e.Reason != "" {
+				fmt.Fprintf(stdout, "%s\t%s\n", e.Test, e.Reason)
+			} else {
+				fmt.Fprintln(stdout, e.Test)
+			}
+		}
+		return nil
+	case "add", "remove":
+		if len(positional) == 0 {
+			return errors.New(quarantineUsage)
+		}
+		for _, test := range positional {
+			if action == "add" {
+				if !list.Add(test, *reason) {
+					fmt.Fprintf(stdout, "%s is already quarantined\n", test)
+					continue
+				}
+				fmt.Fprintf(stdout, "Quarantined %s\n", test)
+			} else {
+				if !list.Remove(test) {
+					return fmt.Errorf("%s is not quarantined in %s", test, *file)
+				}
+				fmt.Fprintf(stdout, "Released %s\n", test)
+			}
+		}
+		return list.Save()
+	default:
+		return fmt.Errorf("unknown quarantine action %q; %s", action, quarantineUsage)
+	}
+}
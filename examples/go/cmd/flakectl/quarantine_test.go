@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/quarantine"
+)
+
+func TestQuarantineCommand(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "quarantine.txt")
+	var out bytes.Buffer
+
+	if err := runQuarantine([]string{"add", "TestRandomFailure", "--reason", "30% flaky", "--file", file}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := runQuarantine([]string{"add", "TestChannelRace", "--file", file}, &out); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := runQuarantine([]string{"list", "--file", file}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "TestChannelRace\nTestRandomFailure\t30% flaky\n" {
+		t.Errorf("Unexpected list output: %q", out.String())
+	}
+
+	if err := runQuarantine([]string{"remove", "TestChannelRace", "--file", file}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := runQuarantine([]string{"remove", "TestChannelRace", "--file", file}, &out); err == nil {
+		t.Error("Expected an error removing a test that is not quarantined")
+	}
+	if err := runQuarantine([]string{"bogus", "--file", file}, &out); err == nil {
+		t.Error("Expected an error for an unknown action")
+	}
+}
+
+func TestPrintQuarantineSuggestions(t *testing.T) {
+	report := runner.Aggregate(2, []runner.Result{
+		{Package: "p", Test: "TestNew", Outcome: runner.Pass},
+		{Package: "p", Test: "TestNew", Outcome: runner.Fail},
+		{Package: "p", Test: "TestKnown", Outcome: runner.Fail},
+		{Package: "p", Test: "TestKnown", Outcome: runner.Pass},
+		{Package: "p", Test: "TestStable", Outcome: runner.Pass},
+	})
+	list := &quarantine.List{Entries: []quarantine.Entry{{Test: "TestKnown"}}}
+
+	var out bytes.Buffer
+	printQuarantineSuggestions(&out, report, list, 0.95)
+	if !strings.Contains(out.String(), "flakectl quarantine add TestNew") {
+		t.Errorf("Expected TestNew to be suggested:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "TestKnown") || strings.Contains(out.String(), "TestStable") {
+		t.Errorf("Suggested a quarantined or stable test:\n%s", out.String())
+	}
+}
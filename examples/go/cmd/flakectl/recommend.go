@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	reportfmt "github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/retrypolicy"
+)
+
+func runRecommendRetries(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("recommend-retries", flag.ContinueOnError)
+	reportPath := fs.String("report", "", "JSON flake report to recommend from, as written by flakectl detect --json (default: run the suite now)")
+	target := fs.Float64("target", 0.001, "largest chance a test's flakes may still fail CI after its retries")
+	maxRetries := fs.Int("max-retries", 5, "most retries to recommend for any test")
+	estimate := fs.String("estimate", "upper", "flake rate to plan for: upper, the upper bound of its confidence interval, or rate, the measured rate")
+	out := fs.String("out", retrypolicy.DefaultFile, "retry policy file to write for flaky.RetryAttempts and CI retry wrappers (empty to only print)")
+	runs := fs.Int("runs", 20, "number of times to run the suite when there is no --report")
+	seed := fs.Int64("seed", 1, "seed of the first run; run i uses seed+i")
+	runRegex := fs.String("run", "", "only run tests matching this regex")
+	dir := fs.String("dir", "", "directory to run go test in")
+	confidence := fs.Float64("confidence", 0.95, "confidence level of the flake-rate intervals when running the suite")
+	packages, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *target <= 0 || *target >= 1 {
+		return fmt.Errorf("--target must be in (0, 1), got %v", *target)
+	}
+	if *maxRetries < 0 {
+		return fmt.Errorf("--max-retries must not be negative, got %d", *maxRetries)
+	}
+	if *estimate != "upper" && *estimate != "rate" {
+		return fmt.Errorf("--estimate must be upper or rate, got %q", *estimate)
+	}
+
+	var report *reportfmt.JSONReport
+	if *reportPath != "" {
+		if report, err = reportfmt.LoadJSON(*reportPath); err != nil {
+			return err
+		}
+	} else {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		r, err := runner.Detect(ctx, runner.Config{Packages: packages, Runs: *runs, Seed: *seed, Run: *runRegex, Dir: *dir})
+		if err != nil {
+			return err
+		}
+		report = reportfmt.NewJSONReport(r, *confidence, nil)
+	}
+
+	policy, broken := recommendRetries(report, *target, *maxRetries, *estimate == "upper")
+	if err := printRecommendation(stdout, policy, broken); err != nil {
+		return err
+	}
+	if *out == "" {
+		return nil
+	}
+	policy.Path = *out
+	if err := policy.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "\nWrote %d rule(s) to %s\n", len(policy.Rules), *out)
+	return nil
+}
+
+// recommendRetries builds the retry policy for every test of report that
+// flaked, planning for the upper bound of its flake-rate interval when upper
+// is set, and returns the tests that never passed, which retries cannot fix
+// Stable and skipped tests get no rule
+func recommendRetries(report *reportfmt.JSONReport, target float64, maxRetries int, upper bool) (*retrypolicy.Policy, []string) {
+	policy := &retrypolicy.Policy{Target: target, MaxRetries: maxRetries}
+	var broken []string
+	for _, t := range report.Tests {
+		if t.Classification == string(runner.Failing) {
+			broken = append(broken, t.Test)
+		}
+		if t.Classification != string(runner.Flaky) {
+			continue
+		}
+		rate := t.FlakeRate
+		if upper {
+			rate = max(rate, t.FlakeRateCI[1])
+		}
+		retries, residual := retrypolicy.Retries(rate, target, maxRetries)
+		policy.Rules = append(policy.Rules, retrypolicy.Rule{Package: t.Package, Test: t.Test, FlakeRate: rate, Retries: retries, Residual: residual})
+	}
+	return policy, broken
+}
+
+// printRecommendation writes the table of recommended retries, starring the
+// tests that stay above the target even at the cap
+// COUNT is the -count that reruns a failing test as often in one go test
+func printRecommendation(w io.Writer, policy *retrypolicy.Policy, broken []string) error {
+	if len(policy.Rules) == 0 {
+		fmt.Fprintln(w, "No flaky tests: no retries needed")
+	} else {
+		fmt.Fprintf(w, "Retries for at most a %.2g%% chance of a flake failing CI (capped at %d):\n\n", policy.Target*100, policy.MaxRetries)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TEST\tFLAKE RATE\tRETRIES\tCOUNT\tRESIDUAL")
+		most, capped := 0, 0
+		for _, r := range policy.Rules {
+			mark := ""
+			// Compare with a little slack so a residual of exactly the
+			// target is within it
+			if r.Residual > policy.Target*(1+1e-9) {
+				mark = " *"
+				capped++
+			}
+			fmt.Fprintf(tw, "%s\t%.1f%%\t%d\t%d\t%.2g%%%s\n", r.Test, r.FlakeRate*100, r.Retries, r.Retries+1, r.Residual*100, mark)
+			most = max(most, r.Retries)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if capped > 0 {
+			fmt.Fprintf(w, "\n* %d test(s) stay above the target even at the cap; quarantine them instead\n", capped)
+		}
+		fmt.Fprintf(w, "\nA CI wrapper that retries every failing test the same way needs %d retries\n", most)
+	}
+	if len(broken) > 0 {
+		fmt.Fprintf(w, "\nNever passed, so retries will not help: %s\n", strings.Join(broken, ", "))
+	}
+	return nil
+}
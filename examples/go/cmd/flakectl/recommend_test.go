@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	reportfmt "github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/retrypolicy"
+)
+
+func TestRecommendRetries(t *testing.T) {
+	report := gateReport(
+		reportfmt.JSONTest{Test: "TestRare", FlakeRate: 0.02, FlakeRateCI: [2]float64{0.005, 0.1}},
+		reportfmt.JSONTest{Test: "TestChronic", FlakeRate: 0.5, FlakeRateCI: [2]float64{0.3, 0.7}},
+		reportfmt.JSONTest{Test: "TestStable", Classification: "stable", FlakeRateCI: [2]float64{0, 0.1}},
+		reportfmt.JSONTest{Test: "TestBroken", Classification: "failing", FlakeRate: 1},
+		reportfmt.JSONTest{Test: "TestSkipped", Classification: "skipped"},
+	)
+	policy, broken := recommendRetries(report, 0.001, 5, false)
+	if len(policy.Rules) != 2 || policy.Rules[0].Test != "TestRare" || policy.Rules[0].Retries != 1 || policy.Rules[1].Retries != 5 {
+		t.Fatalf("Expected 1 retry for TestRare and 5 for TestChronic, got %+v", policy.Rules)
+	}
+	if len(broken) != 1 || broken[0] != "TestBroken" {
+		t.Errorf("Expected TestBroken to be beyond retries, got %v", broken)
+	}
+
+	// Planning for the upper bound of TestRare's interval takes another retry
+	policy, _ = recommendRetries(report, 0.001, 5, true)
+	if r := policy.Rules[0]; r.FlakeRate != 0.1 || r.Retries != 2 {
+		t.Errorf("Expected 2 retries at the upper bound of 10%%, got %+v", r)
+	}
+
+	var out bytes.Buffer
+	if err := printRecommendation(&out, policy, broken); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Retries for at most a 0.1% chance of a flake failing CI (capped at 5):\n\n",
+		"TEST         FLAKE RATE  RETRIES  COUNT  RESIDUAL\n",
+		"TestRare     10.0%       2        3      0.1%\n",
+		"TestChronic  70.0%       5        6      12% *\n",
+		"\n* 1 test(s) stay above the target even at the cap; quarantine them instead\n",
+		"\nA CI wrapper that retries every failing test the same way needs 5 retries\n",
+		"\nNever passed, so retries will not help: TestBroken\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunRecommendRetries(t *testing.T) {
+	dir := t.TempDir()
+	reportPath := filepath.Join(dir, "report.json")
+	data, err := json.Marshal(gateReport(reportfmt.JSONTest{Test: "TestRare", FlakeRate: 0.02, FlakeRateCI: [2]float64{0.005, 0.1}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(reportPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	policyPath := filepath.Join(dir, "retry-policy.json")
+
+	var out bytes.Buffer
+	if err := runRecommendRetries([]string{"--report", reportPath, "--out", policyPath, "--estimate", "rate"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Wrote 1 rule(s) to "+policyPath) {
+		t.Errorf("Expected the policy file to be reported:\n%s", out.String())
+	}
+	policy, err := retrypolicy.Load(policyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := policy.Find("TestRare/case"); !ok || r.Retries != 1 || policy.Target != 0.001 {
+		t.Errorf("Expected 1 retry for TestRare at a 0.1%% target, got %+v", policy)
+	}
+
+	if err := runRecommendRetries([]string{"--report", reportPath, "--estimate", "mean"}, &out); err == nil {
+		t.Error("Expected an unknown --estimate to be rejected")
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/fingerprint"
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/owners"
+	reportfmt "github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func runReport(args []string, stdout io.Writer) error {
+	if len(args) > 0 && args[0] == "html" {
+		return runReportHTML(args[1:], stdout)
+	}
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	sinceFlag := fs.String("since", "30d", "window to report on, as a duration such as 30d or 12h")
+	historyFile := fs.String("history", history.DefaultFile, "history database written by flakectl detect")
+	filterFlag := fs.String("fingerprint", "", "only report sessions whose environment matches these comma-separated key=pattern terms, such as cpus=1")
+	ownersFile := fs.String("owners", owners.DefaultFile, "owners file assigning tests to teams, for the per-owner digest")
+	owner := fs.String("owner", "", "only report the tests this team of --owners owns, such as @payments")
+	rule := history.DefaultDurationRule
+	fs.Float64Var(&rule.MinRatio, "duration-ratio", rule.MinRatio, "how many times its p95 or standard deviation before the window a test's must reach to be flagged as slower or more variable")
+	fs.IntVar(&rule.MinRuns, "duration-runs", rule.MinRuns, "fewest runs a test needs before and in the window to compare its durations")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errors.New("usage: flakectl report [html] [--since 30d] [--fingerprint key=pattern,...] [--owner team] [--duration-ratio 1.5] [--history file]")
+	}
+	window, err := parseSince(*sinceFlag)
+	if err != nil {
+		return err
+	}
+	ownerMap, err := loadOwners(*ownersFile, *owner)
+	if err != nil {
+		return err
+	}
+	sessions, err := loadSessions(*historyFile, *filterFlag)
+	if err != nil {
+		return err
+	}
+	sessions = ownedSessions(sessions, ownerMap, *owner)
+	since := time.Now().Add(-window)
+	if err := printTrends(stdout, sessions, since); err != nil {
+		return err
+	}
+	printTrendOwners(stdout, history.Trends(sessions, since), ownerMap)
+	if err := printDurationRegressions(stdout, history.DurationRegressions(sessions, since, rule), rule); err != nil {
+		return err
+	}
+	return printEnvironments(stdout, sessions, since)
+}
+
+// runReportHTML writes the trend report as a static HTML dashboard
+func runReportHTML(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("report html", flag.ContinueOnError)
+	sinceFlag := fs.String("since", "30d", "window to report on, as a duration such as 30d or 12h")
+	historyFile := fs.String("history", history.DefaultFile, "history database written by flakectl detect")
+	out := fs.String("out", "flaky-report.html", "file to write the dashboard to")
+	filterFlag := fs.String("fingerprint", "", "only report sessions whose environment matches these comma-separated key=pattern terms, such as cpus=1")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errors.New("usage: flakectl report html [--out flaky-report.html] [--since 30d] [--fingerprint key=pattern,...] [--history file]")
+	}
+	window, err := parseSince(*sinceFlag)
+	if err != nil {
+		return err
+	}
+	sessions, err := loadSessions(*historyFile, *filterFlag)
+	if err != nil {
+		return err
+	}
+
+	dashboard := reportfmt.NewDashboard(sessions, time.Now().Add(-window))
+	if err := writeFile(*out, func(w io.Writer) error { return reportfmt.WriteHTML(w, dashboard) }); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Wrote dashboard to %s\n", *out)
+	return nil
+}
+
+// loadSessions reads every session of the history database at path whose
+// environment matches the fingerprint.ParseFilter filter
+func loadSessions(path, filter string) ([]history.Session, error) {
+	f, err := fingerprint.ParseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("no history at %s; run flakectl detect first", path)
+	}
+	db, err := history.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	sessions, err := db.Sessions(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	return history.Matching(sessions, f), nil
+}
+
+// parseSince parses a time.ParseDuration string, additionally accepting a
+// whole number of days such as "30d"
+func parseSince(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid --since %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid --since %q", s)
+	}
+	return d, nil
+}
+
+// printTrends writes the per-test trend table for the sessions since the
+// given time, followed by the newly flaky and recovered tests
+func printTrends(w io.Writer, sessions []history.Session, since time.Time) error {
+	var inWindow int
+	for _, s := range sessions {
+		if !s.Time.Before(since) {
+			inWindow++
+		}
+	}
+	fmt.Fprintf(w, "%d sessions since %s (%d before)\n\n", inWindow, since.Format(time.DateOnly), len(sessions)-inWindow)
+	trends := history.Trends(sessions, since)
+	if len(trends) == 0 {
+		fmt.Fprintln(w, "No tests ran in this window")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TEST\tFLAKE RATE\tBEFORE\tTREND\tSTATUS\tRUNS")
+	for _, t := range trends {
+		before := "-"
+		if t.Before.Runs() > 0 {
+			before = fmt.Sprintf("%.1f%%", t.Before.FlakeRate()*100)
+		}
+		fmt.Fprintf(tw, "%s\t%.1f%%\t%s\t%s\t%s\t%d\n",
+			t.Test, t.Window.FlakeRate()*100, before, sparkline(t.Series), t.Status, t.Window.Runs())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, section := range []struct {
+		status history.Status
+		title  string
+	}{
+		{history.NewlyFlaky, "Newly flaky"},
+		{history.Recovered, "Recovered"},
+	} {
+		var lines []string
+		for _, t := range trends {
+			if t.Status == section.status {
+				lines = append(lines, fmt.Sprintf("  %s: %.1f%% before, %.1f%% over %d runs since",
+					t.Test, t.Before.FlakeRate()*100, t.Window.FlakeRate()*100, t.Window.Runs()))
+			}
+		}
+		if len(lines) > 0 {
+			fmt.Fprintf(w, "\n%s:\n%s\n", section.title, strings.Join(lines, "\n"))
+		}
+	}
+	return nil
+}
+
+// printDurationRegressions lists the tests whose durations got slower or
+// more variable in the window, which tend to be the next to flake on
+// timeouts
+func printDurationRegressions(w io.Writer, regressions []history.DurationRegression, rule history.DurationRule) error {
+	if len(regressions) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "\nSlower or more variable (p95 or standard deviation up %gx, p < %g):\n", rule.MinRatio, rule.Alpha)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  TEST\tP95\tSTDDEV\tMEDIAN\tRUNS\tREGRESSED")
+	for _, r := range regressions {
+		var regressed []string
+		if r.Slower {
+			regressed = append(regressed, fmt.Sprintf("slower (p=%.2g)", r.SlowerP))
+		}
+		if r.Variable {
+			regressed = append(regressed, fmt.Sprintf("more variable (p=%.2g)", r.VariableP))
+		}
+		fmt.Fprintf(tw, "  %s\t%s -> %s\t%s -> %s\t%s -> %s\t%d\t%s\n", r.Test,
+			roundDuration(r.Before.P95), roundDuration(r.Window.P95),
+			roundDuration(r.Before.StdDev), roundDuration(r.Window.StdDev),
+			roundDuration(r.Before.Median), roundDuration(r.Window.Median),
+			r.Window.Runs, strings.Join(regressed, ", "))
+	}
+	return tw.Flush()
+}
+
+// roundDuration rounds d to a precision that reads well in a table
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(100 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
+
+// printEnvironments breaks the sessions since the given time down by
+// environment, when they ran in more than one, so flakes that only happen
+// in some of them stand out
+func printEnvironments(w io.Writer, sessions []history.Session, since time.Time) error {
+	byID := make(map[string][]history.Session)
+	var order []string
+	for _, s := range sessions {
+		if s.Time.Before(since) {
+			continue
+		}
+		id := s.Environment().ID()
+		if byID[id] == nil {
+			order = append(order, id)
+		}
+		byID[id] = append(byID[id], s)
+	}
+	if len(order) < 2 {
+		return nil
+	}
+
+	fmt.Fprintf(w, "\nEnvironments (filter with --fingerprint):\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  FINGERPRINT\tSESSIONS\tFAILED RUNS\tFLAKY TESTS\tENVIRONMENT")
+	for _, id := range order {
+		group := byID[id]
+		report := history.Report(group)
+		var failed, executed, flaky int
+		for _, s := range report.Tests {
+			failed += s.Failed
+			executed += s.Passed + s.Failed
+			if s.Classify() == runner.Flaky {
+				flaky++
+			}
+		}
+		rate := 0.0
+		if executed > 0 {
+			rate = float64(failed) / float64(executed)
+		}
+		fmt.Fprintf(tw, "  %s\t%d\t%d of %d (%.1f%%)\t%d\t%s\n", id, len(group), failed, executed, rate*100, flaky, group[0].Environment())
+	}
+	return tw.Flush()
+}
+
+// sparkline renders flake rates in [0, 1] as block characters
+func sparkline(rates []float64) string {
+	const blocks = "▁▂▃▄▅▆▇█"
+	levels := []rune(blocks)
+	var sb strings.Builder
+	for _, r := range rates {
+		sb.WriteRune(levels[int(r*float64(len(levels)-1)+0.5)])
+	}
+	return sb.String()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/fingerprint"
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestParseSince(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"0d":  0,
+	} {
+		got, err := parseSince(in)
+		if err != nil || got != want {
+			t.Errorf("parseSince(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"d", "-1d", "soon", "-5h"} {
+		if _, err := parseSince(in); err == nil {
+			t.Errorf("Expected parseSince(%q) to fail", in)
+		}
+	}
+}
+
+func TestPrintTrends(t *testing.T) {
+	since := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	rec := func(test string, outcome runner.Outcome) history.Record {
+		return history.Record{Package: "p", Test: test, Outcome: outcome}
+	}
+	sessions := []history.Session{
+		{Time: since.Add(-24 * time.Hour), Results: []history.Record{
+			rec("TestA", runner.Pass), rec("TestB", runner.Fail), rec("TestB", runner.Pass),
+		}},
+		{Time: since.Add(24 * time.Hour), Results: []history.Record{
+			rec("TestA", runner.Fail), rec("TestA", runner.Pass), rec("TestB", runner.Pass),
+		}},
+	}
+	var out bytes.Buffer
+	if err := printTrends(&out, sessions, since); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"1 sessions since 2024-01-15 (1 before)",
+		"TestA  50.0%       0.0%",
+		"newly flaky",
+		"Newly flaky:\n  TestA: 0.0% before, 50.0% over 2 runs since",
+		"Recovered:\n  TestB: 50.0% before, 0.0% over 1 runs since",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestPrintDurationRegressions(t *testing.T) {
+	regressions := []history.DurationRegression{{
+		Test:    "TestSlow",
+		Before:  history.Durations{Runs: 20, Median: 50 * time.Millisecond, P95: 52340 * time.Microsecond, StdDev: 2 * time.Millisecond},
+		Window:  history.Durations{Runs: 12, Median: 90 * time.Millisecond, P95: 1234567 * time.Microsecond, StdDev: 300 * time.Millisecond},
+		Slower:  true,
+		SlowerP: 0.000123,
+	}}
+	var out bytes.Buffer
+	if err := printDurationRegressions(&out, regressions, history.DefaultDurationRule); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Slower or more variable (p95 or standard deviation up 1.5x, p < 0.01):",
+		"TestSlow  52.3ms -> 1.23s",
+		"slower (p=0.00012)",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "more variable (p=") {
+		t.Errorf("Expected only the slower verdict:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := printDurationRegressions(&out, nil, history.DefaultDurationRule); err != nil || out.Len() != 0 {
+		t.Errorf("Expected nothing without regressions, got %q, %v", out.String(), err)
+	}
+}
+
+func TestRunReportFingerprint(t *testing.T) {
+	historyFile := filepath.Join(t.TempDir(), "history.db")
+	for _, cpus := range []int{1, 8} {
+		outcome := runner.Pass
+		if cpus == 1 {
+			outcome = runner.Fail
+		}
+		report := runner.Aggregate(2, []runner.Result{
+			{Package: "p", Test: "TestA", Run: 0, Outcome: runner.Pass},
+			{Package: "p", Test: "TestA", Run: 1, Outcome: outcome, Output: "a_test.go:3: boom\n"},
+		})
+		report.Fingerprint = fingerprint.Fingerprint{GOOS: "linux", GOARCH: "amd64", CPUs: cpus}
+		if err := recordHistory(historyFile, report, "abc"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	small := fingerprint.Fingerprint{GOOS: "linux", GOARCH: "amd64", CPUs: 1}
+
+	var out bytes.Buffer
+	if err := runReport([]string{"--history", historyFile}, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"2 sessions since",
+		"Environments (filter with --fingerprint):",
+		small.ID() + "     1         1 of 2 (50.0%)  1            linux/amd64, 1 CPU",
+		"0 of 2 (0.0%)",
+		"linux/amd64, 8 CPUs",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := runReport([]string{"--history", historyFile, "--fingerprint", "cpus=8"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "1 sessions since") || strings.Contains(out.String(), "Environments") {
+		t.Errorf("Expected only the 8-CPU session without a breakdown:\n%s", out.String())
+	}
+	if err := runReport([]string{"--history", historyFile, "--fingerprint", "cores=1"}, &out); err == nil {
+		t.Error("Expected an unknown fingerprint key to be rejected")
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := sparkline([]float64{0, 0.5, 1}); got != "▁▅█" {
+		t.Errorf("Unexpected sparkline %q", got)
+	}
+}
+
+func TestRunReportHTML(t *testing.T) {
+	dir := t.TempDir()
+	historyFile := filepath.Join(dir, "history.db")
+	report := runner.Aggregate(1, []runner.Result{{Package: "p", Test: "TestA", Outcome: runner.Fail, Output: "a_test.go:3: boom\n"}})
+	if err := recordHistory(historyFile, report, "abc"); err != nil {
+		t.Fatal(err)
+	}
+	page := filepath.Join(dir, "dashboard.html")
+	var out bytes.Buffer
+	if err := runReport([]string{"html", "--history", historyFile, "--out", page}, &out); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "a_test.go:N: boom") {
+		t.Errorf("Expected the dashboard to cluster the failure message:\n%s", data)
+	}
+	if err := runReport([]string{"html", "--history", filepath.Join(dir, "missing.db")}, &out); err == nil {
+		t.Error("Expected a missing history to be rejected")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func runReproduce(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("reproduce", flag.ContinueOnError)
+	seed := fs.Int64("seed", 0, "seed of the failing run (default: latest recorded failure)")
+	failures := fs.String("failures", "", "failures artifact to restore seed and environment from (default: <dir>/"+flaky.DefaultFailuresFile+")")
+	dir := fs.String("dir", "", "package directory to run the test in (default: recorded directory)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: flakectl reproduce <TestName> [--seed N]")
+	}
+	test := positional[0]
+	seedSet := false
+	fs.Visit(func(f *flag.Flag) { seedSet = seedSet || f.Name == "seed" })
+
+	path := *failures
+	if path == "" {
+		path = filepath.Join(*dir, flaky.DefaultFailuresFile)
+	}
+	records, err := flaky.LoadFailures(path)
+	if err != nil {
+		return err
+	}
+	record, found := findFailure(records, test, *seed, seedSet)
+	if !found && !seedSet {
+		return fmt.Errorf("no recorded failure of %s in %s; pass --seed", test, path)
+	}
+	if !found {
+		record = flaky.FailureRecord{Test: test, Seed: *seed}
+	}
+	if *dir != "" {
+		record.Dir = *dir
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Fprintf(stdout, "Reproducing %s with %s=%d%s\n", test, runner.SeedEnv, record.Seed, describeEnv(record.Env))
+	results, err := runner.RunOnce(ctx, runner.Config{
+		Run:  runner.TestPattern(test),
+		Seed: record.Seed,
+		Dir:  record.Dir,
+		Env:  envList(record.Env),
+	}, 0)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Test != test {
+			continue
+		}
+		fmt.Fprint(stdout, r.Output)
+		if r.Outcome == runner.Fail {
+			fmt.Fprintf(stdout, "Reproduced failure of %s with seed %d\n", test, record.Seed)
+			return nil
+		}
+		return fmt.Errorf("%s did not fail with seed %d (outcome: %s)", test, record.Seed, r.Outcome)
+	}
+	return fmt.Errorf("%s did not run; check the test name and --dir", test)
+}
+
+// findFailure returns the matching record, preferring the most recent one
+func findFailure(records []flaky.FailureRecord, test string, seed int64, matchSeed bool) (flaky.FailureRecord, bool) {
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		if r.Test == test && (!matchSeed || r.Seed == seed) {
+			return r, true
+		}
+	}
+	return flaky.FailureRecord{}, false
+}
+
+func envList(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	list := make([]string, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, k+"="+env[k])
+	}
+	return list
+}
+
+func describeEnv(env map[string]string) string {
+	if len(env) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (restored env: %v)", envList(env))
+}
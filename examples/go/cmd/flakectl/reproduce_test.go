@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	flaky "github.com/example/flaky-test-example"
+)
+
+func TestFindFailurePrefersLatest(t *testing.T) {
+	records := []flaky.FailureRecord{
+		{Test: "TestA", Seed: 1},
+		{Test: "TestB", Seed: 2},
+		{Test: "TestA", Seed: 3},
+	}
+
+	if r, ok := findFailure(records, "TestA", 0, false); !ok || r.Seed != 3 {
+		t.Errorf("Expected latest TestA record with seed 3, got %+v, %v", r, ok)
+	}
+	if r, ok := findFailure(records, "TestA", 1, true); !ok || r.Seed != 1 {
+		t.Errorf("Expected TestA record with seed 1, got %+v, %v", r, ok)
+	}
+	if _, ok := findFailure(records, "TestA", 2, true); ok {
+		t.Error("Expected no TestA record with seed 2")
+	}
+}
+
+func TestEnvListIsSorted(t *testing.T) {
+	got := envList(map[string]string{"TZ": "UTC", "FLAKY_STRICT_MARGIN": "0.05"})
+	if len(got) != 2 || got[0] != "FLAKY_STRICT_MARGIN=0.05" || got[1] != "TZ=UTC" {
+		t.Errorf("Unexpected env list: %v", got)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	flaky "github.com/example/flaky-test-example"
+)
+
+// runScenarios lists the failure scenarios with their categories,
+// parameters and rates, as the tests would load them
+func runScenarios(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("scenarios", flag.ContinueOnError)
+	config := fs.String("config", "", "scenario file to list (default: "+flaky.ConfigEnv+", or "+flaky.DefaultConfigFile+" if present, under "+flaky.ProfileEnv+", "+flaky.ScenariosEnv+" and "+flaky.SetEnv+")")
+	defaults := fs.Bool("defaults", false, "list the built-in scenarios at their default rates, ignoring any config")
+	category := fs.String("category", "", "only list scenarios of this category, such as timing or network")
+	jsonPath := fs.String("json", "", "also write the list as JSON to this file")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errors.New("usage: flakectl scenarios [--config file | --defaults] [--category name] [--json file]")
+	}
+	if *defaults && *config != "" {
+		return errors.New("--defaults and --config are mutually exclusive")
+	}
+
+	var infos []flaky.ScenarioInfo
+	switch {
+	case *defaults:
+		infos = flaky.ListScenarios()
+	case *config != "":
+		r, err := flaky.LoadScenarios(*config)
+		if err != nil {
+			return err
+		}
+		infos = r.List()
+	default:
+		r, err := flaky.ScenariosFromEnv()
+		if err != nil {
+			return err
+		}
+		infos = r.List()
+	}
+	if *category != "" {
+		infos = inCategory(infos, *category)
+		if len(infos) == 0 {
+			return fmt.Errorf("no scenarios of category %s", *category)
+		}
+	}
+
+	if *jsonPath != "" {
+		if err := writeFile(*jsonPath, func(w io.Writer) error { return writeScenarios(w, infos) }); err != nil {
+			return err
+		}
+	}
+	return printScenarios(stdout, infos)
+}
+
+// inCategory returns the scenarios of infos in category, ignoring case
+func inCategory(infos []flaky.ScenarioInfo, category string) []flaky.ScenarioInfo {
+	var matching []flaky.ScenarioInfo
+	for _, info := range infos {
+		if strings.EqualFold(info.Category, category) {
+			matching = append(matching, info)
+		}
+	}
+	return matching
+}
+
+// writeScenarios writes infos as indented JSON
+func writeScenarios(w io.Writer, infos []flaky.ScenarioInfo) error {
+	data, err := json.MarshalIndent(infos, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// printScenarios writes a table of infos, marking the disabled scenarios
+func printScenarios(w io.Writer, infos []flaky.ScenarioInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCENARIO\tCATEGORY\tFAILURE RATE\tEFFECTIVE\tPARAMETERS")
+	for _, info := range infos {
+		category, params := info.Category, flaky.FormatParameters(info.Parameters)
+		if category == "" {
+			category = "-"
+		}
+		if params == "" {
+			params = "-"
+		}
+		effective := fmt.Sprintf("%.1f%%", info.EffectiveRate*100)
+		if info.Disabled {
+			effective = "disabled"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.1f%%\t%s\t%s\n", info.Name, category, info.FailureRate*100, effective, params)
+	}
+	return tw.Flush()
+}
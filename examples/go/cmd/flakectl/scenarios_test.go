@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	flaky "github.com/example/flaky-test-example"
+)
+
+func TestRunScenarios(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "flaky.yaml")
+	if err := os.WriteFile(config, []byte("profile: ci-light\nscenarios:\n  - name: NetworkSimulation\n    class: network\n    failure_rate: 0.05\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	jsonPath := filepath.Join(dir, "scenarios.json")
+	var out bytes.Buffer
+	if err := runScenarios([]string{"--config", config, "--category", "Network", "--json", jsonPath}, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"SCENARIO",
+		"NetworkSimulation  network   5.0%",
+		"RateLimit          network   2.5%",
+		"SharedDatabase     network   0.5%          0.5%       incident.chance=0.1 incident.failure_rate=0.225",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "RandomFailure") {
+		t.Errorf("Expected only network scenarios:\n%s", out.String())
+	}
+
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var infos []flaky.ScenarioInfo
+	if err := json.Unmarshal(data, &infos); err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) == 0 || infos[0].Category != "network" {
+		t.Errorf("Expected the network scenarios as JSON, got %+v", infos)
+	}
+}
+
+func TestRunScenariosErrors(t *testing.T) {
+	var out bytes.Buffer
+	if err := runScenarios([]string{"--category", "nonsense", "--defaults"}, &out); err == nil || !strings.Contains(err.Error(), "no scenarios of category nonsense") {
+		t.Errorf("Expected an unknown category to fail, got %v", err)
+	}
+	if err := runScenarios([]string{"--defaults", "--config", "flaky.yaml"}, &out); err == nil {
+		t.Error("Expected --defaults with --config to fail")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/server"
+	"github.com/example/flaky-test-example/internal/sweep"
+)
+
+func runServe(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "address to serve the job API on; the API has no authentication, so only listen beyond localhost on a trusted network")
+	root := fs.String("root", ".", "directory job repos are resolved in; jobs cannot reach outside it")
+	jobs := fs.Int("jobs", 1, "number of jobs swept at once")
+	queue := fs.Int("queue", 100, "most jobs waiting to run; submissions beyond it get 503")
+	keep := fs.Int("keep", server.DefaultKeep, "most finished jobs kept in memory; the oldest are forgotten first")
+	maxRuns := fs.Int("max-runs", 10000, "most runs one job may ask for (0 for no limit)")
+	workers := fs.Int("workers", 4, "number of shards of a job run concurrently")
+	shardSize := fs.Int("shard-size", sweep.DefaultShardSize, "maximum seeds per shard")
+	retries := fs.Int("retries", 1, "times a failed shard is retried before the job fails")
+	endpoint := fs.String("endpoint", "", "serverless endpoint ID to run shards on instead of local go test processes")
+	apiKey := fs.String("api-key", os.Getenv("RUNPOD_API_KEY"), "API key for --endpoint (default $RUNPOD_API_KEY)")
+	confidence := fs.Float64("confidence", 0.95, "confidence level of the reported flake-rate intervals")
+	historyFile := fs.String("history", "", "history database to record JUnit XML and test2json results pushed to POST /results in (off when empty)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return errors.New("usage: flakectl serve [--addr host:port] [--root dir] [--jobs N]")
+	}
+
+	var exec sweep.Executor = sweep.Local{}
+	if *endpoint != "" {
+		if *apiKey == "" {
+			return errors.New("--endpoint needs --api-key or RUNPOD_API_KEY")
+		}
+		exec = &sweep.Endpoint{ID: *endpoint, APIKey: *apiKey}
+	}
+	s := server.New(server.Config{
+		Root:       *root,
+		Exec:       exec,
+		Jobs:       *jobs,
+		Workers:    *workers,
+		ShardSize:  *shardSize,
+		Retries:    *retries,
+		Confidence: *confidence,
+		MaxRuns:    *maxRuns,
+		Queue:      *queue,
+		Keep:       *keep,
+		History:    *historyFile,
+	})
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fmt.Errorf("serve: %w", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Fprintf(stdout, "Serving the job API on http://%s/jobs\n", ln.Addr())
+	if *historyFile != "" {
+		fmt.Fprintf(stdout, "Recording results pushed to http://%s/results in %s\n", ln.Addr(), *historyFile)
+	}
+	return serveJobs(ctx, ln, s)
+}
+
+// serveJobs serves s on ln and sweeps its jobs until ctx is cancelled, then
+// gives in-flight requests a few seconds to finish
+func serveJobs(ctx context.Context, ln net.Listener, s *server.Server) error {
+	srv := &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	<-done
+	return err
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	reportfmt "github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/server"
+	"github.com/example/flaky-test-example/internal/sweep"
+)
+
+// passingExecutor reports every seed of a shard as a pass of TestA
+type passingExecutor struct{}
+
+func (passingExecutor) Run(_ context.Context, _ sweep.Config, s sweep.Shard) (*reportfmt.JSONReport, error) {
+	test := reportfmt.JSONTest{Package: "p", Test: "TestA", Runs: s.Runs(), Passed: s.Runs()}
+	return &reportfmt.JSONReport{Runs: s.Runs(), Tests: []reportfmt.JSONTest{test}}, nil
+}
+
+func TestServeJobs(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- serveJobs(ctx, ln, server.New(server.Config{Exec: passingExecutor{}})) }()
+
+	url := "http://" + ln.Addr().String() + "/jobs"
+	resp, err := http.Post(url, "application/json", strings.NewReader(`{"runs": 20}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var job server.Job
+	err = json.NewDecoder(resp.Body).Decode(&job)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); job.Status != server.Done; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Job still %s after 5s", job.Status)
+		}
+		resp, err := http.Get(url + "/" + job.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&job)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if job.Report == nil || job.Report.Runs != 20 {
+		t.Errorf("Expected a report of 20 runs, got %+v", job.Report)
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("Expected a clean shutdown, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/example/flaky-test-example/internal/attach"
+	reportfmt "github.com/example/flaky-test-example/internal/report"
+)
+
+func runShow(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	seed := fs.Int64("seed", 0, "seed of the failing run to show (default: list the stored runs of the test)")
+	dir := fs.String("attachments", attach.DefaultDir, "directory detect stored the output of failing runs in")
+	pkg := fs.String("package", "", "only consider the test in this package")
+	output := fs.String("output", "", "only show this output: "+attach.TestOutput+", "+attach.Stdout+" or "+attach.Stderr)
+	reportPath := fs.String("report", "", "find the run's outputs in this JSON report instead of the store's index")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: flakectl show <TestName> [--seed N] [--attachments dir]")
+	}
+	switch *output {
+	case "", attach.TestOutput, attach.Stdout, attach.Stderr:
+	default:
+		return fmt.Errorf("unknown --output %q (want %s, %s or %s)", *output, attach.TestOutput, attach.Stdout, attach.Stderr)
+	}
+	test := positional[0]
+	seedSet := false
+	fs.Visit(func(f *flag.Flag) { seedSet = seedSet || f.Name == "seed" })
+
+	store := attach.New(*dir)
+	var entries []attach.Entry
+	if *reportPath != "" {
+		r, err := reportfmt.LoadJSON(*reportPath)
+		if err != nil {
+			return err
+		}
+		entries = reportAttachments(r, *pkg, test)
+	} else {
+		all, err := store.Entries()
+		if err != nil {
+			return err
+		}
+		// The latest sweep's outputs of a seed come first, as Find returns
+		// them
+		for i := len(all) - 1; i >= 0; i-- {
+			if e := all[i]; e.Test == test && (*pkg == "" || e.Package == *pkg) {
+				entries = append(entries, e)
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no stored output of %s; run flakectl detect with --attachments %s first", test, *dir)
+	}
+	if !seedSet {
+		printStoredRuns(stdout, test, entries)
+		return nil
+	}
+	for _, e := range entries {
+		if e.Seed == *seed {
+			return printAttachment(stdout, store, e, *output)
+		}
+	}
+	return fmt.Errorf("no stored output of %s with seed %d; run flakectl show %s to list the stored seeds", test, *seed, test)
+}
+
+// reportAttachments returns the attachments a JSON report lists for test
+func reportAttachments(r *reportfmt.JSONReport, pkg, test string) []attach.Entry {
+	var entries []attach.Entry
+	for _, t := range r.Tests {
+		if t.Test != test || (pkg != "" && t.Package != pkg) {
+			continue
+		}
+		for _, a := range t.Attachments {
+			entries = append(entries, attach.Entry{Package: t.Package, Test: t.Test, Attachment: a})
+		}
+	}
+	return entries
+}
+
+// printStoredRuns lists the failing runs of a test with stored outputs,
+// once per seed, in seed order
+func printStoredRuns(w io.Writer, test string, entries []attach.Entry) {
+	seen := make(map[int64]bool)
+	var unique []attach.Entry
+	for _, e := range entries {
+		if !seen[e.Seed] {
+			seen[e.Seed] = true
+			unique = append(unique, e)
+		}
+	}
+	sort.Slice(unique, func(i, j int) bool { return unique[i].Seed < unique[j].Seed })
+	fmt.Fprintf(w, "%s has stored output for %d failing seed(s):\n", test, len(unique))
+	for _, e := range unique {
+		fmt.Fprintf(w, "  seed %d (run %d", e.Seed, e.Run)
+		if e.Kind != "" {
+			fmt.Fprintf(w, ", %s", e.Kind)
+		}
+		fmt.Fprint(w, "):")
+		for _, ref := range e.Outputs {
+			fmt.Fprintf(w, " %s %d B", ref.Name, ref.Size)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// printAttachment writes the outputs of one failing run, or only the one
+// named output unless it is empty
+func printAttachment(w io.Writer, store *attach.Store, e attach.Entry, output string) error {
+	shown := false
+	for _, ref := range e.Outputs {
+		if output != "" && ref.Name != output {
+			continue
+		}
+		data, err := store.Get(ref.Digest)
+		if err != nil {
+			return err
+		}
+		if output == "" {
+			fmt.Fprintf(w, "=== %s of %s, seed %d (%d bytes, sha256 %s)\n", ref.Name, e.Test, e.Seed, ref.Size, ref.Digest[:12])
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if output == "" && len(data) > 0 && data[len(data)-1] != '\n' {
+			fmt.Fprintln(w)
+		}
+		shown = true
+	}
+	if !shown {
+		return fmt.Errorf("no %s stored for %s with seed %d", output, e.Test, e.Seed)
+	}
+	return nil
+}
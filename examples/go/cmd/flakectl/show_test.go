@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/attach"
+	reportfmt "github.com/example/flaky-test-example/internal/report"
+)
+
+// storedRuns stores two failing runs of TestA, seeds 5 and 9, that share
+// one process output
+func storedRuns(t *testing.T) (string, []attach.Attachment) {
+	t.Helper()
+	dir := t.TempDir()
+	store := attach.New(dir)
+	stdout, _ := store.Put(attach.Stdout, []byte("=== RUN   TestA\n--- FAIL: TestA (0.00s)\nFAIL\n"))
+	var runs []attach.Attachment
+	for i, seed := range []int64{9, 5} {
+		own, _ := store.Put(attach.TestOutput, []byte("    a_test.go:7: boom at seed "+strconv.FormatInt(seed, 10)))
+		a := attach.Attachment{Run: i, Seed: seed, Kind: "assertion", Outputs: []attach.Ref{own, stdout}}
+		if err := store.Record(attach.Entry{Package: "p", Test: "TestA", Attachment: a}); err != nil {
+			t.Fatal(err)
+		}
+		runs = append(runs, a)
+	}
+	return dir, runs
+}
+
+func TestRunShow(t *testing.T) {
+	dir, _ := storedRuns(t)
+	var out bytes.Buffer
+	if err := runShow([]string{"TestA", "--attachments", dir}, &out); err != nil {
+		t.Fatal(err)
+	}
+	list := out.String()
+	if !strings.Contains(list, "stored output for 2 failing seed(s)") || strings.Index(list, "seed 5") > strings.Index(list, "seed 9") {
+		t.Errorf("Expected both seeds listed in order:\n%s", list)
+	}
+
+	out.Reset()
+	if err := runShow([]string{"TestA", "--attachments", dir, "--seed", "5"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"=== output of TestA, seed 5", "boom at seed 5\n", "=== stdout of TestA, seed 5", "--- FAIL: TestA"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := runShow([]string{"TestA", "--attachments", dir, "--seed", "9", "--output", "output"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "    a_test.go:7: boom at seed 9" {
+		t.Errorf("Expected only the test's own output, got %q", out.String())
+	}
+
+	for _, args := range [][]string{
+		{"TestB", "--attachments", dir},
+		{"TestA", "--attachments", dir, "--seed", "6"},
+		{"TestA", "--attachments", dir, "--seed", "5", "--output", "stderr"},
+		{"TestA", "--attachments", dir, "--output", "logs"},
+		{"--attachments", dir},
+	} {
+		if err := runShow(args, &bytes.Buffer{}); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
+
+func TestRunShowFromReport(t *testing.T) {
+	dir, runs := storedRuns(t)
+	os.Remove(filepath.Join(dir, "index.jsonl"))
+	data, _ := json.Marshal(reportfmt.JSONReport{Runs: 2, Tests: []reportfmt.JSONTest{{Package: "p", Test: "TestA", Attachments: runs}}})
+	path := filepath.Join(t.TempDir(), "report.json")
+	os.WriteFile(path, data, 0o644)
+
+	var out bytes.Buffer
+	if err := runShow([]string{"TestA", "--attachments", dir, "--report", path, "--seed", "9"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "boom at seed 9") {
+		t.Errorf("Expected the run the report points at:\n%s", out.String())
+	}
+}
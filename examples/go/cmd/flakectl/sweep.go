@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	flaky "github.com/example/flaky-test-example"
+	reportfmt "github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/sweep"
+)
+
+// maxListedSeeds and maxListedFailures cap what printSweep lists per
+// failure and per test; --json has everything
+const (
+	maxListedSeeds    = 10
+	maxListedFailures = 5
+)
+
+func runSweep(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	seeds := fs.String("seeds", "0-999", "inclusive seed range to sweep, as start-end")
+	workers := fs.Int("workers", 4, "number of shards run concurrently")
+	shardSize := fs.Int("shard-size", sweep.DefaultShardSize, "maximum seeds per shard")
+	retries := fs.Int("retries", 1, "times a failed shard is retried before the sweep fails")
+	runRegex := fs.String("run", "", "only run tests matching this regex")
+	dir := fs.String("dir", "", "directory to run go test in (on the worker, for --endpoint)")
+	endpoint := fs.String("endpoint", "", "serverless endpoint ID to run shards on instead of local go test processes")
+	apiKey := fs.String("api-key", os.Getenv("RUNPOD_API_KEY"), "API key for --endpoint (default $RUNPOD_API_KEY)")
+	jsonPath := fs.String("json", "", "also write the merged JSON flake report to this file")
+	confidence := fs.Float64("confidence", 0.95, "confidence level of the reported flake-rate intervals")
+	set := fs.String("set", "", "scenario overrides every run applies, as target.field=value[,...]; sets $FLAKY_SET")
+	var params paramFlag
+	fs.Var(&params, "param", "sweep the seeds at every value of a scenario field, as target.field=start:stop:step or target.field=a,b,c; repeat for a grid")
+	csvPath := fs.String("csv", "", "with --param, also write the pass rate of every test at every point to this CSV file")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return errors.New("usage: flakectl sweep [package] [--seeds start-end] [--workers N]")
+	}
+	start, end, err := parseSeedRange(*seeds)
+	if err != nil {
+		return err
+	}
+	if *csvPath != "" && len(params) == 0 {
+		return errors.New("--csv needs --param")
+	}
+	if err := checkParams(*set, params); err != nil {
+		return err
+	}
+
+	var exec sweep.Executor = sweep.Local{}
+	where := "local workers"
+	if *endpoint != "" {
+		if *apiKey == "" {
+			return errors.New("--endpoint needs --api-key or RUNPOD_API_KEY")
+		}
+		exec = &sweep.Endpoint{ID: *endpoint, APIKey: *apiKey}
+		where = "endpoint " + *endpoint
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg := sweep.Config{
+		Dir:        *dir,
+		Run:        *runRegex,
+		SeedStart:  start,
+		SeedEnd:    end,
+		Workers:    *workers,
+		ShardSize:  *shardSize,
+		Retries:    *retries,
+		Confidence: *confidence,
+		Set:        *set,
+		OnShard: func(s sweep.Shard, err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "shard %d (seeds %d-%d) failed: %v\n", s.Index, s.SeedStart, s.SeedEnd, err)
+			}
+		},
+	}
+	if len(positional) == 1 {
+		cfg.Package = positional[0]
+	}
+	if len(params) > 0 {
+		fmt.Fprintf(stdout, "Sweeping seeds %d-%d at %d point(s) of %s on %d %s\n", start, end, len(sweep.Grid(params)), paramNames(params), *workers, where)
+		points, err := sweep.RunParams(ctx, cfg, exec, params, func(p sweep.Point) {
+			fmt.Fprintf(os.Stderr, "%s: %d tests, %d flaky, %d failing\n", p.Label(), p.Report.Summary.Tests, p.Report.Summary.Flaky, p.Report.Summary.Failing)
+		})
+		if err != nil {
+			return err
+		}
+		if *jsonPath != "" {
+			if err := writeFile(*jsonPath, func(w io.Writer) error { return writeParamSweep(w, params, points) }); err != nil {
+				return err
+			}
+		}
+		if *csvPath != "" {
+			if err := writeFile(*csvPath, func(w io.Writer) error { return writeParamCSV(w, params, points) }); err != nil {
+				return err
+			}
+		}
+		return printParamSweep(stdout, params, points)
+	}
+	fmt.Fprintf(stdout, "Sweeping seeds %d-%d on %d %s\n", start, end, *workers, where)
+	r, err := sweep.Run(ctx, cfg, exec)
+	if err != nil {
+		return err
+	}
+	if *jsonPath != "" {
+		if err := writeFile(*jsonPath, func(w io.Writer) error { return writeJSONReport(w, r) }); err != nil {
+			return err
+		}
+	}
+	printSweep(stdout, r)
+	return nil
+}
+
+// parseSeedRange parses an inclusive "start-end" range; a single seed is a
+// range of one
+func parseSeedRange(s string) (start, end int64, err error) {
+	lo, hi, ok := strings.Cut(s, "-")
+	if !ok {
+		hi = lo
+	}
+	if start, err = strconv.ParseInt(lo, 10, 64); err == nil {
+		end, err = strconv.ParseInt(hi, 10, 64)
+	}
+	if err != nil || end < start {
+		return 0, 0, fmt.Errorf("invalid seed range %q, want start-end", s)
+	}
+	return start, end, nil
+}
+
+func writeJSONReport(w io.Writer, r *reportfmt.JSONReport) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// printSweep writes the merged summary and, for each failing test, the seeds
+// that trigger each distinct failure
+func printSweep(w io.Writer, r *reportfmt.JSONReport) {
+	s := r.Summary
+	fmt.Fprintf(w, "%d seeds, %d tests: %d stable, %d flaky, %d failing\n", r.Runs, s.Tests, s.Stable, s.Flaky, s.Failing)
+	for _, t := range r.Tests {
+		if t.Failed == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s (%s, %d/%d runs failed)\n", t.Test, t.Classification, t.Failed, t.Runs)
+		failures := t.Failures
+		if len(failures) == 0 {
+			failures = []reportfmt.JSONFailure{{Message: "(no message)", Seeds: t.FailingSeeds}}
+		}
+		for i, f := range failures {
+			if i == maxListedFailures {
+				fmt.Fprintf(w, "  +%d more distinct failures\n", len(failures)-i)
+				break
+			}
+			fmt.Fprintf(w, "  %s\n    seeds: %s\n", f.Message, formatSeeds(f.Seeds))
+		}
+	}
+}
+
+// formatSeeds lists up to maxListedSeeds seeds and counts the rest
+func formatSeeds(seeds []int64) string {
+	parts := make([]string, 0, maxListedSeeds+1)
+	for i, seed := range seeds {
+		if i == maxListedSeeds {
+			parts = append(parts, fmt.Sprintf("+%d more", len(seeds)-i))
+			break
+		}
+		parts = append(parts, strconv.FormatInt(seed, 10))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// paramFlag collects the --param flags of a parameter sweep
+type paramFlag []sweep.Param
+
+func (f *paramFlag) String() string {
+	return paramNames(*f)
+}
+
+func (f *paramFlag) Set(s string) error {
+	p, err := sweep.ParseParam(s)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, p)
+	return nil
+}
+
+// checkParams applies the overrides of every point to the built-in
+// scenarios, so a value out of a field's range fails before the sweep
+// rather than every test of its point
+// Targets the built-in scenarios lack may be scenarios of the package's own
+// config and are left to the tests
+func checkParams(set string, params []sweep.Param) error {
+	var overrides []flaky.Override
+	if set != "" {
+		var err error
+		if overrides, err = flaky.ParseOverrides(set); err != nil {
+			return fmt.Errorf("--set: %w", err)
+		}
+	}
+	for _, point := range sweep.Grid(params) {
+		overrides = append(overrides, point...)
+	}
+	for _, o := range overrides {
+		if err := flaky.DefaultScenarios().Set(o); err != nil && !errors.Is(err, flaky.ErrNoTarget) {
+			return err
+		}
+	}
+	return nil
+}
+
+func paramNames(params []sweep.Param) string {
+	names := make([]string, len(params))
+	for i, p := range params {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, ", ")
+}
+
+// pointValues returns the point's values, in the order of the params
+func pointValues(p sweep.Point) []string {
+	values := make([]string, len(p.Overrides))
+	for i, o := range p.Overrides {
+		values[i] = o.Value
+	}
+	return values
+}
+
+// printParamSweep writes the pass rate of every test that failed at any
+// point, a row per test and a column per point, so each row is the test's
+// response to the parameters
+func printParamSweep(w io.Writer, params []sweep.Param, points []sweep.Point) error {
+	type row struct {
+		pkg, test string
+		rates     []string
+	}
+	var rows []*row
+	byTest := make(map[[2]string]*row)
+	for i, p := range points {
+		for _, t := range p.Report.Tests {
+			if t.Parent != "" {
+				continue
+			}
+			k := [2]string{t.Package, t.Test}
+			r := byTest[k]
+			if r == nil {
+				r = &row{pkg: t.Package, test: t.Test, rates: make([]string, len(points))}
+				for j := range r.rates {
+					r.rates[j] = "-"
+				}
+				byTest[k] = r
+				rows = append(rows, r)
+			}
+			r.rates[i] = fmt.Sprintf("%.1f%%", t.PassRate*100)
+		}
+	}
+	failing := rows[:0]
+	for _, r := range rows {
+		for _, rate := range r.rates {
+			if rate != "-" && rate != "100.0%" {
+				failing = append(failing, r)
+				break
+			}
+		}
+	}
+	if len(failing) == 0 {
+		fmt.Fprintf(w, "\nEvery test passed every run at every point of %s\n", paramNames(params))
+		return nil
+	}
+	sort.Slice(failing, func(i, j int) bool {
+		if failing[i].pkg != failing[j].pkg {
+			return failing[i].pkg < failing[j].pkg
+		}
+		return failing[i].test < failing[j].test
+	})
+
+	fmt.Fprintf(w, "\nPass rate by %s:\n", paramNames(params))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "TEST")
+	for _, p := range points {
+		fmt.Fprintf(tw, "\t%s", strings.Join(pointValues(p), ","))
+	}
+	fmt.Fprintln(tw)
+	for _, r := range failing {
+		fmt.Fprintf(tw, "%s\t%s\n", r.test, strings.Join(r.rates, "\t"))
+	}
+	return tw.Flush()
+}
+
+// writeParamSweep writes the params and every point's report as JSON
+func writeParamSweep(w io.Writer, params []sweep.Param, points []sweep.Point) error {
+	names := make([]string, len(params))
+	for i, p := range params {
+		names[i] = p.Name()
+	}
+	data, err := json.MarshalIndent(struct {
+		Params []string      `json:"params"`
+		Points []sweep.Point `json:"points"`
+	}{names, points}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// writeParamCSV writes a row per test and point, with the point's values
+// first, for plotting pass rate curves
+func writeParamCSV(w io.Writer, params []sweep.Param, points []sweep.Point) error {
+	cw := csv.NewWriter(w)
+	header := make([]string, 0, len(params)+6)
+	for _, p := range params {
+		header = append(header, p.Name())
+	}
+	header = append(header, "package", "test", "runs", "passed", "failed", "pass_rate")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, p := range points {
+		for _, t := range p.Report.Tests {
+			record := append(pointValues(p), t.Package, t.Test, strconv.Itoa(t.Runs), strconv.Itoa(t.Passed), strconv.Itoa(t.Failed), strconv.FormatFloat(t.PassRate, 'f', -1, 64))
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
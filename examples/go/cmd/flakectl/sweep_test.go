@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	flaky "github.com/example/flaky-test-example"
+	reportfmt "github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/sweep"
+)
+
+func TestParseSeedRange(t *testing.T) {
+	for in, want := range map[string][2]int64{
+		"0-100000": {0, 100000},
+		"7":        {7, 7},
+		"5-5":      {5, 5},
+	} {
+		start, end, err := parseSeedRange(in)
+		if err != nil || start != want[0] || end != want[1] {
+			t.Errorf("parseSeedRange(%q) = %d, %d, %v; want %v", in, start, end, err, want)
+		}
+	}
+	for _, in := range []string{"", "10-1", "a-b", "1-"} {
+		if _, _, err := parseSeedRange(in); err == nil {
+			t.Errorf("Expected parseSeedRange(%q) to fail", in)
+		}
+	}
+}
+
+func TestPrintSweep(t *testing.T) {
+	seeds := make([]int64, 12)
+	for i := range seeds {
+		seeds[i] = int64(i * 2)
+	}
+	r := &reportfmt.JSONReport{
+		Runs:    100,
+		Summary: reportfmt.JSONSummary{Tests: 2, Stable: 1, Flaky: 1},
+		Tests: []reportfmt.JSONTest{
+			{Test: "TestA", Classification: "stable", Runs: 100, Passed: 100},
+			{Test: "TestB", Classification: "flaky", Runs: 100, Passed: 87, Failed: 13, Failures: []reportfmt.JSONFailure{
+				{Message: "boundary exceeded", Seeds: seeds},
+				{Message: "timeout", Seeds: []int64{41}},
+			}},
+		},
+	}
+	var out bytes.Buffer
+	printSweep(&out, r)
+	for _, want := range []string{
+		"100 seeds, 2 tests: 1 stable, 1 flaky, 0 failing",
+		"TestB (flaky, 13/100 runs failed)",
+		"  boundary exceeded\n    seeds: 0, 2, 4, 6, 8, 10, 12, 14, 16, 18, +2 more",
+		"  timeout\n    seeds: 41",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "TestA") {
+		t.Errorf("Expected passing tests to be omitted:\n%s", out.String())
+	}
+}
+
+// paramPoints returns a sweep of network.failure_rate over 0.1 and 0.5 in
+// which TestNet follows the rate and TestA always passes
+func paramPoints() ([]sweep.Param, []sweep.Point) {
+	params := []sweep.Param{{Target: "network", Field: "failure_rate", Values: []string{"0.1", "0.5"}}}
+	var points []sweep.Point
+	for _, rate := range []struct {
+		value  string
+		passed int
+	}{{"0.1", 90}, {"0.5", 50}} {
+		points = append(points, sweep.Point{
+			Overrides: []flaky.Override{{Target: "network", Field: "failure_rate", Value: rate.value}},
+			Report: &reportfmt.JSONReport{Runs: 100, Tests: []reportfmt.JSONTest{
+				{Package: "p", Test: "TestA", Runs: 100, Passed: 100, PassRate: 1},
+				{Package: "p", Test: "TestNet", Runs: 100, Passed: rate.passed, Failed: 100 - rate.passed, PassRate: float64(rate.passed) / 100},
+			}},
+		})
+	}
+	return params, points
+}
+
+func TestPrintParamSweep(t *testing.T) {
+	params, points := paramPoints()
+	var out bytes.Buffer
+	if err := printParamSweep(&out, params, points); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Pass rate by network.failure_rate:",
+		"TEST     0.1    0.5",
+		"TestNet  90.0%  50.0%",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "TestA") {
+		t.Errorf("Expected tests passing at every point to be omitted:\n%s", out.String())
+	}
+}
+
+func TestWriteParamCSV(t *testing.T) {
+	params, points := paramPoints()
+	var out bytes.Buffer
+	if err := writeParamCSV(&out, params, points); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Expected a header and a row per test and point, got:\n%s", out.String())
+	}
+	if lines[0] != "network.failure_rate,package,test,runs,passed,failed,pass_rate" || lines[4] != "0.5,p,TestNet,100,50,50,0.5" {
+		t.Errorf("Unexpected CSV:\n%s", out.String())
+	}
+}
+
+func TestRunSweepRejectsInvalidParams(t *testing.T) {
+	for _, args := range [][]string{
+		{"--param", "network.failure_rate=0.5:0.1:0.1"},
+		{"--set", "failure_rate=0.2"},
+		{"--set", "RandomFailure.failure_rate=2"},
+		{"--param", "network.failure_rate=0.5:1.5:0.5"},
+		{"--csv", "out.csv"},
+	} {
+		if err := runSweep(args, &bytes.Buffer{}); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
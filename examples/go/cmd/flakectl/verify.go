@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/expiry"
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/quarantine"
+)
+
+// runQuarantineVerify runs the quarantined tests, records the sweep in the
+// history as a verification and proposes releasing the tests the policy
+// says have recovered
+func runQuarantineVerify(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("quarantine verify", flag.ContinueOnError)
+	file := fs.String("file", quarantine.File(), "quarantine list (.json for JSON, otherwise one test per line)")
+	runs := fs.Int("runs", 10, "number of times to run the quarantined tests")
+	seed := fs.Int64("seed", 0, "seed of the first run (default: one past the highest seed in --history, so every verification tries new seeds)")
+	dir := fs.String("dir", "", "directory to run go test in")
+	historyFile := fs.String("history", history.DefaultFile, "history database to record the verification in")
+	policy := policyFlags(fs)
+	apply := fs.Bool("apply", false, "remove the tests proposed for release from the list instead of only proposing it")
+	packages, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *runs < 1 {
+		return fmt.Errorf("--runs must be at least 1, got %d", *runs)
+	}
+	if *runs < policy.MinRuns {
+		return fmt.Errorf("--runs %d is below --min-runs %d, so the verification would not count", *runs, policy.MinRuns)
+	}
+	if *historyFile == "" {
+		return errors.New("quarantine verify needs --history to count verifications in")
+	}
+	list, err := quarantine.Load(*file)
+	if err != nil {
+		return err
+	}
+	if len(list.Entries) == 0 {
+		fmt.Fprintf(stdout, "Nothing is quarantined in %s\n", *file)
+		return nil
+	}
+
+	db, err := history.Open(*historyFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	past, err := db.Sessions(time.Time{})
+	if err != nil {
+		return err
+	}
+	seedSet := false
+	fs.Visit(func(f *flag.Flag) { seedSet = seedSet || f.Name == "seed" })
+	if !seedSet {
+		*seed = nextSeed(past)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	report, err := runner.Detect(ctx, runner.Config{
+		Packages: packages,
+		Runs:     *runs,
+		Seed:     *seed,
+		Run:      quarantinedPattern(list),
+		Dir:      *dir,
+		// An empty list quarantines nothing, so the tests run
+		Env: []string{quarantine.FileEnv + "=" + os.DevNull},
+	})
+	if err != nil {
+		return err
+	}
+	session := history.NewSession(report, history.Commit(*dir))
+	session.Source = expiry.Source
+	if err := db.Add(session); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Verified %d quarantined test(s) over %d runs from seed %d\n\n", len(list.Entries), report.Runs, *seed)
+
+	statuses := expiry.Evaluate(list, append(past, *session), *policy, time.Now())
+	if err := printExpiry(stdout, statuses, *policy); err != nil {
+		return err
+	}
+	return proposeReleases(stdout, list, statuses, *policy, *apply)
+}
+
+// runQuarantineStatus prints the age and record of every quarantined test
+// from the history, without running anything
+func runQuarantineStatus(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("quarantine status", flag.ContinueOnError)
+	file := fs.String("file", quarantine.File(), "quarantine list (.json for JSON, otherwise one test per line)")
+	historyFile := fs.String("history", history.DefaultFile, "history database flakectl quarantine verify records in")
+	policy := policyFlags(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errors.New("usage: flakectl quarantine status [--file path] [--history file] [--verifications N]")
+	}
+	list, err := quarantine.Load(*file)
+	if err != nil {
+		return err
+	}
+	if len(list.Entries) == 0 {
+		fmt.Fprintf(stdout, "Nothing is quarantined in %s\n", *file)
+		return nil
+	}
+	var sessions []history.Session
+	if _, err := os.Stat(*historyFile); err == nil {
+		db, err := history.Open(*historyFile)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		if sessions, err = db.Sessions(time.Time{}); err != nil {
+			return err
+		}
+	}
+	statuses := expiry.Evaluate(list, sessions, *policy, time.Now())
+	if err := printExpiry(stdout, statuses, *policy); err != nil {
+		return err
+	}
+	return proposeReleases(stdout, list, statuses, *policy, false)
+}
+
+// policyFlags defines the flags of the release policy on fs
+func policyFlags(fs *flag.FlagSet) *expiry.Policy {
+	p := &expiry.Policy{}
+	fs.IntVar(&p.Verifications, "verifications", 3, "verifications in a row a quarantined test must pass every run of to be proposed for release")
+	fs.IntVar(&p.MinRuns, "min-runs", 5, "fewest runs of a test a verification needs to count")
+	return p
+}
+
+// quarantinedPattern returns a go test -run pattern of the top-level tests
+// of list, which runs the subtests of quarantined subtests too
+func quarantinedPattern(list *quarantine.List) string {
+	var names []string
+	for _, e := range list.Entries {
+		name, _, _ := strings.Cut(e.Test, "/")
+		if name = regexp.QuoteMeta(name); !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return "^(" + strings.Join(names, "|") + ")$"
+}
+
+// nextSeed returns one past the highest seed recorded in sessions, or 1
+func nextSeed(sessions []history.Session) int64 {
+	var highest int64
+	for _, s := range sessions {
+		for _, r := range s.Results {
+			highest = max(highest, r.Seed)
+		}
+	}
+	return highest + 1
+}
+
+// printExpiry writes the age, pass rate since quarantining and verification
+// streak of every test in statuses
+func printExpiry(w io.Writer, statuses []expiry.Status, p expiry.Policy) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TEST\tADDED\tAGE\tPASS RATE SINCE\tSTREAK\tVERDICT\tREASON")
+	for _, st := range statuses {
+		added, age := "-", "-"
+		if st.Entry.Added != nil {
+			added = st.Entry.Added.UTC().Format(time.DateOnly)
+			age = fmt.Sprintf("%dd", int(st.Age.Hours()/24))
+		}
+		passRate := "-"
+		if st.Since.Runs() > 0 {
+			passRate = fmt.Sprintf("%.1f%% (%d of %d)", 100-st.Since.FlakeRate()*100, st.Since.Passed, st.Since.Runs())
+		}
+		reason := st.Entry.Reason
+		if reason == "" {
+			reason = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d of %d\t%s\t%s\n", st.Entry.Test, added, age, passRate, min(st.Streak, p.Verifications), p.Verifications, st.Verdict, reason)
+	}
+	return tw.Flush()
+}
+
+// proposeReleases lists the tests the policy releases with the command to
+// release each, or releases them and saves the list when apply is set
+func proposeReleases(w io.Writer, list *quarantine.List, statuses []expiry.Status, p expiry.Policy, apply bool) error {
+	releases := expiry.Releases(statuses)
+	if len(releases) == 0 {
+		return nil
+	}
+	if !apply {
+		fmt.Fprintf(w, "\nProposed releases (passed every run of %d verification(s) in a row):\n", p.Verifications)
+		for _, test := range releases {
+			fmt.Fprintf(w, "  flakectl quarantine remove %s --file %s\n", test, list.Path)
+		}
+		return nil
+	}
+	for _, test := range releases {
+		list.Remove(test)
+		fmt.Fprintf(w, "Released %s\n", test)
+	}
+	return list.Save()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/expiry"
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/quarantine"
+)
+
+func TestQuarantinedPattern(t *testing.T) {
+	list := &quarantine.List{Entries: []quarantine.Entry{{Test: "TestA/case_1"}, {Test: "TestA"}, {Test: "TestB.x"}}}
+	if got := quarantinedPattern(list); got != `^(TestA|TestB\.x)$` {
+		t.Errorf("Unexpected pattern %q", got)
+	}
+}
+
+func TestNextSeed(t *testing.T) {
+	if got := nextSeed(nil); got != 1 {
+		t.Errorf("Expected seed 1 without history, got %d", got)
+	}
+	sessions := []history.Session{{Results: []history.Record{{Seed: 7}, {Seed: 30}}}, {Results: []history.Record{{Seed: 12}}}}
+	if got := nextSeed(sessions); got != 31 {
+		t.Errorf("Expected seed 31, got %d", got)
+	}
+}
+
+func TestQuarantineStatus(t *testing.T) {
+	dir := t.TempDir()
+	file, historyFile := filepath.Join(dir, "quarantine.txt"), filepath.Join(dir, "history.db")
+	added := time.Now().UTC().Add(-72 * time.Hour).Format(time.DateOnly)
+	content := "TestFixed # flaky (added " + added + ")\nTestFlaky\n"
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := history.Open(historyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		flaky := runner.Pass
+		if i == 1 {
+			flaky = runner.Fail
+		}
+		var results []runner.Result
+		for run := 0; run < 5; run++ {
+			results = append(results,
+				runner.Result{Package: "p", Test: "TestFixed", Run: run, Outcome: runner.Pass},
+				runner.Result{Package: "p", Test: "TestFlaky", Run: run, Outcome: flaky})
+		}
+		session := history.NewSession(runner.Aggregate(5, results), "")
+		session.Source = expiry.Source
+		if err := db.Add(session); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	var out bytes.Buffer
+	if err := runQuarantine([]string{"status", "--file", file, "--history", historyFile}, &out); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"TestFixed  " + added + "  3d   100.0% (15 of 15)  3 of 3  release  flaky",
+		"TestFlaky  -           -    66.7% (10 of 15)   1 of 3  keep     -",
+		"flakectl quarantine remove TestFixed --file " + file,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected the status to contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "remove TestFlaky") {
+		t.Errorf("Proposed releasing a test that failed a verification:\n%s", got)
+	}
+}
+
+func TestQuarantineVerifyNeedsEnoughRuns(t *testing.T) {
+	err := runQuarantine([]string{"verify", "--runs", "2", "--min-runs", "5"}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "--min-runs") {
+		t.Errorf("Expected too few runs to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/watch"
+)
+
+func runWatch(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	runs := fs.Int("runs", 5, "times to run the affected packages after each change")
+	window := fs.Int("window", 20, "recent outcomes kept per test")
+	seed := fs.Int64("seed", 1, "seed of the first run; every later run uses the next seed")
+	runRegex := fs.String("run", "", "only run tests matching this regex")
+	dir := fs.String("dir", "", "directory to run go test in")
+	interval := fs.Duration("interval", 500*time.Millisecond, "how often to poll for changed files")
+	noClear := fs.Bool("no-clear", false, "append each update instead of redrawing the screen")
+	packages, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return watch.Run(ctx, watch.Config{
+		Packages: packages,
+		Dir:      *dir,
+		Run:      *runRegex,
+		Seed:     *seed,
+		Runs:     *runs,
+		Window:   *window,
+		Interval: *interval,
+		Clear:    !*noClear && isTerminal(stdout),
+	}, stdout)
+}
+
+// isTerminal reports whether w is a terminal, where redrawing makes sense
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// checkpointDirEnv names the directory jobs are checkpointed in, such as a
+// RunPod network volume under /runpod-volume that outlives the worker
+const checkpointDirEnv = "FLAKY_CHECKPOINT_DIR"
+
+// checkpoints stores one runner checkpoint per job ID in dir; an empty dir
+// stores nothing, so jobs start over when they are retried
+type checkpoints struct {
+	dir string
+}
+
+func checkpointsFromEnv() checkpoints {
+	return checkpoints{dir: os.Getenv(checkpointDirEnv)}
+}
+
+// path returns the checkpoint file of a job, or "" when checkpoints are off
+func (c checkpoints) path(jobID string) string {
+	if c.dir == "" {
+		return ""
+	}
+	name := strings.NewReplacer("/", "_", `\`, "_").Replace(jobID)
+	if name == "" {
+		name = "local"
+	}
+	return filepath.Join(c.dir, name+".json")
+}
+
+// load returns the checkpoint a job resumes from, or nil to start it over
+// A checkpoint of another sweep belongs to an earlier job with the same ID
+// and is ignored
+func (c checkpoints) load(jobID string, cfg runner.Config) (*runner.Checkpoint, error) {
+	if c.dir == "" {
+		return nil, nil
+	}
+	cp, err := runner.LoadCheckpoint(c.path(jobID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cp.Matches(cfg) != nil {
+		return nil, nil
+	}
+	return cp, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/internal/tracing"
+)
+
+// maxRuns caps the runs one job may request, matching input_schema.json
+const maxRuns = 1000
+
+// Job is a serverless job as delivered by the job queue
+type Job struct {
+	ID    string   `json:"id"`
+	Input JobInput `json:"input"`
+}
+
+// JobInput is the payload of a flake detection job
+type JobInput struct {
+	// Package is the go test package pattern (default "./...")
+	Package string `json:"package"`
+	// Dir is the directory go test runs in (default the working directory)
+	Dir string `json:"dir"`
+	// Run is an optional -run regex
+	Run string `json:"run"`
+	// Runs is the number of runs; it may be omitted when SeedEnd is set
+	Runs int `json:"runs"`
+	// SeedStart and SeedEnd bound the inclusive GO_TEST_SEED range; run i
+	// uses SeedStart+i
+	SeedStart *int64 `json:"seed_start"`
+	SeedEnd   *int64 `json:"seed_end"`
+	// Confidence is the level of the reported flake-rate intervals
+	Confidence float64 `json:"confidence"`
+	// Set holds scenario overrides every run applies, as FLAKY_SET takes
+	// them, such as "network.failure_rate=0.2"
+	Set string `json:"set"`
+}
+
+// config validates the input and turns it into a runner configuration
+func (in JobInput) config() (runner.Config, float64, error) {
+	cfg := runner.Config{Packages: []string{"./..."}, Dir: in.Dir, Run: in.Run, Seed: 1}
+	if in.Package != "" {
+		cfg.Packages = []string{in.Package}
+	}
+	if in.SeedStart != nil {
+		cfg.Seed = *in.SeedStart
+	}
+
+	cfg.Runs = in.Runs
+	if in.SeedEnd != nil {
+		if *in.SeedEnd < cfg.Seed {
+			return cfg, 0, fmt.Errorf("seed_end %d is before seed_start %d", *in.SeedEnd, cfg.Seed)
+		}
+		span := *in.SeedEnd - cfg.Seed + 1
+		if span > maxRuns {
+			return cfg, 0, fmt.Errorf("seed range covers %d runs, more than %d", span, maxRuns)
+		}
+		if in.Runs != 0 && int64(in.Runs) != span {
+			return cfg, 0, fmt.Errorf("runs %d does not match seed range %d-%d", in.Runs, cfg.Seed, *in.SeedEnd)
+		}
+		cfg.Runs = int(span)
+	}
+	if cfg.Runs == 0 {
+		cfg.Runs = 10
+	}
+	if cfg.Runs < 0 || cfg.Runs > maxRuns {
+		return cfg, 0, fmt.Errorf("runs must be between 1 and %d, got %d", maxRuns, cfg.Runs)
+	}
+
+	if in.Set != "" {
+		if _, err := flaky.ParseOverrides(in.Set); err != nil {
+			return cfg, 0, fmt.Errorf("set: %w", err)
+		}
+		cfg.Env = append(cfg.Env, flaky.SetEnv+"="+in.Set)
+	}
+
+	confidence := in.Confidence
+	if confidence == 0 {
+		confidence = 0.95
+	}
+	if confidence <= 0 || confidence >= 1 {
+		return cfg, 0, errors.New("confidence must be between 0 and 1")
+	}
+	return cfg, confidence, nil
+}
+
+// handler runs detection jobs, checkpointing every completed run so a job
+// retried after the worker was killed resumes where it stopped
+type handler struct {
+	rec         *tracing.Recorder
+	checkpoints checkpoints
+	// afterRun, when set, is called once each run is checkpointed, with the
+	// number of runs the job has completed
+	afterRun func(completed int)
+}
+
+// newHandler returns a handler recording each run as a trace with rec when
+// it is not nil
+func newHandler(rec *tracing.Recorder, cps checkpoints) *handler {
+	return &handler{rec: rec, checkpoints: cps}
+}
+
+// handle runs the detection loop for a job and returns its flake report
+func (h *handler) handle(ctx context.Context, job *Job) (*report.JSONReport, error) {
+	r, confidence, err := h.detect(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	return report.NewJSONReport(r, confidence, nil), nil
+}
+
+// detect runs the runs of a job after its checkpoint, checkpointing each
+// one, and aggregates them with the checkpointed ones
+func (h *handler) detect(ctx context.Context, job *Job) (*runner.Report, float64, error) {
+	cfg, confidence, err := job.Input.config()
+	if err != nil {
+		return nil, 0, err
+	}
+	if cfg.Resume, err = h.checkpoints.load(job.ID, cfg); err != nil {
+		return nil, 0, err
+	}
+	if cfg.Resume != nil {
+		log.Printf("worker: resuming job %s after %d of %d runs", job.ID, cfg.Resume.Completed, cfg.Runs)
+	}
+	cfg.Checkpoint = h.checkpoints.path(job.ID)
+
+	trace := h.rec.Observer(ctx, cfg)
+	cfg.Observe = func(run int, results []runner.Result) {
+		if trace != nil {
+			trace(run, results)
+		}
+		if h.afterRun != nil {
+			h.afterRun(run + 1)
+		}
+	}
+	r, err := runner.Detect(ctx, cfg)
+	if err != nil {
+		return nil, 0, err
+	}
+	return r, confidence, nil
+}
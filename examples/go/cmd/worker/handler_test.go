@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func int64p(v int64) *int64 { return &v }
+
+func TestJobInputConfig(t *testing.T) {
+	cfg, confidence, err := JobInput{}.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Packages[0] != "./..." || cfg.Runs != 10 || cfg.Seed != 1 || confidence != 0.95 {
+		t.Errorf("Unexpected defaults: %+v confidence=%v", cfg, confidence)
+	}
+
+	cfg, _, err = JobInput{Package: "./pkg", SeedStart: int64p(100), SeedEnd: int64p(149)}.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Packages[0] != "./pkg" || cfg.Seed != 100 || cfg.Runs != 50 {
+		t.Errorf("Expected 50 runs from seed 100 over ./pkg, got %+v", cfg)
+	}
+
+	cfg, _, err = JobInput{Set: "network.failure_rate=0.2"}.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Env) != 1 || cfg.Env[0] != "FLAKY_SET=network.failure_rate=0.2" {
+		t.Errorf("Expected the overrides passed in FLAKY_SET, got %v", cfg.Env)
+	}
+}
+
+func TestJobInputConfigRejectsInvalidInput(t *testing.T) {
+	for name, in := range map[string]JobInput{
+		"inverted range":  {SeedStart: int64p(10), SeedEnd: int64p(5)},
+		"range too large": {SeedStart: int64p(0), SeedEnd: int64p(100000)},
+		"runs mismatch":   {Runs: 3, SeedStart: int64p(1), SeedEnd: int64p(10)},
+		"negative runs":   {Runs: -1},
+		"bad confidence":  {Confidence: 1.5},
+		"bad set":         {Set: "failure_rate=0.2"},
+	} {
+		if _, _, err := in.config(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// seededModule writes a module whose one test fails on odd seeds
+func seededModule(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/seeded\n\ngo 1.22\n",
+		"seeded_test.go": `package seeded
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSeedParity(t *testing.T) {
+	if seed := os.Getenv("GO_TEST_SEED"); seed[len(seed)-1]%2 == 1 {
+		t.Errorf("odd seed %s", seed)
+	}
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestHandleRunsDetection(t *testing.T) {
+	dir := seededModule(t)
+	out, err := newHandler(nil, checkpoints{}).handle(context.Background(), &Job{Input: JobInput{Package: ".", Dir: dir, SeedStart: int64p(1), SeedEnd: int64p(4)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Runs != 4 || out.Summary.Flaky != 1 || len(out.Tests) != 1 {
+		t.Fatalf("Unexpected report: %+v", out)
+	}
+	if seeds := out.Tests[0].FailingSeeds; len(seeds) != 2 || seeds[0] != 1 || seeds[1] != 3 {
+		t.Errorf("Expected failing seeds [1 3], got %v", seeds)
+	}
+}
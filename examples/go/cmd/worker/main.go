@@ -0,0 +1,114 @@
+// Command worker is a RunPod serverless handler that runs flake detection
+//
+// Each job names a package, a number of runs and an optional seed range; the
+// worker reruns the package's tests with go test and returns a structured
+// JSON flake report as the job output:
+//
+//	{"input": {"package": "./...", "seed_start": 1, "seed_end": 100}}
+//
+// On RunPod the worker polls the job queue configured by the
+// RUNPOD_WEBHOOK_* variables. Locally it runs a single job given with
+// --test_input, or read from test_input.json, and prints the report
+//
+// When OTEL_EXPORTER_OTLP_ENDPOINT is set, every run of a job's suite is
+// exported as a trace over OTLP/HTTP
+//
+// When FLAKY_CHECKPOINT_DIR is set, every completed run of a job is
+// checkpointed there, and a job retried after the worker was killed resumes
+// from its checkpoint; --simulate_preemption kills and restarts each job at
+// seeded points to check that it does
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/tracing"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "worker: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("worker", flag.ContinueOnError)
+	testInput := fs.String("test_input", "", "run this job JSON once and print the report instead of polling")
+	preemptions := fs.Int("simulate_preemption", 0, "kill and restart each job this many times at seeded points, checking it resumes from its checkpoint")
+	preemptionSeed := fs.Int64("preemption_seed", 1, "seed of the simulated preemption points")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var rec *tracing.Recorder
+	if tracing.Enabled() {
+		tp, err := tracing.NewProvider(ctx, "flaky-worker")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			// ctx may be cancelled by now; flushing gets its own deadline
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			tp.Shutdown(flushCtx)
+		}()
+		rec = tracing.NewRecorder(tp, nil)
+	}
+	cps := checkpointsFromEnv()
+	if *preemptions > 0 && cps.dir == "" {
+		dir, err := os.MkdirTemp("", "worker-checkpoints-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		cps.dir = dir
+	}
+	h := newHandler(rec, cps)
+	handle := h.handle
+	if *preemptions > 0 {
+		handle = simulatePreemption(h, *preemptions, *preemptionSeed)
+	}
+
+	if *testInput == "" {
+		if q, ok := queueFromEnv(); ok {
+			return serve(ctx, q, handle)
+		}
+		data, err := os.ReadFile("test_input.json")
+		if err != nil {
+			return fmt.Errorf("no RUNPOD_WEBHOOK_GET_JOB, --test_input or test_input.json: %w", err)
+		}
+		*testInput = string(data)
+	}
+	return runLocal(ctx, []byte(*testInput), stdout, handle)
+}
+
+// runLocal runs one job and prints its result the way RunPod would return it
+func runLocal(ctx context.Context, input []byte, stdout io.Writer, handle handlerFunc) error {
+	var job Job
+	if err := json.Unmarshal(input, &job); err != nil {
+		return fmt.Errorf("parse job: %w", err)
+	}
+	out, err := handle(ctx, &job)
+	res := result{Output: out}
+	if err != nil {
+		res = result{Error: err.Error()}
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if encErr := enc.Encode(res); encErr != nil {
+		return encErr
+	}
+	return err
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// preemptionSim kills each job at seeded points and restarts it, as an
+// interruptible instance reclaimed mid-sweep would be, and checks that every
+// restart resumes from exactly the runs checkpointed before the kill
+type preemptionSim struct {
+	h     *handler
+	kills int
+	rng   *rand.Rand
+	// runTime is the duration of the last completed run, which kill delays
+	// are drawn as a fraction of
+	runTime time.Duration
+}
+
+// simulatePreemption returns a handler that kills every job up to kills
+// times before letting it finish; the runs the kills land in and how far
+// into them are drawn from seed
+// h must checkpoint somewhere for the job to survive its kills
+func simulatePreemption(h *handler, kills int, seed int64) handlerFunc {
+	sim := &preemptionSim{h: h, kills: kills, rng: rand.New(rand.NewPCG(uint64(seed), 0))}
+	return sim.handle
+}
+
+func (s *preemptionSim) handle(ctx context.Context, job *Job) (*report.JSONReport, error) {
+	cfg, _, err := job.Input.config()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { s.h.afterRun = nil }()
+
+	executed := 0
+	for kill := 0; kill < s.kills; kill++ {
+		done, err := s.completed(job, cfg)
+		if err != nil {
+			return nil, err
+		}
+		remaining := cfg.Runs - done
+		if remaining == 0 {
+			break
+		}
+		target, fraction := s.rng.IntN(remaining), s.rng.Float64()
+		log.Printf("worker: preempting job %s during run %d of %d", job.ID, done+target+1, cfg.Runs)
+
+		attemptCtx, cancel := context.WithCancel(ctx)
+		var mu sync.Mutex
+		var timer *time.Timer
+		arm := func() {
+			mu.Lock()
+			defer mu.Unlock()
+			timer = time.AfterFunc(time.Duration(fraction*float64(s.runTime)), cancel)
+		}
+		completed, last := done, time.Now()
+		s.h.afterRun = func(n int) {
+			now := time.Now()
+			s.runTime, last = now.Sub(last), now
+			completed = n
+			executed++
+			if n-done == target {
+				arm()
+			}
+		}
+		if target == 0 {
+			arm()
+		}
+		r, confidence, err := s.h.detect(attemptCtx, job)
+		mu.Lock()
+		if timer != nil {
+			timer.Stop()
+		}
+		mu.Unlock()
+		cancel()
+
+		switch {
+		case err == nil:
+			// The job finished before the kill landed
+			return verifyResumed(r, confidence, cfg, executed)
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		case !errors.Is(err, context.Canceled):
+			return nil, err
+		}
+		checkpointed, err := s.completed(job, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if checkpointed != completed {
+			return nil, fmt.Errorf("preemption: job %s checkpointed %d runs after completing %d", job.ID, checkpointed, completed)
+		}
+	}
+
+	s.h.afterRun = func(int) { executed++ }
+	r, confidence, err := s.h.detect(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	return verifyResumed(r, confidence, cfg, executed)
+}
+
+// completed returns the number of runs in the checkpoint of a job
+func (s *preemptionSim) completed(job *Job, cfg runner.Config) (int, error) {
+	cp, err := s.h.checkpoints.load(job.ID, cfg)
+	if cp == nil {
+		return 0, err
+	}
+	return cp.Completed, nil
+}
+
+// verifyResumed checks that a job restarted from its checkpoints ran every
+// seed exactly once
+func verifyResumed(r *runner.Report, confidence float64, cfg runner.Config, executed int) (*report.JSONReport, error) {
+	if r.Runs != cfg.Runs || executed != cfg.Runs {
+		return nil, fmt.Errorf("preemption: %d of %d runs reported, %d executed across restarts", r.Runs, cfg.Runs, executed)
+	}
+	seen := make(map[string]bool)
+	for _, res := range r.Results {
+		if res.Seed != cfg.Seed+int64(res.Run) {
+			return nil, fmt.Errorf("preemption: run %d of %s used seed %d, want %d", res.Run, res.Test, res.Seed, cfg.Seed+int64(res.Run))
+		}
+		key := fmt.Sprintf("%s %s %d", res.Package, res.Test, res.Run)
+		if seen[key] {
+			return nil, fmt.Errorf("preemption: run %d of %s reported twice", res.Run, res.Test)
+		}
+		seen[key] = true
+	}
+	if runs := runSet(r.Results); len(r.Results) > 0 && len(runs) != cfg.Runs {
+		return nil, fmt.Errorf("preemption: results cover %d of %d runs", len(runs), cfg.Runs)
+	}
+	return report.NewJSONReport(r, confidence, nil), nil
+}
+
+// runSet returns the run indexes results come from
+func runSet(results []runner.Result) map[int]bool {
+	runs := make(map[int]bool)
+	for _, res := range results {
+		runs[res.Run] = true
+	}
+	return runs
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestHandleResumesFromCheckpoint(t *testing.T) {
+	dir := seededModule(t)
+	cps := checkpoints{dir: t.TempDir()}
+	job := &Job{ID: "job-1", Input: JobInput{Package: ".", Dir: dir, SeedStart: int64p(1), SeedEnd: int64p(4)}}
+	cfg, _, err := job.Input.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Seeds 1 and 2 ran before the worker was killed; seed 1 failed
+	done := &runner.Checkpoint{Packages: cfg.Packages, Seed: 1, Runs: 4, Completed: 2, Results: []runner.Result{
+		{Package: "example.com/seeded", Test: "TestSeedParity", Run: 0, Seed: 1, Outcome: runner.Fail},
+		{Package: "example.com/seeded", Test: "TestSeedParity", Run: 1, Seed: 2, Outcome: runner.Pass},
+	}}
+	if err := done.Save(cps.path(job.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	var completed []int
+	h := newHandler(nil, cps)
+	h.afterRun = func(n int) { completed = append(completed, n) }
+	out, err := h.handle(context.Background(), job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(completed) != 2 || completed[0] != 3 || completed[1] != 4 {
+		t.Errorf("Expected only runs 3 and 4 to execute, got %v", completed)
+	}
+	if seeds := out.Tests[0].FailingSeeds; out.Runs != 4 || len(seeds) != 2 || seeds[0] != 1 || seeds[1] != 3 {
+		t.Errorf("Expected 4 runs failing on seeds [1 3], got %d runs failing on %v", out.Runs, seeds)
+	}
+	if _, err := os.Stat(cps.path(job.ID)); !os.IsNotExist(err) {
+		t.Errorf("Expected the finished job's checkpoint removed, got %v", err)
+	}
+}
+
+func TestCheckpointOfOtherSweepIsIgnored(t *testing.T) {
+	cps := checkpoints{dir: t.TempDir()}
+	cfg := runner.Config{Packages: []string{"./..."}, Runs: 5, Seed: 1}
+	if err := (&runner.Checkpoint{Packages: cfg.Packages, Runs: 5, Seed: 1, Completed: 3}).Save(cps.path("job-1")); err != nil {
+		t.Fatal(err)
+	}
+	if cp, err := cps.load("job-1", cfg); err != nil || cp == nil || cp.Completed != 3 {
+		t.Errorf("Expected the saved checkpoint, got %+v (%v)", cp, err)
+	}
+	cfg.Runs = 6
+	if cp, err := cps.load("job-1", cfg); err != nil || cp != nil {
+		t.Errorf("Expected no checkpoint for another sweep, got %+v (%v)", cp, err)
+	}
+	if cp, err := (checkpoints{}).load("job-1", cfg); err != nil || cp != nil {
+		t.Errorf("Expected no checkpoints without a directory, got %+v (%v)", cp, err)
+	}
+}
+
+func TestSimulatePreemptionResumesEveryKill(t *testing.T) {
+	dir := seededModule(t)
+	cps := checkpoints{dir: t.TempDir()}
+	handle := simulatePreemption(newHandler(nil, cps), 3, 1)
+	out, err := handle(context.Background(), &Job{ID: "job-1", Input: JobInput{Package: ".", Dir: dir, SeedStart: int64p(1), SeedEnd: int64p(6)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seeds := out.Tests[0].FailingSeeds; out.Runs != 6 || len(seeds) != 3 || seeds[0] != 1 || seeds[2] != 5 {
+		t.Errorf("Expected 6 runs failing on seeds [1 3 5], got %d runs failing on %v", out.Runs, seeds)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/report"
+)
+
+// pollInterval is how long the worker waits after an empty poll or a queue
+// error before asking again
+const pollInterval = time.Second
+
+// queue takes jobs from the RunPod job queue and posts their results, using
+// the endpoints and placeholders the runpod Python SDK uses
+type queue struct {
+	client  *http.Client
+	getURL  string
+	doneURL string
+	apiKey  string
+}
+
+// queueFromEnv configures the queue from the variables RunPod sets on
+// serverless workers; ok is false when they are not set
+func queueFromEnv() (q *queue, ok bool) {
+	get, done := os.Getenv("RUNPOD_WEBHOOK_GET_JOB"), os.Getenv("RUNPOD_WEBHOOK_POST_OUTPUT")
+	if get == "" || done == "" {
+		return nil, false
+	}
+	podID := os.Getenv("RUNPOD_POD_ID")
+	return &queue{
+		client:  &http.Client{Timeout: 90 * time.Second},
+		getURL:  strings.ReplaceAll(get, "$ID", podID),
+		doneURL: strings.ReplaceAll(done, "$RUNPOD_POD_ID", podID),
+		apiKey:  os.Getenv("RUNPOD_AI_API_KEY"),
+	}, true
+}
+
+// next returns the next job, or nil when the queue has none
+func (q *queue) next(ctx context.Context) (*Job, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, q.getURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", q.apiKey)
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNoContent || len(bytes.TrimSpace(body)) == 0:
+		return nil, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("get job: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	var job Job
+	if err := json.Unmarshal(body, &job); err != nil {
+		return nil, fmt.Errorf("get job: %w", err)
+	}
+	return &job, nil
+}
+
+// result is the body posted back for a finished job
+type result struct {
+	Output *report.JSONReport `json:"output,omitempty"`
+	Error  string             `json:"error,omitempty"`
+}
+
+// finish posts a job's report, or its error when jobErr is not nil
+func (q *queue) finish(ctx context.Context, job *Job, out *report.JSONReport, jobErr error) error {
+	res := result{Output: out}
+	if jobErr != nil {
+		res = result{Error: jobErr.Error()}
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	url := strings.ReplaceAll(q.doneURL, "$ID", job.ID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", q.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("post result for job %s: %s", job.ID, resp.Status)
+	}
+	return nil
+}
+
+type handlerFunc func(ctx context.Context, job *Job) (*report.JSONReport, error)
+
+// serve takes and runs jobs one at a time until ctx is cancelled
+func serve(ctx context.Context, q *queue, handle handlerFunc) error {
+	for {
+		job, err := q.next(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Printf("worker: %v", err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		log.Printf("worker: running job %s", job.ID)
+		out, jobErr := handle(ctx, job)
+		if err := q.finish(ctx, job, out, jobErr); err != nil {
+			log.Printf("worker: %v", err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/report"
+)
+
+// fakeQueue serves the given jobs once each and records posted results
+type fakeQueue struct {
+	mu      sync.Mutex
+	jobs    []string
+	results map[string]result
+	auth    []string
+	done    chan struct{}
+}
+
+func (f *fakeQueue) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/job-take/pod-1"):
+		if len(f.jobs) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		io.WriteString(w, f.jobs[0])
+		f.jobs = f.jobs[1:]
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/job-done/pod-1/"):
+		var res result
+		json.NewDecoder(r.Body).Decode(&res)
+		f.results[strings.TrimPrefix(r.URL.Path, "/job-done/pod-1/")] = res
+		if len(f.results) == 2 {
+			close(f.done)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestServeRunsQueuedJobs(t *testing.T) {
+	fq := &fakeQueue{
+		jobs: []string{
+			`{"id": "job-ok", "input": {"package": "./ok", "runs": 2}}`,
+			`{"id": "job-bad", "input": {"runs": -1}}`,
+		},
+		results: make(map[string]result),
+		done:    make(chan struct{}),
+	}
+	srv := httptest.NewServer(fq)
+	defer srv.Close()
+
+	t.Setenv("RUNPOD_WEBHOOK_GET_JOB", srv.URL+"/job-take/$ID")
+	t.Setenv("RUNPOD_WEBHOOK_POST_OUTPUT", srv.URL+"/job-done/$RUNPOD_POD_ID/$ID")
+	t.Setenv("RUNPOD_POD_ID", "pod-1")
+	t.Setenv("RUNPOD_AI_API_KEY", "secret")
+	q, ok := queueFromEnv()
+	if !ok {
+		t.Fatal("Expected a queue from the environment")
+	}
+
+	handle := func(_ context.Context, job *Job) (*report.JSONReport, error) {
+		cfg, _, err := job.Input.config()
+		if err != nil {
+			return nil, err
+		}
+		return &report.JSONReport{Runs: cfg.Runs}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- serve(ctx, q, handle) }()
+	<-fq.done
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected serve to stop with context.Canceled, got %v", err)
+	}
+
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+	if res := fq.results["job-ok"]; res.Output == nil || res.Output.Runs != 2 || res.Error != "" {
+		t.Errorf("Unexpected result for job-ok: %+v", res)
+	}
+	if res := fq.results["job-bad"]; res.Output != nil || !strings.Contains(res.Error, "runs must be") {
+		t.Errorf("Unexpected result for job-bad: %+v", res)
+	}
+	for _, auth := range fq.auth {
+		if auth != "secret" {
+			t.Errorf("Expected the API key on every request, got %q", auth)
+		}
+	}
+}
+
+func TestRunLocalPrintsResult(t *testing.T) {
+	handle := func(_ context.Context, job *Job) (*report.JSONReport, error) {
+		return &report.JSONReport{Runs: job.Input.Runs}, nil
+	}
+	var out bytes.Buffer
+	if err := runLocal(context.Background(), []byte(`{"input": {"runs": 7}}`), &out, handle); err != nil {
+		t.Fatal(err)
+	}
+	var res result
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil || res.Output == nil || res.Output.Runs != 7 {
+		t.Errorf("Unexpected output %s (%v)", out.String(), err)
+	}
+}
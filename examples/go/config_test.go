@@ -0,0 +1,45 @@
+package flaky
+
+import "sort"
+
+// FlakyConfig holds the per-run failure probability of each scenario
+type FlakyConfig struct {
+	FailureRates map[string]float64
+}
+
+// DefaultFlakyConfig returns the failure rates of the scenarios in flaky_test.go
+func DefaultFlakyConfig() FlakyConfig {
+	return FlakyConfig{FailureRates: DefaultScenarios().FailureRates()}
+}
+
+// scenarioNames returns the configured scenario names in a stable order
+func (c FlakyConfig) scenarioNames() []string {
+	names := make([]string, 0, len(c.FailureRates))
+	for name := range c.FailureRates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AllPassProbability returns the probability that every scenario passes in a
+// single run, assuming scenarios fail independently
+func (c FlakyConfig) AllPassProbability() float64 {
+	p := 1.0
+	for _, rate := range c.FailureRates {
+		p *= 1 - rate
+	}
+	return p
+}
+
+// simulateRun draws one outcome per scenario in name order and returns the
+// set of scenarios that failed
+func simulateRun(r randBackend, cfg FlakyConfig) map[string]bool {
+	failed := make(map[string]bool)
+	for _, name := range cfg.scenarioNames() {
+		if r.Float64() < cfg.FailureRates[name] {
+			failed[name] = true
+		}
+	}
+	return failed
+}
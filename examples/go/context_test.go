@@ -0,0 +1,69 @@
+package flaky_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/clock"
+	"github.com/example/flaky-test-example/flakyctx"
+)
+
+// orderDB commits an order 4ms into a save and flushes it for 2ms more
+type orderDB struct {
+	clk   *clock.FakeClock
+	saved int
+}
+
+func (db *orderDB) commit() {
+	db.clk.Sleep(4 * time.Millisecond)
+	db.saved++
+	db.clk.Sleep(2 * time.Millisecond)
+}
+
+// saveOrders saves one order with save, retrying up to 10 times on a context
+// that the scenario's chaos may cancel mid-save
+func saveOrders(t *testing.T, save func(ctx context.Context, db *orderDB) error) {
+	sc := flaky.ScenarioForTest(t, "ContextCancellation")
+	clk := clock.NewFake(time.Now())
+	chaos := flakyctx.New(flaky.ForTest(t), flakyctx.Profile{Cancel: sc.FailureRate, After: sc.LatencyDistribution()}, clk)
+	db := &orderDB{clk: clk}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		ctx, cancel := chaos.Wrap(context.Background())
+		err := save(ctx, db)
+		cancel()
+		if err == nil {
+			break
+		}
+	}
+	if db.saved != 1 {
+		t.Errorf("%s: saved %d times", sc.Message, db.saved)
+	}
+}
+
+// TestContextCancellation demonstrates a save that reports a cancellation
+// which raced with its commit
+// Fails 20% of the time by default: the cancellation lands after the commit,
+// the caller sees an error and retries, and the order is saved twice
+func TestContextCancellation(t *testing.T) {
+	saveOrders(t, func(ctx context.Context, db *orderDB) error {
+		db.commit()
+		return ctx.Err()
+	})
+}
+
+// TestContextCancellationFixed is the reliable variant of
+// TestContextCancellation
+// It checks the context before committing, and once the commit is done
+// reports success whatever happened to the context since
+func TestContextCancellationFixed(t *testing.T) {
+	saveOrders(t, func(ctx context.Context, db *orderDB) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		db.commit()
+		return nil
+	})
+}
@@ -0,0 +1,171 @@
+package flaky
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// loadPeriod is the cycle a busy goroutine spins for WithDutyCycle of and
+// idles for the rest of
+const loadPeriod = 10 * time.Millisecond
+
+// Load configures the background CPU load of a scenario; its FailureRate is
+// the probability that a run shares the host with a noisy neighbour
+type Load struct {
+	// MinLevel and MaxLevel bound the uniformly drawn level of a loaded
+	// run, in busy goroutines per GOMAXPROCS; at 1 every P has one
+	MinLevel float64 `json:"min_level" yaml:"min_level"`
+	MaxLevel float64 `json:"max_level" yaml:"max_level"`
+	// DutyCycle is the fraction of the time each busy goroutine spins, 1
+	// when zero
+	DutyCycle float64 `json:"duty_cycle,omitempty" yaml:"duty_cycle,omitempty"`
+}
+
+// Validate reports a negative or inverted level range and a duty cycle
+// outside [0, 1]
+func (l Load) Validate() error {
+	if l.MinLevel < 0 || l.MaxLevel < l.MinLevel || math.IsNaN(l.MinLevel) || math.IsNaN(l.MaxLevel) {
+		return fmt.Errorf("levels [%v, %v] are not a range of non-negative levels", l.MinLevel, l.MaxLevel)
+	}
+	if l.DutyCycle < 0 || l.DutyCycle > 1 || math.IsNaN(l.DutyCycle) {
+		return fmt.Errorf("duty cycle %v outside [0, 1]", l.DutyCycle)
+	}
+	return nil
+}
+
+// LoadOption configures ApplyCPULoad
+type LoadOption func(*loadConfig)
+
+type loadConfig struct {
+	dutyCycle float64
+}
+
+// WithDutyCycle makes each busy goroutine spin for only the given fraction
+// of every 10ms and sleep for the rest, as a neighbour throttled by its own
+// CPU quota does
+func WithDutyCycle(fraction float64) LoadOption {
+	return func(c *loadConfig) {
+		c.dutyCycle = fraction
+	}
+}
+
+// CPULoad is background CPU work a test runs beside, as a noisy neighbour
+// on a shared host does
+type CPULoad struct {
+	workers int
+	level   float64
+	stop    chan struct{}
+	// running counts the goroutines still spinning; the last to exit
+	// closes done
+	running atomic.Int32
+	done    chan struct{}
+	once    sync.Once
+}
+
+// ApplyCPULoad starts workers goroutines that spin on the CPU until t
+// finishes
+// Go preempts a goroutine only after it has run for about 10ms, so with a
+// worker on every P each goroutine of the test that wakes, from a sleep, a
+// timer or a channel, can wait that long before it runs again
+func ApplyCPULoad(t testing.TB, workers int, opts ...LoadOption) *CPULoad {
+	t.Helper()
+	cfg := loadConfig{dutyCycle: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.dutyCycle <= 0 {
+		workers = 0
+	}
+
+	l := &CPULoad{workers: workers, level: float64(workers) / float64(runtime.GOMAXPROCS(0)), stop: make(chan struct{}), done: make(chan struct{})}
+	busy := time.Duration(min(cfg.dutyCycle, 1) * float64(loadPeriod))
+	if workers == 0 {
+		close(l.done)
+	}
+	l.running.Store(int32(workers))
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer func() {
+				if l.running.Add(-1) == 0 {
+					close(l.done)
+				}
+			}()
+			spin(l.stop, busy)
+		}()
+	}
+	t.Cleanup(l.Stop)
+	return l
+}
+
+// spin keeps a CPU busy for busy of every loadPeriod until stop is closed
+func spin(stop <-chan struct{}, busy time.Duration) {
+	for {
+		for until := time.Now().Add(busy); time.Now().Before(until); {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+		if idle := loadPeriod - busy; idle > 0 {
+			select {
+			case <-stop:
+				return
+			case <-time.After(idle):
+			}
+		}
+	}
+}
+
+// Workers returns how many goroutines the load keeps busy
+func (l *CPULoad) Workers() int {
+	return l.workers
+}
+
+// Level returns the busy goroutines per GOMAXPROCS
+func (l *CPULoad) Level() float64 {
+	return l.level
+}
+
+// Done returns a channel that is closed once every goroutine of the load
+// has exited
+func (l *CPULoad) Done() <-chan struct{} {
+	return l.done
+}
+
+// Stop ends the load and waits for its goroutines to exit; it is safe to
+// call more than once and runs when the test finishes
+func (l *CPULoad) Stop() {
+	l.once.Do(func() {
+		close(l.stop)
+	})
+	<-l.done
+}
+
+// ApplyLoad draws whether a run of s is loaded, with probability
+// FailureRate, and at what level of its Load, then applies that load with
+// ApplyCPULoad; a scenario without a Load is loaded at level 1
+// The draws come from the injector, so a seed reproduces the load, and an
+// unloaded run gets a CPULoad of no workers
+func (i *Injector) ApplyLoad(t testing.TB, s Scenario) *CPULoad {
+	t.Helper()
+	load := Load{MinLevel: 1, MaxLevel: 1}
+	if s.Load != nil {
+		load = *s.Load
+	}
+	if s.Disabled || i.Float64() >= s.FailureRate {
+		return ApplyCPULoad(t, 0)
+	}
+	level := load.MinLevel + i.Float64()*(load.MaxLevel-load.MinLevel)
+	workers := int(math.Ceil(level * float64(runtime.GOMAXPROCS(0))))
+	dutyCycle := load.DutyCycle
+	if dutyCycle == 0 {
+		dutyCycle = 1
+	}
+	return ApplyCPULoad(t, workers, WithDutyCycle(dutyCycle))
+}
@@ -0,0 +1,104 @@
+package flaky
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestApplyCPULoadStops(t *testing.T) {
+	var load *CPULoad
+	t.Run("loaded", func(t *testing.T) {
+		load = ApplyCPULoad(t, 3, WithDutyCycle(0.5))
+		select {
+		case <-load.Done():
+			t.Error("Expected the busy goroutines to run until the test finishes")
+		default:
+		}
+		if load.Workers() != 3 {
+			t.Errorf("Expected 3 busy goroutines, got %d", load.Workers())
+		}
+		if want := 3 / float64(runtime.GOMAXPROCS(0)); load.Level() != want {
+			t.Errorf("Expected level %v, got %v", want, load.Level())
+		}
+	})
+	// The test's cleanup stopped the load and waited for its goroutines
+	select {
+	case <-load.Done():
+	default:
+		t.Error("Expected the busy goroutines to exit with the test")
+	}
+	load.Stop() // stopping again is harmless
+
+	if load := ApplyCPULoad(t, 4, WithDutyCycle(0)); load.Workers() != 0 {
+		t.Errorf("Expected no workers at a duty cycle of 0, got %d", load.Workers())
+	}
+}
+
+func TestApplyCPULoadDelaysWakeups(t *testing.T) {
+	// The total time of 1ms sleeps, rather than any one of them, so a single
+	// stall of a shared machine does not decide the comparison
+	sleeps := func() time.Duration {
+		start := time.Now()
+		for i := 0; i < 20; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		return time.Since(start)
+	}
+	baseline := sleeps()
+	ApplyCPULoad(t, runtime.GOMAXPROCS(0))
+	// A busy worker holds its P for up to a 10ms time slice
+	if loaded := sleeps(); loaded <= baseline {
+		t.Errorf("Expected 1ms sleeps beside busy goroutines to overrun the unloaded %v, took %v", baseline, loaded)
+	}
+}
+
+func TestApplyLoadFollowsScenario(t *testing.T) {
+	procs := runtime.GOMAXPROCS(0)
+	sc := Scenario{Name: "Load", FailureRate: 1, Load: &Load{MinLevel: 1, MaxLevel: 2}}
+	workers := func(seed int64, sc Scenario) int {
+		var n int
+		t.Run("", func(t *testing.T) {
+			n = NewInjector(WithSeed(seed)).ApplyLoad(t, sc).Workers()
+		})
+		return n
+	}
+	for seed := int64(1); seed <= 20; seed++ {
+		n := workers(seed, sc)
+		if n < procs || n > 2*procs {
+			t.Errorf("Seed %d: expected 1 to 2 workers per P, got %d", seed, n)
+		}
+		if again := workers(seed, sc); again != n {
+			t.Errorf("Seed %d: expected the same load again, got %d then %d", seed, n, again)
+		}
+	}
+
+	if n := workers(1, Scenario{Name: "Load", FailureRate: 1}); n != procs {
+		t.Errorf("Expected a scenario without a Load to run at level 1, got %d workers", n)
+	}
+	off := sc
+	off.FailureRate = 0
+	if n := workers(1, off); n != 0 {
+		t.Errorf("Expected no load at a failure rate of 0, got %d workers", n)
+	}
+	off = sc
+	off.Disabled = true
+	if n := workers(1, off); n != 0 {
+		t.Errorf("Expected no load for a disabled scenario, got %d workers", n)
+	}
+}
+
+func TestLoadValidate(t *testing.T) {
+	if err := (Load{MinLevel: 0.5, MaxLevel: 2, DutyCycle: 0.3}).Validate(); err != nil {
+		t.Errorf("Expected a valid load, got %v", err)
+	}
+	for _, l := range []Load{
+		{MinLevel: -1, MaxLevel: 1},
+		{MinLevel: 2, MaxLevel: 1},
+		{MinLevel: 1, MaxLevel: 1, DutyCycle: 1.5},
+	} {
+		if err := l.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected, got %v", l, err)
+		}
+	}
+}
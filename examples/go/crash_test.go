@@ -0,0 +1,63 @@
+package flaky
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"testing"
+)
+
+// crashEnv opts in to the scenarios that end the test binary, which would
+// otherwise stop every test after them from running
+const crashEnv = "FLAKY_CRASH"
+
+// requireCrashOptIn skips t unless FLAKY_CRASH is set
+func requireCrashOptIn(t *testing.T) {
+	t.Helper()
+	if os.Getenv(crashEnv) == "" {
+		t.Skipf("set %s=1 to run scenarios that end the test binary", crashEnv)
+	}
+}
+
+// TestPanic demonstrates a panic in a rarely taken branch
+// This simulates a cache that is only initialised on the common path
+func TestPanic(t *testing.T) {
+	requireCrashOptIn(t)
+	inj := ForTest(t)
+	sc := scenario(t, "Panic")
+
+	var cache map[string]int
+	if inj.Float64() >= sc.FailureRate {
+		cache = make(map[string]int)
+	}
+
+	// Panics with "assignment to entry in nil map" on the rare branch
+	cache["answer"] = 42
+}
+
+// TestGoexit demonstrates a helper that bails out with runtime.Goexit
+// This simulates t.FailNow reimplemented without telling the test it failed,
+// which go test reports as a panic
+func TestGoexit(t *testing.T) {
+	requireCrashOptIn(t)
+	inj := ForTest(t)
+	sc := scenario(t, "Goexit")
+
+	if inj.Float64() < sc.FailureRate {
+		t.Log(sc.Message)
+		runtime.Goexit()
+	}
+}
+
+// TestProcessExit demonstrates library code that exits the process
+// This simulates log.Fatal on a configuration error deep inside the code
+// under test; go test reports nothing for the test, only its package failing
+func TestProcessExit(t *testing.T) {
+	requireCrashOptIn(t)
+	inj := ForTest(t)
+	sc := scenario(t, "ProcessExit")
+
+	if inj.Float64() < sc.FailureRate {
+		log.New(os.Stderr, "", 0).Fatal(sc.Message)
+	}
+}
@@ -0,0 +1,53 @@
+// Package deadlock fails tests that stop making progress
+//
+// A deadlocked test blocks until go test's -timeout, 10 minutes by default,
+// and then panics with every goroutine's stack but no sign of which test
+// hung. Watch fails the test itself after a much shorter timeout and keeps
+// the stacks in that test's output
+package deadlock
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Watch fails t with a dump of every goroutine's stack if t has not finished
+// within timeout
+// A goroutine blocked forever cannot be unblocked from outside, so the
+// returned channel closes once t has failed; a test should stop waiting on
+// its deadlocked work when it does, and return
+func Watch(t testing.TB, timeout time.Duration) <-chan struct{} {
+	t.Helper()
+	expired := make(chan struct{})
+	var (
+		mu       sync.Mutex
+		finished bool
+	)
+	timer := time.AfterFunc(timeout, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if finished {
+			return
+		}
+		t.Errorf("%s did not finish within %v; goroutines:\n\n%s", t.Name(), timeout, Stacks())
+		close(expired)
+	})
+	t.Cleanup(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		finished = true
+		timer.Stop()
+	})
+	return expired
+}
+
+// Stacks returns the stack of every goroutine in the format of an unrecovered
+// panic, including how long each one has been blocked
+func Stacks() string {
+	var buf bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&buf, 2)
+	return buf.String()
+}
@@ -0,0 +1,84 @@
+package deadlock
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingTB captures Watch's failures and defers cleanups to the test
+type recordingTB struct {
+	testing.TB
+	mu       sync.Mutex
+	failures []string
+	cleanups []func()
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Cleanup(f func()) {
+	r.cleanups = append(r.cleanups, f)
+}
+
+func (r *recordingTB) finish() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func (r *recordingTB) failed() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.failures
+}
+
+func blockedForever(held *sync.Mutex) {
+	held.Lock()
+}
+
+func TestWatchFailsHungTestWithStacks(t *testing.T) {
+	rec := &recordingTB{TB: t}
+	defer rec.finish()
+	var mu sync.Mutex
+	mu.Lock()
+	go blockedForever(&mu)
+
+	select {
+	case <-Watch(rec, 10*time.Millisecond):
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the watchdog to expire")
+	}
+	failures := rec.failed()
+	if len(failures) != 1 {
+		t.Fatalf("Expected one failure, got %d", len(failures))
+	}
+	for _, want := range []string{t.Name() + " did not finish within 10ms", "deadlock.blockedForever", "[sync.Mutex.Lock"} {
+		if !strings.Contains(failures[0], want) {
+			t.Errorf("Expected the failure to contain %q:\n%s", want, failures[0])
+		}
+	}
+	mu.Unlock()
+}
+
+func TestWatchIgnoresFinishedTest(t *testing.T) {
+	rec := &recordingTB{TB: t}
+	expired := Watch(rec, 20*time.Millisecond)
+	rec.finish()
+
+	select {
+	case <-expired:
+		t.Error("Expected the watchdog to stop when the test finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if failures := rec.failed(); len(failures) != 0 {
+		t.Errorf("Expected no failures, got %v", failures)
+	}
+}
@@ -0,0 +1,59 @@
+package flaky
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/deadlock"
+)
+
+// account is a balance guarded by its own lock
+type account struct {
+	mu      sync.Mutex
+	balance int
+}
+
+// transfer locks from and then to, holding from while it works, so two
+// transfers locking the same pair of accounts in opposite orders deadlock
+func transfer(from, to *account, amount int) {
+	from.mu.Lock()
+	defer from.mu.Unlock()
+	time.Sleep(time.Millisecond) // check limits, write an audit record, ...
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	from.balance -= amount
+	to.balance += amount
+}
+
+// TestDeadlockSimulation demonstrates a lock-order inversion
+// This simulates two transfers between the same accounts, one of which
+// sometimes locks them in the opposite order
+func TestDeadlockSimulation(t *testing.T) {
+	expired := deadlock.Watch(t, 200*time.Millisecond)
+	inj := ForTest(t)
+	sc := scenario(t, "DeadlockSimulation")
+
+	a, b := &account{balance: 100}, &account{balance: 100}
+	from, to := a, b
+	if inj.Float64() < sc.FailureRate {
+		from, to = b, a // refund path written against the other lock order
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() { defer wg.Done(); transfer(a, b, 30) }()
+		go func() { defer wg.Done(); transfer(from, to, 10) }()
+		wg.Wait()
+	}()
+
+	// Fails with every goroutine's stack when the transfers deadlock
+	select {
+	case <-done:
+	case <-expired:
+		t.Log(sc.Message)
+	}
+}
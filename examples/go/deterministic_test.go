@@ -0,0 +1,105 @@
+package flaky
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+)
+
+// seededScenarios evaluates scenarios against an explicit RNG, reporting
+// whether that run failed
+var seededScenarios = map[string]func(r *rand.Rand) bool{
+	"RandomFailure":     func(r *rand.Rand) bool { return randomFailureThreshold.crossed(r.Float64()) },
+	"NetworkSimulation": func(r *rand.Rand) bool { return networkThreshold.crossed(r.Float64()) },
+	"BoundaryCondition": func(r *rand.Rand) bool { return boundaryThreshold.crossed(float64(r.IntN(5) + 98)) },
+	"ExplicitLocationParse": func(r *rand.Rand) bool {
+		parsed, err := parseExplicit(naiveTimestamp)
+		return err != nil || !parsed.Equal(expectedInstant)
+	},
+	"UnbufferedChannelSendFixed": func(r *rand.Rand) bool {
+		delay := time.Duration(r.IntN(3)) * time.Millisecond
+		return sendToReceiver(1, delay) != 1
+	},
+}
+
+// AssertDeterministic sweeps seeds 0..seeds-1 and fails if the named scenario
+// produces more than one distinct outcome, reporting the first seed whose
+// outcome differs from seed 0
+func AssertDeterministic(t testing.TB, name string, seeds int) {
+	t.Helper()
+	scenario, ok := seededScenarios[name]
+	if !ok {
+		t.Errorf("Unknown scenario %q", name)
+		return
+	}
+
+	outcome := func(seed int64) bool { return scenario(rand.New(PCG(seed))) }
+	first := outcome(0)
+	for seed := int64(1); seed < int64(seeds); seed++ {
+		if got := outcome(seed); got != first {
+			t.Errorf("Scenario %s is not deterministic: seed 0 %s but seed %d %s",
+				name, describeOutcome(first), seed, describeOutcome(got))
+			return
+		}
+	}
+}
+
+func describeOutcome(failed bool) string {
+	if failed {
+		return "failed"
+	}
+	return "passed"
+}
+
+// recordingTB captures failures so AssertDeterministic's own failures can be inspected
+type recordingTB struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+// TestAssertDeterministicPassesFixedScenarios verifies fixed variants are deterministic
+func TestAssertDeterministicPassesFixedScenarios(t *testing.T) {
+	AssertDeterministic(t, "ExplicitLocationParse", 50)
+	AssertDeterministic(t, "UnbufferedChannelSendFixed", 20)
+}
+
+// TestAssertDeterministicReportsCounterexample verifies a probabilistic scenario
+// fails with the first seed that disagrees with seed 0
+func TestAssertDeterministicReportsCounterexample(t *testing.T) {
+	rec := &recordingTB{TB: t}
+	AssertDeterministic(rec, "RandomFailure", 100)
+
+	if len(rec.failures) != 1 {
+		t.Fatalf("Expected exactly one failure, got %v", rec.failures)
+	}
+
+	first := seededScenarios["RandomFailure"](rand.New(PCG(0)))
+	var counterexample int64
+	for seed := int64(1); seed < 100; seed++ {
+		if seededScenarios["RandomFailure"](rand.New(PCG(seed))) != first {
+			counterexample = seed
+			break
+		}
+	}
+	want := fmt.Sprintf("seed %d", counterexample)
+	if got := rec.failures[0]; !strings.Contains(got, want) {
+		t.Errorf("Expected failure to mention %q, got %q", want, got)
+	}
+}
+
+// TestAssertDeterministicUnknownScenario verifies unknown names are reported
+func TestAssertDeterministicUnknownScenario(t *testing.T) {
+	rec := &recordingTB{TB: t}
+	AssertDeterministic(rec, "NoSuchScenario", 10)
+	if len(rec.failures) != 1 {
+		t.Errorf("Expected a failure for an unknown scenario, got %v", rec.failures)
+	}
+}
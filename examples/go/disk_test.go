@@ -0,0 +1,131 @@
+package flaky_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"testing"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/flakyfs"
+)
+
+type appConfig struct {
+	Workers int    `json:"workers"`
+	Region  string `json:"region"`
+}
+
+// saveConfigInPlace truncates the config file and writes the new contents
+// into it, so a failed write leaves a truncated or half-written file behind
+func saveConfigInPlace(fsys flakyfs.FS, cfg appConfig) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return flakyfs.WriteFile(fsys, "config.json", data, 0o644)
+}
+
+// saveConfigAtomic writes the new contents to a temp file and renames it over
+// the config file, which then holds either the old or the new contents
+func saveConfigAtomic(fsys flakyfs.FS, cfg appConfig) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if err := flakyfs.WriteFile(fsys, "config.json.tmp", data, 0o644); err != nil {
+		fsys.Remove("config.json.tmp")
+		return err
+	}
+	return fsys.Rename("config.json.tmp", "config.json")
+}
+
+// checkConfigSave writes an initial config, saves a new one through a disk
+// that fills up at the scenario's rate and checks the file is still readable
+func checkConfigSave(t *testing.T, save func(flakyfs.FS, appConfig) error) {
+	sc := flaky.ScenarioForTest(t, "DiskFull")
+	base := flakyfs.Dir(t.TempDir())
+	if err := saveConfigInPlace(base, appConfig{Workers: 4, Region: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	fsys := flakyfs.New(flaky.ForTest(t), flakyfs.Profile{
+		NoSpace:      sc.FailureRate / 2,
+		PartialWrite: sc.FailureRate / 2,
+	}, base)
+
+	if err := save(fsys, appConfig{Workers: 8, Region: "eu-west-1"}); err != nil {
+		t.Logf("Save failed: %v", err)
+	}
+	flaky.Report(t, sc.Meta(map[string]any{"faults": fsys.Counts()}))
+	data, err := fs.ReadFile(base, "config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg appConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Errorf("%s: %q: %v", sc.Message, data, err)
+	}
+}
+
+// TestDiskFull demonstrates a save that corrupts its file when the disk fills
+// up mid-write
+// Fails 20% of the time by default, with the truncated contents
+func TestDiskFull(t *testing.T) {
+	checkConfigSave(t, saveConfigInPlace)
+}
+
+// TestDiskFullFixed is the reliable variant of TestDiskFull
+// The save itself still fails, but the rename keeps the old config intact
+func TestDiskFullFixed(t *testing.T) {
+	checkConfigSave(t, saveConfigAtomic)
+}
+
+// computeReport stands in for an expensive computation whose result is
+// cached on disk
+func computeReport() []byte {
+	return []byte(`{"total":42}`)
+}
+
+// reportStrict caches the report and fails if the cache cannot be written
+func reportStrict(fsys flakyfs.FS) ([]byte, error) {
+	report := computeReport()
+	if err := flakyfs.WriteFile(fsys, "report.cache", report, 0o644); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// reportBestEffort caches the report when it can and returns it either way
+func reportBestEffort(fsys flakyfs.FS) ([]byte, error) {
+	report := computeReport()
+	if err := flakyfs.WriteFile(fsys, "report.cache", report, 0o644); err != nil && !errors.Is(err, fs.ErrPermission) {
+		return nil, err
+	}
+	return report, nil
+}
+
+// checkReport computes a report against a cache directory that is
+// unwritable at the scenario's rate
+func checkReport(t *testing.T, report func(flakyfs.FS) ([]byte, error)) {
+	sc := flaky.ScenarioForTest(t, "CacheUnwritable")
+	fsys := flakyfs.New(flaky.ForTest(t), flakyfs.Profile{PermissionDenied: sc.FailureRate}, flakyfs.Dir(t.TempDir()))
+
+	got, err := report(fsys)
+	if err != nil {
+		t.Fatalf("%s: %v", sc.Message, err)
+	}
+	if string(got) != `{"total":42}` {
+		t.Errorf("Expected the report, got %q", got)
+	}
+}
+
+// TestCacheUnwritable demonstrates code that treats a cache as required
+// Fails 20% of the time by default, like a CI runner with a read-only home
+func TestCacheUnwritable(t *testing.T) {
+	checkReport(t, reportStrict)
+}
+
+// TestCacheUnwritableFixed is the reliable variant of TestCacheUnwritable
+// A cache that cannot be written only costs recomputing the report next time
+func TestCacheUnwritableFixed(t *testing.T) {
+	checkReport(t, reportBestEffort)
+}
@@ -0,0 +1,347 @@
+// Package distributions provides seeded latency samplers for delay-based
+// fault injection: uniform, constant, normal, lognormal, exponential and
+// Pareto latencies, with their quantiles and CDFs
+//
+// Every sampler draws only from the *rand.Rand it is given, so a seeded
+// generator replays the same latencies on every run; samples are never
+// negative
+package distributions
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"time"
+)
+
+// Distribution is a distribution of non-negative latencies
+type Distribution interface {
+	// Sample draws one latency from r
+	Sample(r *rand.Rand) time.Duration
+	// Quantile returns the latency below which a fraction p of samples fall,
+	// for p in [0, 1]
+	Quantile(p float64) time.Duration
+	// CDF returns the fraction of samples at or below d
+	CDF(d time.Duration) float64
+	// Validate reports parameters the distribution is undefined for
+	Validate() error
+	String() string
+}
+
+// Uniform draws whole nanoseconds uniformly from [Min, Max]
+type Uniform struct {
+	Min, Max time.Duration
+	// Step, when set, draws whole multiples of it above Min instead, as
+	// rand.Intn(5)+1 milliseconds draws one of 1ms, 2ms, ... 5ms
+	Step time.Duration
+}
+
+// Sample draws a latency; it consumes no draw when Min equals Max
+func (u Uniform) Sample(r *rand.Rand) time.Duration {
+	if n := u.steps(); n > 0 {
+		return u.Min + time.Duration(r.Int64N(n+1))*u.unit()
+	}
+	return u.Min
+}
+
+func (u Uniform) Quantile(p float64) time.Duration {
+	if u.Step > 0 {
+		n := u.steps()
+		return u.Min + time.Duration(min(int64(clamp(p)*float64(n+1)), n))*u.Step
+	}
+	return u.Min + time.Duration(clamp(p)*float64(u.Max-u.Min))
+}
+
+func (u Uniform) CDF(d time.Duration) float64 {
+	switch {
+	case d < u.Min:
+		return 0
+	case d >= u.Max:
+		return 1
+	case u.Step > 0:
+		n := u.steps()
+		return float64(min(int64((d-u.Min)/u.Step), n)+1) / float64(n+1)
+	}
+	return float64(d-u.Min) / float64(u.Max-u.Min)
+}
+
+// unit is the duration the draws are whole multiples of
+func (u Uniform) unit() time.Duration {
+	if u.Step > 0 {
+		return u.Step
+	}
+	return 1
+}
+
+// steps is the number of steps from Min to the last value that can be drawn
+func (u Uniform) steps() int64 {
+	if u.Max <= u.Min {
+		return 0
+	}
+	return int64((u.Max - u.Min) / u.unit())
+}
+
+func (u Uniform) Validate() error {
+	if u.Min < 0 {
+		return fmt.Errorf("distributions: uniform min %v is negative", u.Min)
+	}
+	if u.Max < u.Min {
+		return fmt.Errorf("distributions: uniform max %v below min %v", u.Max, u.Min)
+	}
+	if u.Step < 0 {
+		return fmt.Errorf("distributions: uniform step %v is negative", u.Step)
+	}
+	return nil
+}
+
+func (u Uniform) String() string {
+	if u.Step > 0 {
+		return fmt.Sprintf("uniform(%v, %v, step %v)", u.Min, u.Max, u.Step)
+	}
+	return fmt.Sprintf("uniform(%v, %v)", u.Min, u.Max)
+}
+
+// Constant always returns its own latency, without drawing
+type Constant time.Duration
+
+func (c Constant) Sample(*rand.Rand) time.Duration { return time.Duration(c) }
+
+func (c Constant) Quantile(float64) time.Duration { return time.Duration(c) }
+
+func (c Constant) CDF(d time.Duration) float64 {
+	if d < time.Duration(c) {
+		return 0
+	}
+	return 1
+}
+
+func (c Constant) Validate() error {
+	if c < 0 {
+		return fmt.Errorf("distributions: constant latency %v is negative", time.Duration(c))
+	}
+	return nil
+}
+
+func (c Constant) String() string {
+	return fmt.Sprintf("constant(%v)", time.Duration(c))
+}
+
+// Normal is a normal distribution truncated at zero: draws below zero count
+// as zero latency, so a mean close to zero piles samples up there
+type Normal struct {
+	Mean, StdDev time.Duration
+}
+
+func (n Normal) Sample(r *rand.Rand) time.Duration {
+	return nonNegative(float64(n.Mean) + r.NormFloat64()*float64(n.StdDev))
+}
+
+func (n Normal) Quantile(p float64) time.Duration {
+	return nonNegative(float64(n.Mean) + normQuantile(p)*float64(n.StdDev))
+}
+
+func (n Normal) CDF(d time.Duration) float64 {
+	if d < 0 {
+		return 0
+	}
+	if n.StdDev == 0 {
+		return step(d >= n.Mean)
+	}
+	return normCDF(float64(d-n.Mean) / float64(n.StdDev))
+}
+
+func (n Normal) Validate() error {
+	if n.StdDev < 0 {
+		return fmt.Errorf("distributions: normal stddev %v is negative", n.StdDev)
+	}
+	return nil
+}
+
+func (n Normal) String() string {
+	return fmt.Sprintf("normal(mean %v, stddev %v)", n.Mean, n.StdDev)
+}
+
+// LogNormal is a latency whose logarithm is normal: Median is its median
+// and Sigma the standard deviation of its logarithm, so larger Sigma makes
+// a longer right tail
+type LogNormal struct {
+	Median time.Duration
+	Sigma  float64
+}
+
+func (l LogNormal) Sample(r *rand.Rand) time.Duration {
+	return nonNegative(float64(l.Median) * math.Exp(l.Sigma*r.NormFloat64()))
+}
+
+func (l LogNormal) Quantile(p float64) time.Duration {
+	return nonNegative(float64(l.Median) * math.Exp(l.Sigma*normQuantile(p)))
+}
+
+func (l LogNormal) CDF(d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	if l.Sigma == 0 {
+		return step(d >= l.Median)
+	}
+	return normCDF(math.Log(float64(d)/float64(l.Median)) / l.Sigma)
+}
+
+func (l LogNormal) Validate() error {
+	if l.Median <= 0 {
+		return fmt.Errorf("distributions: lognormal median %v is not positive", l.Median)
+	}
+	if l.Sigma < 0 || math.IsNaN(l.Sigma) {
+		return fmt.Errorf("distributions: lognormal sigma %v is negative", l.Sigma)
+	}
+	return nil
+}
+
+func (l LogNormal) String() string {
+	return fmt.Sprintf("lognormal(median %v, sigma %g)", l.Median, l.Sigma)
+}
+
+// Exponential is the memoryless latency of independent arrivals with the
+// given Mean
+type Exponential struct {
+	Mean time.Duration
+}
+
+func (e Exponential) Sample(r *rand.Rand) time.Duration {
+	return nonNegative(r.ExpFloat64() * float64(e.Mean))
+}
+
+func (e Exponential) Quantile(p float64) time.Duration {
+	return nonNegative(-math.Log1p(-clamp(p)) * float64(e.Mean))
+}
+
+func (e Exponential) CDF(d time.Duration) float64 {
+	if d < 0 {
+		return 0
+	}
+	if e.Mean == 0 {
+		return 1
+	}
+	return -math.Expm1(-float64(d) / float64(e.Mean))
+}
+
+func (e Exponential) Validate() error {
+	if e.Mean < 0 {
+		return fmt.Errorf("distributions: exponential mean %v is negative", e.Mean)
+	}
+	return nil
+}
+
+func (e Exponential) String() string {
+	return fmt.Sprintf("exponential(mean %v)", e.Mean)
+}
+
+// Pareto is a heavy-tailed latency of at least Min, where the chance of
+// exceeding x falls as (Min/x)^Alpha; an Alpha at or below 1 has no mean,
+// at or below 2 no variance
+type Pareto struct {
+	Min   time.Duration
+	Alpha float64
+}
+
+func (p Pareto) Sample(r *rand.Rand) time.Duration {
+	// 1 - Float64 is in (0, 1], so the power stays finite
+	return nonNegative(float64(p.Min) / math.Pow(1-r.Float64(), 1/p.Alpha))
+}
+
+func (p Pareto) Quantile(q float64) time.Duration {
+	return nonNegative(float64(p.Min) / math.Pow(1-clamp(q), 1/p.Alpha))
+}
+
+func (p Pareto) CDF(d time.Duration) float64 {
+	if d < p.Min {
+		return 0
+	}
+	return 1 - math.Pow(float64(p.Min)/float64(d), p.Alpha)
+}
+
+func (p Pareto) Validate() error {
+	if p.Min <= 0 {
+		return fmt.Errorf("distributions: pareto min %v is not positive", p.Min)
+	}
+	if !(p.Alpha > 0) {
+		return fmt.Errorf("distributions: pareto alpha %v is not positive", p.Alpha)
+	}
+	return nil
+}
+
+func (p Pareto) String() string {
+	return fmt.Sprintf("pareto(min %v, alpha %g)", p.Min, p.Alpha)
+}
+
+// Percentile returns the p-th percentile (0-100) of samples, interpolating
+// linearly between the closest ranks; samples need not be sorted
+func Percentile(samples []time.Duration, p float64) (time.Duration, error) {
+	if len(samples) == 0 {
+		return 0, errors.New("distributions: percentile of no samples")
+	}
+	if p < 0 || p > 100 || math.IsNaN(p) {
+		return 0, fmt.Errorf("distributions: percentile %v outside [0, 100]", p)
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	pos := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	if lo == len(sorted)-1 {
+		return sorted[lo], nil
+	}
+	frac := pos - float64(lo)
+	return sorted[lo] + time.Duration(frac*float64(sorted[lo+1]-sorted[lo])), nil
+}
+
+// Percentiles returns the quantiles of d at the given percentiles (0-100),
+// such as 50, 95 and 99
+func Percentiles(d Distribution, ps ...float64) []time.Duration {
+	out := make([]time.Duration, len(ps))
+	for i, p := range ps {
+		out[i] = d.Quantile(p / 100)
+	}
+	return out
+}
+
+// Sample draws n latencies from d
+func Sample(d Distribution, r *rand.Rand, n int) []time.Duration {
+	out := make([]time.Duration, n)
+	for i := range out {
+		out[i] = d.Sample(r)
+	}
+	return out
+}
+
+func clamp(p float64) float64 {
+	return math.Max(0, math.Min(1, p))
+}
+
+func step(ok bool) float64 {
+	if ok {
+		return 1
+	}
+	return 0
+}
+
+// nonNegative converts nanoseconds to a Duration, saturating at zero and at
+// the largest Duration
+func nonNegative(ns float64) time.Duration {
+	switch {
+	case !(ns > 0):
+		return 0
+	case ns >= math.MaxInt64:
+		return math.MaxInt64
+	}
+	return time.Duration(ns)
+}
+
+func normCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
+
+func normQuantile(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*clamp(p)-1)
+}
@@ -0,0 +1,151 @@
+package distributions
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+var all = []Distribution{
+	Uniform{Min: time.Millisecond, Max: 5 * time.Millisecond},
+	Constant(3 * time.Millisecond),
+	Normal{Mean: 20 * time.Millisecond, StdDev: 5 * time.Millisecond},
+	LogNormal{Median: 10 * time.Millisecond, Sigma: 0.8},
+	Exponential{Mean: 4 * time.Millisecond},
+	Pareto{Min: 2 * time.Millisecond, Alpha: 1.5},
+}
+
+// TestSamplesMatchQuantiles checks that each sampler's empirical percentiles
+// land near its analytic quantiles
+func TestSamplesMatchQuantiles(t *testing.T) {
+	for _, d := range all {
+		samples := Sample(d, rand.New(rand.NewPCG(1, 2)), 20000)
+		for _, p := range []float64{10, 50, 90, 99} {
+			got, err := Percentile(samples, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := d.Quantile(p / 100)
+			if diff := math.Abs(float64(got - want)); diff > 0.05*float64(want)+float64(50*time.Microsecond) {
+				t.Errorf("%v: expected p%v near %v, got %v", d, p, want, got)
+			}
+		}
+	}
+}
+
+func TestCDFInvertsQuantile(t *testing.T) {
+	for _, d := range all {
+		if _, ok := d.(Constant); ok {
+			continue
+		}
+		for _, p := range []float64{0.05, 0.5, 0.95} {
+			if got := d.CDF(d.Quantile(p)); math.Abs(got-p) > 1e-3 {
+				t.Errorf("%v: expected CDF(Quantile(%v)) = %v, got %v", d, p, p, got)
+			}
+		}
+	}
+}
+
+func TestSamplesAreSeededAndNonNegative(t *testing.T) {
+	for _, d := range all {
+		a := Sample(d, rand.New(rand.NewPCG(7, 0)), 500)
+		b := Sample(d, rand.New(rand.NewPCG(7, 0)), 500)
+		for i := range a {
+			if a[i] != b[i] {
+				t.Fatalf("%v: draw %d differs for one seed: %v and %v", d, i, a[i], b[i])
+			}
+			if a[i] < 0 {
+				t.Fatalf("%v: negative draw %v", d, a[i])
+			}
+		}
+	}
+	// A mean at zero truncates half the normal draws to zero
+	for _, s := range Sample(Normal{StdDev: time.Millisecond}, rand.New(rand.NewPCG(1, 0)), 100) {
+		if s < 0 {
+			t.Fatalf("Expected truncated normal draws, got %v", s)
+		}
+	}
+}
+
+func TestUniformBounds(t *testing.T) {
+	u := Uniform{Min: 2 * time.Millisecond, Max: 4 * time.Millisecond}
+	r := rand.New(rand.NewPCG(1, 0))
+	for i := 0; i < 1000; i++ {
+		if d := u.Sample(r); d < u.Min || d > u.Max {
+			t.Fatalf("Draw %v outside [%v, %v]", d, u.Min, u.Max)
+		}
+	}
+	if got := u.CDF(3 * time.Millisecond); got != 0.5 {
+		t.Errorf("Expected CDF 0.5 at the midpoint, got %v", got)
+	}
+	if d := (Uniform{Min: time.Second, Max: time.Second}).Sample(r); d != time.Second {
+		t.Errorf("Expected an empty range to return its min, got %v", d)
+	}
+}
+
+func TestUniformSteps(t *testing.T) {
+	u := Uniform{Min: time.Millisecond, Max: 5 * time.Millisecond, Step: time.Millisecond}
+	r := rand.New(rand.NewPCG(1, 0))
+	counts := make(map[time.Duration]int)
+	for i := 0; i < 5000; i++ {
+		counts[u.Sample(r)]++
+	}
+	if len(counts) != 5 {
+		t.Fatalf("Expected the 5 whole milliseconds in [1ms, 5ms], got %v", counts)
+	}
+	for d, n := range counts {
+		if d%time.Millisecond != 0 || n < 900 || n > 1100 {
+			t.Errorf("Expected whole milliseconds drawn about 1000 times each, got %v", counts)
+			break
+		}
+	}
+	if got := u.CDF(4 * time.Millisecond); got != 0.8 {
+		t.Errorf("Expected CDF 0.8 at 4ms, got %v", got)
+	}
+	if got := u.CDF(4500 * time.Microsecond); got != 0.8 {
+		t.Errorf("Expected CDF 0.8 between steps, got %v", got)
+	}
+	if got := u.Quantile(0.5); got != 3*time.Millisecond {
+		t.Errorf("Expected median 3ms, got %v", got)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	samples := []time.Duration{4, 1, 3, 2}
+	for p, want := range map[float64]time.Duration{0: 1, 50: 2, 100: 4} {
+		if got, err := Percentile(samples, p); err != nil || got != want {
+			t.Errorf("Expected p%v = %v, got %v (%v)", p, want, got, err)
+		}
+	}
+	if _, err := Percentile(nil, 50); err == nil {
+		t.Error("Expected an error for no samples")
+	}
+	if _, err := Percentile(samples, 101); err == nil {
+		t.Error("Expected an error for a percentile above 100")
+	}
+	if got := Percentiles(Constant(time.Second), 50, 99); len(got) != 2 || got[1] != time.Second {
+		t.Errorf("Expected [1s 1s], got %v", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, d := range all {
+		if err := d.Validate(); err != nil {
+			t.Errorf("%v: unexpected error %v", d, err)
+		}
+	}
+	for _, d := range []Distribution{
+		Uniform{Min: 2, Max: 1},
+		Uniform{Max: 1, Step: -1},
+		Constant(-1),
+		Normal{StdDev: -1},
+		LogNormal{Sigma: 1},
+		Exponential{Mean: -1},
+		Pareto{Min: time.Millisecond},
+	} {
+		if err := d.Validate(); err == nil {
+			t.Errorf("%v: expected an error", d)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+// Package flaky provides seeded failure injection for building reproducibly
+// flaky tests
+//
+// The scenarios in this module's _test.go files are built on the same
+// primitives, so other projects can embed identical flaky behavior in their
+// own integration tests:
+//
+//	inj := flaky.NewInjector(flaky.WithSeed(12345))
+//	if err := inj.MaybeFail(0.3); err != nil {
+//		t.Fatal(err)
+//	}
+package flaky
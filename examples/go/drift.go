@@ -0,0 +1,97 @@
+package flaky
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// FirstRunSeed is the suite seed of run 0, the default --seed of flakectl
+// detect, whose run i sets GO_TEST_SEED to FirstRunSeed+i
+const FirstRunSeed int64 = 1
+
+// RunIndex returns the index of the current run among the runs of a
+// detection sweep, derived from GO_TEST_SEED; it is negative for seeds
+// below FirstRunSeed
+func RunIndex() int64 {
+	return SeedFromEnv() - FirstRunSeed
+}
+
+// Drift makes a scenario's failure rate change over successive runs, for
+// validating detectors that assume a flake rate stays the same
+// The rate starts at the scenario's FailureRate and moves towards To
+type Drift struct {
+	// Type is "ramp", a linear change from run Start over Runs runs; "step",
+	// a jump to To at run Start; or "periodic", a cycle of Period runs
+	// from Start that peaks at To halfway through
+	Type string `json:"type" yaml:"type"`
+	// To is the rate the drift moves to
+	To float64 `json:"to" yaml:"to"`
+	// Start is the run the change begins at
+	Start int64 `json:"start,omitempty" yaml:"start,omitempty"`
+	// Runs is the length of a ramp
+	Runs int64 `json:"runs,omitempty" yaml:"runs,omitempty"`
+	// Period is the length of a periodic cycle
+	Period int64 `json:"period,omitempty" yaml:"period,omitempty"`
+}
+
+// Validate reports an unknown type, a To outside [0, 1] and a ramp or
+// cycle without a length
+func (d Drift) Validate() error {
+	if d.To < 0 || d.To > 1 || math.IsNaN(d.To) {
+		return fmt.Errorf("drift to %v outside [0, 1]", d.To)
+	}
+	if d.Start < 0 {
+		return fmt.Errorf("drift start %d is negative", d.Start)
+	}
+	switch strings.ToLower(d.Type) {
+	case "ramp":
+		if d.Runs <= 0 {
+			return fmt.Errorf("ramp drift needs positive runs, got %d", d.Runs)
+		}
+	case "step":
+	case "periodic":
+		if d.Period <= 0 {
+			return fmt.Errorf("periodic drift needs a positive period, got %d", d.Period)
+		}
+	default:
+		return fmt.Errorf("unknown drift type %q, want ramp, step or periodic", d.Type)
+	}
+	return nil
+}
+
+// Rate returns the failure rate at run, drifting from base
+func (d Drift) Rate(base float64, run int64) float64 {
+	if run < d.Start {
+		return base
+	}
+	var progress float64
+	switch strings.ToLower(d.Type) {
+	case "ramp":
+		progress = math.Min(float64(run-d.Start)/float64(d.Runs), 1)
+	case "step":
+		progress = 1
+	case "periodic":
+		phase := float64((run-d.Start)%d.Period) / float64(d.Period)
+		progress = (1 - math.Cos(2*math.Pi*phase)) / 2
+	}
+	return base + (d.To-base)*progress
+}
+
+// AtRun returns the scenario as it behaves at run: a drifting scenario's
+// FailureRate becomes its drifted rate, then an incident-prone one's
+// becomes its incident rate if the run's Health is hit, and Drift and
+// Incident are cleared so the rate is not changed twice
+func (s Scenario) AtRun(run int64) Scenario {
+	if s.Drift != nil {
+		s.FailureRate = s.Drift.Rate(s.FailureRate, run)
+		s.Drift = nil
+	}
+	if s.Incident != nil {
+		if HealthAt(run).Hit(s.Incident.Chance) {
+			s.FailureRate = s.Incident.FailureRate
+		}
+		s.Incident = nil
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package flaky
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+// TestDriftRate verifies the rate of each drift type over the runs
+func TestDriftRate(t *testing.T) {
+	for _, tt := range []struct {
+		drift Drift
+		run   int64
+		want  float64
+	}{
+		{Drift{Type: "ramp", To: 0.5, Start: 10, Runs: 20}, 0, 0.1},
+		{Drift{Type: "ramp", To: 0.5, Start: 10, Runs: 20}, 20, 0.3},
+		{Drift{Type: "ramp", To: 0.5, Start: 10, Runs: 20}, 100, 0.5},
+		{Drift{Type: "step", To: 0.9, Start: 50}, 49, 0.1},
+		{Drift{Type: "step", To: 0.9, Start: 50}, 50, 0.9},
+		{Drift{Type: "periodic", To: 0.5, Period: 10}, 0, 0.1},
+		{Drift{Type: "periodic", To: 0.5, Period: 10}, 5, 0.5},
+		{Drift{Type: "periodic", To: 0.5, Period: 10}, 20, 0.1},
+		{Drift{Type: "ramp", To: 0, Runs: 10}, 5, 0.05},
+	} {
+		if got := tt.drift.Rate(0.1, tt.run); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%+v at run %d: expected %v, got %v", tt.drift, tt.run, tt.want, got)
+		}
+	}
+}
+
+// TestDriftValidate verifies drifts the rate cannot follow are rejected
+func TestDriftValidate(t *testing.T) {
+	for _, d := range []Drift{
+		{Type: "ramp", To: 0.5},
+		{Type: "periodic", To: 0.5},
+		{Type: "step", To: 1.5},
+		{Type: "step", To: 0.5, Start: -1},
+		{Type: "sawtooth", To: 0.5},
+	} {
+		if err := d.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", d)
+		}
+	}
+}
+
+// TestScenarioAtRun verifies a drifting scenario's failures follow its rate
+// at the run GO_TEST_SEED selects
+func TestScenarioAtRun(t *testing.T) {
+	sc := Scenario{Name: "Drift", FailureRate: 0, Drift: &Drift{Type: "step", To: 1, Start: 10}}
+	at := sc.AtRun(10)
+	if at.FailureRate != 1 || at.Drift != nil || at.AtRun(0).FailureRate != 1 {
+		t.Errorf("Expected the step rate, drifted once, got %+v", at)
+	}
+
+	for seed, failed := range map[int64]bool{FirstRunSeed + 9: false, FirstRunSeed + 10: true} {
+		t.Setenv(SeedEnv, strconv.FormatInt(seed, 10))
+		if got := NewInjector(WithSeed(1)).Decide(sc).Failed; got != failed {
+			t.Errorf("Seed %d: expected failed=%v", seed, failed)
+		}
+		if got := sc.EffectiveFailureRate(); got != map[bool]float64{false: 0, true: 1}[failed] {
+			t.Errorf("Seed %d: unexpected effective rate %v", seed, got)
+		}
+	}
+}
+
+// TestDriftFromConfig verifies drift is configured per scenario
+func TestDriftFromConfig(t *testing.T) {
+	r, err := LoadScenarios(writeConfig(t, "drift.yaml", `scenarios:
+  - name: RandomFailure
+    failure_rate: 0.05
+    drift: {type: periodic, to: 0.5, period: 24}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, _ := r.Get("RandomFailure")
+	if sc.Drift == nil || sc.Drift.Period != 24 || sc.AtRun(12).FailureRate != 0.5 {
+		t.Errorf("Expected a periodic drift as written, got %+v", sc.Drift)
+	}
+
+	bad := writeConfig(t, "bad.yaml", "scenarios:\n  - name: RandomFailure\n    drift: {type: ramp, to: 0.5}\n")
+	if _, err := LoadScenarios(bad); err == nil {
+		t.Error("Expected a ramp without runs to be rejected")
+	}
+}
@@ -0,0 +1,129 @@
+package flaky
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/example/flaky-test-example/clock"
+)
+
+// testInjectors holds the injectors ForTest returned for each running test
+var testInjectors struct {
+	mu     sync.Mutex
+	byTest map[testing.TB][]*Injector
+}
+
+// trackInjector registers i as one of t's injectors until t finishes
+func trackInjector(t testing.TB, i *Injector) {
+	testInjectors.mu.Lock()
+	defer testInjectors.mu.Unlock()
+	if testInjectors.byTest == nil {
+		testInjectors.byTest = make(map[testing.TB][]*Injector)
+	}
+	if _, ok := testInjectors.byTest[t]; !ok {
+		t.Cleanup(func() {
+			testInjectors.mu.Lock()
+			defer testInjectors.mu.Unlock()
+			delete(testInjectors.byTest, t)
+		})
+	}
+	testInjectors.byTest[t] = append(testInjectors.byTest[t], i)
+}
+
+func injectorsFor(t testing.TB) []*Injector {
+	testInjectors.mu.Lock()
+	defer testInjectors.mu.Unlock()
+	return append([]*Injector(nil), testInjectors.byTest[t]...)
+}
+
+// Expectation reports failures of t with the context they happened in
+type Expectation struct {
+	t testing.TB
+}
+
+// Expect returns an assertion helper for t whose failures end with the
+// suite and test seeds, the decisions the injectors ForTest returned for t
+// made so far, and the time elapsed on their WithClock clocks
+// The context is taken when an assertion fails, so it shows the decisions
+// that led up to it
+func Expect(t testing.TB) *Expectation {
+	return &Expectation{t: t}
+}
+
+// Errorf fails the test with the message and its context
+func (e *Expectation) Errorf(format string, args ...any) {
+	e.t.Helper()
+	e.t.Error(fmt.Sprintf(format, args...) + "\n" + e.Context())
+}
+
+// Fatalf fails the test with the message and its context and stops it
+func (e *Expectation) Fatalf(format string, args ...any) {
+	e.t.Helper()
+	e.t.Fatal(fmt.Sprintf(format, args...) + "\n" + e.Context())
+}
+
+// True fails the test with the message unless cond holds, reporting whether
+// it did
+func (e *Expectation) True(cond bool, format string, args ...any) bool {
+	e.t.Helper()
+	if !cond {
+		e.Errorf(format, args...)
+	}
+	return cond
+}
+
+// Equal fails the test unless got and want are reflect.DeepEqual, reporting
+// whether they were
+func (e *Expectation) Equal(got, want any) bool {
+	e.t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		e.Errorf("Expected %v, got %v", want, got)
+		return false
+	}
+	return true
+}
+
+// NoError fails the test if err is not nil, naming an injected one as such,
+// and reports whether it was nil
+func (e *Expectation) NoError(err error) bool {
+	e.t.Helper()
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ErrInjected):
+		e.Errorf("Unexpected injected error: %v", err)
+	default:
+		e.Errorf("Unexpected error: %v", err)
+	}
+	return false
+}
+
+// Context returns the lines a failure of the test is reported with
+func (e *Expectation) Context() string {
+	suiteSeed := SeedFromEnv()
+	lines := []string{fmt.Sprintf("    seed: %s=%d, test seed %d", SeedEnv, suiteSeed, SeedFor(suiteSeed, e.t.Name()))}
+	injectors := injectorsFor(e.t)
+	for n, i := range injectors {
+		label := "injector"
+		if len(injectors) > 1 {
+			label = fmt.Sprintf("injector %d", n+1)
+		}
+		decisions := "none"
+		if d := i.Decisions(); len(d) > 0 {
+			decisions = strings.Join(d, ", ")
+		}
+		lines = append(lines, fmt.Sprintf("    %s decisions: %s", label, decisions))
+		if elapsed, ok := i.Elapsed(); ok {
+			kind := "clock"
+			if _, fake := i.clock.(*clock.FakeClock); fake {
+				kind = "fake clock"
+			}
+			lines = append(lines, fmt.Sprintf("    %s %s: %v elapsed", label, kind, elapsed))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,80 @@
+package flaky
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/clock"
+)
+
+// failingTB records the errors reported on it, on top of finishingTB
+type failingTB struct {
+	finishingTB
+	errs []string
+}
+
+func (f *failingTB) Error(args ...any) {
+	f.failed = true
+	f.errs = append(f.errs, fmt.Sprint(args...))
+}
+
+func (f *failingTB) Errorf(format string, args ...any) {
+	f.Error(fmt.Sprintf(format, args...))
+}
+
+func TestExpect(t *testing.T) {
+	t.Setenv(SeedEnv, "7")
+	t.Setenv(FailuresFileEnv, filepath.Join(t.TempDir(), "flaky-failures.json"))
+	tb := &failingTB{finishingTB: finishingTB{TB: t}}
+	clk := clock.NewFake(time.Unix(0, 0))
+	inj := ForTest(tb, WithClock(clk))
+	err := inj.MaybeFail(1)
+	clk.Advance(1500 * time.Millisecond)
+
+	e := Expect(tb)
+	if !e.True(true, "unused") || !e.Equal(1, 1) || !e.NoError(nil) || len(tb.errs) != 0 {
+		t.Fatalf("Expected passing assertions to report nothing, got %q", tb.errs)
+	}
+	if e.NoError(err) {
+		t.Fatal("Expected NoError to fail on an injected error")
+	}
+	e.Equal("eu", "us")
+	if len(tb.errs) != 2 {
+		t.Fatalf("Expected 2 failures, got %q", tb.errs)
+	}
+	for _, want := range []string{
+		"Unexpected injected error: flaky: injected failure",
+		fmt.Sprintf("\n    seed: GO_TEST_SEED=7, test seed %d\n", SeedFor(7, "TestCheckout")),
+		"\n    injector decisions: MaybeFail(1.000) = fail (draw ",
+		"\n    injector fake clock: 1.5s elapsed",
+	} {
+		if !strings.Contains(tb.errs[0], want) {
+			t.Errorf("Expected the failure to contain %q:\n%s", want, tb.errs[0])
+		}
+	}
+	if !strings.HasPrefix(tb.errs[1], "Expected us, got eu\n") {
+		t.Errorf("Expected the values first, got %q", tb.errs[1])
+	}
+
+	// A second injector is numbered, and the test's injectors are forgotten
+	// once it finishes
+	ForTest(tb)
+	if ctx := e.Context(); !strings.Contains(ctx, "injector 2 decisions: none") {
+		t.Errorf("Expected the second injector to be listed:\n%s", ctx)
+	}
+	tb.finish()
+	if ctx := e.Context(); strings.Contains(ctx, "injector") {
+		t.Errorf("Expected no injectors after the test finished:\n%s", ctx)
+	}
+}
+
+func TestExpectWithoutInjectors(t *testing.T) {
+	tb := &failingTB{finishingTB: finishingTB{TB: t}}
+	Expect(tb).Errorf("Expected %d retries", 2)
+	if len(tb.errs) != 1 || !strings.HasPrefix(tb.errs[0], "Expected 2 retries\n    seed: ") || strings.Contains(tb.errs[0], "injector") {
+		t.Errorf("Expected only the seed as context, got %q", tb.errs)
+	}
+}
@@ -0,0 +1,5 @@
+package flaky
+
+// ScenarioForTest exposes scenario to the external tests of package flaky_test,
+// which can import packages such as flakyfs that import flaky
+var ScenarioForTest = scenario
@@ -0,0 +1,126 @@
+package flaky
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/protocol"
+)
+
+// FailuresFileEnv overrides where RecordFailures writes failing seeds
+const FailuresFileEnv = protocol.FailuresFileEnv
+
+// DefaultFailuresFile is the artifact RecordFailures writes when
+// FLAKY_FAILURES_FILE is unset, relative to the package directory
+const DefaultFailuresFile = "flaky-failures.json"
+
+// FailureRecord identifies a failing test and the conditions needed to
+// reproduce it
+type FailureRecord struct {
+	Test     string            `json:"test"`
+	Seed     int64             `json:"seed"`
+	TestSeed int64             `json:"test_seed"`
+	Dir      string            `json:"dir,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	Time     time.Time         `json:"time"`
+}
+
+var recorder struct {
+	mu       sync.Mutex
+	failures []FailureRecord
+}
+
+// recordFailure notes a failing test that drew from a seeded source
+func recordFailure(t testing.TB, suiteSeed int64) {
+	dir, _ := os.Getwd()
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.failures = append(recorder.failures, FailureRecord{
+		Test:     t.Name(),
+		Seed:     suiteSeed,
+		TestSeed: SeedFor(suiteSeed, t.Name()),
+		Dir:      dir,
+		Env:      reproducibleEnv(),
+		Time:     time.Now().UTC(),
+	})
+}
+
+// reproducibleEnv captures environment variables that influence scenarios
+func reproducibleEnv() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if key == "TZ" || key == "GOMAXPROCS" || strings.HasPrefix(key, "FLAKY_") {
+			env[key] = value
+		}
+	}
+	if len(env) == 0 {
+		return nil
+	}
+	return env
+}
+
+// RecordFailures runs the tests and appends a FailureRecord for every failing
+// test that used ForTest or Rand to the failures artifact
+// Use it from TestMain:
+//
+//	func TestMain(m *testing.M) { os.Exit(flaky.RecordFailures(m)) }
+func RecordFailures(m *testing.M) int {
+	code := m.Run()
+
+	recorder.mu.Lock()
+	failures := recorder.failures
+	recorder.failures = nil
+	recorder.mu.Unlock()
+
+	if len(failures) > 0 {
+		if err := AppendFailures(FailuresFile(), failures); err != nil {
+			fmt.Fprintf(os.Stderr, "flaky: recording failures: %v\n", err)
+		}
+	}
+	return code
+}
+
+// FailuresFile returns the artifact path from FLAKY_FAILURES_FILE or the default
+func FailuresFile() string {
+	if path := os.Getenv(FailuresFileEnv); path != "" {
+		return path
+	}
+	return DefaultFailuresFile
+}
+
+// LoadFailures reads a failures artifact; a missing file has no failures
+func LoadFailures(path string) ([]FailureRecord, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var failures []FailureRecord
+	if err := json.Unmarshal(data, &failures); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return failures, nil
+}
+
+// AppendFailures adds failures to the artifact at path, creating it if needed
+func AppendFailures(path string, failures []FailureRecord) error {
+	existing, err := LoadFailures(path)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(append(existing, failures...), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,64 @@
+package flaky
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAppendAndLoadFailures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failures.json")
+
+	if failures, err := LoadFailures(path); err != nil || len(failures) != 0 {
+		t.Fatalf("Expected no failures from a missing file, got %v, %v", failures, err)
+	}
+
+	first := []FailureRecord{{Test: "TestA", Seed: 1, TestSeed: SeedFor(1, "TestA")}}
+	second := []FailureRecord{{Test: "TestB", Seed: 2, Env: map[string]string{"TZ": "UTC"}}}
+	if err := AppendFailures(path, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendFailures(path, second); err != nil {
+		t.Fatal(err)
+	}
+
+	failures, err := LoadFailures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 2 || failures[0].Test != "TestA" || failures[1].Env["TZ"] != "UTC" {
+		t.Errorf("Unexpected failures after two appends: %+v", failures)
+	}
+}
+
+func TestRecordFailureCapturesSeedAndEnv(t *testing.T) {
+	t.Setenv("FLAKY_STRICT_MARGIN", "0.05")
+	t.Setenv("TZ", "Asia/Tokyo")
+
+	recordFailure(namedTB{TB: t, name: "TestRecorded"}, 99)
+
+	recorder.mu.Lock()
+	got := recorder.failures[len(recorder.failures)-1]
+	recorder.failures = recorder.failures[:len(recorder.failures)-1]
+	recorder.mu.Unlock()
+
+	if got.Test != "TestRecorded" || got.Seed != 99 || got.TestSeed != SeedFor(99, "TestRecorded") {
+		t.Errorf("Unexpected record: %+v", got)
+	}
+	if got.Env["FLAKY_STRICT_MARGIN"] != "0.05" || got.Env["TZ"] != "Asia/Tokyo" {
+		t.Errorf("Expected scenario environment to be captured, got %v", got.Env)
+	}
+	if got.Dir == "" {
+		t.Error("Expected the package directory to be recorded")
+	}
+}
+
+func TestFailuresFileFromEnv(t *testing.T) {
+	t.Setenv(FailuresFileEnv, "")
+	if got := FailuresFile(); got != DefaultFailuresFile {
+		t.Errorf("Expected default %s, got %s", DefaultFailuresFile, got)
+	}
+	t.Setenv(FailuresFileEnv, "/tmp/custom.json")
+	if got := FailuresFile(); got != "/tmp/custom.json" {
+		t.Errorf("Expected /tmp/custom.json, got %s", got)
+	}
+}
@@ -0,0 +1,158 @@
+package flaky
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Outcome is what a scenario does to one run
+type Outcome struct {
+	// Failed reports whether the run fails
+	Failed bool
+	// Delay is the latency injected before the outcome, zero for none
+	Delay time.Duration
+	// Message says why the run failed
+	Message string
+}
+
+// String describes the outcome without its message, such as "fail after 3ms"
+func (o Outcome) String() string {
+	verdict := "ok"
+	if o.Failed {
+		verdict = "fail"
+	}
+	if o.Delay > 0 {
+		return verdict + " after " + o.Delay.String()
+	}
+	return verdict
+}
+
+// FaultInjector is a custom failure scenario
+// Registered with RegisterInjector, it becomes a scenario of its name in
+// DefaultScenarios, so config files can tune and reuse it, Registry.Names
+// lists it and its failures are reported like the built-in ones
+type FaultInjector interface {
+	// Name is the scenario name, such as "SlowDisk"
+	Name() string
+	// Decide draws the outcome of one run from r alone, so runs with the
+	// same seed decide the same
+	Decide(r *rand.Rand) Outcome
+	// Describe says what the injector simulates, and is the default failure
+	// message of its scenario
+	Describe() string
+}
+
+var (
+	faultsMu sync.RWMutex
+	faults   = make(map[string]FaultInjector)
+)
+
+// rateSamples is how many seeded decisions estimate a FaultInjector's rate
+const rateSamples = 10000
+
+// RegisterInjector makes f available as a scenario, usually from an init
+// function of the package defining it
+// It panics if f is nil, has no name, or takes the name of a built-in
+// scenario or of another registered injector
+func RegisterInjector(f FaultInjector) {
+	if f == nil || f.Name() == "" {
+		panic("flaky: RegisterInjector of a nil or unnamed injector")
+	}
+	name := f.Name()
+	if _, ok := DefaultScenarios().Get(name); ok {
+		panic(fmt.Sprintf("flaky: RegisterInjector: scenario %s already exists", name))
+	}
+	faultsMu.Lock()
+	defer faultsMu.Unlock()
+	if _, ok := faults[name]; ok {
+		panic(fmt.Sprintf("flaky: RegisterInjector: scenario %s already exists", name))
+	}
+	faults[name] = f
+}
+
+// unregisterInjector removes a registered injector, for tests that register
+// their own
+func unregisterInjector(name string) {
+	faultsMu.Lock()
+	defer faultsMu.Unlock()
+	delete(faults, name)
+}
+
+// LookupInjector returns the registered injector of the given name
+func LookupInjector(name string) (FaultInjector, bool) {
+	faultsMu.RLock()
+	defer faultsMu.RUnlock()
+	f, ok := faults[name]
+	return f, ok
+}
+
+// InjectorNames returns the names of the registered injectors in sorted order
+func InjectorNames() []string {
+	faultsMu.RLock()
+	defer faultsMu.RUnlock()
+	names := make([]string, 0, len(faults))
+	for name := range faults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// estimateRate returns the fraction of rateSamples seeded decisions of f
+// that fail; the fixed seed keeps the estimate the same on every call
+func estimateRate(f FaultInjector) float64 {
+	r := rand.New(PCG(1))
+	failed := 0
+	for i := 0; i < rateSamples; i++ {
+		if f.Decide(r).Failed {
+			failed++
+		}
+	}
+	return float64(failed) / rateSamples
+}
+
+// Decide draws the outcome of one run of s
+// A scenario with an Injector asks it; any other fails with FailureRate, as
+// drifted to RunIndex, and draws its delay from Latency, failing too when
+// the delay exceeds Timeout
+// Unknown injectors fail the run saying so
+func (i *Injector) Decide(s Scenario) Outcome {
+	if s.Injector != "" {
+		f, ok := LookupInjector(s.Injector)
+		if !ok {
+			return Outcome{Failed: true, Message: fmt.Sprintf("scenario %s: injector %s is not registered", s.Name, s.Injector)}
+		}
+		i.mu.Lock()
+		out := f.Decide(i.rng)
+		i.decide(decision{op: "Decide", scenario: s, outcome: out})
+		i.mu.Unlock()
+		if out.Failed && out.Message == "" {
+			out.Message = s.Message
+		}
+		return out
+	}
+
+	s = s.AtRun(RunIndex())
+	out := Outcome{Message: s.Message}
+	if d := s.LatencyDistribution(); d != nil {
+		out.Delay = i.Draw(d)
+	}
+	out.Failed = i.Float64() < s.FailureRate || (s.Timeout > 0 && out.Delay > time.Duration(s.Timeout))
+	return out
+}
+
+// Inject decides one run of s, sleeps for its delay and returns an error
+// wrapping ErrInjected if it fails
+func (i *Injector) Inject(s Scenario) error {
+	out := i.Decide(s)
+	if out.Delay > 0 {
+		i.sleep(out.Delay)
+	}
+	if !out.Failed {
+		return nil
+	}
+	return fmt.Errorf("%w: %s: %s", ErrInjected, s.Name, out.Message)
+}
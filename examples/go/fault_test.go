@@ -0,0 +1,129 @@
+package flaky
+
+import (
+	"errors"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// slowDisk fails a fifth of its runs and delays the others
+type slowDisk struct{}
+
+func (slowDisk) Name() string     { return "SlowDisk" }
+func (slowDisk) Describe() string { return "Disk write stalled" }
+
+func (slowDisk) Decide(r *rand.Rand) Outcome {
+	if r.Float64() < 0.2 {
+		return Outcome{Failed: true}
+	}
+	return Outcome{Delay: time.Duration(r.IntN(10)) * time.Millisecond}
+}
+
+// registerSlowDisk registers slowDisk for the rest of the test
+func registerSlowDisk(t *testing.T) {
+	t.Helper()
+	RegisterInjector(slowDisk{})
+	t.Cleanup(func() { unregisterInjector("SlowDisk") })
+}
+
+func TestRegisterInjectorAddsScenario(t *testing.T) {
+	registerSlowDisk(t)
+	r := DefaultScenarios()
+	if !slices.Contains(r.Names(), "SlowDisk") {
+		t.Fatalf("Expected SlowDisk among %v", r.Names())
+	}
+	s, _ := r.Get("SlowDisk")
+	if s.Injector != "SlowDisk" || s.Message != "Disk write stalled" {
+		t.Errorf("Unexpected scenario %+v", s)
+	}
+	if rate := r.FailureRates()["SlowDisk"]; rate < 0.18 || rate > 0.22 {
+		t.Errorf("Expected an estimated rate near 0.2, got %v", rate)
+	}
+	if meta := s.Meta(nil); meta.Params["injector_description"] != "Disk write stalled" {
+		t.Errorf("Expected the description in the failure metadata, got %v", meta.Params)
+	}
+}
+
+func TestRegisterInjectorRejectsDuplicates(t *testing.T) {
+	registerSlowDisk(t)
+	for _, f := range []FaultInjector{slowDisk{}, named("RandomFailure"), nil, named("")} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected RegisterInjector(%v) to panic", f)
+				}
+			}()
+			RegisterInjector(f)
+		}()
+	}
+}
+
+// named is an injector that never fails
+type named string
+
+func (n named) Name() string              { return string(n) }
+func (n named) Describe() string          { return "never fails" }
+func (n named) Decide(*rand.Rand) Outcome { return Outcome{} }
+
+func TestInjectorDecideIsSeeded(t *testing.T) {
+	registerSlowDisk(t)
+	s, _ := DefaultScenarios().Get("SlowDisk")
+	a, b := NewInjector(WithSeed(7)), NewInjector(WithSeed(7))
+	for i := 0; i < 50; i++ {
+		if x, y := a.Decide(s), b.Decide(s); x != y {
+			t.Fatalf("Draw %d: expected equal outcomes for one seed, got %+v and %+v", i, x, y)
+		}
+	}
+}
+
+func TestInjectorInject(t *testing.T) {
+	registerSlowDisk(t)
+	s, _ := DefaultScenarios().Get("SlowDisk")
+	var slept time.Duration
+	inj := NewInjector(WithSeed(3), WithSleep(func(d time.Duration) { slept += d }))
+	failed := 0
+	for i := 0; i < 200; i++ {
+		if err := inj.Inject(s); err != nil {
+			if !errors.Is(err, ErrInjected) {
+				t.Fatalf("Expected an error wrapping ErrInjected, got %v", err)
+			}
+			failed++
+		}
+	}
+	if failed == 0 || failed == 200 || slept == 0 {
+		t.Errorf("Expected some failures and delays, got %d failures and %v slept", failed, slept)
+	}
+
+	builtin := Scenario{Name: "Fixed", FailureRate: 1, Message: "always"}
+	if err := inj.Inject(builtin); err == nil || err.Error() != "flaky: injected failure: Fixed: always" {
+		t.Errorf("Expected the built-in scenario to fail, got %v", err)
+	}
+}
+
+func TestLoadScenariosInjector(t *testing.T) {
+	registerSlowDisk(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "flaky.yaml")
+	config := "scenarios:\n  - name: SlowUploads\n    injector: SlowDisk\n    message: Upload stalled\n"
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadScenarios(path)
+	if err != nil {
+		t.Fatalf("LoadScenarios failed: %v", err)
+	}
+	if s, ok := r.Get("SlowUploads"); !ok || s.Injector != "SlowDisk" || s.Message != "Upload stalled" {
+		t.Errorf("Expected SlowUploads to use SlowDisk, got %+v", s)
+	}
+
+	if err := os.WriteFile(path, []byte("scenarios:\n  - name: X\n    injector: Missing\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadScenarios(path); err == nil {
+		t.Error("Expected an error for an unregistered injector")
+	}
+}
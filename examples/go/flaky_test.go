@@ -1,104 +1,248 @@
 package flaky
 
 import (
-	"math/rand"
-	"os"
-	"strconv"
+	"fmt"
+	"runtime"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/example/flaky-test-example/clock"
 )
 
-// Initialize random seed from GO_TEST_SEED environment variable
-func init() {
-	seed := int64(42) // default seed
-	if seedStr := os.Getenv("GO_TEST_SEED"); seedStr != "" {
-		if parsedSeed, err := strconv.ParseInt(seedStr, 10, 64); err == nil {
-			seed = parsedSeed
-		}
+var (
+	registryOnce sync.Once
+	registry     *Registry
+	registryErr  error
+)
+
+// scenario returns the named scenario from FLAKY_CONFIG, flaky.yaml or the
+// defaults as it behaves in this run, skipping the test if it is quarantined
+// or the preset or the scenario selection disables the scenario
+func scenario(t *testing.T, name string) Scenario {
+	t.Helper()
+	SkipIfQuarantined(t)
+	registryOnce.Do(func() { registry, registryErr = ScenariosFromEnv() })
+	if registryErr != nil {
+		t.Fatalf("Loading scenarios: %v", registryErr)
+	}
+	s, ok := registry.Get(name)
+	if !ok {
+		t.Fatalf("Unknown scenario %s", name)
 	}
-	rand.Seed(seed)
+	if s.Disabled {
+		t.Skipf("Scenario %s: %s", name, registry.DisabledBy(name))
+	}
+	return s.AtRun(RunIndex())
 }
 
 // TestRandomFailure demonstrates a test that fails randomly (~30% of the time)
 // This simulates race conditions or non-deterministic behavior
 func TestRandomFailure(t *testing.T) {
-	value := rand.Float64()
+	inj := ForTest(t)
+	sc := scenario(t, "RandomFailure")
+
+	value := inj.Float64()
+	limit := 1 - sc.FailureRate
+	Report(t, sc.Meta(map[string]any{"value": value}))
 
-	// Fails when value > 0.7
-	if value > 0.7 {
-		t.Errorf("Random failure: got %.3f, expected <= 0.7", value)
+	// Fails when value > 0.7 (with the default 30% failure rate)
+	if value > limit {
+		t.Errorf("%s: got %.3f, expected <= %.1f", sc.Message, value, limit)
 	}
+	checkNearMiss(t, randomFailureThreshold.at(limit), value)
 }
 
 // TestTimingDependent demonstrates a test that depends on timing
 // This simulates timeout issues or performance-dependent tests
+// Latency is simulated on a fake clock, so the test is instant and the outcome depends only on the seed
 func TestTimingDependent(t *testing.T) {
-	// Simulate variable processing time
-	delay := time.Duration(rand.Intn(5)+1) * time.Millisecond
-	time.Sleep(delay)
+	clk := clock.NewFake(time.Unix(0, 0))
+	inj := ForTest(t, WithClock(clk))
+	sc := scenario(t, "TimingDependent")
+	latency := sc.LatencyDistribution()
+	timeout := time.Duration(sc.Timeout)
+
+	// Simulate variable processing time (1-5ms by default) on the fake clock
+	start := clk.Now()
+	inj.Delay(latency)
+	elapsed := clk.Since(start)
+	Report(t, sc.Meta(map[string]any{"elapsed": elapsed.String()}))
 
-	// Fails if processing takes "too long" (> 4ms)
-	if delay > 4*time.Millisecond {
-		t.Errorf("Operation too slow: %v", delay)
+	// Fails if processing takes "too long" (> 4ms by default)
+	if elapsed > timeout {
+		t.Errorf("%s: %v", sc.Message, elapsed)
 	}
+	checkNearMiss(t, timingThreshold.scaled(timeout, delaySpan(latency)), float64(elapsed)/float64(time.Millisecond))
 }
 
 // TestOrderDependency demonstrates a test that depends on execution order
 // This simulates shared state issues
 func TestOrderDependency(t *testing.T) {
+	inj := ForTest(t)
+	sc := scenario(t, "OrderDependency")
+
 	var items []string
 
 	// Simulate checking a cache that may or may not have items
-	if rand.Float64() > 0.5 {
+	if inj.Float64() < sc.FailureRate {
 		items = append(items, "existing_item")
 	}
 
 	// Fails when cache is unexpectedly populated
 	if len(items) != 0 {
-		t.Errorf("Expected empty cache, found %d items", len(items))
+		t.Errorf("%s, found %d items", sc.Message, len(items))
+	}
+}
+
+// defaultCurrency is package state that TestCurrencyOverride forgets to restore
+var defaultCurrency = "USD"
+
+func init() {
+	RegisterGlobal("defaultCurrency", &defaultCurrency)
+}
+
+func formatPrice(cents int) string {
+	return fmt.Sprintf("%d.%02d %s", cents/100, cents%100, defaultCurrency)
+}
+
+// TestPriceFormatting demonstrates a victim of real shared state pollution
+// It passes in declaration order and fails whenever -shuffle runs TestCurrencyOverride first
+func TestPriceFormatting(t *testing.T) {
+	if got := formatPrice(1999); got != "19.99 USD" {
+		t.Errorf("Expected 19.99 USD, got %s", got)
+	}
+}
+
+// TestCurrencyOverride demonstrates a polluter: it changes package state and never restores it
+// It always passes itself; flakectl bisect-order traces TestPriceFormatting's failures back to it
+func TestCurrencyOverride(t *testing.T) {
+	defaultCurrency = "EUR"
+	if got := formatPrice(500); got != "5.00 EUR" {
+		t.Errorf("Expected 5.00 EUR, got %s", got)
 	}
 }
 
 // TestBoundaryCondition demonstrates a test at boundary conditions
 // This simulates off-by-one errors
 func TestBoundaryCondition(t *testing.T) {
+	inj := ForTest(t)
+	scenario(t, "BoundaryCondition") // fails at the rate of the draw below
+
 	// Simulate calculating a threshold
-	calculatedValue := rand.Intn(5) + 98 // Range: 98-102
+	calculatedValue := inj.Intn(5) + 98 // Range: 98-102
 	threshold := 100
 
 	// Fails when value exceeds threshold
 	if calculatedValue > threshold {
 		t.Errorf("Value %d exceeds threshold %d", calculatedValue, threshold)
 	}
-}
-
-// TestConcurrentAccess demonstrates concurrent access patterns
-// This simulates race conditions with shared resources
-func TestConcurrentAccess(t *testing.T) {
-	// Simulate checking if resource is locked
-	isLocked := rand.Float64() > 0.5
-
-	// Fails when resource is locked
-	if isLocked {
-		t.Error("Resource is locked by another process")
-	}
+	checkNearMiss(t, boundaryThreshold, float64(calculatedValue))
 }
 
 // TestNetworkSimulation demonstrates network flakiness
 // This simulates unreliable network conditions
 func TestNetworkSimulation(t *testing.T) {
+	inj := ForTest(t)
+	sc := scenario(t, "NetworkSimulation")
+
 	// Simulate network response success rate
-	successRate := rand.Float64()
+	successRate := inj.Float64()
+
+	// Fails 20% of the time by default (simulating network issues)
+	if successRate <= sc.FailureRate {
+		t.Errorf("%s: %.3f", sc.Message, successRate)
+	}
+	checkNearMiss(t, networkThreshold.at(sc.FailureRate), successRate)
+}
+
+// TestNetworkSimulationWithRetry shows flaky.Retry absorbing transient network failures
+// It flaky-passes when a retry succeeds and only fails if all 3 attempts fail (~0.8%)
+func TestNetworkSimulationWithRetry(t *testing.T) {
+	inj := ForTest(t)
+	sc := scenario(t, "NetworkSimulation")
+
+	Retry(t, 3, func(t testing.TB) {
+		if successRate := inj.Float64(); successRate <= sc.FailureRate {
+			t.Errorf("%s: %.3f", sc.Message, successRate)
+		}
+	})
+}
+
+// burstWindow is how many preceding suite seeds TestBurstyFailure walks its
+// chain over; enough for the chain to forget its passing start state
+const burstWindow = 20
+
+// TestBurstyFailure demonstrates failures that come in bursts
+// This simulates a bad node that keeps failing runs until it recovers: the
+// chain is walked over the preceding suite seeds, so the consecutive seeds of
+// flakectl detect see correlated outcomes
+func TestBurstyFailure(t *testing.T) {
+	sc := scenario(t, "BurstyFailure")
+	chain := NewBurstyFailer(sc.FailureRate, sc.FailAfterFail)
+
+	suiteSeed := SeedFromEnv()
+	failures := 0
+	for seed := suiteSeed - burstWindow; seed < suiteSeed; seed++ {
+		if chain.Next(NewInjector(WithSeed(SeedFor(seed, t.Name())))) != nil {
+			failures++
+		}
+	}
+	if err := chain.Next(ForTest(t)); err != nil {
+		t.Errorf("%s: %d of the previous %d runs also failed", sc.Message, failures, burstWindow)
+	}
+}
+
+// TestDriftingFailure demonstrates a flake rate that changes between runs
+// This simulates a dependency degrading over a sweep: the scenario fails 2% of
+// the first runs and ramps to 40% by run 100, so a detector that estimates one
+// rate from the early runs underestimates the late ones
+func TestDriftingFailure(t *testing.T) {
+	inj := ForTest(t)
+	sc := scenario(t, "DriftingFailure")
+	run := RunIndex()
+	Report(t, sc.Meta(map[string]any{"run": run}))
+
+	if err := inj.MaybeFail(sc.FailureRate); err != nil {
+		t.Errorf("%s at run %d: %v", sc.Message, run, err)
+	}
+}
+
+// TestSharedDatabase demonstrates a test that fails with its environment
+// This simulates an infrastructure-wide incident: on its own the test fails
+// 2% of runs, but in the ~10% of runs a shared database is degraded it fails
+// 90% of them, and TestSharedCache fails in those same runs
+func TestSharedDatabase(t *testing.T) {
+	inj := ForTest(t)
+	sc := scenario(t, "SharedDatabase")
+	health := HealthAt(RunIndex())
+	Report(t, sc.Meta(map[string]any{"health": health.Level}))
 
-	// Fails 20% of the time (simulating network issues)
-	if successRate <= 0.2 {
-		t.Errorf("Network request failed: %.3f", successRate)
+	if err := inj.MaybeFail(sc.FailureRate); err != nil {
+		t.Errorf("%s at run %d: %v", sc.Message, health.Run, err)
+	}
+}
+
+// TestSharedCache demonstrates a second test hit by the same incidents as
+// TestSharedDatabase, so the two fail together far more often than their
+// rates alone would have them
+func TestSharedCache(t *testing.T) {
+	inj := ForTest(t)
+	sc := scenario(t, "SharedCache")
+	health := HealthAt(RunIndex())
+	Report(t, sc.Meta(map[string]any{"health": health.Level}))
+
+	if err := inj.MaybeFail(sc.FailureRate); err != nil {
+		t.Errorf("%s at run %d: %v", sc.Message, health.Run, err)
 	}
 }
 
 // TestMapIteration demonstrates non-deterministic map iteration
 // Go maps have random iteration order
 func TestMapIteration(t *testing.T) {
+	inj := ForTest(t)
+	scenario(t, "MapIteration") // fails at the rate of the draw below
+
 	m := map[string]int{
 		"a": 1,
 		"b": 2,
@@ -115,7 +259,7 @@ func TestMapIteration(t *testing.T) {
 	// This test is intentionally flaky - map iteration order is random
 	// But with seeded random, we can make it more predictable
 	expectedKeys := []string{"a", "b", "c"}
-	expected := expectedKeys[rand.Intn(len(expectedKeys))]
+	expected := expectedKeys[inj.Intn(len(expectedKeys))]
 
 	if firstKey != expected {
 		t.Errorf("Expected first key to be %s, got %s", expected, firstKey)
@@ -125,10 +269,13 @@ func TestMapIteration(t *testing.T) {
 // TestChannelRace demonstrates channel race conditions
 // This simulates timing issues with goroutines
 func TestChannelRace(t *testing.T) {
+	inj := ForTest(t)
+
+	sc := scenario(t, "ChannelRace")
 	ch := make(chan int, 1)
 
 	// Randomly decide to send or not
-	if rand.Float64() > 0.5 {
+	if inj.Float64() >= sc.FailureRate {
 		ch <- 1
 	}
 
@@ -139,6 +286,133 @@ func TestChannelRace(t *testing.T) {
 			t.Errorf("Unexpected value: %d", val)
 		}
 	case <-time.After(1 * time.Millisecond):
-		t.Error("Channel receive timeout - no value sent")
+		t.Error(sc.Message)
+	}
+}
+
+// TestUnbufferedChannelSend demonstrates the unbuffered-channel-default pitfall
+// A select with a default branch only delivers if a receiver is already waiting
+func TestUnbufferedChannelSend(t *testing.T) {
+	inj := ForTest(t)
+
+	ch := make(chan int)
+
+	sc := scenario(t, "UnbufferedChannelSend")
+
+	// Simulate a receiver goroutine that is sometimes slow to start
+	receiverDelay := time.Duration(0)
+	if inj.Float64() < sc.FailureRate {
+		receiverDelay = 2 * time.Millisecond
+	}
+	stop := startReceiver(ch, receiverDelay)
+	defer close(stop)
+
+	// Give the receiver a moment to reach its receive (may or may not be enough)
+	time.Sleep(500 * time.Microsecond)
+
+	// Fails when the default branch is taken because no receiver was ready
+	if !trySend(ch, 1) {
+		t.Error(sc.Message)
+	}
+}
+
+// TestUnbufferedChannelSendFixed is the reliable variant of TestUnbufferedChannelSend
+// The receiver goroutine is started first and the send blocks until it is taken
+func TestUnbufferedChannelSendFixed(t *testing.T) {
+	inj := ForTest(t)
+	sc := scenario(t, "UnbufferedChannelSend")
+
+	receiverDelay := time.Duration(0)
+	if inj.Float64() < sc.FailureRate {
+		receiverDelay = 2 * time.Millisecond
+	}
+
+	if got := sendToReceiver(1, receiverDelay); got != 1 {
+		t.Errorf("Unexpected value: %d", got)
+	}
+}
+
+// trySend performs a non-blocking send, reporting whether a receiver took the value
+func trySend(ch chan<- int, v int) bool {
+	select {
+	case ch <- v:
+		return true
+	default:
+		return false
+	}
+}
+
+// startReceiver starts a goroutine that waits delay and then receives from ch
+// until stop is closed
+func startReceiver(ch <-chan int, delay time.Duration) chan<- struct{} {
+	stop := make(chan struct{})
+	go func() {
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		select {
+		case <-ch:
+		case <-stop:
+		}
+	}()
+	return stop
+}
+
+// sendToReceiver starts a receiver goroutine before sending and blocks on the
+// send, so delivery does not depend on whether the receiver is already waiting
+func sendToReceiver(v int, receiverDelay time.Duration) int {
+	ch := make(chan int)
+	result := make(chan int, 1)
+	go func() {
+		if receiverDelay > 0 {
+			time.Sleep(receiverDelay)
+		}
+		result <- <-ch
+	}()
+	ch <- v
+	return <-result
+}
+
+// TestTrySendWithoutReceiverTakesDefault verifies the flaky path deterministically:
+// with no receiver goroutine, the default branch is always taken
+func TestTrySendWithoutReceiverTakesDefault(t *testing.T) {
+	ch := make(chan int)
+	for i := 0; i < 100; i++ {
+		if trySend(ch, i) {
+			t.Fatalf("trySend delivered value %d with no receiver", i)
+		}
+	}
+}
+
+// TestTrySendDeliversToWaitingReceiver verifies that the non-blocking send only
+// succeeds once the receiver goroutine is waiting on the channel
+func TestTrySendDeliversToWaitingReceiver(t *testing.T) {
+	ch := make(chan int)
+	received := make(chan int, 1)
+	go func() {
+		received <- <-ch
+	}()
+
+	// Retry until the receiver is parked; each failed attempt is the default path
+	deadline := time.Now().Add(time.Second)
+	for !trySend(ch, 7) {
+		if time.Now().After(deadline) {
+			t.Fatal("receiver never became ready")
+		}
+		runtime.Gosched()
+	}
+
+	if got := <-received; got != 7 {
+		t.Errorf("Expected 7, got %d", got)
+	}
+}
+
+// TestSendToReceiverAlwaysDelivers verifies the fixed variant delivers regardless
+// of how long the receiver takes to start
+func TestSendToReceiverAlwaysDelivers(t *testing.T) {
+	for _, delay := range []time.Duration{0, time.Millisecond, 5 * time.Millisecond} {
+		if got := sendToReceiver(42, delay); got != 42 {
+			t.Errorf("delay %v: expected 42, got %d", delay, got)
+		}
 	}
 }
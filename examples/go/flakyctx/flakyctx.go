@@ -0,0 +1,237 @@
+// Package flakyctx injects seeded cancellations and shortened deadlines into
+// contexts, for testing the code paths that handle them
+//
+// Chaos.Wrap derives a context that, with a seeded probability, is canceled
+// or reaches a shortened deadline a drawn delay later, as a client giving up
+// or a tight upstream deadline would. The delay runs on a clock, and Err
+// compares against it directly, so with a clock.FakeClock the operation
+// sees the cancellation at exactly the same point on every run of a seed:
+//
+//	clk := clock.NewFake(time.Now())
+//	chaos := flakyctx.New(flaky.ForTest(t), flakyctx.Profile{
+//		Cancel:        0.1,
+//		ShortDeadline: 0.1,
+//		After:         distributions.Uniform{Max: 10 * time.Millisecond},
+//	}, clk)
+//	ctx, cancel := chaos.Wrap(ctx)
+//	defer cancel()
+package flakyctx
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/clock"
+	"github.com/example/flaky-test-example/distributions"
+)
+
+// Fault is what a wrapped context has injected into it
+type Fault string
+
+const (
+	None          Fault = "none"
+	Canceled      Fault = "canceled"
+	ShortDeadline Fault = "short_deadline"
+)
+
+// defaultAfter is the delay distribution when Profile.After is nil
+var defaultAfter = distributions.Uniform{Max: 10 * time.Millisecond}
+
+// Profile sets the probability of each fault and when it strikes
+// At most one fault is injected per wrapped context, so the rates must sum
+// to at most 1
+type Profile struct {
+	// Cancel cancels the context, as a caller that gave up does
+	Cancel float64
+	// ShortDeadline gives the context a deadline, earlier than its parent's,
+	// at which it expires with context.DeadlineExceeded
+	ShortDeadline float64
+
+	// After is the delay from Wrap to the fault (default uniform 0-10ms)
+	After distributions.Distribution
+}
+
+// Validate reports rates outside [0, 1], rates that sum to more than 1 and
+// an invalid delay distribution
+func (p Profile) Validate() error {
+	for _, fr := range p.rates() {
+		if fr.rate < 0 || fr.rate > 1 {
+			return fmt.Errorf("flakyctx: %s rate %v outside [0, 1]", fr.fault, fr.rate)
+		}
+	}
+	if sum := p.Cancel + p.ShortDeadline; sum > 1 {
+		return fmt.Errorf("flakyctx: fault rates sum to %v, more than 1", sum)
+	}
+	if p.After != nil {
+		if err := p.After.Validate(); err != nil {
+			return fmt.Errorf("flakyctx: after distribution: %w", err)
+		}
+	}
+	return nil
+}
+
+type faultRate struct {
+	fault Fault
+	rate  float64
+}
+
+func (p Profile) rates() []faultRate {
+	return []faultRate{{Canceled, p.Cancel}, {ShortDeadline, p.ShortDeadline}}
+}
+
+// pick maps a draw in [0, 1) onto a fault, partitioning the unit interval by
+// the rates in order
+func (p Profile) pick(draw float64) Fault {
+	var upper float64
+	for _, fr := range p.rates() {
+		upper += fr.rate
+		if draw < upper {
+			return fr.fault
+		}
+	}
+	return None
+}
+
+func (p Profile) after() distributions.Distribution {
+	if p.After == nil {
+		return defaultAfter
+	}
+	return p.After
+}
+
+// FaultError is the cause of an injected cancellation, returned by
+// context.Cause
+// It matches flaky.ErrInjected and the context error with errors.Is, so
+// context.Canceled and context.DeadlineExceeded checks behave as they would
+// for a real cancellation
+type FaultError struct {
+	Fault Fault
+	// After is how long after Wrap the fault struck
+	After time.Duration
+	Err   error
+}
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("flakyctx: injected %s after %v: %v", e.Fault, e.After, e.Err)
+}
+
+// Unwrap returns flaky.ErrInjected and the context error
+func (e *FaultError) Unwrap() []error {
+	return []error{flaky.ErrInjected, e.Err}
+}
+
+// Chaos wraps contexts with the faults of a Profile
+// It is safe for concurrent use, but concurrent wraps consume draws in
+// scheduling order; wrap sequentially for exact replay
+type Chaos struct {
+	Profile Profile
+
+	inj    *flaky.Injector
+	clk    clock.Clock
+	mu     sync.Mutex
+	counts map[Fault]int
+}
+
+// New returns a Chaos drawing its faults from inj and timing them on clk
+// (the real clock when nil)
+// It panics if the profile is invalid
+func New(inj *flaky.Injector, profile Profile, clk clock.Clock) *Chaos {
+	if err := profile.Validate(); err != nil {
+		panic(err)
+	}
+	if clk == nil {
+		clk = clock.Real()
+	}
+	return &Chaos{Profile: profile, inj: inj, clk: clk, counts: make(map[Fault]int)}
+}
+
+// Counts returns how many wrapped contexts saw each fault, including None
+func (c *Chaos) Counts() map[Fault]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[Fault]int, len(c.counts))
+	for f, n := range c.counts {
+		counts[f] = n
+	}
+	return counts
+}
+
+// Wrap returns a child of parent that a drawn fault may cancel or expire,
+// and the function that cancels it like context.WithCancel's
+// A context without a fault only ends with parent or the cancel function
+func (c *Chaos) Wrap(parent context.Context) (context.Context, context.CancelFunc) {
+	fault := c.Profile.pick(c.inj.Float64())
+	var after time.Duration
+	if fault != None {
+		after = c.inj.Draw(c.Profile.after())
+	}
+	c.mu.Lock()
+	c.counts[fault]++
+	c.mu.Unlock()
+
+	inner, cancel := context.WithCancelCause(parent)
+	stop := func() { cancel(context.Canceled) }
+	if fault == None {
+		return inner, stop
+	}
+	err := context.Canceled
+	if fault == ShortDeadline {
+		err = context.DeadlineExceeded
+	}
+	ctx := &chaosCtx{
+		Context: inner,
+		cancel:  cancel,
+		clk:     c.clk,
+		at:      c.clk.Now().Add(after),
+		cause:   &FaultError{Fault: fault, After: after, Err: err},
+	}
+	go ctx.watch(c.clk.After(after))
+	return ctx, stop
+}
+
+// chaosCtx is canceled with cause once its clock reaches at
+type chaosCtx struct {
+	context.Context
+	cancel context.CancelCauseFunc
+	clk    clock.Clock
+	at     time.Time
+	cause  *FaultError
+}
+
+// watch fires the fault when fired delivers, so Done closes without anyone
+// calling Err
+func (c *chaosCtx) watch(fired <-chan time.Time) {
+	select {
+	case <-fired:
+		c.cancel(c.cause)
+	case <-c.Context.Done():
+	}
+}
+
+// Err fires the fault first if the clock has reached it, so code that checks
+// Err after work on a fake clock sees the same outcome on every run
+// A context the fault ended reports context.DeadlineExceeded for a
+// ShortDeadline, which a plain cancellation could not
+func (c *chaosCtx) Err() error {
+	if !c.clk.Now().Before(c.at) {
+		c.cancel(c.cause)
+	}
+	err := c.Context.Err()
+	if err != nil && context.Cause(c.Context) == error(c.cause) {
+		return c.cause.Err
+	}
+	return err
+}
+
+// Deadline returns the shortened deadline, on the chaos clock, of a
+// ShortDeadline context
+func (c *chaosCtx) Deadline() (time.Time, bool) {
+	deadline, ok := c.Context.Deadline()
+	if c.cause.Fault == ShortDeadline && (!ok || c.at.Before(deadline)) {
+		return c.at, true
+	}
+	return deadline, ok
+}
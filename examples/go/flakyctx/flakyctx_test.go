@@ -0,0 +1,136 @@
+package flakyctx
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/clock"
+	"github.com/example/flaky-test-example/distributions"
+)
+
+func newChaos(t *testing.T, profile Profile) (*Chaos, *clock.FakeClock) {
+	t.Helper()
+	clk := clock.NewFake(time.Unix(0, 0))
+	return New(flaky.NewInjector(flaky.WithSeed(1)), profile, clk), clk
+}
+
+func TestCancelStrikesOnTheClock(t *testing.T) {
+	chaos, clk := newChaos(t, Profile{Cancel: 1, After: distributions.Constant(5 * time.Millisecond)})
+	ctx, cancel := chaos.Wrap(context.Background())
+	defer cancel()
+
+	clk.Sleep(4 * time.Millisecond)
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("Expected the context alive before the fault, got %v", err)
+	}
+	clk.Sleep(time.Millisecond)
+	if err := ctx.Err(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled at the fault, got %v", err)
+	}
+	<-ctx.Done()
+
+	var fault *FaultError
+	cause := context.Cause(ctx)
+	if !errors.As(cause, &fault) || fault.Fault != Canceled || fault.After != 5*time.Millisecond {
+		t.Errorf("Expected an injected cancel after 5ms as the cause, got %v", cause)
+	}
+	if !errors.Is(cause, flaky.ErrInjected) || !errors.Is(cause, context.Canceled) {
+		t.Errorf("Expected the cause to match flaky.ErrInjected and context.Canceled, got %v", cause)
+	}
+}
+
+func TestShortDeadline(t *testing.T) {
+	chaos, clk := newChaos(t, Profile{ShortDeadline: 1, After: distributions.Constant(time.Second)})
+	parent, stop := context.WithTimeout(context.Background(), time.Hour)
+	defer stop()
+	ctx, cancel := chaos.Wrap(parent)
+	defer cancel()
+
+	if deadline, ok := ctx.Deadline(); !ok || !deadline.Equal(clk.Now().Add(time.Second)) {
+		t.Errorf("Expected a deadline 1s away on the fake clock, got %v, %v", deadline, ok)
+	}
+	clk.Advance(time.Second)
+	<-ctx.Done()
+	if err := ctx.Err(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestNoFaultEndsWithParent(t *testing.T) {
+	chaos, clk := newChaos(t, Profile{})
+	parent, stop := context.WithCancel(context.Background())
+	ctx, cancel := chaos.Wrap(parent)
+	defer cancel()
+
+	clk.Advance(time.Hour)
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("Expected no fault, got %v", err)
+	}
+	stop()
+	if err := ctx.Err(); !errors.Is(err, context.Canceled) || errors.Is(context.Cause(ctx), flaky.ErrInjected) {
+		t.Errorf("Expected the parent's plain cancellation, got %v (cause %v)", err, context.Cause(ctx))
+	}
+	if got := chaos.Counts(); got[None] != 1 {
+		t.Errorf("Expected one context without a fault, got %v", got)
+	}
+}
+
+func TestCancelFuncWinsOverFault(t *testing.T) {
+	chaos, clk := newChaos(t, Profile{ShortDeadline: 1, After: distributions.Constant(time.Second)})
+	ctx, cancel := chaos.Wrap(context.Background())
+	cancel()
+	clk.Advance(time.Second)
+	if err := ctx.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the earlier cancel to win, got %v", err)
+	}
+}
+
+func TestWrapIsSeeded(t *testing.T) {
+	profile := Profile{Cancel: 0.3, ShortDeadline: 0.3}
+	draw := func() []time.Duration {
+		chaos, _ := newChaos(t, profile)
+		var afters []time.Duration
+		for i := 0; i < 50; i++ {
+			ctx, cancel := chaos.Wrap(context.Background())
+			var after time.Duration = -1
+			if c, ok := ctx.(*chaosCtx); ok {
+				after = c.cause.After
+			}
+			afters = append(afters, after)
+			cancel()
+		}
+		return afters
+	}
+	a, b := draw(), draw()
+	faults := 0
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("Wrap %d: expected the same fault for one seed, got %v and %v", i, a[i], b[i])
+		}
+		if a[i] >= 0 {
+			faults++
+			if a[i] > 10*time.Millisecond {
+				t.Errorf("Wrap %d: expected the default delay of at most 10ms, got %v", i, a[i])
+			}
+		}
+	}
+	if faults == 0 || faults == len(a) {
+		t.Errorf("Expected some of 50 contexts to see a fault at a 60%% rate, got %d", faults)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, p := range []Profile{
+		{Cancel: -0.1},
+		{ShortDeadline: 1.1},
+		{Cancel: 0.6, ShortDeadline: 0.6},
+		{After: distributions.Uniform{Min: 2, Max: 1}},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", p)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package flakydocker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+)
+
+// Fault is a container-level failure Chaos can inject
+type Fault string
+
+const (
+	None       Fault = "none"
+	Pause      Fault = "pause"
+	Restart    Fault = "restart"
+	Disconnect Fault = "disconnect"
+)
+
+// Profile sets the probability of each fault at every Inject and how it
+// behaves
+// At most one fault is injected per Inject, so the rates must sum to at
+// most 1
+type Profile struct {
+	// Pause freezes the container for PauseFor; connections stay open but
+	// nothing answers, like a GC pause or a stalled host
+	Pause float64
+	// Restart kills and restarts the container before Inject returns, so
+	// open connections break and unpersisted data is gone
+	Restart float64
+	// Disconnect detaches the container from its networks for
+	// DisconnectFor, so new and open connections time out
+	Disconnect float64
+
+	// PauseFor is how long a pause lasts (default 1s)
+	PauseFor time.Duration
+	// DisconnectFor is how long a disconnect lasts (default 1s)
+	DisconnectFor time.Duration
+}
+
+// Validate reports rates outside [0, 1], rates that sum to more than 1 and
+// negative durations
+func (p Profile) Validate() error {
+	var sum float64
+	for _, fr := range p.rates() {
+		if fr.rate < 0 || fr.rate > 1 {
+			return fmt.Errorf("flakydocker: %s rate %v outside [0, 1]", fr.fault, fr.rate)
+		}
+		sum += fr.rate
+	}
+	if sum > 1 {
+		return fmt.Errorf("flakydocker: fault rates sum to %v, more than 1", sum)
+	}
+	if p.PauseFor < 0 || p.DisconnectFor < 0 {
+		return fmt.Errorf("flakydocker: negative pause or disconnect duration")
+	}
+	return nil
+}
+
+type faultRate struct {
+	fault Fault
+	rate  float64
+}
+
+func (p Profile) rates() []faultRate {
+	return []faultRate{
+		{Pause, p.Pause},
+		{Restart, p.Restart},
+		{Disconnect, p.Disconnect},
+	}
+}
+
+// pick maps a draw in [0, 1) onto a fault, partitioning the unit interval by
+// the rates in order
+func pick(rates []faultRate, draw float64) Fault {
+	var upper float64
+	for _, fr := range rates {
+		upper += fr.rate
+		if draw < upper {
+			return fr.fault
+		}
+	}
+	return None
+}
+
+func orDefault(d time.Duration) time.Duration {
+	if d == 0 {
+		return time.Second
+	}
+	return d
+}
+
+// Chaos injects the faults of a Profile into a container at the points a
+// test calls Inject
+// A pause or disconnect is undone in the background after its duration, so
+// the test keeps running against the faulty container; Inject waits for the
+// previous fault to be undone before drawing the next
+type Chaos struct {
+	Profile Profile
+
+	inj       *flaky.Injector
+	container *Container
+	mu        sync.Mutex
+	counts    map[Fault]int
+	recovery  sync.WaitGroup
+	errs      []error
+}
+
+// NewChaos returns a Chaos drawing its faults for container from inj
+// It panics if the profile is invalid
+func NewChaos(inj *flaky.Injector, profile Profile, container *Container) *Chaos {
+	if err := profile.Validate(); err != nil {
+		panic(err)
+	}
+	return &Chaos{Profile: profile, inj: inj, container: container, counts: make(map[Fault]int)}
+}
+
+// Counts returns how many Inject calls drew each fault, including None
+func (c *Chaos) Counts() map[Fault]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[Fault]int, len(c.counts))
+	for f, n := range c.counts {
+		counts[f] = n
+	}
+	return counts
+}
+
+// Inject draws a fault and applies it to the container
+// It returns the fault and the error of applying it; the error of undoing a
+// fault later is returned by Wait
+func (c *Chaos) Inject(ctx context.Context) (Fault, error) {
+	c.recovery.Wait()
+	fault := pick(c.Profile.rates(), c.inj.Float64())
+	c.mu.Lock()
+	c.counts[fault]++
+	c.mu.Unlock()
+
+	switch fault {
+	case Pause:
+		if err := c.container.Pause(ctx); err != nil {
+			return fault, err
+		}
+		c.after(orDefault(c.Profile.PauseFor), c.container.Unpause)
+	case Restart:
+		return fault, c.container.Restart(ctx)
+	case Disconnect:
+		networks := c.container.Networks()
+		for _, network := range networks {
+			if err := c.container.Disconnect(ctx, network); err != nil {
+				return fault, err
+			}
+		}
+		c.after(orDefault(c.Profile.DisconnectFor), func(ctx context.Context) error {
+			for _, network := range networks {
+				if err := c.container.Connect(ctx, network); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	return fault, nil
+}
+
+// after undoes a fault with undo once d has passed
+func (c *Chaos) after(d time.Duration, undo func(context.Context) error) {
+	c.recovery.Add(1)
+	time.AfterFunc(d, func() {
+		defer c.recovery.Done()
+		if err := undo(context.Background()); err != nil {
+			c.mu.Lock()
+			c.errs = append(c.errs, err)
+			c.mu.Unlock()
+		}
+	})
+}
+
+// Run calls Inject every interval until ctx is done, for chaos that does
+// not follow the test's steps; the faults drawn are the same under the same
+// seed, but which step of the test they hit depends on timing
+// It returns the first error of a fault, or nil once ctx is done
+func (c *Chaos) Run(ctx context.Context, every time.Duration) error {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := c.Inject(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Wait waits until the last fault has been undone and returns the errors of
+// undoing faults
+func (c *Chaos) Wait() error {
+	c.recovery.Wait()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return errors.Join(c.errs...)
+}
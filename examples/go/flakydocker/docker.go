@@ -0,0 +1,365 @@
+// Package flakydocker runs a throwaway dependency container, such as Redis
+// or Postgres, and injects chaos at the container level: pauses, restarts
+// and network disconnects through the Docker Engine API
+//
+// It bridges in-process fakes like flakystore and real infrastructure: the
+// client under test talks to the real server, while a flaky.Injector decides
+// at which points of the test the container misbehaves, so the same seed
+// injects the same faults at the same points:
+//
+//	redis := flakydocker.StartContainer(t, flakydocker.Config{Image: "redis:7", Port: "6379/tcp"})
+//	chaos := flakydocker.NewChaos(flaky.ForTest(t), flakydocker.Profile{Pause: 0.1, Restart: 0.05}, redis)
+//	for _, key := range keys {
+//		chaos.Inject(ctx)
+//		client.Set(ctx, key, "v")
+//	}
+//
+// The package only needs the standard library; StartContainer skips the
+// test when no Docker daemon is reachable, so suites that use it still pass
+// on machines without Docker
+package flakydocker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// APIVersion is the Docker Engine API version the client speaks, supported
+// by Docker 20.10 and later
+const APIVersion = "v1.41"
+
+// DefaultHost is the daemon address used when neither Client.Host nor
+// DOCKER_HOST is set
+const DefaultHost = "unix:///var/run/docker.sock"
+
+// APIError is an error answer of the Docker daemon
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("flakydocker: docker API %d: %s", e.StatusCode, e.Message)
+}
+
+// Client calls the Docker Engine API
+type Client struct {
+	// Host is the daemon address as in DOCKER_HOST, unix:///path or
+	// tcp://host:port (default $DOCKER_HOST, then DefaultHost)
+	Host string
+
+	http *http.Client
+	base string
+}
+
+// NewClient returns a client of the daemon at host, or of the default
+// daemon when host is empty
+func NewClient(host string) (*Client, error) {
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+	if host == "" {
+		host = DefaultHost
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("flakydocker: docker host %q: %w", host, err)
+	}
+	c := &Client{Host: host}
+	switch u.Scheme {
+	case "unix":
+		path := u.Path
+		c.base = "http://docker"
+		c.http = &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}}
+	case "tcp", "http":
+		c.base = "http://" + u.Host
+		c.http = &http.Client{}
+	default:
+		return nil, fmt.Errorf("flakydocker: unsupported docker host %q, want unix:// or tcp://", host)
+	}
+	return c, nil
+}
+
+// Ping checks that the daemon answers
+func (c *Client) Ping(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/_ping", nil, nil)
+}
+
+// do calls the API and decodes a JSON answer into out, when set
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+"/"+APIVersion+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("flakydocker: %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		var msg struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(data, &msg) != nil || msg.Message == "" {
+			msg.Message = strings.TrimSpace(string(data))
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: msg.Message}
+	}
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Config describes a container to run
+type Config struct {
+	// Image is the image to run, pulled when missing
+	Image string
+	// Port is the container port the test connects to, such as "6379/tcp";
+	// it is published on a random host port
+	Port string
+	// Env holds KEY=value environment variables
+	Env []string
+	// Cmd overrides the image's command
+	Cmd []string
+	// Ready, when set, is polled after the container starts until it
+	// returns nil, for servers that accept connections before they are
+	// ready (default: until Port accepts a TCP connection)
+	Ready func(ctx context.Context, c *Container) error
+	// ReadyTimeout bounds waiting for Ready (default 30s)
+	ReadyTimeout time.Duration
+}
+
+// Container is a running container
+type Container struct {
+	ID string
+
+	client *Client
+	// mu guards ports and networks, which a Restart by a Chaos running in
+	// the background reads again
+	mu       sync.Mutex
+	ports    map[string]string
+	networks []string
+}
+
+// Addr returns the host address the container port, such as "6379/tcp", is
+// published on
+func (c *Container) Addr(port string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ports[port]
+}
+
+// Networks returns the networks the container is attached to, sorted
+func (c *Container) Networks() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.networks...)
+}
+
+// Run creates and starts a container from cfg, pulling its image when
+// missing, and waits until it is ready
+func (c *Client) Run(ctx context.Context, cfg Config) (*Container, error) {
+	body := map[string]any{
+		"Image": cfg.Image,
+		"Env":   cfg.Env,
+		"HostConfig": map[string]any{
+			"PortBindings": map[string]any{cfg.Port: []map[string]string{{"HostIp": "127.0.0.1", "HostPort": ""}}},
+		},
+		"ExposedPorts": map[string]any{cfg.Port: struct{}{}},
+	}
+	if len(cfg.Cmd) > 0 {
+		body["Cmd"] = cfg.Cmd
+	}
+	var created struct {
+		ID string `json:"Id"`
+	}
+	err := c.do(ctx, http.MethodPost, "/containers/create", body, &created)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		if err = c.pull(ctx, cfg.Image); err == nil {
+			err = c.do(ctx, http.MethodPost, "/containers/create", body, &created)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	ctr := &Container{ID: created.ID, client: c}
+	if err := c.do(ctx, http.MethodPost, "/containers/"+ctr.ID+"/start", nil, nil); err != nil {
+		ctr.Remove(context.Background())
+		return nil, err
+	}
+	if err := ctr.inspect(ctx); err != nil {
+		ctr.Remove(context.Background())
+		return nil, err
+	}
+	if err := ctr.waitReady(ctx, cfg); err != nil {
+		ctr.Remove(context.Background())
+		return nil, err
+	}
+	return ctr, nil
+}
+
+// pull pulls image, reading the progress stream to its end
+func (c *Client) pull(ctx context.Context, image string) error {
+	return c.do(ctx, http.MethodPost, "/images/create?fromImage="+url.QueryEscape(image), nil, nil)
+}
+
+// inspect reads the container's published ports and networks
+func (ctr *Container) inspect(ctx context.Context) error {
+	var info struct {
+		NetworkSettings struct {
+			Ports map[string][]struct {
+				HostIP   string `json:"HostIp"`
+				HostPort string `json:"HostPort"`
+			}
+			Networks map[string]json.RawMessage
+		}
+	}
+	if err := ctr.client.do(ctx, http.MethodGet, "/containers/"+ctr.ID+"/json", nil, &info); err != nil {
+		return err
+	}
+	ports := make(map[string]string)
+	for port, bindings := range info.NetworkSettings.Ports {
+		if len(bindings) > 0 {
+			host := bindings[0].HostIP
+			if host == "" || host == "0.0.0.0" {
+				host = "127.0.0.1"
+			}
+			ports[port] = net.JoinHostPort(host, bindings[0].HostPort)
+		}
+	}
+	var networks []string
+	for name := range info.NetworkSettings.Networks {
+		networks = append(networks, name)
+	}
+	sort.Strings(networks)
+	ctr.mu.Lock()
+	defer ctr.mu.Unlock()
+	ctr.ports, ctr.networks = ports, networks
+	return nil
+}
+
+// waitReady polls cfg.Ready, or dials cfg.Port, until it succeeds or
+// cfg.ReadyTimeout passes
+func (ctr *Container) waitReady(ctx context.Context, cfg Config) error {
+	timeout := cfg.ReadyTimeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ready := cfg.Ready
+	if ready == nil {
+		ready = func(ctx context.Context, c *Container) error {
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, "tcp", c.Addr(cfg.Port))
+			if err == nil {
+				conn.Close()
+			}
+			return err
+		}
+	}
+	for {
+		err := ready(ctx, ctr)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("flakydocker: container %.12s not ready: %w", ctr.ID, err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Pause freezes every process of the container
+func (ctr *Container) Pause(ctx context.Context) error {
+	return ctr.client.do(ctx, http.MethodPost, "/containers/"+ctr.ID+"/pause", nil, nil)
+}
+
+// Unpause resumes a paused container
+func (ctr *Container) Unpause(ctx context.Context) error {
+	return ctr.client.do(ctx, http.MethodPost, "/containers/"+ctr.ID+"/unpause", nil, nil)
+}
+
+// Restart kills and restarts the container without a grace period, losing
+// whatever it did not persist, and reads its ports again
+func (ctr *Container) Restart(ctx context.Context) error {
+	if err := ctr.client.do(ctx, http.MethodPost, "/containers/"+ctr.ID+"/restart?t=0", nil, nil); err != nil {
+		return err
+	}
+	return ctr.inspect(ctx)
+}
+
+// Disconnect detaches the container from network
+func (ctr *Container) Disconnect(ctx context.Context, network string) error {
+	body := map[string]any{"Container": ctr.ID, "Force": true}
+	return ctr.client.do(ctx, http.MethodPost, "/networks/"+url.PathEscape(network)+"/disconnect", body, nil)
+}
+
+// Connect attaches the container to network
+func (ctr *Container) Connect(ctx context.Context, network string) error {
+	body := map[string]any{"Container": ctr.ID}
+	return ctr.client.do(ctx, http.MethodPost, "/networks/"+url.PathEscape(network)+"/connect", body, nil)
+}
+
+// Remove force-removes the container and its volumes
+func (ctr *Container) Remove(ctx context.Context) error {
+	return ctr.client.do(ctx, http.MethodDelete, "/containers/"+ctr.ID+"?force=true&v=true", nil, nil)
+}
+
+// StartContainer runs a container from cfg on the default daemon for the
+// test and removes it when the test ends
+// It skips the test when no daemon answers, and fails it when the container
+// does not start
+func StartContainer(t testing.TB, cfg Config) *Container {
+	t.Helper()
+	c, err := NewClient("")
+	if err != nil {
+		t.Skipf("Docker unavailable: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	err = c.Ping(ctx)
+	cancel()
+	if err != nil {
+		t.Skipf("Docker unavailable: %v", err)
+	}
+	ctr, err := c.Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Start %s: %v", cfg.Image, err)
+	}
+	t.Cleanup(func() { ctr.Remove(context.Background()) })
+	return ctr
+}
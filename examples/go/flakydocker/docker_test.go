@@ -0,0 +1,229 @@
+package flakydocker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+)
+
+// fakeEngine is a Docker daemon that records the API calls it gets and
+// publishes every container port on backend
+type fakeEngine struct {
+	mu      sync.Mutex
+	calls   []string
+	images  map[string]bool
+	backend string
+}
+
+func newFakeEngine(t *testing.T) (*fakeEngine, *Client) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	e := &fakeEngine{images: make(map[string]bool), backend: ln.Addr().String()}
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	c, err := NewClient("tcp://" + strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, c
+}
+
+func (e *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/"+APIVersion)
+	var body map[string]any
+	json.NewDecoder(r.Body).Decode(&body)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls = append(e.calls, r.Method+" "+path)
+
+	switch {
+	case path == "/containers/create":
+		if !e.images[body["Image"].(string)] {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"message": "No such image"}`)
+			return
+		}
+		io.WriteString(w, `{"Id": "c0ffee"}`)
+	case path == "/images/create":
+		e.images[r.URL.Query().Get("fromImage")] = true
+		io.WriteString(w, `{"status": "Pulling"}`+"\n"+`{"status": "Downloaded"}`)
+	case path == "/containers/c0ffee/json":
+		_, port, _ := net.SplitHostPort(e.backend)
+		fmt.Fprintf(w, `{"NetworkSettings": {"Ports": {"6379/tcp": [{"HostIp": "0.0.0.0", "HostPort": %q}]}, "Networks": {"bridge": {}, "backend": {}}}}`, port)
+	case path == "/containers/broken/pause":
+		w.WriteHeader(http.StatusConflict)
+		io.WriteString(w, `{"message": "container is not running"}`)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (e *fakeEngine) Calls() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	calls := e.calls
+	e.calls = nil
+	return calls
+}
+
+func TestNewClient(t *testing.T) {
+	for host, base := range map[string]string{
+		"tcp://127.0.0.1:2375":        "http://127.0.0.1:2375",
+		"unix:///var/run/docker.sock": "http://docker",
+	} {
+		c, err := NewClient(host)
+		if err != nil || c.base != base {
+			t.Errorf("NewClient(%q) = %v, %v; want base %s", host, c, err, base)
+		}
+	}
+	if _, err := NewClient("ssh://host"); err == nil {
+		t.Error("Expected ssh:// to be unsupported")
+	}
+}
+
+func TestRunPullsAndPublishes(t *testing.T) {
+	e, c := newFakeEngine(t)
+	ctr, err := c.Run(context.Background(), Config{Image: "redis:7", Port: "6379/tcp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"POST /containers/create",
+		"POST /images/create",
+		"POST /containers/create",
+		"POST /containers/c0ffee/start",
+		"GET /containers/c0ffee/json",
+	}
+	if got := e.Calls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected calls %v, got %v", want, got)
+	}
+	if ctr.Addr("6379/tcp") != e.backend || !reflect.DeepEqual(ctr.Networks(), []string{"backend", "bridge"}) {
+		t.Errorf("Expected port on %s on both networks, got %s %v", e.backend, ctr.Addr("6379/tcp"), ctr.Networks())
+	}
+
+	if err := ctr.Remove(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Calls(); !reflect.DeepEqual(got, []string{"DELETE /containers/c0ffee"}) {
+		t.Errorf("Expected the container removed, got %v", got)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	_, c := newFakeEngine(t)
+	err := (&Container{ID: "broken", client: c}).Pause(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict || apiErr.Message != "container is not running" {
+		t.Errorf("Expected a 409 API error, got %v", err)
+	}
+}
+
+func TestChaosUndoesFaults(t *testing.T) {
+	e, c := newFakeEngine(t)
+	ctr := &Container{ID: "c0ffee", networks: []string{"bridge"}, client: c}
+	for _, tc := range []struct {
+		profile Profile
+		fault   Fault
+		calls   []string
+	}{
+		{Profile{Pause: 1, PauseFor: 10 * time.Millisecond}, Pause,
+			[]string{"POST /containers/c0ffee/pause", "POST /containers/c0ffee/unpause"}},
+		{Profile{Disconnect: 1, DisconnectFor: 10 * time.Millisecond}, Disconnect,
+			[]string{"POST /networks/bridge/disconnect", "POST /networks/bridge/connect"}},
+		{Profile{Restart: 1}, Restart,
+			[]string{"POST /containers/c0ffee/restart", "GET /containers/c0ffee/json"}},
+		{Profile{}, None, nil},
+	} {
+		chaos := NewChaos(flaky.NewInjector(flaky.WithSeed(1)), tc.profile, ctr)
+		fault, err := chaos.Inject(context.Background())
+		if err != nil || fault != tc.fault {
+			t.Fatalf("Expected %s, got %s, %v", tc.fault, fault, err)
+		}
+		if err := chaos.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		if got := e.Calls(); !reflect.DeepEqual(got, tc.calls) {
+			t.Errorf("Expected %s calls %v, got %v", tc.fault, tc.calls, got)
+		}
+		if chaos.Counts()[tc.fault] != 1 {
+			t.Errorf("Expected one %s counted, got %v", tc.fault, chaos.Counts())
+		}
+	}
+}
+
+func TestChaosReplays(t *testing.T) {
+	_, c := newFakeEngine(t)
+	ctr := &Container{ID: "c0ffee", client: c}
+	profile := Profile{Pause: 0.2, Restart: 0.2, Disconnect: 0.2, PauseFor: time.Millisecond, DisconnectFor: time.Millisecond}
+	faults := func() []Fault {
+		chaos := NewChaos(flaky.NewInjector(flaky.WithSeed(3)), profile, ctr)
+		var seen []Fault
+		for i := 0; i < 20; i++ {
+			fault, err := chaos.Inject(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen = append(seen, fault)
+		}
+		return seen
+	}
+	if first, second := faults(), faults(); !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected the same faults under the same seed, got %v then %v", first, second)
+	}
+}
+
+func TestStartContainerSkipsWithoutDocker(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	t.Setenv("DOCKER_HOST", "tcp://"+addr)
+
+	skipped := false
+	t.Run("start", func(t *testing.T) {
+		defer func() { skipped = t.Skipped() }()
+		StartContainer(t, Config{Image: "redis:7", Port: "6379/tcp"})
+	})
+	if !skipped {
+		t.Error("Expected the test to be skipped without a Docker daemon")
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	for _, p := range []Profile{
+		{Pause: -0.1},
+		{Restart: 0.6, Disconnect: 0.6},
+		{PauseFor: -time.Second},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", p)
+		}
+	}
+}
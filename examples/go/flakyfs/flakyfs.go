@@ -0,0 +1,354 @@
+// Package flakyfs injects seeded disk faults into filesystem access
+//
+// Code that takes an FS instead of calling os directly can be tested against
+// a full disk, a read-only directory, short writes and slow reads; every
+// decision is drawn from a flaky.Injector, so a failing sequence replays
+// exactly under the same seed:
+//
+//	fsys := flakyfs.New(flaky.ForTest(t), flakyfs.Profile{
+//		NoSpace:      0.1,
+//		PartialWrite: 0.1,
+//	}, flakyfs.Dir(t.TempDir()))
+//
+// FS extends io/fs.FS with the writes an afero-style filesystem offers, so
+// fs.ReadFile, fs.WalkDir and friends work on it unchanged
+package flakyfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/distributions"
+)
+
+// FS is a writable filesystem with slash-separated paths, as io/fs uses
+type FS interface {
+	fs.FS
+	// OpenFile opens name with os.OpenFile flags
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+	Remove(name string) error
+	Rename(oldname, newname string) error
+	MkdirAll(name string, perm fs.FileMode) error
+}
+
+// File is an open file of an FS; *os.File implements it
+type File interface {
+	fs.File
+	io.Writer
+	Sync() error
+}
+
+// Dir returns the FS of the directory tree rooted at dir, which like
+// os.DirFS only accepts paths valid for fs.ValidPath
+func Dir(dir string) FS {
+	return dirFS(dir)
+}
+
+type dirFS string
+
+func (d dirFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(string(d), filepath.FromSlash(name)), nil
+}
+
+func (d dirFS) Open(name string) (fs.File, error) {
+	return d.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (d dirFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	path, err := d.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (d dirFS) Remove(name string) error {
+	path, err := d.path("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (d dirFS) Rename(oldname, newname string) error {
+	oldpath, err := d.path("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newpath, err := d.path("rename", newname)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldpath, newpath)
+}
+
+func (d dirFS) MkdirAll(name string, perm fs.FileMode) error {
+	path, err := d.path("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(path, perm)
+}
+
+// Create creates or truncates name for writing, as os.Create does
+func Create(fsys FS, name string) (File, error) {
+	return fsys.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+// WriteFile writes data to name, creating or truncating it, as os.WriteFile
+// does
+func WriteFile(fsys FS, name string, data []byte, perm fs.FileMode) error {
+	f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+// Fault is a failure mode the filesystem can inject
+type Fault string
+
+const (
+	None             Fault = "none"
+	NoSpace          Fault = "no_space"
+	PartialWrite     Fault = "partial_write"
+	PermissionDenied Fault = "permission_denied"
+	SlowRead         Fault = "slow_read"
+)
+
+// Profile sets the probability of each fault and how it behaves
+// Every operation draws once and injects at most one of the faults that
+// apply to it, so NoSpace and PartialWrite must sum to at most 1
+type Profile struct {
+	// NoSpace fails a write with ENOSPC without writing anything
+	NoSpace float64
+	// PartialWrite writes the first half of the data and then fails with
+	// ENOSPC, as a disk filling up mid-write does
+	PartialWrite float64
+	// PermissionDenied fails opening, removing, renaming or creating a
+	// directory with EACCES
+	PermissionDenied float64
+	// SlowRead delays a read by ReadDelay
+	SlowRead float64
+
+	// ReadDelay is the delay of slow reads (default 10ms)
+	ReadDelay time.Duration
+	// ReadDelayDistribution, when set, draws the delay of every slow read
+	// from it instead
+	ReadDelayDistribution distributions.Distribution
+}
+
+// Validate reports rates outside [0, 1] and write fault rates that sum to
+// more than 1
+func (p Profile) Validate() error {
+	for _, fr := range []faultRate{{NoSpace, p.NoSpace}, {PartialWrite, p.PartialWrite}, {PermissionDenied, p.PermissionDenied}, {SlowRead, p.SlowRead}} {
+		if fr.rate < 0 || fr.rate > 1 {
+			return fmt.Errorf("flakyfs: %s rate %v outside [0, 1]", fr.fault, fr.rate)
+		}
+	}
+	if sum := p.NoSpace + p.PartialWrite; sum > 1 {
+		return fmt.Errorf("flakyfs: write fault rates sum to %v, more than 1", sum)
+	}
+	if p.ReadDelayDistribution != nil {
+		if err := p.ReadDelayDistribution.Validate(); err != nil {
+			return fmt.Errorf("flakyfs: read delay distribution: %w", err)
+		}
+	}
+	return nil
+}
+
+type faultRate struct {
+	fault Fault
+	rate  float64
+}
+
+// pick maps a draw in [0, 1) onto one of the faults, partitioning the unit
+// interval by their rates in order
+func pick(draw float64, rates ...faultRate) Fault {
+	var upper float64
+	for _, fr := range rates {
+		upper += fr.rate
+		if draw < upper {
+			return fr.fault
+		}
+	}
+	return None
+}
+
+func (p Profile) readDelay() distributions.Distribution {
+	if p.ReadDelayDistribution != nil {
+		return p.ReadDelayDistribution
+	}
+	if p.ReadDelay == 0 {
+		return distributions.Constant(10 * time.Millisecond)
+	}
+	return distributions.Constant(p.ReadDelay)
+}
+
+// FaultError is the error returned for injected faults
+// It matches flaky.ErrInjected and the underlying *fs.PathError with
+// errors.Is and errors.As, so syscall.ENOSPC and fs.ErrPermission checks
+// behave as they would against a real disk
+type FaultError struct {
+	Fault Fault
+	Err   *fs.PathError
+}
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("flakyfs: injected %s: %v", e.Fault, e.Err)
+}
+
+// Unwrap returns flaky.ErrInjected and the underlying *fs.PathError
+func (e *FaultError) Unwrap() []error {
+	return []error{flaky.ErrInjected, e.Err}
+}
+
+// FaultFS is an FS that injects the faults of a Profile into the operations
+// it passes on to Base
+// It is safe for concurrent use, but concurrent operations consume draws in
+// scheduling order; use it from one goroutine for exact replay
+type FaultFS struct {
+	Base    FS
+	Profile Profile
+
+	inj    *flaky.Injector
+	mu     sync.Mutex
+	counts map[Fault]int
+}
+
+var _ FS = (*FaultFS)(nil)
+
+// New returns a FaultFS over base drawing its faults from inj
+// It panics if the profile is invalid
+func New(inj *flaky.Injector, profile Profile, base FS) *FaultFS {
+	if err := profile.Validate(); err != nil {
+		panic(err)
+	}
+	return &FaultFS{Base: base, Profile: profile, inj: inj, counts: make(map[Fault]int)}
+}
+
+// Counts returns how many operations drew each fault, including None
+func (f *FaultFS) Counts() map[Fault]int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	counts := make(map[Fault]int, len(f.counts))
+	for fault, n := range f.counts {
+		counts[fault] = n
+	}
+	return counts
+}
+
+func (f *FaultFS) draw(rates ...faultRate) Fault {
+	fault := pick(f.inj.Float64(), rates...)
+	f.mu.Lock()
+	f.counts[fault]++
+	f.mu.Unlock()
+	return fault
+}
+
+// denied draws whether op on name fails with EACCES
+func (f *FaultFS) denied(op, name string) error {
+	if f.draw(faultRate{PermissionDenied, f.Profile.PermissionDenied}) == PermissionDenied {
+		return &FaultError{Fault: PermissionDenied, Err: &fs.PathError{Op: op, Path: name, Err: syscall.EACCES}}
+	}
+	return nil
+}
+
+// Open opens name for reading
+func (f *FaultFS) Open(name string) (fs.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile opens name, failing with EACCES at the PermissionDenied rate; the
+// returned file injects the write and read faults
+func (f *FaultFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if err := f.denied("open", name); err != nil {
+		return nil, err
+	}
+	file, err := f.Base.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &faultFile{File: file, fsys: f, name: name}, nil
+}
+
+// Remove removes name, failing with EACCES at the PermissionDenied rate
+func (f *FaultFS) Remove(name string) error {
+	if err := f.denied("remove", name); err != nil {
+		return err
+	}
+	return f.Base.Remove(name)
+}
+
+// Rename renames oldname, failing with EACCES at the PermissionDenied rate
+func (f *FaultFS) Rename(oldname, newname string) error {
+	if err := f.denied("rename", oldname); err != nil {
+		return err
+	}
+	return f.Base.Rename(oldname, newname)
+}
+
+// MkdirAll creates name and its parents, failing with EACCES at the
+// PermissionDenied rate
+func (f *FaultFS) MkdirAll(name string, perm fs.FileMode) error {
+	if err := f.denied("mkdir", name); err != nil {
+		return err
+	}
+	return f.Base.MkdirAll(name, perm)
+}
+
+// faultFile injects write and read faults into an open file
+type faultFile struct {
+	File
+	fsys *FaultFS
+	name string
+}
+
+func (f *faultFile) Write(p []byte) (int, error) {
+	prof := f.fsys.Profile
+	switch f.fsys.draw(faultRate{NoSpace, prof.NoSpace}, faultRate{PartialWrite, prof.PartialWrite}) {
+	case NoSpace:
+		return 0, &FaultError{Fault: NoSpace, Err: &fs.PathError{Op: "write", Path: f.name, Err: syscall.ENOSPC}}
+	case PartialWrite:
+		n, err := f.File.Write(p[:len(p)/2])
+		if err != nil {
+			return n, err
+		}
+		return n, &FaultError{Fault: PartialWrite, Err: &fs.PathError{Op: "write", Path: f.name, Err: syscall.ENOSPC}}
+	}
+	return f.File.Write(p)
+}
+
+func (f *faultFile) Read(p []byte) (int, error) {
+	if f.fsys.draw(faultRate{SlowRead, f.fsys.Profile.SlowRead}) == SlowRead {
+		f.fsys.inj.Delay(f.fsys.Profile.readDelay())
+	}
+	return f.File.Read(p)
+}
+
+// ReadDir passes directory reads through, so fs.ReadDir and fs.WalkDir work
+func (f *faultFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d, ok := f.File.(fs.ReadDirFile); ok {
+		return d.ReadDir(n)
+	}
+	return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errors.ErrUnsupported}
+}
@@ -0,0 +1,198 @@
+package flakyfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/clock"
+)
+
+func TestDirReadsAndWrites(t *testing.T) {
+	fsys := Dir(t.TempDir())
+	if err := fsys.MkdirAll("a/b", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(fsys, "a/b/data.txt", []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Rename("a/b/data.txt", "a/data.txt"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := fs.ReadFile(fsys, "a/data.txt")
+	if err != nil || string(got) != "hello" {
+		t.Errorf("Expected hello, got %q, %v", got, err)
+	}
+	if err := fsys.Remove("a/data.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.Open("a/data.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist after Remove, got %v", err)
+	}
+	if _, err := fsys.Open("../escape"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Expected fs.ErrInvalid for a path outside the root, got %v", err)
+	}
+}
+
+func TestNoSpace(t *testing.T) {
+	base := Dir(t.TempDir())
+	fsys := New(flaky.NewInjector(flaky.WithSeed(1)), Profile{NoSpace: 1}, base)
+
+	err := WriteFile(fsys, "data.txt", []byte("hello"), 0o644)
+	if !errors.Is(err, syscall.ENOSPC) || !errors.Is(err, flaky.ErrInjected) {
+		t.Fatalf("Expected an injected ENOSPC, got %v", err)
+	}
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) || pathErr.Op != "write" || pathErr.Path != "data.txt" {
+		t.Errorf("Expected a write *fs.PathError for data.txt, got %v", err)
+	}
+	if got, _ := fs.ReadFile(base, "data.txt"); len(got) != 0 {
+		t.Errorf("Expected nothing written, got %q", got)
+	}
+}
+
+func TestPartialWrite(t *testing.T) {
+	base := Dir(t.TempDir())
+	fsys := New(flaky.NewInjector(flaky.WithSeed(1)), Profile{PartialWrite: 1}, base)
+
+	f, err := Create(fsys, "data.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := f.Write([]byte("0123456789"))
+	f.Close()
+	if n != 5 || !errors.Is(err, syscall.ENOSPC) {
+		t.Fatalf("Expected 5 bytes and ENOSPC, got %d, %v", n, err)
+	}
+	if got, _ := fs.ReadFile(base, "data.txt"); string(got) != "01234" {
+		t.Errorf("Expected the first half on disk, got %q", got)
+	}
+}
+
+func TestPermissionDenied(t *testing.T) {
+	base := Dir(t.TempDir())
+	if err := WriteFile(base, "data.txt", []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fsys := New(flaky.NewInjector(flaky.WithSeed(1)), Profile{PermissionDenied: 1}, base)
+
+	for op, err := range map[string]error{
+		"open":   func() error { _, err := fsys.Open("data.txt"); return err }(),
+		"remove": fsys.Remove("data.txt"),
+		"rename": fsys.Rename("data.txt", "other.txt"),
+		"mkdir":  fsys.MkdirAll("dir", 0o755),
+	} {
+		if !errors.Is(err, fs.ErrPermission) || !errors.Is(err, flaky.ErrInjected) {
+			t.Errorf("Expected %s to fail with an injected fs.ErrPermission, got %v", op, err)
+		}
+	}
+	if got, _ := fs.ReadFile(base, "data.txt"); string(got) != "hello" {
+		t.Errorf("Expected data.txt untouched, got %q", got)
+	}
+}
+
+func TestSlowRead(t *testing.T) {
+	base := Dir(t.TempDir())
+	if err := WriteFile(base, "data.txt", []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	clk := clock.NewFake(time.Unix(0, 0))
+	inj := flaky.NewInjector(flaky.WithSeed(1), flaky.WithClock(clk))
+	fsys := New(inj, Profile{SlowRead: 1, ReadDelay: time.Second}, base)
+
+	f, err := fsys.Open("data.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := io.ReadAll(f)
+	if err != nil || string(got) != "hello" {
+		t.Fatalf("Expected hello, got %q, %v", got, err)
+	}
+	// One read returns the data and a second one io.EOF
+	if elapsed := clk.Since(time.Unix(0, 0)); elapsed != 2*time.Second {
+		t.Errorf("Expected 2s of fake time for 2 reads, got %v", elapsed)
+	}
+}
+
+func TestWalkDirPassesThrough(t *testing.T) {
+	base := Dir(t.TempDir())
+	for _, name := range []string{"a.txt", "sub/b.txt"} {
+		if err := base.MkdirAll("sub", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteFile(base, name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fsys := New(flaky.NewInjector(flaky.WithSeed(1)), Profile{}, base)
+
+	var files []string
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil || !reflect.DeepEqual(files, []string{"a.txt", "sub/b.txt"}) {
+		t.Errorf("Expected a.txt and sub/b.txt, got %v, %v", files, err)
+	}
+}
+
+func TestSeededReplay(t *testing.T) {
+	outcomes := func() ([]bool, map[Fault]int) {
+		fsys := New(flaky.NewInjector(flaky.WithSeed(42)), Profile{NoSpace: 0.3, PartialWrite: 0.2}, Dir(t.TempDir()))
+		var failed []bool
+		for i := 0; i < 20; i++ {
+			failed = append(failed, WriteFile(fsys, "data.txt", []byte("payload"), 0o644) != nil)
+		}
+		return failed, fsys.Counts()
+	}
+	first, counts := outcomes()
+	second, _ := outcomes()
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected the same outcomes under the same seed, got %v and %v", first, second)
+	}
+	// Each write opens and writes once
+	if counts[None]+counts[NoSpace]+counts[PartialWrite] != 40 || counts[NoSpace] == 0 || counts[PartialWrite] == 0 {
+		t.Errorf("Expected 40 draws including both write faults, got %v", counts)
+	}
+}
+
+func TestPick(t *testing.T) {
+	rates := []faultRate{{NoSpace, 0.2}, {PartialWrite, 0.3}}
+	for _, tc := range []struct {
+		draw float64
+		want Fault
+	}{
+		{0, NoSpace},
+		{0.19, NoSpace},
+		{0.2, PartialWrite},
+		{0.49, PartialWrite},
+		{0.5, None},
+	} {
+		if got := pick(tc.draw, rates...); got != tc.want {
+			t.Errorf("pick(%v) = %s, expected %s", tc.draw, got, tc.want)
+		}
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	for _, p := range []Profile{
+		{NoSpace: -0.1},
+		{SlowRead: 1.5},
+		{NoSpace: 0.6, PartialWrite: 0.6},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", p)
+		}
+	}
+	if err := (Profile{NoSpace: 0.5, PartialWrite: 0.5, PermissionDenied: 1, SlowRead: 1}).Validate(); err != nil {
+		t.Errorf("Expected a valid profile, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+// Package flakygrpc injects seeded UNAVAILABLE and DEADLINE_EXCEEDED errors
+// and latency into gRPC calls
+//
+// The server interceptor makes a real service unreliable for every client;
+// the client interceptor fails calls before they leave the process, which
+// tests retry policies without touching the server:
+//
+//	srv := grpc.NewServer(grpc.UnaryInterceptor(flakygrpc.UnaryServerInterceptor(
+//		flaky.ForTest(t), flakygrpc.Profile{Unavailable: 0.2},
+//	)))
+//
+// Every decision is drawn from a flaky.Injector, so a failing sequence
+// replays under the same seed as long as calls are made sequentially
+package flakygrpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/distributions"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Profile sets the probability of each injected error and the latency added
+// to every call
+// At most one error is injected per call, so the rates must sum to at most 1
+type Profile struct {
+	// Unavailable fails the call with codes.Unavailable
+	Unavailable float64
+	// DeadlineExceeded fails the call with codes.DeadlineExceeded
+	DeadlineExceeded float64
+	// MinLatency and MaxLatency bound the uniformly drawn delay added to
+	// every call, failed or not
+	MinLatency time.Duration
+	MaxLatency time.Duration
+	// LatencyDistribution, when set, draws the delay from it instead of
+	// from MinLatency to MaxLatency
+	LatencyDistribution distributions.Distribution
+}
+
+// Validate reports rates outside [0, 1], rates that sum to more than 1 and
+// inverted latency bounds
+func (p Profile) Validate() error {
+	for _, rate := range []float64{p.Unavailable, p.DeadlineExceeded} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("flakygrpc: rate %v outside [0, 1]", rate)
+		}
+	}
+	if sum := p.Unavailable + p.DeadlineExceeded; sum > 1 {
+		return fmt.Errorf("flakygrpc: error rates sum to %v, more than 1", sum)
+	}
+	if p.MaxLatency < p.MinLatency {
+		return fmt.Errorf("flakygrpc: max latency %v below min latency %v", p.MaxLatency, p.MinLatency)
+	}
+	if p.LatencyDistribution != nil {
+		if err := p.LatencyDistribution.Validate(); err != nil {
+			return fmt.Errorf("flakygrpc: latency distribution: %w", err)
+		}
+	}
+	return nil
+}
+
+// latency returns the distribution of the delay added to every call
+func (p Profile) latency() distributions.Distribution {
+	if p.LatencyDistribution != nil {
+		return p.LatencyDistribution
+	}
+	return distributions.Uniform{Min: p.MinLatency, Max: p.MaxLatency}
+}
+
+// pick maps a draw in [0, 1) onto the code to fail with, codes.OK for none
+func (p Profile) pick(draw float64) codes.Code {
+	switch {
+	case draw < p.Unavailable:
+		return codes.Unavailable
+	case draw < p.Unavailable+p.DeadlineExceeded:
+		return codes.DeadlineExceeded
+	}
+	return codes.OK
+}
+
+// FaultError is an injected gRPC error
+// status.FromError and status.Code read its code, and errors.Is matches it
+// against flaky.ErrInjected on the side that injected it
+type FaultError struct {
+	Code   codes.Code
+	Method string
+}
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("flakygrpc: injected %s for %s", e.Code, e.Method)
+}
+
+// GRPCStatus returns the status the error is sent and reported as
+func (e *FaultError) GRPCStatus() *status.Status {
+	return status.New(e.Code, e.Error())
+}
+
+// Unwrap returns flaky.ErrInjected
+func (e *FaultError) Unwrap() error {
+	return flaky.ErrInjected
+}
+
+// inject delays the call and returns the error to fail it with, or nil
+func inject(inj *flaky.Injector, p Profile, method string) error {
+	code := p.pick(inj.Float64())
+	inj.Delay(p.latency())
+	if code == codes.OK {
+		return nil
+	}
+	return &FaultError{Code: code, Method: method}
+}
+
+func mustValidate(p Profile) {
+	if err := p.Validate(); err != nil {
+		panic(err)
+	}
+}
+
+// UnaryServerInterceptor fails or delays incoming unary calls according to p
+// It panics if the profile is invalid
+func UnaryServerInterceptor(inj *flaky.Injector, p Profile) grpc.UnaryServerInterceptor {
+	mustValidate(p)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := inject(inj, p, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// UnaryClientInterceptor fails or delays outgoing unary calls according to p
+// before they are sent
+// It panics if the profile is invalid
+func UnaryClientInterceptor(inj *flaky.Injector, p Profile) grpc.UnaryClientInterceptor {
+	mustValidate(p)
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if err := inject(inj, p, method); err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
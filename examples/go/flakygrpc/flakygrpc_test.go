@@ -0,0 +1,168 @@
+package flakygrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/distributions"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dialHealth serves the standard health service over an in-memory listener
+// and returns a client for it
+func dialHealth(t *testing.T, serverOpts []grpc.ServerOption, dialOpts ...grpc.DialOption) healthpb.HealthClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(serverOpts...)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	dialOpts = append(dialOpts,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	conn, err := grpc.NewClient("passthrough:///bufnet", dialOpts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+// codesOf makes n sequential health checks and returns one letter per outcome
+func codesOf(t *testing.T, client healthpb.HealthClient, n int) string {
+	t.Helper()
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		switch status.Code(err) {
+		case codes.OK:
+			sb.WriteByte('.')
+		case codes.Unavailable:
+			sb.WriteByte('U')
+		case codes.DeadlineExceeded:
+			sb.WriteByte('D')
+		default:
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	return sb.String()
+}
+
+func TestUnaryServerInterceptorSeededReplay(t *testing.T) {
+	profile := Profile{Unavailable: 0.3, DeadlineExceeded: 0.3}
+	outcomes := func(seed int64) string {
+		client := dialHealth(t, []grpc.ServerOption{
+			grpc.UnaryInterceptor(UnaryServerInterceptor(flaky.NewInjector(flaky.WithSeed(seed)), profile)),
+		})
+		return codesOf(t, client, 40)
+	}
+
+	first := outcomes(5)
+	if second := outcomes(5); first != second {
+		t.Errorf("Expected identical outcomes for the same seed:\n%s\n%s", first, second)
+	}
+	for _, c := range ".UD" {
+		if !strings.ContainsRune(first, c) {
+			t.Errorf("Expected outcome %q in %s", c, first)
+		}
+	}
+}
+
+func TestUnaryClientInterceptorFailsBeforeSending(t *testing.T) {
+	var served int
+	counting := func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		served++
+		return handler(ctx, req)
+	}
+	client := dialHealth(t,
+		[]grpc.ServerOption{grpc.UnaryInterceptor(counting)},
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(flaky.NewInjector(flaky.WithSeed(1)), Profile{Unavailable: 1})),
+	)
+
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("Expected UNAVAILABLE, got %v", err)
+	}
+	if !errors.Is(err, flaky.ErrInjected) {
+		t.Errorf("Expected the client-side error to match flaky.ErrInjected, got %v", err)
+	}
+	if served != 0 {
+		t.Errorf("Expected the call never to reach the server, served %d", served)
+	}
+}
+
+func TestInterceptorLatency(t *testing.T) {
+	var slept []time.Duration
+	inj := flaky.NewInjector(flaky.WithSeed(1), flaky.WithSleep(func(d time.Duration) { slept = append(slept, d) }))
+	client := dialHealth(t, nil, grpc.WithUnaryInterceptor(UnaryClientInterceptor(inj, Profile{
+		MinLatency: time.Second,
+		MaxLatency: 2 * time.Second,
+	})))
+
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(slept) != 1 || slept[0] < time.Second || slept[0] > 2*time.Second {
+		t.Errorf("Expected one delay in [1s, 2s], got %v", slept)
+	}
+}
+
+func TestInterceptorLatencyDistribution(t *testing.T) {
+	var slept []time.Duration
+	inj := flaky.NewInjector(flaky.WithSeed(1), flaky.WithSleep(func(d time.Duration) { slept = append(slept, d) }))
+	client := dialHealth(t, nil, grpc.WithUnaryInterceptor(UnaryClientInterceptor(inj, Profile{
+		MaxLatency:          time.Millisecond,
+		LatencyDistribution: distributions.Constant(3 * time.Second),
+	})))
+
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(slept) != 1 || slept[0] != 3*time.Second {
+		t.Errorf("Expected the distribution's 3s delay in place of the range, got %v", slept)
+	}
+}
+
+func TestProfilePick(t *testing.T) {
+	p := Profile{Unavailable: 0.2, DeadlineExceeded: 0.1}
+	for _, tc := range []struct {
+		draw float64
+		want codes.Code
+	}{
+		{0, codes.Unavailable},
+		{0.19, codes.Unavailable},
+		{0.2, codes.DeadlineExceeded},
+		{0.31, codes.OK},
+	} {
+		if got := p.pick(tc.draw); got != tc.want {
+			t.Errorf("pick(%v) = %s, want %s", tc.draw, got, tc.want)
+		}
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	for _, p := range []Profile{
+		{Unavailable: -1},
+		{DeadlineExceeded: 1.1},
+		{Unavailable: 0.7, DeadlineExceeded: 0.7},
+		{MinLatency: time.Second},
+		{LatencyDistribution: distributions.Pareto{}},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", p)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Package flakyhttp injects seeded network faults into HTTP clients
+//
+// Wrap a real client's transport so tests exercise its error handling
+// against refused connections, timeouts, 5xx responses, slow bodies and
+// truncated responses; every decision is drawn from a flaky.Injector, so a
+// failing sequence replays exactly under the same seed:
+//
+//	client := &http.Client{Transport: flakyhttp.NewTransport(flaky.ForTest(t), flakyhttp.Profile{
+//		ServerError: 0.2,
+//		Timeout:     0.1,
+//	})}
+//
+// NewServer is the server-side counterpart: an httptest.Server that answers
+// with 5xx errors, hangs, drops connections mid-response, adds latency or
+// throttles with 429s behind a token bucket
+package flakyhttp
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/example/flaky-test-example/distributions"
+)
+
+// Fault is a failure mode the transport can inject
+type Fault string
+
+const (
+	None        Fault = "none"
+	ConnRefused Fault = "conn_refused"
+	Timeout     Fault = "timeout"
+	ServerError Fault = "server_error"
+	SlowBody    Fault = "slow_body"
+	Truncated   Fault = "truncated"
+)
+
+// Profile sets the probability of each fault and how it behaves
+// At most one fault is injected per request, so the rates must sum to at
+// most 1
+type Profile struct {
+	// ConnRefused fails the request as if the dial had been refused
+	ConnRefused float64
+	// Timeout fails the request with a timeout error after TimeoutDelay
+	Timeout float64
+	// ServerError answers with StatusCode without contacting the server
+	ServerError float64
+	// SlowBody delays every read of the response body by BodyDelay
+	SlowBody float64
+	// Truncate cuts the response body short with io.ErrUnexpectedEOF
+	Truncate float64
+
+	// TimeoutDelay is how long a timed-out request hangs before failing
+	TimeoutDelay time.Duration
+	// StatusCode is the status of injected server errors (default 503)
+	StatusCode int
+	// BodyDelay is the delay per body read of slow responses (default 10ms)
+	BodyDelay time.Duration
+	// BodyDelayDistribution, when set, draws the delay of every slow body
+	// read from it instead
+	BodyDelayDistribution distributions.Distribution
+	// TruncateAfter is the number of body bytes delivered before a truncated
+	// response fails (default half the Content-Length, or 0 if unknown)
+	TruncateAfter int64
+}
+
+// Validate reports rates outside [0, 1], rates that sum to more than 1 and
+// non-5xx status codes
+func (p Profile) Validate() error {
+	if err := validate(p.rates(), p.StatusCode); err != nil {
+		return err
+	}
+	if p.BodyDelayDistribution != nil {
+		if err := p.BodyDelayDistribution.Validate(); err != nil {
+			return fmt.Errorf("flakyhttp: body delay distribution: %w", err)
+		}
+	}
+	return nil
+}
+
+type faultRate struct {
+	fault Fault
+	rate  float64
+}
+
+func (p Profile) rates() []faultRate {
+	return []faultRate{
+		{ConnRefused, p.ConnRefused},
+		{Timeout, p.Timeout},
+		{ServerError, p.ServerError},
+		{SlowBody, p.SlowBody},
+		{Truncated, p.Truncate},
+	}
+}
+
+func (p Profile) pick(draw float64) Fault {
+	return pick(p.rates(), draw)
+}
+
+// pick maps a draw in [0, 1) onto a fault, partitioning the unit interval by
+// the rates in order
+func pick(rates []faultRate, draw float64) Fault {
+	var upper float64
+	for _, fr := range rates {
+		upper += fr.rate
+		if draw < upper {
+			return fr.fault
+		}
+	}
+	return None
+}
+
+func validate(rates []faultRate, statusCode int) error {
+	var sum float64
+	for _, fr := range rates {
+		if fr.rate < 0 || fr.rate > 1 {
+			return fmt.Errorf("flakyhttp: %s rate %v outside [0, 1]", fr.fault, fr.rate)
+		}
+		sum += fr.rate
+	}
+	if sum > 1 {
+		return fmt.Errorf("flakyhttp: fault rates sum to %v, more than 1", sum)
+	}
+	if statusCode != 0 && (statusCode < 500 || statusCode > 599) {
+		return fmt.Errorf("flakyhttp: status code %d is not a 5xx", statusCode)
+	}
+	return nil
+}
+
+func (p Profile) statusCode() int {
+	if p.StatusCode == 0 {
+		return http.StatusServiceUnavailable
+	}
+	return p.StatusCode
+}
+
+func (p Profile) bodyDelay() distributions.Distribution {
+	if p.BodyDelayDistribution != nil {
+		return p.BodyDelayDistribution
+	}
+	if p.BodyDelay == 0 {
+		return distributions.Constant(10 * time.Millisecond)
+	}
+	return distributions.Constant(p.BodyDelay)
+}
@@ -0,0 +1,133 @@
+package flakyhttp
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/example/flaky-test-example/clock"
+	"github.com/example/flaky-test-example/distributions"
+)
+
+// Throttled is a request answered with 429 Too Many Requests, either drawn
+// at the profile's Throttle rate or refused by the rate limit's token bucket
+const Throttled Fault = "throttled"
+
+// RateLimit throttles a Server with a token bucket: every request takes a
+// token, and a request that finds the bucket empty gets a 429
+// The bucket refills on Clock, so a clock.FakeClock makes the throttling
+// the same on every run
+type RateLimit struct {
+	// Burst is the bucket's capacity, the requests answered back to back
+	// before throttling starts; 0 disables the bucket
+	Burst int
+	// Refill is how long one token takes to come back (default 1s)
+	Refill time.Duration
+	// MinRetryAfter and MaxRetryAfter bound the uniformly drawn Retry-After
+	// of every 429, which is never sooner than the bucket's next token and
+	// is rounded up to whole seconds; a MaxRetryAfter of 0 means
+	// MinRetryAfter, and both default to 1s
+	MinRetryAfter time.Duration
+	MaxRetryAfter time.Duration
+	// Clock is the clock the bucket refills on (default clock.Real())
+	Clock clock.Clock
+}
+
+// Validate reports a negative burst or refill and inverted Retry-After
+// bounds
+func (l RateLimit) Validate() error {
+	if l.Burst < 0 {
+		return fmt.Errorf("flakyhttp: rate limit burst %d is negative", l.Burst)
+	}
+	if l.Refill < 0 {
+		return fmt.Errorf("flakyhttp: rate limit refill %v is negative", l.Refill)
+	}
+	if l.MinRetryAfter < 0 {
+		return fmt.Errorf("flakyhttp: min retry-after %v is negative", l.MinRetryAfter)
+	}
+	if l.MaxRetryAfter != 0 && l.MaxRetryAfter < l.MinRetryAfter {
+		return fmt.Errorf("flakyhttp: max retry-after %v below min retry-after %v", l.MaxRetryAfter, l.MinRetryAfter)
+	}
+	return nil
+}
+
+func (l RateLimit) refill() time.Duration {
+	if l.Refill == 0 {
+		return time.Second
+	}
+	return l.Refill
+}
+
+// retryAfter returns the distribution of the Retry-After of every 429
+func (l RateLimit) retryAfter() distributions.Distribution {
+	lo, hi := l.MinRetryAfter, l.MaxRetryAfter
+	if lo == 0 && hi == 0 {
+		lo, hi = time.Second, time.Second
+	}
+	return distributions.Uniform{Min: lo, Max: max(lo, hi)}
+}
+
+// bucket is the token bucket of a RateLimit
+type bucket struct {
+	limit  RateLimit
+	clock  clock.Clock
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(limit RateLimit) *bucket {
+	c := limit.Clock
+	if c == nil {
+		c = clock.Real()
+	}
+	return &bucket{limit: limit, clock: c, tokens: float64(limit.Burst), last: c.Now()}
+}
+
+// take takes a token, or reports that the bucket is empty and how long
+// until it has one again
+func (b *bucket) take() (wait time.Duration, ok bool) {
+	if b.limit.Burst == 0 {
+		return 0, true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.clock.Now()
+	refill := b.limit.refill()
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+float64(now.Sub(b.last))/float64(refill))
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) * float64(refill)), false
+}
+
+// throttle answers 429 with a Retry-After drawn from the rate limit, and no
+// sooner than wait
+func (s *Server) throttle(w http.ResponseWriter, wait time.Duration) {
+	after := max(wait, s.inj.Draw(s.Profile.RateLimit.retryAfter()))
+	secs := max(int64(math.Ceil(after.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+	code := http.StatusTooManyRequests
+	http.Error(w, fmt.Sprintf("flakyhttp: injected %d %s", code, http.StatusText(code)), code)
+}
+
+// RetryAfter returns the delay a 429 or 503 response asks for in its
+// Retry-After header, given in seconds or as an HTTP date
+func RetryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
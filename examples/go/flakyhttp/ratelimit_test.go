@@ -0,0 +1,96 @@
+package flakyhttp
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/clock"
+)
+
+// getThrottled requests srv's root and returns the status and Retry-After
+func getThrottled(t *testing.T, srv *Server) (int, string) {
+	t.Helper()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode, resp.Header.Get("Retry-After")
+}
+
+func TestServerRateLimit(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	srv := newServer(t, 1, ServerProfile{RateLimit: RateLimit{Burst: 2, Refill: 2 * time.Second, Clock: fake}})
+
+	for i := 0; i < 2; i++ {
+		if code, _ := getThrottled(t, srv); code != http.StatusOK {
+			t.Fatalf("Expected request %d within the burst to pass, got %d", i, code)
+		}
+	}
+	code, after := getThrottled(t, srv)
+	if code != http.StatusTooManyRequests || after != "2" {
+		t.Errorf("Expected 429 with Retry-After 2 once the bucket is empty, got %d %q", code, after)
+	}
+	fake.Advance(time.Second)
+	if code, after := getThrottled(t, srv); code != http.StatusTooManyRequests || after != "1" {
+		t.Errorf("Expected 429 with Retry-After 1 halfway through the refill, got %d %q", code, after)
+	}
+	fake.Advance(time.Second)
+	if code, _ := getThrottled(t, srv); code != http.StatusOK {
+		t.Errorf("Expected a refilled token to pass, got %d", code)
+	}
+	if got := srv.Counts()[Throttled]; got != 2 {
+		t.Errorf("Expected 2 throttled requests, got %d", got)
+	}
+}
+
+func TestServerThrottleReplays(t *testing.T) {
+	profile := ServerProfile{Throttle: 0.5, RateLimit: RateLimit{MinRetryAfter: time.Second, MaxRetryAfter: 30 * time.Second}}
+	sequence := func() []string {
+		srv := newServer(t, 7, profile)
+		var seen []string
+		for i := 0; i < 20; i++ {
+			code, after := getThrottled(t, srv)
+			seen = append(seen, strconv.Itoa(code)+" "+after)
+			if code == http.StatusTooManyRequests {
+				if secs, err := strconv.Atoi(after); err != nil || secs < 1 || secs > 30 {
+					t.Errorf("Expected a Retry-After of 1-30s, got %q", after)
+				}
+			}
+		}
+		return seen
+	}
+	first, second := sequence(), sequence()
+	throttled := 0
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Request %d replayed as %q, first %q", i, second[i], first[i])
+		}
+		if first[i][:3] == "429" {
+			throttled++
+		}
+	}
+	if throttled == 0 || throttled == len(first) {
+		t.Errorf("Expected some but not all of 20 requests throttled, got %d", throttled)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for v, want := range map[string]time.Duration{"3": 3 * time.Second, "0": 0} {
+		resp := &http.Response{Header: http.Header{"Retry-After": {v}}}
+		if got, ok := RetryAfter(resp); !ok || got != want {
+			t.Errorf("RetryAfter(%q) = %v, %v; want %v", v, got, ok, want)
+		}
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got, ok := RetryAfter(&http.Response{Header: http.Header{"Retry-After": {date}}}); !ok || got < 59*time.Minute {
+		t.Errorf("Expected about an hour for %q, got %v, %v", date, got, ok)
+	}
+	for _, v := range []string{"", "soon", "-1"} {
+		if _, ok := RetryAfter(&http.Response{Header: http.Header{"Retry-After": {v}}}); ok {
+			t.Errorf("Expected no delay for %q", v)
+		}
+	}
+}
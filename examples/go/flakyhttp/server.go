@@ -0,0 +1,210 @@
+package flakyhttp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/distributions"
+)
+
+const (
+	Hang    Fault = "hang"
+	Dropped Fault = "dropped"
+)
+
+// ServerProfile sets the probability of each server-side fault
+// At most one fault is injected per request, so the rates must sum to at
+// most 1; latency is added to every request on top of any fault
+type ServerProfile struct {
+	// ServerError answers with StatusCode instead of calling the handler
+	ServerError float64
+	// Hang never answers; the request blocks until the client gives up,
+	// HangFor elapses or the server is closed
+	Hang float64
+	// Drop sends the headers and half the handler's body, then closes the
+	// connection
+	Drop float64
+	// Throttle answers 429 Too Many Requests with a Retry-After drawn from
+	// RateLimit, as if other clients had used up a shared quota
+	Throttle float64
+
+	// StatusCode is the status of injected server errors (default 500)
+	StatusCode int
+	// HangFor bounds how long a hung request blocks; 0 means until the
+	// client disconnects or the server is closed
+	HangFor time.Duration
+	// MinLatency and MaxLatency bound the uniformly drawn delay added
+	// before every response
+	MinLatency time.Duration
+	MaxLatency time.Duration
+	// LatencyDistribution, when set, draws the delay from it instead of
+	// from MinLatency to MaxLatency
+	LatencyDistribution distributions.Distribution
+	// RateLimit throttles requests beyond its token bucket, whatever fault
+	// they drew
+	RateLimit RateLimit
+}
+
+// Validate reports rates outside [0, 1], rates that sum to more than 1,
+// non-5xx status codes, inverted latency bounds and invalid rate limits
+func (p ServerProfile) Validate() error {
+	if err := validate(p.rates(), p.StatusCode); err != nil {
+		return err
+	}
+	if err := p.RateLimit.Validate(); err != nil {
+		return err
+	}
+	if p.MaxLatency < p.MinLatency {
+		return fmt.Errorf("flakyhttp: max latency %v below min latency %v", p.MaxLatency, p.MinLatency)
+	}
+	if p.LatencyDistribution != nil {
+		if err := p.LatencyDistribution.Validate(); err != nil {
+			return fmt.Errorf("flakyhttp: latency distribution: %w", err)
+		}
+	}
+	return nil
+}
+
+// latency returns the distribution of the delay before every response
+func (p ServerProfile) latency() distributions.Distribution {
+	if p.LatencyDistribution != nil {
+		return p.LatencyDistribution
+	}
+	return distributions.Uniform{Min: p.MinLatency, Max: p.MaxLatency}
+}
+
+func (p ServerProfile) rates() []faultRate {
+	return []faultRate{
+		{ServerError, p.ServerError},
+		{Hang, p.Hang},
+		{Dropped, p.Drop},
+		{Throttled, p.Throttle},
+	}
+}
+
+func (p ServerProfile) pick(draw float64) Fault {
+	return pick(p.rates(), draw)
+}
+
+// Server is an httptest.Server whose responses fail according to a
+// ServerProfile
+// Draws are taken in the order requests arrive, so clients must send
+// requests sequentially for exact replay
+type Server struct {
+	*httptest.Server
+	Profile ServerProfile
+
+	inj       *flaky.Injector
+	handler   http.Handler
+	bucket    *bucket
+	closing   chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
+	counts    map[Fault]int
+}
+
+// NewServer starts a Server that serves handler through the faults of
+// profile, drawing them from inj; a nil handler answers 200 "ok"
+// It panics if the profile is invalid
+func NewServer(inj *flaky.Injector, profile ServerProfile, handler http.Handler) *Server {
+	if err := profile.Validate(); err != nil {
+		panic(err)
+	}
+	if handler == nil {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		})
+	}
+	s := &Server{
+		Profile: profile,
+		inj:     inj,
+		handler: handler,
+		bucket:  newBucket(profile.RateLimit),
+		closing: make(chan struct{}),
+		counts:  make(map[Fault]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Close releases hung requests and shuts the server down
+func (s *Server) Close() {
+	s.closeOnce.Do(func() { close(s.closing) })
+	s.Server.Close()
+}
+
+// Counts returns how many requests saw each fault, including None
+func (s *Server) Counts() map[Fault]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[Fault]int, len(s.counts))
+	for f, n := range s.counts {
+		counts[f] = n
+	}
+	return counts
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	fault := s.Profile.pick(s.inj.Float64())
+	wait, ok := s.bucket.take()
+	if !ok {
+		fault = Throttled
+	}
+	s.mu.Lock()
+	s.counts[fault]++
+	s.mu.Unlock()
+	s.inj.Delay(s.Profile.latency())
+
+	switch fault {
+	case ServerError:
+		code := s.Profile.StatusCode
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		http.Error(w, fmt.Sprintf("flakyhttp: injected %d %s", code, http.StatusText(code)), code)
+	case Hang:
+		var timeout <-chan time.Time
+		if s.Profile.HangFor > 0 {
+			timer := time.NewTimer(s.Profile.HangFor)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case <-r.Context().Done():
+		case <-s.closing:
+		case <-timeout:
+		}
+	case Dropped:
+		s.drop(w, r)
+	case Throttled:
+		s.throttle(w, wait)
+	default:
+		s.handler.ServeHTTP(w, r)
+	}
+}
+
+// drop records the handler's response and sends only the first half of its
+// body before aborting the connection, so the client sees a Content-Length it
+// never receives; an empty body is dropped before the headers
+func (s *Server) drop(w http.ResponseWriter, r *http.Request) {
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, r)
+	body := rec.Body.Bytes()
+
+	if len(body) > 0 {
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(rec.Code)
+		w.Write(body[:len(body)/2])
+		http.NewResponseController(w).Flush()
+	}
+	// ErrAbortHandler closes the connection without logging a stack trace
+	panic(http.ErrAbortHandler)
+}
@@ -0,0 +1,180 @@
+package flakyhttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+)
+
+func payloadHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, payload)
+	})
+}
+
+func newServer(t *testing.T, seed int64, profile ServerProfile) *Server {
+	t.Helper()
+	srv := NewServer(flaky.NewInjector(flaky.WithSeed(seed)), profile, payloadHandler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestServerPassesThrough(t *testing.T) {
+	srv := newServer(t, 1, ServerProfile{})
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != payload {
+		t.Errorf("Expected 200 %q, got %d %q", payload, resp.StatusCode, body)
+	}
+}
+
+func TestServerError(t *testing.T) {
+	srv := newServer(t, 1, ServerProfile{ServerError: 1})
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected 500, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerHangUntilClientTimeout(t *testing.T) {
+	srv := newServer(t, 1, ServerProfile{Hang: 1})
+	client := &http.Client{Timeout: 50 * time.Millisecond}
+	_, err := client.Get(srv.URL)
+	var timeout interface{ Timeout() bool }
+	if !errors.As(err, &timeout) || !timeout.Timeout() {
+		t.Errorf("Expected a client timeout, got %v", err)
+	}
+}
+
+func TestServerHangFor(t *testing.T) {
+	srv := newServer(t, 1, ServerProfile{Hang: 1, HangFor: 10 * time.Millisecond})
+	start := time.Now()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("Expected the request to hang at least 10ms, took %v", elapsed)
+	}
+}
+
+func TestServerCloseReleasesHungRequests(t *testing.T) {
+	srv := NewServer(flaky.NewInjector(flaky.WithSeed(1)), ServerProfile{Hang: 1}, nil)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := http.Get(srv.URL)
+		errc <- err
+	}()
+	for srv.Counts()[Hang] == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		srv.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close blocked on a hung request")
+	}
+	<-errc
+}
+
+func TestServerDropsMidResponse(t *testing.T) {
+	srv := newServer(t, 1, ServerProfile{Drop: 1})
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if string(body) != payload[:len(payload)/2] {
+		t.Errorf("Expected half the body, got %q", body)
+	}
+}
+
+func TestServerLatency(t *testing.T) {
+	var slept []time.Duration
+	inj := flaky.NewInjector(flaky.WithSeed(1), flaky.WithSleep(func(d time.Duration) { slept = append(slept, d) }))
+	srv := NewServer(inj, ServerProfile{MinLatency: time.Second, MaxLatency: 2 * time.Second}, nil)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(slept) != 1 || slept[0] < time.Second || slept[0] > 2*time.Second {
+		t.Errorf("Expected one delay in [1s, 2s], got %v", slept)
+	}
+}
+
+// TestServerExercisesClientRetries shows the intended use: a client retry
+// loop against a reproducibly unreliable backend
+func TestServerExercisesClientRetries(t *testing.T) {
+	outcomes := func(seed int64) string {
+		srv := newServer(t, seed, ServerProfile{ServerError: 0.3, Drop: 0.3})
+		var sb strings.Builder
+		for attempt := 0; attempt < 20; attempt++ {
+			resp, err := http.Get(srv.URL)
+			if err != nil {
+				sb.WriteByte('E')
+				continue
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			switch {
+			case err != nil:
+				sb.WriteByte('D')
+			case resp.StatusCode != http.StatusOK:
+				sb.WriteByte('5')
+			case string(body) == payload:
+				sb.WriteByte('.')
+			}
+		}
+		return sb.String()
+	}
+
+	first := outcomes(3)
+	if second := outcomes(3); first != second {
+		t.Errorf("Expected identical outcomes for the same seed:\n%s\n%s", first, second)
+	}
+	if !strings.Contains(first, ".") || !strings.ContainsAny(first, "D5") {
+		t.Errorf("Expected a mix of successes and failures, got %s", first)
+	}
+}
+
+func TestServerProfileValidate(t *testing.T) {
+	for _, p := range []ServerProfile{
+		{Hang: 2},
+		{ServerError: 0.5, Drop: 0.6},
+		{StatusCode: 200},
+		{MinLatency: time.Second},
+		{Throttle: 0.5, Drop: 0.6},
+		{RateLimit: RateLimit{Burst: -1}},
+		{RateLimit: RateLimit{MinRetryAfter: 2 * time.Second, MaxRetryAfter: time.Second}},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", p)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package flakyhttp
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/distributions"
+)
+
+// FaultError is the error returned for injected connection faults
+// It matches flaky.ErrInjected and the underlying cause with errors.Is, so
+// both syscall.ECONNREFUSED and os.ErrDeadlineExceeded checks behave as they
+// would against a real network failure
+type FaultError struct {
+	Fault Fault
+	Err   error
+}
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("flakyhttp: injected %s: %v", e.Fault, e.Err)
+}
+
+// Unwrap returns flaky.ErrInjected and the underlying cause
+func (e *FaultError) Unwrap() []error {
+	return []error{flaky.ErrInjected, e.Err}
+}
+
+// Timeout reports whether the fault is a timeout, as net.Error requires
+func (e *FaultError) Timeout() bool {
+	return e.Fault == Timeout
+}
+
+// Temporary always reports true; injected faults are transient by design
+func (e *FaultError) Temporary() bool {
+	return true
+}
+
+var _ net.Error = (*FaultError)(nil)
+
+// Transport is an http.RoundTripper that injects the faults of a Profile
+// into requests passed on to Base
+// It is safe for concurrent use, but concurrent requests consume draws in
+// scheduling order; issue requests sequentially for exact replay
+type Transport struct {
+	// Base performs requests that are not failed outright; nil means
+	// http.DefaultTransport
+	Base    http.RoundTripper
+	Profile Profile
+
+	inj    *flaky.Injector
+	mu     sync.Mutex
+	counts map[Fault]int
+}
+
+// NewTransport returns a Transport drawing its faults from inj
+// It panics if the profile is invalid
+func NewTransport(inj *flaky.Injector, profile Profile) *Transport {
+	if err := profile.Validate(); err != nil {
+		panic(err)
+	}
+	return &Transport{Profile: profile, inj: inj, counts: make(map[Fault]int)}
+}
+
+// Counts returns how many requests saw each fault, including None
+func (t *Transport) Counts() map[Fault]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts := make(map[Fault]int, len(t.counts))
+	for f, n := range t.counts {
+		counts[f] = n
+	}
+	return counts
+}
+
+// RoundTrip draws a fault for req and either injects it or forwards req to Base
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fault := t.Profile.pick(t.inj.Float64())
+	t.mu.Lock()
+	t.counts[fault]++
+	t.mu.Unlock()
+
+	switch fault {
+	case ConnRefused:
+		closeBody(req)
+		return nil, &FaultError{Fault: fault, Err: &net.OpError{
+			Op:  "dial",
+			Net: "tcp",
+			Err: os.NewSyscallError("connect", syscall.ECONNREFUSED),
+		}}
+	case Timeout:
+		closeBody(req)
+		// A request cancelled meanwhile ends with its context's error, as it
+		// would waiting on a server that does not answer
+		delay := distributions.Uniform{Min: t.Profile.TimeoutDelay, Max: t.Profile.TimeoutDelay}
+		if _, err := t.inj.DelayContext(req.Context(), delay); err != nil {
+			return nil, err
+		}
+		return nil, &FaultError{Fault: fault, Err: os.ErrDeadlineExceeded}
+	case ServerError:
+		closeBody(req)
+		return errorResponse(req, t.Profile.statusCode()), nil
+	}
+
+	resp, err := t.base().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	switch fault {
+	case SlowBody:
+		resp.Body = &slowBody{ReadCloser: resp.Body, inj: t.inj, delay: t.Profile.bodyDelay()}
+	case Truncated:
+		limit := t.Profile.TruncateAfter
+		if limit == 0 && resp.ContentLength > 0 {
+			limit = resp.ContentLength / 2
+		}
+		resp.Body = &truncatedBody{ReadCloser: resp.Body, remaining: limit}
+	}
+	return resp, nil
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// closeBody closes the request body, which RoundTrip must do even when the
+// request is never sent
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+func errorResponse(req *http.Request, code int) *http.Response {
+	body := fmt.Sprintf("flakyhttp: injected %d %s\n", code, http.StatusText(code))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// slowBody delays every read by a draw from delay
+type slowBody struct {
+	io.ReadCloser
+	inj   *flaky.Injector
+	delay distributions.Distribution
+}
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	b.inj.Delay(b.delay)
+	return b.ReadCloser.Read(p)
+}
+
+// truncatedBody delivers remaining bytes and then fails with
+// io.ErrUnexpectedEOF, as a connection dropped mid-response does
+type truncatedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,212 @@
+package flakyhttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/clock"
+)
+
+const payload = "0123456789abcdefghij"
+
+func newBackend(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, payload)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, tr *Transport, url string) (*http.Response, error) {
+	t.Helper()
+	return (&http.Client{Transport: tr}).Get(url)
+}
+
+func TestTransportConnRefused(t *testing.T) {
+	srv := newBackend(t)
+	tr := NewTransport(flaky.NewInjector(flaky.WithSeed(1)), Profile{ConnRefused: 1})
+
+	_, err := get(t, tr, srv.URL)
+	if !errors.Is(err, syscall.ECONNREFUSED) || !errors.Is(err, flaky.ErrInjected) {
+		t.Fatalf("Expected an injected ECONNREFUSED, got %v", err)
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "dial" {
+		t.Errorf("Expected a dial *net.OpError, got %v", err)
+	}
+}
+
+func TestTransportTimeout(t *testing.T) {
+	srv := newBackend(t)
+	clk := clock.NewFake(time.Unix(0, 0))
+	inj := flaky.NewInjector(flaky.WithSeed(1), flaky.WithClock(clk))
+	tr := NewTransport(inj, Profile{Timeout: 1, TimeoutDelay: 30 * time.Second})
+
+	_, err := get(t, tr, srv.URL)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("Expected a timeout net.Error, got %v", err)
+	}
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected os.ErrDeadlineExceeded, got %v", err)
+	}
+	if got := clk.Since(time.Unix(0, 0)); got != 30*time.Second {
+		t.Errorf("Expected the request to hang for 30s of fake time, got %v", got)
+	}
+}
+
+func TestTransportTimeoutCancelled(t *testing.T) {
+	srv := newBackend(t)
+	tr := NewTransport(flaky.NewInjector(flaky.WithSeed(1)), Profile{Timeout: 1, TimeoutDelay: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = (&http.Client{Transport: tr}).Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the request's deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Expected the request to end with its context, not after %v", elapsed)
+	}
+}
+
+func TestTransportServerError(t *testing.T) {
+	srv := newBackend(t)
+	tr := NewTransport(flaky.NewInjector(flaky.WithSeed(1)), Profile{ServerError: 1, StatusCode: http.StatusBadGateway})
+
+	resp, err := get(t, tr, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected 502, got %d", resp.StatusCode)
+	}
+}
+
+func TestTransportSlowBody(t *testing.T) {
+	srv := newBackend(t)
+	var slept time.Duration
+	inj := flaky.NewInjector(flaky.WithSeed(1), flaky.WithSleep(func(d time.Duration) { slept += d }))
+	tr := NewTransport(inj, Profile{SlowBody: 1, BodyDelay: time.Second})
+
+	resp, err := get(t, tr, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || string(body) != payload {
+		t.Fatalf("Expected the full body, got %q, %v", body, err)
+	}
+	if slept < time.Second {
+		t.Errorf("Expected body reads to be delayed, slept %v", slept)
+	}
+}
+
+func TestTransportTruncated(t *testing.T) {
+	srv := newBackend(t)
+	tr := NewTransport(flaky.NewInjector(flaky.WithSeed(1)), Profile{Truncate: 1})
+
+	resp, err := get(t, tr, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if string(body) != payload[:len(payload)/2] {
+		t.Errorf("Expected half the body, got %q", body)
+	}
+}
+
+func TestTransportSeededReplay(t *testing.T) {
+	srv := newBackend(t)
+	profile := Profile{ConnRefused: 0.2, ServerError: 0.2, Truncate: 0.2}
+	outcomes := func(seed int64) string {
+		tr := NewTransport(flaky.NewInjector(flaky.WithSeed(seed)), profile)
+		var sb strings.Builder
+		for i := 0; i < 50; i++ {
+			resp, err := get(t, tr, srv.URL)
+			switch {
+			case err != nil:
+				sb.WriteByte('E')
+			case resp.StatusCode != http.StatusOK:
+				sb.WriteByte('5')
+			default:
+				if _, err := io.ReadAll(resp.Body); err != nil {
+					sb.WriteByte('T')
+				} else {
+					sb.WriteByte('.')
+				}
+			}
+			if resp != nil {
+				resp.Body.Close()
+			}
+		}
+		return sb.String()
+	}
+
+	first := outcomes(7)
+	if second := outcomes(7); first != second {
+		t.Errorf("Expected identical outcomes for the same seed:\n%s\n%s", first, second)
+	}
+	for _, c := range "E5T." {
+		if !strings.ContainsRune(first, c) {
+			t.Errorf("Expected outcome %q in %s", c, first)
+		}
+	}
+}
+
+func TestProfilePick(t *testing.T) {
+	p := Profile{ConnRefused: 0.1, Timeout: 0.1, ServerError: 0.3}
+	for _, tc := range []struct {
+		draw float64
+		want Fault
+	}{
+		{0, ConnRefused},
+		{0.15, Timeout},
+		{0.2, ServerError},
+		{0.49, ServerError},
+		{0.5, None},
+		{0.99, None},
+	} {
+		if got := p.pick(tc.draw); got != tc.want {
+			t.Errorf("pick(%v) = %s, want %s", tc.draw, got, tc.want)
+		}
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	for _, p := range []Profile{
+		{ConnRefused: -0.1},
+		{ServerError: 1.5},
+		{ConnRefused: 0.6, Timeout: 0.6},
+		{ServerError: 0.1, StatusCode: 404},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", p)
+		}
+	}
+	if err := (Profile{ConnRefused: 0.5, Timeout: 0.5, StatusCode: 500}).Validate(); err != nil {
+		t.Errorf("Expected a valid profile, got %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+// Package flakyio injects seeded partial I/O into readers and writers
+//
+// Network code that assumes one Read returns a whole message, or that a
+// message written in one Write arrives in one piece, passes against a local
+// buffer and flakes against a real connection; every decision is drawn from
+// a flaky.Injector, so a failing sequence replays exactly under the same
+// seed:
+//
+//	r := flakyio.NewReader(flaky.ForTest(t), flakyio.Profile{
+//		ShortRead:     0.2,
+//		UnexpectedEOF: 0.01,
+//	}, conn)
+//
+// Short reads and split writes are legal io.Reader and io.Writer behavior;
+// only UnexpectedEOF loses data
+package flakyio
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	flaky "github.com/example/flaky-test-example"
+)
+
+// Fault is a partial I/O behavior the wrappers can inject
+type Fault string
+
+const (
+	None          Fault = "none"
+	ShortRead     Fault = "short_read"
+	UnexpectedEOF Fault = "unexpected_eof"
+	SplitWrite    Fault = "split_write"
+)
+
+// Profile sets the probability of each fault
+// Every Read draws once and injects at most one of the read faults, so
+// ShortRead and UnexpectedEOF must sum to at most 1
+type Profile struct {
+	// ShortRead returns fewer bytes than the underlying reader returned,
+	// as a socket returns what has arrived so far, and the rest on the
+	// reads after it
+	ShortRead float64
+	// UnexpectedEOF truncates the stream: the read returns part of what it
+	// asked for with io.ErrUnexpectedEOF, and so does every read after it,
+	// as a connection dropped mid-message does
+	UnexpectedEOF float64
+	// SplitWrite passes a write on to the underlying writer in two calls,
+	// split at a drawn offset, as a stream can deliver one message in
+	// several segments
+	SplitWrite float64
+}
+
+// Validate reports rates outside [0, 1] and read fault rates that sum to
+// more than 1
+func (p Profile) Validate() error {
+	for _, fr := range []faultRate{{ShortRead, p.ShortRead}, {UnexpectedEOF, p.UnexpectedEOF}, {SplitWrite, p.SplitWrite}} {
+		if fr.rate < 0 || fr.rate > 1 {
+			return fmt.Errorf("flakyio: %s rate %v outside [0, 1]", fr.fault, fr.rate)
+		}
+	}
+	if sum := p.ShortRead + p.UnexpectedEOF; sum > 1 {
+		return fmt.Errorf("flakyio: read fault rates sum to %v, more than 1", sum)
+	}
+	return nil
+}
+
+type faultRate struct {
+	fault Fault
+	rate  float64
+}
+
+// pick maps a draw in [0, 1) onto one of the faults, partitioning the unit
+// interval by their rates in order
+func pick(draw float64, rates ...faultRate) Fault {
+	var upper float64
+	for _, fr := range rates {
+		upper += fr.rate
+		if draw < upper {
+			return fr.fault
+		}
+	}
+	return None
+}
+
+// FaultError is the error returned for an injected UnexpectedEOF
+// It matches flaky.ErrInjected and io.ErrUnexpectedEOF with errors.Is, so
+// checks written with errors.Is behave as they would against a dropped
+// connection; comparing with == does not
+type FaultError struct {
+	Fault Fault
+	Err   error
+}
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("flakyio: injected %s: %v", e.Fault, e.Err)
+}
+
+// Unwrap returns flaky.ErrInjected and the underlying error
+func (e *FaultError) Unwrap() []error {
+	return []error{flaky.ErrInjected, e.Err}
+}
+
+// counter tallies the faults a wrapper drew
+type counter struct {
+	inj    *flaky.Injector
+	mu     sync.Mutex
+	counts map[Fault]int
+}
+
+func newCounter(inj *flaky.Injector) counter {
+	return counter{inj: inj, counts: make(map[Fault]int)}
+}
+
+// Counts returns how many operations drew each fault, including None
+func (c *counter) Counts() map[Fault]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[Fault]int, len(c.counts))
+	for fault, n := range c.counts {
+		counts[fault] = n
+	}
+	return counts
+}
+
+func (c *counter) draw(rates ...faultRate) Fault {
+	fault := pick(c.inj.Float64(), rates...)
+	c.mu.Lock()
+	c.counts[fault]++
+	c.mu.Unlock()
+	return fault
+}
+
+// cut draws an offset in [1, n), splitting n bytes into two non-empty parts
+func (c *counter) cut(n int) int {
+	return 1 + c.inj.Intn(n-1)
+}
+
+// Reader is an io.Reader that injects the read faults of a Profile into the
+// reads it passes on to Base
+// Like any io.Reader it is not safe for concurrent reads
+type Reader struct {
+	Base    io.Reader
+	Profile Profile
+	counter
+	// pending holds what a short read read from Base but did not return,
+	// and err the error Base returned with it
+	pending []byte
+	err     error
+	// truncated is set once an UnexpectedEOF was injected
+	truncated bool
+}
+
+// NewReader returns a Reader over base drawing its faults from inj
+// It panics if the profile is invalid
+func NewReader(inj *flaky.Injector, profile Profile, base io.Reader) *Reader {
+	if err := profile.Validate(); err != nil {
+		panic(err)
+	}
+	return &Reader{Base: base, Profile: profile, counter: newCounter(inj)}
+}
+
+// Read reads into p, returning only part of what it read at the ShortRead
+// rate and failing with io.ErrUnexpectedEOF from the first draw of
+// UnexpectedEOF on
+// The rest of a short read is returned by the reads after it, so nothing
+// is lost; a read of one byte cannot be shorter
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.truncated {
+		return 0, &FaultError{Fault: UnexpectedEOF, Err: io.ErrUnexpectedEOF}
+	}
+	fault := r.draw(faultRate{ShortRead, r.Profile.ShortRead}, faultRate{UnexpectedEOF, r.Profile.UnexpectedEOF})
+	n, err := r.read(p)
+	switch {
+	case fault == UnexpectedEOF:
+		r.truncated = true
+		if n > 1 {
+			n = r.cut(n)
+		}
+		return n, &FaultError{Fault: UnexpectedEOF, Err: io.ErrUnexpectedEOF}
+	case fault == ShortRead && n > 1:
+		short := r.cut(n)
+		r.pending = append(r.pending[:0:0], p[short:n]...)
+		r.err = err
+		return short, nil
+	}
+	return n, err
+}
+
+// read reads what a short read left pending, or from Base when nothing is
+func (r *Reader) read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		return r.Base.Read(p)
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	if len(r.pending) == 0 && r.err != nil {
+		err := r.err
+		r.err = nil
+		return n, err
+	}
+	return n, nil
+}
+
+// Writer is an io.Writer that injects the write faults of a Profile into
+// the writes it passes on to Base
+// Like any io.Writer it is not safe for concurrent writes
+type Writer struct {
+	Base    io.Writer
+	Profile Profile
+	counter
+}
+
+// NewWriter returns a Writer over base drawing its faults from inj
+// It panics if the profile is invalid
+func NewWriter(inj *flaky.Injector, profile Profile, base io.Writer) *Writer {
+	if err := profile.Validate(); err != nil {
+		panic(err)
+	}
+	return &Writer{Base: base, Profile: profile, counter: newCounter(inj)}
+}
+
+// Write writes p, in two calls to Base at the SplitWrite rate; it returns
+// the bytes written and the first error, as a single write would
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.draw(faultRate{SplitWrite, w.Profile.SplitWrite}) != SplitWrite || len(p) < 2 {
+		return w.Base.Write(p)
+	}
+	cut := w.cut(len(p))
+	n, err := w.Base.Write(p[:cut])
+	if err != nil {
+		return n, err
+	}
+	m, err := w.Base.Write(p[cut:])
+	return n + m, err
+}
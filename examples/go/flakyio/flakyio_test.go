@@ -0,0 +1,128 @@
+package flakyio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	flaky "github.com/example/flaky-test-example"
+)
+
+const message = "the quick brown fox jumps over the lazy dog"
+
+func TestShortRead(t *testing.T) {
+	r := NewReader(flaky.NewInjector(flaky.WithSeed(1)), Profile{ShortRead: 1}, strings.NewReader(message))
+	buf := make([]byte, len(message))
+	n, err := r.Read(buf)
+	if err != nil || n == 0 || n >= len(message) {
+		t.Fatalf("Expected a short read, got %d bytes, %v", n, err)
+	}
+	if string(buf[:n]) != message[:n] {
+		t.Errorf("Expected a prefix of the message, got %q", buf[:n])
+	}
+
+	// Short reads lose nothing, so io.ReadAll still sees all of it
+	r = NewReader(flaky.NewInjector(flaky.WithSeed(1)), Profile{ShortRead: 1}, strings.NewReader(message))
+	got, err := io.ReadAll(r)
+	if err != nil || string(got) != message {
+		t.Errorf("Expected the whole message, got %q, %v", got, err)
+	}
+	if counts := r.Counts(); counts[ShortRead] == 0 || counts[UnexpectedEOF] != 0 {
+		t.Errorf("Expected only short reads, got %v", counts)
+	}
+}
+
+func TestUnexpectedEOF(t *testing.T) {
+	r := NewReader(flaky.NewInjector(flaky.WithSeed(2)), Profile{UnexpectedEOF: 1}, strings.NewReader(message))
+	buf := make([]byte, len(message))
+	n, err := io.ReadFull(r, buf)
+	if !errors.Is(err, io.ErrUnexpectedEOF) || !errors.Is(err, flaky.ErrInjected) {
+		t.Fatalf("Expected an injected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if n == 0 || n >= len(message) || string(buf[:n]) != message[:n] {
+		t.Errorf("Expected a truncated prefix, got %q", buf[:n])
+	}
+	// The stream stays truncated
+	if n, err := r.Read(buf); n != 0 || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected every later read to fail, got %d bytes, %v", n, err)
+	}
+}
+
+// recordingWriter records the chunks each Write call passed
+type recordingWriter struct {
+	chunks []string
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.chunks = append(w.chunks, string(p))
+	return len(p), nil
+}
+
+func TestSplitWrite(t *testing.T) {
+	base := &recordingWriter{}
+	w := NewWriter(flaky.NewInjector(flaky.WithSeed(3)), Profile{SplitWrite: 1}, base)
+	n, err := w.Write([]byte(message))
+	if err != nil || n != len(message) {
+		t.Fatalf("Expected the whole message written, got %d bytes, %v", n, err)
+	}
+	if len(base.chunks) != 2 || base.chunks[0] == "" || base.chunks[0]+base.chunks[1] != message {
+		t.Errorf("Expected the message in two non-empty chunks, got %q", base.chunks)
+	}
+
+	// A single byte cannot be split
+	base.chunks = nil
+	if _, err := w.Write([]byte("x")); err != nil || len(base.chunks) != 1 {
+		t.Errorf("Expected one chunk for one byte, got %q, %v", base.chunks, err)
+	}
+}
+
+func TestNoFaults(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(flaky.NewInjector(flaky.WithSeed(4)), Profile{}, &buf)
+	r := NewReader(flaky.NewInjector(flaky.WithSeed(4)), Profile{}, &buf)
+	if _, err := io.WriteString(w, message); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(message))
+	if n, err := r.Read(got); err != nil || n != len(message) {
+		t.Errorf("Expected one full read, got %d bytes, %v", n, err)
+	}
+	if counts := r.Counts(); counts[None] != 1 {
+		t.Errorf("Expected one fault-free read, got %v", counts)
+	}
+}
+
+func TestSameSeedSameFaults(t *testing.T) {
+	read := func() []int {
+		r := NewReader(flaky.NewInjector(flaky.WithSeed(5)), Profile{ShortRead: 0.5}, strings.NewReader(strings.Repeat(message, 10)))
+		var sizes []int
+		buf := make([]byte, 64)
+		for {
+			n, err := r.Read(buf)
+			if err != nil {
+				return sizes
+			}
+			sizes = append(sizes, n)
+		}
+	}
+	if a, b := read(), read(); !slices.Equal(a, b) {
+		t.Errorf("Expected the same reads under one seed, got %v and %v", a, b)
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	for _, p := range []Profile{{ShortRead: -0.1}, {SplitWrite: 1.5}, {ShortRead: 0.6, UnexpectedEOF: 0.6}} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", p)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected NewReader to panic on an invalid profile")
+		}
+	}()
+	NewReader(flaky.NewInjector(), Profile{ShortRead: 2}, strings.NewReader(""))
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package flakylock
+
+import (
+	"errors"
+	"os"
+)
+
+// tryFlock needs flock(2), which this system does not have
+func tryFlock(f *os.File) (bool, error) {
+	return false, errors.ErrUnsupported
+}
@@ -0,0 +1,207 @@
+// Package flakylock contends for real advisory file locks, for testing code
+// that waits for a lock with a timeout
+//
+// A Contender stands for another process sharing a lock file: with a seeded
+// probability it takes the lock just before the code under test asks for it
+// and holds it for a seeded duration. It holds the lock through a file
+// descriptor of its own, so flock treats it exactly as another process, and
+// a lock-timeout flake fails the way it does in production:
+//
+//	path := filepath.Join(t.TempDir(), "cache.lock")
+//	other := flakylock.New(flaky.ForTest(t), flakylock.Profile{
+//		Held:    0.5,
+//		MinHold: 20 * time.Millisecond,
+//		MaxHold: 100 * time.Millisecond,
+//	}, path)
+//	defer other.Close()
+//	other.Contend()
+//
+//	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+//	defer cancel()
+//	lock, err := flakylock.Acquire(ctx, path) // ErrTimeout while other holds it
+//
+// Locks are flock(2) locks and need a Unix system; elsewhere Acquire and
+// Contend return errors.ErrUnsupported
+package flakylock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/distributions"
+)
+
+// ErrTimeout is returned by Acquire when its context ends before the lock
+// is free
+var ErrTimeout = errors.New("flakylock: timed out waiting for lock")
+
+// pollInterval is how often Acquire retries a held lock; flock cannot be
+// interrupted, so Acquire polls to honour its context
+const pollInterval = time.Millisecond
+
+// Lock is an exclusive advisory lock on a file
+type Lock struct {
+	f *os.File
+}
+
+// TryLock takes the lock on path, creating the file if needed, and reports
+// false without waiting when someone else holds it
+func TryLock(path string) (*Lock, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, false, fmt.Errorf("flakylock: %w", err)
+	}
+	ok, err := tryFlock(f)
+	if err != nil || !ok {
+		f.Close()
+		return nil, false, err
+	}
+	return &Lock{f: f}, true, nil
+}
+
+// Acquire waits for the lock on path until ctx ends, then returns an error
+// wrapping ErrTimeout and the context's error
+func Acquire(ctx context.Context, path string) (*Lock, error) {
+	start := time.Now()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		lock, ok, err := TryLock(path)
+		if err != nil || ok {
+			return lock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w %s after %v: %w", ErrTimeout, path, time.Since(start).Round(time.Millisecond), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Unlock releases the lock; closing the file releases it too, as the
+// kernel does when a process holding a lock exits
+func (l *Lock) Unlock() error {
+	return l.f.Close()
+}
+
+// Profile sets how often the contender holds the lock and for how long
+type Profile struct {
+	// Held is the probability that Contend takes the lock
+	Held float64
+	// MinHold and MaxHold bound the uniformly drawn time the lock is held
+	MinHold time.Duration
+	MaxHold time.Duration
+	// HoldDistribution, when set, draws the hold from it instead of from
+	// MinHold to MaxHold
+	HoldDistribution distributions.Distribution
+}
+
+// Validate reports a rate outside [0, 1] and negative or inverted holds
+func (p Profile) Validate() error {
+	if p.Held < 0 || p.Held > 1 {
+		return fmt.Errorf("flakylock: held rate %v outside [0, 1]", p.Held)
+	}
+	if p.MinHold < 0 {
+		return fmt.Errorf("flakylock: negative min hold %v", p.MinHold)
+	}
+	if p.MaxHold < p.MinHold {
+		return fmt.Errorf("flakylock: max hold %v below min hold %v", p.MaxHold, p.MinHold)
+	}
+	if p.HoldDistribution != nil {
+		if err := p.HoldDistribution.Validate(); err != nil {
+			return fmt.Errorf("flakylock: hold distribution: %w", err)
+		}
+	}
+	return nil
+}
+
+// Contender holds the lock on a path the way another process sharing it
+// would, at seeded times for seeded durations
+type Contender struct {
+	Profile Profile
+
+	inj  *flaky.Injector
+	path string
+
+	mu        sync.Mutex
+	wg        sync.WaitGroup
+	stop      chan struct{}
+	closeOnce sync.Once
+	holds     []time.Duration
+}
+
+// New returns a Contender for the lock on path drawing from inj
+// It panics if the profile is invalid
+func New(inj *flaky.Injector, profile Profile, path string) *Contender {
+	if err := profile.Validate(); err != nil {
+		panic(err)
+	}
+	return &Contender{Profile: profile, inj: inj, path: path, stop: make(chan struct{})}
+}
+
+// Contend draws whether the contender takes the lock now and, if it does,
+// takes it and returns how long it will hold it, releasing it from a
+// goroutine afterwards; it returns 0 when it left the lock alone
+// It fails when the lock is already held, since it would not get it then
+func (c *Contender) Contend() (time.Duration, error) {
+	if c.inj.Float64() >= c.Profile.Held {
+		return 0, nil
+	}
+	hold := c.hold()
+	lock, ok, err := TryLock(c.path)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("flakylock: %s is already locked", c.path)
+	}
+
+	c.mu.Lock()
+	c.holds = append(c.holds, hold)
+	c.mu.Unlock()
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer lock.Unlock()
+		timer := time.NewTimer(hold)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-c.stop:
+		}
+	}()
+	return hold, nil
+}
+
+// hold draws how long a taken lock is held
+func (c *Contender) hold() time.Duration {
+	if c.Profile.HoldDistribution != nil {
+		return c.inj.Draw(c.Profile.HoldDistribution)
+	}
+	return c.Profile.MinHold + time.Duration(c.inj.Float64()*float64(c.Profile.MaxHold-c.Profile.MinHold))
+}
+
+// Holds returns the duration of every hold Contend took, in order
+func (c *Contender) Holds() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.holds...)
+}
+
+// Wait blocks until every hold Contend took has ended
+func (c *Contender) Wait() {
+	c.wg.Wait()
+}
+
+// Close cuts the holds still running short and waits until their locks are
+// released; Contend must not be called after it
+func (c *Contender) Close() error {
+	c.closeOnce.Do(func() { close(c.stop) })
+	c.wg.Wait()
+	return nil
+}
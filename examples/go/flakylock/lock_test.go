@@ -0,0 +1,136 @@
+//go:build unix
+
+package flakylock
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/distributions"
+)
+
+func TestTryLockExcludesOtherDescriptors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.lock")
+	first, ok, err := TryLock(path)
+	if err != nil || !ok {
+		t.Fatalf("Expected the first lock, got %v, %v", ok, err)
+	}
+	if _, ok, err := TryLock(path); err != nil || ok {
+		t.Fatalf("Expected a second descriptor to find the lock held, got %v, %v", ok, err)
+	}
+	if err := first.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	again, ok, err := TryLock(path)
+	if err != nil || !ok {
+		t.Fatalf("Expected the lock free after Unlock, got %v, %v", ok, err)
+	}
+	again.Unlock()
+}
+
+func TestAcquireTimesOutWhileHeld(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.lock")
+	c := New(flaky.NewInjector(flaky.WithSeed(1)), Profile{Held: 1, MinHold: 100 * time.Millisecond, MaxHold: 100 * time.Millisecond}, path)
+	defer c.Close()
+	if hold, err := c.Contend(); err != nil || hold != 100*time.Millisecond {
+		t.Fatalf("Expected a 100ms hold, got %v, %v", hold, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := Acquire(ctx, path); !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected ErrTimeout, got %v", err)
+	}
+
+	start := time.Now()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	lock, err := Acquire(ctx, path)
+	if err != nil {
+		t.Fatalf("Expected the lock once the hold ended, got %v", err)
+	}
+	lock.Unlock()
+	if waited := time.Since(start); waited > 500*time.Millisecond {
+		t.Errorf("Expected the lock within the 100ms hold, waited %v", waited)
+	}
+}
+
+func TestContendFailsOnHeldLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.lock")
+	lock, _, err := TryLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Unlock()
+	c := New(flaky.NewInjector(flaky.WithSeed(1)), Profile{Held: 1, MaxHold: time.Second}, path)
+	defer c.Close()
+	if _, err := c.Contend(); err == nil {
+		t.Error("Expected Contend to fail on a lock someone else holds")
+	}
+}
+
+func TestCloseReleasesHold(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.lock")
+	c := New(flaky.NewInjector(flaky.WithSeed(1)), Profile{Held: 1, MinHold: time.Hour, MaxHold: time.Hour}, path)
+	if _, err := c.Contend(); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	lock, ok, err := TryLock(path)
+	if err != nil || !ok {
+		t.Fatalf("Expected Close to release the lock, got %v, %v", ok, err)
+	}
+	lock.Unlock()
+}
+
+func TestSeededReplay(t *testing.T) {
+	holds := func() []time.Duration {
+		dir := t.TempDir()
+		c := New(flaky.NewInjector(flaky.WithSeed(42)), Profile{Held: 0.5, HoldDistribution: distributions.Exponential{Mean: time.Millisecond}}, filepath.Join(dir, "a.lock"))
+		defer c.Close()
+		var got []time.Duration
+		for i := 0; i < 20; i++ {
+			hold, err := c.Contend()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, hold)
+			c.Wait()
+		}
+		return got
+	}
+	first := holds()
+	if !reflect.DeepEqual(first, holds()) {
+		t.Errorf("Expected the same holds under the same seed")
+	}
+	held := 0
+	for _, hold := range first {
+		if hold > 0 {
+			held++
+		}
+	}
+	if held == 0 || held == len(first) {
+		t.Errorf("Expected some of 20 draws at rate 0.5 held, got %v", first)
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	for _, p := range []Profile{
+		{Held: 1.5},
+		{MinHold: -time.Second},
+		{MinHold: time.Second, MaxHold: time.Millisecond},
+		{HoldDistribution: distributions.Uniform{Min: time.Second, Max: time.Millisecond}},
+	} {
+		if p.Validate() == nil {
+			t.Errorf("Expected %+v to be rejected", p)
+		}
+	}
+	if err := (Profile{Held: 0.5, MaxHold: time.Second}).Validate(); err != nil {
+		t.Errorf("Expected a valid profile, got %v", err)
+	}
+}
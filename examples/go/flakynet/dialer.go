@@ -0,0 +1,194 @@
+package flakynet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+
+	flaky "github.com/example/flaky-test-example"
+)
+
+// FaultError is the error returned for injected faults
+// It matches flaky.ErrInjected and the underlying cause with errors.Is and
+// errors.As, so *net.DNSError and syscall.ECONNRESET checks behave as they
+// would against a real network failure
+type FaultError struct {
+	Fault Fault
+	Err   error
+}
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("flakynet: injected %s: %v", e.Fault, e.Err)
+}
+
+// Unwrap returns flaky.ErrInjected and the underlying cause
+func (e *FaultError) Unwrap() []error {
+	return []error{flaky.ErrInjected, e.Err}
+}
+
+// Timeout reports whether a slow dial outlasted its context's deadline, as
+// net.Error requires
+func (e *FaultError) Timeout() bool {
+	return e.Fault == SlowDial && errors.Is(e.Err, context.DeadlineExceeded)
+}
+
+// Temporary always reports true; injected faults are transient by design
+func (e *FaultError) Temporary() bool {
+	return true
+}
+
+var _ net.Error = (*FaultError)(nil)
+
+// ContextDialer dials connections; *net.Dialer implements it
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Dialer injects the faults of a Profile into the dials it passes on to
+// Base
+// It is safe for concurrent use, but concurrent dials consume draws in
+// scheduling order; dial sequentially for exact replay
+type Dialer struct {
+	// Base dials connections that are not failed outright; nil means a zero
+	// net.Dialer
+	Base    ContextDialer
+	Profile Profile
+
+	inj    *flaky.Injector
+	mu     sync.Mutex
+	counts map[Fault]int
+}
+
+var _ ContextDialer = (*Dialer)(nil)
+
+// NewDialer returns a Dialer drawing its faults from inj
+// It panics if the profile is invalid
+func NewDialer(inj *flaky.Injector, profile Profile) *Dialer {
+	if err := profile.Validate(); err != nil {
+		panic(err)
+	}
+	return &Dialer{Profile: profile, inj: inj, counts: make(map[Fault]int)}
+}
+
+// Counts returns how many dials saw each fault, including None
+func (d *Dialer) Counts() map[Fault]int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	counts := make(map[Fault]int, len(d.counts))
+	for f, n := range d.counts {
+		counts[f] = n
+	}
+	return counts
+}
+
+// Dial dials address without a context
+func (d *Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext draws a fault for the dial and either injects it or dials
+// address with Base
+// A context that expires during a slow dial fails it with the context's
+// error, as a real dial would
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	fault := pick(d.Profile.rates(!isIP(host)), d.inj.Float64())
+	d.mu.Lock()
+	d.counts[fault]++
+	d.mu.Unlock()
+
+	switch fault {
+	case NXDomain:
+		return nil, &FaultError{Fault: fault, Err: &net.OpError{Op: "dial", Net: network, Err: notFound(host)}}
+	case SlowDial:
+		if _, err := d.inj.DelayContext(ctx, d.Profile.dialDelay()); err != nil {
+			return nil, &FaultError{Fault: fault, Err: &net.OpError{Op: "dial", Net: network, Err: err}}
+		}
+	}
+
+	conn, err := d.base().DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	if fault == ConnReset {
+		return &resetConn{Conn: conn, remaining: d.Profile.ResetAfter}, nil
+	}
+	return conn, nil
+}
+
+func (d *Dialer) base() ContextDialer {
+	if d.Base == nil {
+		return &net.Dialer{}
+	}
+	return d.Base
+}
+
+// notFound is the error a resolver returns for a name that does not exist
+func notFound(host string) *net.DNSError {
+	return &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// resetConn delivers remaining bytes and then fails every read and write
+// with ECONNRESET, as a connection reset by the peer does
+// Writes pass through until the reset, so a request still reaches the server
+type resetConn struct {
+	net.Conn
+	mu        sync.Mutex
+	remaining int64
+	reset     bool
+}
+
+func (c *resetConn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	remaining := c.remaining
+	c.mu.Unlock()
+	if remaining <= 0 {
+		return 0, c.fail("read")
+	}
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := c.Conn.Read(p)
+	c.mu.Lock()
+	c.remaining -= int64(n)
+	c.mu.Unlock()
+	return n, err
+}
+
+func (c *resetConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	reset := c.reset
+	c.mu.Unlock()
+	if reset {
+		return 0, c.fail("write")
+	}
+	return c.Conn.Write(p)
+}
+
+// fail resets the connection, closing it with a TCP RST where possible so
+// the peer sees the reset too, and returns the error for op
+func (c *resetConn) fail(op string) error {
+	c.mu.Lock()
+	if !c.reset {
+		c.reset = true
+		if tcp, ok := c.Conn.(*net.TCPConn); ok {
+			tcp.SetLinger(0)
+		}
+		c.Conn.Close()
+	}
+	c.mu.Unlock()
+	return &FaultError{Fault: ConnReset, Err: &net.OpError{
+		Op:     op,
+		Net:    c.Conn.LocalAddr().Network(),
+		Source: c.Conn.LocalAddr(),
+		Addr:   c.Conn.RemoteAddr(),
+		Err:    os.NewSyscallError(op, syscall.ECONNRESET),
+	}}
+}
@@ -0,0 +1,207 @@
+package flakynet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/clock"
+)
+
+const payload = "0123456789"
+
+// newBackend listens on a loopback port and writes payload to every
+// connection, returning the address to dial
+func newBackend(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			io.WriteString(conn, payload)
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestDialerNXDomain(t *testing.T) {
+	d := NewDialer(flaky.NewInjector(flaky.WithSeed(1)), Profile{NXDomain: 1})
+
+	_, err := d.Dial("tcp", "db.internal:5432")
+	if !errors.Is(err, flaky.ErrInjected) {
+		t.Fatalf("Expected an injected error, got %v", err)
+	}
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound || dnsErr.Name != "db.internal" {
+		t.Errorf("Expected a not-found *net.DNSError for db.internal, got %v", err)
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "dial" {
+		t.Errorf("Expected a dial *net.OpError, got %v", err)
+	}
+}
+
+func TestDialerNXDomainSkipsIPLiterals(t *testing.T) {
+	addr := newBackend(t)
+	d := NewDialer(flaky.NewInjector(flaky.WithSeed(1)), Profile{NXDomain: 1})
+
+	conn, err := d.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Expected %s to dial without a lookup, got %v", addr, err)
+	}
+	conn.Close()
+	if got := d.Counts(); got[None] != 1 {
+		t.Errorf("Expected one clean dial, got %v", got)
+	}
+}
+
+func TestDialerConnReset(t *testing.T) {
+	addr := newBackend(t)
+	d := NewDialer(flaky.NewInjector(flaky.WithSeed(1)), Profile{ConnReset: 1, ResetAfter: 4})
+
+	conn, err := d.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	got, err := io.ReadAll(conn)
+	if string(got) != "0123" {
+		t.Errorf("Expected 4 bytes before the reset, got %q", got)
+	}
+	if !errors.Is(err, syscall.ECONNRESET) || !errors.Is(err, flaky.ErrInjected) {
+		t.Fatalf("Expected an injected ECONNRESET, got %v", err)
+	}
+	if _, err := conn.Write([]byte("ping")); !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("Expected writes after the reset to fail with ECONNRESET, got %v", err)
+	}
+}
+
+func TestDialerSlowDial(t *testing.T) {
+	addr := newBackend(t)
+	clk := clock.NewFake(time.Unix(0, 0))
+	inj := flaky.NewInjector(flaky.WithSeed(1), flaky.WithClock(clk))
+	d := NewDialer(inj, Profile{SlowDial: 1, DialDelay: 3 * time.Second})
+
+	conn, err := d.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if got := clk.Since(time.Unix(0, 0)); got != 3*time.Second {
+		t.Errorf("Expected the dial to take 3s of fake time, got %v", got)
+	}
+}
+
+func TestDialerSlowDialHonoursContext(t *testing.T) {
+	addr := newBackend(t)
+	d := NewDialer(flaky.NewInjector(flaky.WithSeed(1)), Profile{SlowDial: 1, DialDelay: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := d.DialContext(ctx, "tcp", addr)
+	var netErr net.Error
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("Expected a timeout once the context expired, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Expected the dial to end with its context, not after %v", elapsed)
+	}
+}
+
+func TestDialerWithHTTPTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, payload)
+	}))
+	defer srv.Close()
+	d := NewDialer(flaky.NewInjector(flaky.WithSeed(1)), Profile{ConnReset: 1})
+	client := &http.Client{Transport: &http.Transport{DialContext: d.DialContext}}
+
+	_, err := client.Get(srv.URL)
+	if !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("Expected the request to fail with ECONNRESET, got %v", err)
+	}
+}
+
+func TestDialerSeededReplay(t *testing.T) {
+	addr := newBackend(t)
+	profile := Profile{ConnReset: 0.3, SlowDial: 0.3, DialDelay: time.Millisecond}
+	outcomes := func() ([]Fault, map[Fault]int) {
+		d := NewDialer(flaky.NewInjector(flaky.WithSeed(42)), profile)
+		var faults []Fault
+		for i := 0; i < 20; i++ {
+			conn, err := d.Dial("tcp", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fault := None
+			if _, ok := conn.(*resetConn); ok {
+				fault = ConnReset
+			}
+			faults = append(faults, fault)
+			conn.Close()
+		}
+		return faults, d.Counts()
+	}
+	first, counts := outcomes()
+	second, _ := outcomes()
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected the same resets under the same seed, got %v and %v", first, second)
+	}
+	if counts[None]+counts[ConnReset]+counts[SlowDial] != 20 || counts[ConnReset] == 0 || counts[SlowDial] == 0 {
+		t.Errorf("Expected 20 dials including both faults, got %v", counts)
+	}
+}
+
+func TestPick(t *testing.T) {
+	rates := Profile{NXDomain: 0.1, ConnReset: 0.2, SlowDial: 0.3}.rates(true)
+	for _, tc := range []struct {
+		draw float64
+		want Fault
+	}{
+		{0, NXDomain},
+		{0.1, ConnReset},
+		{0.29, ConnReset},
+		{0.31, SlowDial},
+		{0.61, None},
+	} {
+		if got := pick(rates, tc.draw); got != tc.want {
+			t.Errorf("pick(%v) = %s, expected %s", tc.draw, got, tc.want)
+		}
+	}
+	if got := pick(Profile{NXDomain: 1}.rates(false), 0); got != None {
+		t.Errorf("Expected no NXDomain without a lookup, got %s", got)
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	for _, p := range []Profile{
+		{NXDomain: -0.1},
+		{SlowDial: 1.5},
+		{ConnReset: 0.6, SlowDial: 0.6},
+		{ResetAfter: -1},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", p)
+		}
+	}
+	if err := (Profile{NXDomain: 0.2, ConnReset: 0.3, SlowDial: 0.5}).Validate(); err != nil {
+		t.Errorf("Expected a valid profile, got %v", err)
+	}
+}
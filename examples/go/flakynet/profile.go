@@ -0,0 +1,132 @@
+// Package flakynet injects seeded DNS and connection faults below HTTP
+//
+// Dialer and Resolver wrap the real ones so client code that dials TCP or
+// resolves names itself can be tested against NXDOMAIN answers, connections
+// reset by the peer and slow dials; every decision is drawn from a
+// flaky.Injector, so a failing sequence replays exactly under the same seed:
+//
+//	d := flakynet.NewDialer(flaky.ForTest(t), flakynet.Profile{
+//		NXDomain:  0.1,
+//		ConnReset: 0.1,
+//	})
+//	conn, err := d.DialContext(ctx, "tcp", addr)
+//
+// Dialer.DialContext also plugs into http.Transport.DialContext and
+// grpc.WithContextDialer
+//
+// TLSListener and NewTLSServer are the TLS counterpart on the server side:
+// handshakes that stall or close abruptly, and expired or mismatched
+// certificates
+package flakynet
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/example/flaky-test-example/distributions"
+)
+
+// Fault is a failure mode the dialer or resolver can inject
+type Fault string
+
+const (
+	None      Fault = "none"
+	NXDomain  Fault = "nxdomain"
+	ConnReset Fault = "conn_reset"
+	SlowDial  Fault = "slow_dial"
+)
+
+// Profile sets the probability of each fault and how it behaves
+// At most one fault is injected per dial or lookup, so the rates must sum to
+// at most 1
+type Profile struct {
+	// NXDomain fails a lookup, or a dial to a host name, with a not-found
+	// *net.DNSError; addresses that are IP literals never see it
+	NXDomain float64
+	// ConnReset dials the connection but resets it with ECONNRESET once
+	// ResetAfter bytes have been read from it
+	ConnReset float64
+	// SlowDial delays the dial by DialDelay before connecting
+	SlowDial float64
+
+	// ResetAfter is the number of bytes a reset connection delivers before
+	// failing (default 0, so the first read fails)
+	ResetAfter int64
+	// DialDelay is the delay of slow dials (default 100ms)
+	DialDelay time.Duration
+	// DialDelayDistribution, when set, draws the delay of every slow dial
+	// from it instead
+	DialDelayDistribution distributions.Distribution
+}
+
+// Validate reports rates outside [0, 1], rates that sum to more than 1 and
+// a negative ResetAfter
+func (p Profile) Validate() error {
+	var sum float64
+	for _, fr := range p.rates(true) {
+		if fr.rate < 0 || fr.rate > 1 {
+			return fmt.Errorf("flakynet: %s rate %v outside [0, 1]", fr.fault, fr.rate)
+		}
+		sum += fr.rate
+	}
+	if sum > 1 {
+		return fmt.Errorf("flakynet: fault rates sum to %v, more than 1", sum)
+	}
+	if p.ResetAfter < 0 {
+		return fmt.Errorf("flakynet: negative reset after %d", p.ResetAfter)
+	}
+	if p.DialDelayDistribution != nil {
+		if err := p.DialDelayDistribution.Validate(); err != nil {
+			return fmt.Errorf("flakynet: dial delay distribution: %w", err)
+		}
+	}
+	return nil
+}
+
+type faultRate struct {
+	fault Fault
+	rate  float64
+}
+
+// rates lists the faults of a dial, leaving NXDomain out when the address
+// needs no lookup
+func (p Profile) rates(lookup bool) []faultRate {
+	nxdomain := p.NXDomain
+	if !lookup {
+		nxdomain = 0
+	}
+	return []faultRate{
+		{NXDomain, nxdomain},
+		{ConnReset, p.ConnReset},
+		{SlowDial, p.SlowDial},
+	}
+}
+
+// pick maps a draw in [0, 1) onto a fault, partitioning the unit interval by
+// the rates in order
+func pick(rates []faultRate, draw float64) Fault {
+	var upper float64
+	for _, fr := range rates {
+		upper += fr.rate
+		if draw < upper {
+			return fr.fault
+		}
+	}
+	return None
+}
+
+func (p Profile) dialDelay() distributions.Distribution {
+	if p.DialDelayDistribution != nil {
+		return p.DialDelayDistribution
+	}
+	if p.DialDelay == 0 {
+		return distributions.Constant(100 * time.Millisecond)
+	}
+	return distributions.Constant(p.DialDelay)
+}
+
+// isIP reports whether host is an IP literal, which resolves without DNS
+func isIP(host string) bool {
+	return net.ParseIP(host) != nil
+}
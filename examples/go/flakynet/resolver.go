@@ -0,0 +1,91 @@
+package flakynet
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	flaky "github.com/example/flaky-test-example"
+)
+
+// Resolver injects NXDOMAIN answers into the lookups it passes on to Base
+// Only the NXDomain rate of its Profile applies; lookups of IP literals draw
+// but never fail, as a real resolver answers them without DNS
+// It is safe for concurrent use, but concurrent lookups consume draws in
+// scheduling order; look up names sequentially for exact replay
+type Resolver struct {
+	// Base answers lookups that are not failed; nil means net.DefaultResolver
+	Base    *net.Resolver
+	Profile Profile
+
+	inj    *flaky.Injector
+	mu     sync.Mutex
+	counts map[Fault]int
+}
+
+// NewResolver returns a Resolver drawing its faults from inj
+// It panics if the profile is invalid
+func NewResolver(inj *flaky.Injector, profile Profile) *Resolver {
+	if err := profile.Validate(); err != nil {
+		panic(err)
+	}
+	return &Resolver{Profile: profile, inj: inj, counts: make(map[Fault]int)}
+}
+
+// Counts returns how many lookups saw each fault, including None
+func (r *Resolver) Counts() map[Fault]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[Fault]int, len(r.counts))
+	for f, n := range r.counts {
+		counts[f] = n
+	}
+	return counts
+}
+
+// LookupHost looks up host, returning its addresses
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if err := r.fail(host); err != nil {
+		return nil, err
+	}
+	return r.base().LookupHost(ctx, host)
+}
+
+// LookupIPAddr looks up host, returning its IPv4 and IPv6 addresses
+func (r *Resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if err := r.fail(host); err != nil {
+		return nil, err
+	}
+	return r.base().LookupIPAddr(ctx, host)
+}
+
+// LookupIP looks up host for the network "ip", "ip4" or "ip6"
+func (r *Resolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	if err := r.fail(host); err != nil {
+		return nil, err
+	}
+	return r.base().LookupIP(ctx, network, host)
+}
+
+// fail draws whether the lookup of host answers NXDOMAIN
+func (r *Resolver) fail(host string) error {
+	rate := r.Profile.NXDomain
+	if isIP(host) {
+		rate = 0
+	}
+	fault := pick([]faultRate{{NXDomain, rate}}, r.inj.Float64())
+	r.mu.Lock()
+	r.counts[fault]++
+	r.mu.Unlock()
+	if fault == NXDomain {
+		return &FaultError{Fault: fault, Err: notFound(host)}
+	}
+	return nil
+}
+
+func (r *Resolver) base() *net.Resolver {
+	if r.Base == nil {
+		return net.DefaultResolver
+	}
+	return r.Base
+}
@@ -0,0 +1,46 @@
+package flakynet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	flaky "github.com/example/flaky-test-example"
+)
+
+func TestResolverNXDomain(t *testing.T) {
+	r := NewResolver(flaky.NewInjector(flaky.WithSeed(1)), Profile{NXDomain: 1})
+	ctx := context.Background()
+
+	_, err := r.LookupHost(ctx, "localhost")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound || !errors.Is(err, flaky.ErrInjected) {
+		t.Fatalf("Expected an injected not-found *net.DNSError, got %v", err)
+	}
+	if _, err := r.LookupIPAddr(ctx, "localhost"); !errors.As(err, &dnsErr) {
+		t.Errorf("Expected LookupIPAddr to fail, got %v", err)
+	}
+	if _, err := r.LookupIP(ctx, "ip4", "localhost"); !errors.As(err, &dnsErr) {
+		t.Errorf("Expected LookupIP to fail, got %v", err)
+	}
+	if got := r.Counts(); got[NXDomain] != 3 {
+		t.Errorf("Expected 3 NXDOMAIN answers, got %v", got)
+	}
+}
+
+func TestResolverPassesThrough(t *testing.T) {
+	r := NewResolver(flaky.NewInjector(flaky.WithSeed(1)), Profile{NXDomain: 1})
+
+	// IP literals resolve to themselves without DNS, so they never fail
+	got, err := r.LookupHost(context.Background(), "127.0.0.1")
+	if err != nil || !reflect.DeepEqual(got, []string{"127.0.0.1"}) {
+		t.Errorf("Expected [127.0.0.1], got %v, %v", got, err)
+	}
+
+	r = NewResolver(flaky.NewInjector(flaky.WithSeed(1)), Profile{})
+	if addrs, err := r.LookupIP(context.Background(), "ip4", "localhost"); err != nil || len(addrs) == 0 {
+		t.Errorf("Expected localhost to resolve, got %v, %v", addrs, err)
+	}
+}
@@ -0,0 +1,317 @@
+package flakynet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+)
+
+const (
+	HandshakeTimeout Fault = "handshake_timeout"
+	HandshakeClose   Fault = "handshake_close"
+	ExpiredCert      Fault = "expired_cert"
+	WrongHost        Fault = "wrong_host"
+)
+
+// TLSProfile sets the probability of each TLS fault a TLSListener injects
+// into the handshakes it serves
+// At most one fault is injected per connection, so the rates must sum to at
+// most 1
+type TLSProfile struct {
+	// HandshakeTimeout reads the client's hello and never answers, until the
+	// client gives up, StallFor elapses or the listener is closed
+	HandshakeTimeout float64
+	// HandshakeClose reads the client's hello and resets the connection
+	HandshakeClose float64
+	// ExpiredCert completes the handshake with a certificate that expired a
+	// day ago
+	ExpiredCert float64
+	// WrongHost completes the handshake with a certificate for another host
+	WrongHost float64
+
+	// StallFor bounds how long a stalled handshake blocks; 0 means until the
+	// client disconnects or the listener is closed
+	StallFor time.Duration
+}
+
+// Validate reports rates outside [0, 1], rates that sum to more than 1 and
+// a negative StallFor
+func (p TLSProfile) Validate() error {
+	var sum float64
+	for _, fr := range p.rates() {
+		if fr.rate < 0 || fr.rate > 1 {
+			return fmt.Errorf("flakynet: %s rate %v outside [0, 1]", fr.fault, fr.rate)
+		}
+		sum += fr.rate
+	}
+	if sum > 1 {
+		return fmt.Errorf("flakynet: fault rates sum to %v, more than 1", sum)
+	}
+	if p.StallFor < 0 {
+		return fmt.Errorf("flakynet: negative stall for %v", p.StallFor)
+	}
+	return nil
+}
+
+func (p TLSProfile) rates() []faultRate {
+	return []faultRate{
+		{HandshakeTimeout, p.HandshakeTimeout},
+		{HandshakeClose, p.HandshakeClose},
+		{ExpiredCert, p.ExpiredCert},
+		{WrongHost, p.WrongHost},
+	}
+}
+
+// TLSListener serves TLS on the connections of a listener, failing their
+// handshakes according to a TLSProfile
+// Its certificates are signed by a CA of its own, for localhost and the
+// loopback addresses; trust RootCAs, or use ClientConfig
+// The faults are the server's, so clients see the errors crypto/tls
+// returns, such as x509.CertificateInvalidError, x509.HostnameError or
+// io.EOF, rather than a flaky.ErrInjected
+// Draws are taken in the order connections are accepted; dial sequentially
+// for exact replay
+type TLSListener struct {
+	net.Listener
+	Profile TLSProfile
+
+	inj     *flaky.Injector
+	certs   *tlsCerts
+	closing chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+	counts  map[Fault]int
+}
+
+// NewTLSListener wraps ln, drawing the fault of every accepted connection
+// from inj
+// It panics if the profile is invalid
+func NewTLSListener(inj *flaky.Injector, profile TLSProfile, ln net.Listener) *TLSListener {
+	if err := profile.Validate(); err != nil {
+		panic(err)
+	}
+	certs, err := newTLSCerts(time.Now())
+	if err != nil {
+		panic(fmt.Sprintf("flakynet: generate certificates: %v", err))
+	}
+	return &TLSListener{
+		Listener: ln,
+		Profile:  profile,
+		inj:      inj,
+		certs:    certs,
+		closing:  make(chan struct{}),
+		counts:   make(map[Fault]int),
+	}
+}
+
+// RootCAs returns a pool holding the CA that signs the listener's
+// certificates
+func (l *TLSListener) RootCAs() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(l.certs.ca)
+	return pool
+}
+
+// ClientConfig returns a client TLS config trusting RootCAs
+func (l *TLSListener) ClientConfig() *tls.Config {
+	return &tls.Config{RootCAs: l.RootCAs()}
+}
+
+// Counts returns how many connections saw each fault, including None
+func (l *TLSListener) Counts() map[Fault]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	counts := make(map[Fault]int, len(l.counts))
+	for f, n := range l.counts {
+		counts[f] = n
+	}
+	return counts
+}
+
+// Accept draws a fault for the next connection and returns it as a TLS
+// server connection; connections whose handshake stalls or closes are
+// handled in the background and never returned
+func (l *TLSListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		fault := pick(l.Profile.rates(), l.inj.Float64())
+		l.mu.Lock()
+		l.counts[fault]++
+		l.mu.Unlock()
+
+		switch fault {
+		case HandshakeTimeout:
+			go l.stall(conn)
+		case HandshakeClose:
+			go closeAfterHello(conn)
+		case ExpiredCert:
+			return tls.Server(conn, serverConfig(l.certs.expired)), nil
+		case WrongHost:
+			return tls.Server(conn, serverConfig(l.certs.wrongHost)), nil
+		default:
+			return tls.Server(conn, serverConfig(l.certs.valid)), nil
+		}
+	}
+}
+
+// Close releases stalled handshakes and closes the listener
+func (l *TLSListener) Close() error {
+	l.once.Do(func() { close(l.closing) })
+	return l.Listener.Close()
+}
+
+// stall reads and discards what the client sends without answering
+func (l *TLSListener) stall(conn net.Conn) {
+	defer conn.Close()
+	if l.Profile.StallFor > 0 {
+		conn.SetDeadline(time.Now().Add(l.Profile.StallFor))
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		io.Copy(io.Discard, conn)
+	}()
+	select {
+	case <-done:
+	case <-l.closing:
+	}
+}
+
+// closeAfterHello waits for the first bytes of the client's hello, then
+// resets the connection
+func closeAfterHello(conn net.Conn) {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var header [5]byte
+	io.ReadFull(conn, header[:])
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+func serverConfig(cert tls.Certificate) *tls.Config {
+	return &tls.Config{Certificates: []tls.Certificate{cert}}
+}
+
+// TLSServer is an httptest.Server that serves over a TLSListener; closing
+// it also releases stalled handshakes
+type TLSServer struct {
+	*httptest.Server
+	Listener *TLSListener
+
+	client *http.Client
+}
+
+// NewTLSServer starts a TLSServer serving handler through the TLS faults of
+// profile, drawing them from inj; a nil handler answers 200 "ok"
+// It panics if the profile is invalid
+func NewTLSServer(inj *flaky.Injector, profile TLSProfile, handler http.Handler) *TLSServer {
+	if handler == nil {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		})
+	}
+	srv := httptest.NewUnstartedServer(handler)
+	ln := NewTLSListener(inj, profile, srv.Listener)
+	srv.Listener = ln
+	// Handshake errors are what the test is after, not server log noise
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.Start()
+	srv.URL = "https://" + ln.Addr().String()
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: ln.ClientConfig()}}
+	return &TLSServer{Server: srv, Listener: ln, client: client}
+}
+
+// Client returns an HTTP client trusting the server's CA; it reuses
+// connections, so every new connection it makes draws a fault
+func (s *TLSServer) Client() *http.Client {
+	return s.client
+}
+
+// Close shuts the server down and closes the client's idle connections
+func (s *TLSServer) Close() {
+	s.Server.Close()
+	s.client.CloseIdleConnections()
+}
+
+// tlsCerts are a CA and the server certificates it signed
+type tlsCerts struct {
+	ca                        *x509.Certificate
+	valid, expired, wrongHost tls.Certificate
+}
+
+func newTLSCerts(now time.Time) (*tlsCerts, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "flakynet test CA"},
+		NotBefore:             now.Add(-72 * time.Hour),
+		NotAfter:              now.Add(72 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := &tlsCerts{ca: ca}
+	loopback := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	for i, leaf := range []struct {
+		cert      *tls.Certificate
+		dnsNames  []string
+		ips       []net.IP
+		notBefore time.Time
+		notAfter  time.Time
+	}{
+		{&certs.valid, []string{"localhost"}, loopback, now.Add(-time.Hour), now.Add(24 * time.Hour)},
+		{&certs.expired, []string{"localhost"}, loopback, now.Add(-48 * time.Hour), now.Add(-24 * time.Hour)},
+		{&certs.wrongHost, []string{"wrong-host.invalid"}, nil, now.Add(-time.Hour), now.Add(24 * time.Hour)},
+	} {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 2)),
+			Subject:      pkix.Name{CommonName: leaf.dnsNames[0]},
+			DNSNames:     leaf.dnsNames,
+			IPAddresses:  leaf.ips,
+			NotBefore:    leaf.notBefore,
+			NotAfter:     leaf.notAfter,
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		if err != nil {
+			return nil, err
+		}
+		*leaf.cert = tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	}
+	return certs, nil
+}
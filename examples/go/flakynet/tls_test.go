@@ -0,0 +1,131 @@
+package flakynet
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+)
+
+func newTLSServer(t *testing.T, seed int64, profile TLSProfile) *TLSServer {
+	t.Helper()
+	srv := NewTLSServer(flaky.NewInjector(flaky.WithSeed(seed)), profile, nil)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTLSServerPassesThrough(t *testing.T) {
+	srv := newTLSServer(t, 1, TLSProfile{})
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" || resp.TLS == nil {
+		t.Errorf("Expected 200 \"ok\" over TLS, got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestTLSServerExpiredCert(t *testing.T) {
+	srv := newTLSServer(t, 1, TLSProfile{ExpiredCert: 1})
+	_, err := srv.Client().Get(srv.URL)
+	var invalid x509.CertificateInvalidError
+	if !errors.As(err, &invalid) || invalid.Reason != x509.Expired {
+		t.Errorf("Expected an expired certificate error, got %v", err)
+	}
+}
+
+func TestTLSServerWrongHost(t *testing.T) {
+	srv := newTLSServer(t, 1, TLSProfile{WrongHost: 1})
+	_, err := srv.Client().Get(srv.URL)
+	var hostErr x509.HostnameError
+	if !errors.As(err, &hostErr) {
+		t.Errorf("Expected a hostname mismatch, got %v", err)
+	}
+}
+
+func TestTLSServerHandshakeClose(t *testing.T) {
+	srv := newTLSServer(t, 1, TLSProfile{HandshakeClose: 1})
+	conn, err := tls.Dial("tcp", srv.Listener.Addr().String(), srv.Listener.ClientConfig())
+	if err == nil {
+		conn.Close()
+		t.Fatal("Expected the handshake to fail")
+	}
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		t.Errorf("Expected the connection to close mid-handshake, got a certificate error %v", err)
+	}
+}
+
+func TestTLSServerHandshakeTimeout(t *testing.T) {
+	srv := newTLSServer(t, 1, TLSProfile{HandshakeTimeout: 1})
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(50 * time.Millisecond))
+	cfg := srv.Listener.ClientConfig()
+	cfg.ServerName = "localhost"
+	err = tls.Client(conn, cfg).Handshake()
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("Expected the handshake to time out, got %v", err)
+	}
+}
+
+func TestTLSServerStallFor(t *testing.T) {
+	srv := newTLSServer(t, 1, TLSProfile{HandshakeTimeout: 1, StallFor: 20 * time.Millisecond})
+	start := time.Now()
+	_, err := tls.Dial("tcp", srv.Listener.Addr().String(), srv.Listener.ClientConfig())
+	if err == nil {
+		t.Fatal("Expected the stalled handshake to fail")
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("Expected the handshake to stall about 20ms, took %v", elapsed)
+	}
+}
+
+func TestTLSListenerReplaysFaults(t *testing.T) {
+	profile := TLSProfile{ExpiredCert: 0.3, WrongHost: 0.3}
+	outcomes := func() ([]bool, map[Fault]int) {
+		srv := newTLSServer(t, 9, profile)
+		var ok []bool
+		for i := 0; i < 20; i++ {
+			conn, err := tls.Dial("tcp", srv.Listener.Addr().String(), srv.Listener.ClientConfig())
+			if err == nil {
+				conn.Close()
+			}
+			ok = append(ok, err == nil)
+		}
+		return ok, srv.Listener.Counts()
+	}
+	first, firstCounts := outcomes()
+	second, secondCounts := outcomes()
+	if !reflect.DeepEqual(first, second) || !reflect.DeepEqual(firstCounts, secondCounts) {
+		t.Errorf("Expected the same handshakes to fail under the same seed, got %v then %v", firstCounts, secondCounts)
+	}
+	if firstCounts[None] == 0 || firstCounts[ExpiredCert] == 0 || firstCounts[WrongHost] == 0 {
+		t.Errorf("Expected a mix of faults over 20 handshakes, got %v", firstCounts)
+	}
+}
+
+func TestTLSProfileValidate(t *testing.T) {
+	for _, p := range []TLSProfile{
+		{ExpiredCert: -0.1},
+		{HandshakeTimeout: 0.6, WrongHost: 0.6},
+		{StallFor: -time.Second},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", p)
+		}
+	}
+}
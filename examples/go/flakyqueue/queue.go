@@ -0,0 +1,261 @@
+// Package flakyqueue is an in-memory at-least-once message queue that
+// duplicates, reorders and delays messages, for testing idempotent consumers
+//
+// Every publish draws at most one fault from a flaky.Injector, and the queue
+// reads the time from a clock, so with a clock.FakeClock a failing delivery
+// sequence replays exactly under the same seed:
+//
+//	clk := clock.NewFake(time.Now())
+//	q := flakyqueue.New(flaky.ForTest(t), flakyqueue.Profile{
+//		Duplicate: 0.1,
+//		Reorder:   0.1,
+//	}, clk)
+//
+// Like SQS or Pub/Sub, a received message that is not acked within the
+// visibility timeout is delivered again
+package flakyqueue
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/clock"
+	"github.com/example/flaky-test-example/distributions"
+)
+
+// Fault is a delivery anomaly the queue can inject
+type Fault string
+
+const (
+	None      Fault = "none"
+	Duplicate Fault = "duplicate"
+	Reorder   Fault = "reorder"
+	Delay     Fault = "delay"
+)
+
+// Profile sets the probability of each fault and how it behaves
+// At most one fault is injected per published message, so the rates must
+// sum to at most 1
+type Profile struct {
+	// Duplicate delivers the message twice, as a lost ack does
+	Duplicate float64
+	// Reorder delivers the message before the one published just before it,
+	// if that one is still waiting
+	Reorder float64
+	// Delay holds the message back for a delay drawn from MinDelay to
+	// MaxDelay, so messages published after it overtake it
+	Delay float64
+
+	// MinDelay and MaxDelay bound the uniformly drawn delay of delayed
+	// messages
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	// DelayDistribution, when set, draws the delay of delayed messages from
+	// it instead of from MinDelay to MaxDelay
+	DelayDistribution distributions.Distribution
+	// VisibilityTimeout is how long a received message may go unacked before
+	// it is delivered again (default 30s)
+	VisibilityTimeout time.Duration
+}
+
+// Validate reports rates outside [0, 1], rates that sum to more than 1 and
+// negative or inverted delay bounds
+func (p Profile) Validate() error {
+	var sum float64
+	for _, fr := range p.rates() {
+		if fr.rate < 0 || fr.rate > 1 {
+			return fmt.Errorf("flakyqueue: %s rate %v outside [0, 1]", fr.fault, fr.rate)
+		}
+		sum += fr.rate
+	}
+	if sum > 1 {
+		return fmt.Errorf("flakyqueue: fault rates sum to %v, more than 1", sum)
+	}
+	if p.MinDelay < 0 {
+		return fmt.Errorf("flakyqueue: negative min delay %v", p.MinDelay)
+	}
+	if p.MaxDelay < p.MinDelay {
+		return fmt.Errorf("flakyqueue: max delay %v below min delay %v", p.MaxDelay, p.MinDelay)
+	}
+	if p.DelayDistribution != nil {
+		if err := p.DelayDistribution.Validate(); err != nil {
+			return fmt.Errorf("flakyqueue: delay distribution: %w", err)
+		}
+	}
+	return nil
+}
+
+type faultRate struct {
+	fault Fault
+	rate  float64
+}
+
+func (p Profile) rates() []faultRate {
+	return []faultRate{
+		{Duplicate, p.Duplicate},
+		{Reorder, p.Reorder},
+		{Delay, p.Delay},
+	}
+}
+
+// pick maps a draw in [0, 1) onto a fault, partitioning the unit interval by
+// the rates in order
+func pick(rates []faultRate, draw float64) Fault {
+	var upper float64
+	for _, fr := range rates {
+		upper += fr.rate
+		if draw < upper {
+			return fr.fault
+		}
+	}
+	return None
+}
+
+func (p Profile) visibilityTimeout() time.Duration {
+	if p.VisibilityTimeout == 0 {
+		return 30 * time.Second
+	}
+	return p.VisibilityTimeout
+}
+
+// Message is one delivery of a published message
+// Duplicates and redeliveries carry the same ID, which is what an idempotent
+// consumer keys on
+type Message struct {
+	ID   string
+	Body string
+	// Attempt counts the deliveries of this copy, starting at 1
+	Attempt int
+
+	e *entry
+}
+
+// entry is one copy of a message waiting in or received from the queue
+type entry struct {
+	id        string
+	body      string
+	readyAt   time.Time
+	attempts  int
+	inFlight  bool
+	invisible time.Time
+}
+
+// Queue is an at-least-once queue
+// It is safe for concurrent use, but concurrent publishes consume draws in
+// scheduling order; publish sequentially for exact replay
+type Queue struct {
+	Profile Profile
+
+	inj     *flaky.Injector
+	clk     clock.Clock
+	mu      sync.Mutex
+	entries []*entry
+	nextID  int
+	counts  map[Fault]int
+}
+
+// New returns an empty Queue drawing its faults from inj and reading the
+// time from clk; nil means the real clock
+// It panics if the profile is invalid
+func New(inj *flaky.Injector, profile Profile, clk clock.Clock) *Queue {
+	if err := profile.Validate(); err != nil {
+		panic(err)
+	}
+	if clk == nil {
+		clk = clock.Real()
+	}
+	return &Queue{Profile: profile, inj: inj, clk: clk, counts: make(map[Fault]int)}
+}
+
+// Counts returns how many published messages saw each fault, including None
+func (q *Queue) Counts() map[Fault]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	counts := make(map[Fault]int, len(q.counts))
+	for f, n := range q.counts {
+		counts[f] = n
+	}
+	return counts
+}
+
+// Publish adds body to the queue and returns its message ID
+func (q *Queue) Publish(body string) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	fault := pick(q.Profile.rates(), q.inj.Float64())
+	q.counts[fault]++
+	q.nextID++
+	now := q.clk.Now()
+	e := &entry{id: fmt.Sprintf("m%d", q.nextID), body: body, readyAt: now}
+
+	switch fault {
+	case Duplicate:
+		dup := *e
+		q.entries = append(q.entries, e, &dup)
+	case Reorder:
+		if n := len(q.entries); n > 0 && !q.entries[n-1].inFlight {
+			q.entries = append(q.entries[:n-1], e, q.entries[n-1])
+		} else {
+			q.entries = append(q.entries, e)
+		}
+	case Delay:
+		e.readyAt = now.Add(q.delay())
+		q.entries = append(q.entries, e)
+	default:
+		q.entries = append(q.entries, e)
+	}
+	return e.id
+}
+
+// delay draws how long a delayed message is held back
+func (q *Queue) delay() time.Duration {
+	if q.Profile.DelayDistribution != nil {
+		return q.inj.Draw(q.Profile.DelayDistribution)
+	}
+	span := q.Profile.MaxDelay - q.Profile.MinDelay
+	return q.Profile.MinDelay + time.Duration(q.inj.Float64()*float64(span))
+}
+
+// Receive returns the first message that is ready, or false if none is
+// The message stays in the queue, invisible to other receives, until it is
+// acked; after the visibility timeout it is delivered again
+func (q *Queue) Receive() (*Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.clk.Now()
+	for _, e := range q.entries {
+		if e.readyAt.After(now) || (e.inFlight && e.invisible.After(now)) {
+			continue
+		}
+		e.attempts++
+		e.inFlight = true
+		e.invisible = now.Add(q.Profile.visibilityTimeout())
+		return &Message{ID: e.id, Body: e.body, Attempt: e.attempts, e: e}, true
+	}
+	return nil, false
+}
+
+// Ack removes the copy m was delivered from, reporting false if it was
+// already acked
+// Acking one copy of a duplicated message leaves the other to be delivered
+func (q *Queue) Ack(m *Message) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, e := range q.entries {
+		if e == m.e {
+			q.entries = append(q.entries[:i], q.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns how many message copies are not acked yet, waiting, delayed or
+// in flight
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
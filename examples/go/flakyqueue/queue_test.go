@@ -0,0 +1,150 @@
+package flakyqueue
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/clock"
+)
+
+func newQueue(t *testing.T, profile Profile) (*Queue, *clock.FakeClock) {
+	t.Helper()
+	clk := clock.NewFake(time.Unix(0, 0))
+	return New(flaky.NewInjector(flaky.WithSeed(1)), profile, clk), clk
+}
+
+// drain receives and acks every ready message, returning the bodies in
+// delivery order
+func drain(q *Queue) []string {
+	var bodies []string
+	for {
+		m, ok := q.Receive()
+		if !ok {
+			return bodies
+		}
+		bodies = append(bodies, m.Body)
+		q.Ack(m)
+	}
+}
+
+func TestDeliversInOrder(t *testing.T) {
+	q, _ := newQueue(t, Profile{})
+	for _, body := range []string{"a", "b", "c"} {
+		q.Publish(body)
+	}
+	if got := drain(q); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("Expected a b c, got %v", got)
+	}
+	if q.Len() != 0 {
+		t.Errorf("Expected an empty queue, got %d", q.Len())
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	q, _ := newQueue(t, Profile{Duplicate: 1})
+	id := q.Publish("a")
+
+	first, _ := q.Receive()
+	second, ok := q.Receive()
+	if !ok || first.ID != id || second.ID != id || second.Body != "a" {
+		t.Fatalf("Expected two deliveries of %s, got %+v and %+v", id, first, second)
+	}
+	if !q.Ack(first) || q.Len() != 1 {
+		t.Errorf("Expected acking one copy to leave the other, got %d left", q.Len())
+	}
+	if q.Ack(first) {
+		t.Error("Expected a second ack of the same copy to report false")
+	}
+}
+
+func TestReorder(t *testing.T) {
+	q, _ := newQueue(t, Profile{})
+	q.Publish("a")
+	q.Profile.Reorder = 1
+	q.Publish("b")
+	if got := drain(q); !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Errorf("Expected b to overtake a, got %v", got)
+	}
+}
+
+func TestReorderDoesNotOvertakeInFlight(t *testing.T) {
+	q, _ := newQueue(t, Profile{})
+	q.Publish("a")
+	a, _ := q.Receive()
+	q.Profile.Reorder = 1
+	q.Publish("b")
+	if q.entries[0].id != a.ID {
+		t.Errorf("Expected b to queue behind the in-flight a, got %s first", q.entries[0].id)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	q, clk := newQueue(t, Profile{Delay: 1, MinDelay: time.Second, MaxDelay: time.Second})
+	q.Publish("a")
+	q.Profile.Delay = 0
+	q.Publish("b")
+
+	if got := drain(q); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("Expected only b before the delay, got %v", got)
+	}
+	clk.Advance(time.Second)
+	if got := drain(q); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("Expected a after the delay, got %v", got)
+	}
+}
+
+func TestRedeliversUnacked(t *testing.T) {
+	q, clk := newQueue(t, Profile{VisibilityTimeout: 10 * time.Second})
+	q.Publish("a")
+
+	m, _ := q.Receive()
+	if _, ok := q.Receive(); ok {
+		t.Fatal("Expected the in-flight message to be invisible")
+	}
+	clk.Advance(10 * time.Second)
+	again, ok := q.Receive()
+	if !ok || again.ID != m.ID || again.Attempt != 2 {
+		t.Fatalf("Expected %s again on attempt 2, got %+v", m.ID, again)
+	}
+	q.Ack(again)
+	if q.Len() != 0 {
+		t.Errorf("Expected an empty queue, got %d", q.Len())
+	}
+}
+
+func TestSeededReplay(t *testing.T) {
+	outcomes := func() ([]string, map[Fault]int) {
+		q := New(flaky.NewInjector(flaky.WithSeed(42)), Profile{Duplicate: 0.2, Reorder: 0.2}, clock.NewFake(time.Unix(0, 0)))
+		for _, body := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+			q.Publish(body)
+		}
+		return drain(q), q.Counts()
+	}
+	first, counts := outcomes()
+	second, _ := outcomes()
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected the same deliveries under the same seed, got %v and %v", first, second)
+	}
+	if counts[None]+counts[Duplicate]+counts[Reorder] != 10 || len(first) != 10+counts[Duplicate] {
+		t.Errorf("Expected 10 publishes and one extra delivery per duplicate, got %v and %v", counts, first)
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	for _, p := range []Profile{
+		{Duplicate: -0.1},
+		{Delay: 1.5},
+		{Duplicate: 0.5, Reorder: 0.3, Delay: 0.3},
+		{MinDelay: -time.Second},
+		{MinDelay: time.Second, MaxDelay: time.Millisecond},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", p)
+		}
+	}
+	if err := (Profile{Duplicate: 0.5, Reorder: 0.5, MaxDelay: time.Second}).Validate(); err != nil {
+		t.Errorf("Expected a valid profile, got %v", err)
+	}
+}
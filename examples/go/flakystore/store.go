@@ -0,0 +1,219 @@
+// Package flakystore is an in-memory key-value store whose reads lag its
+// writes, for testing code against eventual consistency
+//
+// Each write is stale with a seeded probability: it stays invisible to Get
+// for a lag drawn from the profile's range, as a write that has not reached
+// the replica serving the read yet. Reads compare against a clock, so a
+// clock.FakeClock makes the lag instant to wait out and the whole sequence
+// replays under the same seed:
+//
+//	clk := clock.NewFake(time.Now())
+//	store := flakystore.New(flaky.ForTest(t), flakystore.Profile{
+//		Stale:  0.3,
+//		MinLag: 10 * time.Millisecond,
+//		MaxLag: 100 * time.Millisecond,
+//	}, clk)
+//
+// GetLatest is the strongly consistent read, such as a quorum read, for the
+// code paths that need one
+package flakystore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/clock"
+	"github.com/example/flaky-test-example/distributions"
+)
+
+// Fault is what happened to a write
+type Fault string
+
+const (
+	None  Fault = "none"
+	Stale Fault = "stale"
+)
+
+// Profile sets how often writes lag and by how much
+type Profile struct {
+	// Stale is the probability that a write is invisible to Get for a while
+	Stale float64
+	// MinLag and MaxLag bound the uniformly drawn lag of stale writes
+	MinLag time.Duration
+	MaxLag time.Duration
+	// LagDistribution, when set, draws the lag of stale writes from it
+	// instead of from MinLag to MaxLag
+	LagDistribution distributions.Distribution
+}
+
+// Validate reports a rate outside [0, 1] and negative or inverted lag bounds
+func (p Profile) Validate() error {
+	if p.Stale < 0 || p.Stale > 1 {
+		return fmt.Errorf("flakystore: stale rate %v outside [0, 1]", p.Stale)
+	}
+	if p.MinLag < 0 {
+		return fmt.Errorf("flakystore: negative min lag %v", p.MinLag)
+	}
+	if p.MaxLag < p.MinLag {
+		return fmt.Errorf("flakystore: max lag %v below min lag %v", p.MaxLag, p.MinLag)
+	}
+	if p.LagDistribution != nil {
+		if err := p.LagDistribution.Validate(); err != nil {
+			return fmt.Errorf("flakystore: lag distribution: %w", err)
+		}
+	}
+	return nil
+}
+
+// version is one write of a key; deleted marks a tombstone
+type version struct {
+	value     string
+	deleted   bool
+	visibleAt time.Time
+}
+
+// Store is an eventually consistent key-value store
+// It is safe for concurrent use, but concurrent writes consume draws in
+// scheduling order; write sequentially for exact replay
+type Store struct {
+	Profile Profile
+
+	inj    *flaky.Injector
+	clk    clock.Clock
+	mu     sync.Mutex
+	keys   map[string][]version
+	counts map[Fault]int
+}
+
+// New returns an empty Store drawing its lags from inj and reading the time
+// from clk; nil means the real clock
+// It panics if the profile is invalid
+func New(inj *flaky.Injector, profile Profile, clk clock.Clock) *Store {
+	if err := profile.Validate(); err != nil {
+		panic(err)
+	}
+	if clk == nil {
+		clk = clock.Real()
+	}
+	return &Store{Profile: profile, inj: inj, clk: clk, keys: make(map[string][]version), counts: make(map[Fault]int)}
+}
+
+// Counts returns how many writes saw each fault, including None
+func (s *Store) Counts() map[Fault]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[Fault]int, len(s.counts))
+	for f, n := range s.counts {
+		counts[f] = n
+	}
+	return counts
+}
+
+// Put sets key to value, drawing whether and for how long the write lags
+func (s *Store) Put(key, value string) {
+	s.write(key, version{value: value})
+}
+
+// Delete removes key, with the same lag as a Put
+func (s *Store) Delete(key string) {
+	s.write(key, version{deleted: true})
+}
+
+// lag draws how long a stale write stays invisible
+func (s *Store) lag() time.Duration {
+	if s.Profile.LagDistribution != nil {
+		return s.inj.Draw(s.Profile.LagDistribution)
+	}
+	return s.Profile.MinLag + time.Duration(s.inj.Float64()*float64(s.Profile.MaxLag-s.Profile.MinLag))
+}
+
+func (s *Store) write(key string, v version) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fault, lag := None, time.Duration(0)
+	if s.inj.Float64() < s.Profile.Stale {
+		fault = Stale
+		lag = s.lag()
+	}
+	s.counts[fault]++
+	now := s.clk.Now()
+	v.visibleAt = now.Add(lag)
+	// Versions older than the newest visible one can no longer be read
+	versions := s.keys[key]
+	for i := len(versions) - 1; i > 0; i-- {
+		if !versions[i].visibleAt.After(now) {
+			versions = versions[i:]
+			break
+		}
+	}
+	s.keys[key] = append(versions, v)
+}
+
+// Get returns the value of the newest write of key that has become visible
+// A stale write hides behind the writes before it until its lag has passed,
+// so Get can return an older value or report a just-written key missing
+// Once a write is visible, Get never goes back to an older one
+func (s *Store) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clk.Now()
+	versions := s.keys[key]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if !versions[i].visibleAt.After(now) {
+			return versions[i].value, !versions[i].deleted
+		}
+	}
+	return "", false
+}
+
+// GetLatest returns the value of the newest write of key, visible or not
+func (s *Store) GetLatest(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions := s.keys[key]
+	if len(versions) == 0 {
+		return "", false
+	}
+	v := versions[len(versions)-1]
+	return v.value, !v.deleted
+}
+
+// List returns the keys with prefix that Get currently finds, sorted
+func (s *Store) List(prefix string) []string {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.keys))
+	for key := range s.keys {
+		if strings.HasPrefix(key, prefix) {
+			names = append(names, key)
+		}
+	}
+	s.mu.Unlock()
+
+	var keys []string
+	for _, key := range names {
+		if _, ok := s.Get(key); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Pending returns how many writes Get will still start returning once their
+// lag passes; a stale write overtaken by a visible newer one never will
+func (s *Store) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clk.Now()
+	n := 0
+	for _, versions := range s.keys {
+		for i := len(versions) - 1; i >= 0 && versions[i].visibleAt.After(now); i-- {
+			n++
+		}
+	}
+	return n
+}
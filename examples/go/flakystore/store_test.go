@@ -0,0 +1,151 @@
+package flakystore
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/clock"
+)
+
+func newStore(t *testing.T, profile Profile) (*Store, *clock.FakeClock) {
+	t.Helper()
+	clk := clock.NewFake(time.Unix(0, 0))
+	return New(flaky.NewInjector(flaky.WithSeed(1)), profile, clk), clk
+}
+
+func TestConsistentWrites(t *testing.T) {
+	s, _ := newStore(t, Profile{})
+	s.Put("a", "1")
+	s.Put("a", "2")
+	if got, ok := s.Get("a"); !ok || got != "2" {
+		t.Errorf("Expected 2, got %q, %v", got, ok)
+	}
+	s.Delete("a")
+	if _, ok := s.Get("a"); ok {
+		t.Error("Expected a to be deleted")
+	}
+	if got := s.Counts(); got[None] != 3 || got[Stale] != 0 {
+		t.Errorf("Expected 3 consistent writes, got %v", got)
+	}
+}
+
+func TestStaleWriteBecomesVisible(t *testing.T) {
+	s, clk := newStore(t, Profile{Stale: 1, MinLag: time.Second, MaxLag: time.Second})
+
+	s.Put("a", "1")
+	if _, ok := s.Get("a"); ok {
+		t.Fatal("Expected the write to be invisible at first")
+	}
+	if got, ok := s.GetLatest("a"); !ok || got != "1" {
+		t.Errorf("Expected GetLatest to see 1, got %q, %v", got, ok)
+	}
+	if s.Pending() != 1 {
+		t.Errorf("Expected 1 pending write, got %d", s.Pending())
+	}
+	clk.Advance(time.Second)
+	if got, ok := s.Get("a"); !ok || got != "1" {
+		t.Errorf("Expected 1 after the lag, got %q, %v", got, ok)
+	}
+	if s.Pending() != 0 {
+		t.Errorf("Expected no pending writes, got %d", s.Pending())
+	}
+}
+
+func TestStaleWriteHidesBehindOlderValue(t *testing.T) {
+	s, clk := newStore(t, Profile{})
+	s.Put("a", "old")
+	s.Profile = Profile{Stale: 1, MinLag: time.Second, MaxLag: time.Second}
+	s.Put("a", "new")
+
+	if got, _ := s.Get("a"); got != "old" {
+		t.Errorf("Expected the old value during the lag, got %q", got)
+	}
+	clk.Advance(time.Second)
+	if got, _ := s.Get("a"); got != "new" {
+		t.Errorf("Expected the new value after the lag, got %q", got)
+	}
+}
+
+func TestNewerVisibleWriteOvertakesStaleOne(t *testing.T) {
+	s, clk := newStore(t, Profile{Stale: 1, MinLag: time.Minute, MaxLag: time.Minute})
+	s.Put("a", "slow")
+	s.Profile = Profile{}
+	s.Put("a", "fast")
+
+	if got, _ := s.Get("a"); got != "fast" {
+		t.Errorf("Expected the newer visible write, got %q", got)
+	}
+	if s.Pending() != 0 {
+		t.Errorf("Expected the overtaken write not to count as pending, got %d", s.Pending())
+	}
+	clk.Advance(time.Minute)
+	if got, _ := s.Get("a"); got != "fast" {
+		t.Errorf("Expected Get not to go back to the older write, got %q", got)
+	}
+}
+
+func TestList(t *testing.T) {
+	s, _ := newStore(t, Profile{})
+	s.Put("user/2", "b")
+	s.Put("user/1", "a")
+	s.Put("order/1", "x")
+	s.Put("user/3", "c")
+	s.Delete("user/3")
+	s.Profile = Profile{Stale: 1, MinLag: time.Second, MaxLag: time.Second}
+	s.Put("user/4", "d")
+
+	if got, want := s.List("user/"), []string{"user/1", "user/2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestLagsWithinRange(t *testing.T) {
+	s, clk := newStore(t, Profile{Stale: 1, MinLag: 10 * time.Millisecond, MaxLag: 50 * time.Millisecond})
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		s.Put(key, "v")
+	}
+	clk.Advance(10*time.Millisecond - 1)
+	if s.Pending() != 8 {
+		t.Errorf("Expected every write pending before the min lag, got %d", s.Pending())
+	}
+	clk.Advance(40*time.Millisecond + 1)
+	if s.Pending() != 0 {
+		t.Errorf("Expected every write visible at the max lag, got %d", s.Pending())
+	}
+}
+
+func TestSeededReplay(t *testing.T) {
+	outcomes := func() []bool {
+		s := New(flaky.NewInjector(flaky.WithSeed(42)), Profile{Stale: 0.5, MaxLag: time.Second}, clock.NewFake(time.Unix(0, 0)))
+		var seen []bool
+		for i := 0; i < 20; i++ {
+			s.Put("k", "v")
+			_, ok := s.Get("k")
+			seen = append(seen, ok)
+			s.Delete("k")
+		}
+		return seen
+	}
+	first, second := outcomes(), outcomes()
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected the same reads under the same seed, got %v and %v", first, second)
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	for _, p := range []Profile{
+		{Stale: -0.1},
+		{Stale: 1.5},
+		{MinLag: -time.Second},
+		{MinLag: time.Second, MaxLag: time.Millisecond},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", p)
+		}
+	}
+	if err := (Profile{Stale: 1, MinLag: time.Millisecond, MaxLag: time.Second}).Validate(); err != nil {
+		t.Errorf("Expected a valid profile, got %v", err)
+	}
+}
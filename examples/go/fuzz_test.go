@@ -0,0 +1,103 @@
+package flaky_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	flaky "github.com/example/flaky-test-example"
+)
+
+// joinFields joins a record's fields with commas, quoting the fields that
+// hold a comma or a quote and doubling their quotes
+func joinFields(fields []string) string {
+	quoted := make([]string, len(fields))
+	for i, field := range fields {
+		quoted[i] = field
+		if strings.ContainsAny(field, `,"`) {
+			quoted[i] = `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+		}
+	}
+	return strings.Join(quoted, ",")
+}
+
+// splitFields splits a record joinFields built back into its fields
+func splitFields(record string) []string {
+	var fields []string
+	var field strings.Builder
+	quoted := false
+	for i := 0; i < len(record); i++ {
+		switch c := record[i]; {
+		case c == '"' && quoted && i+1 < len(record) && record[i+1] == '"':
+			field.WriteByte('"')
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(c)
+		}
+	}
+	return append(fields, field.String())
+}
+
+// splitFieldsFast is the vectorized path some hosts enable: it splits on
+// every comma before unquoting, which is only right when no quoted field
+// holds a comma
+func splitFieldsFast(record string) []string {
+	fields := strings.Split(record, ",")
+	for i, field := range fields {
+		if len(field) >= 2 && strings.HasPrefix(field, `"`) && strings.HasSuffix(field, `"`) {
+			fields[i] = strings.ReplaceAll(field[1:len(field)-1], `""`, `"`)
+		}
+	}
+	return fields
+}
+
+// addParserCorpus adds the seed corpus of the parser fuzz targets; only the
+// entries with a comma in them can be split wrongly
+// FuzzFlakyParser also reads testdata/fuzz/FuzzFlakyParser
+func addParserCorpus(f *testing.F) {
+	for _, field := range []string{"plain", "", "Paris, France", `say "hi"`} {
+		f.Add(field)
+	}
+}
+
+// FuzzFlakyParser demonstrates a fuzz target whose seed corpus entries fail
+// only under some injector states
+// Each entry draws from its own seed whether it runs on the fast path (30%
+// of the time by default); only the entries with a comma then fail
+func FuzzFlakyParser(f *testing.F) {
+	addParserCorpus(f)
+	f.Fuzz(func(t *testing.T, field string) {
+		sc := flaky.ScenarioForTest(t, "FlakyParser")
+		split := splitFields
+		if flaky.ForTest(t).Float64() < sc.FailureRate {
+			split = splitFieldsFast
+		}
+		want := []string{"id", field}
+		if got := split(joinFields(want)); !slices.Equal(got, want) {
+			t.Errorf("%s: expected %q, got %q", sc.Message, want, got)
+		}
+	})
+}
+
+// FuzzFlakyParserFixed is the reliable variant of FuzzFlakyParser
+// The fast path is only taken for records without quotes
+func FuzzFlakyParserFixed(f *testing.F) {
+	addParserCorpus(f)
+	f.Fuzz(func(t *testing.T, field string) {
+		sc := flaky.ScenarioForTest(t, "FlakyParser")
+		want := []string{"id", field}
+		record := joinFields(want)
+		split := splitFields
+		if flaky.ForTest(t).Float64() < sc.FailureRate && !strings.Contains(record, `"`) {
+			split = splitFieldsFast
+		}
+		if got := split(record); !slices.Equal(got, want) {
+			t.Errorf("%s: expected %q, got %q", sc.Message, want, got)
+		}
+	})
+}
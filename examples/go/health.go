@@ -0,0 +1,55 @@
+package flaky
+
+import (
+	"fmt"
+	"math"
+)
+
+// healthStream names the seed stream of the environment's health, apart
+// from every test's so drawing it never shifts a test's draws
+const healthStream = "flaky/health"
+
+// Health is the state of the environment in one run, shared by every test
+// of the run, for modeling infrastructure-wide incidents such as a degraded
+// database that fail unrelated tests together
+// Level is one seeded draw per run in [0, 1): the lower it is, the worse the
+// run's incident, so a scenario is hit when Level is below its Incident's
+// Chance; scenarios with the same Chance are hit in the same runs, and a
+// rarer incident's runs are among a commoner one's
+type Health struct {
+	Run   int64
+	Level float64
+}
+
+// HealthAt returns the environment's health at run, the same for every test
+// and package under the run's seed
+func HealthAt(run int64) Health {
+	inj := NewInjector(WithSeed(SeedFor(FirstRunSeed+run, healthStream)))
+	return Health{Run: run, Level: inj.Float64()}
+}
+
+// Hit reports whether an incident of the given chance hits the run
+func (h Health) Hit(chance float64) bool {
+	return h.Level < chance
+}
+
+// Incident makes a scenario fail with the environment rather than on its
+// own: in the runs an incident hits, see Health, it fails at FailureRate in
+// place of the scenario's
+type Incident struct {
+	// Chance is the probability that an incident hits a run
+	Chance float64 `json:"chance" yaml:"chance"`
+	// FailureRate is the scenario's failure rate in the runs hit
+	FailureRate float64 `json:"failure_rate" yaml:"failure_rate"`
+}
+
+// Validate reports a chance or failure rate outside [0, 1]
+func (i Incident) Validate() error {
+	if i.Chance < 0 || i.Chance > 1 || math.IsNaN(i.Chance) {
+		return fmt.Errorf("incident chance %v outside [0, 1]", i.Chance)
+	}
+	if i.FailureRate < 0 || i.FailureRate > 1 || math.IsNaN(i.FailureRate) {
+		return fmt.Errorf("incident failure_rate %v outside [0, 1]", i.FailureRate)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package flaky
+
+import (
+	"strconv"
+	"testing"
+)
+
+// TestHealthShared verifies the health of a run is the same for every
+// scenario and changes between runs
+func TestHealthShared(t *testing.T) {
+	if HealthAt(7) != HealthAt(7) {
+		t.Error("Expected the same health at the same run")
+	}
+	hit := 0
+	for run := int64(0); run < 1000; run++ {
+		if HealthAt(run).Hit(0.1) {
+			hit++
+		}
+	}
+	if hit < 70 || hit > 130 {
+		t.Errorf("Expected about 100 of 1000 runs hit at chance 0.1, got %d", hit)
+	}
+}
+
+// TestScenarioIncident verifies incident-prone scenarios fail at their
+// incident rate in the same runs
+func TestScenarioIncident(t *testing.T) {
+	db := Scenario{Name: "DB", FailureRate: 0, Incident: &Incident{Chance: 0.2, FailureRate: 1}}
+	cache := Scenario{Name: "Cache", FailureRate: 0, Incident: &Incident{Chance: 0.2, FailureRate: 1}}
+	hit := 0
+	for run := int64(0); run < 100; run++ {
+		a, b := db.AtRun(run), cache.AtRun(run)
+		if a.FailureRate != b.FailureRate || a.Incident != nil {
+			t.Fatalf("Run %d: expected both hit or neither, once, got %+v and %+v", run, a, b)
+		}
+		if a.FailureRate == 1 {
+			hit++
+		}
+	}
+	if hit == 0 || hit == 100 {
+		t.Errorf("Expected some runs hit and some not, got %d of 100", hit)
+	}
+
+	for run := int64(0); run < 100; run++ {
+		t.Setenv(SeedEnv, strconv.FormatInt(FirstRunSeed+run, 10))
+		incident := HealthAt(run).Hit(0.2)
+		if got := NewInjector(WithSeed(1)).Decide(db).Failed; got != incident {
+			t.Fatalf("Run %d: expected failed=%v", run, incident)
+		}
+	}
+}
+
+// TestIncidentValidate verifies out-of-range incidents are rejected, from
+// config too
+func TestIncidentValidate(t *testing.T) {
+	for _, i := range []Incident{{Chance: -0.1}, {Chance: 0.1, FailureRate: 1.5}} {
+		if err := i.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", i)
+		}
+	}
+	_, err := LoadScenarios(writeConfig(t, "incident.yaml", `scenarios:
+  - name: SharedDatabase
+    incident: {chance: 2, failure_rate: 0.9}
+`))
+	if err == nil {
+		t.Error("Expected an incident chance of 2 to be rejected")
+	}
+	r, err := LoadScenarios(writeConfig(t, "incident.yaml", `scenarios:
+  - name: SharedDatabase
+    incident: {chance: 0.3, failure_rate: 0.5}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, _ := r.Get("SharedDatabase")
+	if *sc.Incident != (Incident{Chance: 0.3, FailureRate: 0.5}) {
+		t.Errorf("Expected the incident as written, got %+v", sc.Incident)
+	}
+	if base, _ := DefaultScenarios().Get("SharedDatabase"); base.Incident.Chance != 0.1 {
+		t.Errorf("Expected the default incident left alone, got %+v", base.Incident)
+	}
+}
@@ -0,0 +1,266 @@
+package flaky
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/example/flaky-test-example/clock"
+	"github.com/example/flaky-test-example/distributions"
+)
+
+// ErrInjected is returned (wrapped) by every failure the Injector injects
+var ErrInjected = errors.New("flaky: injected failure")
+
+// Injector makes seeded, reproducible flaky decisions
+// It is safe for concurrent use
+type Injector struct {
+	mu     sync.Mutex
+	rng    *rand.Rand
+	seed   int64
+	source Source
+	sleep  func(time.Duration)
+	// realTime is set while sleep is time.Sleep or the real clock's, which
+	// DelayContext can cut short
+	realTime bool
+	// clock and start are set by WithClock, for Elapsed
+	clock clock.Clock
+	start time.Time
+	// decisions is a ring of the latest maxDecisions decisions, made counts
+	// every decision so far
+	decisions [maxDecisions]decision
+	made      int
+}
+
+// maxDecisions bounds the decisions an Injector keeps for Decisions
+const maxDecisions = 64
+
+// Option configures an Injector
+type Option func(*Injector)
+
+// WithSeed seeds the injector explicitly instead of from GO_TEST_SEED
+func WithSeed(seed int64) Option {
+	return func(i *Injector) {
+		i.seed = seed
+	}
+}
+
+// WithSource draws from src instead of the Source named by FLAKY_RAND_SOURCE
+func WithSource(src Source) Option {
+	return func(i *Injector) {
+		i.source = src
+	}
+}
+
+// WithSleep replaces time.Sleep for injected delays
+func WithSleep(sleep func(time.Duration)) Option {
+	return func(i *Injector) {
+		i.sleep, i.realTime = sleep, false
+	}
+}
+
+// WithClock sleeps on c for injected delays, so a clock.FakeClock makes them
+// instant and lets the caller measure them with c.Since or Elapsed
+func WithClock(c clock.Clock) Option {
+	return func(i *Injector) {
+		i.sleep, i.clock, i.realTime = c.Sleep, c, c == clock.Real()
+	}
+}
+
+// NewInjector returns an Injector seeded from GO_TEST_SEED unless WithSeed is given
+// It panics when FLAKY_RAND_SOURCE names no Source and WithSource is not
+// given; ForTest fails the test instead
+func NewInjector(opts ...Option) *Injector {
+	i := &Injector{
+		seed:     SeedFromEnv(),
+		sleep:    time.Sleep,
+		realTime: true,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	if i.source == nil {
+		src, err := SourceFromEnv()
+		if err != nil {
+			panic(err)
+		}
+		i.source = src
+	}
+	i.rng = rand.New(i.source(i.seed))
+	if i.clock != nil {
+		i.start = i.clock.Now()
+	}
+	return i
+}
+
+// Seed returns the seed the injector was created with
+func (i *Injector) Seed() int64 {
+	return i.seed
+}
+
+// Decisions returns the decisions the injector made so far, oldest first,
+// such as "MaybeFail(0.200) = fail (draw 0.134)"
+// Only the latest 64 are kept; an elided count stands in for older ones
+func (i *Injector) Decisions() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	kept := min(i.made, maxDecisions)
+	decisions := make([]string, 0, kept+1)
+	if dropped := i.made - kept; dropped > 0 {
+		decisions = append(decisions, fmt.Sprintf("(%d earlier)", dropped))
+	}
+	for n := i.made - kept; n < i.made; n++ {
+		decisions = append(decisions, i.decisions[n%maxDecisions].String())
+	}
+	return decisions
+}
+
+// Elapsed returns how much time passed on the clock given WithClock since
+// the injector was created, and false without one
+func (i *Injector) Elapsed() (time.Duration, bool) {
+	if i.clock == nil {
+		return 0, false
+	}
+	return i.clock.Since(i.start), true
+}
+
+// decision is one draw an injector made, kept as its values so that draws,
+// the hottest path of the package, format nothing until Decisions is called
+type decision struct {
+	// op is the method that drew it, such as "MaybeFail" or "Delay"
+	op string
+	// rate and draw are the probability and float draw of Float64,
+	// MaybeFail and Locked; n and drawn the bound and draw of Intn
+	rate, draw float64
+	n, drawn   int
+	// dist and delay are the distribution and draw of Delay and Draw
+	dist  distributions.Distribution
+	delay time.Duration
+	// scenario and outcome are the scenario and outcome of Decide
+	scenario Scenario
+	outcome  Outcome
+	// hit reports whether MaybeFail failed or Locked found the lock held
+	hit bool
+}
+
+func (d decision) String() string {
+	switch d.op {
+	case "Float64":
+		return fmt.Sprintf("Float64() = %.3f", d.draw)
+	case "Intn":
+		return fmt.Sprintf("Intn(%d) = %d", d.n, d.drawn)
+	case "MaybeFail":
+		verdict := "ok"
+		if d.hit {
+			verdict = "fail"
+		}
+		return fmt.Sprintf("MaybeFail(%.3f) = %s (draw %.3f)", d.rate, verdict, d.draw)
+	case "Locked":
+		return fmt.Sprintf("Locked(%.3f) = %t", d.rate, d.hit)
+	case "Decide":
+		return fmt.Sprintf("Decide(%s via %s) = %s", d.scenario.Name, d.scenario.Injector, d.outcome)
+	}
+	return fmt.Sprintf("%s(%s) = %v", d.op, d.dist, d.delay)
+}
+
+// decide records a decision; i.mu must be held
+func (i *Injector) decide(d decision) {
+	i.decisions[i.made%maxDecisions] = d
+	i.made++
+}
+
+// Float64 returns a draw in [0.0, 1.0)
+func (i *Injector) Float64() float64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	draw := i.rng.Float64()
+	i.decide(decision{op: "Float64", draw: draw})
+	return draw
+}
+
+// Intn returns a draw in [0, n)
+func (i *Injector) Intn(n int) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	draw := i.rng.IntN(n)
+	i.decide(decision{op: "Intn", n: n, drawn: draw})
+	return draw
+}
+
+// MaybeFail returns an error wrapping ErrInjected with probability rate
+func (i *Injector) MaybeFail(rate float64) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	draw := i.rng.Float64()
+	failed := draw < rate
+	i.decide(decision{op: "MaybeFail", rate: rate, draw: draw, hit: failed})
+	if failed {
+		return fmt.Errorf("%w (draw %.3f < rate %.3f)", ErrInjected, draw, rate)
+	}
+	return nil
+}
+
+// RandomDelay sleeps for a uniformly drawn duration in [min, max] and returns it
+// It draws whole milliseconds above min, as rand.Intn(5)+1 milliseconds did,
+// unless the range is under a millisecond
+func (i *Injector) RandomDelay(min, max time.Duration) time.Duration {
+	u := distributions.Uniform{Min: min, Max: max, Step: time.Millisecond}
+	if max-min < time.Millisecond {
+		u.Step = 0
+	}
+	return i.Delay(u)
+}
+
+// Delay sleeps for a duration drawn from d and returns it
+func (i *Injector) Delay(d distributions.Distribution) time.Duration {
+	delay := i.sample("Delay", d)
+	i.sleep(delay)
+	return delay
+}
+
+// DelayContext sleeps for a duration drawn from d like Delay, but returns
+// ctx's error as soon as ctx ends
+// A delay on a fake clock or a WithSleep function is not cut short; it
+// returns ctx's error if ctx ended by the time it did
+func (i *Injector) DelayContext(ctx context.Context, d distributions.Distribution) (time.Duration, error) {
+	delay := i.sample("Delay", d)
+	if !i.realTime {
+		i.sleep(delay)
+		return delay, ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return delay, nil
+	case <-ctx.Done():
+		return delay, ctx.Err()
+	}
+}
+
+// Draw returns a duration drawn from d without sleeping
+func (i *Injector) Draw(d distributions.Distribution) time.Duration {
+	return i.sample("Draw", d)
+}
+
+func (i *Injector) sample(op string, d distributions.Distribution) time.Duration {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	v := d.Sample(i.rng)
+	i.decide(decision{op: op, dist: d, delay: v})
+	return v
+}
+
+// Locked reports whether a simulated shared resource is held by someone else,
+// which happens with probability prob
+// flakylock contends for a real file lock instead
+func (i *Injector) Locked(prob float64) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	locked := i.rng.Float64() < prob
+	i.decide(decision{op: "Locked", rate: prob, hit: locked})
+	return locked
+}
@@ -0,0 +1,207 @@
+package flaky
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/clock"
+	"github.com/example/flaky-test-example/distributions"
+)
+
+// TestInjectorSameSeedSameDecisions verifies two injectors with one seed agree
+func TestInjectorSameSeedSameDecisions(t *testing.T) {
+	a := NewInjector(WithSeed(12345))
+	b := NewInjector(WithSeed(12345))
+	for i := 0; i < 100; i++ {
+		if (a.MaybeFail(0.3) == nil) != (b.MaybeFail(0.3) == nil) {
+			t.Fatalf("Decision %d differs between injectors with the same seed", i)
+		}
+	}
+}
+
+// TestInjectorSeedFromEnv verifies GO_TEST_SEED seeds the default injector
+func TestInjectorSeedFromEnv(t *testing.T) {
+	t.Setenv(SeedEnv, "777")
+	if got := NewInjector().Seed(); got != 777 {
+		t.Errorf("Expected seed 777, got %d", got)
+	}
+
+	t.Setenv(SeedEnv, "not-a-seed")
+	if got := NewInjector().Seed(); got != DefaultSeed {
+		t.Errorf("Expected default seed %d for invalid GO_TEST_SEED, got %d", DefaultSeed, got)
+	}
+}
+
+// TestInjectorWithSource verifies WithSource picks the algorithm regardless
+// of option order
+func TestInjectorWithSource(t *testing.T) {
+	a := NewInjector(WithSeed(12345), WithSource(ChaCha8))
+	b := NewInjector(WithSource(ChaCha8), WithSeed(12345))
+	c := NewInjector(WithSeed(12345), WithSource(PCG))
+	same, differ := true, false
+	for i := 0; i < 10; i++ {
+		x, y, z := a.Float64(), b.Float64(), c.Float64()
+		same = same && x == y
+		differ = differ || x != z
+	}
+	if !same {
+		t.Error("WithSeed and WithSource gave different draws depending on order")
+	}
+	if !differ {
+		t.Error("ChaCha8 and PCG injectors drew the same sequence")
+	}
+}
+
+// TestInjectorMaybeFailRates verifies the extreme rates and the error type
+func TestInjectorMaybeFailRates(t *testing.T) {
+	inj := NewInjector(WithSeed(1))
+	for i := 0; i < 100; i++ {
+		if err := inj.MaybeFail(0); err != nil {
+			t.Fatalf("MaybeFail(0) failed: %v", err)
+		}
+		if err := inj.MaybeFail(1); !errors.Is(err, ErrInjected) {
+			t.Fatalf("MaybeFail(1) returned %v, expected ErrInjected", err)
+		}
+	}
+}
+
+// TestInjectorMaybeFailApproximatesRate verifies the observed failure rate
+func TestInjectorMaybeFailApproximatesRate(t *testing.T) {
+	inj := NewInjector(WithSeed(2))
+	failures := 0
+	const runs = 10000
+	for i := 0; i < runs; i++ {
+		if inj.MaybeFail(0.3) != nil {
+			failures++
+		}
+	}
+	if rate := float64(failures) / runs; rate < 0.28 || rate > 0.32 {
+		t.Errorf("Expected failure rate near 0.3, got %.3f", rate)
+	}
+}
+
+// TestInjectorRandomDelayBounds verifies delays stay within bounds and are slept
+func TestInjectorRandomDelayBounds(t *testing.T) {
+	var slept []time.Duration
+	inj := NewInjector(WithSeed(3), WithSleep(func(d time.Duration) { slept = append(slept, d) }))
+
+	for i := 0; i < 100; i++ {
+		d := inj.RandomDelay(time.Millisecond, 5*time.Millisecond)
+		if d < time.Millisecond || d > 5*time.Millisecond || d%time.Millisecond != 0 {
+			t.Fatalf("Delay %v not a whole millisecond in [1ms, 5ms]", d)
+		}
+		if slept[i] != d {
+			t.Fatalf("Slept %v but returned %v", slept[i], d)
+		}
+	}
+
+	if d := inj.RandomDelay(2*time.Millisecond, 2*time.Millisecond); d != 2*time.Millisecond {
+		t.Errorf("Expected fixed delay of 2ms, got %v", d)
+	}
+}
+
+// TestInjectorDelayDistribution verifies Delay sleeps a draw of the
+// distribution and Draw only returns one
+func TestInjectorDelayDistribution(t *testing.T) {
+	var slept []time.Duration
+	inj := NewInjector(WithSeed(3), WithSleep(func(d time.Duration) { slept = append(slept, d) }))
+	d := distributions.Pareto{Min: time.Millisecond, Alpha: 2}
+
+	if got := inj.Delay(d); len(slept) != 1 || slept[0] != got || got < time.Millisecond {
+		t.Errorf("Expected one slept delay of at least 1ms, got %v and slept %v", got, slept)
+	}
+	if got := inj.Draw(d); len(slept) != 1 || got < time.Millisecond {
+		t.Errorf("Expected Draw not to sleep, got %v and slept %v", got, slept)
+	}
+
+	uniform := NewInjector(WithSeed(5)).Delay(distributions.Uniform{Min: time.Millisecond, Max: 5 * time.Millisecond, Step: time.Millisecond})
+	if legacy := NewInjector(WithSeed(5)).RandomDelay(time.Millisecond, 5*time.Millisecond); legacy != uniform {
+		t.Errorf("Expected RandomDelay to draw like Uniform in whole milliseconds, got %v and %v", legacy, uniform)
+	}
+}
+
+// TestInjectorWithFakeClock verifies delays advance a fake clock instead of sleeping
+func TestInjectorDelayContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if _, err := NewInjector(WithSeed(1)).DelayContext(ctx, distributions.Constant(time.Minute)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancelled context's error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Expected the delay cut short, took %v", elapsed)
+	}
+
+	clk := clock.NewFake(time.Unix(0, 0))
+	if d, err := NewInjector(WithSeed(1), WithClock(clk)).DelayContext(context.Background(), distributions.Constant(time.Hour)); err != nil || clk.Since(time.Unix(0, 0)) != d {
+		t.Errorf("Expected a fake clock advanced by the delay, got %v, %v", d, err)
+	}
+}
+
+func TestInjectorWithFakeClock(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	inj := NewInjector(WithSeed(3), WithClock(clk))
+
+	start := time.Now()
+	var total time.Duration
+	for i := 0; i < 10; i++ {
+		total += inj.RandomDelay(time.Hour, 2*time.Hour)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Expected delays on a fake clock not to block")
+	}
+	if got := clk.Since(time.Unix(0, 0)); got != total {
+		t.Errorf("Expected the clock to advance %v, got %v", total, got)
+	}
+}
+
+// TestInjectorLocked verifies the extreme lock probabilities
+func TestInjectorLocked(t *testing.T) {
+	inj := NewInjector(WithSeed(4))
+	for i := 0; i < 100; i++ {
+		if inj.Locked(0) {
+			t.Fatal("Locked(0) reported a held lock")
+		}
+		if !inj.Locked(1) {
+			t.Fatal("Locked(1) reported a free lock")
+		}
+	}
+}
+
+// TestInjectorDecisions verifies every draw is recorded, keeping only the
+// latest ones
+func TestInjectorDecisions(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	inj := NewInjector(WithSeed(5), WithClock(clk))
+	inj.MaybeFail(1)
+	inj.Locked(0)
+	inj.Intn(1)
+	inj.Delay(distributions.Constant(time.Second))
+	want := []string{"MaybeFail(1.000) = fail", "Locked(0.000) = false", "Intn(1) = 0", "Delay(constant(1s)) = 1s"}
+	got := inj.Decisions()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d decisions, got %q", len(want), got)
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("Expected decision %d to start with %q, got %q", i, want[i], got[i])
+		}
+	}
+	if elapsed, ok := inj.Elapsed(); !ok || elapsed != time.Second {
+		t.Errorf("Expected 1s elapsed on the clock, got %v, %v", elapsed, ok)
+	}
+	if _, ok := NewInjector(WithSeed(5)).Elapsed(); ok {
+		t.Error("Expected no elapsed time without a clock")
+	}
+
+	for i := 0; i < maxDecisions; i++ {
+		inj.Float64()
+	}
+	got = inj.Decisions()
+	if len(got) != maxDecisions+1 || got[0] != "(4 earlier)" {
+		t.Errorf("Expected the latest %d decisions after an elided count, got %d starting %q", maxDecisions, len(got), got[0])
+	}
+}
@@ -0,0 +1,229 @@
+// Package attach stores the output of failing test runs, gzip-compressed
+// and addressed by the SHA-256 of their content, so a log many runs share
+// is kept once, and indexes them by test and seed
+//
+//	store := attach.New("flaky-attachments")
+//	entries, err := store.Find("", "TestRandomFailure", 42)
+//	for _, ref := range entries[0].Outputs {
+//	    data, err := store.Get(ref.Digest)
+//	    ...
+//	}
+package attach
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultDir is the store flakectl detect writes to unless told otherwise
+const DefaultDir = "flaky-attachments"
+
+// indexFile lists the store's entries, one JSON object per line
+const indexFile = "index.jsonl"
+
+// Output names of a failing run
+const (
+	// TestOutput is what the test itself printed and logged
+	TestOutput = "output"
+	// Stdout is the go test output of the whole process the test ran in,
+	// its other tests included
+	Stdout = "stdout"
+	// Stderr is the standard error of that process, where build errors,
+	// race reports of exited binaries and crashes of go itself go
+	Stderr = "stderr"
+)
+
+// Ref points at one stored output
+type Ref struct {
+	Name   string `json:"name"`
+	Digest string `json:"digest"`
+	// Size is the uncompressed size in bytes
+	Size int `json:"size"`
+}
+
+// Attachment is the stored outputs of one failing run of a test
+type Attachment struct {
+	Run     int    `json:"run"`
+	Seed    int64  `json:"seed"`
+	Kind    string `json:"kind,omitempty"`
+	Outputs []Ref  `json:"outputs"`
+}
+
+// Output returns the ref of the named output, or false
+func (a Attachment) Output(name string) (Ref, bool) {
+	for _, ref := range a.Outputs {
+		if ref.Name == name {
+			return ref, true
+		}
+	}
+	return Ref{}, false
+}
+
+// Entry is an attachment in the store's index, with the test it belongs to
+type Entry struct {
+	Package string `json:"package"`
+	Test    string `json:"test"`
+	Attachment
+}
+
+// Store is a directory of compressed outputs and their index; it is safe
+// for concurrent use by the runs of one process
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// New returns the store in dir, which is created on the first Put
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir returns the store's directory
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Put stores data unless an output with the same content is stored already
+// and returns its ref
+func (s *Store) Put(name string, data []byte) (Ref, error) {
+	sum := sha256.Sum256(data)
+	ref := Ref{Name: name, Digest: hex.EncodeToString(sum[:]), Size: len(data)}
+	path := s.path(ref.Digest)
+	if _, err := os.Stat(path); err == nil {
+		return ref, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return Ref{}, err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return Ref{}, err
+	}
+	if err := zw.Close(); err != nil {
+		return Ref{}, err
+	}
+	// A temporary file renamed into place keeps a reader from seeing half
+	// an output
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".put-*")
+	if err != nil {
+		return Ref{}, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return Ref{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return Ref{}, err
+	}
+	return ref, os.Rename(tmp.Name(), path)
+}
+
+// Get returns the uncompressed content of the output with digest, checking
+// it still hashes to it
+func (s *Store) Get(digest string) ([]byte, error) {
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != sha256.Size*2 {
+		return nil, fmt.Errorf("attach: invalid digest %q", digest)
+	}
+	f, err := os.Open(s.path(digest))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("attach: %s: %w", digest, err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("attach: %s: %w", digest, err)
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != digest {
+		return nil, fmt.Errorf("attach: %s: content does not match its digest", digest)
+	}
+	return data, nil
+}
+
+// Record appends e to the store's index
+func (s *Store) Record(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, indexFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Entries returns every entry of the index in the order they were
+// recorded; a store nothing was recorded in has none
+func (s *Store) Entries() ([]Entry, error) {
+	f, err := os.Open(filepath.Join(s.dir, indexFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("attach: %s line %d: %w", indexFile, line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// Find returns the entries of test, in pkg unless it is empty, that failed
+// with seed, the latest first
+func (s *Store) Find(pkg, test string, seed int64) ([]Entry, error) {
+	entries, err := s.Entries()
+	if err != nil {
+		return nil, err
+	}
+	var found []Entry
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.Test == test && e.Seed == seed && (pkg == "" || e.Package == pkg) {
+			found = append(found, e)
+		}
+	}
+	return found, nil
+}
+
+// path spreads the outputs over directories named after the first two hex
+// digits of their digest
+func (s *Store) path(digest string) string {
+	return filepath.Join(s.dir, "objects", digest[:2], digest[2:]+".gz")
+}
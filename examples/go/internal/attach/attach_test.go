@@ -0,0 +1,80 @@
+package attach
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPutGet(t *testing.T) {
+	s := New(t.TempDir())
+	log := []byte(strings.Repeat("--- FAIL: TestA (0.00s)\n", 100))
+	ref, err := s.Put(Stdout, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Name != Stdout || ref.Size != len(log) || len(ref.Digest) != 64 {
+		t.Errorf("Unexpected ref %+v", ref)
+	}
+	again, err := s.Put(TestOutput, log)
+	if err != nil || again.Digest != ref.Digest {
+		t.Errorf("Expected the same content under the same digest, got %+v, %v", again, err)
+	}
+	data, err := s.Get(ref.Digest)
+	if err != nil || string(data) != string(log) {
+		t.Errorf("Expected the content back, got %d bytes, %v", len(data), err)
+	}
+	objects, _ := filepath.Glob(filepath.Join(s.Dir(), "objects", "*", "*.gz"))
+	if len(objects) != 1 {
+		t.Fatalf("Expected one stored object, got %v", objects)
+	}
+	if info, _ := os.Stat(objects[0]); info.Size() >= int64(len(log)) {
+		t.Errorf("Expected the output compressed, got %d bytes for %d", info.Size(), len(log))
+	}
+
+	if _, err := s.Get("not-a-digest"); err == nil {
+		t.Error("Expected an invalid digest to be rejected")
+	}
+	if _, err := s.Get(strings.Repeat("0", 64)); err == nil {
+		t.Error("Expected a missing output to be an error")
+	}
+	os.WriteFile(objects[0], []byte("garbage"), 0o644)
+	if _, err := s.Get(ref.Digest); err == nil {
+		t.Error("Expected a corrupted output to be an error")
+	}
+}
+
+func TestRecordFind(t *testing.T) {
+	s := New(t.TempDir())
+	if entries, err := s.Entries(); err != nil || entries != nil {
+		t.Errorf("Expected an empty store to have no entries, got %v, %v", entries, err)
+	}
+	ref, _ := s.Put(TestOutput, []byte("boom\n"))
+	for _, e := range []Entry{
+		{Package: "p", Test: "TestA", Attachment: Attachment{Run: 0, Seed: 7, Kind: "assertion", Outputs: []Ref{ref}}},
+		{Package: "q", Test: "TestA", Attachment: Attachment{Run: 0, Seed: 7}},
+		{Package: "p", Test: "TestA", Attachment: Attachment{Run: 3, Seed: 7, Kind: "panic", Outputs: []Ref{ref}}},
+		{Package: "p", Test: "TestB", Attachment: Attachment{Run: 1, Seed: 8}},
+	} {
+		if err := s.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found, err := s.Find("p", "TestA", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || found[0].Kind != "panic" || found[1].Kind != "assertion" {
+		t.Errorf("Expected both runs of p.TestA with seed 7, the latest first, got %+v", found)
+	}
+	if out, ok := found[0].Output(TestOutput); !ok || out.Digest != ref.Digest {
+		t.Errorf("Expected the test output ref, got %+v", out)
+	}
+	if _, ok := found[0].Output(Stderr); ok {
+		t.Error("Expected no stderr ref")
+	}
+	if found, _ := s.Find("", "TestA", 7); len(found) != 3 {
+		t.Errorf("Expected every package without one, got %d entries", len(found))
+	}
+}
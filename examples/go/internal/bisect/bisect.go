@@ -0,0 +1,279 @@
+// Package bisect finds order-dependent test failures by shuffling test
+// execution order and bisecting the tests that ran before a victim
+//
+// go test -shuffle permutes the full test list before -run filters it, so
+// rerunning a subset with the same shuffle seed keeps the subset's relative
+// order; that is what makes bisecting a failing order possible
+package bisect
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// Config describes a bisection sweep over one package
+type Config struct {
+	// Package is the package pattern passed to go test; it must match a
+	// single package because each test binary shuffles independently
+	Package string
+	// Dir is the directory go test runs in
+	Dir string
+	// Seed is the GO_TEST_SEED every run uses, so only the order varies
+	Seed int64
+	// Shuffles is the number of shuffled orders to try
+	Shuffles int
+	// FirstShuffle is the -shuffle seed of the first order; order i uses
+	// FirstShuffle+i
+	FirstShuffle int64
+	// Env holds additional KEY=VALUE pairs for every run
+	Env []string
+	// Run is an optional -run regex selecting the tests Exhaustive orders
+	Run string
+	// MaxPermuted is the most tests Exhaustive runs in every order; larger
+	// selections run every ordered pair instead (default 8)
+	MaxPermuted int
+}
+
+// Order is one shuffled execution of the package
+type Order struct {
+	Shuffle int64
+	// Tests are the top-level tests in the order they ran
+	Tests []string
+	// Failed are the top-level tests that failed in this order
+	Failed []string
+}
+
+// Culprit is an order-dependent failure narrowed down by bisection
+type Culprit struct {
+	Victim string
+	// Polluters are the tests that must run before Victim for it to fail;
+	// a single polluter is the common case, more means the failure needs
+	// several of them together
+	Polluters []string
+	// Shuffle is the -shuffle seed that reproduces the failure with only
+	// Polluters and Victim selected
+	Shuffle int64
+}
+
+// Pattern is the -run regex that reproduces the culprit together with Shuffle
+func (c Culprit) Pattern() string {
+	return runner.RunPattern(append(append([]string(nil), c.Polluters...), c.Victim))
+}
+
+// Unresolved is a test that failed in some orders but could not be narrowed
+// down to polluters
+type Unresolved struct {
+	Test   string
+	Reason string
+}
+
+// Result is the outcome of a bisection sweep
+type Result struct {
+	Orders     []Order
+	Culprits   []Culprit
+	Unresolved []Unresolved
+}
+
+// FailingOrders returns the orders in which at least one test failed
+func (r *Result) FailingOrders() []Order {
+	var failing []Order
+	for _, o := range r.Orders {
+		if len(o.Failed) > 0 {
+			failing = append(failing, o)
+		}
+	}
+	return failing
+}
+
+// execFunc runs the selected tests (all when tests is nil) in the order given
+// by shuffle and returns their results
+type execFunc func(ctx context.Context, shuffle int64, tests []string) ([]runner.Result, error)
+
+// Run shuffles the package cfg.Shuffles times and bisects every test that
+// failed in some orders but passed in others
+func Run(ctx context.Context, cfg Config) (*Result, error) {
+	if cfg.Shuffles < 1 {
+		return nil, fmt.Errorf("shuffles must be at least 1, got %d", cfg.Shuffles)
+	}
+	return run(ctx, cfg, goTest(cfg))
+}
+
+func goTest(cfg Config) execFunc {
+	return func(ctx context.Context, shuffle int64, tests []string) ([]runner.Result, error) {
+		rc := runner.Config{
+			Packages: []string{cfg.Package},
+			Seed:     cfg.Seed,
+			Dir:      cfg.Dir,
+			Args:     []string{"-shuffle", strconv.FormatInt(shuffle, 10)},
+			Env:      cfg.Env,
+		}
+		if tests != nil {
+			rc.Run = runner.RunPattern(tests)
+		}
+		return runner.RunOnce(ctx, rc, 0)
+	}
+}
+
+func run(ctx context.Context, cfg Config, exec execFunc) (*Result, error) {
+	res := &Result{}
+	for i := 0; i < cfg.Shuffles; i++ {
+		shuffle := cfg.FirstShuffle + int64(i)
+		results, err := exec(ctx, shuffle, nil)
+		if err != nil {
+			return nil, err
+		}
+		order := Order{Shuffle: shuffle}
+		order.Tests, order.Failed = topLevel(results)
+		res.Orders = append(res.Orders, order)
+	}
+
+	for _, victim := range orderSensitive(res.Orders) {
+		failing := firstFailing(res.Orders, victim)
+		culprit, reason, err := bisectVictim(ctx, exec, failing, victim)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			res.Unresolved = append(res.Unresolved, Unresolved{Test: victim, Reason: reason})
+			continue
+		}
+		res.Culprits = append(res.Culprits, culprit)
+	}
+	return res, nil
+}
+
+// topLevel returns the top-level tests in the order they finished, which for
+// sequential tests is the order they ran, and the ones that failed
+func topLevel(results []runner.Result) (tests, failed []string) {
+	for _, r := range results {
+		if strings.Contains(r.Test, "/") {
+			continue
+		}
+		tests = append(tests, r.Test)
+		if r.Outcome == runner.Fail {
+			failed = append(failed, r.Test)
+		}
+	}
+	return tests, failed
+}
+
+// orderSensitive returns the tests that failed in at least one order and
+// passed in at least one other, sorted by name
+// Tests that fail in every order are broken or flaky for reasons other than
+// ordering, since every run uses the same GO_TEST_SEED
+func orderSensitive(orders []Order) []string {
+	failed := make(map[string]int)
+	for _, o := range orders {
+		for _, test := range o.Failed {
+			failed[test]++
+		}
+	}
+	var tests []string
+	for test, n := range failed {
+		if n < len(orders) {
+			tests = append(tests, test)
+		}
+	}
+	sort.Strings(tests)
+	return tests
+}
+
+func firstFailing(orders []Order, test string) Order {
+	for _, o := range orders {
+		for _, f := range o.Failed {
+			if f == test {
+				return o
+			}
+		}
+	}
+	return Order{}
+}
+
+// bisectVictim narrows the tests that ran before victim in a failing order to
+// the smallest set that still makes it fail
+// A non-empty reason means the failure could not be attributed to ordering
+func bisectVictim(ctx context.Context, exec execFunc, order Order, victim string) (Culprit, string, error) {
+	fails := func(polluters []string) (bool, error) {
+		results, err := exec(ctx, order.Shuffle, append(append([]string(nil), polluters...), victim))
+		if err != nil {
+			return false, err
+		}
+		_, failed := topLevel(results)
+		for _, f := range failed {
+			if f == victim {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	alone, err := fails(nil)
+	if err != nil {
+		return Culprit{}, "", err
+	}
+	if alone {
+		return Culprit{}, "fails when run alone", nil
+	}
+
+	var candidates []string
+	for _, test := range order.Tests {
+		if test == victim {
+			break
+		}
+		candidates = append(candidates, test)
+	}
+	ok, err := fails(candidates)
+	if err != nil {
+		return Culprit{}, "", err
+	}
+	if !ok {
+		return Culprit{}, fmt.Sprintf("failure with -shuffle %d did not reproduce", order.Shuffle), nil
+	}
+
+	for len(candidates) > 1 {
+		half := len(candidates) / 2
+		first, second := candidates[:half], candidates[half:]
+		if ok, err = fails(first); err != nil {
+			return Culprit{}, "", err
+		} else if ok {
+			candidates = first
+			continue
+		}
+		if ok, err = fails(second); err != nil {
+			return Culprit{}, "", err
+		} else if ok {
+			candidates = second
+			continue
+		}
+		// the failure needs tests from both halves; drop single tests
+		// that are not needed instead
+		candidates, err = minimize(candidates, fails)
+		if err != nil {
+			return Culprit{}, "", err
+		}
+		break
+	}
+	return Culprit{Victim: victim, Polluters: candidates, Shuffle: order.Shuffle}, "", nil
+}
+
+// minimize removes every candidate whose absence still reproduces the failure
+func minimize(candidates []string, fails func([]string) (bool, error)) ([]string, error) {
+	for i := 0; i < len(candidates); {
+		without := append(append([]string(nil), candidates[:i]...), candidates[i+1:]...)
+		ok, err := fails(without)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			candidates = without
+			continue
+		}
+		i++
+	}
+	return candidates, nil
+}
@@ -0,0 +1,161 @@
+package bisect
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// fakeSuite simulates a test binary: order follows -shuffle semantics and
+// fail decides each test's outcome from the tests that ran before it
+type fakeSuite struct {
+	tests []string
+	fail  func(test string, before map[string]bool, shuffle int64) bool
+	runs  int
+}
+
+func (s *fakeSuite) exec(_ context.Context, shuffle int64, selected []string) ([]runner.Result, error) {
+	s.runs++
+	order := append([]string(nil), s.tests...)
+	rand.New(rand.NewSource(shuffle)).Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+
+	want := make(map[string]bool)
+	for _, test := range selected {
+		want[test] = true
+	}
+	before := make(map[string]bool)
+	var results []runner.Result
+	for _, test := range order {
+		if selected != nil && !want[test] {
+			continue
+		}
+		outcome := runner.Pass
+		if s.fail(test, before, shuffle) {
+			outcome = runner.Fail
+		}
+		results = append(results, runner.Result{Test: test, Outcome: outcome})
+		before[test] = true
+	}
+	return results, nil
+}
+
+func TestBisectFindsPolluterVictimPair(t *testing.T) {
+	suite := &fakeSuite{
+		tests: []string{"TestA", "TestB", "TestC", "TestD", "TestE", "TestF", "TestG", "TestH"},
+		fail: func(test string, before map[string]bool, _ int64) bool {
+			return test == "TestE" && before["TestC"]
+		},
+	}
+	res, err := run(context.Background(), Config{Shuffles: 10, FirstShuffle: 1}, suite.exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.FailingOrders()) == 0 || len(res.FailingOrders()) == len(res.Orders) {
+		t.Fatalf("Expected some but not all orders to fail, got %d of %d", len(res.FailingOrders()), len(res.Orders))
+	}
+	if len(res.Culprits) != 1 {
+		t.Fatalf("Expected one culprit, got %+v (unresolved %+v)", res.Culprits, res.Unresolved)
+	}
+	c := res.Culprits[0]
+	if c.Victim != "TestE" || !reflect.DeepEqual(c.Polluters, []string{"TestC"}) {
+		t.Errorf("Expected TestC -> TestE, got %+v", c)
+	}
+	if c.Pattern() != "^(TestC|TestE)$" {
+		t.Errorf("Unexpected pattern %q", c.Pattern())
+	}
+}
+
+func TestBisectKeepsPollutersThatOnlyFailTogether(t *testing.T) {
+	suite := &fakeSuite{
+		tests: []string{"TestA", "TestB", "TestC", "TestD", "TestE", "TestF"},
+		fail: func(test string, before map[string]bool, _ int64) bool {
+			return test == "TestF" && before["TestA"] && before["TestD"]
+		},
+	}
+	res, err := run(context.Background(), Config{Shuffles: 20, FirstShuffle: 1}, suite.exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Culprits) != 1 {
+		t.Fatalf("Expected one culprit, got %+v (unresolved %+v)", res.Culprits, res.Unresolved)
+	}
+	polluters := append([]string(nil), res.Culprits[0].Polluters...)
+	if len(polluters) != 2 || !(polluters[0] == "TestA" && polluters[1] == "TestD" || polluters[0] == "TestD" && polluters[1] == "TestA") {
+		t.Errorf("Expected polluters TestA and TestD, got %v", polluters)
+	}
+}
+
+func TestBisectSkipsFailuresIndependentOfOrder(t *testing.T) {
+	suite := &fakeSuite{
+		tests: []string{"TestA", "TestB", "TestC"},
+		fail: func(test string, _ map[string]bool, shuffle int64) bool {
+			return test == "TestA" || test == "TestB" && shuffle%2 == 1
+		},
+	}
+	res, err := run(context.Background(), Config{Shuffles: 4, FirstShuffle: 1}, suite.exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Culprits) != 0 {
+		t.Errorf("Expected no culprits, got %+v", res.Culprits)
+	}
+	want := []Unresolved{{Test: "TestB", Reason: "fails when run alone"}}
+	if !reflect.DeepEqual(res.Unresolved, want) {
+		t.Errorf("Expected %+v, got %+v", want, res.Unresolved)
+	}
+}
+
+func TestRunRejectsZeroShuffles(t *testing.T) {
+	if _, err := Run(context.Background(), Config{}); err == nil {
+		t.Error("Expected an error for zero shuffles")
+	}
+}
+
+func TestRunBisectsRealPackage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/polluted\n\ngo 1.22\n",
+		"polluted_test.go": `package polluted
+
+import "testing"
+
+var mode = "default"
+
+func TestVictim(t *testing.T) {
+	if mode != "default" {
+		t.Errorf("mode is %q", mode)
+	}
+}
+
+func TestInnocentOne(t *testing.T)   {}
+func TestInnocentTwo(t *testing.T)   {}
+func TestPolluter(t *testing.T)      { mode = "changed" }
+func TestInnocentThree(t *testing.T) {}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := Run(context.Background(), Config{Package: ".", Dir: dir, Seed: 1, Shuffles: 6, FirstShuffle: 1})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(res.Culprits) != 1 {
+		t.Fatalf("Expected one culprit, got %+v (orders %+v)", res.Culprits, res.Orders)
+	}
+	c := res.Culprits[0]
+	if c.Victim != "TestVictim" || !reflect.DeepEqual(c.Polluters, []string{"TestPolluter"}) {
+		t.Errorf("Expected TestPolluter -> TestVictim, got %+v", c)
+	}
+}
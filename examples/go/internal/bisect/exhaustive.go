@@ -0,0 +1,408 @@
+package bisect
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// DefaultMaxPermuted is the most tests Exhaustive runs in every order
+const DefaultMaxPermuted = 8
+
+// maxShuffle bounds the -shuffle seeds searched for an order; 8 tests need
+// around half a million
+const maxShuffle = 1 << 24
+
+// Ordering is one run of the selected tests in an order Exhaustive chose
+type Ordering struct {
+	// Tests are the tests in the order they ran
+	Tests []string
+	// Shuffle is the -shuffle seed that runs Tests in this order when -run
+	// selects only them
+	Shuffle int64
+	// Failed are the tests that failed in this order
+	Failed []string
+}
+
+// Pattern is the -run regex that reproduces the ordering together with
+// Shuffle
+func (o Ordering) Pattern() string {
+	return runner.RunPattern(o.Tests)
+}
+
+// ExhaustiveResult is the outcome of running the selected tests in every
+// order
+type ExhaustiveResult struct {
+	// Tests are the selected tests in source order
+	Tests []string
+	// Pairs is set when there were more than MaxPermuted tests, so every
+	// ordered pair of them ran instead of every permutation
+	Pairs      bool
+	Orderings  []Ordering
+	Culprits   []Culprit
+	Unresolved []Unresolved
+}
+
+// FailingOrderings returns the orderings in which at least one test failed
+func (r *ExhaustiveResult) FailingOrderings() []Ordering {
+	var failing []Ordering
+	for _, o := range r.Orderings {
+		if len(o.Failed) > 0 {
+			failing = append(failing, o)
+		}
+	}
+	return failing
+}
+
+// Exhaustive runs the tests of cfg.Package selected by cfg.Run in every
+// order, or in every ordered pair when there are more than cfg.MaxPermuted
+// of them, and works out from the failing orders which tests must run
+// before each victim
+// Unlike Run it is deterministic: every order is reached by searching for a
+// -shuffle seed that produces it, since go test shuffles with math/rand
+func Exhaustive(ctx context.Context, cfg Config) (*ExhaustiveResult, error) {
+	all, err := listTests(ctx, cfg, ".")
+	if err != nil {
+		return nil, err
+	}
+	selected := all
+	if cfg.Run != "" {
+		if selected, err = listTests(ctx, cfg, cfg.Run); err != nil {
+			return nil, err
+		}
+	}
+	return exhaustive(ctx, cfg, all, selected, goTest(cfg))
+}
+
+// listTests returns the top-level tests of the package matching pattern, in
+// source order, which is the order -shuffle permutes
+func listTests(ctx context.Context, cfg Config, pattern string) ([]string, error) {
+	return runner.ListTests(ctx, runner.Config{Packages: []string{cfg.Package}, Dir: cfg.Dir, Env: cfg.Env}, pattern)
+}
+
+func exhaustive(ctx context.Context, cfg Config, all, selected []string, exec execFunc) (*ExhaustiveResult, error) {
+	if cfg.MaxPermuted == 0 {
+		cfg.MaxPermuted = DefaultMaxPermuted
+	}
+	if len(selected) < 2 {
+		return nil, fmt.Errorf("need at least two tests to order, found %d", len(selected))
+	}
+	res := &ExhaustiveResult{Tests: selected}
+	var orders [][]string
+	if len(selected) <= cfg.MaxPermuted {
+		orders = permutations(selected)
+	} else {
+		res.Pairs = true
+		orders = pairs(selected)
+	}
+	seeds, err := shuffleSeeds(all, orders)
+	if err != nil {
+		return nil, err
+	}
+	for i, order := range orders {
+		results, err := exec(ctx, seeds[i], order)
+		if err != nil {
+			return nil, err
+		}
+		o := Ordering{Shuffle: seeds[i]}
+		o.Tests, o.Failed = topLevel(results)
+		res.Orderings = append(res.Orderings, o)
+	}
+
+	for _, victim := range exhaustiveVictims(res.Orderings) {
+		polluters, reason := explain(res.Orderings, victim)
+		if reason != "" {
+			res.Unresolved = append(res.Unresolved, Unresolved{Test: victim, Reason: reason})
+			continue
+		}
+		for _, p := range polluters {
+			res.Culprits = append(res.Culprits, Culprit{Victim: victim, Polluters: p})
+		}
+	}
+	if len(res.Culprits) > 0 {
+		culpritOrders := make([][]string, len(res.Culprits))
+		for i, c := range res.Culprits {
+			culpritOrders[i] = append(append([]string(nil), c.Polluters...), c.Victim)
+		}
+		seeds, err := shuffleSeeds(all, culpritOrders)
+		if err != nil {
+			return nil, err
+		}
+		for i := range res.Culprits {
+			res.Culprits[i].Shuffle = seeds[i]
+		}
+	}
+	return res, nil
+}
+
+// exhaustiveVictims returns the tests that failed in some of the orderings
+// they ran in and passed in others, sorted by name
+// Every run uses the same GO_TEST_SEED, so a test failing in all of them is
+// broken or flaky for reasons other than ordering
+func exhaustiveVictims(orderings []Ordering) []string {
+	ran := make(map[string]int)
+	failed := make(map[string]int)
+	for _, o := range orderings {
+		for _, test := range o.Tests {
+			ran[test]++
+		}
+		for _, test := range o.Failed {
+			failed[test]++
+		}
+	}
+	var victims []string
+	for test, n := range failed {
+		if n < ran[test] {
+			victims = append(victims, test)
+		}
+	}
+	sort.Strings(victims)
+	return victims
+}
+
+// explain finds the tests whose running before victim decides whether it
+// fails, from every ordering victim ran in
+// Either each of a set of tests makes victim fail on its own, returned as
+// one polluter list per test, or victim fails only when all of a set ran
+// before it, returned as a single list
+// A non-empty reason means no such set explains the failures
+func explain(orderings []Ordering, victim string) ([][]string, string) {
+	type run struct {
+		before map[string]bool
+		failed bool
+	}
+	var runs []run
+	// alone starts as every other test and loses the ones run before victim
+	// in a passing order
+	alone := make(map[string]bool)
+	for _, o := range orderings {
+		before := make(map[string]bool)
+		i := 0
+		for ; i < len(o.Tests) && o.Tests[i] != victim; i++ {
+			before[o.Tests[i]] = true
+		}
+		if i == len(o.Tests) {
+			continue
+		}
+		for _, test := range o.Tests {
+			if test != victim {
+				alone[test] = true
+			}
+		}
+		runs = append(runs, run{before, contains(o.Failed, victim)})
+	}
+
+	failing := 0
+	for _, r := range runs {
+		if r.failed {
+			failing++
+			if len(r.before) == 0 {
+				return nil, "fails when run first"
+			}
+		}
+	}
+
+	// Each test never run before victim in a passing order is a polluter on
+	// its own if every failing order ran one of them first
+	for _, r := range runs {
+		if !r.failed {
+			for test := range r.before {
+				delete(alone, test)
+			}
+		}
+	}
+	explained := len(alone) > 0
+	for _, r := range runs {
+		if r.failed && !intersects(r.before, alone) {
+			explained = false
+		}
+	}
+	if explained {
+		var polluters [][]string
+		for _, test := range sortedKeys(alone) {
+			polluters = append(polluters, []string{test})
+		}
+		return polluters, ""
+	}
+
+	// Otherwise the tests run before victim in every failing order are
+	// polluters together if every order running all of them first fails
+	var together map[string]bool
+	for _, r := range runs {
+		if !r.failed {
+			continue
+		}
+		if together == nil {
+			together = make(map[string]bool)
+			for test := range r.before {
+				together[test] = true
+			}
+			continue
+		}
+		for test := range together {
+			if !r.before[test] {
+				delete(together, test)
+			}
+		}
+	}
+	explained = len(together) > 0
+	for _, r := range runs {
+		if !r.failed && subset(together, r.before) {
+			explained = false
+		}
+	}
+	if explained {
+		return [][]string{sortedKeys(together)}, ""
+	}
+	return nil, fmt.Sprintf("fails in %d of %d orders, not explained by the tests run before it", failing, len(runs))
+}
+
+// permutations returns every order of tests, in lexicographic order of their
+// positions
+func permutations(tests []string) [][]string {
+	if len(tests) <= 1 {
+		return [][]string{append([]string(nil), tests...)}
+	}
+	var out [][]string
+	for i, first := range tests {
+		rest := append(append([]string(nil), tests[:i]...), tests[i+1:]...)
+		for _, p := range permutations(rest) {
+			out = append(out, append([]string{first}, p...))
+		}
+	}
+	return out
+}
+
+// pairs returns every ordered pair of tests
+func pairs(tests []string) [][]string {
+	var out [][]string
+	for _, a := range tests {
+		for _, b := range tests {
+			if a != b {
+				out = append(out, []string{a, b})
+			}
+		}
+	}
+	return out
+}
+
+// shuffleSeeds finds, for every order, the smallest -shuffle seed that runs
+// its tests in that order when -run selects only them
+// all lists every test of the package in source order
+func shuffleSeeds(all []string, orders [][]string) ([]int64, error) {
+	index := make(map[string]int, len(all))
+	for i, test := range all {
+		index[test] = i
+	}
+	// Orders of the same tests share a group, so each seed is checked
+	// against a group with a single lookup of the order it produces; orders
+	// are keyed by the source positions of their tests
+	type group struct {
+		tests   []int
+		missing map[string]int
+	}
+	groups := make(map[string]*group)
+	for i, order := range orders {
+		tests := make([]int, len(order))
+		for j, test := range order {
+			pos, ok := index[test]
+			if !ok {
+				return nil, fmt.Errorf("%s is not a test of the package", test)
+			}
+			tests[j] = pos
+		}
+		key := orderKey(tests)
+		sort.Ints(tests)
+		g := groups[orderKey(tests)]
+		if g == nil {
+			g = &group{tests: tests, missing: make(map[string]int)}
+			groups[orderKey(tests)] = g
+		}
+		g.missing[key] = i
+	}
+
+	seeds := make([]int64, len(orders))
+	perm := make([]int, len(all))
+	ran := make([]int, len(all))
+	// go test shuffles with rand.New(rand.NewSource(seed)); reseeding one
+	// source produces the same sequence
+	rng := rand.New(rand.NewSource(0))
+	for seed := int64(1); seed <= maxShuffle && len(groups) > 0; seed++ {
+		for i := range perm {
+			perm[i] = i
+		}
+		rng.Seed(seed)
+		rng.Shuffle(len(perm), func(i, j int) { perm[i], perm[j] = perm[j], perm[i] })
+		for i, test := range perm {
+			ran[test] = i
+		}
+		for gkey, g := range groups {
+			order := append([]int(nil), g.tests...)
+			sort.Slice(order, func(i, j int) bool { return ran[order[i]] < ran[order[j]] })
+			if i, ok := g.missing[orderKey(order)]; ok {
+				seeds[i] = seed
+				delete(g.missing, orderKey(order))
+				if len(g.missing) == 0 {
+					delete(groups, gkey)
+				}
+			}
+		}
+	}
+	for _, g := range groups {
+		for _, i := range g.missing {
+			return nil, fmt.Errorf("no -shuffle seed up to %d runs %s in that order", maxShuffle, strings.Join(orders[i], ", "))
+		}
+	}
+	return seeds, nil
+}
+
+// orderKey encodes source positions as a map key
+func orderKey(positions []int) string {
+	var b []byte
+	for _, p := range positions {
+		b = binary.AppendUvarint(b, uint64(p))
+	}
+	return string(b)
+}
+
+func contains(tests []string, test string) bool {
+	for _, t := range tests {
+		if t == test {
+			return true
+		}
+	}
+	return false
+}
+
+func intersects(a, b map[string]bool) bool {
+	for k := range a {
+		if b[k] {
+			return true
+		}
+	}
+	return false
+}
+
+// subset reports whether every key of a is in b
+func subset(a, b map[string]bool) bool {
+	for k := range a {
+		if !b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,186 @@
+package bisect
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestShuffleSeedsReachEveryOrder(t *testing.T) {
+	suite := &fakeSuite{
+		tests: []string{"TestA", "TestB", "TestC", "TestD", "TestE", "TestF", "TestG"},
+		fail:  func(string, map[string]bool, int64) bool { return false },
+	}
+	orders := permutations([]string{"TestB", "TestD", "TestF", "TestG"})
+	seeds, err := shuffleSeeds(suite.tests, orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, order := range orders {
+		results, _ := suite.exec(context.Background(), seeds[i], order)
+		got, _ := topLevel(results)
+		if !reflect.DeepEqual(got, order) {
+			t.Errorf("-shuffle %d runs %v, expected %v", seeds[i], got, order)
+		}
+	}
+}
+
+func TestExhaustiveRunsEveryPermutation(t *testing.T) {
+	suite := &fakeSuite{
+		tests: []string{"TestA", "TestB", "TestC", "TestD", "TestE"},
+		fail: func(test string, before map[string]bool, _ int64) bool {
+			return test == "TestD" && (before["TestA"] || before["TestC"])
+		},
+	}
+	selected := []string{"TestA", "TestB", "TestC", "TestD"}
+	res, err := exhaustive(context.Background(), Config{}, suite.tests, selected, suite.exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Pairs || len(res.Orderings) != 24 {
+		t.Fatalf("Expected 24 permutations, got %d (pairs %v)", len(res.Orderings), res.Pairs)
+	}
+	seen := make(map[string]bool)
+	for _, o := range res.Orderings {
+		seen[strings.Join(o.Tests, " ")] = true
+	}
+	if len(seen) != 24 {
+		t.Errorf("Expected 24 distinct orders, got %d", len(seen))
+	}
+	// TestD passes only when TestB alone, or nothing, runs before it
+	if got := len(res.FailingOrderings()); got != 16 {
+		t.Errorf("Expected 16 failing orders, got %d", got)
+	}
+	want := []Culprit{
+		{Victim: "TestD", Polluters: []string{"TestA"}},
+		{Victim: "TestD", Polluters: []string{"TestC"}},
+	}
+	if len(res.Culprits) != len(want) {
+		t.Fatalf("Expected %+v, got %+v (unresolved %+v)", want, res.Culprits, res.Unresolved)
+	}
+	for i, c := range res.Culprits {
+		if c.Victim != want[i].Victim || !reflect.DeepEqual(c.Polluters, want[i].Polluters) {
+			t.Errorf("Expected %+v, got %+v", want[i], c)
+		}
+		if results, _ := suite.exec(context.Background(), c.Shuffle, append(c.Polluters, c.Victim)); results[len(results)-1].Test != c.Victim {
+			t.Errorf("-shuffle %d does not run %s last", c.Shuffle, c.Victim)
+		}
+	}
+}
+
+func TestExhaustiveFindsPollutersNeededTogether(t *testing.T) {
+	suite := &fakeSuite{
+		tests: []string{"TestA", "TestB", "TestC", "TestD"},
+		fail: func(test string, before map[string]bool, _ int64) bool {
+			return test == "TestC" && before["TestA"] && before["TestD"]
+		},
+	}
+	res, err := exhaustive(context.Background(), Config{}, suite.tests, suite.tests, suite.exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Culprits) != 1 || !reflect.DeepEqual(res.Culprits[0].Polluters, []string{"TestA", "TestD"}) {
+		t.Errorf("Expected TestA + TestD -> TestC, got %+v (unresolved %+v)", res.Culprits, res.Unresolved)
+	}
+}
+
+func TestExhaustiveRunsPairsOfLargerSuites(t *testing.T) {
+	suite := &fakeSuite{
+		tests: []string{"TestA", "TestB", "TestC", "TestD", "TestE", "TestF"},
+		fail: func(test string, before map[string]bool, _ int64) bool {
+			return test == "TestB" && before["TestE"]
+		},
+	}
+	res, err := exhaustive(context.Background(), Config{MaxPermuted: 4}, suite.tests, suite.tests, suite.exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Pairs || len(res.Orderings) != 30 {
+		t.Fatalf("Expected 30 ordered pairs, got %d (pairs %v)", len(res.Orderings), res.Pairs)
+	}
+	failing := res.FailingOrderings()
+	if len(failing) != 1 || !reflect.DeepEqual(failing[0].Tests, []string{"TestE", "TestB"}) {
+		t.Errorf("Expected only TestE, TestB to fail, got %+v", failing)
+	}
+	if len(res.Culprits) != 1 || res.Culprits[0].Victim != "TestB" || !reflect.DeepEqual(res.Culprits[0].Polluters, []string{"TestE"}) {
+		t.Errorf("Expected TestE -> TestB, got %+v", res.Culprits)
+	}
+}
+
+func TestExhaustiveReportsUnexplainedFailures(t *testing.T) {
+	suite := &fakeSuite{
+		tests: []string{"TestA", "TestB", "TestC"},
+		fail: func(test string, before map[string]bool, _ int64) bool {
+			// TestB fails when exactly one test ran before it, TestC always
+			return test == "TestB" && len(before) == 1 || test == "TestC"
+		},
+	}
+	res, err := exhaustive(context.Background(), Config{}, suite.tests, suite.tests, suite.exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Culprits) != 0 {
+		t.Errorf("Expected no culprits, got %+v", res.Culprits)
+	}
+	want := []Unresolved{{Test: "TestB", Reason: "fails in 2 of 6 orders, not explained by the tests run before it"}}
+	if !reflect.DeepEqual(res.Unresolved, want) {
+		t.Errorf("Expected %+v, got %+v", want, res.Unresolved)
+	}
+}
+
+func TestExhaustiveNeedsTwoTests(t *testing.T) {
+	if _, err := exhaustive(context.Background(), Config{}, []string{"TestA"}, []string{"TestA"}, nil); err == nil {
+		t.Error("Expected an error for a single test")
+	}
+}
+
+func TestExhaustiveRealPackage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/polluted\n\ngo 1.22\n",
+		"polluted_test.go": `package polluted
+
+import "testing"
+
+var mode = "default"
+
+func TestVictim(t *testing.T) {
+	if mode != "default" {
+		t.Errorf("mode is %q", mode)
+	}
+}
+
+func TestInnocent(t *testing.T)  {}
+func TestPolluter(t *testing.T)  { mode = "changed" }
+func TestUnselected(t *testing.T) {}
+func BenchmarkIgnored(b *testing.B) {}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := Exhaustive(context.Background(), Config{Package: ".", Dir: dir, Seed: 1, Run: "^(TestVictim|TestInnocent|TestPolluter)$"})
+	if err != nil {
+		t.Fatalf("Exhaustive failed: %v", err)
+	}
+	if len(res.Orderings) != 6 || len(res.FailingOrderings()) != 3 {
+		t.Fatalf("Expected 3 of 6 orders to fail, got %+v", res.Orderings)
+	}
+	for _, o := range res.Orderings {
+		if len(o.Tests) != 3 {
+			t.Errorf("Expected 3 tests in -shuffle %d, got %v", o.Shuffle, o.Tests)
+		}
+	}
+	if len(res.Culprits) != 1 || res.Culprits[0].Victim != "TestVictim" || !reflect.DeepEqual(res.Culprits[0].Polluters, []string{"TestPolluter"}) {
+		t.Errorf("Expected TestPolluter -> TestVictim, got %+v (unresolved %+v)", res.Culprits, res.Unresolved)
+	}
+}
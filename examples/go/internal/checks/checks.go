@@ -0,0 +1,252 @@
+// Package checks publishes flake reports as GitHub check runs, annotating
+// the declaration of every flaky or failing test with its flake rate and the
+// command that reproduces its most common failure
+package checks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// DefaultAPIBase is the GitHub REST API
+const DefaultAPIBase = "https://api.github.com"
+
+// maxAnnotations is how many annotations GitHub accepts per request; the
+// rest are added by updating the check run
+const maxAnnotations = 50
+
+// Annotation levels GitHub understands
+const (
+	LevelWarning = "warning"
+	LevelFailure = "failure"
+)
+
+// Annotation marks a source line of a check run
+type Annotation struct {
+	Path            string `json:"path"`
+	StartLine       int    `json:"start_line"`
+	EndLine         int    `json:"end_line"`
+	AnnotationLevel string `json:"annotation_level"`
+	Title           string `json:"title"`
+	Message         string `json:"message"`
+	RawDetails      string `json:"raw_details,omitempty"`
+}
+
+// Output is the body of a check run
+type Output struct {
+	Title       string       `json:"title"`
+	Summary     string       `json:"summary"`
+	Annotations []Annotation `json:"annotations,omitempty"`
+}
+
+// CheckRun is the check run GitHub created
+type CheckRun struct {
+	ID      int64  `json:"id"`
+	HTMLURL string `json:"html_url"`
+}
+
+// Publisher creates check runs on a GitHub repository
+type Publisher struct {
+	// Repo is the repository as owner/name
+	Repo  string
+	Token string
+	// Name is the check run's name (default "flaky tests")
+	Name string
+	// APIBase overrides DefaultAPIBase, such as for GitHub Enterprise
+	APIBase string
+	Client  *http.Client
+}
+
+// Annotations returns one annotation per flaky or failing test of r that
+// locate knows the declaration of, in report order; a nil locate knows none
+func Annotations(r *report.JSONReport, locate report.Locator) []Annotation {
+	var annotations []Annotation
+	for _, t := range r.Tests {
+		level := levelOf(t)
+		if level == "" || locate == nil {
+			continue
+		}
+		loc, ok := locate(t.Package, t.Test)
+		if !ok {
+			continue
+		}
+		a := Annotation{
+			Path:            loc.File,
+			StartLine:       loc.Line,
+			EndLine:         loc.Line,
+			AnnotationLevel: level,
+			Title:           fmt.Sprintf("%s is %s: %.1f%% of %d runs failed", t.Test, t.Classification, 100*t.FlakeRate, t.Runs),
+			Message:         message(r.Confidence, t),
+		}
+		if len(t.FailingSeeds) > 0 {
+			a.RawDetails = "Failing seeds: " + joinSeeds(t.FailingSeeds)
+		}
+		annotations = append(annotations, a)
+	}
+	return annotations
+}
+
+func levelOf(t report.JSONTest) string {
+	switch runner.Classification(t.Classification) {
+	case runner.Flaky:
+		return LevelWarning
+	case runner.Failing:
+		return LevelFailure
+	}
+	return ""
+}
+
+func message(confidence float64, t report.JSONTest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Failed %d of %d runs (%.0f%% CI %.1f%%-%.1f%%)", t.Failed, t.Runs, 100*confidence, 100*t.FlakeRateCI[0], 100*t.FlakeRateCI[1])
+	failure := t.MostCommonFailure()
+	if failure.Message != "" {
+		fmt.Fprintf(&b, "\nMost common failure: %s", failure.Message)
+	}
+	if len(failure.Seeds) > 0 {
+		fmt.Fprintf(&b, "\n\nReproduce with:\n%s", ReproduceCommand(t.Package, t.Test, failure.Seeds[0]))
+	}
+	return b.String()
+}
+
+// ReproduceCommand returns the go test command line that reruns test with
+// a failing seed
+func ReproduceCommand(pkg, test string, seed int64) string {
+	return fmt.Sprintf("%s=%d go test -count=1 -run '%s' %s", runner.SeedEnv, seed, runner.RunPattern([]string{test}), pkg)
+}
+
+func joinSeeds(seeds []int64) string {
+	parts := make([]string, len(seeds))
+	for i, s := range seeds {
+		parts[i] = fmt.Sprint(s)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Conclusion returns "failure" when a test failed every run, "neutral" when
+// some were only flaky and "success" otherwise
+func Conclusion(r *report.JSONReport) string {
+	switch {
+	case r.Summary.Failing > 0:
+		return "failure"
+	case r.Summary.Flaky > 0:
+		return "neutral"
+	}
+	return "success"
+}
+
+// summary is the check run's markdown body: the counts, then a table of the
+// flaky and failing tests, whether or not they could be annotated
+func summary(r *report.JSONReport) string {
+	var b strings.Builder
+	s := r.Summary
+	fmt.Fprintf(&b, "%d flaky and %d failing of %d tests over %d runs.\n", s.Flaky, s.Failing, s.Tests, r.Runs)
+	header := false
+	for _, t := range r.Tests {
+		if levelOf(t) == "" {
+			continue
+		}
+		if !header {
+			b.WriteString("\n| Test | Package | Classification | Flake rate | Runs |\n|---|---|---|---|---|\n")
+			header = true
+		}
+		fmt.Fprintf(&b, "| `%s` | `%s` | %s | %.1f%% | %d |\n", t.Test, t.Package, t.Classification, 100*t.FlakeRate, t.Runs)
+	}
+	return b.String()
+}
+
+// Publish creates a completed check run for commit sha from r, annotating
+// the tests locate knows the declaration of
+// GitHub takes at most 50 annotations per request, so the rest are added by
+// updating the check run in batches
+func (p *Publisher) Publish(ctx context.Context, sha string, r *report.JSONReport, locate report.Locator) (*CheckRun, error) {
+	if !strings.Contains(p.Repo, "/") {
+		return nil, fmt.Errorf("repository must be owner/name, got %q", p.Repo)
+	}
+	if sha == "" {
+		return nil, errors.New("a commit SHA is required")
+	}
+	annotations := Annotations(r, locate)
+	output := Output{
+		Title:   fmt.Sprintf("%d flaky, %d failing", r.Summary.Flaky, r.Summary.Failing),
+		Summary: summary(r),
+	}
+	first := annotations[:min(len(annotations), maxAnnotations)]
+	output.Annotations = first
+	name := p.Name
+	if name == "" {
+		name = "flaky tests"
+	}
+	body, err := json.Marshal(map[string]any{
+		"name":         name,
+		"head_sha":     sha,
+		"status":       "completed",
+		"conclusion":   Conclusion(r),
+		"completed_at": time.Now().UTC().Format(time.RFC3339),
+		"output":       output,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var run CheckRun
+	if err := p.do(ctx, http.MethodPost, "/check-runs", body, &run); err != nil {
+		return nil, err
+	}
+
+	for rest := annotations[len(first):]; len(rest) > 0; rest = rest[len(output.Annotations):] {
+		output.Annotations = rest[:min(len(rest), maxAnnotations)]
+		body, err := json.Marshal(map[string]any{"output": output})
+		if err != nil {
+			return nil, err
+		}
+		if err := p.do(ctx, http.MethodPatch, fmt.Sprintf("/check-runs/%d", run.ID), body, &run); err != nil {
+			return nil, err
+		}
+	}
+	return &run, nil
+}
+
+func (p *Publisher) do(ctx context.Context, method, path string, body []byte, out any) error {
+	base := p.APIBase
+	if base == "" {
+		base = DefaultAPIBase
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(base, "/")+"/repos/"+p.Repo+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.Token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("Content-Type", "application/json")
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(data))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/report"
+)
+
+func sampleReport() *report.JSONReport {
+	return &report.JSONReport{
+		Runs:       10,
+		Confidence: 0.95,
+		Summary:    report.JSONSummary{Tests: 3, Stable: 1, Flaky: 1, Failing: 1},
+		Tests: []report.JSONTest{
+			{
+				Package: "example.com/p", Test: "TestFlaky", Classification: "flaky", Runs: 10, Passed: 7, Failed: 3,
+				FlakeRate: 0.3, FlakeRateCI: [2]float64{0.108, 0.603}, FailingSeeds: []int64{2, 5, 9},
+				Failures: []report.JSONFailure{
+					{Message: "f_test.go:9: timeout", Seeds: []int64{2}},
+					{Message: "f_test.go:12: got 0.812", Seeds: []int64{5, 9}},
+				},
+			},
+			{Package: "example.com/p", Test: "TestBroken", Classification: "failing", Runs: 10, Failed: 10, FlakeRate: 1},
+			{Package: "example.com/p", Test: "TestStable", Classification: "stable", Runs: 10, Passed: 10},
+		},
+	}
+}
+
+func locateAll(pkg, test string) (report.Location, bool) {
+	return report.Location{File: "p/" + test + "_test.go", Line: 7}, true
+}
+
+func TestAnnotations(t *testing.T) {
+	annotations := Annotations(sampleReport(), locateAll)
+	if len(annotations) != 2 {
+		t.Fatalf("Expected annotations for the flaky and failing tests, got %+v", annotations)
+	}
+	flaky := annotations[0]
+	if flaky.Path != "p/TestFlaky_test.go" || flaky.StartLine != 7 || flaky.AnnotationLevel != LevelWarning {
+		t.Errorf("Unexpected flaky annotation: %+v", flaky)
+	}
+	if flaky.Title != "TestFlaky is flaky: 30.0% of 10 runs failed" {
+		t.Errorf("Unexpected title %q", flaky.Title)
+	}
+	want := "GO_TEST_SEED=5 go test -count=1 -run '^(TestFlaky)$' example.com/p"
+	if !strings.Contains(flaky.Message, "Most common failure: f_test.go:12: got 0.812") || !strings.Contains(flaky.Message, want) {
+		t.Errorf("Expected the most common failure and %q in message, got %q", want, flaky.Message)
+	}
+	if flaky.RawDetails != "Failing seeds: 2, 5, 9" {
+		t.Errorf("Unexpected raw details %q", flaky.RawDetails)
+	}
+	if annotations[1].AnnotationLevel != LevelFailure {
+		t.Errorf("Expected a failure annotation for TestBroken, got %+v", annotations[1])
+	}
+
+	if got := Annotations(sampleReport(), nil); len(got) != 0 {
+		t.Errorf("Expected no annotations without a locator, got %+v", got)
+	}
+}
+
+func TestPublishBatchesAnnotations(t *testing.T) {
+	r := sampleReport()
+	for i := 0; i < 60; i++ {
+		r.Tests = append(r.Tests, report.JSONTest{Package: "example.com/p", Test: fmt.Sprintf("TestMore%d", i), Classification: "flaky", Runs: 10, Failed: 1})
+	}
+
+	var posted map[string]any
+	var batches []int
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Output Output `json:"output"`
+		}
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+		batches = append(batches, len(body.Output.Annotations))
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/o/r/check-runs":
+			json.Unmarshal(data, &posted)
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"id": 7, "html_url": "https://github.com/o/r/runs/7"}`)
+		case r.Method == http.MethodPatch && r.URL.Path == "/repos/o/r/check-runs/7":
+			io.WriteString(w, `{"id": 7, "html_url": "https://github.com/o/r/runs/7"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	p := &Publisher{Repo: "o/r", Token: "token", APIBase: api.URL}
+	run, err := p.Publish(context.Background(), "abc123", r, locateAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.ID != 7 {
+		t.Errorf("Expected check run 7, got %+v", run)
+	}
+	if fmt.Sprint(batches) != "[50 12]" {
+		t.Errorf("Expected 62 annotations sent as 50 then 12, got %v", batches)
+	}
+	if posted["head_sha"] != "abc123" || posted["conclusion"] != "failure" || posted["name"] != "flaky tests" {
+		t.Errorf("Unexpected check run: %v", posted)
+	}
+}
+
+func TestPublishReportsAPIErrors(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Resource not accessible by integration"}`, http.StatusForbidden)
+	}))
+	defer api.Close()
+
+	p := &Publisher{Repo: "o/r", APIBase: api.URL}
+	_, err := p.Publish(context.Background(), "abc123", sampleReport(), locateAll)
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "not accessible") {
+		t.Errorf("Expected the API error, got %v", err)
+	}
+	if _, err := (&Publisher{Repo: "repo"}).Publish(context.Background(), "abc123", sampleReport(), nil); err == nil {
+		t.Error("Expected a repository without an owner to be rejected")
+	}
+}
+
+func TestConclusion(t *testing.T) {
+	for _, tc := range []struct {
+		summary report.JSONSummary
+		want    string
+	}{
+		{report.JSONSummary{Tests: 2, Stable: 2}, "success"},
+		{report.JSONSummary{Tests: 2, Stable: 1, Flaky: 1}, "neutral"},
+		{report.JSONSummary{Tests: 2, Flaky: 1, Failing: 1}, "failure"},
+	} {
+		if got := Conclusion(&report.JSONReport{Summary: tc.summary}); got != tc.want {
+			t.Errorf("Expected %s for %+v, got %s", tc.want, tc.summary, got)
+		}
+	}
+}
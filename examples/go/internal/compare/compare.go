@@ -0,0 +1,280 @@
+// Package compare reports how flake rates changed between two commits
+//
+// Each commit's results come from the detection history when it holds
+// sessions recorded at that commit; otherwise the commit is checked out into
+// a temporary git worktree and the suite is run there. A change in a test's
+// flake rate counts as significant when Fisher's exact test rejects equal
+// rates at the configured level
+package compare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/stats"
+)
+
+// Config describes a comparison of two commits
+type Config struct {
+	// Base and Head are the commits compared, as any git revision
+	Base, Head string
+	// Packages are the package patterns passed to go test
+	Packages []string
+	// Runs is the number of times each commit's suite is run (default 20)
+	Runs int
+	// Seed is the seed of the first run; run i uses Seed+i at both commits
+	Seed int64
+	// Run is an optional -run regex
+	Run string
+	// Dir is a directory inside the git checkout; go test runs in the same
+	// directory of each commit's worktree
+	Dir string
+	// Env holds additional KEY=VALUE pairs for every run
+	Env []string
+	// History, when set, is the history database searched for stored results
+	// of each commit; commits it has none for are run and then recorded
+	// Stored results are used as recorded, whichever packages they covered
+	History string
+	// Rerun runs both commits even when the history has results for them
+	Rerun bool
+	// Alpha is the significance level of a flake-rate change (default 0.05)
+	Alpha float64
+}
+
+func (cfg *Config) defaults() {
+	if cfg.Runs == 0 {
+		cfg.Runs = 20
+	}
+	if cfg.Alpha == 0 {
+		cfg.Alpha = 0.05
+	}
+}
+
+// Status says how a test's flake rate moved from base to head
+type Status string
+
+const (
+	// NewlyFlaky is a test that never failed at base, or did not exist there,
+	// and failed at head
+	NewlyFlaky Status = "newly flaky"
+	// Fixed is a test that failed at base and never failed at head
+	Fixed Status = "fixed"
+	// Worse and Better are tests that failed at both commits whose flake
+	// rate rose or dropped significantly
+	Worse  Status = "worse"
+	Better Status = "better"
+	// Unchanged is every other test
+	Unchanged Status = "unchanged"
+)
+
+// Side is one commit's results
+type Side struct {
+	// Commit is the full hash of the commit
+	Commit string
+	// Stored is true when the results came from the history instead of a run
+	Stored bool
+	Report *runner.Report
+}
+
+// Change is one test's outcomes at both commits
+type Change struct {
+	Package string
+	Test    string
+	// Base counts the test's runs at base; both are zero for a new test
+	Base, Head history.Counts
+	// PValue is the two-sided p-value of Fisher's exact test that both
+	// commits share one flake rate
+	PValue float64
+	// Significant is true when PValue is below the configured Alpha
+	Significant bool
+	Status      Status
+	// Message is the first failure message at head, if any
+	Message string
+}
+
+// Result is the outcome of a comparison
+type Result struct {
+	Base, Head Side
+	Alpha      float64
+	// Changes holds every test that ran at head, sorted by package, then
+	// test name
+	Changes []Change
+}
+
+// detectFunc runs the suite, as runner.Detect does
+type detectFunc func(ctx context.Context, cfg runner.Config) (*runner.Report, error)
+
+// Run loads or runs the suite at both commits and compares them
+func Run(ctx context.Context, cfg Config) (*Result, error) {
+	return run(ctx, cfg, runner.Detect)
+}
+
+func run(ctx context.Context, cfg Config, detect detectFunc) (*Result, error) {
+	cfg.defaults()
+	if cfg.Base == "" || cfg.Head == "" {
+		return nil, errors.New("both a base and a head commit are required")
+	}
+	if cfg.Runs < 1 {
+		return nil, fmt.Errorf("runs must be at least 1, got %d", cfg.Runs)
+	}
+	if cfg.Alpha <= 0 || cfg.Alpha >= 1 {
+		return nil, fmt.Errorf("alpha must be in (0, 1), got %v", cfg.Alpha)
+	}
+
+	var db *history.DB
+	if cfg.History != "" {
+		var err error
+		if db, err = history.Open(cfg.History); err != nil {
+			return nil, err
+		}
+		defer db.Close()
+	}
+	base, err := load(ctx, cfg, db, cfg.Base, detect)
+	if err != nil {
+		return nil, fmt.Errorf("base %s: %w", cfg.Base, err)
+	}
+	head, err := load(ctx, cfg, db, cfg.Head, detect)
+	if err != nil {
+		return nil, fmt.Errorf("head %s: %w", cfg.Head, err)
+	}
+	return &Result{
+		Base:    base,
+		Head:    head,
+		Alpha:   cfg.Alpha,
+		Changes: Compare(base.Report, head.Report, cfg.Alpha),
+	}, nil
+}
+
+// load returns the stored results of rev, or runs the suite at rev and
+// records them when db is open
+func load(ctx context.Context, cfg Config, db *history.DB, rev string, detect detectFunc) (Side, error) {
+	commit, err := git(cfg.Dir, "rev-parse", "--verify", rev+"^{commit}")
+	if err != nil {
+		return Side{}, err
+	}
+	side := Side{Commit: commit}
+	if db != nil && !cfg.Rerun {
+		sessions, err := db.SessionsAt(commit)
+		if err != nil {
+			return Side{}, err
+		}
+		if len(sessions) > 0 {
+			side.Stored = true
+			side.Report = history.Report(sessions)
+			return side, nil
+		}
+	}
+	if side.Report, err = detectAt(ctx, cfg, commit, detect); err != nil {
+		return Side{}, err
+	}
+	if db != nil {
+		if err := db.Add(history.NewSession(side.Report, commit)); err != nil {
+			return Side{}, err
+		}
+	}
+	return side, nil
+}
+
+// detectAt runs the suite in a temporary worktree of commit, which is
+// removed again afterwards
+func detectAt(ctx context.Context, cfg Config, commit string, detect detectFunc) (*runner.Report, error) {
+	// The worktree is a fresh checkout, so go test must run in the same
+	// directory relative to the repository root as cfg.Dir
+	prefix, err := git(cfg.Dir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp("", "flakectl-compare-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	worktree := filepath.Join(tmp, commit[:min(len(commit), 12)])
+	if _, err := git(cfg.Dir, "worktree", "add", "--detach", worktree, commit); err != nil {
+		return nil, err
+	}
+	defer git(cfg.Dir, "worktree", "remove", "--force", worktree)
+
+	return detect(ctx, runner.Config{
+		Packages: cfg.Packages,
+		Runs:     cfg.Runs,
+		Seed:     cfg.Seed,
+		Run:      cfg.Run,
+		Dir:      filepath.Join(worktree, prefix),
+		Env:      cfg.Env,
+	})
+}
+
+// git runs a git command in dir and returns its trimmed output
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) && len(exit.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exit.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Compare matches every test that ran at head with its runs at base
+// Tests that only ran at base are left out, as are tests skipped at head
+func Compare(base, head *runner.Report, alpha float64) []Change {
+	type key struct{ pkg, test string }
+	before := make(map[key]*runner.TestStats)
+	for _, s := range base.Tests {
+		before[key{s.Package, s.Test}] = s
+	}
+
+	var changes []Change
+	for _, s := range head.Tests {
+		if s.Classify() == runner.Skipped {
+			continue
+		}
+		c := Change{Package: s.Package, Test: s.Test, Head: history.Counts{Passed: s.Passed, Failed: s.Failed}}
+		if b := before[key{s.Package, s.Test}]; b != nil {
+			c.Base = history.Counts{Passed: b.Passed, Failed: b.Failed}
+		}
+		if len(s.FailureMessages) > 0 {
+			c.Message = s.FailureMessages[0]
+		}
+		c.PValue = stats.FisherExact(c.Base.Failed, c.Base.Runs(), c.Head.Failed, c.Head.Runs())
+		c.Significant = c.PValue < alpha
+		c.Status = status(c)
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Package != changes[j].Package {
+			return changes[i].Package < changes[j].Package
+		}
+		return changes[i].Test < changes[j].Test
+	})
+	return changes
+}
+
+func status(c Change) Status {
+	switch {
+	case c.Base.Failed == 0 && c.Head.Failed == 0:
+		return Unchanged
+	case c.Base.Failed == 0:
+		return NewlyFlaky
+	case c.Head.Failed == 0:
+		return Fixed
+	case !c.Significant:
+		return Unchanged
+	case c.Head.FlakeRate() > c.Base.FlakeRate():
+		return Worse
+	}
+	return Better
+}
@@ -0,0 +1,159 @@
+package compare
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// report builds a report in which each test passes and fails the given
+// number of times
+func report(counts map[string][2]int) *runner.Report {
+	var results []runner.Result
+	for test, c := range counts {
+		for i := 0; i < c[0]; i++ {
+			results = append(results, runner.Result{Package: "p", Test: test, Outcome: runner.Pass})
+		}
+		for i := 0; i < c[1]; i++ {
+			results = append(results, runner.Result{Package: "p", Test: test, Outcome: runner.Fail, Output: "boom\n"})
+		}
+	}
+	return runner.Aggregate(20, results)
+}
+
+func TestCompare(t *testing.T) {
+	base := report(map[string][2]int{
+		"TestStable":  {20, 0},
+		"TestBroke":   {20, 0},
+		"TestFixed":   {15, 5},
+		"TestWorse":   {18, 2},
+		"TestSteady":  {16, 4},
+		"TestRemoved": {10, 10},
+	})
+	head := report(map[string][2]int{
+		"TestStable": {20, 0},
+		"TestBroke":  {19, 1},
+		"TestFixed":  {20, 0},
+		"TestWorse":  {6, 14},
+		"TestSteady": {15, 5},
+		"TestNew":    {10, 10},
+		"TestSkip":   {0, 0},
+	})
+	want := map[string]Status{
+		"TestStable": Unchanged,
+		"TestBroke":  NewlyFlaky,
+		"TestFixed":  Fixed,
+		"TestWorse":  Worse,
+		"TestSteady": Unchanged,
+		"TestNew":    NewlyFlaky,
+	}
+	changes := Compare(base, head, 0.05)
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %+v", len(want), changes)
+	}
+	for _, c := range changes {
+		if c.Status != want[c.Test] {
+			t.Errorf("%s: expected %s, got %s", c.Test, want[c.Test], c.Status)
+		}
+	}
+	if changes[0].Test != "TestBroke" || changes[0].Significant {
+		t.Errorf("Expected a single failure in TestBroke not to be significant, got %+v", changes[0])
+	}
+	if worse := changes[len(changes)-1]; worse.Test != "TestWorse" || !worse.Significant || worse.Message != "boom" {
+		t.Errorf("Expected TestWorse to change significantly with its message, got %+v", worse)
+	}
+}
+
+// gitRepo creates a repository with two commits of a file, returning the
+// directory of the file inside it and both commit hashes
+func gitRepo(t *testing.T) (string, string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gitT := func(args ...string) string {
+		t.Helper()
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		out, err := git(root, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	gitT("init", "-q")
+	var commits []string
+	for _, content := range []string{"base", "head"} {
+		if err := os.WriteFile(filepath.Join(sub, "version"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		gitT("add", "-A")
+		gitT("commit", "-qm", content)
+		commits = append(commits, gitT("rev-parse", "HEAD"))
+	}
+	return sub, commits[0], commits[1]
+}
+
+func TestRunChecksOutEachCommit(t *testing.T) {
+	dir, baseCommit, headCommit := gitRepo(t)
+	var ran []string
+	detect := func(ctx context.Context, cfg runner.Config) (*runner.Report, error) {
+		version, err := os.ReadFile(filepath.Join(cfg.Dir, "version"))
+		if err != nil {
+			return nil, err
+		}
+		ran = append(ran, string(version))
+		if string(version) == "base" {
+			return report(map[string][2]int{"TestA": {20, 0}}), nil
+		}
+		return report(map[string][2]int{"TestA": {10, 10}}), nil
+	}
+	cfg := Config{Base: "HEAD~1", Head: "HEAD", Dir: dir, History: filepath.Join(t.TempDir(), "history.db")}
+
+	res, err := run(context.Background(), cfg, detect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ran, ",") != "base,head" {
+		t.Errorf("Expected the suite to run at base then head, ran at %v", ran)
+	}
+	if res.Base.Commit != baseCommit || res.Head.Commit != headCommit || res.Base.Stored {
+		t.Errorf("Unexpected sides: %+v, %+v", res.Base, res.Head)
+	}
+	if len(res.Changes) != 1 || res.Changes[0].Status != NewlyFlaky || !res.Changes[0].Significant {
+		t.Errorf("Expected TestA to become significantly flaky, got %+v", res.Changes)
+	}
+	if out, _ := git(dir, "worktree", "list"); strings.Count(out, "\n") != 0 {
+		t.Errorf("Expected the worktrees to be removed, got:\n%s", out)
+	}
+
+	// The history now holds both commits, so nothing runs again
+	ran = nil
+	res, err = run(context.Background(), cfg, detect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 0 || !res.Base.Stored || !res.Head.Stored {
+		t.Errorf("Expected both commits to load from the history, ran at %v", ran)
+	}
+	if res.Changes[0].Head.Failed != 10 {
+		t.Errorf("Expected the stored head to have 10 failures, got %+v", res.Changes[0])
+	}
+}
+
+func TestRunRejectsUnknownCommit(t *testing.T) {
+	dir, _, _ := gitRepo(t)
+	_, err := run(context.Background(), Config{Base: "nope", Head: "HEAD", Dir: dir}, nil)
+	if err == nil || !strings.Contains(err.Error(), "base nope") {
+		t.Errorf("Expected an error naming the base, got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+// Package expiry decides when quarantined tests have earned their way out
+// of quarantine
+//
+// A scheduled job runs flakectl quarantine verify, which runs only the
+// quarantined tests and records the sweep in the history as a verification
+// A test that passed every run of the last Policy.Verifications
+// verifications in a row is proposed for release; one failing run starts
+// the count over
+package expiry
+
+import (
+	"time"
+
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/quarantine"
+)
+
+// Source marks the history sessions of verification runs
+const Source = "quarantine-verify"
+
+// Policy sets when a quarantined test is proposed for release
+type Policy struct {
+	// Verifications is how many verifications in a row the test must pass
+	// every run of
+	Verifications int
+	// MinRuns is the fewest runs of the test a verification must have for
+	// it to count; verifications with fewer neither count nor break a streak
+	MinRuns int
+}
+
+// Verdict is what the policy makes of a quarantined test
+type Verdict string
+
+const (
+	// Release is a test that passed enough verifications in a row
+	Release Verdict = "release"
+	// Keep is a test that failed since it was quarantined and has yet to
+	// make a long enough streak
+	Keep Verdict = "keep"
+	// Unverified is a test no verification has counted for since it was
+	// quarantined
+	Unverified Verdict = "unverified"
+)
+
+// Status is a quarantined test's record since it was quarantined
+type Status struct {
+	Entry quarantine.Entry
+	// Age is how long the test has been quarantined, 0 when not known
+	Age time.Duration
+	// Since counts the test's runs in every session since it was
+	// quarantined, verifications or not
+	Since history.Counts
+	// Verifications is how many verifications counted for the test, and
+	// Streak how many of the latest in a row it passed every run of
+	Verifications int
+	Streak        int
+	// LastFailure is when a verification or other session last saw the test
+	// fail, zero when none did
+	LastFailure time.Time
+	Verdict     Verdict
+}
+
+// Evaluate returns the status of every test of list, in list order, from
+// the sessions of the history, oldest first, at now
+func Evaluate(list *quarantine.List, sessions []history.Session, p Policy, now time.Time) []Status {
+	minRuns := max(p.MinRuns, 1)
+	statuses := make([]Status, len(list.Entries))
+	for i, e := range list.Entries {
+		st := Status{Entry: e, Age: e.Age(now)}
+		streakBroken := false
+		for j := len(sessions) - 1; j >= 0; j-- {
+			s := sessions[j]
+			if e.Added != nil && s.Time.Before(*e.Added) {
+				break
+			}
+			var c history.Counts
+			for _, r := range s.Results {
+				if r.Test != e.Test {
+					continue
+				}
+				switch r.Outcome {
+				case runner.Pass:
+					c.Passed++
+				case runner.Fail:
+					c.Failed++
+				}
+			}
+			st.Since.Passed += c.Passed
+			st.Since.Failed += c.Failed
+			if c.Failed > 0 && st.LastFailure.IsZero() {
+				st.LastFailure = s.Time
+			}
+			if s.Source != Source || c.Runs() < minRuns {
+				continue
+			}
+			st.Verifications++
+			if c.Failed > 0 {
+				streakBroken = true
+			}
+			if !streakBroken {
+				st.Streak++
+			}
+		}
+		switch {
+		case p.Verifications > 0 && st.Streak >= p.Verifications:
+			st.Verdict = Release
+		case st.Verifications == 0:
+			st.Verdict = Unverified
+		default:
+			st.Verdict = Keep
+		}
+		statuses[i] = st
+	}
+	return statuses
+}
+
+// Releases returns the tests of statuses the policy releases
+func Releases(statuses []Status) []string {
+	var tests []string
+	for _, st := range statuses {
+		if st.Verdict == Release {
+			tests = append(tests, st.Entry.Test)
+		}
+	}
+	return tests
+}
@@ -0,0 +1,82 @@
+package expiry
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/quarantine"
+)
+
+var added = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// session records the outcomes of one sweep on the given day after added,
+// '.' passing, 'F' failing and 'S' skipped
+func session(day int, source string, outcomes map[string]string) history.Session {
+	s := history.Session{Time: added.Add(time.Duration(day) * 24 * time.Hour), Source: source}
+	for test, runs := range outcomes {
+		for _, o := range runs {
+			outcome := map[rune]runner.Outcome{'.': runner.Pass, 'F': runner.Fail, 'S': runner.Skip}[o]
+			s.Results = append(s.Results, history.Record{Package: "p", Test: test, Outcome: outcome})
+		}
+	}
+	return s
+}
+
+func TestEvaluate(t *testing.T) {
+	list := &quarantine.List{Entries: []quarantine.Entry{
+		{Test: "TestFixed", Added: &added},
+		{Test: "TestStillFlaky", Added: &added},
+		{Test: "TestNeverVerified", Added: &added},
+		{Test: "TestRecent", Added: &added},
+	}}
+	sessions := []history.Session{
+		// Before any test was quarantined, so it does not count
+		session(-1, Source, map[string]string{"TestFixed": "FFFF"}),
+		session(1, "", map[string]string{"TestFixed": "SSSS", "TestStillFlaky": "SSSS", "TestNeverVerified": "SSSS"}),
+		session(2, Source, map[string]string{"TestFixed": "..F.", "TestStillFlaky": "....", "TestRecent": "...."}),
+		session(3, Source, map[string]string{"TestFixed": "....", "TestStillFlaky": "F...", "TestRecent": "...."}),
+		// Too few runs to count
+		session(4, Source, map[string]string{"TestFixed": "F"}),
+		session(5, Source, map[string]string{"TestFixed": "....", "TestStillFlaky": "....", "TestRecent": "...."}),
+		session(6, Source, map[string]string{"TestFixed": "....", "TestStillFlaky": "...."}),
+	}
+	statuses := Evaluate(list, sessions, Policy{Verifications: 3, MinRuns: 2}, added.Add(10*24*time.Hour))
+
+	want := []struct {
+		verdict               Verdict
+		verifications, streak int
+		passed, failed        int
+	}{
+		{Release, 4, 3, 15, 2},
+		{Keep, 4, 2, 15, 1},
+		{Unverified, 0, 0, 0, 0},
+		{Release, 3, 3, 12, 0},
+	}
+	for i, st := range statuses {
+		w := want[i]
+		if st.Verdict != w.verdict || st.Verifications != w.verifications || st.Streak != w.streak || st.Since.Passed != w.passed || st.Since.Failed != w.failed {
+			t.Errorf("%s: unexpected status %+v, want %+v", st.Entry.Test, st, w)
+		}
+	}
+	if st := statuses[0]; st.Age != 10*24*time.Hour || !st.LastFailure.Equal(sessions[4].Time) {
+		t.Errorf("Unexpected age %v or last failure %v", st.Age, st.LastFailure)
+	}
+	if got := Releases(statuses); !reflect.DeepEqual(got, []string{"TestFixed", "TestRecent"}) {
+		t.Errorf("Unexpected releases %v", got)
+	}
+}
+
+func TestEvaluateWithoutAddedCountsAllHistory(t *testing.T) {
+	list := &quarantine.List{Entries: []quarantine.Entry{{Test: "TestOld"}}}
+	sessions := []history.Session{session(-30, Source, map[string]string{"TestOld": "."})}
+	st := Evaluate(list, sessions, Policy{Verifications: 1}, added)[0]
+	if st.Verdict != Release || st.Age != 0 || st.Since.Passed != 1 {
+		t.Errorf("Unexpected status %+v", st)
+	}
+	if st := Evaluate(list, sessions, Policy{}, added)[0]; st.Verdict != Keep {
+		t.Errorf("Expected a policy without verifications to release nothing, got %+v", st)
+	}
+}
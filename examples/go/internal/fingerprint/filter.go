@@ -0,0 +1,64 @@
+package fingerprint
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Filter selects fingerprints by their fields; a fingerprint matches when
+// it matches every term
+type Filter []Term
+
+// Term matches one field of a fingerprint against a path.Match pattern
+type Term struct {
+	Key     string
+	Pattern string
+}
+
+// ParseFilter parses comma-separated key=pattern terms, such as
+// "cpus=1,go=go1.22*"; keys are those of Keys or environment variable
+// names, and a term without "=" matches the fingerprint's ID
+func ParseFilter(s string) (Filter, error) {
+	var f Filter
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		key, pattern, ok := strings.Cut(term, "=")
+		if !ok {
+			key, pattern = "id", term
+		}
+		key = strings.TrimSpace(key)
+		if key == "" || (key == strings.ToLower(key) && !slices.Contains(Keys, key)) {
+			return nil, fmt.Errorf("fingerprint: unknown key %q in %q; want one of %s or an environment variable", key, term, strings.Join(Keys, ", "))
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("fingerprint: bad pattern in %q: %w", term, err)
+		}
+		f = append(f, Term{Key: key, Pattern: pattern})
+	}
+	return f, nil
+}
+
+// Matches reports whether fp matches every term of the filter; an empty
+// filter matches every fingerprint
+func (f Filter) Matches(fp Fingerprint) bool {
+	for _, t := range f {
+		if ok, _ := path.Match(t.Pattern, fp.Field(t.Key)); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the filter as ParseFilter reads it
+func (f Filter) String() string {
+	terms := make([]string, len(f))
+	for i, t := range f {
+		terms[i] = t.Key + "=" + t.Pattern
+	}
+	return strings.Join(terms, ",")
+}
@@ -0,0 +1,48 @@
+package fingerprint
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	small := Fingerprint{GOOS: "linux", GOARCH: "amd64", GoVersion: "go1.22.5", CPUs: 1, CPULimit: 0.5, Env: map[string]string{"GOMAXPROCS": "1"}}
+	large := Fingerprint{GOOS: "linux", GOARCH: "arm64", GoVersion: "go1.23.0", CPUs: 16}
+	tests := []struct {
+		filter       string
+		small, large bool
+	}{
+		{"", true, true},
+		{"cpus=1", true, false},
+		{"goos=linux,go=go1.22*", true, false},
+		{"goarch=arm64", false, true},
+		{"cpu_limit=", false, true},
+		{"cpu_limit=0.5", true, false},
+		{"GOMAXPROCS=1", true, false},
+		{"GOMAXPROCS=", false, true},
+		{small.ID(), true, false},
+		{" id=" + large.ID() + " , ", false, true},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.filter)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q) failed: %v", tt.filter, err)
+		}
+		if f.Matches(small) != tt.small || f.Matches(large) != tt.large {
+			t.Errorf("Expected %q to match small %v and large %v, got %v and %v",
+				tt.filter, tt.small, tt.large, f.Matches(small), f.Matches(large))
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, s := range []string{"cores=1", "=1", "go=[", "cpus=1,ram=2"} {
+		if _, err := ParseFilter(s); err == nil || !strings.HasPrefix(err.Error(), "fingerprint: ") {
+			t.Errorf("Expected ParseFilter(%q) to fail, got %v", s, err)
+		}
+	}
+	f, err := ParseFilter("cpus=1,GOGC=off")
+	if err != nil || f.String() != "cpus=1,GOGC=off" {
+		t.Errorf("Expected the filter to format as it parsed, got %q, %v", f.String(), err)
+	}
+}
@@ -0,0 +1,298 @@
+// Package fingerprint describes the environment a sweep ran in, so flakes
+// that only happen on some machines, such as 1-CPU containers, can be told
+// apart from the rest
+package fingerprint
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/protocol"
+)
+
+// EnvVars are the environment variables a fingerprint records, those that
+// change how the Go runtime, the toolchain or the chaos scenarios behave
+var EnvVars = []string{
+	"GOMAXPROCS", "GOGC", "GOMEMLIMIT", "GODEBUG", "GOFLAGS", "GOTOOLCHAIN",
+	"GOAMD64", "GOARM", "GOARM64", "CGO_ENABLED", "GORACE", "CI",
+	protocol.ConfigEnv, protocol.ProfileEnv, protocol.ScenariosEnv, protocol.PollutionEnv, protocol.SourceEnv,
+}
+
+// Fingerprint is the environment of a sweep
+type Fingerprint struct {
+	GOOS   string `json:"goos"`
+	GOARCH string `json:"goarch"`
+	// GoVersion is the version of the go command the tests ran with, empty
+	// when it could not be asked
+	GoVersion string `json:"go_version,omitempty"`
+	// CPUs is the number of CPUs the process may run on
+	CPUs int `json:"cpus"`
+	// CPULimit is the container's CPU quota in CPUs and MemoryLimit its
+	// memory limit in bytes, zero when there is none
+	CPULimit    float64 `json:"cpu_limit,omitempty"`
+	MemoryLimit int64   `json:"memory_limit,omitempty"`
+	// Env holds the EnvVars that were set
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// cgroupRoot and selfCgroup are where the cgroup limits are read from
+var (
+	cgroupRoot = "/sys/fs/cgroup"
+	selfCgroup = "/proc/self/cgroup"
+)
+
+// Capture fingerprints this machine as go test sees it when run in dir
+// with env, additional KEY=VALUE pairs that override the process's own
+func Capture(dir string, env []string) Fingerprint {
+	f := Fingerprint{GOOS: runtime.GOOS, GOARCH: runtime.GOARCH, CPUs: runtime.NumCPU()}
+	f.CPULimit, f.MemoryLimit = cgroupLimits(cgroupRoot, selfCgroup)
+	f.GoVersion = goVersion(dir, env)
+	for _, name := range EnvVars {
+		value, ok := os.LookupEnv(name)
+		for _, kv := range env {
+			if k, v, _ := strings.Cut(kv, "="); k == name {
+				value, ok = v, true
+			}
+		}
+		if ok {
+			if f.Env == nil {
+				f.Env = make(map[string]string)
+			}
+			f.Env[name] = value
+		}
+	}
+	return f
+}
+
+// goVersion asks the go command in dir for its version, "" when it fails
+func goVersion(dir string, env []string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "go", "env", "GOVERSION")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// ID returns a short hash that is the same for equal fingerprints
+func (f Fingerprint) ID() string {
+	// Marshal sorts Env's keys, so equal fingerprints encode alike
+	data, _ := json.Marshal(f)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:4])
+}
+
+// String describes the fingerprint on one line, such as
+// "linux/amd64 go1.22.5, 8 CPUs, limit 1 CPU and 512 MiB, GOMAXPROCS=1"
+func (f Fingerprint) String() string {
+	parts := []string{f.GOOS + "/" + f.GOARCH}
+	if f.GoVersion != "" {
+		parts[0] += " " + f.GoVersion
+	}
+	if f.CPUs > 0 {
+		parts = append(parts, plural(float64(f.CPUs), "CPU"))
+	}
+	var limits []string
+	if f.CPULimit > 0 {
+		limits = append(limits, plural(f.CPULimit, "CPU"))
+	}
+	if f.MemoryLimit > 0 {
+		limits = append(limits, formatBytes(f.MemoryLimit))
+	}
+	if len(limits) > 0 {
+		parts = append(parts, "limit "+strings.Join(limits, " and "))
+	}
+	names := make([]string, 0, len(f.Env))
+	for name := range f.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, name+"="+f.Env[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Keys are the fields a Filter can match besides environment variables,
+// which it matches by their names
+var Keys = []string{"id", "goos", "goarch", "go", "cpus", "cpu_limit", "memory_limit"}
+
+// Field returns the value of a key of Keys or an environment variable, ""
+// for a limit or variable that is not set
+func (f Fingerprint) Field(key string) string {
+	switch key {
+	case "id":
+		return f.ID()
+	case "goos":
+		return f.GOOS
+	case "goarch":
+		return f.GOARCH
+	case "go":
+		return f.GoVersion
+	case "cpus":
+		return strconv.Itoa(f.CPUs)
+	case "cpu_limit":
+		if f.CPULimit == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(f.CPULimit, 'f', -1, 64)
+	case "memory_limit":
+		if f.MemoryLimit == 0 {
+			return ""
+		}
+		return strconv.FormatInt(f.MemoryLimit, 10)
+	}
+	return f.Env[key]
+}
+
+// EnvString returns the set environment variables as sorted,
+// space-separated KEY=VALUE pairs
+func (f Fingerprint) EnvString() string {
+	pairs := make([]string, 0, len(f.Env))
+	for name, value := range f.Env {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
+func plural(n float64, unit string) string {
+	s := strconv.FormatFloat(n, 'f', -1, 64) + " " + unit
+	if n != 1 {
+		s += "s"
+	}
+	return s
+}
+
+// formatBytes renders n in the largest binary unit it is a whole multiple
+// of, up to GiB
+func formatBytes(n int64) string {
+	for _, unit := range []struct {
+		size int64
+		name string
+	}{{1 << 30, "GiB"}, {1 << 20, "MiB"}, {1 << 10, "KiB"}} {
+		if n%unit.size == 0 {
+			return fmt.Sprintf("%d %s", n/unit.size, unit.name)
+		}
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// cgroupLimits reads the CPU quota and memory limit of the process's
+// cgroups, listed in the file self, under root, taking the tightest limit
+// of each cgroup up to the root; it understands cgroup v1 and v2
+func cgroupLimits(root, self string) (cpu float64, memory int64) {
+	data, err := os.ReadFile(self)
+	if err != nil {
+		return 0, 0
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		controllers, path := strings.Split(fields[1], ","), fields[2]
+		if fields[0] == "0" && fields[1] == "" {
+			// cgroup v2, unified at the root or beside the v1 hierarchies
+			dir := root
+			if _, err := os.Stat(root + "/cgroup.controllers"); err != nil {
+				dir = root + "/unified"
+			}
+			walk(dir, path, func(d string) {
+				cpu = tighter(cpu, cpuMax(d))
+				memory = tighter(memory, readLimit(d+"/memory.max"))
+			})
+			continue
+		}
+		for _, c := range controllers {
+			switch c {
+			case "cpu":
+				walk(root+"/cpu", path, func(d string) {
+					quota, period := readLimit(d+"/cpu.cfs_quota_us"), readLimit(d+"/cpu.cfs_period_us")
+					if quota > 0 && period > 0 {
+						cpu = tighter(cpu, float64(quota)/float64(period))
+					}
+				})
+			case "memory":
+				walk(root+"/memory", path, func(d string) {
+					memory = tighter(memory, readLimit(d+"/memory.limit_in_bytes"))
+				})
+			}
+		}
+	}
+	return cpu, memory
+}
+
+// walk calls visit on the directory of path under root and every parent up
+// to root
+func walk(root, path string, visit func(dir string)) {
+	path = strings.TrimSuffix(path, "/")
+	for {
+		visit(root + path)
+		i := strings.LastIndex(path, "/")
+		if i < 0 {
+			return
+		}
+		path = path[:i]
+	}
+}
+
+// cpuMax reads a cgroup v2 cpu.max, "<quota> <period>" or "max <period>",
+// as a number of CPUs, zero without a quota
+func cpuMax(dir string) float64 {
+	data, err := os.ReadFile(dir + "/cpu.max")
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return 0
+	}
+	quota, err1 := strconv.ParseFloat(fields[0], 64)
+	period, err2 := strconv.ParseFloat(fields[1], 64)
+	if err1 != nil || err2 != nil || quota <= 0 || period <= 0 {
+		return 0
+	}
+	return quota / period
+}
+
+// unlimited is the least value cgroup v1 uses for no memory limit, which
+// is the largest int64 rounded down to a page
+const unlimited = 1 << 62
+
+// readLimit reads a file holding one number, zero when it is missing, says
+// max or is unlimited
+func readLimit(path string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	n, err := strconv.ParseInt(string(bytes.TrimSpace(data)), 10, 64)
+	if err != nil || n <= 0 || n >= unlimited {
+		return 0
+	}
+	return n
+}
+
+// tighter returns the smaller positive limit, zero meaning none
+func tighter[T int64 | float64](a, b T) T {
+	if b > 0 && (a == 0 || b < a) {
+		return b
+	}
+	return a
+}
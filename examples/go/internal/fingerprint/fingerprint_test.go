@@ -0,0 +1,137 @@
+package fingerprint
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// writeFiles creates files under dir, keyed by their slash-separated paths
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCgroupLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		self   string
+		files  map[string]string
+		cpu    float64
+		memory int64
+	}{
+		{
+			name: "v2 in the container's own namespace",
+			self: "0::/\n",
+			files: map[string]string{
+				"cgroup.controllers": "cpu memory",
+				"cpu.max":            "150000 100000\n",
+				"memory.max":         "536870912\n",
+			},
+			cpu: 1.5, memory: 512 << 20,
+		},
+		{
+			name: "v2 takes the tightest limit up the tree",
+			self: "0::/ci/job\n",
+			files: map[string]string{
+				"cgroup.controllers": "",
+				"ci/cpu.max":         "100000 100000\n",
+				"ci/memory.max":      "max\n",
+				"ci/job/cpu.max":     "max 100000\n",
+				"ci/job/memory.max":  "1073741824\n",
+			},
+			cpu: 1, memory: 1 << 30,
+		},
+		{
+			name: "v1",
+			self: "4:memory:/docker/abc\n2:cpu,cpuacct:/docker/abc\n0::/\n",
+			files: map[string]string{
+				"cpu/docker/abc/cpu.cfs_quota_us":           "200000\n",
+				"cpu/docker/abc/cpu.cfs_period_us":          "100000\n",
+				"memory/docker/abc/memory.limit_in_bytes":   "268435456\n",
+				"memory/memory.limit_in_bytes":              "9223372036854771712\n",
+				"unified/docker/abc/cgroup.controllers":     "",
+				"unified/docker/abc/cgroup.subtree_control": "",
+			},
+			cpu: 2, memory: 256 << 20,
+		},
+		{
+			name: "v1 without limits",
+			self: "4:memory:/\n1:cpu:/\n",
+			files: map[string]string{
+				"cpu/cpu.cfs_quota_us":         "-1\n",
+				"cpu/cpu.cfs_period_us":        "100000\n",
+				"memory/memory.limit_in_bytes": "9223372036854771712\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			self := filepath.Join(t.TempDir(), "cgroup")
+			writeFiles(t, filepath.Dir(self), map[string]string{"cgroup": tt.self})
+			cpu, memory := cgroupLimits(dir, self)
+			if cpu != tt.cpu || memory != tt.memory {
+				t.Errorf("Expected limits %v CPUs and %d bytes, got %v and %d", tt.cpu, tt.memory, cpu, memory)
+			}
+		})
+	}
+
+	if cpu, memory := cgroupLimits(t.TempDir(), filepath.Join(t.TempDir(), "missing")); cpu != 0 || memory != 0 {
+		t.Errorf("Expected no limits without cgroups, got %v and %d", cpu, memory)
+	}
+}
+
+func TestCapture(t *testing.T) {
+	t.Setenv("GOGC", "50")
+	t.Setenv("GOMAXPROCS", "8")
+	f := Capture("", []string{"GOMAXPROCS=1", "UNRELATED=x"})
+	if f.GOOS != runtime.GOOS || f.GOARCH != runtime.GOARCH || f.CPUs != runtime.NumCPU() {
+		t.Errorf("Expected this platform, got %+v", f)
+	}
+	if f.GoVersion == "" {
+		t.Errorf("Expected the go command's version, got none")
+	}
+	if f.Env["GOGC"] != "50" || f.Env["GOMAXPROCS"] != "1" || f.Env["UNRELATED"] != "" {
+		t.Errorf("Expected the recorded variables with env taking precedence, got %v", f.Env)
+	}
+}
+
+func TestFingerprintID(t *testing.T) {
+	a := Fingerprint{GOOS: "linux", GOARCH: "amd64", CPUs: 4, Env: map[string]string{"GOGC": "50", "CI": "true"}}
+	b := Fingerprint{GOOS: "linux", GOARCH: "amd64", CPUs: 4, Env: map[string]string{"CI": "true", "GOGC": "50"}}
+	if a.ID() != b.ID() || len(a.ID()) != 8 {
+		t.Errorf("Expected equal fingerprints to share an 8-digit ID, got %s and %s", a.ID(), b.ID())
+	}
+	b.CPUs = 1
+	if a.ID() == b.ID() {
+		t.Errorf("Expected a different CPU count to change the ID %s", a.ID())
+	}
+}
+
+func TestFingerprintString(t *testing.T) {
+	f := Fingerprint{
+		GOOS: "linux", GOARCH: "amd64", GoVersion: "go1.22.5", CPUs: 8,
+		CPULimit: 1, MemoryLimit: 512 << 20, Env: map[string]string{"GOMAXPROCS": "1", "CI": "true"},
+	}
+	want := "linux/amd64 go1.22.5, 8 CPUs, limit 1 CPU and 512 MiB, CI=true, GOMAXPROCS=1"
+	if got := f.String(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got := (Fingerprint{GOOS: "darwin", GOARCH: "arm64"}).String(); got != "darwin/arm64" {
+		t.Errorf("Expected only the platform of an old session, got %q", got)
+	}
+	if got := f.EnvString(); got != "CI=true GOMAXPROCS=1" {
+		t.Errorf("Expected sorted pairs, got %q", got)
+	}
+}
@@ -0,0 +1,141 @@
+package history
+
+import (
+	"sort"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/stats"
+)
+
+// DurationRule sets when a test's durations count as regressed
+type DurationRule struct {
+	// Alpha is the significance level of the one-sided tests
+	Alpha float64
+	// MinRatio is how many times its value before the window the p95 or the
+	// standard deviation must reach, such as 1.5
+	MinRatio float64
+	// MinRuns is the fewest runs needed before and in the window
+	MinRuns int
+	// MinDuration leaves out tests whose p95 in the window is shorter, whose
+	// durations are mostly scheduling noise
+	MinDuration time.Duration
+}
+
+// DefaultDurationRule flags a p95 or spread that grew by half and is
+// significant at 1%, over at least 10 runs on both sides for tests of 10ms
+// or more
+var DefaultDurationRule = DurationRule{Alpha: 0.01, MinRatio: 1.5, MinRuns: 10, MinDuration: 10 * time.Millisecond}
+
+// Durations summarizes the durations of a test's runs
+type Durations struct {
+	Runs   int
+	Median time.Duration
+	P95    time.Duration
+	StdDev time.Duration
+}
+
+// summarize returns the Durations of samples in nanoseconds
+func summarize(samples []float64) Durations {
+	return Durations{
+		Runs:   len(samples),
+		Median: time.Duration(stats.Quantile(samples, 0.5)),
+		P95:    time.Duration(stats.Quantile(samples, 0.95)),
+		StdDev: time.Duration(stats.Describe(samples).StdDev),
+	}
+}
+
+// DurationRegression is a test whose durations in the window grew
+// significantly compared with before it
+type DurationRegression struct {
+	Package string
+	Test    string
+	Before  Durations
+	Window  Durations
+	// Slower is set when the durations shifted up and the p95 reached
+	// MinRatio times its value before; SlowerP is the p-value of the shift
+	Slower  bool
+	SlowerP float64
+	// Variable is set when the durations spread out and the standard
+	// deviation reached MinRatio times its value before; VariableP is the
+	// p-value of the spread
+	Variable  bool
+	VariableP float64
+}
+
+// DurationRegressions compares the durations of every test's passing and
+// failing runs in sessions recorded at or after since with those before,
+// and returns the tests that got significantly slower or more variable,
+// sorted by package, then test name
+// Slower tests the durations themselves with a Mann-Whitney U test, which
+// suits their skew; Variable tests their absolute deviations from each
+// side's median in the same way, as the Brown-Forsythe test does
+func DurationRegressions(sessions []Session, since time.Time, rule DurationRule) []DurationRegression {
+	type key struct{ pkg, test string }
+	type samples struct{ before, window []float64 }
+	byTest := make(map[key]*samples)
+	for _, s := range sessions {
+		inWindow := !s.Time.Before(since)
+		for _, r := range s.Results {
+			if r.Outcome != runner.Pass && r.Outcome != runner.Fail {
+				continue
+			}
+			k := key{r.Package, r.Test}
+			if byTest[k] == nil {
+				byTest[k] = &samples{}
+			}
+			if inWindow {
+				byTest[k].window = append(byTest[k].window, float64(r.Duration))
+			} else {
+				byTest[k].before = append(byTest[k].before, float64(r.Duration))
+			}
+		}
+	}
+
+	minRuns := max(rule.MinRuns, 2)
+	var regressions []DurationRegression
+	for k, s := range byTest {
+		if len(s.before) < minRuns || len(s.window) < minRuns {
+			continue
+		}
+		reg := DurationRegression{Package: k.pkg, Test: k.test, Before: summarize(s.before), Window: summarize(s.window)}
+		if reg.Window.P95 < rule.MinDuration {
+			continue
+		}
+		reg.SlowerP = stats.MannWhitney(s.before, s.window)
+		reg.Slower = reg.SlowerP < rule.Alpha && grew(reg.Before.P95, reg.Window.P95, rule.MinRatio)
+		reg.VariableP = stats.MannWhitney(deviations(s.before), deviations(s.window))
+		reg.Variable = reg.VariableP < rule.Alpha && grew(reg.Before.StdDev, reg.Window.StdDev, rule.MinRatio)
+		if reg.Slower || reg.Variable {
+			regressions = append(regressions, reg)
+		}
+	}
+	sort.Slice(regressions, func(i, j int) bool {
+		if regressions[i].Package != regressions[j].Package {
+			return regressions[i].Package < regressions[j].Package
+		}
+		return regressions[i].Test < regressions[j].Test
+	})
+	return regressions
+}
+
+// grew reports whether after reached ratio times before; anything above a
+// zero before counts
+func grew(before, after time.Duration, ratio float64) bool {
+	if before == 0 {
+		return after > 0
+	}
+	return float64(after) >= ratio*float64(before)
+}
+
+// deviations returns the absolute deviations of samples from their median
+func deviations(samples []float64) []float64 {
+	median := stats.Quantile(samples, 0.5)
+	out := make([]float64, len(samples))
+	for i, x := range samples {
+		if out[i] = x - median; out[i] < 0 {
+			out[i] = -out[i]
+		}
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// timed builds a session at day running test once per duration
+func timed(day int, test string, durations ...time.Duration) Session {
+	s := Session{Time: time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC)}
+	for _, d := range durations {
+		s.Results = append(s.Results, Record{Package: "p", Test: test, Outcome: runner.Pass, Duration: d})
+	}
+	return s
+}
+
+// around returns n durations cycling through mid and up to spread either
+// side of it, in steps of half spread
+func around(n int, mid, spread time.Duration) []time.Duration {
+	out := make([]time.Duration, n)
+	for i := range out {
+		step := time.Duration(i%5-2) * spread / 2
+		out[i] = mid + step
+	}
+	return out
+}
+
+func TestDurationRegressions(t *testing.T) {
+	since := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	sessions := []Session{
+		timed(1, "TestSteady", around(20, 50*time.Millisecond, 2*time.Millisecond)...),
+		timed(20, "TestSteady", around(20, 51*time.Millisecond, 2*time.Millisecond)...),
+		timed(1, "TestSlower", around(20, 50*time.Millisecond, 2*time.Millisecond)...),
+		timed(20, "TestSlower", around(20, 100*time.Millisecond, 2*time.Millisecond)...),
+		timed(1, "TestVariable", around(20, 50*time.Millisecond, 2*time.Millisecond)...),
+		timed(20, "TestVariable", around(20, 50*time.Millisecond, 20*time.Millisecond)...),
+		timed(1, "TestFast", around(20, time.Millisecond, 100*time.Microsecond)...),
+		timed(20, "TestFast", around(20, 5*time.Millisecond, 100*time.Microsecond)...),
+		timed(1, "TestFew", around(5, 50*time.Millisecond, 2*time.Millisecond)...),
+		timed(20, "TestFew", around(20, 100*time.Millisecond, 2*time.Millisecond)...),
+	}
+	regressions := DurationRegressions(sessions, since, DefaultDurationRule)
+	if len(regressions) != 2 {
+		t.Fatalf("Expected TestSlower and TestVariable, got %+v", regressions)
+	}
+	slower, variable := regressions[0], regressions[1]
+	if slower.Test != "TestSlower" || !slower.Slower || slower.Variable {
+		t.Errorf("Expected TestSlower only slower, got %+v", slower)
+	}
+	if slower.Before.Runs != 20 || slower.Window.Runs != 20 || slower.Window.P95 < 100*time.Millisecond {
+		t.Errorf("Unexpected TestSlower durations: %+v %+v", slower.Before, slower.Window)
+	}
+	if variable.Test != "TestVariable" || !variable.Variable || variable.Slower {
+		t.Errorf("Expected TestVariable only more variable, got %+v", variable)
+	}
+	if variable.VariableP >= DefaultDurationRule.Alpha {
+		t.Errorf("Expected a significant spread, got p=%g", variable.VariableP)
+	}
+}
+
+func TestDurationRegressionsSkipsUnrun(t *testing.T) {
+	since := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	before := timed(1, "TestA", around(20, 50*time.Millisecond, 2*time.Millisecond)...)
+	window := timed(20, "TestA", around(20, 100*time.Millisecond, 2*time.Millisecond)...)
+	for i := range window.Results {
+		window.Results[i].Outcome = runner.Skip
+	}
+	if got := DurationRegressions([]Session{before, window}, since, DefaultDurationRule); len(got) != 0 {
+		t.Errorf("Expected skipped runs not to count, got %+v", got)
+	}
+}
@@ -0,0 +1,234 @@
+// Package history persists detection runs in a BoltDB file so flake rates can
+// be compared over time
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/example/flaky-test-example/internal/fingerprint"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// DefaultFile is the history database flakectl uses unless told otherwise
+const DefaultFile = "flaky-history.db"
+
+var sessionsBucket = []byte("sessions")
+
+// Session is one flakectl detect invocation and every test outcome it saw
+type Session struct {
+	ID     uint64    `json:"id"`
+	Time   time.Time `json:"time"`
+	Commit string    `json:"commit,omitempty"`
+	// Source names the CI system that ran a session pushed to flakectl
+	// serve, empty for sessions flakectl ran itself
+	Source string `json:"source,omitempty"`
+	// Host is the name of the machine that ran the session, empty when
+	// unknown
+	Host   string `json:"host,omitempty"`
+	GOOS   string `json:"goos"`
+	GOARCH string `json:"goarch"`
+	Runs   int    `json:"runs"`
+	// Fingerprint is the environment the session ran in, nil for sessions
+	// recorded before fingerprints were
+	Fingerprint *fingerprint.Fingerprint `json:"fingerprint,omitempty"`
+	// Results holds one record per test per run
+	Results []Record `json:"results"`
+}
+
+// Record is one test's outcome in one run of a session
+type Record struct {
+	Package  string         `json:"package"`
+	Test     string         `json:"test"`
+	Seed     int64          `json:"seed"`
+	Outcome  runner.Outcome `json:"outcome"`
+	Duration time.Duration  `json:"duration"`
+	// Message is the first message a failing run logged
+	Message string `json:"message,omitempty"`
+}
+
+// NewSession captures a detection report as a session recorded now on this
+// host and platform
+func NewSession(report *runner.Report, commit string) *Session {
+	host, _ := os.Hostname()
+	s := &Session{
+		Time:   time.Now().UTC(),
+		Commit: commit,
+		Host:   host,
+		GOOS:   runtime.GOOS,
+		GOARCH: runtime.GOARCH,
+		Runs:   report.Runs,
+	}
+	if report.Fingerprint.GOOS != "" {
+		fp := report.Fingerprint
+		s.Fingerprint = &fp
+	}
+	for _, r := range report.Results {
+		rec := Record{
+			Package:  r.Package,
+			Test:     r.Test,
+			Seed:     r.Seed,
+			Outcome:  r.Outcome,
+			Duration: r.Duration,
+		}
+		if msgs := runner.FailureMessages(r.Output); r.Outcome == runner.Fail && len(msgs) > 0 {
+			rec.Message = msgs[0]
+		}
+		s.Results = append(s.Results, rec)
+	}
+	return s
+}
+
+// Environment returns the session's fingerprint, or one of only its
+// platform for a session recorded without one
+func (s *Session) Environment() fingerprint.Fingerprint {
+	if s.Fingerprint != nil {
+		return *s.Fingerprint
+	}
+	return fingerprint.Fingerprint{GOOS: s.GOOS, GOARCH: s.GOARCH}
+}
+
+// Matching returns the sessions whose environment matches f, in order
+func Matching(sessions []Session, f fingerprint.Filter) []Session {
+	var matching []Session
+	for _, s := range sessions {
+		if f.Matches(s.Environment()) {
+			matching = append(matching, s)
+		}
+	}
+	return matching
+}
+
+// Commit returns the HEAD commit of the git checkout containing dir, or ""
+// when dir is not in a git checkout
+func Commit(dir string) string {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// DB is an open history database
+type DB struct {
+	bolt *bolt.DB
+}
+
+// Open opens or creates the history database at path
+func Open(path string) (*DB, error) {
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open history %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(sessionsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open history %s: %w", path, err)
+	}
+	return &DB{bolt: db}, nil
+}
+
+// Close closes the database
+func (d *DB) Close() error {
+	return d.bolt.Close()
+}
+
+// Add stores s, assigning its ID
+func (d *DB) Add(s *Session) error {
+	return d.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionsBucket)
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		s.ID = id
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		return b.Put(key(id), data)
+	})
+}
+
+// Sessions returns every stored session recorded at or after since, oldest
+// first; a zero since returns all of them
+func (d *DB) Sessions(since time.Time) ([]Session, error) {
+	var sessions []Session
+	err := d.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).ForEach(func(k, v []byte) error {
+			var s Session
+			if err := json.Unmarshal(v, &s); err != nil {
+				return fmt.Errorf("session %d: %w", binary.BigEndian.Uint64(k), err)
+			}
+			if !s.Time.Before(since) {
+				sessions = append(sessions, s)
+			}
+			return nil
+		})
+	})
+	return sessions, err
+}
+
+// SessionsAt returns the stored sessions recorded at commit, oldest first
+func (d *DB) SessionsAt(commit string) ([]Session, error) {
+	all, err := d.Sessions(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	var sessions []Session
+	for _, s := range all {
+		if s.Commit == commit {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions, nil
+}
+
+// Report rebuilds a detection report from sessions, as if their runs had
+// been one sweep, fingerprinted when they all ran in the same environment
+// Records keep only a failing run's first message, so the report has no
+// failure kinds
+func Report(sessions []Session) *runner.Report {
+	var runs int
+	var results []runner.Result
+	same := len(sessions) > 0
+	for _, s := range sessions {
+		same = same && s.Fingerprint != nil && s.Environment().ID() == sessions[0].Environment().ID()
+		for _, r := range s.Results {
+			results = append(results, runner.Result{
+				Package:  r.Package,
+				Test:     r.Test,
+				Seed:     r.Seed,
+				Outcome:  r.Outcome,
+				Duration: r.Duration,
+				Output:   r.Message,
+			})
+		}
+		runs += s.Runs
+	}
+	report := runner.Aggregate(runs, results)
+	if same {
+		report.Fingerprint = sessions[0].Environment()
+	}
+	return report
+}
+
+// key encodes id big-endian so sessions iterate in insertion order
+func key(id uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, id)
+	return k
+}
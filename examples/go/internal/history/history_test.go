@@ -0,0 +1,143 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/fingerprint"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestDBRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := &Session{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Commit: "abc"}
+	recent := &Session{
+		Time:    time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		Results: []Record{{Package: "p", Test: "TestA", Seed: 3, Outcome: runner.Fail, Duration: time.Millisecond}},
+	}
+	for _, s := range []*Session{old, recent} {
+		if err := db.Add(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if old.ID != 1 || recent.ID != 2 {
+		t.Errorf("Expected IDs 1 and 2, got %d and %d", old.ID, recent.ID)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	all, err := db.Sessions(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].Commit != "abc" || all[1].ID != 2 {
+		t.Fatalf("Unexpected sessions: %+v", all)
+	}
+	since, err := db.Sessions(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(since) != 1 || since[0].Results[0] != recent.Results[0] {
+		t.Errorf("Expected only the recent session, got %+v", since)
+	}
+	at, err := db.SessionsAt("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(at) != 1 || at[0].ID != 1 {
+		t.Errorf("Expected only the session at abc, got %+v", at)
+	}
+}
+
+func TestNewSession(t *testing.T) {
+	report := runner.Aggregate(2, []runner.Result{
+		{Package: "p", Test: "TestA", Run: 0, Seed: 1, Outcome: runner.Pass, Duration: time.Millisecond},
+		{Package: "p", Test: "TestA", Run: 1, Seed: 2, Outcome: runner.Fail, Duration: 2 * time.Millisecond,
+			Output: "=== RUN   TestA\n    a_test.go:9: boom\n--- FAIL: TestA (0.00s)\n"},
+	})
+	s := NewSession(report, "deadbeef")
+	host, _ := os.Hostname()
+	if s.Commit != "deadbeef" || s.Host != host || s.GOOS != runtime.GOOS || s.GOARCH != runtime.GOARCH || s.Runs != 2 {
+		t.Errorf("Unexpected session metadata: %+v", s)
+	}
+	want := Record{Package: "p", Test: "TestA", Seed: 2, Outcome: runner.Fail, Duration: 2 * time.Millisecond, Message: "a_test.go:9: boom"}
+	if len(s.Results) != 2 || s.Results[1] != want {
+		t.Errorf("Expected results ending in %+v, got %+v", want, s.Results)
+	}
+	if s.Fingerprint != nil || s.Environment().GOOS != runtime.GOOS {
+		t.Errorf("Expected an unfingerprinted report to fall back to the platform, got %+v", s.Fingerprint)
+	}
+
+	report.Fingerprint = fingerprint.Fingerprint{GOOS: "linux", GOARCH: "amd64", CPUs: 1}
+	if s := NewSession(report, ""); s.Fingerprint == nil || s.Fingerprint.CPUs != 1 {
+		t.Errorf("Expected the report's fingerprint, got %+v", s.Fingerprint)
+	}
+}
+
+func TestMatching(t *testing.T) {
+	small := fingerprint.Fingerprint{GOOS: "linux", GOARCH: "amd64", CPUs: 1}
+	large := fingerprint.Fingerprint{GOOS: "linux", GOARCH: "amd64", CPUs: 16}
+	sessions := []Session{
+		{ID: 1, GOOS: "darwin", GOARCH: "arm64"},
+		{ID: 2, Fingerprint: &small},
+		{ID: 3, Fingerprint: &large},
+	}
+	for _, tt := range []struct {
+		filter string
+		want   []uint64
+	}{
+		{"", []uint64{1, 2, 3}},
+		{"cpus=1", []uint64{2}},
+		{"goos=darwin", []uint64{1}},
+		{large.ID(), []uint64{3}},
+	} {
+		f, err := fingerprint.ParseFilter(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []uint64
+		for _, s := range Matching(sessions, f) {
+			ids = append(ids, s.ID)
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("Expected %q to match sessions %v, got %v", tt.filter, tt.want, ids)
+		}
+	}
+
+	if fp := Report(sessions[1:2]).Fingerprint; fp.CPUs != 1 {
+		t.Errorf("Expected a report of one environment to keep its fingerprint, got %+v", fp)
+	}
+	if fp := Report(sessions[1:]).Fingerprint; fp.GOOS != "" {
+		t.Errorf("Expected a report of two environments to have none, got %+v", fp)
+	}
+}
+
+func TestReport(t *testing.T) {
+	rec := func(outcome runner.Outcome) Record {
+		return Record{Package: "p", Test: "TestA", Outcome: outcome}
+	}
+	report := Report([]Session{
+		{Runs: 2, Results: []Record{rec(runner.Pass), rec(runner.Fail)}},
+		{Runs: 1, Results: []Record{rec(runner.Fail)}},
+	})
+	if report.Runs != 3 || len(report.Tests) != 1 {
+		t.Fatalf("Expected 3 runs of one test, got %+v", report)
+	}
+	if s := report.Tests[0]; s.Passed != 1 || s.Failed != 2 {
+		t.Errorf("Expected 1 pass and 2 failures, got %+v", s)
+	}
+}
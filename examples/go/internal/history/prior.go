@@ -0,0 +1,43 @@
+package history
+
+import "github.com/example/flaky-test-example/stats"
+
+// Prior is what a test's recorded history says about its flake rate before
+// a new sweep
+type Prior struct {
+	// History counts the test's runs in the sessions
+	History Counts
+	// Rate is the flake-rate distribution a new sweep of the test updates
+	Rate stats.Beta
+}
+
+// Priors returns the Prior of every test that ran in sessions, keyed by
+// package and test name, each worth at most weight runs; see
+// stats.PriorFromHistory
+func Priors(sessions []Session, weight float64) map[[2]string]Prior {
+	priors := make(map[[2]string]Prior)
+	for _, s := range sessions {
+		for _, r := range s.Results {
+			k := [2]string{r.Package, r.Test}
+			p := priors[k]
+			p.History.add(r.Outcome)
+			priors[k] = p
+		}
+	}
+	for k, p := range priors {
+		p.Rate = stats.PriorFromHistory(p.History.Passed, p.History.Failed, weight)
+		priors[k] = p
+	}
+	return priors
+}
+
+// Posterior returns the flake-rate distribution of a test after a sweep of
+// it passed passes and failed failures times, starting from its prior in
+// priors, or from stats.Jeffreys for a test without history
+func Posterior(priors map[[2]string]Prior, pkg, test string, passes, failures int) stats.Beta {
+	prior, ok := priors[[2]string{pkg, test}]
+	if !ok {
+		prior.Rate = stats.Jeffreys
+	}
+	return prior.Rate.Update(passes, failures)
+}
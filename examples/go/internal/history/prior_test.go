@@ -0,0 +1,35 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/example/flaky-test-example/stats"
+)
+
+func TestPriors(t *testing.T) {
+	sessions := []Session{
+		session(1, map[string]string{"TestStable": "pppp", "TestFlaky": "pfpf"}),
+		session(2, map[string]string{"TestStable": "pppp", "TestFlaky": "ppff"}),
+	}
+	priors := Priors(sessions, 5)
+
+	flaky := priors[[2]string{"p", "TestFlaky"}]
+	if flaky.History != (Counts{Passed: 4, Failed: 4}) {
+		t.Errorf("Expected 4 passes and 4 failures, got %+v", flaky.History)
+	}
+	if flaky.Rate.Weight() != 5 || flaky.Rate.Mean() != 0.5 {
+		t.Errorf("Expected a prior worth 5 runs around 50%%, got %v", flaky.Rate)
+	}
+	stable := priors[[2]string{"p", "TestStable"}]
+	if want := stats.PriorFromHistory(8, 0, 5); stable.Rate != want {
+		t.Errorf("Expected %v, got %v", want, stable.Rate)
+	}
+
+	// One failure in 5 runs moves the stable test far less
+	if s, f := Posterior(priors, "p", "TestStable", 4, 1), Posterior(priors, "p", "TestFlaky", 4, 1); s.Mean() >= f.Mean() {
+		t.Errorf("Expected the stable test's posterior %v below the flaky one's %v", s, f)
+	}
+	if got, want := Posterior(priors, "p", "TestNew", 4, 1), stats.Jeffreys.Update(4, 1); got != want {
+		t.Errorf("Expected a test without history to start from Jeffreys, got %v", got)
+	}
+}
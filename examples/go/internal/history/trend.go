@@ -0,0 +1,134 @@
+package history
+
+import (
+	"sort"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// Counts tallies passing and failing runs; skips are not counted
+type Counts struct {
+	Passed int
+	Failed int
+}
+
+// Runs returns the number of counted runs
+func (c Counts) Runs() int {
+	return c.Passed + c.Failed
+}
+
+// FlakeRate returns the fraction of failing runs, 0 without runs
+func (c Counts) FlakeRate() float64 {
+	if c.Runs() == 0 {
+		return 0
+	}
+	return float64(c.Failed) / float64(c.Runs())
+}
+
+func (c *Counts) add(outcome runner.Outcome) {
+	switch outcome {
+	case runner.Pass:
+		c.Passed++
+	case runner.Fail:
+		c.Failed++
+	}
+}
+
+// Status summarizes how a test's flake rate moved
+type Status string
+
+const (
+	Stable     Status = "stable"
+	Flaky      Status = "flaky"
+	NewlyFlaky Status = "newly flaky"
+	Recovered  Status = "recovered"
+	Worse      Status = "worse"
+	Better     Status = "better"
+	Unchanged  Status = "unchanged"
+)
+
+// trendMargin is how far the flake rate must move for a still-flaky test to
+// count as worse or better
+const trendMargin = 0.05
+
+// Trend compares a test's outcomes inside a window with all earlier history
+type Trend struct {
+	Package string
+	Test    string
+	// Before counts runs recorded before the window, Window those inside it
+	Before Counts
+	Window Counts
+	// Series is the flake rate in each window session that ran the test,
+	// oldest first
+	Series []float64
+	Status Status
+}
+
+// Trends computes a Trend for every test that ran in sessions recorded at or
+// after since, sorted by package, then test name
+func Trends(sessions []Session, since time.Time) []Trend {
+	type key struct{ pkg, test string }
+	byTest := make(map[key]*Trend)
+	for _, s := range sessions {
+		inWindow := !s.Time.Before(since)
+		perSession := make(map[key]*Counts)
+		for _, r := range s.Results {
+			k := key{r.Package, r.Test}
+			t := byTest[k]
+			if t == nil {
+				t = &Trend{Package: r.Package, Test: r.Test}
+				byTest[k] = t
+			}
+			if !inWindow {
+				t.Before.add(r.Outcome)
+				continue
+			}
+			t.Window.add(r.Outcome)
+			if perSession[k] == nil {
+				perSession[k] = &Counts{}
+			}
+			perSession[k].add(r.Outcome)
+		}
+		for k, c := range perSession {
+			if c.Runs() > 0 {
+				byTest[k].Series = append(byTest[k].Series, c.FlakeRate())
+			}
+		}
+	}
+
+	var trends []Trend
+	for _, t := range byTest {
+		if t.Window.Runs() == 0 {
+			continue
+		}
+		t.Status = status(t.Before, t.Window)
+		trends = append(trends, *t)
+	}
+	sort.Slice(trends, func(i, j int) bool {
+		if trends[i].Package != trends[j].Package {
+			return trends[i].Package < trends[j].Package
+		}
+		return trends[i].Test < trends[j].Test
+	})
+	return trends
+}
+
+func status(before, window Counts) Status {
+	switch {
+	case window.Failed == 0 && before.Failed == 0:
+		return Stable
+	case before.Runs() == 0:
+		// no earlier history to compare against
+		return Flaky
+	case before.Failed == 0:
+		return NewlyFlaky
+	case window.Failed == 0:
+		return Recovered
+	case window.FlakeRate() > before.FlakeRate()+trendMargin:
+		return Worse
+	case window.FlakeRate() < before.FlakeRate()-trendMargin:
+		return Better
+	}
+	return Unchanged
+}
@@ -0,0 +1,72 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// session builds a session at day with the given outcome string per test,
+// 'p' for pass and 'f' for fail
+func session(day int, outcomes map[string]string) Session {
+	s := Session{Time: time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC)}
+	for test, seq := range outcomes {
+		for _, c := range seq {
+			outcome := runner.Pass
+			if c == 'f' {
+				outcome = runner.Fail
+			}
+			s.Results = append(s.Results, Record{Package: "p", Test: test, Outcome: outcome})
+		}
+	}
+	return s
+}
+
+func TestTrends(t *testing.T) {
+	sessions := []Session{
+		session(1, map[string]string{"TestStable": "pp", "TestNew": "pp", "TestRecovered": "pf", "TestWorse": "ppppppppf", "TestSame": "pf", "TestGone": "pf"}),
+		session(20, map[string]string{"TestStable": "pp", "TestNew": "pf", "TestRecovered": "pp", "TestWorse": "ff", "TestSame": "fp"}),
+		session(25, map[string]string{"TestNew": "ff", "TestBrandNew": "pf"}),
+	}
+	trends := Trends(sessions, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC))
+
+	got := make(map[string]Status)
+	for _, tr := range trends {
+		got[tr.Test] = tr.Status
+	}
+	want := map[string]Status{
+		"TestBrandNew":  Flaky,
+		"TestNew":       NewlyFlaky,
+		"TestRecovered": Recovered,
+		"TestSame":      Unchanged,
+		"TestStable":    Stable,
+		"TestWorse":     Worse,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected statuses %v, got %v", want, got)
+	}
+	if trends[0].Test != "TestBrandNew" {
+		t.Errorf("Expected trends sorted by name, got %s first", trends[0].Test)
+	}
+	for _, tr := range trends {
+		if tr.Test == "TestNew" {
+			if !reflect.DeepEqual(tr.Series, []float64{0.5, 1}) {
+				t.Errorf("Expected TestNew series [0.5 1], got %v", tr.Series)
+			}
+			if tr.Before != (Counts{Passed: 2}) || tr.Window != (Counts{Passed: 1, Failed: 3}) {
+				t.Errorf("Unexpected TestNew counts: %+v %+v", tr.Before, tr.Window)
+			}
+		}
+	}
+}
+
+func TestCountsFlakeRate(t *testing.T) {
+	if rate := (Counts{}).FlakeRate(); rate != 0 {
+		t.Errorf("Expected 0 without runs, got %v", rate)
+	}
+	if rate := (Counts{Passed: 3, Failed: 1}).FlakeRate(); rate != 0.25 {
+		t.Errorf("Expected 0.25, got %v", rate)
+	}
+}
@@ -0,0 +1,285 @@
+// Package hunt searches the seed space for seeds that make one test fail
+//
+// Seeds are sampled at random; the neighbours of a seed that produced a new
+// failure message are probed before further random samples, since failures
+// often cluster where a seed feeds arithmetic directly
+// Each distinct failure message is kept with the seeds that produced it, and
+// the hunt ends with a minimal set of seeds that together reproduce every
+// message
+package hunt
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// Config describes a seed hunt for one test
+type Config struct {
+	// Package is the package pattern passed to go test (default ".")
+	Package string
+	// Dir is the directory go test runs in
+	Dir string
+	// Test is the top-level test or subtest path to hunt
+	Test string
+	// Budget is the most seeds probed (default 200)
+	Budget int
+	// Min and Max bound the inclusive seed space (default 0 to MaxInt32)
+	Min, Max int64
+	// Radius is how many seeds on either side of a failing seed are probed
+	// (default 2; negative disables neighbourhood exploration)
+	Radius int64
+	// Workers is the number of probes run concurrently (default 1)
+	Workers int
+	// Rand seeds the sampler, so a hunt with the same outcomes probes the
+	// same seeds
+	Rand int64
+	// Env holds additional KEY=VALUE pairs for every probe
+	Env []string
+}
+
+func (cfg *Config) defaults() {
+	if cfg.Package == "" {
+		cfg.Package = "."
+	}
+	if cfg.Budget == 0 {
+		cfg.Budget = 200
+	}
+	if cfg.Min == 0 && cfg.Max == 0 {
+		cfg.Max = math.MaxInt32
+	}
+	if cfg.Radius == 0 {
+		cfg.Radius = 2
+	}
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+}
+
+// Failure is a distinct failure message and every seed found to produce it
+type Failure struct {
+	Message string
+	// Seeds are sorted ascending
+	Seeds []int64
+	// Found is the probe number (1-based) that first produced the message
+	Found int
+}
+
+// Result is the outcome of a hunt
+type Result struct {
+	// Probes is the number of seeds run
+	Probes int
+	// Failed is the number of probed seeds the test failed with
+	Failed int
+	// Failures are ordered by the probe that found them
+	Failures []Failure
+	// Minimal holds the lowest seed of each failure, sorted ascending
+	Minimal []int64
+}
+
+// probeFunc runs the test once with seed and returns its first failure
+// message; failed is false when the test passed or was skipped
+type probeFunc func(ctx context.Context, seed int64) (message string, failed bool, err error)
+
+// Run hunts cfg.Test's seed space with go test
+func Run(ctx context.Context, cfg Config) (*Result, error) {
+	if cfg.Test == "" {
+		return nil, fmt.Errorf("no test to hunt")
+	}
+	cfg.defaults()
+	if cfg.Budget < 1 {
+		return nil, fmt.Errorf("budget must be at least 1, got %d", cfg.Budget)
+	}
+	if cfg.Max < cfg.Min {
+		return nil, fmt.Errorf("seed space %d-%d is empty", cfg.Min, cfg.Max)
+	}
+	return run(ctx, cfg, goTest(cfg))
+}
+
+func goTest(cfg Config) probeFunc {
+	rc := runner.Config{
+		Packages: []string{cfg.Package},
+		Dir:      cfg.Dir,
+		Run:      runner.RunPattern([]string{cfg.Test}),
+		Env:      cfg.Env,
+	}
+	if _, sub, ok := strings.Cut(cfg.Test, "/"); ok {
+		// RunPattern selects the parent; -run matches subtest paths level
+		// by level, so select the subtest too
+		rc.Run += "/^" + regexp.QuoteMeta(sub) + "$"
+	}
+	return func(ctx context.Context, seed int64) (string, bool, error) {
+		probeCfg := rc
+		probeCfg.Seed = seed
+		results, err := runner.RunOnce(ctx, probeCfg, 0)
+		if err != nil {
+			return "", false, err
+		}
+		for _, r := range results {
+			if r.Test != cfg.Test {
+				continue
+			}
+			if r.Outcome != runner.Fail {
+				return "", false, nil
+			}
+			if msgs := runner.FailureMessages(r.Output); len(msgs) > 0 {
+				return msgs[0], true, nil
+			}
+			return "(no message)", true, nil
+		}
+		return "", false, fmt.Errorf("%s did not run with seed %d; check the test name and package", cfg.Test, seed)
+	}
+}
+
+// probe is one seed's outcome within a batch
+type probe struct {
+	seed    int64
+	message string
+	failed  bool
+	err     error
+}
+
+func run(ctx context.Context, cfg Config, exec probeFunc) (*Result, error) {
+	h := &hunter{
+		cfg:     cfg,
+		rng:     rand.New(rand.NewPCG(uint64(cfg.Rand), 0)),
+		visited: make(map[int64]bool),
+		byMsg:   make(map[string]*Failure),
+	}
+	res := &Result{}
+	for res.Probes < cfg.Budget {
+		n := cfg.Workers
+		if left := cfg.Budget - res.Probes; n > left {
+			n = left
+		}
+		batch := h.nextBatch(n)
+		if len(batch) == 0 {
+			break // the whole seed space has been probed
+		}
+		runBatch(ctx, exec, batch)
+
+		// Handle outcomes in batch order so the hunt stays deterministic
+		// however the probes finished
+		for i := range batch {
+			p := &batch[i]
+			if p.err != nil {
+				return nil, p.err
+			}
+			res.Probes++
+			if !p.failed {
+				continue
+			}
+			res.Failed++
+			if h.record(p, res.Probes) {
+				h.explore(p.seed)
+			}
+		}
+	}
+	res.Failures = h.failures()
+	for _, f := range res.Failures {
+		res.Minimal = append(res.Minimal, f.Seeds[0])
+	}
+	sort.Slice(res.Minimal, func(i, j int) bool { return res.Minimal[i] < res.Minimal[j] })
+	return res, nil
+}
+
+func runBatch(ctx context.Context, exec probeFunc, batch []probe) {
+	var wg sync.WaitGroup
+	for i := range batch {
+		wg.Add(1)
+		go func(p *probe) {
+			defer wg.Done()
+			p.message, p.failed, p.err = exec(ctx, p.seed)
+		}(&batch[i])
+	}
+	wg.Wait()
+}
+
+// hunter holds the search state between batches
+type hunter struct {
+	cfg      Config
+	rng      *rand.Rand
+	visited  map[int64]bool
+	frontier []int64
+	byMsg    map[string]*Failure
+	order    []string
+}
+
+// nextBatch picks up to n unvisited seeds, neighbours of failures first
+func (h *hunter) nextBatch(n int) []probe {
+	var batch []probe
+	for len(batch) < n && len(h.frontier) > 0 {
+		seed := h.frontier[0]
+		h.frontier = h.frontier[1:]
+		if !h.visited[seed] {
+			h.visited[seed] = true
+			batch = append(batch, probe{seed: seed})
+		}
+	}
+	space := uint64(h.cfg.Max-h.cfg.Min) + 1
+	for len(batch) < n && (space == 0 || uint64(len(h.visited)) < space) {
+		seed := h.sample(space)
+		if !h.visited[seed] {
+			h.visited[seed] = true
+			batch = append(batch, probe{seed: seed})
+		}
+	}
+	return batch
+}
+
+// sample draws a uniform seed from [Min, Max]; space is 0 when the range
+// covers every int64
+func (h *hunter) sample(space uint64) int64 {
+	switch {
+	case space == 0:
+		return int64(h.rng.Uint64())
+	case space > math.MaxInt64:
+		return h.cfg.Min + int64(h.rng.Uint64()%space)
+	}
+	return h.cfg.Min + h.rng.Int64N(int64(space))
+}
+
+// explore queues the unvisited seeds within Radius of seed, nearest
+// first; distances are unsigned so seeds near the int64 limits cannot wrap
+func (h *hunter) explore(seed int64) {
+	below, above := uint64(seed-h.cfg.Min), uint64(h.cfg.Max-seed)
+	for d := int64(1); d <= h.cfg.Radius; d++ {
+		if below >= uint64(d) && !h.visited[seed-d] {
+			h.frontier = append(h.frontier, seed-d)
+		}
+		if above >= uint64(d) && !h.visited[seed+d] {
+			h.frontier = append(h.frontier, seed+d)
+		}
+	}
+}
+
+// record files a failing probe under its message and reports whether the
+// message is new
+func (h *hunter) record(p *probe, probeNum int) (fresh bool) {
+	f := h.byMsg[p.message]
+	if f == nil {
+		f = &Failure{Message: p.message, Found: probeNum}
+		h.byMsg[p.message] = f
+		h.order = append(h.order, p.message)
+		fresh = true
+	}
+	f.Seeds = append(f.Seeds, p.seed)
+	return fresh
+}
+
+func (h *hunter) failures() []Failure {
+	failures := make([]Failure, 0, len(h.order))
+	for _, msg := range h.order {
+		f := *h.byMsg[msg]
+		sort.Slice(f.Seeds, func(i, j int) bool { return f.Seeds[i] < f.Seeds[j] })
+		failures = append(failures, f)
+	}
+	return failures
+}
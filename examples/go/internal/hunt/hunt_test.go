@@ -0,0 +1,189 @@
+package hunt
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// fakeTest fails with the message message returns for a seed, passing on
+// "", and records the order seeds were probed in
+type fakeTest struct {
+	mu      sync.Mutex
+	probed  []int64
+	message func(seed int64) string
+}
+
+func (f *fakeTest) probe(_ context.Context, seed int64) (string, bool, error) {
+	f.mu.Lock()
+	f.probed = append(f.probed, seed)
+	f.mu.Unlock()
+	msg := f.message(seed)
+	return msg, msg != "", nil
+}
+
+// clustered fails around seed 42, differently at 43, and once more at 137
+func clustered(seed int64) string {
+	switch {
+	case seed == 43:
+		return "edge"
+	case seed >= 40 && seed <= 44:
+		return "cluster"
+	case seed == 137:
+		return "lone"
+	}
+	return ""
+}
+
+func TestHuntFindsEveryMessageAndMinimalSeeds(t *testing.T) {
+	f := &fakeTest{message: clustered}
+	res, err := run(context.Background(), withDefaults(Config{Test: "TestX", Min: 0, Max: 199, Budget: 200, Rand: 1}), f.probe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Probes != 200 || res.Failed != 6 {
+		t.Errorf("Expected 200 probes with 6 failures, got %d and %d", res.Probes, res.Failed)
+	}
+	got := make(map[string][]int64)
+	for _, fl := range res.Failures {
+		got[fl.Message] = fl.Seeds
+	}
+	want := map[string][]int64{
+		"cluster": {40, 41, 42, 44},
+		"edge":    {43},
+		"lone":    {137},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected failures %v, got %v", want, got)
+	}
+	if !reflect.DeepEqual(res.Minimal, []int64{40, 43, 137}) {
+		t.Errorf("Expected minimal seeds [40 43 137], got %v", res.Minimal)
+	}
+}
+
+func TestHuntExploresNeighboursOfNewFailures(t *testing.T) {
+	f := &fakeTest{message: func(seed int64) string {
+		if seed >= 500 && seed <= 520 {
+			return "cluster"
+		}
+		return ""
+	}}
+	cfg := withDefaults(Config{Test: "TestX", Min: 0, Max: 999, Budget: 400, Radius: 2, Rand: 3})
+	if _, err := run(context.Background(), cfg, f.probe); err != nil {
+		t.Fatal(err)
+	}
+	for i, seed := range f.probed {
+		if seed < 500 || seed > 520 {
+			continue
+		}
+		next := f.probed[i+1 : i+5]
+		if want := []int64{seed - 1, seed + 1, seed - 2, seed + 2}; !reflect.DeepEqual(next, want) {
+			t.Errorf("Expected neighbours %v after the first failure at %d, got %v", want, seed, next)
+		}
+		return
+	}
+	t.Fatal("Expected the hunt to hit the failing cluster")
+}
+
+func TestHuntIsDeterministic(t *testing.T) {
+	cfg := withDefaults(Config{Test: "TestX", Min: 0, Max: 1 << 20, Budget: 60, Workers: 4, Rand: 9})
+	a, b := &fakeTest{message: clustered}, &fakeTest{message: clustered}
+	if _, err := run(context.Background(), cfg, a.probe); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(context.Background(), cfg, b.probe); err != nil {
+		t.Fatal(err)
+	}
+	seen := func(probed []int64) map[int64]bool {
+		m := make(map[int64]bool)
+		for _, s := range probed {
+			m[s] = true
+		}
+		return m
+	}
+	if !reflect.DeepEqual(seen(a.probed), seen(b.probed)) {
+		t.Error("Expected two hunts with the same Rand to probe the same seeds")
+	}
+}
+
+func TestHuntStopsWhenSpaceIsExhausted(t *testing.T) {
+	f := &fakeTest{message: func(int64) string { return "" }}
+	res, err := run(context.Background(), withDefaults(Config{Test: "TestX", Min: 5, Max: 14, Budget: 100, Workers: 3}), f.probe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Probes != 10 || len(res.Failures) != 0 || res.Minimal != nil {
+		t.Errorf("Expected 10 passing probes, got %+v", res)
+	}
+}
+
+func TestHuntReturnsProbeErrors(t *testing.T) {
+	boom := errors.New("build failed")
+	probe := func(context.Context, int64) (string, bool, error) { return "", false, boom }
+	if _, err := run(context.Background(), withDefaults(Config{Test: "TestX"}), probe); !errors.Is(err, boom) {
+		t.Errorf("Expected the probe error, got %v", err)
+	}
+}
+
+func TestRunValidatesConfig(t *testing.T) {
+	for _, cfg := range []Config{{}, {Test: "TestX", Budget: -1}, {Test: "TestX", Min: 10, Max: 5}} {
+		if _, err := Run(context.Background(), cfg); err == nil {
+			t.Errorf("Expected %+v to be rejected", cfg)
+		}
+	}
+}
+
+func TestRunHuntsRealPackage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/seeded\n\ngo 1.22\n",
+		"seeded_test.go": `package seeded
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestSeed(t *testing.T) {
+	t.Run("mod", func(t *testing.T) {
+		seed, _ := strconv.Atoi(os.Getenv("GO_TEST_SEED"))
+		if seed%3 == 0 {
+			t.Errorf("seed is a multiple of %d", 3)
+		}
+	})
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := Run(context.Background(), Config{Dir: dir, Test: "TestSeed/mod", Min: 0, Max: 8, Budget: 9, Workers: 3})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(res.Failures) != 1 || !reflect.DeepEqual(res.Failures[0].Seeds, []int64{0, 3, 6}) {
+		t.Fatalf("Expected seeds 0, 3 and 6 to fail, got %+v", res.Failures)
+	}
+	if res.Failures[0].Message != "seeded_test.go:13: seed is a multiple of 3" {
+		t.Errorf("Unexpected message %q", res.Failures[0].Message)
+	}
+	if !reflect.DeepEqual(res.Minimal, []int64{0}) {
+		t.Errorf("Expected seed 0 to reproduce the failure, got %v", res.Minimal)
+	}
+}
+
+func withDefaults(cfg Config) Config {
+	cfg.defaults()
+	return cfg
+}
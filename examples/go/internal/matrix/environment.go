@@ -0,0 +1,119 @@
+package matrix
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/stats"
+)
+
+// EnvironmentSensitivity is one test's outcomes in every timezone and
+// locale of a matrix, pooled over the other axes
+type EnvironmentSensitivity struct {
+	Package string
+	Test    string
+	// Environments are the matrix's timezone and locale settings, as
+	// Cell.Environment names them, and Stats the test's runs in each
+	Environments []string
+	Stats        []runner.TestStats
+	// PValue is Fisher's exact test of equal flake rates in the settings
+	// the test failed most and least often in
+	PValue float64
+	// Deterministic is set when every setting either passed or failed all
+	// of its runs, so rerunning the test in any one environment never shows
+	// it flaky
+	Deterministic bool
+}
+
+// Failing returns the settings in which the test failed at least once
+func (e EnvironmentSensitivity) Failing() []string {
+	var envs []string
+	for i, s := range e.Stats {
+		if s.Failed > 0 {
+			envs = append(envs, e.Environments[i])
+		}
+	}
+	return envs
+}
+
+// Passing returns the settings in which the test ran and never failed
+func (e EnvironmentSensitivity) Passing() []string {
+	var envs []string
+	for i, s := range e.Stats {
+		if s.Failed == 0 && s.Passed > 0 {
+			envs = append(envs, e.Environments[i])
+		}
+	}
+	return envs
+}
+
+// EnvironmentSensitive returns the tests whose flake rate differs
+// significantly at alpha between the timezones and locales of the matrix,
+// sorted by package, then test name
+// Without two distinct settings there is nothing to compare and it returns
+// nil
+func (r *Result) EnvironmentSensitive(alpha float64) []EnvironmentSensitivity {
+	var envs []string
+	for _, cell := range r.Cells {
+		if env := cell.Environment(); !slices.Contains(envs, env) {
+			envs = append(envs, env)
+		}
+	}
+	if len(envs) < 2 {
+		return nil
+	}
+
+	type key struct{ pkg, test string }
+	byTest := make(map[key]*EnvironmentSensitivity)
+	for i, report := range r.Reports {
+		env := slices.Index(envs, r.Cells[i].Environment())
+		for _, s := range report.Tests {
+			k := key{s.Package, s.Test}
+			e := byTest[k]
+			if e == nil {
+				e = &EnvironmentSensitivity{Package: s.Package, Test: s.Test, Environments: envs, Stats: make([]runner.TestStats, len(envs))}
+				byTest[k] = e
+			}
+			e.Stats[env].Passed += s.Passed
+			e.Stats[env].Failed += s.Failed
+			e.Stats[env].Skipped += s.Skipped
+		}
+	}
+
+	var sensitive []EnvironmentSensitivity
+	for _, e := range byTest {
+		var worst, best *runner.TestStats
+		e.Deterministic = true
+		for i := range e.Stats {
+			s := &e.Stats[i]
+			if s.Passed+s.Failed == 0 {
+				continue
+			}
+			if worst == nil || s.FlakeRate() > worst.FlakeRate() {
+				worst = s
+			}
+			if best == nil || s.FlakeRate() < best.FlakeRate() {
+				best = s
+			}
+			if s.Flaky() {
+				e.Deterministic = false
+			}
+		}
+		if worst == nil || worst == best {
+			continue
+		}
+		e.PValue = stats.FisherExact(worst.Failed, worst.Passed+worst.Failed, best.Failed, best.Passed+best.Failed)
+		if e.PValue < alpha && worst.FlakeRate() > best.FlakeRate() {
+			sensitive = append(sensitive, *e)
+		}
+	}
+	sort.Slice(sensitive, func(i, j int) bool {
+		a, b := sensitive[i], sensitive[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		return a.Test < b.Test
+	})
+	return sensitive
+}
@@ -0,0 +1,106 @@
+package matrix
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestEnvironmentSensitive(t *testing.T) {
+	// TestLocale fails every run under LANG=de_DE.UTF-8 only; TestFlaky
+	// fails every other run everywhere
+	detect := func(ctx context.Context, cfg runner.Config) (*runner.Report, error) {
+		var results []runner.Result
+		for run := 0; run < cfg.Runs; run++ {
+			locale, flaky := runner.Pass, runner.Pass
+			if slices.Contains(cfg.Env, "LANG=de_DE.UTF-8") {
+				locale = runner.Fail
+			}
+			if run%2 == 1 {
+				flaky = runner.Fail
+			}
+			results = append(results,
+				runner.Result{Package: "p", Test: "TestLocale", Run: run, Outcome: locale},
+				runner.Result{Package: "p", Test: "TestFlaky", Run: run, Outcome: flaky})
+		}
+		return runner.Aggregate(cfg.Runs, results), nil
+	}
+	cfg := Config{Base: runner.Config{Runs: 6}, TZ: []string{"UTC", "Asia/Tokyo"}, Lang: []string{"C.UTF-8", "de_DE.UTF-8"}}
+	res, err := run(context.Background(), cfg, detect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Cells[1].String(); got != "TZ=UTC LANG=de_DE.UTF-8" {
+		t.Errorf("Expected the second cell to vary the locale, got %q", got)
+	}
+	sensitive := res.EnvironmentSensitive(0.05)
+	if len(sensitive) != 1 || sensitive[0].Test != "TestLocale" {
+		t.Fatalf("Expected only TestLocale, got %+v", sensitive)
+	}
+	e := sensitive[0]
+	if want := []string{"TZ=UTC LANG=de_DE.UTF-8", "TZ=Asia/Tokyo LANG=de_DE.UTF-8"}; !slices.Equal(e.Failing(), want) {
+		t.Errorf("Expected it failing with %v, got %v", want, e.Failing())
+	}
+	if len(e.Passing()) != 2 || !e.Deterministic || e.PValue >= 0.01 {
+		t.Errorf("Expected a deterministic, significant difference, got %+v", e)
+	}
+
+	if got := (&Result{Cells: []Cell{{TZ: "UTC"}}, Reports: res.Reports[:1]}).EnvironmentSensitive(0.05); got != nil {
+		t.Errorf("Expected nothing to compare with one setting, got %+v", got)
+	}
+	if _, err := Run(context.Background(), Config{Base: runner.Config{Runs: 1}, TZ: []string{"Mars/Olympus"}}); err == nil {
+		t.Error("Expected an unknown timezone to be rejected")
+	}
+}
+
+func TestApplyEnvironment(t *testing.T) {
+	base := runner.Config{Env: []string{"A=1"}}
+	cfg := Cell{TZ: "Asia/Tokyo", Lang: "de_DE.UTF-8"}.apply(base)
+	if want := []string{"A=1", "TZ=Asia/Tokyo", "LANG=de_DE.UTF-8", "LC_ALL=de_DE.UTF-8"}; !slices.Equal(cfg.Env, want) {
+		t.Errorf("Expected env %v, got %v", want, cfg.Env)
+	}
+	if len(base.Env) != 1 {
+		t.Errorf("Expected the base env untouched, got %v", base.Env)
+	}
+}
+
+func TestRunTimezone(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/tz\n\ngo 1.22\n",
+		"tz_test.go": `package tz
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestDay(t *testing.T) {
+	if day := time.Date(2024, 3, 15, 23, 30, 0, 0, time.UTC).Local().Day(); day != 15 {
+		t.Errorf("Expected day 15, got %d", day)
+	}
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := Run(context.Background(), Config{Base: runner.Config{Dir: dir, Runs: 3}, TZ: []string{"UTC", "Asia/Tokyo"}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	sensitive := res.EnvironmentSensitive(0.5)
+	if len(sensitive) != 1 || !slices.Equal(sensitive[0].Failing(), []string{"TZ=Asia/Tokyo"}) {
+		t.Errorf("Expected TestDay to fail only in Asia/Tokyo, got %+v", sensitive)
+	}
+}
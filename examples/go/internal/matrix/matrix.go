@@ -0,0 +1,275 @@
+// Package matrix runs a suite across combinations of GOMAXPROCS, go test
+// -parallel, the race detector, go test -count, the timezone and the
+// locale, and reports which combinations expose each flaky test
+//
+// Many timing flakes only show at GOMAXPROCS=1, where goroutines interleave
+// differently, or under -race, which slows memory accesses down; repeating
+// tests in one process with -count exposes state leaking between them, and
+// raising -parallel exposes t.Parallel tests that share state
+// Tests that depend on TZ or LANG pass every rerun on one machine and fail
+// every rerun on another, so only varying them shows those
+package matrix
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// Cell is one configuration of the matrix
+type Cell struct {
+	// GOMAXPROCS is the value the tests run with, or 0 to inherit it
+	GOMAXPROCS int
+	// Parallel is the go test -parallel of the tests that call t.Parallel,
+	// or 0 to inherit it, which defaults to GOMAXPROCS
+	Parallel int
+	Race     bool
+	// Count is the go test -count of each run, so each run executes every
+	// test Count times in one process
+	Count int
+	// TZ is the timezone the tests run in, such as "Asia/Tokyo", or empty
+	// to inherit it
+	TZ string
+	// Lang is the locale the tests run in, such as "de_DE.UTF-8", or empty
+	// to inherit it
+	Lang string
+}
+
+// String returns the cell as the settings a go test command line would use,
+// such as "GOMAXPROCS=1 -parallel=4 -race -count=10 TZ=Asia/Tokyo"
+func (c Cell) String() string {
+	var parts []string
+	if c.GOMAXPROCS > 0 {
+		parts = append(parts, "GOMAXPROCS="+strconv.Itoa(c.GOMAXPROCS))
+	}
+	if c.Parallel > 0 {
+		parts = append(parts, "-parallel="+strconv.Itoa(c.Parallel))
+	}
+	if c.Race {
+		parts = append(parts, "-race")
+	}
+	if c.Count > 1 {
+		parts = append(parts, "-count="+strconv.Itoa(c.Count))
+	}
+	if env := c.environment(); env != "" {
+		parts = append(parts, env)
+	}
+	if len(parts) == 0 {
+		return "default"
+	}
+	return strings.Join(parts, " ")
+}
+
+// Environment returns the cell's timezone and locale, such as
+// "TZ=Asia/Tokyo LANG=de_DE.UTF-8", or "inherited" when it sets neither
+func (c Cell) Environment() string {
+	if env := c.environment(); env != "" {
+		return env
+	}
+	return "inherited"
+}
+
+func (c Cell) environment() string {
+	var parts []string
+	if c.TZ != "" {
+		parts = append(parts, "TZ="+c.TZ)
+	}
+	if c.Lang != "" {
+		parts = append(parts, "LANG="+c.Lang)
+	}
+	return strings.Join(parts, " ")
+}
+
+// apply returns base with the cell's settings added
+func (c Cell) apply(base runner.Config) runner.Config {
+	cfg := base
+	cfg.Args = append([]string(nil), base.Args...)
+	if c.GOMAXPROCS > 0 {
+		// -cpu sets GOMAXPROCS in the test binary only, where a GOMAXPROCS
+		// variable would also slow the go command's build down
+		cfg.Args = append(cfg.Args, "-cpu="+strconv.Itoa(c.GOMAXPROCS))
+	}
+	if c.Parallel > 0 {
+		cfg.Args = append(cfg.Args, "-parallel="+strconv.Itoa(c.Parallel))
+	}
+	if c.Race {
+		cfg.Args = append(cfg.Args, "-race")
+	}
+	if c.Count > 1 {
+		// Later flags win, overriding the -count=1 of runner.RunOnce
+		cfg.Args = append(cfg.Args, "-count="+strconv.Itoa(c.Count))
+	}
+	cfg.Env = append([]string(nil), base.Env...)
+	if c.TZ != "" {
+		cfg.Env = append(cfg.Env, "TZ="+c.TZ)
+	}
+	if c.Lang != "" {
+		// LC_ALL overrides LANG, so a host's LC_ALL would otherwise win
+		cfg.Env = append(cfg.Env, "LANG="+c.Lang, "LC_ALL="+c.Lang)
+	}
+	return cfg
+}
+
+// Config describes a matrix run
+type Config struct {
+	// Base is the detection sweep run in every cell; Base.Runs is the
+	// number of runs per cell
+	Base runner.Config
+	// GOMAXPROCS, Parallel, Race, Count, TZ and Lang are the values of each
+	// axis; an empty axis has the single default value 0, 0, false, 1, ""
+	// or ""
+	GOMAXPROCS []int
+	Parallel   []int
+	Race       []bool
+	Count      []int
+	TZ         []string
+	Lang       []string
+}
+
+// Cells returns every combination of the axes, ordered by GOMAXPROCS, then
+// -parallel, then race off before on, then count, then timezone, then
+// locale
+func (cfg Config) Cells() []Cell {
+	procs, parallel, races, counts := cfg.GOMAXPROCS, cfg.Parallel, cfg.Race, cfg.Count
+	zones, langs := cfg.TZ, cfg.Lang
+	if len(procs) == 0 {
+		procs = []int{0}
+	}
+	if len(parallel) == 0 {
+		parallel = []int{0}
+	}
+	if len(races) == 0 {
+		races = []bool{false}
+	}
+	if len(counts) == 0 {
+		counts = []int{1}
+	}
+	if len(zones) == 0 {
+		zones = []string{""}
+	}
+	if len(langs) == 0 {
+		langs = []string{""}
+	}
+	var cells []Cell
+	for _, p := range procs {
+		for _, par := range parallel {
+			for _, r := range races {
+				for _, n := range counts {
+					for _, tz := range zones {
+						for _, lang := range langs {
+							cells = append(cells, Cell{GOMAXPROCS: p, Parallel: par, Race: r, Count: n, TZ: tz, Lang: lang})
+						}
+					}
+				}
+			}
+		}
+	}
+	return cells
+}
+
+// Result holds one detection report per cell
+type Result struct {
+	Cells   []Cell
+	Reports []*runner.Report
+}
+
+// Exposure is one test's outcomes in every cell
+type Exposure struct {
+	Package string
+	Test    string
+	// Stats holds the test's runs in each cell, in the order of
+	// Result.Cells; a cell the test did not run in has empty stats
+	Stats []runner.TestStats
+}
+
+// Exposed returns the cells in which the test failed at least once
+func (e Exposure) Exposed(cells []Cell) []Cell {
+	var exposed []Cell
+	for i, s := range e.Stats {
+		if s.Failed > 0 {
+			exposed = append(exposed, cells[i])
+		}
+	}
+	return exposed
+}
+
+// detectFunc runs the suite, as runner.Detect does
+type detectFunc func(ctx context.Context, cfg runner.Config) (*runner.Report, error)
+
+// Run runs the base sweep in every cell of the matrix, one cell at a time so
+// the cells do not compete for CPUs
+func Run(ctx context.Context, cfg Config) (*Result, error) {
+	return run(ctx, cfg, runner.Detect)
+}
+
+func run(ctx context.Context, cfg Config, detect detectFunc) (*Result, error) {
+	if cfg.Base.Runs < 1 {
+		return nil, fmt.Errorf("runs must be at least 1, got %d", cfg.Base.Runs)
+	}
+	for _, p := range cfg.GOMAXPROCS {
+		if p < 0 {
+			return nil, fmt.Errorf("GOMAXPROCS must not be negative, got %d", p)
+		}
+	}
+	for _, p := range cfg.Parallel {
+		if p < 1 {
+			return nil, fmt.Errorf("parallel must be at least 1, got %d", p)
+		}
+	}
+	for _, n := range cfg.Count {
+		if n < 1 {
+			return nil, fmt.Errorf("count must be at least 1, got %d", n)
+		}
+	}
+	for _, tz := range cfg.TZ {
+		if _, err := time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("unknown timezone %q: %w", tz, err)
+		}
+	}
+	res := &Result{Cells: cfg.Cells()}
+	for _, cell := range res.Cells {
+		report, err := detect(ctx, cell.apply(cfg.Base))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cell, err)
+		}
+		res.Reports = append(res.Reports, report)
+	}
+	return res, nil
+}
+
+// Exposures returns every test that failed in at least one cell, sorted by
+// package, then test name
+func (r *Result) Exposures() []Exposure {
+	type key struct{ pkg, test string }
+	byTest := make(map[key]*Exposure)
+	for i, report := range r.Reports {
+		for _, s := range report.Tests {
+			k := key{s.Package, s.Test}
+			e := byTest[k]
+			if e == nil {
+				e = &Exposure{Package: s.Package, Test: s.Test, Stats: make([]runner.TestStats, len(r.Cells))}
+				byTest[k] = e
+			}
+			e.Stats[i] = *s
+		}
+	}
+	var exposures []Exposure
+	for _, e := range byTest {
+		if len(e.Exposed(r.Cells)) > 0 {
+			exposures = append(exposures, *e)
+		}
+	}
+	sort.Slice(exposures, func(i, j int) bool {
+		a, b := exposures[i], exposures[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		return a.Test < b.Test
+	})
+	return exposures
+}
@@ -0,0 +1,130 @@
+package matrix
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestCells(t *testing.T) {
+	cells := Config{GOMAXPROCS: []int{1, 8}, Race: []bool{false, true}, Count: []int{1, 10}}.Cells()
+	if len(cells) != 8 {
+		t.Fatalf("Expected 8 cells, got %v", cells)
+	}
+	if got := cells[0].String(); got != "GOMAXPROCS=1" {
+		t.Errorf("Expected the first cell GOMAXPROCS=1, got %q", got)
+	}
+	if got := cells[7].String(); got != "GOMAXPROCS=8 -race -count=10" {
+		t.Errorf("Expected the last cell with every setting, got %q", got)
+	}
+	cells = Config{GOMAXPROCS: []int{2}, Parallel: []int{1, 8}}.Cells()
+	if len(cells) != 2 || cells[1].String() != "GOMAXPROCS=2 -parallel=8" {
+		t.Errorf("Expected a cell per -parallel value, got %v", cells)
+	}
+	if got := (Config{}).Cells(); len(got) != 1 || got[0].String() != "default" {
+		t.Errorf("Expected one default cell without axes, got %v", got)
+	}
+}
+
+func TestApply(t *testing.T) {
+	base := runner.Config{Args: []string{"-short"}, Runs: 3}
+	cfg := Cell{GOMAXPROCS: 2, Parallel: 4, Race: true, Count: 5}.apply(base)
+	if want := []string{"-short", "-cpu=2", "-parallel=4", "-race", "-count=5"}; !reflect.DeepEqual(cfg.Args, want) {
+		t.Errorf("Expected args %v, got %v", want, cfg.Args)
+	}
+	if len(base.Args) != 1 || cfg.Runs != 3 {
+		t.Errorf("Expected the base config untouched and runs kept, got %v and %d", base.Args, cfg.Runs)
+	}
+}
+
+func TestRunReportsExposingCells(t *testing.T) {
+	// TestTiming fails only at GOMAXPROCS=1 under -race; TestBroken fails
+	// everywhere and TestFine nowhere
+	detect := func(ctx context.Context, cfg runner.Config) (*runner.Report, error) {
+		args := strings.Join(cfg.Args, " ")
+		timing := runner.Pass
+		if strings.Contains(args, "-cpu=1") && strings.Contains(args, "-race") {
+			timing = runner.Fail
+		}
+		return runner.Aggregate(1, []runner.Result{
+			{Package: "p", Test: "TestTiming", Outcome: timing},
+			{Package: "p", Test: "TestBroken", Outcome: runner.Fail},
+			{Package: "p", Test: "TestFine", Outcome: runner.Pass},
+		}), nil
+	}
+	res, err := run(context.Background(), Config{Base: runner.Config{Runs: 1}, GOMAXPROCS: []int{1, 8}, Race: []bool{false, true}}, detect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exposures := res.Exposures()
+	if len(exposures) != 2 || exposures[0].Test != "TestBroken" || exposures[1].Test != "TestTiming" {
+		t.Fatalf("Expected TestBroken and TestTiming, got %+v", exposures)
+	}
+	if got := exposures[0].Exposed(res.Cells); len(got) != 4 {
+		t.Errorf("Expected TestBroken exposed by all 4 cells, got %v", got)
+	}
+	if got := exposures[1].Exposed(res.Cells); len(got) != 1 || got[0] != (Cell{GOMAXPROCS: 1, Race: true, Count: 1}) {
+		t.Errorf("Expected TestTiming exposed only by GOMAXPROCS=1 -race, got %v", got)
+	}
+}
+
+func TestRunRejectsBadAxes(t *testing.T) {
+	for _, cfg := range []Config{
+		{Base: runner.Config{Runs: 0}},
+		{Base: runner.Config{Runs: 1}, GOMAXPROCS: []int{-1}},
+		{Base: runner.Config{Runs: 1}, Parallel: []int{0}},
+		{Base: runner.Config{Runs: 1}, Count: []int{0}},
+	} {
+		if _, err := Run(context.Background(), cfg); err == nil {
+			t.Errorf("Expected %+v to be rejected", cfg)
+		}
+	}
+}
+
+func TestRunGOMAXPROCS(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/procs\n\ngo 1.22\n",
+		"procs_test.go": `package procs
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestNeedsParallelism(t *testing.T) {
+	if runtime.GOMAXPROCS(0) == 1 {
+		t.Error("GOMAXPROCS is 1")
+	}
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := Run(context.Background(), Config{Base: runner.Config{Dir: dir, Runs: 1}, GOMAXPROCS: []int{1, 2}, Count: []int{1, 3}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	exposures := res.Exposures()
+	if len(exposures) != 1 {
+		t.Fatalf("Expected one exposed test, got %+v", exposures)
+	}
+	exposed := exposures[0].Exposed(res.Cells)
+	if len(exposed) != 2 || exposed[0].GOMAXPROCS != 1 || exposed[1].GOMAXPROCS != 1 {
+		t.Errorf("Expected only the GOMAXPROCS=1 cells to expose the test, got %v", exposed)
+	}
+	if s := exposures[0].Stats[1]; s.Failed != 3 {
+		t.Errorf("Expected -count=3 to run the test 3 times per run, got %+v", s)
+	}
+}
@@ -0,0 +1,84 @@
+package matrix
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/stats"
+)
+
+// ParallelSafety is one test's outcomes at the lowest and the highest
+// -parallel of a matrix, pooled over the other axes
+type ParallelSafety struct {
+	Package string
+	Test    string
+	// Serial and Parallel are the test's runs at the lowest and highest
+	// -parallel values
+	Serial, Parallel runner.TestStats
+	// PValue is Fisher's exact test of equal flake rates at both values
+	PValue float64
+	// Unsafe is set when the test fails significantly more often at the
+	// highest -parallel, so tests running alongside it break it
+	Unsafe bool
+}
+
+// ParallelSafety compares every test that ran at both the lowest and the
+// highest -parallel of the matrix, sorted by package, then test name
+// Without two distinct -parallel values there is nothing to compare and it
+// returns nil
+func (r *Result) ParallelSafety(alpha float64) []ParallelSafety {
+	var levels []int
+	for _, cell := range r.Cells {
+		if !slices.Contains(levels, cell.Parallel) {
+			levels = append(levels, cell.Parallel)
+		}
+	}
+	if len(levels) < 2 {
+		return nil
+	}
+	low, high := slices.Min(levels), slices.Max(levels)
+
+	type key struct{ pkg, test string }
+	byTest := make(map[key]*ParallelSafety)
+	for i, report := range r.Reports {
+		level := r.Cells[i].Parallel
+		if level != low && level != high {
+			continue
+		}
+		for _, s := range report.Tests {
+			k := key{s.Package, s.Test}
+			p := byTest[k]
+			if p == nil {
+				p = &ParallelSafety{Package: s.Package, Test: s.Test}
+				byTest[k] = p
+			}
+			side := &p.Serial
+			if level == high {
+				side = &p.Parallel
+			}
+			side.Passed += s.Passed
+			side.Failed += s.Failed
+			side.Skipped += s.Skipped
+		}
+	}
+
+	var safety []ParallelSafety
+	for _, p := range byTest {
+		serial, parallel := p.Serial.Passed+p.Serial.Failed, p.Parallel.Passed+p.Parallel.Failed
+		if serial == 0 || parallel == 0 {
+			continue
+		}
+		p.PValue = stats.FisherExact(p.Serial.Failed, serial, p.Parallel.Failed, parallel)
+		p.Unsafe = p.PValue < alpha && p.Parallel.FlakeRate() > p.Serial.FlakeRate()
+		safety = append(safety, *p)
+	}
+	sort.Slice(safety, func(i, j int) bool {
+		a, b := safety[i], safety[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		return a.Test < b.Test
+	})
+	return safety
+}
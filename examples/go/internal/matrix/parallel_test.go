@@ -0,0 +1,108 @@
+package matrix
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestParallelSafety(t *testing.T) {
+	// TestShared fails every run at -parallel=8 only; TestFlaky fails every
+	// other run at either value
+	detect := func(ctx context.Context, cfg runner.Config) (*runner.Report, error) {
+		var results []runner.Result
+		for run := 0; run < cfg.Runs; run++ {
+			shared, flaky := runner.Pass, runner.Pass
+			if slices.Contains(cfg.Args, "-parallel=8") {
+				shared = runner.Fail
+			}
+			if run%2 == 1 {
+				flaky = runner.Fail
+			}
+			results = append(results,
+				runner.Result{Package: "p", Test: "TestShared", Run: run, Outcome: shared},
+				runner.Result{Package: "p", Test: "TestFlaky", Run: run, Outcome: flaky})
+		}
+		return runner.Aggregate(cfg.Runs, results), nil
+	}
+	res, err := run(context.Background(), Config{Base: runner.Config{Runs: 10}, Parallel: []int{1, 8}, Race: []bool{false, true}}, detect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	safety := res.ParallelSafety(0.05)
+	if len(safety) != 2 || safety[0].Test != "TestFlaky" || safety[1].Test != "TestShared" {
+		t.Fatalf("Expected TestFlaky and TestShared, got %+v", safety)
+	}
+	if flaky := safety[0]; flaky.Unsafe || flaky.PValue < 0.99 || flaky.Serial.Failed != 10 || flaky.Parallel.Runs() != 20 {
+		t.Errorf("Expected TestFlaky as flaky at both values, pooled over -race, got %+v", flaky)
+	}
+	if shared := safety[1]; !shared.Unsafe || shared.Serial.Failed != 0 || shared.Parallel.Failed != 20 {
+		t.Errorf("Expected TestShared parallel-unsafe, got %+v", shared)
+	}
+
+	if got := (&Result{Cells: []Cell{{Parallel: 4}}, Reports: res.Reports[:1]}).ParallelSafety(0.05); got != nil {
+		t.Errorf("Expected nothing to compare with one -parallel value, got %+v", got)
+	}
+}
+
+func TestRunParallel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/shared\n\ngo 1.22\n",
+		"shared_test.go": `package shared
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	mu    sync.Mutex
+	owner string
+)
+
+func claim(t *testing.T) {
+	t.Parallel()
+	mu.Lock()
+	owner = t.Name()
+	mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if owner != t.Name() {
+		t.Errorf("owned by %s", owner)
+	}
+}
+
+func TestA(t *testing.T) { claim(t) }
+func TestB(t *testing.T) { claim(t) }
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := Run(context.Background(), Config{Base: runner.Config{Dir: dir, Runs: 4}, Parallel: []int{1, 2}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	failed := 0
+	for _, s := range res.ParallelSafety(1) {
+		if s.Serial.Failed != 0 {
+			t.Errorf("Expected %s to pass at -parallel=1, got %+v", s.Test, s.Serial)
+		}
+		failed += s.Parallel.Failed
+	}
+	if failed != 4 {
+		t.Errorf("Expected one of the two tests to fail each run at -parallel=2, got %d failures", failed)
+	}
+}
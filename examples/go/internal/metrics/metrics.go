@@ -0,0 +1,140 @@
+// Package metrics exports detection outcomes in the Prometheus text format
+// so long-running detection can be scraped and alerted on
+//
+// The flake rate of a test over a window is
+//
+//	rate(test_failures_total[1h]) / rate(test_runs_total[1h])
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the
+// test_duration_seconds histogram
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30}
+
+// Exporter accumulates test outcomes and serves them as Prometheus metrics
+// It is safe for concurrent use
+type Exporter struct {
+	mu      sync.Mutex
+	buckets []float64
+	suites  int
+	tests   map[testKey]*testMetrics
+}
+
+type testKey struct {
+	pkg, test string
+}
+
+type testMetrics struct {
+	runs, failures int
+	// buckets counts durations per bucket, not cumulatively
+	buckets []int
+	sum     float64
+}
+
+// NewExporter returns an Exporter with no observations, using
+// DefaultBuckets
+func NewExporter() *Exporter {
+	return &Exporter{buckets: DefaultBuckets, tests: make(map[testKey]*testMetrics)}
+}
+
+// Observe records the results of one run of the suite
+// Skipped tests only count towards test_suite_runs_total
+func (e *Exporter) Observe(results []runner.Result) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.suites++
+	for _, r := range results {
+		if r.Outcome == runner.Skip {
+			continue
+		}
+		k := testKey{r.Package, r.Test}
+		m := e.tests[k]
+		if m == nil {
+			m = &testMetrics{buckets: make([]int, len(e.buckets)+1)}
+			e.tests[k] = m
+		}
+		m.runs++
+		if r.Outcome == runner.Fail {
+			m.failures++
+		}
+		seconds := r.Duration.Seconds()
+		m.buckets[sort.SearchFloat64s(e.buckets, seconds)]++
+		m.sum += seconds
+	}
+}
+
+// Write writes every metric in the Prometheus text exposition format
+func (e *Exporter) Write(w io.Writer) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	keys := make([]testKey, 0, len(e.tests))
+	for k := range e.tests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].pkg != keys[j].pkg {
+			return keys[i].pkg < keys[j].pkg
+		}
+		return keys[i].test < keys[j].test
+	})
+
+	bw := bufio.NewWriter(w)
+	header(bw, "test_suite_runs_total", "counter", "Runs of the suite")
+	fmt.Fprintf(bw, "test_suite_runs_total %d\n", e.suites)
+
+	header(bw, "test_runs_total", "counter", "Test executions that passed or failed")
+	for _, k := range keys {
+		fmt.Fprintf(bw, "test_runs_total{%s} %d\n", labels(k), e.tests[k].runs)
+	}
+	header(bw, "test_failures_total", "counter", "Test executions that failed")
+	for _, k := range keys {
+		fmt.Fprintf(bw, "test_failures_total{%s} %d\n", labels(k), e.tests[k].failures)
+	}
+
+	header(bw, "test_duration_seconds", "histogram", "Duration of test executions that passed or failed")
+	for _, k := range keys {
+		m := e.tests[k]
+		cumulative := 0
+		for i, le := range e.buckets {
+			cumulative += m.buckets[i]
+			fmt.Fprintf(bw, "test_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels(k), formatFloat(le), cumulative)
+		}
+		fmt.Fprintf(bw, "test_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels(k), m.runs)
+		fmt.Fprintf(bw, "test_duration_seconds_sum{%s} %s\n", labels(k), formatFloat(m.sum))
+		fmt.Fprintf(bw, "test_duration_seconds_count{%s} %d\n", labels(k), m.runs)
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the metrics to a Prometheus scrape
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.Write(w)
+}
+
+func header(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// labelEscaper escapes label values as the exposition format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labels(k testKey) string {
+	return fmt.Sprintf(`package="%s",test="%s"`, labelEscaper.Replace(k.pkg), labelEscaper.Replace(k.test))
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestExporter(t *testing.T) {
+	e := NewExporter()
+	e.Observe([]runner.Result{
+		{Package: "p", Test: "TestA", Outcome: runner.Pass, Duration: 2 * time.Millisecond},
+		{Package: "p", Test: "TestSkip", Outcome: runner.Skip},
+	})
+	e.Observe([]runner.Result{
+		{Package: "p", Test: "TestA", Outcome: runner.Fail, Duration: 40 * time.Second},
+	})
+
+	var b strings.Builder
+	if err := e.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE test_suite_runs_total counter\ntest_suite_runs_total 2\n",
+		"test_runs_total{package=\"p\",test=\"TestA\"} 2\n",
+		"test_failures_total{package=\"p\",test=\"TestA\"} 1\n",
+		"# TYPE test_duration_seconds histogram\n",
+		"test_duration_seconds_bucket{package=\"p\",test=\"TestA\",le=\"0.001\"} 0\n",
+		"test_duration_seconds_bucket{package=\"p\",test=\"TestA\",le=\"0.005\"} 1\n",
+		"test_duration_seconds_bucket{package=\"p\",test=\"TestA\",le=\"30\"} 1\n",
+		"test_duration_seconds_bucket{package=\"p\",test=\"TestA\",le=\"+Inf\"} 2\n",
+		"test_duration_seconds_sum{package=\"p\",test=\"TestA\"} 40.002\n",
+		"test_duration_seconds_count{package=\"p\",test=\"TestA\"} 2\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Expected metrics to contain %q:\n%s", want, b.String())
+		}
+	}
+	if strings.Contains(b.String(), "TestSkip") {
+		t.Errorf("Expected skipped tests to be left out:\n%s", b.String())
+	}
+}
+
+func TestLabelsAreEscaped(t *testing.T) {
+	if got := labels(testKey{"p", `TestA/with "quotes"`}); got != `package="p",test="TestA/with \"quotes\""` {
+		t.Errorf("Unexpected labels %s", got)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	e := NewExporter()
+	e.Observe(nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "test_suite_runs_total 1") {
+		t.Errorf("Unexpected body:\n%s", rec.Body.String())
+	}
+}
@@ -0,0 +1,231 @@
+// Package minimize narrows a failure that only reproduces with the whole
+// suite down to the smallest set of tests that still reproduces it, with
+// Zeller's delta debugging (ddmin) over the -run selection
+//
+// go test runs the selected tests in source order, so every subset keeps the
+// relative order the failure needs; unlike bisecting the tests before a
+// victim, ddmin also finds failures that need several tests together
+package minimize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// Config describes a minimization
+type Config struct {
+	// Package is the single package pattern passed to go test
+	Package string
+	// Dir is the directory go test runs in
+	Dir string
+	// Test is the top-level test whose failure to minimize
+	Test string
+	// Seed is the GO_TEST_SEED every run uses
+	Seed int64
+	// Tries is how many times each selection runs; it reproduces the
+	// failure if Test fails in any of them (default 1)
+	Tries int
+	// Args holds extra go test arguments, such as -race or -shuffle N
+	Args []string
+	// Env holds additional KEY=VALUE pairs for every run
+	Env []string
+}
+
+// Probe is one selection that was run
+type Probe struct {
+	// Tests are the other tests selected alongside Config.Test
+	Tests []string
+	// Failed reports whether Config.Test failed in any try
+	Failed bool
+}
+
+// Result is the outcome of a minimization
+type Result struct {
+	// Suite holds the package's top-level tests in source order
+	Suite []string
+	// Minimal holds the other tests the failure needs, in source order; it
+	// is 1-minimal, so removing any one of them stops the failure
+	Minimal []string
+	// Alone reports that the test fails without any other test, leaving
+	// Minimal empty
+	Alone bool
+	// Probes are the selections run, in order
+	Probes []Probe
+}
+
+// Pattern is the -run regex that reproduces the failure with the minimal
+// set of tests
+func (r *Result) Pattern(test string) string {
+	return runner.RunPattern(append(append([]string(nil), r.Minimal...), test))
+}
+
+// ErrNotReproduced is returned when the test does not fail with the whole
+// suite selected
+var ErrNotReproduced = errors.New("the failure does not reproduce with the whole suite")
+
+// execFunc runs the selected tests and returns their results
+type execFunc func(ctx context.Context, tests []string) ([]runner.Result, error)
+
+// Run lists the package's tests and minimizes the failure of cfg.Test over
+// the others
+func Run(ctx context.Context, cfg Config) (*Result, error) {
+	rc := runner.Config{Packages: []string{cfg.Package}, Dir: cfg.Dir, Env: cfg.Env}
+	suite, err := runner.ListTests(ctx, rc, ".")
+	if err != nil {
+		return nil, err
+	}
+	return run(ctx, cfg, suite, goTest(cfg))
+}
+
+func goTest(cfg Config) execFunc {
+	return func(ctx context.Context, tests []string) ([]runner.Result, error) {
+		return runner.RunOnce(ctx, runner.Config{
+			Packages: []string{cfg.Package},
+			Seed:     cfg.Seed,
+			Dir:      cfg.Dir,
+			Run:      runner.RunPattern(tests),
+			Args:     cfg.Args,
+			Env:      cfg.Env,
+		}, 0)
+	}
+}
+
+func run(ctx context.Context, cfg Config, suite []string, exec execFunc) (*Result, error) {
+	if cfg.Tries == 0 {
+		cfg.Tries = 1
+	}
+	if cfg.Tries < 0 {
+		return nil, fmt.Errorf("tries must be at least 1, got %d", cfg.Tries)
+	}
+	if !slices.Contains(suite, cfg.Test) {
+		return nil, fmt.Errorf("%s is not a top-level test of %s", cfg.Test, cfg.Package)
+	}
+	res := &Result{Suite: suite}
+	cache := make(map[string]bool)
+	fails := func(tests []string) (bool, error) {
+		key := strings.Join(tests, "\x00")
+		if failed, ok := cache[key]; ok {
+			return failed, nil
+		}
+		failed, err := reproduces(ctx, exec, cfg, tests)
+		if err != nil {
+			return false, err
+		}
+		cache[key] = failed
+		res.Probes = append(res.Probes, Probe{Tests: tests, Failed: failed})
+		return failed, nil
+	}
+
+	var others []string
+	for _, test := range suite {
+		if test != cfg.Test {
+			others = append(others, test)
+		}
+	}
+	if ok, err := fails(others); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("%s passed with all %d tests selected: %w", cfg.Test, len(suite), ErrNotReproduced)
+	}
+	if ok, err := fails(nil); err != nil {
+		return nil, err
+	} else if ok {
+		res.Alone = true
+		return res, nil
+	}
+	minimal, err := ddmin(others, fails)
+	if err != nil {
+		return nil, err
+	}
+	res.Minimal = minimal
+	return res, nil
+}
+
+// reproduces runs tests with cfg.Test up to cfg.Tries times and reports
+// whether cfg.Test failed in any run
+func reproduces(ctx context.Context, exec execFunc, cfg Config, tests []string) (bool, error) {
+	selected := append(append([]string(nil), tests...), cfg.Test)
+	for try := 0; try < cfg.Tries; try++ {
+		results, err := exec(ctx, selected)
+		if err != nil {
+			return false, err
+		}
+		for _, r := range results {
+			if r.Test == cfg.Test && r.Outcome == runner.Fail {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// ddmin returns a 1-minimal subset of candidates for which fails holds,
+// given that it holds for candidates and not for the empty set
+// It tries each of n chunks, then each chunk's complement, and splits
+// finer when neither reproduces the failure
+func ddmin(candidates []string, fails func([]string) (bool, error)) ([]string, error) {
+	n := 2
+	for len(candidates) >= 2 {
+		chunks := split(candidates, n)
+		reduced := false
+		for _, chunk := range chunks {
+			ok, err := fails(chunk)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				candidates, n, reduced = chunk, 2, true
+				break
+			}
+		}
+		if !reduced && n > 2 {
+			for i := range chunks {
+				complement := without(chunks, i)
+				ok, err := fails(complement)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					candidates, n, reduced = complement, max(n-1, 2), true
+					break
+				}
+			}
+		}
+		if reduced {
+			continue
+		}
+		if n >= len(candidates) {
+			break
+		}
+		n = min(2*n, len(candidates))
+	}
+	return candidates, nil
+}
+
+// split cuts tests into n contiguous chunks of nearly equal size
+func split(tests []string, n int) [][]string {
+	chunks := make([][]string, 0, n)
+	start := 0
+	for i := 0; i < n; i++ {
+		end := start + (len(tests)-start)/(n-i)
+		chunks = append(chunks, tests[start:end])
+		start = end
+	}
+	return chunks
+}
+
+// without concatenates every chunk but the i-th, keeping source order
+func without(chunks [][]string, i int) []string {
+	var tests []string
+	for j, chunk := range chunks {
+		if j != i {
+			tests = append(tests, chunk...)
+		}
+	}
+	return tests
+}
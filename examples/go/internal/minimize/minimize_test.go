@@ -0,0 +1,158 @@
+package minimize
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// fakeSuite runs the selected tests in suite order; fail decides each
+// test's outcome from the tests that ran before it
+type fakeSuite struct {
+	tests []string
+	fail  func(test string, before map[string]bool) bool
+	runs  int
+}
+
+func (s *fakeSuite) exec(_ context.Context, selected []string) ([]runner.Result, error) {
+	s.runs++
+	want := make(map[string]bool)
+	for _, test := range selected {
+		want[test] = true
+	}
+	before := make(map[string]bool)
+	var results []runner.Result
+	for _, test := range s.tests {
+		if !want[test] {
+			continue
+		}
+		outcome := runner.Pass
+		if s.fail(test, before) {
+			outcome = runner.Fail
+		}
+		results = append(results, runner.Result{Test: test, Outcome: outcome})
+		before[test] = true
+	}
+	return results, nil
+}
+
+func suiteOf(n int) []string {
+	var tests []string
+	for i := 0; i < n; i++ {
+		tests = append(tests, "Test"+string(rune('A'+i)))
+	}
+	return tests
+}
+
+func TestMinimizeFindsTestsNeededTogether(t *testing.T) {
+	suite := &fakeSuite{
+		tests: suiteOf(16),
+		fail: func(test string, before map[string]bool) bool {
+			return test == "TestP" && before["TestC"] && before["TestK"]
+		},
+	}
+	res, err := run(context.Background(), Config{Test: "TestP"}, suite.tests, suite.exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"TestC", "TestK"}; !reflect.DeepEqual(res.Minimal, want) {
+		t.Errorf("Expected %v, got %v", want, res.Minimal)
+	}
+	if res.Alone || res.Pattern("TestP") != "^(TestC|TestK|TestP)$" {
+		t.Errorf("Unexpected result: alone=%v pattern=%s", res.Alone, res.Pattern("TestP"))
+	}
+	if len(res.Probes) != suite.runs || suite.runs > 30 {
+		t.Errorf("Expected at most 30 probes, one run each; got %d probes and %d runs", len(res.Probes), suite.runs)
+	}
+}
+
+func TestMinimizeReportsFailuresAlone(t *testing.T) {
+	suite := &fakeSuite{tests: suiteOf(4), fail: func(test string, _ map[string]bool) bool { return test == "TestB" }}
+	res, err := run(context.Background(), Config{Test: "TestB"}, suite.tests, suite.exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Alone || len(res.Minimal) != 0 {
+		t.Errorf("Expected TestB to fail alone, got %+v", res)
+	}
+}
+
+func TestMinimizeRetriesEachSelection(t *testing.T) {
+	// The failure shows on every third run only
+	suite := &fakeSuite{tests: suiteOf(6)}
+	suite.fail = func(test string, before map[string]bool) bool {
+		return test == "TestF" && before["TestA"] && suite.runs%3 == 0
+	}
+	if _, err := run(context.Background(), Config{Test: "TestF"}, suite.tests, suite.exec); !errors.Is(err, ErrNotReproduced) {
+		t.Fatalf("Expected a single try to miss the failure, got %v", err)
+	}
+	suite.runs = 0
+	res, err := run(context.Background(), Config{Test: "TestF", Tries: 3}, suite.tests, suite.exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Minimal, []string{"TestA"}) {
+		t.Errorf("Expected [TestA], got %v", res.Minimal)
+	}
+}
+
+func TestMinimizeRejectsUnknownTest(t *testing.T) {
+	suite := &fakeSuite{tests: suiteOf(3), fail: func(string, map[string]bool) bool { return false }}
+	if _, err := run(context.Background(), Config{Test: "TestZ", Package: "./p"}, suite.tests, suite.exec); err == nil || !strings.Contains(err.Error(), "TestZ") {
+		t.Errorf("Expected an unknown test to be rejected, got %v", err)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	got := split(suiteOf(5), 3)
+	want := [][]string{{"TestA"}, {"TestB", "TestC"}, {"TestD", "TestE"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestRunMinimizesRealPackage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/shared\n\ngo 1.22\n",
+		"shared_test.go": `package shared
+
+import "testing"
+
+var registered = map[string]bool{}
+
+func TestOne(t *testing.T)      {}
+func TestRegisterA(t *testing.T) { registered["a"] = true }
+func TestTwo(t *testing.T)      {}
+func TestRegisterB(t *testing.T) { registered["b"] = true }
+func TestThree(t *testing.T)    {}
+
+func TestVictim(t *testing.T) {
+	if registered["a"] && registered["b"] {
+		t.Error("both handlers registered")
+	}
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := Run(context.Background(), Config{Package: ".", Dir: dir, Test: "TestVictim"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"TestRegisterA", "TestRegisterB"}; !reflect.DeepEqual(res.Minimal, want) {
+		t.Errorf("Expected %v, got %v", want, res.Minimal)
+	}
+}
@@ -0,0 +1,145 @@
+// Package notify tells people when the flakiness of a test changes: when a
+// test crosses the flaky threshold for the first time, and when a
+// quarantined test has passed long enough to come out of quarantine
+//
+// Events are derived from the detection history, so each change is sent
+// once, by the sweep that makes it
+package notify
+
+import (
+	"context"
+	"errors"
+
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/quarantine"
+)
+
+// Kind is what changed about a test
+type Kind string
+
+const (
+	// NewlyFlaky is a test whose flake rate exceeded the threshold in a
+	// sweep for the first time in the history
+	NewlyFlaky Kind = "newly_flaky"
+	// Recovered is a quarantined test that has now passed the required
+	// number of runs in a row
+	Recovered Kind = "quarantine_recovered"
+)
+
+// Event is one change to notify about
+type Event struct {
+	Kind    Kind   `json:"kind"`
+	Package string `json:"package"`
+	Test    string `json:"test"`
+	Commit  string `json:"commit,omitempty"`
+	// Runs, Failed and FlakeRate describe the test in the sweep that
+	// triggered the event
+	Runs      int     `json:"runs"`
+	Failed    int     `json:"failed"`
+	FlakeRate float64 `json:"flake_rate"`
+	// Message is the sweep's first failure message of a NewlyFlaky test
+	Message string `json:"message,omitempty"`
+	// PassingRuns is how many runs in a row a Recovered test has passed
+	PassingRuns int `json:"passing_runs,omitempty"`
+	// Owners are the teams an owners file assigns the test to
+	Owners []string `json:"owners,omitempty"`
+}
+
+// Notifier delivers events somewhere people see them
+type Notifier interface {
+	Notify(ctx context.Context, events []Event) error
+}
+
+// Rule sets when events fire
+type Rule struct {
+	// FlakeRate is the flaky threshold: a sweep in which a test fails more
+	// than this share of its runs makes it flaky
+	FlakeRate float64
+	// PassingRuns is how many runs in a row a quarantined test must pass to
+	// count as recovered
+	PassingRuns int
+}
+
+// Events compares the current session with the past ones and returns what
+// changed, ordered by package and test with NewlyFlaky events first
+// Quarantined tests are already known to be flaky and only ever recover;
+// their runs are skipped, and never count, unless the sweep ran them anyway
+func Events(past []history.Session, current *history.Session, list *quarantine.List, rule Rule) []Event {
+	sessionReport := history.Report([]history.Session{*current})
+	everFlaky := make(map[[2]string]bool)
+	for _, s := range past {
+		for _, t := range history.Report([]history.Session{s}).Tests {
+			if t.Runs() > 0 && t.FlakeRate() > rule.FlakeRate {
+				everFlaky[[2]string{t.Package, t.Test}] = true
+			}
+		}
+	}
+
+	var flaky, recovered []Event
+	for _, t := range sessionReport.Tests {
+		event := Event{
+			Package:   t.Package,
+			Test:      t.Test,
+			Commit:    current.Commit,
+			Runs:      t.Runs(),
+			Failed:    t.Failed,
+			FlakeRate: t.FlakeRate(),
+		}
+		if list.Contains(t.Test) {
+			streak, before := passingStreak(past, current, t.Package, t.Test)
+			if rule.PassingRuns > 0 && streak >= rule.PassingRuns && before < rule.PassingRuns {
+				event.Kind, event.PassingRuns = Recovered, streak
+				recovered = append(recovered, event)
+			}
+			continue
+		}
+		if t.Runs() == 0 || t.FlakeRate() <= rule.FlakeRate || everFlaky[[2]string{t.Package, t.Test}] {
+			continue
+		}
+		event.Kind = NewlyFlaky
+		if len(t.FailureMessages) > 0 {
+			event.Message = t.FailureMessages[0]
+		}
+		flaky = append(flaky, event)
+	}
+	return append(flaky, recovered...)
+}
+
+// passingStreak counts the test's passing runs in a row up to the end of
+// the current session, and how many of them came before it; skipped runs
+// neither count nor break the streak
+func passingStreak(past []history.Session, current *history.Session, pkg, test string) (streak, before int) {
+	sessions := append(append([]history.Session(nil), past...), *current)
+	for i := len(sessions) - 1; i >= 0; i-- {
+		results := sessions[i].Results
+		for j := len(results) - 1; j >= 0; j-- {
+			r := results[j]
+			if r.Package != pkg || r.Test != test || r.Outcome == runner.Skip {
+				continue
+			}
+			if r.Outcome != runner.Pass {
+				return streak, before
+			}
+			streak++
+			if i < len(sessions)-1 {
+				before++
+			}
+		}
+	}
+	return streak, before
+}
+
+// Multi sends events to every notifier, returning their errors joined
+type Multi []Notifier
+
+// Notify implements Notifier
+func (m Multi) Notify(ctx context.Context, events []Event) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, events); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/quarantine"
+)
+
+// session records the outcomes of one sweep, in run order
+func session(outcomes map[string]string) history.Session {
+	var s history.Session
+	for test, runs := range outcomes {
+		for _, o := range runs {
+			outcome := map[rune]runner.Outcome{'.': runner.Pass, 'F': runner.Fail, 'S': runner.Skip}[o]
+			r := history.Record{Package: "p", Test: test, Outcome: outcome}
+			if outcome == runner.Fail {
+				r.Message = "f_test.go:3: boom"
+			}
+			s.Results = append(s.Results, r)
+		}
+	}
+	return s
+}
+
+func TestEventsNewlyFlaky(t *testing.T) {
+	rule := Rule{FlakeRate: 0.1, PassingRuns: 5}
+	past := []history.Session{
+		session(map[string]string{"TestNew": "..........", "TestKnown": "..F.."}),
+	}
+	current := session(map[string]string{"TestNew": "..F..", "TestKnown": "F....", "TestRare": "..........F..........", "TestStable": "....."})
+
+	events := Events(past, &current, &quarantine.List{}, rule)
+	if len(events) != 1 {
+		t.Fatalf("Expected only TestNew to become flaky, got %+v", events)
+	}
+	e := events[0]
+	if e.Kind != NewlyFlaky || e.Test != "TestNew" || e.Runs != 5 || e.Failed != 1 || e.Message != "f_test.go:3: boom" {
+		t.Errorf("Unexpected event: %+v", e)
+	}
+
+	// A test flaky in every sweep of the history is reported once
+	if events := Events(append(past, current), &current, &quarantine.List{}, rule); len(events) != 0 {
+		t.Errorf("Expected no events once TestNew is in the history, got %+v", events)
+	}
+}
+
+func TestEventsQuarantineRecovered(t *testing.T) {
+	rule := Rule{FlakeRate: 0.1, PassingRuns: 5}
+	list := &quarantine.List{Entries: []quarantine.Entry{{Test: "TestQ"}}}
+
+	past := []history.Session{session(map[string]string{"TestQ": "F..S"})}
+	current := session(map[string]string{"TestQ": "..."})
+	events := Events(past, &current, list, rule)
+	if len(events) != 1 || events[0].Kind != Recovered || events[0].PassingRuns != 5 {
+		t.Fatalf("Expected TestQ to recover after 5 passing runs, got %+v", events)
+	}
+
+	// The streak had already reached 5 before the next sweep
+	next := session(map[string]string{"TestQ": ".."})
+	if events := Events(append(past, current), &next, list, rule); len(events) != 0 {
+		t.Errorf("Expected the recovery to be reported once, got %+v", events)
+	}
+
+	failing := session(map[string]string{"TestQ": "....F"})
+	if events := Events(past, &failing, list, rule); len(events) != 0 {
+		t.Errorf("Expected a quarantined test that failed to be neither flaky nor recovered, got %+v", events)
+	}
+}
+
+type recordingNotifier struct {
+	events []Event
+	err    error
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, events []Event) error {
+	n.events = append(n.events, events...)
+	return n.err
+}
+
+func TestMultiNotifiesEveryNotifier(t *testing.T) {
+	ok, broken := &recordingNotifier{}, &recordingNotifier{err: errors.New("unreachable")}
+	err := Multi{broken, ok}.Notify(context.Background(), []Event{{Kind: NewlyFlaky, Test: "TestA"}})
+	if err == nil || !strings.Contains(err.Error(), "unreachable") {
+		t.Errorf("Expected the broken notifier's error, got %v", err)
+	}
+	if len(ok.events) != 1 {
+		t.Errorf("Expected the other notifier to be notified anyway, got %+v", ok.events)
+	}
+}
@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Slack posts events as one message to a Slack incoming webhook
+type Slack struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+// Notify implements Notifier
+func (s *Slack) Notify(ctx context.Context, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(map[string]string{"text": slackText(events)})
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.Client, s.WebhookURL, body)
+}
+
+// slackText formats events one per line, grouped under a heading per owner
+// when any of them has one; a test with several owners is listed under each
+func slackText(events []Event) string {
+	byOwner := make(map[string][]string)
+	var owners []string
+	var unowned []string
+	for _, e := range events {
+		if len(e.Owners) == 0 {
+			unowned = append(unowned, slackLine(e))
+			continue
+		}
+		for _, o := range e.Owners {
+			if byOwner[o] == nil {
+				owners = append(owners, o)
+			}
+			byOwner[o] = append(byOwner[o], slackLine(e))
+		}
+	}
+	if len(owners) == 0 {
+		return strings.Join(unowned, "\n")
+	}
+	sort.Strings(owners)
+	var sections []string
+	for _, o := range owners {
+		sections = append(sections, "*"+o+"*\n"+strings.Join(byOwner[o], "\n"))
+	}
+	if len(unowned) > 0 {
+		sections = append(sections, "*Unowned*\n"+strings.Join(unowned, "\n"))
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// slackLine formats an event in Slack's mrkdwn
+func slackLine(e Event) string {
+	switch e.Kind {
+	case NewlyFlaky:
+		line := fmt.Sprintf(":warning: `%s` in `%s` became flaky: it failed %d of %d runs (%.1f%%)", e.Test, e.Package, e.Failed, e.Runs, 100*e.FlakeRate)
+		if e.Message != "" {
+			line += "\n> " + e.Message
+		}
+		return line
+	case Recovered:
+		return fmt.Sprintf(":white_check_mark: Quarantined `%s` in `%s` passed its last %d runs; consider `flakectl quarantine remove %s`", e.Test, e.Package, e.PassingRuns, e.Test)
+	}
+	return fmt.Sprintf("%s: `%s` in `%s`", e.Kind, e.Test, e.Package)
+}
+
+// Webhook posts events as JSON, {"events": [...]}, to any HTTP endpoint
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify implements Notifier
+func (w *Webhook) Notify(ctx context.Context, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(map[string][]Event{"events": events})
+	if err != nil {
+		return err
+	}
+	return post(ctx, w.Client, w.URL, body)
+}
+
+// post sends body as JSON to url, failing unless the response is 2xx
+// Errors leave the URL out, since a Slack webhook's path is its secret
+func post(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return errors.New("invalid webhook URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("POST webhook: %w", errors.Unwrap(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("POST webhook: %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSlackPostsOneMessage(t *testing.T) {
+	var payload map[string]string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer hook.Close()
+
+	s := &Slack{WebhookURL: hook.URL}
+	err := s.Notify(context.Background(), []Event{
+		{Kind: NewlyFlaky, Package: "p", Test: "TestA", Runs: 10, Failed: 3, FlakeRate: 0.3, Message: "a_test.go:4: boom"},
+		{Kind: Recovered, Package: "p", Test: "TestQ", PassingRuns: 20},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(payload["text"], "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %q", payload["text"])
+	}
+	if !strings.Contains(lines[0], "`TestA` in `p` became flaky: it failed 3 of 10 runs (30.0%)") || lines[1] != "> a_test.go:4: boom" {
+		t.Errorf("Unexpected flaky lines: %q", lines[:2])
+	}
+	if !strings.Contains(lines[2], "passed its last 20 runs") || !strings.Contains(lines[2], "flakectl quarantine remove TestQ") {
+		t.Errorf("Unexpected recovered line: %q", lines[2])
+	}
+}
+
+func TestSlackGroupsByOwner(t *testing.T) {
+	text := slackText([]Event{
+		{Kind: NewlyFlaky, Package: "p", Test: "TestA", Owners: []string{"@web", "@api"}},
+		{Kind: NewlyFlaky, Package: "p", Test: "TestB"},
+		{Kind: Recovered, Package: "p", Test: "TestQ", Owners: []string{"@api"}},
+	})
+	sections := strings.Split(text, "\n\n")
+	if len(sections) != 3 {
+		t.Fatalf("Expected a section per owner and one for unowned tests, got %q", text)
+	}
+	for i, want := range []struct{ heading, tests string }{{"*@api*", "TestA TestQ"}, {"*@web*", "TestA"}, {"*Unowned*", "TestB"}} {
+		lines := strings.Split(sections[i], "\n")
+		if lines[0] != want.heading || len(lines)-1 != len(strings.Fields(want.tests)) {
+			t.Errorf("Unexpected section %q, want %s with %s", sections[i], want.heading, want.tests)
+		}
+	}
+	if plain := slackText([]Event{{Kind: NewlyFlaky, Package: "p", Test: "TestB"}}); strings.Contains(plain, "*") {
+		t.Errorf("Expected no headings without owners, got %q", plain)
+	}
+}
+
+func TestWebhookPostsEvents(t *testing.T) {
+	var payload struct {
+		Events []Event `json:"events"`
+	}
+	var contentType string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer hook.Close()
+
+	w := &Webhook{URL: hook.URL}
+	if err := w.Notify(context.Background(), []Event{{Kind: NewlyFlaky, Package: "p", Test: "TestA", FlakeRate: 0.3}}); err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/json" || len(payload.Events) != 1 || payload.Events[0].Test != "TestA" || payload.Events[0].FlakeRate != 0.3 {
+		t.Errorf("Unexpected payload %+v (%s)", payload, contentType)
+	}
+}
+
+func TestWebhookErrorsHideTheURL(t *testing.T) {
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no_service", http.StatusNotFound)
+	}))
+	defer hook.Close()
+
+	s := &Slack{WebhookURL: hook.URL + "/services/T000/B000/secret"}
+	err := s.Notify(context.Background(), []Event{{Kind: NewlyFlaky, Test: "TestA"}})
+	if err == nil || !strings.Contains(err.Error(), "404") || strings.Contains(err.Error(), "secret") {
+		t.Errorf("Expected a 404 without the webhook URL, got %v", err)
+	}
+	if err := s.Notify(context.Background(), nil); err != nil {
+		t.Errorf("Expected no request without events, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+// Package owners maps tests to the teams that own them with a
+// CODEOWNERS-style file, so flake reports and notifications can be split
+// per team
+//
+// Each line of the file is a pattern followed by one or more owners:
+//
+//	# Last matching rule wins
+//	flakynet/                  @network
+//	**/TestShared*             @platform @network
+//	/github.com/acme/shop/pay  payments@acme.com
+//
+// A pattern matches the test's path, its package import path, a slash and
+// the test name, such as github.com/acme/shop/pay/TestRefund/partial
+// Elements match as path.Match globs, ** matches any number of elements,
+// and a pattern owns everything below what it matches, so a package
+// pattern owns its tests and a test pattern its subtests
+// A pattern starting with / matches from the start of the path; any other
+// matches at any element boundary, and one ending in / only matches
+// directories, never a test itself
+package owners
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// DefaultFile is the owners file flakectl reads unless told otherwise
+const DefaultFile = "owners.txt"
+
+// Unowned groups the tests no rule matches
+const Unowned = "(unowned)"
+
+// Rule assigns the tests its pattern matches to its owners
+type Rule struct {
+	Pattern string
+	Owners  []string
+	// Line is the rule's line in its file
+	Line int
+}
+
+// Map is an owners file
+type Map struct {
+	Path  string
+	Rules []Rule
+}
+
+// Load reads the owners file at path; a missing file maps no test to an
+// owner
+func Load(path string) (*Map, error) {
+	m := &Map{Path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: want a pattern and at least one owner", path, line)
+		}
+		if err := validate(fields[0]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		m.Rules = append(m.Rules, Rule{Pattern: fields[0], Owners: fields[1:], Line: line})
+	}
+	return m, scanner.Err()
+}
+
+// validate rejects patterns with empty or malformed elements
+func validate(pattern string) error {
+	elems := strings.Split(strings.TrimSuffix(strings.TrimPrefix(pattern, "/"), "/"), "/")
+	for _, elem := range elems {
+		if elem == "" {
+			return fmt.Errorf("pattern %q has an empty element", pattern)
+		}
+		if _, err := path.Match(elem, ""); err != nil {
+			return fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// TestPath returns the path patterns match a test at
+func TestPath(pkg, test string) string {
+	return pkg + "/" + test
+}
+
+// Owners returns the owners of the last rule matching the test, or nil
+func (m *Map) Owners(pkg, test string) []string {
+	p := TestPath(pkg, test)
+	for i := len(m.Rules) - 1; i >= 0; i-- {
+		if Match(m.Rules[i].Pattern, p) {
+			return m.Rules[i].Owners
+		}
+	}
+	return nil
+}
+
+// Teams returns the test's owners, or Unowned alone when it has none, for
+// grouping every test under at least one name
+func (m *Map) Teams(pkg, test string) []string {
+	if owners := m.Owners(pkg, test); len(owners) > 0 {
+		return owners
+	}
+	return []string{Unowned}
+}
+
+// Owns reports whether owner, compared case-insensitively, is one of the
+// test's owners; Unowned owns the tests without any
+func (m *Map) Owns(owner, pkg, test string) bool {
+	return slices.ContainsFunc(m.Teams(pkg, test), func(o string) bool { return strings.EqualFold(o, owner) })
+}
+
+// Match reports whether pattern matches test path p
+func Match(pattern, p string) bool {
+	anchored := strings.HasPrefix(pattern, "/")
+	dir := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "/"), "/")
+	pe, se := strings.Split(pattern, "/"), strings.Split(p, "/")
+	for start := range se {
+		if matchPrefix(pe, se[start:], dir) {
+			return true
+		}
+		if anchored {
+			break
+		}
+	}
+	return false
+}
+
+// matchPrefix reports whether the pattern elements match the first path
+// elements, leaving at least one of them over when dir is set
+func matchPrefix(pe, se []string, dir bool) bool {
+	if len(pe) == 0 {
+		return !dir || len(se) > 0
+	}
+	if pe[0] == "**" {
+		for i := 0; i <= len(se); i++ {
+			if matchPrefix(pe[1:], se[i:], dir) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(se) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pe[0], se[0]); !ok {
+		return false
+	}
+	return matchPrefix(pe[1:], se[1:], dir)
+}
@@ -0,0 +1,90 @@
+package owners
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	const pkg = "github.com/acme/shop/pay"
+	for _, tt := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"pay", pkg + "/TestRefund", true},
+		{"shop/pay", pkg + "/TestRefund", true},
+		{"pay/", pkg + "/TestRefund", true},
+		{"TestRefund", pkg + "/TestRefund/partial", true},
+		{"TestRefund/", pkg + "/TestRefund", false},
+		{"TestRef*", pkg + "/TestRefund", true},
+		{"**/TestRefund", pkg + "/TestRefund", true},
+		{"/github.com/acme/shop", pkg + "/TestRefund", true},
+		{"/shop/pay", pkg + "/TestRefund", false},
+		{"/github.com/**/TestRefund", pkg + "/TestRefund", true},
+		{"shop/*/TestRefund", pkg + "/TestRefund", true},
+		{"shop/*/TestCharge", pkg + "/TestRefund", false},
+		{"ay", pkg + "/TestRefund", false},
+		{"cart", pkg + "/TestRefund", false},
+	} {
+		if got := Match(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func writeOwners(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "owners.txt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	m, err := Load(writeOwners(t, `# Payments own their package, except refunds
+pay/           @payments   # the whole package
+TestRefund*    @refunds @payments
+
+**/TestShared* @platform
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Rules) != 3 || m.Rules[1].Line != 3 {
+		t.Fatalf("Unexpected rules %+v", m.Rules)
+	}
+	const pkg = "github.com/acme/shop/pay"
+	for test, want := range map[string][]string{
+		"TestCharge":         {"@payments"},
+		"TestRefund/partial": {"@refunds", "@payments"},
+		"TestSharedDB":       {"@platform"},
+	} {
+		if got := m.Owners(pkg, test); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %v, got %v", test, want, got)
+		}
+	}
+	if got := m.Owners("github.com/acme/shop/cart", "TestCheckout"); got != nil {
+		t.Errorf("Expected no owners, got %v", got)
+	}
+	if got := m.Teams("github.com/acme/shop/cart", "TestCheckout"); !reflect.DeepEqual(got, []string{Unowned}) {
+		t.Errorf("Expected the unowned group, got %v", got)
+	}
+	if !m.Owns("@Refunds", pkg, "TestRefund") || m.Owns("@refunds", pkg, "TestCharge") || !m.Owns(Unowned, "cart", "TestCheckout") {
+		t.Error("Unexpected ownership")
+	}
+}
+
+func TestLoadMissingAndInvalid(t *testing.T) {
+	m, err := Load(filepath.Join(t.TempDir(), "missing.txt"))
+	if err != nil || len(m.Rules) != 0 {
+		t.Errorf("Expected a missing file to own nothing, got %+v, %v", m, err)
+	}
+	for _, content := range []string{"pay/\n", "pay//x @a\n", "[pay @a\n"} {
+		if _, err := Load(writeOwners(t, content)); err == nil {
+			t.Errorf("Expected %q to be rejected", content)
+		}
+	}
+}
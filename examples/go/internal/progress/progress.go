@@ -0,0 +1,241 @@
+// Package progress shows a detection sweep while it runs: a view redrawn in
+// place with every test's rolling pass rate, the current run, an ETA and the
+// latest failures, or one log line per run where redrawing makes no sense,
+// such as in CI
+package progress
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/internal/watch"
+)
+
+// Config configures a View
+type Config struct {
+	// Runs is the number of runs the sweep plans, the most it can take when
+	// adaptive runs stop early
+	Runs int
+	// Seed is the seed of run 0
+	Seed int64
+	// Window is how many recent outcomes the rolling pass rates cover
+	// (default 20)
+	Window int
+	// Rows caps the tests shown, lowest rolling pass rate first (default 15)
+	Rows int
+	// Failures is how many of the latest failures are listed (default 5)
+	Failures int
+	// Live redraws the screen after every run and Refresh; otherwise every
+	// run appends a log line
+	Live bool
+	// Now returns the current time (default time.Now)
+	Now func() time.Time
+}
+
+func (c *Config) defaults() {
+	if c.Window == 0 {
+		c.Window = 20
+	}
+	if c.Rows == 0 {
+		c.Rows = 15
+	}
+	if c.Failures == 0 {
+		c.Failures = 5
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+}
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\x1b[H\x1b[2J"
+
+// maxMessage is the most runes of a failure message shown
+const maxMessage = 100
+
+// failure is one failing result the view lists
+type failure struct {
+	run     int
+	test    string
+	message string
+}
+
+// View follows the runs of a sweep and writes their progress
+// It is safe for concurrent use, so Refresh can run on a ticker
+type View struct {
+	cfg Config
+	w   io.Writer
+
+	mu        sync.Mutex
+	window    *watch.Window
+	start     time.Time
+	last      time.Time
+	observed  int
+	completed int
+	failures  []failure
+}
+
+// New returns a View writing to w, timing the sweep from now
+func New(w io.Writer, cfg Config) *View {
+	cfg.defaults()
+	now := cfg.Now()
+	return &View{cfg: cfg, w: w, window: watch.NewWindow(cfg.Window), start: now, last: now}
+}
+
+// Observe records a finished run and writes the progress; it is a
+// runner.Config.Observe function
+func (v *View) Observe(run int, results []runner.Result) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := v.cfg.Now()
+	took := now.Sub(v.last)
+	v.last = now
+	v.observed++
+	v.completed = run + 1
+	v.window.Add(results)
+
+	var failed []string
+	for _, r := range failures(results) {
+		failed = append(failed, r.Test)
+		msg := "(no message)"
+		if msgs := runner.FailureMessages(r.Output); len(msgs) > 0 {
+			msg = msgs[0]
+		}
+		v.failures = append(v.failures, failure{run: run, test: r.Test, message: msg})
+	}
+	if extra := len(v.failures) - v.cfg.Failures; extra > 0 {
+		v.failures = v.failures[extra:]
+	}
+
+	if v.cfg.Live {
+		v.render(now)
+		return
+	}
+	v.logRun(run, len(results), failed, took, now)
+}
+
+// failures returns the failing results, leaving out parents whose subtests
+// failed, whose own output holds no message
+func failures(results []runner.Result) []runner.Result {
+	var failed []runner.Result
+	for _, r := range results {
+		if r.Outcome != runner.Fail {
+			continue
+		}
+		throughSubtest := slices.ContainsFunc(results, func(sub runner.Result) bool {
+			return sub.Outcome == runner.Fail && sub.Package == r.Package && strings.HasPrefix(sub.Test, r.Test+"/")
+		})
+		if !throughSubtest {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// Refresh redraws the live view, so its elapsed time and ETA keep moving
+// during a long run; it writes nothing in log mode
+func (v *View) Refresh() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.cfg.Live {
+		v.render(v.cfg.Now())
+	}
+}
+
+// ETA estimates the time left from the mean time of the runs observed so
+// far, zero before the first run and once the planned runs are done
+func (v *View) ETA() time.Duration {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.eta()
+}
+
+func (v *View) eta() time.Duration {
+	left := v.cfg.Runs - v.completed
+	if v.observed == 0 || left <= 0 {
+		return 0
+	}
+	perRun := v.last.Sub(v.start) / time.Duration(v.observed)
+	return perRun * time.Duration(left)
+}
+
+// logRun writes the line of one run
+func (v *View) logRun(run, tests int, failed []string, took time.Duration, now time.Time) {
+	status := fmt.Sprintf("%d test(s) passed", tests)
+	if len(failed) > 0 {
+		names := failed
+		if len(names) > 3 {
+			names = append(names[:3:3], fmt.Sprintf("+%d more", len(failed)-3))
+		}
+		status = fmt.Sprintf("%d of %d test(s) failed (%s)", len(failed), tests, strings.Join(names, ", "))
+	}
+	fmt.Fprintf(v.w, "run %d/%d (seed %d): %s in %v, ETA %v\n",
+		run+1, v.cfg.Runs, v.cfg.Seed+int64(run), status, took.Round(time.Millisecond), v.eta().Round(time.Second))
+}
+
+// render redraws the whole view
+func (v *View) render(now time.Time) {
+	io.WriteString(v.w, clearScreen)
+	elapsed := now.Sub(v.start).Round(time.Second)
+	if v.completed >= v.cfg.Runs {
+		fmt.Fprintf(v.w, "%d of %d run(s) done in %v\n\n", v.completed, v.cfg.Runs, elapsed)
+	} else {
+		fmt.Fprintf(v.w, "Run %d of %d running (seed %d), %d done in %v, ETA %v\n\n",
+			v.completed+1, v.cfg.Runs, v.cfg.Seed+int64(v.completed), v.completed, elapsed, v.eta().Round(time.Second))
+	}
+
+	tests := v.window.Tests()
+	sort.SliceStable(tests, func(i, j int) bool { return tests[i].FlakeRate() > tests[j].FlakeRate() })
+	shown := tests
+	if len(shown) > v.cfg.Rows {
+		shown = shown[:v.cfg.Rows]
+	}
+	tw := tabwriter.NewWriter(v.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "TEST\tPASS RATE (LAST %d)\tRECENT\tRUNS\n", v.cfg.Window)
+	for _, t := range shown {
+		fmt.Fprintf(tw, "%s\t%.1f%%\t%s\t%d\n", t.Test, (1-t.FlakeRate())*100, strip(t.Outcomes), t.Total)
+	}
+	tw.Flush()
+	if hidden := len(tests) - len(shown); hidden > 0 {
+		fmt.Fprintf(v.w, "... and %d more test(s)\n", hidden)
+	}
+
+	if len(v.failures) == 0 {
+		return
+	}
+	fmt.Fprintln(v.w, "\nRecent failures:")
+	for i := len(v.failures) - 1; i >= 0; i-- {
+		f := v.failures[i]
+		fmt.Fprintf(v.w, "  run %d (seed %d) %s: %s\n", f.run+1, v.cfg.Seed+int64(f.run), f.test, truncate(f.message, maxMessage))
+	}
+}
+
+// strip draws outcomes oldest first, a check for a pass and a cross for a
+// failure
+func strip(outcomes []runner.Outcome) string {
+	var sb strings.Builder
+	for _, o := range outcomes {
+		if o == runner.Fail {
+			sb.WriteRune('✗')
+		} else {
+			sb.WriteRune('✓')
+		}
+	}
+	return sb.String()
+}
+
+// truncate shortens s to n runes, ending it with an ellipsis when cut
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
@@ -0,0 +1,103 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// fakeNow returns a clock for Config.Now and a function advancing it
+func fakeNow() (func() time.Time, func(time.Duration)) {
+	now := time.Unix(0, 0)
+	return func() time.Time { return now }, func(d time.Duration) { now = now.Add(d) }
+}
+
+func result(test string, outcome runner.Outcome, output string) runner.Result {
+	return runner.Result{Package: "p", Test: test, Outcome: outcome, Output: output}
+}
+
+func TestLogMode(t *testing.T) {
+	now, advance := fakeNow()
+	var out bytes.Buffer
+	v := New(&out, Config{Runs: 4, Seed: 10, Now: now})
+
+	advance(2 * time.Second)
+	v.Observe(0, []runner.Result{result("TestA", runner.Pass, ""), result("TestB", runner.Pass, "")})
+	advance(4 * time.Second)
+	v.Observe(1, []runner.Result{
+		result("TestA", runner.Fail, "    a_test.go:3: boom\n"),
+		result("TestT", runner.Fail, ""),
+		result("TestT/case", runner.Fail, "    t_test.go:9: stale\n"),
+	})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a line per run, got %q", out.String())
+	}
+	if want := "run 1/4 (seed 10): 2 test(s) passed in 2s, ETA 6s"; lines[0] != want {
+		t.Errorf("Expected %q, got %q", want, lines[0])
+	}
+	if want := "run 2/4 (seed 11): 2 of 3 test(s) failed (TestA, TestT/case) in 4s, ETA 6s"; lines[1] != want {
+		t.Errorf("Expected the parent failing through its subtest left out, %q, got %q", want, lines[1])
+	}
+	if got := v.ETA(); got != 6*time.Second {
+		t.Errorf("Expected 2 runs left at 3s each, got %v", got)
+	}
+	v.Refresh()
+	if strings.Count(out.String(), "\n") != 2 {
+		t.Error("Expected Refresh to write nothing in log mode")
+	}
+}
+
+func TestLiveMode(t *testing.T) {
+	now, advance := fakeNow()
+	var out bytes.Buffer
+	v := New(&out, Config{Runs: 3, Seed: 1, Rows: 2, Failures: 2, Live: true, Now: now})
+
+	advance(time.Second)
+	long := strings.Repeat("x", 150)
+	v.Observe(0, []runner.Result{
+		result("TestA", runner.Pass, ""),
+		result("TestB", runner.Fail, "    b_test.go:1: first\n"),
+		result("TestC", runner.Pass, ""),
+	})
+	advance(time.Second)
+	v.Observe(1, []runner.Result{
+		result("TestA", runner.Fail, "    a_test.go:2: "+long+"\n"),
+		result("TestB", runner.Fail, "    b_test.go:1: second\n"),
+		result("TestC", runner.Pass, ""),
+	})
+
+	screen := out.String()[strings.LastIndex(out.String(), clearScreen)+len(clearScreen):]
+	for _, want := range []string{
+		"Run 3 of 3 running (seed 3), 2 done in 2s, ETA 1s",
+		"TestB  0.0%",
+		"TestA  50.0%",
+		"... and 1 more test(s)",
+		"run 2 (seed 2) TestB: b_test.go:1: second",
+		"…",
+	} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("Expected the view to contain %q, got\n%s", want, screen)
+		}
+	}
+	if strings.Contains(screen, "first") {
+		t.Errorf("Expected only the 2 latest failures, got\n%s", screen)
+	}
+	if strings.Index(screen, "b_test.go:1: second") > strings.Index(screen, "a_test.go:2") {
+		t.Errorf("Expected the latest failure first, got\n%s", screen)
+	}
+
+	advance(5 * time.Second)
+	v.Refresh()
+	if !strings.Contains(out.String(), "2 done in 7s") {
+		t.Errorf("Expected Refresh to redraw the elapsed time, got\n%s", out.String())
+	}
+	v.Observe(2, []runner.Result{result("TestA", runner.Pass, "")})
+	if !strings.Contains(out.String(), "3 of 3 run(s) done in 7s") {
+		t.Errorf("Expected the finished sweep's header, got\n%s", out.String())
+	}
+}
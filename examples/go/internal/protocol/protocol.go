@@ -0,0 +1,70 @@
+// Package protocol holds what the flaky helpers and the tools that run them,
+// such as the runner and flakectl, agree on across the go test process: the
+// environment variables one sets and the other reads, and the formats of
+// the lines the helpers log into test output
+// It imports nothing of the module, so the tools can speak it without
+// depending on the helpers
+package protocol
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// SeedEnv carries each run's suite seed to the tests
+const SeedEnv = "GO_TEST_SEED"
+
+// The variables that configure the scenarios and helpers of a run
+const (
+	// SourceEnv selects the RNG algorithm the helpers draw from
+	SourceEnv = "FLAKY_RAND_SOURCE"
+	// ConfigEnv points to a scenario config file overriding the defaults
+	ConfigEnv = "FLAKY_CONFIG"
+	// ProfileEnv names the preset applied to the scenarios
+	ProfileEnv = "FLAKY_PROFILE"
+	// ScenariosEnv selects the scenarios that run
+	ScenariosEnv = "FLAKY_SCENARIOS"
+	// PollutionEnv, when set to 1, checks every test for global pollution
+	PollutionEnv = "FLAKY_POLLUTION"
+	// SetEnv overrides scenario fields
+	SetEnv = "FLAKY_SET"
+	// FailuresFileEnv overrides where failing seeds are recorded
+	FailuresFileEnv = "FLAKY_FAILURES_FILE"
+)
+
+// MetaLogPrefix marks the structured line flaky.Report logs when a test fails
+const MetaLogPrefix = "flaky-meta: "
+
+// GoroutineDumpHeader precedes the goroutine dump in flaky.WithTimeout's
+// failure, which flakectl detect looks for to save the dump
+const GoroutineDumpHeader = "goroutine dump:"
+
+// FailureMeta describes the injected conditions behind a failing test, so a
+// failure can be traced to them and not just to its error string
+type FailureMeta struct {
+	Test string `json:"test"`
+	// Seed is the suite seed and TestSeed the seed the test derived from it
+	Seed     int64 `json:"seed"`
+	TestSeed int64 `json:"test_seed"`
+	// Scenario names the scenario, such as "DiskFull"
+	Scenario string `json:"scenario"`
+	// Params holds the scenario's settings and the values drawn in the run
+	Params map[string]any `json:"params,omitempty"`
+	// Time is when the test finished failing
+	Time time.Time `json:"time"`
+}
+
+// ParseMetaLine returns the FailureMeta of a line of test output flaky.Report
+// logged, such as "    meta.go:58: flaky-meta: {...}"
+func ParseMetaLine(line string) (FailureMeta, bool) {
+	_, data, ok := strings.Cut(line, MetaLogPrefix)
+	if !ok {
+		return FailureMeta{}, false
+	}
+	var meta FailureMeta
+	if err := json.Unmarshal([]byte(data), &meta); err != nil {
+		return FailureMeta{}, false
+	}
+	return meta, true
+}
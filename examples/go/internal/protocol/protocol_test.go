@@ -0,0 +1,18 @@
+package protocol
+
+import "testing"
+
+func TestParseMetaLine(t *testing.T) {
+	meta, ok := ParseMetaLine(`    meta.go:58: ` + MetaLogPrefix + `{"test":"TestDiskFull","seed":7,"scenario":"DiskFull","params":{"failure_rate":0.2}}`)
+	if !ok || meta.Test != "TestDiskFull" || meta.Seed != 7 || meta.Params["failure_rate"] != 0.2 {
+		t.Errorf("Expected the logged meta, got %+v, %v", meta, ok)
+	}
+}
+
+func TestParseMetaLineIgnoresOtherOutput(t *testing.T) {
+	for _, line := range []string{"    a_test.go:10: boom", "    meta.go:58: " + MetaLogPrefix + "{not json"} {
+		if _, ok := ParseMetaLine(line); ok {
+			t.Errorf("Expected %q not to parse", line)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package report
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// Allure reads a results directory holding one <uuid>-result.json per test
+// execution; executions sharing a historyId are retries of one test, the
+// latest shown as the result and the others listed under it
+
+type allureResult struct {
+	UUID          string              `json:"uuid"`
+	HistoryID     string              `json:"historyId"`
+	TestCaseID    string              `json:"testCaseId"`
+	FullName      string              `json:"fullName"`
+	Name          string              `json:"name"`
+	Status        string              `json:"status"`
+	StatusDetails allureStatusDetails `json:"statusDetails"`
+	Stage         string              `json:"stage"`
+	Start         int64               `json:"start"`
+	Stop          int64               `json:"stop"`
+	Labels        []allureLabel       `json:"labels"`
+	Parameters    []allureParameter   `json:"parameters"`
+	Attachments   []allureAttachment  `json:"attachments"`
+}
+
+type allureStatusDetails struct {
+	Flaky   bool   `json:"flaky,omitempty"`
+	Message string `json:"message,omitempty"`
+	Trace   string `json:"trace,omitempty"`
+}
+
+type allureLabel struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type allureParameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	// Excluded keeps the seed out of the historyId Allure would compute,
+	// so every run stays a retry of the same test
+	Excluded bool `json:"excluded,omitempty"`
+}
+
+type allureAttachment struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Type   string `json:"type"`
+}
+
+type allureCategory struct {
+	Name            string   `json:"name"`
+	MatchedStatuses []string `json:"matchedStatuses"`
+	Flaky           bool     `json:"flaky,omitempty"`
+}
+
+// allureCategories sort failures in the Categories tab; Allure files each
+// failure under the first category that matches
+var allureCategories = []allureCategory{
+	{Name: "Flaky tests", MatchedStatuses: []string{"failed", "broken"}, Flaky: true},
+	{Name: "Assertion failures", MatchedStatuses: []string{"failed"}},
+	{Name: "Races, panics, timeouts and crashes", MatchedStatuses: []string{"broken"}},
+}
+
+// WriteAllure writes the report's runs into the Allure results directory
+// dir, creating it if needed: one result per run with the seed as an
+// excluded parameter and attachments of the seed and of a failing run's
+// output
+// Runs of a test share its historyId, so Allure shows them as retries, and
+// the runs of a flaky test are marked flaky
+// Failures are classified with c (DefaultClassifier when nil): assertion
+// failures are "failed", other categories "broken", and every category is a
+// tag
+func WriteAllure(dir string, r *runner.Report, c *Classifier) error {
+	c = c.orDefault()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	flaky := make(map[[2]string]bool)
+	for _, s := range r.Tests {
+		flaky[[2]string{s.Package, s.Test}] = s.Classify() == runner.Flaky
+	}
+
+	for _, result := range r.Results {
+		id := newUUID()
+		sum := md5.Sum([]byte(result.Package + "." + result.Test))
+		history := hex.EncodeToString(sum[:])
+		start := result.Start
+		if start.IsZero() {
+			start = time.Now()
+		}
+		res := allureResult{
+			UUID:       id,
+			HistoryID:  history,
+			TestCaseID: history,
+			FullName:   result.Package + "." + result.Test,
+			Name:       result.Test,
+			Status:     "passed",
+			Stage:      "finished",
+			Start:      start.UnixMilli(),
+			Stop:       start.Add(result.Duration).UnixMilli(),
+			StatusDetails: allureStatusDetails{
+				Flaky: flaky[[2]string{result.Package, result.Test}],
+			},
+			Labels: []allureLabel{
+				{Name: "package", Value: result.Package},
+				{Name: "suite", Value: result.Package},
+				{Name: "framework", Value: "go test"},
+				{Name: "language", Value: "go"},
+			},
+			Parameters: []allureParameter{
+				{Name: runner.SeedEnv, Value: strconv.FormatInt(result.Seed, 10), Excluded: true},
+				{Name: "run", Value: strconv.Itoa(result.Run), Excluded: true},
+			},
+		}
+
+		seed := fmt.Sprintf("%s=%d\nrun %d\n", runner.SeedEnv, result.Seed, result.Run)
+		attachment, err := writeAttachment(dir, seed)
+		if err != nil {
+			return err
+		}
+		res.Attachments = []allureAttachment{{Name: "seed", Source: attachment, Type: "text/plain"}}
+
+		switch result.Outcome {
+		case runner.Skip:
+			res.Status = "skipped"
+		case runner.Fail:
+			category := c.Classify(result)
+			res.Status = "broken"
+			if category == CategoryAssertion {
+				res.Status = "failed"
+			}
+			res.Labels = append(res.Labels, allureLabel{Name: "tag", Value: category})
+			res.StatusDetails.Message = fmt.Sprintf("failed in run %d", result.Run)
+			if msgs := runner.FailureMessages(result.Output); len(msgs) > 0 {
+				res.StatusDetails.Message = msgs[0]
+			}
+			res.StatusDetails.Trace = result.Output
+			output, err := writeAttachment(dir, result.Output)
+			if err != nil {
+				return err
+			}
+			res.Attachments = append(res.Attachments, allureAttachment{Name: "output", Source: output, Type: "text/plain"})
+		}
+
+		if err := writeJSONFile(filepath.Join(dir, id+"-result.json"), res); err != nil {
+			return err
+		}
+	}
+	return writeJSONFile(filepath.Join(dir, "categories.json"), allureCategories)
+}
+
+// writeAttachment stores content as a new attachment in dir and returns its
+// file name
+func writeAttachment(dir, content string) (string, error) {
+	name := newUUID() + "-attachment.txt"
+	return name, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+}
+
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// newUUID returns a random version 4 UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,93 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestWriteAllure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "allure-results")
+	if err := WriteAllure(dir, sampleReport(), nil); err != nil {
+		t.Fatalf("WriteAllure failed: %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*-result.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 7 {
+		t.Fatalf("Expected one result per run, got %d", len(files))
+	}
+
+	byTest := make(map[string][]allureResult)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res allureResult
+		if err := json.Unmarshal(data, &res); err != nil {
+			t.Fatalf("%s is not valid JSON: %v", file, err)
+		}
+		if filepath.Base(file) != res.UUID+"-result.json" {
+			t.Errorf("Expected %s to be named after its UUID %s", file, res.UUID)
+		}
+		byTest[res.Name] = append(byTest[res.Name], res)
+	}
+
+	flaky := byTest["TestFlaky"]
+	if len(flaky) != 3 || flaky[0].HistoryID != flaky[1].HistoryID || flaky[0].HistoryID == byTest["TestBroken"][0].HistoryID {
+		t.Fatalf("Expected the runs of each test to share a historyId, got %+v", flaky)
+	}
+	var failed *allureResult
+	for i := range flaky {
+		if !flaky[i].StatusDetails.Flaky {
+			t.Errorf("Expected every run of a flaky test to be marked flaky, got %+v", flaky[i])
+		}
+		if flaky[i].Status == "failed" {
+			failed = &flaky[i]
+		}
+	}
+	if failed == nil || failed.StatusDetails.Message != "f_test.go:3: got 0.812" || len(failed.Attachments) != 2 {
+		t.Fatalf("Expected a failed run with its message and two attachments, got %+v", flaky)
+	}
+	seed, err := os.ReadFile(filepath.Join(dir, failed.Attachments[0].Source))
+	if err != nil || !strings.Contains(string(seed), "GO_TEST_SEED=2") {
+		t.Errorf("Expected the seed attachment to hold GO_TEST_SEED=2, got %q (%v)", seed, err)
+	}
+	if p := failed.Parameters[0]; p.Name != "GO_TEST_SEED" || p.Value != "2" || !p.Excluded {
+		t.Errorf("Expected an excluded seed parameter, got %+v", p)
+	}
+
+	for _, res := range byTest["TestBroken"] {
+		if res.StatusDetails.Flaky || res.Status != "failed" {
+			t.Errorf("Expected consistent assertion failures, not flaky, got %+v", res)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "categories.json")); err != nil {
+		t.Errorf("Expected categories.json: %v", err)
+	}
+}
+
+func TestWriteAllureMarksNonAssertionFailuresBroken(t *testing.T) {
+	r := sampleReport()
+	r.Results[1].Kind = runner.Panic
+	r.Results[1].Output = "panic: runtime error: index out of range [recovered]\n"
+	dir := t.TempDir()
+	if err := WriteAllure(dir, r, nil); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*-result.json"))
+	for _, file := range files {
+		data, _ := os.ReadFile(file)
+		var res allureResult
+		json.Unmarshal(data, &res)
+		if res.Name == "TestFlaky" && res.Status != "passed" && res.Status != "broken" {
+			t.Errorf("Expected the panicking run to be broken, got %s", res.Status)
+		}
+	}
+}
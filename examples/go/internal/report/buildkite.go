@@ -0,0 +1,117 @@
+package report
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// Buildkite Test Analytics and CircleCI both find flaky tests themselves
+// from repeated executions, so these formats carry one entry per run rather
+// than the per-test aggregate of the JUnit and JSON reports
+
+type buildkiteTest struct {
+	ID              string              `json:"id"`
+	Scope           string              `json:"scope"`
+	Name            string              `json:"name"`
+	Location        string              `json:"location,omitempty"`
+	FileName        string              `json:"file_name,omitempty"`
+	Result          string              `json:"result"`
+	FailureReason   string              `json:"failure_reason,omitempty"`
+	FailureExpanded []buildkiteExpanded `json:"failure_expanded,omitempty"`
+	History         buildkiteSpan       `json:"history"`
+}
+
+type buildkiteExpanded struct {
+	Expanded  []string `json:"expanded"`
+	Backtrace []string `json:"backtrace"`
+}
+
+type buildkiteSpan struct {
+	Section  string          `json:"section"`
+	StartAt  float64         `json:"start_at"`
+	EndAt    float64         `json:"end_at"`
+	Duration float64         `json:"duration"`
+	Children []buildkiteSpan `json:"children"`
+}
+
+var buildkiteResults = map[runner.Outcome]string{
+	runner.Pass: "passed",
+	runner.Fail: "failed",
+	runner.Skip: "skipped",
+}
+
+// WriteBuildkite writes the report's runs in the Buildkite Test Analytics
+// JSON upload format, one execution per run of each test scoped to its
+// package
+// A failed execution's reason is its first failure message, prefixed with
+// its category from c (DefaultClassifier when nil), and the expanded
+// failure holds the seed and output; locate, when set, adds the test's
+// location
+func WriteBuildkite(w io.Writer, r *runner.Report, c *Classifier, locate Locator) error {
+	c = c.orDefault()
+	tests := []buildkiteTest{}
+	for _, result := range r.Results {
+		secs := result.Duration.Seconds()
+		t := buildkiteTest{
+			ID:      executionID(result),
+			Scope:   result.Package,
+			Name:    result.Test,
+			Result:  buildkiteResults[result.Outcome],
+			History: buildkiteSpan{Section: "top", EndAt: secs, Duration: secs, Children: []buildkiteSpan{}},
+		}
+		if loc, ok := lookup(locate, result.Package, result.Test); ok {
+			t.FileName = loc.File
+			t.Location = fmt.Sprintf("%s:%d", loc.File, loc.Line)
+		}
+		if result.Outcome == runner.Fail {
+			message := fmt.Sprintf("failed in run %d", result.Run)
+			if msgs := runner.FailureMessages(result.Output); len(msgs) > 0 {
+				message = msgs[0]
+			}
+			t.FailureReason = c.Classify(result) + ": " + message
+			t.FailureExpanded = []buildkiteExpanded{{
+				Expanded:  outputLines(result.Output),
+				Backtrace: []string{fmt.Sprintf("run %d, %s=%d", result.Run, runner.SeedEnv, result.Seed)},
+			}}
+		}
+		tests = append(tests, t)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(tests)
+}
+
+// executionID derives a UUID from the package, test and run, so writing the
+// same report twice uploads the same executions
+func executionID(r runner.Result) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s\x00%s\x00%d\x00%d", r.Package, r.Test, r.Run, r.Seed)))
+	// Version 5 and the RFC 4122 variant, as for a name-based UUID
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// outputLines returns the non-blank lines of a test's output, trimmed
+func outputLines(output string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// lookup calls locate, treating a nil locate as knowing no test
+func lookup(locate Locator, pkg, test string) (Location, bool) {
+	if locate == nil {
+		return Location{}, false
+	}
+	return locate(pkg, test)
+}
@@ -0,0 +1,64 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"testing"
+)
+
+func TestWriteBuildkite(t *testing.T) {
+	locate := func(pkg, test string) (Location, bool) {
+		return Location{File: pkg + "/f_test.go", Line: 9}, test == "TestFlaky"
+	}
+	var buf bytes.Buffer
+	if err := WriteBuildkite(&buf, sampleReport(), nil, locate); err != nil {
+		t.Fatalf("WriteBuildkite failed: %v", err)
+	}
+	var tests []buildkiteTest
+	if err := json.Unmarshal(buf.Bytes(), &tests); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if len(tests) != 7 {
+		t.Fatalf("Expected one execution per run, got %d", len(tests))
+	}
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	ids := make(map[string]bool)
+	for _, test := range tests {
+		if !uuid.MatchString(test.ID) || ids[test.ID] {
+			t.Errorf("Expected a distinct UUID per execution, got %q", test.ID)
+		}
+		ids[test.ID] = true
+	}
+
+	failed := tests[1]
+	if failed.Scope != "p" || failed.Name != "TestFlaky" || failed.Result != "failed" || failed.Location != "p/f_test.go:9" {
+		t.Errorf("Unexpected failed execution: %+v", failed)
+	}
+	if failed.FailureReason != "assertion: f_test.go:3: got 0.812" {
+		t.Errorf("Unexpected failure reason %q", failed.FailureReason)
+	}
+	if len(failed.FailureExpanded) != 1 || failed.FailureExpanded[0].Backtrace[0] != "run 1, GO_TEST_SEED=2" {
+		t.Errorf("Expected the seed in the expanded failure, got %+v", failed.FailureExpanded)
+	}
+	if failed.History.Duration != 0.02 {
+		t.Errorf("Expected a duration of 0.02s, got %v", failed.History.Duration)
+	}
+	if passed := tests[0]; passed.Result != "passed" || passed.FailureReason != "" {
+		t.Errorf("Unexpected passed execution: %+v", passed)
+	}
+	if broken := tests[3]; broken.Location != "" {
+		t.Errorf("Expected no location for a test the locator does not know, got %q", broken.Location)
+	}
+
+	var again bytes.Buffer
+	if err := WriteBuildkite(&again, sampleReport(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	var unlocated []buildkiteTest
+	json.Unmarshal(again.Bytes(), &unlocated)
+	if unlocated[1].ID != failed.ID {
+		t.Errorf("Expected stable execution IDs, got %s and %s", unlocated[1].ID, failed.ID)
+	}
+}
@@ -0,0 +1,106 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// CircleCI reads plain JUnit XML from store_test_results and flags a test
+// as flaky when it both passes and fails on the same commit, so every run
+// is written as its own test case, with the file attribute CircleCI uses to
+// link and split tests
+
+type circleSuites struct {
+	XMLName xml.Name      `xml:"testsuites"`
+	Suites  []circleSuite `xml:"testsuite"`
+}
+
+type circleSuite struct {
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Cases    []circleCase `xml:"testcase"`
+}
+
+type circleCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+// WriteCircleCI writes the report's runs as the JUnit XML CircleCI test
+// insights ingest: one test suite per package, one test case per run
+// Failures are classified with c (DefaultClassifier when nil), the case's
+// file comes from locate when set
+func WriteCircleCI(w io.Writer, r *runner.Report, c *Classifier, locate Locator) error {
+	c = c.orDefault()
+	suites := make(map[string]*circleSuite)
+	totals := make(map[string]float64)
+	for _, result := range r.Results {
+		suite := suites[result.Package]
+		if suite == nil {
+			suite = &circleSuite{Name: result.Package}
+			suites[result.Package] = suite
+		}
+		tc := circleCase{
+			Name:      result.Test,
+			Classname: result.Package,
+			Time:      formatSeconds(result.Duration.Seconds()),
+		}
+		if loc, ok := lookup(locate, result.Package, result.Test); ok {
+			tc.File = loc.File
+		}
+		switch result.Outcome {
+		case runner.Fail:
+			message := fmt.Sprintf("failed in run %d", result.Run)
+			if msgs := runner.FailureMessages(result.Output); len(msgs) > 0 {
+				message = msgs[0]
+			}
+			tc.Failure = &junitFailure{
+				Message: message,
+				Type:    c.Classify(result),
+				Body:    fmt.Sprintf("run %d, %s=%d\n%s", result.Run, runner.SeedEnv, result.Seed, strings.TrimRight(result.Output, "\n")),
+			}
+			suite.Failures++
+		case runner.Skip:
+			tc.Skipped = &struct{}{}
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, tc)
+		suite.Tests++
+		totals[result.Package] += result.Duration.Seconds()
+	}
+
+	var root circleSuites
+	names := make([]string, 0, len(suites))
+	for name := range suites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		suite := suites[name]
+		suite.Time = formatSeconds(totals[name])
+		root.Suites = append(root.Suites, *suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,41 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestWriteCircleCI(t *testing.T) {
+	locate := func(pkg, test string) (Location, bool) {
+		return Location{File: pkg + "/f_test.go", Line: 9}, true
+	}
+	var buf bytes.Buffer
+	if err := WriteCircleCI(&buf, sampleReport(), nil, locate); err != nil {
+		t.Fatalf("WriteCircleCI failed: %v", err)
+	}
+	var doc circleSuites
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Output is not valid XML: %v\n%s", err, buf.String())
+	}
+	if len(doc.Suites) != 2 || doc.Suites[0].Name != "p" || doc.Suites[0].Tests != 6 || doc.Suites[0].Failures != 4 {
+		t.Fatalf("Expected suite p with 6 runs and 4 failures, got %+v", doc.Suites)
+	}
+
+	var flaky []circleCase
+	for _, tc := range doc.Suites[0].Cases {
+		if tc.Name == "TestFlaky" {
+			flaky = append(flaky, tc)
+		}
+	}
+	if len(flaky) != 3 || flaky[0].Failure != nil || flaky[1].Failure == nil || flaky[0].File != "p/f_test.go" {
+		t.Fatalf("Expected one test case per run of TestFlaky, got %+v", flaky)
+	}
+	if f := flaky[1].Failure; f.Type != CategoryAssertion || f.Message != "f_test.go:3: got 0.812" || !strings.Contains(f.Body, "GO_TEST_SEED=2") {
+		t.Errorf("Unexpected failure: %+v", f)
+	}
+	if flaky[1].Time != "0.020" {
+		t.Errorf("Expected the run's own duration, got %s", flaky[1].Time)
+	}
+}
@@ -0,0 +1,170 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// Failure categories assigned by the default rules; a failure no rule
+// matches keeps its runner.FailureKind as its category
+const (
+	CategoryAssertion = string(runner.Assertion)
+	CategoryRace      = string(runner.Race)
+	CategoryPanic     = string(runner.Panic)
+	CategoryTimeout   = string(runner.Timeout)
+	CategoryCrash     = string(runner.Crash)
+	CategoryOOM       = "oom"
+	CategoryNetwork   = "network"
+)
+
+// Rule assigns Category to failing runs that match it
+type Rule struct {
+	Category string `yaml:"category"`
+	// Pattern is a regular expression matched against the run's output
+	Pattern string `yaml:"pattern,omitempty"`
+	// Kind, when set, restricts the rule to failures of that kind
+	Kind runner.FailureKind `yaml:"kind,omitempty"`
+}
+
+// DefaultRules finds out-of-memory failures of any kind, then network errors
+// and timeouts among the assertions
+var DefaultRules = []Rule{
+	{Category: CategoryOOM, Pattern: `runtime: out of memory|cannot allocate memory`},
+	{Category: CategoryNetwork, Kind: runner.Assertion,
+		Pattern: `(?i)connection (refused|reset)|no such host|network is unreachable|broken pipe|i/o timeout|\bunavailable\b|\bnetwork\b`},
+	{Category: CategoryTimeout, Kind: runner.Assertion, Pattern: `(?i)deadline[ _]exceeded|timed out|\btimeout\b`},
+}
+
+// Classifier buckets failing runs by the first rule they match
+type Classifier struct {
+	rules    []Rule
+	patterns []*regexp.Regexp
+}
+
+// DefaultClassifier applies DefaultRules
+var DefaultClassifier = MustClassifier(DefaultRules)
+
+// NewClassifier compiles rules, which are tried in order
+func NewClassifier(rules []Rule) (*Classifier, error) {
+	c := &Classifier{rules: rules, patterns: make([]*regexp.Regexp, len(rules))}
+	for i, rule := range rules {
+		if rule.Category == "" {
+			return nil, fmt.Errorf("rule %d: no category", i+1)
+		}
+		if rule.Pattern == "" && rule.Kind == "" {
+			return nil, fmt.Errorf("rule %d (%s): needs a pattern or a kind", i+1, rule.Category)
+		}
+		if rule.Pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i+1, rule.Category, err)
+		}
+		c.patterns[i] = re
+	}
+	return c, nil
+}
+
+// MustClassifier is NewClassifier for rules known to be valid
+func MustClassifier(rules []Rule) *Classifier {
+	c, err := NewClassifier(rules)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// LoadClassifier reads rules from a YAML file of the form
+//
+//	rules:
+//	  - category: database
+//	    pattern: 'pq: too many connections'
+//
+// and tries them before DefaultRules
+func LoadClassifier(path string) (*Classifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Rules []Rule `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	c, err := NewClassifier(append(file.Rules, DefaultRules...))
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Classify returns the category of a failing run, or "" for other outcomes
+func (c *Classifier) Classify(r runner.Result) string {
+	if r.Outcome != runner.Fail {
+		return ""
+	}
+	kind := r.Kind
+	if kind == "" {
+		kind = runner.Assertion
+	}
+	for i, rule := range c.rules {
+		if rule.Kind != "" && rule.Kind != kind {
+			continue
+		}
+		if re := c.patterns[i]; re != nil && !re.MatchString(r.Output) {
+			continue
+		}
+		return rule.Category
+	}
+	return string(kind)
+}
+
+// orDefault returns c, or DefaultClassifier when c is nil
+func (c *Classifier) orDefault() *Classifier {
+	if c == nil {
+		return DefaultClassifier
+	}
+	return c
+}
+
+// Categories counts the failing runs of every test by category
+func (c *Classifier) Categories(r *runner.Report) map[[2]string]map[string]int {
+	c = c.orDefault()
+	counts := make(map[[2]string]map[string]int)
+	for _, result := range r.Results {
+		category := c.Classify(result)
+		if category == "" {
+			continue
+		}
+		key := [2]string{result.Package, result.Test}
+		if counts[key] == nil {
+			counts[key] = make(map[string]int)
+		}
+		counts[key][category]++
+	}
+	return counts
+}
+
+// SortedCategories returns the categories of counts, most failures first
+func SortedCategories(counts map[string]int) []string {
+	categories := make([]string, 0, len(counts))
+	for category := range counts {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		a, b := categories[i], categories[j]
+		if counts[a] != counts[b] {
+			return counts[a] > counts[b]
+		}
+		return a < b
+	})
+	return categories
+}
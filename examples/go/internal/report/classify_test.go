@@ -0,0 +1,90 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestDefaultClassifier(t *testing.T) {
+	cases := []struct {
+		result runner.Result
+		want   string
+	}{
+		{runner.Result{Outcome: runner.Pass}, ""},
+		{runner.Result{Outcome: runner.Fail, Kind: runner.Assertion, Output: "    a_test.go:1: got 3, want 4\n"}, CategoryAssertion},
+		{runner.Result{Outcome: runner.Fail, Output: "    a_test.go:1: want 4\n"}, CategoryAssertion},
+		{runner.Result{Outcome: runner.Fail, Kind: runner.Assertion, Output: "    a_test.go:1: dial tcp 10.0.0.1:5432: connect: connection refused\n"}, CategoryNetwork},
+		{runner.Result{Outcome: runner.Fail, Kind: runner.Assertion, Output: "    a_test.go:1: Network request failed: 0.182\n"}, CategoryNetwork},
+		{runner.Result{Outcome: runner.Fail, Kind: runner.Assertion, Output: "    a_test.go:1: rpc error: code = DeadlineExceeded desc = context deadline exceeded\n"}, CategoryTimeout},
+		{runner.Result{Outcome: runner.Fail, Kind: runner.Race, Output: "WARNING: DATA RACE\n"}, CategoryRace},
+		{runner.Result{Outcome: runner.Fail, Kind: runner.Panic, Output: "panic: dial tcp: connection refused\n"}, CategoryPanic},
+		{runner.Result{Outcome: runner.Fail, Kind: runner.Timeout, Output: "panic: test timed out after 1s\n"}, CategoryTimeout},
+		{runner.Result{Outcome: runner.Fail, Kind: runner.Crash, Output: "fatal error: runtime: out of memory\n"}, CategoryOOM},
+		{runner.Result{Outcome: runner.Fail, Kind: runner.Crash}, CategoryCrash},
+	}
+	for _, tc := range cases {
+		if got := DefaultClassifier.Classify(tc.result); got != tc.want {
+			t.Errorf("Expected %q for %s %q, got %q", tc.want, tc.result.Kind, tc.result.Output, got)
+		}
+	}
+}
+
+func TestLoadClassifierTriesCustomRulesFirst(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	rules := `rules:
+  - category: database
+    pattern: 'pq: too many connections|:5432: .*connection refused'
+  - category: fatal
+    kind: crash
+`
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadClassifier(path)
+	if err != nil {
+		t.Fatalf("LoadClassifier failed: %v", err)
+	}
+	cases := map[string]runner.Result{
+		"database": {Outcome: runner.Fail, Kind: runner.Assertion, Output: "dial tcp 10.0.0.1:5432: connect: connection refused"},
+		"network":  {Outcome: runner.Fail, Kind: runner.Assertion, Output: "dial tcp 10.0.0.1:6379: connect: connection refused"},
+		"fatal":    {Outcome: runner.Fail, Kind: runner.Crash},
+	}
+	for want, result := range cases {
+		if got := c.Classify(result); got != want {
+			t.Errorf("Expected %q for %q, got %q", want, result.Output, got)
+		}
+	}
+}
+
+func TestNewClassifierRejectsInvalidRules(t *testing.T) {
+	for _, rule := range []Rule{
+		{Pattern: "boom"},
+		{Category: "empty"},
+		{Category: "bad", Pattern: "("},
+	} {
+		if _, err := NewClassifier([]Rule{rule}); err == nil {
+			t.Errorf("Expected %+v to be rejected", rule)
+		}
+	}
+}
+
+func TestCategoriesCountsFailingRuns(t *testing.T) {
+	r := runner.Aggregate(3, []runner.Result{
+		{Package: "p", Test: "TestA", Outcome: runner.Fail, Kind: runner.Assertion, Output: "i/o timeout"},
+		{Package: "p", Test: "TestA", Outcome: runner.Fail, Kind: runner.Panic},
+		{Package: "p", Test: "TestA", Outcome: runner.Fail, Kind: runner.Panic},
+		{Package: "p", Test: "TestB", Outcome: runner.Pass},
+	})
+	got := DefaultClassifier.Categories(r)
+	want := map[[2]string]map[string]int{{"p", "TestA"}: {CategoryNetwork: 1, CategoryPanic: 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if order := SortedCategories(got[[2]string{"p", "TestA"}]); !reflect.DeepEqual(order, []string{CategoryPanic, CategoryNetwork}) {
+		t.Errorf("Expected panic before network, got %v", order)
+	}
+}
@@ -0,0 +1,201 @@
+package report
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/stats"
+)
+
+// CorrelationAlpha is the significance level two tests' failures must be
+// correlated at to share a likely cause, Bonferroni-corrected for the
+// number of pairs compared
+const CorrelationAlpha = 0.05
+
+// MinCoFailures is the fewest runs two tests must fail together in to share
+// a likely cause
+const MinCoFailures = 3
+
+// TestRef names a test
+type TestRef struct {
+	Package string `json:"package"`
+	Test    string `json:"test"`
+}
+
+// CommonCause is a group of tests whose failures cluster in the same runs
+// far more than their flake rates explain, so they likely fail from one
+// cause, such as an infrastructure-wide incident or state they share,
+// rather than each from a bug of its own
+type CommonCause struct {
+	Tests []TestRef `json:"tests"`
+	// Seeds are the seeds of the runs at least two of the tests failed in
+	Seeds []int64 `json:"seeds"`
+	// Phi is the weakest correlation of the pairs linking the tests, in
+	// (0, 1]
+	Phi float64 `json:"phi"`
+	// P is the largest Fisher's exact test p-value of those pairs,
+	// uncorrected
+	P float64 `json:"p"`
+}
+
+// failureSet is the failing seeds of a test that executed once in every
+// run
+type failureSet struct {
+	ref    TestRef
+	failed map[int64]bool
+}
+
+// CommonCauses groups the tests of r whose failures are correlated across
+// runs
+// Only flaky top-level tests that executed once in every run are compared,
+// as runs a test skipped or reran cannot be told apart from its seeds
+func CommonCauses(r *runner.Report) []CommonCause {
+	var sets []failureSet
+	for _, s := range r.Tests {
+		if s.Parent() == "" {
+			sets = appendFailureSet(sets, r.Runs, s.Package, s.Test, s.Passed, s.Failed, s.Skipped, s.FailingSeeds)
+		}
+	}
+	return commonCauses(r.Runs, sets)
+}
+
+// jsonCommonCauses is CommonCauses over the tests of a JSON report, for
+// merged reports whose runs are gone
+func jsonCommonCauses(r *JSONReport) []CommonCause {
+	var sets []failureSet
+	for _, t := range r.Tests {
+		if t.Parent == "" {
+			sets = appendFailureSet(sets, r.Runs, t.Package, t.Test, t.Passed, t.Failed, t.Skipped, t.FailingSeeds)
+		}
+	}
+	return commonCauses(r.Runs, sets)
+}
+
+func appendFailureSet(sets []failureSet, runs int, pkg, test string, passed, failed, skipped int, seeds []int64) []failureSet {
+	if passed == 0 || failed == 0 || skipped > 0 || passed+failed != runs {
+		return sets
+	}
+	set := failureSet{ref: TestRef{pkg, test}, failed: make(map[int64]bool, len(seeds))}
+	for _, seed := range seeds {
+		set.failed[seed] = true
+	}
+	return append(sets, set)
+}
+
+// commonCauses links every pair of sets whose failures are significantly
+// and positively correlated and returns the connected groups, the ones
+// that failed together most first
+func commonCauses(runs int, sets []failureSet) []CommonCause {
+	pairs := len(sets) * (len(sets) - 1) / 2
+	if pairs == 0 {
+		return nil
+	}
+	parent := make([]int, len(sets))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	type link struct {
+		phi, p float64
+	}
+	links := make(map[[2]int]link)
+	for i := range sets {
+		for j := i + 1; j < len(sets); j++ {
+			a, b := sets[i].failed, sets[j].failed
+			both := 0
+			for seed := range a {
+				if b[seed] {
+					both++
+				}
+			}
+			if both < MinCoFailures {
+				continue
+			}
+			phi := phiCoefficient(both, len(a), len(b), runs)
+			p := stats.FisherExact(both, len(a), len(b)-both, runs-len(a))
+			if phi <= 0 || p*float64(pairs) >= CorrelationAlpha {
+				continue
+			}
+			links[[2]int{i, j}] = link{phi, p}
+			parent[find(i)] = find(j)
+		}
+	}
+
+	groups := make(map[int]*CommonCause)
+	var roots []int
+	for pair, l := range links {
+		root := find(pair[0])
+		g := groups[root]
+		if g == nil {
+			g = &CommonCause{Phi: 1}
+			groups[root] = g
+			roots = append(roots, root)
+		}
+		g.Phi = math.Min(g.Phi, l.phi)
+		g.P = math.Max(g.P, l.p)
+	}
+	var causes []CommonCause
+	for _, root := range roots {
+		g := groups[root]
+		count := make(map[int64]int)
+		for i, set := range sets {
+			if find(i) != root {
+				continue
+			}
+			g.Tests = append(g.Tests, set.ref)
+			for seed := range set.failed {
+				count[seed]++
+			}
+		}
+		for seed, n := range count {
+			if n >= 2 {
+				g.Seeds = append(g.Seeds, seed)
+			}
+		}
+		slices.Sort(g.Seeds)
+		sort.Slice(g.Tests, func(i, j int) bool { return refLess(g.Tests[i], g.Tests[j]) })
+		causes = append(causes, *g)
+	}
+	sort.Slice(causes, func(i, j int) bool {
+		if len(causes[i].Seeds) != len(causes[j].Seeds) {
+			return len(causes[i].Seeds) > len(causes[j].Seeds)
+		}
+		return refLess(causes[i].Tests[0], causes[j].Tests[0])
+	})
+	return causes
+}
+
+// phiCoefficient returns the correlation of two tests' failures over runs,
+// given the runs both failed in and the runs each failed in
+func phiCoefficient(both, a, b, runs int) float64 {
+	den := math.Sqrt(float64(a) * float64(runs-a) * float64(b) * float64(runs-b))
+	if den == 0 {
+		return 0
+	}
+	return (float64(both)*float64(runs) - float64(a)*float64(b)) / den
+}
+
+func refLess(a, b TestRef) bool {
+	if a.Package != b.Package {
+		return a.Package < b.Package
+	}
+	return a.Test < b.Test
+}
+
+// Names returns the names of the cause's tests, joined by ", "
+func (c CommonCause) Names() string {
+	names := make([]string, len(c.Tests))
+	for i, t := range c.Tests {
+		names[i] = t.Test
+	}
+	return strings.Join(names, ", ")
+}
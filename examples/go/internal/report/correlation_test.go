@@ -0,0 +1,76 @@
+package report
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// sweep returns the results of runs in which each test fails at the seeds
+// given, with seed run+1
+func sweep(runs int, failing map[string][]int64) []runner.Result {
+	var results []runner.Result
+	for test, seeds := range failing {
+		for run := 0; run < runs; run++ {
+			seed := int64(run + 1)
+			outcome := runner.Pass
+			if slices.Contains(seeds, seed) {
+				outcome = runner.Fail
+			}
+			results = append(results, runner.Result{Package: "p", Test: test, Run: run, Seed: seed, Outcome: outcome})
+		}
+	}
+	return results
+}
+
+func TestCommonCauses(t *testing.T) {
+	incident := []int64{4, 11, 23, 37, 52, 68}
+	report := runner.Aggregate(100, sweep(100, map[string][]int64{
+		"TestDB":      append([]int64{2}, incident...),
+		"TestCache":   append(slices.Clone(incident[1:]), 90),
+		"TestQueue":   incident[:4],
+		"TestAlone":   {5, 30, 61, 77, 95},
+		"TestStable":  nil,
+		"TestAlone/x": {5, 30, 61, 77, 95},
+	}))
+	causes := CommonCauses(report)
+	if len(causes) != 1 {
+		t.Fatalf("Expected one common cause, got %+v", causes)
+	}
+	c := causes[0]
+	if c.Names() != "TestCache, TestDB, TestQueue" {
+		t.Errorf("Expected the three incident tests, got %s", c.Names())
+	}
+	if !slices.Equal(c.Seeds, incident) {
+		t.Errorf("Expected the incident seeds %v, got %v", incident, c.Seeds)
+	}
+	if c.Phi <= 0.5 || c.Phi > 1 || c.P <= 0 || c.P > 0.001 {
+		t.Errorf("Expected a strong, significant correlation, got phi %v p %v", c.Phi, c.P)
+	}
+
+	// Failing together twice is too little to blame a common cause
+	few := runner.Aggregate(100, sweep(100, map[string][]int64{"TestA": {1, 2}, "TestB": {1, 2}}))
+	if causes := CommonCauses(few); len(causes) != 0 {
+		t.Errorf("Expected no cause from two co-failures, got %+v", causes)
+	}
+}
+
+func TestCommonCausesMerged(t *testing.T) {
+	shard := func(first int64) *JSONReport {
+		var results []runner.Result
+		for _, r := range sweep(50, map[string][]int64{"TestDB": {1, 2, 3}, "TestCache": {1, 2, 3}}) {
+			r.Seed += first - 1
+			results = append(results, r)
+		}
+		return NewJSONReport(runner.Aggregate(50, results), 0.95, nil)
+	}
+	a, b := shard(1), shard(51)
+	if len(a.CommonCauses) != 1 {
+		t.Fatalf("Expected each shard to find the cause, got %+v", a.CommonCauses)
+	}
+	merged := MergeJSON(0.95, a, b)
+	if len(merged.CommonCauses) != 1 || len(merged.CommonCauses[0].Seeds) != 6 {
+		t.Errorf("Expected the merged cause over both shards' seeds, got %+v", merged.CommonCauses)
+	}
+}
@@ -0,0 +1,161 @@
+package report
+
+import (
+	"sort"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// UnknownHost labels the sessions recorded without a host name
+const UnknownHost = "(unknown)"
+
+// maxHostTests is how many failing tests a host's row names
+const maxHostTests = 3
+
+// Heatmap counts the runs and failures of a window by the hour and weekday,
+// in UTC, their session started and by the host that ran it, to tell
+// failures that follow the clock, such as a nightly backup or cron job,
+// from those that follow a bad machine
+type Heatmap struct {
+	// Days holds a row of 24 hourly cells per weekday, Monday first
+	Days []HeatmapDay
+	// Hosts are sorted by failure rate, highest first
+	Hosts []HostRow
+	// Failures and Runs total the window, so a cell or host can be compared
+	// with the overall rate
+	Failures, Runs int
+}
+
+// HeatmapDay is one weekday's row of a Heatmap
+type HeatmapDay struct {
+	Day   time.Weekday
+	Hours [24]HeatmapCell
+}
+
+// HeatmapCell counts the runs of one hour of one weekday
+type HeatmapCell struct {
+	Runs, Failures int
+	// Shade is the cell's failure rate relative to the highest cell's, in
+	// [0, 1]
+	Shade float64
+}
+
+// Rate returns the cell's failure rate, 0 for a cell without runs
+func (c HeatmapCell) Rate() float64 {
+	if c.Runs == 0 {
+		return 0
+	}
+	return float64(c.Failures) / float64(c.Runs)
+}
+
+// HostRow counts the runs of the sessions one host ran
+type HostRow struct {
+	Host     string
+	Sessions int
+	Runs     int
+	Failures int
+	// Tests names the tests that failed most on the host, up to
+	// maxHostTests, most failures first
+	Tests []string
+}
+
+// Rate returns the host's failure rate, 0 for a host without runs
+func (h HostRow) Rate() float64 {
+	if h.Runs == 0 {
+		return 0
+	}
+	return float64(h.Failures) / float64(h.Runs)
+}
+
+// Rate returns the window's failure rate, 0 for a window without runs
+func (h *Heatmap) Rate() float64 {
+	if h.Runs == 0 {
+		return 0
+	}
+	return float64(h.Failures) / float64(h.Runs)
+}
+
+// NewHeatmap counts the test runs of the sessions recorded at or after
+// since, skipped runs aside, by when and where each session ran
+func NewHeatmap(sessions []history.Session, since time.Time) *Heatmap {
+	h := &Heatmap{}
+	cells := make(map[time.Weekday]*[24]HeatmapCell)
+	for day := range 7 {
+		cells[time.Weekday(day)] = new([24]HeatmapCell)
+	}
+	hosts := make(map[string]*HostRow)
+	failing := make(map[string]map[string]int)
+	for _, s := range sessions {
+		if s.Time.Before(since) {
+			continue
+		}
+		t := s.Time.UTC()
+		cell := &cells[t.Weekday()][t.Hour()]
+		name := s.Host
+		if name == "" {
+			name = UnknownHost
+		}
+		host := hosts[name]
+		if host == nil {
+			host = &HostRow{Host: name}
+			hosts[name] = host
+			failing[name] = make(map[string]int)
+		}
+		host.Sessions++
+		for _, r := range s.Results {
+			if r.Outcome == runner.Skip {
+				continue
+			}
+			cell.Runs++
+			host.Runs++
+			h.Runs++
+			if r.Outcome == runner.Fail {
+				cell.Failures++
+				host.Failures++
+				h.Failures++
+				failing[name][r.Test]++
+			}
+		}
+	}
+
+	var highest float64
+	for _, hours := range cells {
+		for _, c := range hours {
+			highest = max(highest, c.Rate())
+		}
+	}
+	// Monday first, as a work week reads
+	for i := range 7 {
+		day := time.Weekday((i + 1) % 7)
+		row := HeatmapDay{Day: day, Hours: *cells[day]}
+		for hour := range row.Hours {
+			if highest > 0 {
+				row.Hours[hour].Shade = row.Hours[hour].Rate() / highest
+			}
+		}
+		h.Days = append(h.Days, row)
+	}
+
+	for name, host := range hosts {
+		tests := make([]string, 0, len(failing[name]))
+		for test := range failing[name] {
+			tests = append(tests, test)
+		}
+		sort.Slice(tests, func(i, j int) bool {
+			a, b := failing[name][tests[i]], failing[name][tests[j]]
+			return a > b || a == b && tests[i] < tests[j]
+		})
+		if len(tests) > maxHostTests {
+			tests = tests[:maxHostTests]
+		}
+		host.Tests = tests
+		h.Hosts = append(h.Hosts, *host)
+	}
+	sort.Slice(h.Hosts, func(i, j int) bool {
+		a, b := h.Hosts[i], h.Hosts[j]
+		return a.Rate() > b.Rate() || a.Rate() == b.Rate() && a.Host < b.Host
+	})
+	return h
+}
@@ -0,0 +1,89 @@
+package report
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestHeatmap(t *testing.T) {
+	// 2024-03-04 is a Monday
+	monday := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	session := func(at time.Time, host string, outcomes ...runner.Outcome) history.Session {
+		s := history.Session{Time: at, Host: host, Runs: len(outcomes)}
+		for i, o := range outcomes {
+			s.Results = append(s.Results, history.Record{Package: "p", Test: "TestA", Seed: int64(i), Outcome: o})
+		}
+		return s
+	}
+	sessions := []history.Session{
+		session(monday.Add(-time.Hour), "old", runner.Fail),
+		session(monday.Add(3*time.Hour), "ci-1", runner.Fail, runner.Fail, runner.Pass, runner.Pass),
+		session(monday.Add(3*time.Hour+30*time.Minute), "ci-2", runner.Pass, runner.Pass, runner.Pass, runner.Fail),
+		session(monday.Add(50*time.Hour), "ci-2", runner.Pass, runner.Pass, runner.Skip),
+		session(monday.Add(6*24*time.Hour+23*time.Hour), "", runner.Pass),
+	}
+	h := NewHeatmap(sessions, monday)
+	if h.Runs != 11 || h.Failures != 3 {
+		t.Fatalf("Expected 3 of 11 runs failing since Monday, got %d of %d", h.Failures, h.Runs)
+	}
+	days := make([]time.Weekday, len(h.Days))
+	for i, d := range h.Days {
+		days[i] = d.Day
+	}
+	if want := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}; !slices.Equal(days, want) {
+		t.Errorf("Expected the week Monday first, got %v", days)
+	}
+	if c := h.Days[0].Hours[3]; c.Runs != 8 || c.Failures != 3 || c.Shade != 1 {
+		t.Errorf("Expected Monday 03:00 to hold both early sessions at full shade, got %+v", c)
+	}
+	if c := h.Days[2].Hours[2]; c.Runs != 2 || c.Failures != 0 || c.Shade != 0 {
+		t.Errorf("Expected Wednesday 02:00 to hold two passing runs, got %+v", c)
+	}
+	if c := h.Days[6].Hours[23]; c.Runs != 1 {
+		t.Errorf("Expected Sunday 23:00 to hold one run, got %+v", c)
+	}
+
+	hosts := make([]string, len(h.Hosts))
+	for i, host := range h.Hosts {
+		hosts[i] = host.Host
+	}
+	if want := []string{"ci-1", "ci-2", UnknownHost}; !slices.Equal(hosts, want) {
+		t.Errorf("Expected hosts by failure rate %v, got %v", want, hosts)
+	}
+	if ci2 := h.Hosts[1]; ci2.Sessions != 2 || ci2.Runs != 6 || ci2.Failures != 1 || !slices.Equal(ci2.Tests, []string{"TestA"}) {
+		t.Errorf("Unexpected ci-2 row: %+v", ci2)
+	}
+}
+
+func TestWriteHTMLHeatmap(t *testing.T) {
+	at := time.Date(2024, 3, 5, 14, 0, 0, 0, time.UTC)
+	sessions := []history.Session{{Time: at, Host: "ci-7", Runs: 2, Results: []history.Record{
+		{Package: "p", Test: "TestA", Seed: 1, Outcome: runner.Fail},
+		{Package: "p", Test: "TestA", Seed: 2, Outcome: runner.Pass},
+	}}}
+	var out bytes.Buffer
+	if err := WriteHTML(&out, NewDashboard(sessions, at.Add(-time.Hour))); err != nil {
+		t.Fatal(err)
+	}
+	html := out.String()
+	for _, want := range []string{"When and where failures happen", `title="Tue 14:00: 1 of 2 run(s) failed (50.0%)"`, "rgba(207, 34, 46, 1)", "<td>ci-7</td>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected the page to contain %q", want)
+		}
+	}
+
+	out.Reset()
+	sessions[0].Results[0].Outcome = runner.Pass
+	if err := WriteHTML(&out, NewDashboard(sessions, at.Add(-time.Hour))); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "When and where failures happen") {
+		t.Error("Expected no heatmap for a window without failures")
+	}
+}
@@ -0,0 +1,226 @@
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+//go:embed templates/dashboard.html
+var dashboardHTML string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"percent": func(rate float64) string { return fmt.Sprintf("%.1f%%", rate*100) },
+	"slug":    func(s history.Status) string { return strings.ReplaceAll(string(s), " ", "-") },
+	"weekday": func(d time.Weekday) string { return d.String()[:3] },
+	"seeds": func(seeds []int64) string {
+		parts := make([]string, len(seeds))
+		for i, s := range seeds {
+			parts[i] = fmt.Sprint(s)
+		}
+		return strings.Join(parts, ", ")
+	},
+}).Parse(dashboardHTML))
+
+// histogramBins is the number of bars in a test's duration histogram
+const histogramBins = 10
+
+// maxExamples is how many distinct messages a cluster shows
+const maxExamples = 3
+
+// Dashboard is the data behind the HTML report
+type Dashboard struct {
+	Generated time.Time
+	Since     time.Time
+	// Sessions and Runs count the sessions in the window and their runs
+	Sessions int
+	Runs     int
+	// Statuses counts the tests by status
+	Statuses map[history.Status]int
+	// Tests are sorted by flake rate, highest first
+	Tests []DashboardTest
+	// Heatmap counts the window's failures by hour, weekday and host
+	Heatmap *Heatmap
+}
+
+// DashboardTest is one test's row of the dashboard
+type DashboardTest struct {
+	Package string
+	Test    string
+	Status  history.Status
+	Counts  history.Counts
+	// PassRates is the pass rate in each session that ran the test, oldest
+	// first
+	PassRates []float64
+	// Sparkline is PassRates as SVG polyline points
+	Sparkline string
+	// Histogram buckets the durations of every run in the window
+	Histogram []Bin
+	// FailingSeeds holds the seed of every failing run, in run order
+	FailingSeeds []int64
+	// Clusters groups the failure messages, largest first
+	Clusters []Cluster
+}
+
+// Bin is one bar of a duration histogram
+type Bin struct {
+	Min, Max time.Duration
+	Count    int
+	// Height is Count relative to the tallest bar, in [0, 1]
+	Height float64
+}
+
+// Cluster is a group of failure messages that differ only in numbers, such
+// as "Expected 3 items, got 2" and "Expected 5 items, got 4"
+type Cluster struct {
+	// Pattern is the messages' shared text, with each number shown as N
+	Pattern string
+	Count   int
+	// Examples holds up to maxExamples distinct messages, in first-seen order
+	Examples []string
+	Seeds    []int64
+}
+
+// NewDashboard summarizes the sessions recorded at or after since, comparing
+// each test's status with the earlier ones as flakectl report does
+func NewDashboard(sessions []history.Session, since time.Time) *Dashboard {
+	d := &Dashboard{
+		Generated: time.Now().UTC(),
+		Since:     since,
+		Statuses:  make(map[history.Status]int),
+		Heatmap:   NewHeatmap(sessions, since),
+	}
+	type key struct{ pkg, test string }
+	durations := make(map[key][]time.Duration)
+	failures := make(map[key][]history.Record)
+	for _, s := range sessions {
+		if s.Time.Before(since) {
+			continue
+		}
+		d.Sessions++
+		d.Runs += s.Runs
+		for _, r := range s.Results {
+			if r.Outcome == runner.Skip {
+				continue
+			}
+			k := key{r.Package, r.Test}
+			durations[k] = append(durations[k], r.Duration)
+			if r.Outcome == runner.Fail {
+				failures[k] = append(failures[k], r)
+			}
+		}
+	}
+
+	for _, t := range history.Trends(sessions, since) {
+		k := key{t.Package, t.Test}
+		row := DashboardTest{
+			Package:   t.Package,
+			Test:      t.Test,
+			Status:    t.Status,
+			Counts:    t.Window,
+			Histogram: histogram(durations[k], histogramBins),
+			Clusters:  clusterFailures(failures[k]),
+		}
+		for _, rate := range t.Series {
+			row.PassRates = append(row.PassRates, 1-rate)
+		}
+		row.Sparkline = sparklinePoints(row.PassRates)
+		for _, f := range failures[k] {
+			row.FailingSeeds = append(row.FailingSeeds, f.Seed)
+		}
+		d.Statuses[t.Status]++
+		d.Tests = append(d.Tests, row)
+	}
+	sort.SliceStable(d.Tests, func(i, j int) bool {
+		return d.Tests[i].Counts.FlakeRate() > d.Tests[j].Counts.FlakeRate()
+	})
+	return d
+}
+
+// WriteHTML renders d as a self-contained HTML page
+func WriteHTML(w io.Writer, d *Dashboard) error {
+	return dashboardTemplate.Execute(w, d)
+}
+
+// sparklinePoints plots rates in [0, 1] on a 100x20 canvas, 1 at the top
+func sparklinePoints(rates []float64) string {
+	if len(rates) == 0 {
+		return ""
+	}
+	if len(rates) == 1 {
+		// A single session draws as a flat line
+		rates = []float64{rates[0], rates[0]}
+	}
+	points := make([]string, len(rates))
+	for i, r := range rates {
+		x := 100 * float64(i) / float64(len(rates)-1)
+		points[i] = fmt.Sprintf("%.1f,%.1f", x, 20*(1-r))
+	}
+	return strings.Join(points, " ")
+}
+
+// histogram buckets durations into bins of equal width between the shortest
+// and longest; identical durations make a single bin
+func histogram(durations []time.Duration, bins int) []Bin {
+	if len(durations) == 0 {
+		return nil
+	}
+	lo, hi := durations[0], durations[0]
+	for _, d := range durations {
+		lo, hi = min(lo, d), max(hi, d)
+	}
+	if lo == hi {
+		return []Bin{{Min: lo, Max: hi, Count: len(durations), Height: 1}}
+	}
+	width := (hi - lo + time.Duration(bins) - 1) / time.Duration(bins)
+	out := make([]Bin, bins)
+	for i := range out {
+		out[i].Min = lo + time.Duration(i)*width
+		out[i].Max = out[i].Min + width
+	}
+	tallest := 0
+	for _, d := range durations {
+		i := min(int((d-lo)/width), bins-1)
+		out[i].Count++
+		tallest = max(tallest, out[i].Count)
+	}
+	for i := range out {
+		out[i].Height = float64(out[i].Count) / float64(tallest)
+	}
+	return out
+}
+
+// clusterFailures groups failing runs by the signature of their message,
+// so failures that differ only in values, line numbers or timings, or in a
+// few words, count as one
+func clusterFailures(failures []history.Record) []Cluster {
+	var clusters []Cluster
+	for _, f := range failures {
+		msg := f.Message
+		if msg == "" {
+			msg = "(no message)"
+		}
+		pattern := Signature(msg)
+		i := slices.IndexFunc(clusters, func(c Cluster) bool { return SameFailure(c.Pattern, pattern) })
+		if i < 0 {
+			i = len(clusters)
+			clusters = append(clusters, Cluster{Pattern: pattern})
+		}
+		c := &clusters[i]
+		c.Count++
+		c.Seeds = append(c.Seeds, f.Seed)
+		if len(c.Examples) < maxExamples && !slices.Contains(c.Examples, msg) {
+			c.Examples = append(c.Examples, msg)
+		}
+	}
+	sort.SliceStable(clusters, func(i, j int) bool { return clusters[i].Count > clusters[j].Count })
+	return clusters
+}
@@ -0,0 +1,98 @@
+package report
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestClusterFailures(t *testing.T) {
+	rec := func(seed int64, msg string) history.Record {
+		return history.Record{Seed: seed, Outcome: runner.Fail, Message: msg}
+	}
+	clusters := clusterFailures([]history.Record{
+		rec(1, "a_test.go:12: Expected 3 items, got 2"),
+		rec(2, "a_test.go:40: http2 stream reset after 1.5s"),
+		rec(3, "a_test.go:12: Expected 5 items, got 4"),
+		rec(4, "a_test.go:12: Expected 3 items, got 2"),
+		rec(5, ""),
+	})
+	if len(clusters) != 3 {
+		t.Fatalf("Expected 3 clusters, got %+v", clusters)
+	}
+	top := clusters[0]
+	if top.Pattern != "a_test.go:N: Expected N items, got N" || top.Count != 3 || len(top.Examples) != 2 {
+		t.Errorf("Unexpected largest cluster: %+v", top)
+	}
+	if got := clusters[1].Pattern; got != "a_test.go:N: http2 stream reset after N" {
+		t.Errorf("Expected digits inside names to be kept, got %q", got)
+	}
+	if clusters[2].Pattern != "(no message)" || clusters[2].Seeds[0] != 5 {
+		t.Errorf("Expected a cluster for runs without a message, got %+v", clusters[2])
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	bins := histogram([]time.Duration{0, 1, 1, 9, 10}, 5)
+	counts := make([]int, len(bins))
+	for i, b := range bins {
+		counts[i] = b.Count
+	}
+	if want := []int{3, 0, 0, 0, 2}; !slices.Equal(counts, want) {
+		t.Errorf("Expected bin counts %v, got %v", want, counts)
+	}
+	if bins[0].Height != 1 || bins[4].Height < 0.66 || bins[4].Height > 0.67 {
+		t.Errorf("Expected heights relative to the tallest bin, got %+v", bins)
+	}
+	if bins := histogram([]time.Duration{7, 7}, 5); len(bins) != 1 || bins[0].Count != 2 {
+		t.Errorf("Expected identical durations in one bin, got %+v", bins)
+	}
+}
+
+func TestSparklinePoints(t *testing.T) {
+	if got := sparklinePoints([]float64{1, 0.5, 0}); got != "0.0,0.0 50.0,10.0 100.0,20.0" {
+		t.Errorf("Unexpected points %q", got)
+	}
+	if got := sparklinePoints([]float64{1}); got != "0.0,0.0 100.0,0.0" {
+		t.Errorf("Expected a single session to draw a flat line, got %q", got)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	since := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	rec := func(test string, seed int64, outcome runner.Outcome, msg string) history.Record {
+		return history.Record{Package: "p", Test: test, Seed: seed, Outcome: outcome, Duration: time.Duration(seed) * time.Millisecond, Message: msg}
+	}
+	sessions := []history.Session{
+		{Time: since.Add(-time.Hour), Runs: 1, Results: []history.Record{rec("TestA", 1, runner.Pass, "")}},
+		{Time: since.Add(time.Hour), Runs: 2, Results: []history.Record{
+			rec("TestA", 2, runner.Fail, "a_test.go:9: got <nil>"),
+			rec("TestA", 3, runner.Pass, ""),
+			rec("TestB", 2, runner.Pass, ""),
+			rec("TestB", 3, runner.Pass, ""),
+		}},
+	}
+	d := NewDashboard(sessions, since)
+	if d.Sessions != 1 || d.Runs != 2 || len(d.Tests) != 2 {
+		t.Fatalf("Unexpected dashboard: %+v", d)
+	}
+	if a := d.Tests[0]; a.Test != "TestA" || a.Status != history.NewlyFlaky || len(a.FailingSeeds) != 1 || a.FailingSeeds[0] != 2 {
+		t.Errorf("Expected the newly flaky TestA first, got %+v", a)
+	}
+
+	var out bytes.Buffer
+	if err := WriteHTML(&out, d); err != nil {
+		t.Fatal(err)
+	}
+	html := out.String()
+	for _, want := range []string{"<!DOCTYPE html>", `class="status-newly-flaky"`, "50.0%", "<polyline points=", "<rect ", "seeds: 2", "a_test.go:9: got &lt;nil&gt;"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected the page to contain %q", want)
+		}
+	}
+}
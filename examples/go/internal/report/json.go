@@ -0,0 +1,294 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/internal/attach"
+	"github.com/example/flaky-test-example/internal/fingerprint"
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/stats"
+)
+
+// JSONReport is the structured flake report returned by the serverless worker
+// and written by flakectl detect --json
+type JSONReport struct {
+	Runs       int         `json:"runs"`
+	Confidence float64     `json:"confidence"`
+	Summary    JSONSummary `json:"summary"`
+	// Fingerprint is the environment the runs ran in, nil when unknown or,
+	// for merged reports, when the reports ran in different ones
+	Fingerprint *fingerprint.Fingerprint `json:"fingerprint,omitempty"`
+	Tests       []JSONTest               `json:"tests"`
+	// CommonCauses groups the tests whose failures cluster in the same runs
+	CommonCauses []CommonCause `json:"common_causes,omitempty"`
+}
+
+// JSONSummary counts tests per classification
+type JSONSummary struct {
+	Tests   int `json:"tests"`
+	Stable  int `json:"stable"`
+	Flaky   int `json:"flaky"`
+	Failing int `json:"failing"`
+	Skipped int `json:"skipped"`
+	// Categories counts the failing runs of every test by category
+	Categories map[string]int `json:"categories,omitempty"`
+}
+
+// JSONTest is one test's aggregated outcomes
+type JSONTest struct {
+	Package        string  `json:"package"`
+	Test           string  `json:"test"`
+	Classification string  `json:"classification"`
+	Runs           int     `json:"runs"`
+	Passed         int     `json:"passed"`
+	Failed         int     `json:"failed"`
+	Skipped        int     `json:"skipped"`
+	PassRate       float64 `json:"pass_rate"`
+	FlakeRate      float64 `json:"flake_rate"`
+	// FlakeRateCI is the Wilson interval of FlakeRate at the report's confidence
+	FlakeRateCI     [2]float64 `json:"flake_rate_ci"`
+	MeanDurationMS  float64    `json:"mean_duration_ms"`
+	FailingSeeds    []int64    `json:"failing_seeds,omitempty"`
+	FailureMessages []string   `json:"failure_messages,omitempty"`
+	// Categories counts the failing runs by category
+	Categories map[string]int `json:"categories,omitempty"`
+	// Crashes counts the runs whose test binary crashed in the test by how
+	// it ended, such as "SIGKILL" or "exit status 2"
+	Crashes map[string]int `json:"crashes,omitempty"`
+	// Failures groups the failing seeds by the signature of the first
+	// message each failing run logged
+	Failures []JSONFailure `json:"failures,omitempty"`
+	// Meta holds the injected conditions failing runs logged with
+	// flaky.Report, ordered by seed
+	Meta []flaky.FailureMeta `json:"meta,omitempty"`
+	// Attachments point at the stored outputs of failing runs, ordered by
+	// seed, for flakectl show
+	Attachments []attach.Attachment `json:"attachments,omitempty"`
+	// Owners are the teams an owners file assigns the test to
+	Owners []string `json:"owners,omitempty"`
+	// Parent names the test a subtest runs under
+	Parent string `json:"parent,omitempty"`
+	// SubtestFailures counts the failing runs a failing subtest explains
+	SubtestFailures int `json:"subtest_failures,omitempty"`
+	// Corpus says where a fuzz seed corpus entry is defined, as
+	// runner.CorpusEntry.Source does
+	Corpus string `json:"corpus,omitempty"`
+}
+
+// JSONFailure is a distinct failure and the seeds that trigger it
+type JSONFailure struct {
+	// Message is the first message of the failure's first run
+	Message string `json:"message"`
+	// Signature is Message with the parts that vary between runs, such as
+	// numbers, replaced by N
+	Signature string `json:"signature,omitempty"`
+	// Category is the category of the first run that failed with Message
+	Category string `json:"category,omitempty"`
+	// Count is the number of failing runs, and Seed the seed of the first,
+	// to reproduce the failure with
+	Count int     `json:"count,omitempty"`
+	Seed  int64   `json:"seed,omitempty"`
+	Seeds []int64 `json:"seeds"`
+}
+
+// NewJSONReport converts r, computing flake-rate intervals at confidence and
+// classifying failures with c (DefaultClassifier when nil)
+func NewJSONReport(r *runner.Report, confidence float64, c *Classifier) *JSONReport {
+	c = c.orDefault()
+	out := &JSONReport{Runs: r.Runs, Confidence: confidence, Tests: []JSONTest{}}
+	if r.Fingerprint.GOOS != "" {
+		fp := r.Fingerprint
+		out.Fingerprint = &fp
+	}
+	failures := FailureSignatures(r.Results, c)
+	categories := c.Categories(r)
+	for _, s := range r.Tests {
+		class := s.Classify()
+		out.Summary.count(class)
+		est := stats.EstimateCounts(s.Passed, s.Failed, confidence)
+		var corpus string
+		if e, ok := runner.ParseCorpusEntry(s.Test); ok {
+			corpus = e.Source()
+		}
+		out.Tests = append(out.Tests, JSONTest{
+			Package:         s.Package,
+			Test:            s.Test,
+			Classification:  string(class),
+			Runs:            s.Runs(),
+			Passed:          s.Passed,
+			Failed:          s.Failed,
+			Skipped:         s.Skipped,
+			PassRate:        s.PassRate(),
+			FlakeRate:       s.FlakeRate(),
+			FlakeRateCI:     [2]float64{est.Wilson.Lower, est.Wilson.Upper},
+			MeanDurationMS:  float64(s.MeanDuration().Microseconds()) / 1000,
+			FailingSeeds:    s.FailingSeeds,
+			FailureMessages: s.FailureMessages,
+			Categories:      categories[[2]string{s.Package, s.Test}],
+			Crashes:         s.Crashes,
+			Failures:        failures[[2]string{s.Package, s.Test}],
+			Meta:            s.Meta,
+			Attachments:     s.Attachments,
+			Parent:          s.Parent(),
+			SubtestFailures: s.SubtestFailures,
+			Corpus:          corpus,
+		})
+		out.Summary.addCategories(categories[[2]string{s.Package, s.Test}])
+	}
+	out.Summary.Tests = len(out.Tests)
+	out.CommonCauses = CommonCauses(r)
+	return out
+}
+
+// SetOwners sets the owners of every test to what owners returns for it,
+// such as the Owners method of an owners.Map
+func (r *JSONReport) SetOwners(owners func(pkg, test string) []string) {
+	for i := range r.Tests {
+		r.Tests[i].Owners = owners(r.Tests[i].Package, r.Tests[i].Test)
+	}
+}
+
+// MostCommonFailure returns the failure the most failing seeds share, the
+// first seen among equals, or the zero JSONFailure for a test that never failed
+func (t JSONTest) MostCommonFailure() JSONFailure {
+	return representative(t.Failures)
+}
+
+// MergeJSON combines reports over disjoint runs, such as the shards of a
+// seed sweep, into one report with intervals recomputed at confidence
+func MergeJSON(confidence float64, reports ...*JSONReport) *JSONReport {
+	type key struct{ pkg, test string }
+	merged := make(map[key]*JSONTest)
+	var order []key
+	out := &JSONReport{Confidence: confidence, Tests: []JSONTest{}}
+	if len(reports) > 0 {
+		out.Fingerprint = reports[0].Fingerprint
+	}
+	for _, r := range reports {
+		if out.Fingerprint != nil && (r.Fingerprint == nil || r.Fingerprint.ID() != out.Fingerprint.ID()) {
+			out.Fingerprint = nil
+		}
+		out.Runs += r.Runs
+		for _, t := range r.Tests {
+			k := key{t.Package, t.Test}
+			m := merged[k]
+			if m == nil {
+				m = &JSONTest{Package: t.Package, Test: t.Test, Parent: t.Parent, Corpus: t.Corpus}
+				merged[k] = m
+				order = append(order, k)
+			}
+			if len(m.Owners) == 0 {
+				m.Owners = t.Owners
+			}
+			m.MeanDurationMS += t.MeanDurationMS * float64(t.Runs)
+			m.Passed += t.Passed
+			m.Failed += t.Failed
+			m.Skipped += t.Skipped
+			m.SubtestFailures += t.SubtestFailures
+			for category, n := range t.Categories {
+				if m.Categories == nil {
+					m.Categories = make(map[string]int)
+				}
+				m.Categories[category] += n
+			}
+			for exit, n := range t.Crashes {
+				if m.Crashes == nil {
+					m.Crashes = make(map[string]int)
+				}
+				m.Crashes[exit] += n
+			}
+			m.FailingSeeds = append(m.FailingSeeds, t.FailingSeeds...)
+			for _, msg := range t.FailureMessages {
+				if !slices.Contains(m.FailureMessages, msg) {
+					m.FailureMessages = append(m.FailureMessages, msg)
+				}
+			}
+			for _, f := range t.Failures {
+				m.Failures = addFailure(m.Failures, f, f.Seeds...)
+			}
+			m.Meta = append(m.Meta, t.Meta...)
+			m.Attachments = append(m.Attachments, t.Attachments...)
+		}
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		if order[i].pkg != order[j].pkg {
+			return order[i].pkg < order[j].pkg
+		}
+		return order[i].test < order[j].test
+	})
+	for _, k := range order {
+		t := merged[k]
+		ts := &runner.TestStats{Passed: t.Passed, Failed: t.Failed, Skipped: t.Skipped}
+		t.Runs = ts.Runs()
+		if t.Runs > 0 {
+			t.MeanDurationMS /= float64(t.Runs)
+		}
+		t.Classification = string(ts.Classify())
+		t.PassRate = ts.PassRate()
+		t.FlakeRate = ts.FlakeRate()
+		est := stats.EstimateCounts(t.Passed, t.Failed, confidence)
+		t.FlakeRateCI = [2]float64{est.Wilson.Lower, est.Wilson.Upper}
+		slices.Sort(t.FailingSeeds)
+		for i := range t.Failures {
+			slices.Sort(t.Failures[i].Seeds)
+		}
+		sort.SliceStable(t.Meta, func(i, j int) bool { return t.Meta[i].Seed < t.Meta[j].Seed })
+		sort.SliceStable(t.Attachments, func(i, j int) bool { return t.Attachments[i].Seed < t.Attachments[j].Seed })
+		out.Summary.count(ts.Classify())
+		out.Summary.addCategories(t.Categories)
+		out.Tests = append(out.Tests, *t)
+	}
+	out.Summary.Tests = len(out.Tests)
+	out.CommonCauses = jsonCommonCauses(out)
+	return out
+}
+
+func (s *JSONSummary) count(c runner.Classification) {
+	switch c {
+	case runner.Stable:
+		s.Stable++
+	case runner.Flaky:
+		s.Flaky++
+	case runner.Failing:
+		s.Failing++
+	case runner.Skipped:
+		s.Skipped++
+	}
+}
+
+func (s *JSONSummary) addCategories(counts map[string]int) {
+	for category, n := range counts {
+		if s.Categories == nil {
+			s.Categories = make(map[string]int)
+		}
+		s.Categories[category] += n
+	}
+}
+
+// LoadJSON reads a report written by WriteJSON
+func LoadJSON(path string) (*JSONReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r JSONReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// WriteJSON writes r as an indented JSONReport, classifying failures with c
+// (DefaultClassifier when nil)
+func WriteJSON(w io.Writer, r *runner.Report, confidence float64, c *Classifier) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(NewJSONReport(r, confidence, c))
+}
@@ -0,0 +1,168 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/internal/fingerprint"
+)
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, sampleReport(), 0.95, nil); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+
+	var doc JSONReport
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	want := JSONSummary{Tests: 3, Stable: 1, Flaky: 1, Failing: 1, Categories: map[string]int{CategoryAssertion: 4}}
+	if doc.Runs != 3 || !reflect.DeepEqual(doc.Summary, want) {
+		t.Fatalf("Unexpected summary: runs=%d %+v", doc.Runs, doc.Summary)
+	}
+
+	tests := make(map[string]JSONTest)
+	for _, tc := range doc.Tests {
+		tests[tc.Test] = tc
+	}
+	flaky := tests["TestFlaky"]
+	if flaky.Classification != "flaky" || flaky.Passed != 2 || flaky.Failed != 1 || flaky.MeanDurationMS != 20 {
+		t.Errorf("Unexpected TestFlaky entry: %+v", flaky)
+	}
+	if len(flaky.FailingSeeds) != 1 || flaky.FailingSeeds[0] != 2 {
+		t.Errorf("Expected failing seed 2, got %v", flaky.FailingSeeds)
+	}
+	if lo, hi := flaky.FlakeRateCI[0], flaky.FlakeRateCI[1]; lo >= flaky.FlakeRate || hi <= flaky.FlakeRate {
+		t.Errorf("Expected the interval %v to contain %v", flaky.FlakeRateCI, flaky.FlakeRate)
+	}
+	broken := tests["TestBroken"]
+	if len(broken.Failures) != 1 || broken.Failures[0].Message != "b_test.go:1: boom" || len(broken.Failures[0].Seeds) != 3 {
+		t.Errorf("Expected one failure triggered by 3 seeds, got %+v", broken.Failures)
+	}
+	if broken.Categories[CategoryAssertion] != 3 || broken.Failures[0].Category != CategoryAssertion {
+		t.Errorf("Expected assertion failures to be counted, got %v and %+v", broken.Categories, broken.Failures)
+	}
+	if stable := tests["TestStable"]; stable.FailingSeeds != nil || stable.FailureMessages != nil {
+		t.Errorf("Expected no failure details for TestStable: %+v", stable)
+	}
+}
+
+func TestMergeJSON(t *testing.T) {
+	shard := func(runs int, seeds []int64, msg string) *JSONReport {
+		passed := runs - len(seeds)
+		test := JSONTest{Package: "p", Test: "TestA", Runs: runs, Passed: passed, Failed: len(seeds), MeanDurationMS: float64(runs)}
+		if len(seeds) > 0 {
+			test.FailingSeeds = seeds
+			test.FailureMessages = []string{msg}
+			test.Failures = []JSONFailure{{Message: msg, Seeds: seeds}}
+		}
+		return &JSONReport{Runs: runs, Tests: []JSONTest{test, {Package: "p", Test: "TestB", Runs: runs, Passed: runs}}}
+	}
+	racy := shard(10, []int64{45}, "bang")
+	racy.Tests[0].Categories = map[string]int{"network": 1}
+	crashed := shard(30, []int64{12}, "boom")
+	crashed.Tests[0].Crashes = map[string]int{"SIGKILL": 1}
+	merged := MergeJSON(0.95,
+		shard(10, []int64{7, 3}, "boom"),
+		crashed,
+		racy,
+	)
+
+	if want := (JSONSummary{Tests: 2, Stable: 1, Flaky: 1, Categories: map[string]int{"network": 1}}); merged.Runs != 50 || !reflect.DeepEqual(merged.Summary, want) {
+		t.Fatalf("Unexpected merged summary: runs=%d %+v", merged.Runs, merged.Summary)
+	}
+	a := merged.Tests[0]
+	if a.Test != "TestA" || a.Passed != 46 || a.Failed != 4 || math.Abs(a.FlakeRate-0.08) > 1e-9 || a.Classification != "flaky" {
+		t.Errorf("Unexpected merged TestA: %+v", a)
+	}
+	if a.Categories["network"] != 1 {
+		t.Errorf("Expected the shard's network failure to be kept, got %v", a.Categories)
+	}
+	if !reflect.DeepEqual(a.Crashes, map[string]int{"SIGKILL": 1}) {
+		t.Errorf("Expected the shard's crash to be kept, got %v", a.Crashes)
+	}
+	if a.MeanDurationMS != 22 {
+		t.Errorf("Expected a run-weighted mean duration of 22ms, got %v", a.MeanDurationMS)
+	}
+	want := []JSONFailure{{Message: "boom", Signature: "boom", Count: 3, Seed: 7, Seeds: []int64{3, 7, 12}}, {Message: "bang", Signature: "bang", Count: 1, Seed: 45, Seeds: []int64{45}}}
+	if !reflect.DeepEqual(a.Failures, want) {
+		t.Errorf("Expected failures %+v, got %+v", want, a.Failures)
+	}
+	if !reflect.DeepEqual(a.FailingSeeds, []int64{3, 7, 12, 45}) {
+		t.Errorf("Expected sorted failing seeds, got %v", a.FailingSeeds)
+	}
+}
+
+func TestMergeJSONFingerprint(t *testing.T) {
+	small := &fingerprint.Fingerprint{GOOS: "linux", GOARCH: "amd64", CPUs: 1}
+	large := &fingerprint.Fingerprint{GOOS: "linux", GOARCH: "amd64", CPUs: 16}
+	shard := func(fp *fingerprint.Fingerprint) *JSONReport {
+		return &JSONReport{Runs: 1, Fingerprint: fp}
+	}
+	if fp := MergeJSON(0.95, shard(small), shard(&fingerprint.Fingerprint{GOOS: "linux", GOARCH: "amd64", CPUs: 1})).Fingerprint; fp == nil || fp.CPUs != 1 {
+		t.Errorf("Expected shards of one environment to keep it, got %+v", fp)
+	}
+	for _, shards := range [][]*JSONReport{{shard(small), shard(large)}, {shard(small), shard(nil)}} {
+		if fp := MergeJSON(0.95, shards...).Fingerprint; fp != nil {
+			t.Errorf("Expected shards of different environments to have none, got %+v", fp)
+		}
+	}
+}
+
+func TestMergeJSONOrdersMetaBySeed(t *testing.T) {
+	shard := func(seed int64) *JSONReport {
+		return &JSONReport{Runs: 1, Tests: []JSONTest{{
+			Package: "p", Test: "TestA", Runs: 1, Failed: 1,
+			Meta: []flaky.FailureMeta{{Test: "TestA", Seed: seed, Scenario: "DiskFull"}},
+		}}}
+	}
+	merged := MergeJSON(0.95, shard(9), shard(2), shard(5))
+	var seeds []int64
+	for _, m := range merged.Tests[0].Meta {
+		seeds = append(seeds, m.Seed)
+	}
+	if !reflect.DeepEqual(seeds, []int64{2, 5, 9}) {
+		t.Errorf("Expected the metadata of every shard ordered by seed, got %v", seeds)
+	}
+}
+
+func TestLoadJSONReadsWriteJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, sampleReport(), 0.95, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadJSON(path)
+	if err != nil {
+		t.Fatalf("LoadJSON failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, NewJSONReport(sampleReport(), 0.95, nil)) {
+		t.Errorf("Expected the written report back, got %+v", got)
+	}
+}
+
+func TestSetOwnersSurvivesMerge(t *testing.T) {
+	r := NewJSONReport(sampleReport(), 0.95, nil)
+	r.SetOwners(func(_, test string) []string {
+		if test == "TestFlaky" {
+			return []string{"@payments"}
+		}
+		return nil
+	})
+	merged := MergeJSON(0.95, r, NewJSONReport(sampleReport(), 0.95, nil))
+	for _, tc := range merged.Tests {
+		if want := tc.Test == "TestFlaky"; (len(tc.Owners) == 1 && tc.Owners[0] == "@payments") != want {
+			t.Errorf("%s: unexpected owners %v", tc.Test, tc.Owners)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+// Package report renders detection reports in formats consumed by CI systems
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// The JUnit schema used here is the Maven Surefire rerun extension that
+// Jenkins understands: flaky tests pass with a <flakyFailure> per failing run,
+// consistently failing tests get a <failure> plus a <rerunFailure> per rerun
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Flaky      int             `xml:"flaky,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name          string          `xml:"name,attr"`
+	Classname     string          `xml:"classname,attr"`
+	Time          string          `xml:"time,attr"`
+	Properties    []junitProperty `xml:"properties>property,omitempty"`
+	Failure       *junitFailure   `xml:"failure,omitempty"`
+	Error         *junitFailure   `xml:"error,omitempty"`
+	RerunFailures []junitFailure  `xml:"rerunFailure,omitempty"`
+	FlakyFailures []junitFailure  `xml:"flakyFailure,omitempty"`
+	Skipped       *struct{}       `xml:"skipped,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as one JUnit test case per test with its flake
+// rate, run and retry counts and classification as properties
+// Failures are classified with c (DefaultClassifier when nil): each failure's
+// type is its category, and a failures.<category> property counts them
+func WriteJUnit(w io.Writer, r *runner.Report, c *Classifier) error {
+	c = c.orDefault()
+	categories := c.Categories(r)
+	suites := make(map[string]*junitTestSuite)
+	var root junitTestSuites
+	root.Name = "flakectl"
+
+	for _, stats := range r.Tests {
+		suite := suites[stats.Package]
+		if suite == nil {
+			suite = &junitTestSuite{
+				Name:       stats.Package,
+				Properties: []junitProperty{{Name: "runs", Value: strconv.Itoa(r.Runs)}},
+			}
+			suites[stats.Package] = suite
+		}
+		tc := testCase(r, stats, c, categories[[2]string{stats.Package, stats.Test}])
+		suite.Cases = append(suite.Cases, tc)
+		suite.Tests++
+		switch stats.Classify() {
+		case runner.Failing:
+			suite.Failures++
+		case runner.Flaky:
+			suite.Flaky++
+		case runner.Skipped:
+			suite.Skipped++
+		}
+	}
+
+	names := make([]string, 0, len(suites))
+	for name := range suites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		suite := suites[name]
+		var total float64
+		for _, tc := range suite.Cases {
+			secs, _ := strconv.ParseFloat(tc.Time, 64)
+			total += secs
+		}
+		suite.Time = formatSeconds(total)
+		root.Tests += suite.Tests
+		root.Failures += suite.Failures
+		root.Skipped += suite.Skipped
+		root.Suites = append(root.Suites, *suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func testCase(r *runner.Report, stats *runner.TestStats, c *Classifier, categories map[string]int) junitTestCase {
+	class := stats.Classify()
+	tc := junitTestCase{
+		Name:      stats.Test,
+		Classname: stats.Package,
+		Time:      formatSeconds(stats.MeanDuration().Seconds()),
+		Properties: []junitProperty{
+			{Name: "classification", Value: string(class)},
+			{Name: "flake_rate", Value: strconv.FormatFloat(stats.FlakeRate(), 'f', 4, 64)},
+			{Name: "runs", Value: strconv.Itoa(stats.Runs())},
+			{Name: "passed", Value: strconv.Itoa(stats.Passed)},
+			{Name: "failed", Value: strconv.Itoa(stats.Failed)},
+			{Name: "retries", Value: strconv.Itoa(max(stats.Runs()-1, 0))},
+		},
+	}
+
+	for _, category := range SortedCategories(categories) {
+		tc.Properties = append(tc.Properties, junitProperty{Name: "failures." + category, Value: strconv.Itoa(categories[category])})
+	}
+
+	failures := failingRuns(r, stats, c)
+	switch class {
+	case runner.Skipped:
+		tc.Skipped = &struct{}{}
+	case runner.Flaky:
+		tc.FlakyFailures = failures
+	case runner.Failing:
+		if len(failures) > 0 {
+			tc.Failure = &failures[0]
+			tc.RerunFailures = failures[1:]
+		}
+	}
+	return tc
+}
+
+// failingRuns returns one JUnit failure per failing run of the test
+func failingRuns(r *runner.Report, stats *runner.TestStats, c *Classifier) []junitFailure {
+	var failures []junitFailure
+	for _, result := range r.Results {
+		if result.Package != stats.Package || result.Test != stats.Test || result.Outcome != runner.Fail {
+			continue
+		}
+		message := fmt.Sprintf("failed in run %d", result.Run)
+		if msgs := runner.FailureMessages(result.Output); len(msgs) > 0 {
+			message = msgs[0]
+		}
+		failures = append(failures, junitFailure{
+			Message: message,
+			Type:    c.Classify(result),
+			Body:    fmt.Sprintf("run %d, %s=%d\n%s", result.Run, runner.SeedEnv, result.Seed, strings.TrimRight(result.Output, "\n")),
+		})
+	}
+	return failures
+}
+
+// ReadJUnit reads a JUnit XML document, rooted at <testsuites> or a single
+// <testsuite>, from another CI system and returns one result per attempt of
+// each test case, with the classname as the package
+// Every <failure>, <error>, <rerunFailure> and <flakyFailure> is a failing
+// attempt, and a case whose last attempt did not fail adds a passing one;
+// flakectl's own reports count their passes in a "passed" property instead
+func ReadJUnit(r io.Reader) ([]runner.Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var doc junitTestSuites
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		var suite junitTestSuite
+		if xml.Unmarshal(data, &suite) != nil {
+			return nil, fmt.Errorf("parse JUnit XML: %w", err)
+		}
+		doc.Suites = []junitTestSuite{suite}
+	}
+
+	var results []runner.Result
+	for _, suite := range doc.Suites {
+		for _, tc := range suite.Cases {
+			pkg := tc.Classname
+			if pkg == "" {
+				pkg = suite.Name
+			}
+			secs, _ := strconv.ParseFloat(tc.Time, 64)
+			res := runner.Result{Package: pkg, Test: tc.Name, Duration: time.Duration(secs * float64(time.Second))}
+			if tc.Skipped != nil {
+				res.Outcome = runner.Skip
+				results = append(results, res)
+				continue
+			}
+			var failures []junitFailure
+			for _, f := range []*junitFailure{tc.Failure, tc.Error} {
+				if f != nil {
+					failures = append(failures, *f)
+				}
+			}
+			failures = append(append(failures, tc.RerunFailures...), tc.FlakyFailures...)
+			for _, f := range failures {
+				res.Outcome, res.Output = runner.Fail, f.Message
+				if res.Output == "" {
+					res.Output = f.Body
+				}
+				results = append(results, res)
+			}
+			passed := 0
+			if tc.Failure == nil && tc.Error == nil {
+				passed = 1
+			}
+			for _, p := range tc.Properties {
+				if p.Name == "passed" {
+					passed, _ = strconv.Atoi(p.Value)
+				}
+			}
+			res.Outcome, res.Output = runner.Pass, ""
+			for i := 0; i < passed; i++ {
+				results = append(results, res)
+			}
+		}
+	}
+	return results, nil
+}
+
+func formatSeconds(secs float64) string {
+	return strconv.FormatFloat(secs, 'f', 3, 64)
+}
@@ -0,0 +1,137 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func sampleReport() *runner.Report {
+	return runner.Aggregate(3, []runner.Result{
+		{Package: "p", Test: "TestFlaky", Run: 0, Seed: 1, Outcome: runner.Pass, Duration: 10 * time.Millisecond},
+		{Package: "p", Test: "TestFlaky", Run: 1, Seed: 2, Outcome: runner.Fail, Duration: 20 * time.Millisecond, Output: "    f_test.go:3: got 0.812\n"},
+		{Package: "p", Test: "TestFlaky", Run: 2, Seed: 3, Outcome: runner.Pass, Duration: 30 * time.Millisecond},
+		{Package: "p", Test: "TestBroken", Run: 0, Seed: 1, Outcome: runner.Fail, Output: "    b_test.go:1: boom\n"},
+		{Package: "p", Test: "TestBroken", Run: 1, Seed: 2, Outcome: runner.Fail, Output: "    b_test.go:1: boom\n"},
+		{Package: "p", Test: "TestBroken", Run: 2, Seed: 3, Outcome: runner.Fail, Output: "    b_test.go:1: boom\n"},
+		{Package: "q", Test: "TestStable", Run: 0, Seed: 1, Outcome: runner.Pass},
+	})
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, sampleReport(), nil); err != nil {
+		t.Fatalf("WriteJUnit failed: %v", err)
+	}
+
+	var doc junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Output is not valid XML: %v\n%s", err, buf.String())
+	}
+	if doc.Tests != 3 || doc.Failures != 1 || len(doc.Suites) != 2 {
+		t.Fatalf("Unexpected totals: tests=%d failures=%d suites=%d", doc.Tests, doc.Failures, len(doc.Suites))
+	}
+
+	cases := make(map[string]junitTestCase)
+	for _, suite := range doc.Suites {
+		for _, tc := range suite.Cases {
+			cases[tc.Name] = tc
+		}
+	}
+
+	flaky := cases["TestFlaky"]
+	if flaky.Failure != nil || len(flaky.FlakyFailures) != 1 {
+		t.Errorf("Expected flaky test to pass with one flakyFailure, got %+v", flaky)
+	}
+	if got := property(flaky, "flake_rate"); got != "0.3333" {
+		t.Errorf("Expected flake_rate 0.3333, got %q", got)
+	}
+	if got := property(flaky, "retries"); got != "2" {
+		t.Errorf("Expected 2 retries, got %q", got)
+	}
+	if !strings.Contains(flaky.FlakyFailures[0].Body, "GO_TEST_SEED=2") {
+		t.Errorf("Expected failing seed in failure body, got %q", flaky.FlakyFailures[0].Body)
+	}
+
+	broken := cases["TestBroken"]
+	if broken.Failure == nil || broken.Failure.Type != CategoryAssertion || property(broken, "failures.assertion") != "3" {
+		t.Errorf("Expected 3 assertion failures for TestBroken, got %+v", broken)
+	}
+	if broken.Failure == nil || len(broken.RerunFailures) != 2 || property(broken, "classification") != "failing" {
+		t.Errorf("Expected consistently failing test with failure and 2 reruns, got %+v", broken)
+	}
+	if broken.Failure.Message != "b_test.go:1: boom" {
+		t.Errorf("Unexpected failure message %q", broken.Failure.Message)
+	}
+
+	if stable := cases["TestStable"]; stable.Failure != nil || property(stable, "classification") != "stable" {
+		t.Errorf("Expected stable test without failures, got %+v", stable)
+	}
+}
+
+func property(tc junitTestCase, name string) string {
+	for _, p := range tc.Properties {
+		if p.Name == name {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+func TestReadJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, sampleReport(), nil); err != nil {
+		t.Fatal(err)
+	}
+	results, err := ReadJUnit(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := runner.Aggregate(3, results)
+	want := map[string][2]int{"TestFlaky": {2, 1}, "TestBroken": {0, 3}, "TestStable": {1, 0}}
+	if len(report.Tests) != len(want) {
+		t.Fatalf("Expected %d tests, got %d", len(want), len(report.Tests))
+	}
+	for _, s := range report.Tests {
+		if got := [2]int{s.Passed, s.Failed}; got != want[s.Test] {
+			t.Errorf("Expected %s to pass and fail %v times, got %v", s.Test, want[s.Test], got)
+		}
+	}
+
+	// A single suite from another tool, with errors and a retried case
+	single := `<testsuite name="api">
+  <testcase classname="api.Users" name="test_create" time="0.250"/>
+  <testcase classname="api.Users" name="test_delete"><error message="connection reset"/></testcase>
+  <testcase classname="api.Users" name="test_list"><flakyFailure message="timeout"/></testcase>
+  <testcase name="test_export"><skipped/></testcase>
+</testsuite>`
+	results, err = ReadJUnit(strings.NewReader(single))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, len(results))
+	for i, r := range results {
+		got[i] = r.Package + "." + r.Test + " " + string(r.Outcome) + " " + r.Output
+	}
+	wantResults := []string{
+		"api.Users.test_create pass ",
+		"api.Users.test_delete fail connection reset",
+		"api.Users.test_list fail timeout",
+		"api.Users.test_list pass ",
+		"api.test_export skip ",
+	}
+	if strings.Join(got, "\n") != strings.Join(wantResults, "\n") {
+		t.Errorf("Expected results:\n%s\ngot:\n%s", strings.Join(wantResults, "\n"), strings.Join(got, "\n"))
+	}
+	if results[0].Duration != 250*time.Millisecond {
+		t.Errorf("Expected a duration of 250ms, got %v", results[0].Duration)
+	}
+
+	if _, err := ReadJUnit(strings.NewReader("not xml")); err == nil {
+		t.Error("Expected an error for a document that is not JUnit XML")
+	}
+}
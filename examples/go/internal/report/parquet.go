@@ -0,0 +1,320 @@
+package report
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/example/flaky-test-example/internal/fingerprint"
+)
+
+// WriteParquet writes the records as an uncompressed Parquet file with the
+// columns of WriteCSV: one row group, one PLAIN-encoded page per column
+// Durations are DOUBLE seconds and start times INT64 TIMESTAMP_MICROS in
+// UTC; fields that do not apply are null
+// Parquet has no writer in the standard library, so this one covers only
+// what the records need
+func WriteParquet(w io.Writer, records []Record) error {
+	columns := recordColumns()
+	file := bytes.NewBufferString(parquetMagic)
+	chunks := make([]parquetChunk, len(columns))
+	for i, col := range columns {
+		page := col.page(records)
+		var header compactWriter
+		header.begin()
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.beginStruct(5) // DataPageHeader
+		header.i32(1, int32(len(records)))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.end()
+		header.end()
+
+		chunks[i] = parquetChunk{offset: int64(file.Len()), size: int64(header.Len() + len(page))}
+		file.Write(header.Bytes())
+		file.Write(page)
+	}
+
+	var meta compactWriter
+	meta.begin()
+	meta.i32(1, 1) // version
+	meta.list(2, thriftStruct, len(columns)+1)
+	meta.begin()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(columns)))
+	meta.end()
+	for _, col := range columns {
+		meta.begin()
+		meta.i32(1, col.typ)
+		repetition := int32(0) // REQUIRED
+		if col.optional {
+			repetition = 1 // OPTIONAL
+		}
+		meta.i32(3, repetition)
+		meta.binary(4, col.name)
+		if col.converted >= 0 {
+			meta.i32(6, col.converted)
+		}
+		meta.end()
+	}
+	meta.i64(3, int64(len(records)))
+	meta.list(4, thriftStruct, 1)
+	meta.begin() // RowGroup
+	meta.list(1, thriftStruct, len(columns))
+	var total int64
+	for i, col := range columns {
+		chunk := chunks[i]
+		total += chunk.size
+		meta.begin() // ColumnChunk
+		meta.i64(2, chunk.offset)
+		meta.beginStruct(3) // ColumnMetaData
+		meta.i32(1, col.typ)
+		meta.list(2, thriftI32, 2)
+		meta.varint(encodingPlain)
+		meta.varint(encodingRLE)
+		meta.list(3, thriftBinary, 1)
+		meta.str(col.name)
+		meta.i32(4, 0) // UNCOMPRESSED
+		meta.i64(5, int64(len(records)))
+		meta.i64(6, chunk.size)
+		meta.i64(7, chunk.size)
+		meta.i64(9, chunk.offset)
+		meta.end()
+		meta.end()
+	}
+	meta.i64(2, total)
+	meta.i64(3, int64(len(records)))
+	meta.end()
+	meta.binary(6, "flakectl")
+	meta.end()
+
+	file.Write(meta.Bytes())
+	binary.Write(file, binary.LittleEndian, uint32(meta.Len()))
+	file.WriteString(parquetMagic)
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+const parquetMagic = "PAR1"
+
+// Parquet physical types, converted types and encodings
+const (
+	parquetInt32     int32 = 1
+	parquetInt64     int32 = 2
+	parquetDouble    int32 = 5
+	parquetByteArray int32 = 6
+
+	convertedNone            int32 = -1
+	convertedUTF8            int32 = 0
+	convertedTimestampMicros int32 = 10
+
+	encodingPlain = 0
+	encodingRLE   = 3
+)
+
+type parquetChunk struct {
+	offset, size int64
+}
+
+// parquetColumn is one column of the file; value returns a record's value,
+// an int32, int64, float64 or string, and false for a null
+type parquetColumn struct {
+	name      string
+	typ       int32
+	converted int32
+	optional  bool
+	value     func(Record) (any, bool)
+}
+
+// recordColumns returns the columns of a Record, named as in csvHeader
+func recordColumns() []parquetColumn {
+	required := func(name string, typ, converted int32, value func(Record) any) parquetColumn {
+		return parquetColumn{name: name, typ: typ, converted: converted, value: func(r Record) (any, bool) { return value(r), true }}
+	}
+	nullable := func(name string, typ, converted int32, value func(Record) (any, bool)) parquetColumn {
+		return parquetColumn{name: name, typ: typ, converted: converted, optional: true, value: value}
+	}
+	optional := func(name string, value func(Record) string) parquetColumn {
+		return nullable(name, parquetByteArray, convertedUTF8, func(r Record) (any, bool) {
+			v := value(r)
+			return v, v != ""
+		})
+	}
+	// fingerprinted leaves a column null for a run in an unknown
+	// environment
+	fingerprinted := func(name string, value func(fingerprint.Fingerprint) string) parquetColumn {
+		return optional(name, func(r Record) string {
+			if r.Fingerprint.GOOS == "" {
+				return ""
+			}
+			return value(r.Fingerprint)
+		})
+	}
+	return []parquetColumn{
+		required("package", parquetByteArray, convertedUTF8, func(r Record) any { return r.Package }),
+		required("test", parquetByteArray, convertedUTF8, func(r Record) any { return r.Test }),
+		required("run", parquetInt32, convertedNone, func(r Record) any { return int32(r.Run) }),
+		required("seed", parquetInt64, convertedNone, func(r Record) any { return r.Seed }),
+		required("outcome", parquetByteArray, convertedUTF8, func(r Record) any { return string(r.Outcome) }),
+		required("duration_seconds", parquetDouble, convertedNone, func(r Record) any { return r.Duration.Seconds() }),
+		nullable("start", parquetInt64, convertedTimestampMicros, func(r Record) (any, bool) {
+			return r.Start.UnixMicro(), !r.Start.IsZero()
+		}),
+		optional("failure_kind", func(r Record) string { return string(r.Kind) }),
+		optional("failure_class", func(r Record) string { return r.Class }),
+		optional("message", func(r Record) string { return r.Message }),
+		optional("commit", func(r Record) string { return r.Commit }),
+		fingerprinted("fingerprint", fingerprint.Fingerprint.ID),
+		fingerprinted("goos", func(fp fingerprint.Fingerprint) string { return fp.GOOS }),
+		fingerprinted("goarch", func(fp fingerprint.Fingerprint) string { return fp.GOARCH }),
+		fingerprinted("go_version", func(fp fingerprint.Fingerprint) string { return fp.GoVersion }),
+		nullable("cpus", parquetInt32, convertedNone, func(r Record) (any, bool) {
+			return int32(r.Fingerprint.CPUs), r.Fingerprint.CPUs > 0
+		}),
+		nullable("cpu_limit", parquetDouble, convertedNone, func(r Record) (any, bool) {
+			return r.Fingerprint.CPULimit, r.Fingerprint.CPULimit > 0
+		}),
+		nullable("memory_limit_bytes", parquetInt64, convertedNone, func(r Record) (any, bool) {
+			return r.Fingerprint.MemoryLimit, r.Fingerprint.MemoryLimit > 0
+		}),
+		fingerprinted("env", fingerprint.Fingerprint.EnvString),
+	}
+}
+
+// page encodes the column's values of every record: the definition levels
+// of an optional column, then the PLAIN values that are not null
+func (col parquetColumn) page(records []Record) []byte {
+	var values bytes.Buffer
+	levels := make([]bool, len(records))
+	for i, rec := range records {
+		v, ok := col.value(rec)
+		levels[i] = ok
+		if !ok {
+			continue
+		}
+		switch v := v.(type) {
+		case int32:
+			binary.Write(&values, binary.LittleEndian, v)
+		case int64:
+			binary.Write(&values, binary.LittleEndian, v)
+		case float64:
+			binary.Write(&values, binary.LittleEndian, math.Float64bits(v))
+		case string:
+			binary.Write(&values, binary.LittleEndian, uint32(len(v)))
+			values.WriteString(v)
+		}
+	}
+	if !col.optional {
+		return values.Bytes()
+	}
+	rle := definitionLevels(levels)
+	page := binary.LittleEndian.AppendUint32(nil, uint32(len(rle)))
+	return append(append(page, rle...), values.Bytes()...)
+}
+
+// definitionLevels RLE-encodes the levels of an optional column at bit
+// width 1: a run header of count<<1, then the level in one byte
+func definitionLevels(present []bool) []byte {
+	var out []byte
+	for i := 0; i < len(present); {
+		j := i
+		for j < len(present) && present[j] == present[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		level := byte(0)
+		if present[i] {
+			level = 1
+		}
+		out = append(out, level)
+		i = j
+	}
+	return out
+}
+
+// Thrift compact protocol types used by the Parquet metadata
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// compactWriter writes Thrift compact protocol structs, which is how
+// Parquet encodes page headers and the file footer
+type compactWriter struct {
+	bytes.Buffer
+	last  int16
+	stack []int16
+}
+
+// begin opens a struct at the top level or as a list element
+func (w *compactWriter) begin() {
+	w.stack = append(w.stack, w.last)
+	w.last = 0
+}
+
+// beginStruct opens a struct-valued field
+func (w *compactWriter) beginStruct(id int16) {
+	w.field(id, thriftStruct)
+	w.begin()
+}
+
+// end closes the innermost struct
+func (w *compactWriter) end() {
+	w.WriteByte(0)
+	w.last = w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+}
+
+func (w *compactWriter) field(id int16, typ byte) {
+	if delta := id - w.last; delta > 0 && delta <= 15 {
+		w.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.WriteByte(typ)
+		w.varint(int64(id))
+	}
+	w.last = id
+}
+
+func (w *compactWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.varint(int64(v))
+}
+
+func (w *compactWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.varint(v)
+}
+
+func (w *compactWriter) binary(id int16, s string) {
+	w.field(id, thriftBinary)
+	w.str(s)
+}
+
+// list opens a list field of n elements, which follow as varints, strs or
+// begin-end structs
+func (w *compactWriter) list(id int16, elem byte, n int) {
+	w.field(id, thriftList)
+	if n < 15 {
+		w.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+	w.WriteByte(0xf0 | elem)
+	w.Write(binary.AppendUvarint(nil, uint64(n)))
+}
+
+// varint writes a zigzag varint, the encoding of every integer
+func (w *compactWriter) varint(v int64) {
+	w.Write(binary.AppendUvarint(nil, uint64(v<<1)^uint64(v>>63)))
+}
+
+func (w *compactWriter) str(s string) {
+	w.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	w.WriteString(s)
+}
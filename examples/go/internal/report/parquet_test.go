@@ -0,0 +1,245 @@
+package report
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/fingerprint"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// thriftStructValue is a decoded Thrift struct, keyed by field id
+type thriftStructValue map[int16]any
+
+// compactReader decodes the Thrift compact protocol structs WriteParquet
+// writes, just far enough to check them
+type compactReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *compactReader) byte() byte {
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *compactReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		panic(fmt.Sprintf("bad varint at %d", r.pos))
+	}
+	r.pos += n
+	return v
+}
+
+func (r *compactReader) varint() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *compactReader) value(typ byte) any {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		n := int(r.uvarint())
+		s := string(r.data[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case thriftList:
+		header := r.byte()
+		n, elem := int(header>>4), header&0x0f
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = r.value(elem)
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic(fmt.Sprintf("unexpected thrift type %d at %d", typ, r.pos))
+}
+
+func (r *compactReader) readStruct() thriftStructValue {
+	s := make(thriftStructValue)
+	var last int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return s
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.varint())
+		}
+		s[id] = r.value(header & 0x0f)
+		last = id
+	}
+}
+
+func (s thriftStructValue) int(id int16) int64 {
+	v, _ := s[id].(int64)
+	return v
+}
+
+func (s thriftStructValue) list(id int16) []any {
+	v, _ := s[id].([]any)
+	return v
+}
+
+// readParquet reads back the file WriteParquet wrote, returning its footer
+// and every column's values, nil for a null
+func readParquet(t *testing.T, data []byte) (thriftStructValue, map[string][]any) {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte(parquetMagic)) || !bytes.HasSuffix(data, []byte(parquetMagic)) {
+		t.Fatalf("Expected the file to start and end with %s", parquetMagic)
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := (&compactReader{data: data[len(data)-8-footerLen : len(data)-8]}).readStruct()
+	rows := int(footer.int(3))
+
+	schema := footer.list(2)
+	columns := make(map[string][]any)
+	chunks := footer.list(4)[0].(thriftStructValue).list(1)
+	for i, chunk := range chunks {
+		field := schema[i+1].(thriftStructValue)
+		meta := chunk.(thriftStructValue)[3].(thriftStructValue)
+		r := &compactReader{data: data, pos: int(meta.int(9))}
+		header := r.readStruct()
+		if got := header.int(3); int64(r.pos)+got-meta.int(9) != meta.int(7) {
+			t.Fatalf("Column %s: chunk size %d does not match its page", field[4], meta.int(7))
+		}
+		page := &compactReader{data: data[r.pos : r.pos+int(header.int(3))]}
+
+		present := make([]bool, rows)
+		for j := range present {
+			present[j] = true
+		}
+		if field.int(3) == 1 {
+			levels := int(binary.LittleEndian.Uint32(page.data))
+			page.pos = 4
+			for j := 0; page.pos < 4+levels; {
+				n := int(page.uvarint() >> 1)
+				level := page.byte()
+				for ; n > 0; n-- {
+					present[j] = level == 1
+					j++
+				}
+			}
+		}
+		values := make([]any, rows)
+		for j := range values {
+			if !present[j] {
+				continue
+			}
+			rest := page.data[page.pos:]
+			switch int32(field.int(1)) {
+			case parquetInt32:
+				values[j] = int32(binary.LittleEndian.Uint32(rest))
+				page.pos += 4
+			case parquetInt64:
+				values[j] = int64(binary.LittleEndian.Uint64(rest))
+				page.pos += 8
+			case parquetDouble:
+				values[j] = math.Float64frombits(binary.LittleEndian.Uint64(rest))
+				page.pos += 8
+			case parquetByteArray:
+				n := int(binary.LittleEndian.Uint32(rest))
+				values[j] = string(rest[4 : 4+n])
+				page.pos += 4 + n
+			}
+		}
+		if page.pos != len(page.data) {
+			t.Fatalf("Column %s: %d bytes left over in its page", field[4], len(page.data)-page.pos)
+		}
+		columns[field[4].(string)] = values
+	}
+	return footer, columns
+}
+
+func TestWriteParquet(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fp := fingerprint.Fingerprint{GOOS: "linux", GOARCH: "amd64", CPUs: 4, MemoryLimit: 1 << 30, Env: map[string]string{"GOMAXPROCS": "1"}}
+	records := []Record{
+		{Package: "p", Test: "TestA", Run: 0, Seed: 7, Outcome: runner.Pass, Duration: 1500 * time.Millisecond, Start: start, Commit: "abc", Fingerprint: fp},
+		{Package: "p", Test: "TestA", Run: 1, Seed: -8, Outcome: runner.Fail, Kind: runner.Panic, Class: "panic", Message: "boom", Commit: "abc"},
+		{Package: "q", Test: "TestB", Run: 2, Seed: 1 << 40, Outcome: runner.Skip},
+	}
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, records); err != nil {
+		t.Fatalf("WriteParquet failed: %v", err)
+	}
+	footer, columns := readParquet(t, buf.Bytes())
+
+	if footer.int(3) != 3 || footer[6] != "flakectl" {
+		t.Errorf("Expected 3 rows created by flakectl, got %v", footer)
+	}
+	schema := footer.list(2)
+	if len(schema) != len(csvHeader)+1 || schema[0].(thriftStructValue).int(5) != int64(len(csvHeader)) {
+		t.Fatalf("Expected a root and %d columns, got %v", len(csvHeader), schema)
+	}
+	for i, name := range csvHeader {
+		if got := schema[i+1].(thriftStructValue)[4]; got != name {
+			t.Errorf("Expected column %d to be %s as in the CSV, got %v", i, name, got)
+		}
+	}
+
+	want := map[string][]any{
+		"package":          {"p", "p", "q"},
+		"test":             {"TestA", "TestA", "TestB"},
+		"run":              {int32(0), int32(1), int32(2)},
+		"seed":             {int64(7), int64(-8), int64(1 << 40)},
+		"outcome":          {"pass", "fail", "skip"},
+		"duration_seconds": {1.5, 0.0, 0.0},
+		"start":            {start.UnixMicro(), nil, nil},
+		"failure_kind":     {nil, "panic", nil},
+		"failure_class":    {nil, "panic", nil},
+		"message":          {nil, "boom", nil},
+		"commit":           {"abc", "abc", nil},
+
+		"fingerprint":        {fp.ID(), nil, nil},
+		"goos":               {"linux", nil, nil},
+		"go_version":         {nil, nil, nil},
+		"cpus":               {int32(4), nil, nil},
+		"cpu_limit":          {nil, nil, nil},
+		"memory_limit_bytes": {int64(1 << 30), nil, nil},
+		"env":                {"GOMAXPROCS=1", nil, nil},
+	}
+	for name, values := range want {
+		if got := fmt.Sprint(columns[name]); got != fmt.Sprint(values) {
+			t.Errorf("Expected column %s to be %v, got %s", name, values, got)
+		}
+	}
+}
+
+func TestWriteParquetNullRuns(t *testing.T) {
+	records := make([]Record, 100)
+	for i := range records {
+		records[i] = Record{Package: "p", Test: "TestA", Run: i, Outcome: runner.Pass}
+		if i%3 == 0 {
+			records[i].Message = strings.Repeat("x", i+1)
+		}
+	}
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, records); err != nil {
+		t.Fatalf("WriteParquet failed: %v", err)
+	}
+	_, columns := readParquet(t, buf.Bytes())
+	for i, v := range columns["message"] {
+		if (i%3 == 0) != (v != nil) {
+			t.Fatalf("Expected message %d to be null only when empty, got %v", i, v)
+		}
+	}
+	if got := columns["run"][99]; got != int32(99) {
+		t.Errorf("Expected the last run to be 99, got %v", got)
+	}
+}
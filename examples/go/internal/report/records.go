@@ -0,0 +1,109 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/fingerprint"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// Record is one run of one test, flattened for analysis outside flakectl,
+// as written by WriteCSV and WriteParquet
+type Record struct {
+	Package string
+	Test    string
+	Run     int
+	Seed    int64
+	Outcome runner.Outcome
+	// Duration is the test's own run time, Start when it started or zero
+	// when go test did not say
+	Duration time.Duration
+	Start    time.Time
+	// Kind, Class and Message describe a failing run: how it failed, its
+	// category from the classifier and its first failure message
+	Kind    runner.FailureKind
+	Class   string
+	Message string
+	// Commit is the commit the suite ran at, empty outside a git checkout
+	Commit string
+	// Fingerprint is the environment the run ran in, zero when unknown
+	Fingerprint fingerprint.Fingerprint
+}
+
+// Records flattens every run of the report, classifying failures with c
+// (DefaultClassifier when nil)
+func Records(r *runner.Report, c *Classifier, commit string) []Record {
+	c = c.orDefault()
+	records := make([]Record, 0, len(r.Results))
+	for _, result := range r.Results {
+		rec := Record{
+			Package:  result.Package,
+			Test:     result.Test,
+			Run:      result.Run,
+			Seed:     result.Seed,
+			Outcome:  result.Outcome,
+			Duration: result.Duration,
+			Start:    result.Start,
+			Commit:   commit,
+
+			Fingerprint: r.Fingerprint,
+		}
+		if result.Outcome == runner.Fail {
+			rec.Kind = result.Kind
+			if rec.Kind == "" {
+				rec.Kind = runner.Assertion
+			}
+			rec.Class = c.Classify(result)
+			if msgs := runner.FailureMessages(result.Output); len(msgs) > 0 {
+				rec.Message = msgs[0]
+			}
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+// csvHeader names the columns of WriteCSV, which WriteParquet shares
+var csvHeader = []string{
+	"package", "test", "run", "seed", "outcome", "duration_seconds", "start", "failure_kind", "failure_class", "message", "commit",
+	"fingerprint", "goos", "goarch", "go_version", "cpus", "cpu_limit", "memory_limit_bytes", "env",
+}
+
+// WriteCSV writes one row per record under a header row
+// Durations are in seconds and start times in RFC 3339; fields that do not
+// apply, such as the failure of a passing run or the fingerprint of a run
+// in an unknown environment, are empty
+func WriteCSV(w io.Writer, records []Record) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, rec := range records {
+		start := ""
+		if !rec.Start.IsZero() {
+			start = rec.Start.UTC().Format(time.RFC3339Nano)
+		}
+		row := []string{
+			rec.Package, rec.Test, strconv.Itoa(rec.Run), strconv.FormatInt(rec.Seed, 10), string(rec.Outcome),
+			strconv.FormatFloat(rec.Duration.Seconds(), 'f', -1, 64), start,
+			string(rec.Kind), rec.Class, rec.Message, rec.Commit,
+		}
+		if fp := rec.Fingerprint; fp.GOOS != "" {
+			cpus := ""
+			if fp.CPUs > 0 {
+				cpus = strconv.Itoa(fp.CPUs)
+			}
+			row = append(row, fp.ID(), fp.GOOS, fp.GOARCH, fp.GoVersion, cpus, fp.Field("cpu_limit"), fp.Field("memory_limit"), fp.EnvString())
+		} else {
+			row = append(row, make([]string, 8)...)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
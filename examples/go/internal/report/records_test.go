@@ -0,0 +1,73 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/fingerprint"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestRecords(t *testing.T) {
+	report := sampleReport()
+	report.Fingerprint = fingerprint.Fingerprint{GOOS: "linux", GOARCH: "amd64", CPUs: 1}
+	records := Records(report, nil, "abc123")
+	if len(records) != 7 {
+		t.Fatalf("Expected one record per run, got %d", len(records))
+	}
+	passed, failed := records[0], records[1]
+	if passed.Test != "TestFlaky" || passed.Outcome != runner.Pass || passed.Kind != "" || passed.Class != "" || passed.Message != "" {
+		t.Errorf("Expected a passing run without failure fields, got %+v", passed)
+	}
+	if failed.Run != 1 || failed.Seed != 2 || failed.Duration != 20*time.Millisecond {
+		t.Errorf("Unexpected failing run: %+v", failed)
+	}
+	if failed.Kind != runner.Assertion || failed.Class != CategoryAssertion || failed.Message != "f_test.go:3: got 0.812" {
+		t.Errorf("Expected the failure to be described, got %+v", failed)
+	}
+	for _, rec := range records {
+		if rec.Commit != "abc123" || rec.Fingerprint.CPUs != 1 {
+			t.Errorf("Expected every record at commit abc123 on 1 CPU, got %+v", rec)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 500, time.FixedZone("CEST", 2*60*60))
+	fp := fingerprint.Fingerprint{GOOS: "linux", GOARCH: "amd64", GoVersion: "go1.22.5", CPUs: 4, CPULimit: 0.5, Env: map[string]string{"GOMAXPROCS": "1", "CI": "true"}}
+	records := []Record{
+		{Package: "p", Test: "TestA", Run: 0, Seed: 7, Outcome: runner.Pass, Duration: 1500 * time.Millisecond, Start: start},
+		{Package: "p", Test: "TestA", Run: 1, Seed: 8, Outcome: runner.Fail, Kind: runner.Panic, Class: "panic", Message: `say "hi", then fail`,
+			Fingerprint: fp},
+	}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, records); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Output is not valid CSV: %v", err)
+	}
+	want := [][]string{
+		csvHeader,
+		{"p", "TestA", "0", "7", "pass", "1.5", "2024-05-01T10:00:00.0000005Z", "", "", "", "", "", "", "", "", "", "", "", ""},
+		{"p", "TestA", "1", "8", "fail", "0", "", "panic", "panic", `say "hi", then fail`, "",
+			fp.ID(), "linux", "amd64", "go1.22.5", "4", "0.5", "", "CI=true GOMAXPROCS=1"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("Expected %d rows, got %d", len(want), len(rows))
+	}
+	for i := range want {
+		if len(rows[i]) != len(want[i]) {
+			t.Fatalf("Expected row %d to be %q, got %q", i, want[i], rows[i])
+		}
+		for j := range want[i] {
+			if rows[i][j] != want[i][j] {
+				t.Errorf("Expected row %d to be %q, got %q", i, want[i], rows[i])
+				break
+			}
+		}
+	}
+}
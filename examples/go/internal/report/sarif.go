@@ -0,0 +1,322 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/example/flaky-test-example/internal/runner"
+	"github.com/example/flaky-test-example/stats"
+)
+
+// The SARIF written here is the 2.1.0 subset GitHub code scanning ingests:
+// one result per flaky or failing test, located at the test's declaration,
+// with the line of its most common failure as a related location
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	// sarifSrcRoot is the base relative locations resolve against; GitHub
+	// maps it to the root of the checkout
+	sarifSrcRoot = "%SRCROOT%"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	FullDescription      sarifMessage       `json:"fullDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations,omitempty"`
+	RelatedLocations    []sarifLocation   `json:"relatedLocations,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Properties          sarifProperties   `json:"properties"`
+}
+
+type sarifLocation struct {
+	ID               int                   `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifProperties struct {
+	Package        string         `json:"package"`
+	Test           string         `json:"test"`
+	Classification string         `json:"classification"`
+	Runs           int            `json:"runs"`
+	Failed         int            `json:"failed"`
+	FlakeRate      float64        `json:"flakeRate"`
+	FlakeRateCI    [2]float64     `json:"flakeRateCI"`
+	FailingSeeds   []int64        `json:"failingSeeds,omitempty"`
+	Categories     map[string]int `json:"categories,omitempty"`
+}
+
+// SARIF rule IDs, one per classification that is worth an alert
+const (
+	RuleFlakyTest   = "flaky-test"
+	RuleFailingTest = "failing-test"
+)
+
+var sarifRules = []sarifRule{
+	{
+		ID:                   RuleFlakyTest,
+		Name:                 "FlakyTest",
+		ShortDescription:     sarifMessage{"Test fails intermittently"},
+		FullDescription:      sarifMessage{"The test both passed and failed across runs of the same code with different seeds."},
+		DefaultConfiguration: sarifConfiguration{"warning"},
+	},
+	{
+		ID:                   RuleFailingTest,
+		Name:                 "FailingTest",
+		ShortDescription:     sarifMessage{"Test fails in every run"},
+		FullDescription:      sarifMessage{"The test failed in every run of the detection sweep."},
+		DefaultConfiguration: sarifConfiguration{"error"},
+	},
+}
+
+// Location is where a test is declared, with File relative to the root of
+// the checkout and slash-separated
+type Location struct {
+	File string
+	Line int
+}
+
+// Locator returns where the test of a package is declared, if it knows
+type Locator func(pkg, test string) (Location, bool)
+
+// WriteSARIF writes the report as a SARIF log with one result per flaky or
+// failing test, carrying its flake rate and Wilson interval at confidence and
+// its most common failure message
+// Failures are classified with c (DefaultClassifier when nil); results are
+// located with locate, and a nil locate or a test it does not know leaves
+// the result without a location, which GitHub code scanning drops
+func WriteSARIF(w io.Writer, r *runner.Report, confidence float64, c *Classifier, locate Locator) error {
+	c = c.orDefault()
+	failures := FailureSignatures(r.Results, c)
+	categories := c.Categories(r)
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: "flakectl", Rules: sarifRules}},
+		Results: []sarifResult{},
+	}
+	for _, s := range r.Tests {
+		class := s.Classify()
+		var rule, level string
+		switch class {
+		case runner.Flaky:
+			rule, level = RuleFlakyTest, "warning"
+		case runner.Failing:
+			rule, level = RuleFailingTest, "error"
+		default:
+			continue
+		}
+		if s.FailsThroughSubtests() {
+			// Its failing subtests are alerted on instead
+			continue
+		}
+		key := [2]string{s.Package, s.Test}
+		est := stats.EstimateCounts(s.Passed, s.Failed, confidence)
+		failure := representative(failures[key])
+		res := sarifResult{
+			RuleID:  rule,
+			Level:   level,
+			Message: sarifMessage{sarifText(s, est, confidence, failure.Message)},
+			// The fingerprint keeps one alert per test as its code moves
+			PartialFingerprints: map[string]string{"flakyTest/v1": s.Package + "." + s.Test},
+			Properties: sarifProperties{
+				Package:        s.Package,
+				Test:           s.Test,
+				Classification: string(class),
+				Runs:           s.Runs(),
+				Failed:         s.Failed,
+				FlakeRate:      s.FlakeRate(),
+				FlakeRateCI:    [2]float64{est.Wilson.Lower, est.Wilson.Upper},
+				FailingSeeds:   s.FailingSeeds,
+				Categories:     categories[key],
+			},
+		}
+		if loc, ok := lookup(locate, s.Package, s.Test); ok {
+			res.Locations = []sarifLocation{{PhysicalLocation: physical(loc)}}
+			if line, ok := failureLocation(loc, failure.Message); ok {
+				res.RelatedLocations = []sarifLocation{{ID: 1, PhysicalLocation: physical(line), Message: &sarifMessage{"Most common failure"}}}
+			}
+		}
+		run.Results = append(run.Results, res)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
+
+// representative returns the failure that the most seeds share, the first
+// seen among equals
+func representative(failures []JSONFailure) JSONFailure {
+	var best JSONFailure
+	for _, f := range failures {
+		if len(f.Seeds) > len(best.Seeds) {
+			best = f
+		}
+	}
+	return best
+}
+
+func sarifText(s *runner.TestStats, est stats.Estimate, confidence float64, failure string) string {
+	text := fmt.Sprintf("%s failed %d of %d runs (%.1f%% flake rate, %.0f%% CI %.1f%%-%.1f%%)",
+		s.Test, s.Failed, s.Runs(), 100*s.FlakeRate(), 100*confidence, 100*est.Wilson.Lower, 100*est.Wilson.Upper)
+	if failure != "" {
+		text += ". Most common failure: " + failure
+	}
+	return text
+}
+
+func physical(loc Location) sarifPhysicalLocation {
+	return sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: loc.File, URIBaseID: sarifSrcRoot},
+		Region:           sarifRegion{StartLine: loc.Line},
+	}
+}
+
+// failurePosition matches the file:line go test puts before a logged message
+var failurePosition = regexp.MustCompile(`^([\w.-]+\.go):(\d+): `)
+
+// failureLocation returns where a message such as "f_test.go:3: got 0.812"
+// was logged, taking the file to sit next to the test at loc
+func failureLocation(loc Location, message string) (Location, bool) {
+	m := failurePosition.FindStringSubmatch(message)
+	if m == nil {
+		return Location{}, false
+	}
+	line, _ := strconv.Atoi(m[2])
+	return Location{File: path.Join(path.Dir(loc.File), m[1]), Line: line}, true
+}
+
+// LocateTests lists the packages matching patterns in dir with go list and
+// finds the declaration of every test function in their test files
+// Files are made relative to the root of the git checkout containing dir,
+// or to dir outside one; a subtest is located at its top-level test
+func LocateTests(ctx context.Context, dir string, patterns []string) (Locator, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	cmd := exec.CommandContext(ctx, "go", append([]string{"list", "-json=ImportPath,Dir,TestGoFiles,XTestGoFiles"}, patterns...)...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list %s: %w\n%s", strings.Join(patterns, " "), err, stderr.String())
+	}
+	root, err := checkoutRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	locations := make(map[[2]string]Location)
+	fset := token.NewFileSet()
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for dec.More() {
+		var p struct {
+			ImportPath, Dir           string
+			TestGoFiles, XTestGoFiles []string
+		}
+		if err := dec.Decode(&p); err != nil {
+			return nil, fmt.Errorf("go list: %w", err)
+		}
+		for _, name := range append(p.TestGoFiles, p.XTestGoFiles...) {
+			file := filepath.Join(p.Dir, name)
+			f, err := parser.ParseFile(fset, file, nil, parser.SkipObjectResolution)
+			if err != nil {
+				return nil, err
+			}
+			rel, err := filepath.Rel(root, file)
+			if err != nil {
+				return nil, err
+			}
+			for _, decl := range f.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Recv != nil || !strings.HasPrefix(fn.Name.Name, "Test") {
+					continue
+				}
+				locations[[2]string{p.ImportPath, fn.Name.Name}] = Location{File: filepath.ToSlash(rel), Line: fset.Position(fn.Pos()).Line}
+			}
+		}
+	}
+	return func(pkg, test string) (Location, bool) {
+		top, _, _ := strings.Cut(test, "/")
+		loc, ok := locations[[2]string{pkg, top}]
+		return loc, ok
+	}, nil
+}
+
+// checkoutRoot returns the top of the git checkout containing dir, or dir
+// itself outside one
+func checkoutRoot(dir string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	if out, err := cmd.Output(); err == nil {
+		return strings.TrimSpace(string(out)), nil
+	}
+	if dir == "" {
+		dir = "."
+	}
+	return filepath.Abs(dir)
+}
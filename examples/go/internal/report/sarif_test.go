@@ -0,0 +1,134 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	locate := func(pkg, test string) (Location, bool) {
+		if test == "TestFlaky" {
+			return Location{File: "p/f_test.go", Line: 12}, true
+		}
+		return Location{}, false
+	}
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, sampleReport(), 0.95, nil, locate); err != nil {
+		t.Fatalf("WriteSARIF failed: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Tool.Driver.Rules) != 2 {
+		t.Fatalf("Unexpected log header: %+v", log)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 2 {
+		t.Fatalf("Expected results for the flaky and failing tests only, got %+v", results)
+	}
+
+	byTest := make(map[string]sarifResult)
+	for _, r := range results {
+		byTest[r.Properties.Test] = r
+	}
+	flaky := byTest["TestFlaky"]
+	if flaky.RuleID != RuleFlakyTest || flaky.Level != "warning" {
+		t.Errorf("Expected a flaky-test warning, got %s %s", flaky.RuleID, flaky.Level)
+	}
+	if !strings.Contains(flaky.Message.Text, "failed 1 of 3 runs (33.3% flake rate") || !strings.Contains(flaky.Message.Text, "got 0.812") {
+		t.Errorf("Expected flake rate and failure in message, got %q", flaky.Message.Text)
+	}
+	if len(flaky.Locations) != 1 || flaky.Locations[0].PhysicalLocation.ArtifactLocation.URI != "p/f_test.go" || flaky.Locations[0].PhysicalLocation.Region.StartLine != 12 {
+		t.Errorf("Expected location p/f_test.go:12, got %+v", flaky.Locations)
+	}
+	if len(flaky.RelatedLocations) != 1 || flaky.RelatedLocations[0].PhysicalLocation.Region.StartLine != 3 {
+		t.Errorf("Expected the failure at p/f_test.go:3 as related location, got %+v", flaky.RelatedLocations)
+	}
+
+	broken := byTest["TestBroken"]
+	if broken.RuleID != RuleFailingTest || broken.Level != "error" || broken.Properties.Categories[CategoryAssertion] != 3 {
+		t.Errorf("Expected a failing-test error with 3 assertion failures, got %+v", broken)
+	}
+	if len(broken.Locations) != 0 {
+		t.Errorf("Expected no location for a test the locator does not know, got %+v", broken.Locations)
+	}
+	if broken.PartialFingerprints["flakyTest/v1"] != "p.TestBroken" {
+		t.Errorf("Expected fingerprint p.TestBroken, got %v", broken.PartialFingerprints)
+	}
+}
+
+func TestSubtestGranularity(t *testing.T) {
+	r := runner.Aggregate(2, []runner.Result{
+		{Package: "p", Test: "TestTable", Run: 0, Seed: 1, Outcome: runner.Fail},
+		{Package: "p", Test: "TestTable/ok", Run: 0, Seed: 1, Outcome: runner.Pass},
+		{Package: "p", Test: "TestTable/flaky", Run: 0, Seed: 1, Outcome: runner.Fail, Output: "    t_test.go:9: stale\n"},
+		{Package: "p", Test: "TestTable", Run: 1, Seed: 2, Outcome: runner.Pass},
+		{Package: "p", Test: "TestTable/ok", Run: 1, Seed: 2, Outcome: runner.Pass},
+		{Package: "p", Test: "TestTable/flaky", Run: 1, Seed: 2, Outcome: runner.Pass},
+	})
+
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, r, 0.95, nil, nil); err != nil {
+		t.Fatalf("WriteSARIF failed: %v", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+	if results := log.Runs[0].Results; len(results) != 1 || results[0].Properties.Test != "TestTable/flaky" {
+		t.Errorf("Expected an alert for the flaky case only, got %+v", results)
+	}
+
+	tests := NewJSONReport(r, 0.95, nil).Tests
+	if tests[0].Test != "TestTable" || tests[0].SubtestFailures != 1 || tests[0].Parent != "" {
+		t.Errorf("Expected TestTable with 1 subtest failure, got %+v", tests[0])
+	}
+	if tests[1].Test != "TestTable/flaky" || tests[1].Parent != "TestTable" {
+		t.Errorf("Expected TestTable/flaky under TestTable, got %+v", tests[1])
+	}
+}
+
+func TestLocateTests(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/located\n\ngo 1.22\n")
+	write("sub/sub.go", "package sub\n")
+	write("sub/sub_test.go", "package sub\n\nimport \"testing\"\n\nfunc helper() {}\n\nfunc TestInternal(t *testing.T) {}\n")
+	write("sub/ext_test.go", "package sub_test\n\nimport \"testing\"\n\nfunc TestExternal(t *testing.T) {}\n")
+
+	locate, err := LocateTests(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatalf("LocateTests failed: %v", err)
+	}
+	for _, tc := range []struct {
+		test string
+		want Location
+	}{
+		{"TestInternal", Location{File: "sub/sub_test.go", Line: 7}},
+		{"TestExternal/case_1", Location{File: "sub/ext_test.go", Line: 5}},
+	} {
+		if got, ok := locate("example.com/located/sub", tc.test); !ok || got != tc.want {
+			t.Errorf("Expected %s at %+v, got %+v, %v", tc.test, tc.want, got, ok)
+		}
+	}
+	if _, ok := locate("example.com/located/sub", "helper"); ok {
+		t.Error("Expected non-test functions to be unknown")
+	}
+}
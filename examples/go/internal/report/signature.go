@@ -0,0 +1,111 @@
+package report
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// number matches the parts of a failure message that vary between runs of
+// one failure: hex addresses, durations and other numbers, but not digits
+// inside names such as http2
+var number = regexp.MustCompile(`\b(0x[0-9a-fA-F]+|\d+(\.\d+)?(ns|µs|us|ms|s|m|h)?)\b`)
+
+// uuid matches random identifiers, which would otherwise leave their
+// letters behind once their digits are replaced
+var uuid = regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`)
+
+// similarity is the share of words two signatures must have in common, in
+// order, to count as one failure
+const similarity = 0.8
+
+// Signature normalizes a failure message to what runs of one failure share,
+// replacing numbers, addresses, durations and UUIDs with N; runs that fail
+// with "got 0.812" and "got 0.743" share the signature "got N"
+func Signature(msg string) string {
+	if msg == "" {
+		return "(no message)"
+	}
+	return number.ReplaceAllString(uuid.ReplaceAllString(msg, "N"), "N")
+}
+
+// SameFailure reports whether two signatures are one failure: equal, or
+// differing in at most a fifth of their words, such as the name of the value
+// a seed picked
+func SameFailure(a, b string) bool {
+	if a == b {
+		return true
+	}
+	x, y := strings.Fields(a), strings.Fields(b)
+	longest := max(len(x), len(y))
+	return longest > 0 && float64(longest-wordDistance(x, y))/float64(longest) >= similarity
+}
+
+// wordDistance is the Levenshtein distance between two lists of words
+func wordDistance(x, y []string) int {
+	prev := make([]int, len(y)+1)
+	cur := make([]int, len(y)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(x); i++ {
+		cur[0] = i
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(y)]
+}
+
+// FailureSignatures groups each test's failing runs by the signature of the
+// first message each logged, in order of first occurrence, classifying them
+// with c (DefaultClassifier when nil)
+// Each group keeps the message and seed of its first run as a representative
+func FailureSignatures(results []runner.Result, c *Classifier) map[[2]string][]JSONFailure {
+	c = c.orDefault()
+	byTest := make(map[[2]string][]JSONFailure)
+	for _, r := range results {
+		if r.Outcome != runner.Fail {
+			continue
+		}
+		var msg string
+		if msgs := runner.FailureMessages(r.Output); len(msgs) > 0 {
+			msg = msgs[0]
+		}
+		key := [2]string{r.Package, r.Test}
+		byTest[key] = addFailure(byTest[key], JSONFailure{Message: msg, Category: c.Classify(r)}, r.Seed)
+	}
+	return byTest
+}
+
+// addFailure records seeds under the failure of failures that f is the same
+// failure as, appending f when there is none
+func addFailure(failures []JSONFailure, f JSONFailure, seeds ...int64) []JSONFailure {
+	if f.Message == "" {
+		f.Message = "(no message)"
+	}
+	if f.Signature == "" {
+		f.Signature = Signature(f.Message)
+	}
+	if f.Count == 0 {
+		f.Count = len(seeds)
+	}
+	for i := range failures {
+		if SameFailure(failures[i].Signature, f.Signature) {
+			failures[i].Count += f.Count
+			failures[i].Seeds = append(failures[i].Seeds, seeds...)
+			return failures
+		}
+	}
+	if len(seeds) > 0 && f.Seed == 0 {
+		f.Seed = seeds[0]
+	}
+	f.Seeds = append([]int64(nil), seeds...)
+	return append(failures, f)
+}
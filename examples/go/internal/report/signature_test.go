@@ -0,0 +1,58 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestSignature(t *testing.T) {
+	for msg, want := range map[string]string{
+		"a_test.go:12: Expected rate below 0.5, got 0.812":                 "a_test.go:N: Expected rate below N, got N",
+		"a_test.go:7: request 7f1c2a9e-4b3d-4e5f-8a6b-1c2d3e4f5a6b failed": "a_test.go:N: request N failed",
+		"a_test.go:9: lock held by 0xc000012345 after 150ms":               "a_test.go:N: lock held by N after N",
+		"a_test.go:40: http2 stream reset":                                 "a_test.go:N: http2 stream reset",
+		"":                                                                 "(no message)",
+	} {
+		if got := Signature(msg); got != want {
+			t.Errorf("Signature(%q) = %q, want %q", msg, got, want)
+		}
+	}
+}
+
+func TestSameFailure(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		same bool
+	}{
+		{"a_test.go:N: Expected N items in the cart, got N", "a_test.go:N: Expected N items in the basket, got N", true},
+		{"a_test.go:N: Expected N items, got N", "a_test.go:N: http2 stream reset after N", false},
+		// One word of two is half the message
+		{"connection refused", "connection reset", false},
+	} {
+		if got := SameFailure(c.a, c.b); got != c.same {
+			t.Errorf("SameFailure(%q, %q) = %v, want %v", c.a, c.b, got, c.same)
+		}
+	}
+}
+
+func TestFailureSignatures(t *testing.T) {
+	fail := func(seed int64, msg string) runner.Result {
+		return runner.Result{Package: "p", Test: "TestRate", Seed: seed, Outcome: runner.Fail, Kind: runner.Assertion, Output: "    rate_test.go:9: " + msg + "\n"}
+	}
+	results := []runner.Result{
+		fail(3, "got 0.812, want below 0.5"),
+		{Package: "p", Test: "TestRate", Seed: 4, Outcome: runner.Pass},
+		fail(5, "connection refused"),
+		fail(8, "got 0.743, want below 0.5"),
+	}
+	got := FailureSignatures(results, nil)[[2]string{"p", "TestRate"}]
+	want := []JSONFailure{
+		{Message: "rate_test.go:9: got 0.812, want below 0.5", Signature: "rate_test.go:N: got N, want below N", Category: CategoryAssertion, Count: 2, Seed: 3, Seeds: []int64{3, 8}},
+		{Message: "rate_test.go:9: connection refused", Signature: "rate_test.go:N: connection refused", Category: "network", Count: 1, Seed: 5, Seeds: []int64{5}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected 2 signatures:\n%+v\ngot:\n%+v", want, got)
+	}
+}
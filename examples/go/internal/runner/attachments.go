@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/example/flaky-test-example/internal/attach"
+	"github.com/example/flaky-test-example/testevent"
+)
+
+// attachFailures stores the output of every failing result of one go test
+// process, with the process's stdout and stderr, in store and records each
+// in its index
+// The process outputs are stored once however many of its tests failed
+func attachFailures(store *attach.Store, results []Result, stdout, stderr []byte) error {
+	var procRefs []attach.Ref
+	for i := range results {
+		r := &results[i]
+		if r.Outcome != Fail {
+			continue
+		}
+		if procRefs == nil {
+			out, err := store.Put(attach.Stdout, []byte(plainOutput(stdout)))
+			if err != nil {
+				return err
+			}
+			procRefs = append(procRefs, out)
+			if len(stderr) > 0 {
+				errRef, err := store.Put(attach.Stderr, stderr)
+				if err != nil {
+					return err
+				}
+				procRefs = append(procRefs, errRef)
+			}
+		}
+		own, err := store.Put(attach.TestOutput, []byte(r.Output))
+		if err != nil {
+			return err
+		}
+		r.Attachments = append([]attach.Ref{own}, procRefs...)
+		err = store.Record(attach.Entry{Package: r.Package, Test: r.Test, Attachment: r.Attachment()})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Attachment returns the stored outputs of a failing run, which are empty
+// unless Config.Attachments was set
+func (r Result) Attachment() attach.Attachment {
+	return attach.Attachment{Run: r.Run, Seed: r.Seed, Kind: string(r.Kind), Outputs: r.Attachments}
+}
+
+// plainOutput turns a go test -json stream back into the text go test
+// prints without -json; lines that are not events, such as build errors,
+// are kept as they are
+func plainOutput(stream []byte) string {
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(stream))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var ev testevent.Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil || ev.Action == "" {
+			fmt.Fprintf(&out, "%s\n", scanner.Bytes())
+			continue
+		}
+		if ev.Action == testevent.Output {
+			out.WriteString(ev.Output)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,62 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/attach"
+)
+
+func TestPlainOutput(t *testing.T) {
+	stream := `# example.com/seeded
+{"Action":"start","Package":"p"}
+{"Action":"run","Package":"p","Test":"TestA"}
+{"Action":"output","Package":"p","Test":"TestA","Output":"=== RUN   TestA\n"}
+{"Action":"output","Package":"p","Test":"TestA","Output":"--- FAIL: TestA (0.00s)\n"}
+{"Action":"fail","Package":"p","Test":"TestA"}
+{"Action":"output","Package":"p","Output":"FAIL\n"}
+`
+	want := "# example.com/seeded\n=== RUN   TestA\n--- FAIL: TestA (0.00s)\nFAIL\n"
+	if got := plainOutput([]byte(stream)); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestDetectAttachments(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	store := attach.New(t.TempDir())
+	report, err := Detect(context.Background(), Config{Dir: writeModule(t), Runs: 4, Seed: 10, Attachments: store})
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	for _, r := range report.Results {
+		if (r.Outcome == Fail) != (len(r.Attachments) > 0) {
+			t.Errorf("%s seed %d (%s): expected attachments only for failures, got %v", r.Test, r.Seed, r.Outcome, r.Attachments)
+		}
+	}
+	parity := report.Tests[0]
+	if len(parity.Attachments) != 2 || parity.Attachments[0].Seed != 11 || parity.Attachments[1].Seed != 13 {
+		t.Fatalf("Expected the attachments of seeds 11 and 13, got %+v", parity.Attachments)
+	}
+
+	found, err := store.Find("", "TestSeedParity", 13)
+	if err != nil || len(found) != 1 {
+		t.Fatalf("Expected the run of seed 13 in the index, got %v, %v", found, err)
+	}
+	own, _ := found[0].Output(attach.TestOutput)
+	data, err := store.Get(own.Digest)
+	if err != nil || !strings.Contains(string(data), "odd seed 13") || strings.Contains(string(data), "TestStable") {
+		t.Errorf("Expected the test's own output, got %q, %v", data, err)
+	}
+	stdout, _ := found[0].Output(attach.Stdout)
+	data, err = store.Get(stdout.Digest)
+	if err != nil || !strings.Contains(string(data), "--- PASS: TestStable") || strings.Contains(string(data), `"Action"`) {
+		t.Errorf("Expected the process's plain output, got %q, %v", data, err)
+	}
+	if entries, _ := store.Entries(); len(entries) != 2 {
+		t.Errorf("Expected one index entry per failing run, got %d", len(entries))
+	}
+}
@@ -0,0 +1,185 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/example/flaky-test-example/stats"
+)
+
+// BenchResult is one benchmark's measurement in one run of the suite
+type BenchResult struct {
+	Package    string
+	Benchmark  string
+	Run        int
+	Iterations int
+	NsPerOp    float64
+}
+
+// ParseBench reads the text output of go test -bench and returns a
+// BenchResult for every benchmark line
+// The package comes from the "pkg:" header go test prints before each
+// package's benchmarks; other lines are ignored
+func ParseBench(r io.Reader, run int) ([]BenchResult, error) {
+	var results []BenchResult
+	pkg := ""
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if p, ok := strings.CutPrefix(line, "pkg: "); ok {
+			pkg = strings.TrimSpace(p)
+			continue
+		}
+		if res, ok := parseBenchLine(line); ok {
+			res.Package, res.Run = pkg, run
+			results = append(results, res)
+		}
+	}
+	return results, scanner.Err()
+}
+
+// parseBenchLine parses a line like "BenchmarkEncode-8  1000  1234 ns/op",
+// which may carry more unit pairs such as B/op after the ns/op
+func parseBenchLine(line string) (BenchResult, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+		return BenchResult{}, false
+	}
+	n, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return BenchResult{}, false
+	}
+	for i := 2; i+1 < len(fields); i += 2 {
+		if fields[i+1] != "ns/op" {
+			continue
+		}
+		ns, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return BenchResult{}, false
+		}
+		return BenchResult{Benchmark: fields[0], Iterations: n, NsPerOp: ns}, true
+	}
+	return BenchResult{}, false
+}
+
+// RunBenchOnce executes go test -bench once for the given run index, running
+// the benchmarks that match bench and no tests
+// cfg.Run is ignored; pass -benchtime and the like in cfg.Args
+func RunBenchOnce(ctx context.Context, cfg Config, bench string, run int) ([]BenchResult, error) {
+	seed := cfg.Seed + int64(run)
+	args := []string{"test", "-run", "^$", "-bench", bench, "-count=1"}
+	args = append(args, cfg.Args...)
+	args = append(args, packagesOrDefault(cfg.Packages)...)
+
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = append(os.Environ(), cfg.Env...)
+	cmd.Env = append(cmd.Env, SeedEnv+"="+strconv.FormatInt(seed, 10))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	runErr := cmd.Run()
+	results, parseErr := ParseBench(&stdout, run)
+	if parseErr != nil {
+		return nil, fmt.Errorf("run %d: parse go test output: %w", run, parseErr)
+	}
+	// A failing benchmark makes go test exit non-zero while the others
+	// still report; only a run without any measurement is an error
+	var exitErr *exec.ExitError
+	if runErr != nil && (len(results) == 0 || !errors.As(runErr, &exitErr)) {
+		return nil, fmt.Errorf("run %d: go %v: %w\n%s%s", run, args, runErr, stdout.String(), stderr.String())
+	}
+	return results, nil
+}
+
+// BenchStats are the measurements of one benchmark across runs
+type BenchStats struct {
+	Package   string
+	Benchmark string
+	// NsPerOp holds one measurement per run the benchmark reported in, and
+	// SampleRuns the run index of each
+	NsPerOp    []float64
+	SampleRuns []int
+}
+
+// Spread summarizes the benchmark's ns/op across runs
+func (s *BenchStats) Spread() stats.Spread {
+	return stats.Describe(s.NsPerOp)
+}
+
+// OutlierRuns returns the run indexes whose ns/op lies outside Tukey's
+// fences for this benchmark
+func (s *BenchStats) OutlierRuns() []int {
+	var runs []int
+	for _, i := range stats.Outliers(s.NsPerOp) {
+		runs = append(runs, s.SampleRuns[i])
+	}
+	return runs
+}
+
+// Unstable reports whether the benchmark's coefficient of variation exceeds
+// threshold, such as 0.05 for results that swing more than 5% between runs
+func (s *BenchStats) Unstable(threshold float64) bool {
+	return s.Spread().CV > threshold
+}
+
+// BenchReport is the aggregated result of a benchmark sweep
+type BenchReport struct {
+	Runs       int
+	Benchmarks []*BenchStats
+}
+
+// AggregateBench groups results per benchmark, ordered by package and name
+func AggregateBench(runs int, results []BenchResult) *BenchReport {
+	byKey := make(map[testKey]*BenchStats)
+	report := &BenchReport{Runs: runs}
+	for _, r := range results {
+		key := testKey{r.Package, r.Benchmark}
+		s, ok := byKey[key]
+		if !ok {
+			s = &BenchStats{Package: r.Package, Benchmark: r.Benchmark}
+			byKey[key] = s
+			report.Benchmarks = append(report.Benchmarks, s)
+		}
+		s.NsPerOp = append(s.NsPerOp, r.NsPerOp)
+		s.SampleRuns = append(s.SampleRuns, r.Run)
+	}
+	sort.Slice(report.Benchmarks, func(i, j int) bool {
+		a, b := report.Benchmarks[i], report.Benchmarks[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		return a.Benchmark < b.Benchmark
+	})
+	return report
+}
+
+// DetectBench runs the benchmarks matching bench cfg.Runs times and
+// aggregates their measurements
+// Adaptive, RerunFailed, MaxDuration and Observe do not apply to benchmarks
+// and are ignored
+func DetectBench(ctx context.Context, cfg Config, bench string) (*BenchReport, error) {
+	if cfg.Runs < 1 {
+		return nil, fmt.Errorf("runs must be at least 1, got %d", cfg.Runs)
+	}
+	var results []BenchResult
+	for run := 0; run < cfg.Runs; run++ {
+		runResults, err := RunBenchOnce(ctx, cfg, bench, run)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, runResults...)
+	}
+	return AggregateBench(cfg.Runs, results), nil
+}
@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sampleBenchOutput = `goos: linux
+goarch: amd64
+pkg: example.com/codec
+cpu: Example CPU @ 3.00GHz
+BenchmarkEncode-8   	 1000000	      1234 ns/op	     128 B/op	       2 allocs/op
+BenchmarkDecode-8   	  500000	      2500.5 ns/op
+--- FAIL: BenchmarkBroken-8
+    codec_test.go:40: boom
+PASS
+ok  	example.com/codec	3.210s
+pkg: example.com/store
+BenchmarkPut    	    2000	    600000 ns/op
+ok  	example.com/store	1.500s
+`
+
+func TestParseBench(t *testing.T) {
+	results, err := ParseBench(strings.NewReader(sampleBenchOutput), 2)
+	if err != nil {
+		t.Fatalf("ParseBench failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %+v", results)
+	}
+	enc, dec, put := results[0], results[1], results[2]
+	if enc.Package != "example.com/codec" || enc.Benchmark != "BenchmarkEncode-8" || enc.Iterations != 1000000 || enc.NsPerOp != 1234 || enc.Run != 2 {
+		t.Errorf("Unexpected first result: %+v", enc)
+	}
+	if dec.NsPerOp != 2500.5 {
+		t.Errorf("Expected fractional ns/op, got %+v", dec)
+	}
+	if put.Package != "example.com/store" || put.Benchmark != "BenchmarkPut" {
+		t.Errorf("Expected the package header to switch, got %+v", put)
+	}
+}
+
+func TestAggregateBench(t *testing.T) {
+	var results []BenchResult
+	for run, ns := range []float64{100, 102, 98, 101, 99, 250} {
+		results = append(results,
+			BenchResult{Package: "p", Benchmark: "BenchmarkB", Run: run, NsPerOp: ns},
+			BenchResult{Package: "p", Benchmark: "BenchmarkA", Run: run, NsPerOp: 50})
+	}
+	report := AggregateBench(6, results)
+	if len(report.Benchmarks) != 2 || report.Benchmarks[0].Benchmark != "BenchmarkA" {
+		t.Fatalf("Expected 2 benchmarks sorted by name, got %+v", report.Benchmarks)
+	}
+	steady, swinging := report.Benchmarks[0], report.Benchmarks[1]
+	if steady.Unstable(0.05) || steady.OutlierRuns() != nil {
+		t.Errorf("Expected a constant benchmark to be stable, got %+v", steady.Spread())
+	}
+	if !swinging.Unstable(0.05) {
+		t.Errorf("Expected a CV of %.2f to exceed 5%%", swinging.Spread().CV)
+	}
+	if out := swinging.OutlierRuns(); len(out) != 1 || out[0] != 5 {
+		t.Errorf("Expected run 5 to be the outlier, got %v", out)
+	}
+}
+
+func TestDetectBench(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	dir := writeModule(t)
+	bench := "package seeded\n\nimport \"testing\"\n\nfunc BenchmarkNoop(b *testing.B) {\n\tfor i := 0; i < b.N; i++ {\n\t}\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "bench_test.go"), []byte(bench), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	report, err := DetectBench(context.Background(), Config{Dir: dir, Runs: 3, Args: []string{"-benchtime=10x"}}, ".")
+	if err != nil {
+		t.Fatalf("DetectBench failed: %v", err)
+	}
+	if len(report.Benchmarks) != 1 {
+		t.Fatalf("Expected one benchmark, got %+v", report.Benchmarks)
+	}
+	// The odd-seed test must not run, or run 1 would fail
+	if b := report.Benchmarks[0]; !strings.HasPrefix(b.Benchmark, "BenchmarkNoop") || len(b.NsPerOp) != 3 || b.Package != "example.com/seeded" {
+		t.Errorf("Expected 3 samples of BenchmarkNoop, got %+v", b)
+	}
+}
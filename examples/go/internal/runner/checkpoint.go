@@ -0,0 +1,153 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// DefaultCheckpointFile is the checkpoint flakectl detect saves unless told
+// otherwise
+const DefaultCheckpointFile = "flaky-checkpoint.json"
+
+// Checkpoint is the progress of an interrupted Detect, which Config.Resume
+// continues from
+type Checkpoint struct {
+	// Packages, Run, Seed, Runs and Args identify the sweep; a checkpoint
+	// only resumes the same sweep
+	Packages []string `json:"packages"`
+	Run      string   `json:"run,omitempty"`
+	Seed     int64    `json:"seed"`
+	Runs     int      `json:"runs"`
+	Args     []string `json:"args,omitempty"`
+
+	// Completed is the number of runs that finished; the next run is run
+	// Completed, with seed Seed+Completed
+	Completed int `json:"completed"`
+	// Remaining is the inclusive seed range left to run at most, which
+	// Adaptive may cut short
+	Remaining SeedRange `json:"remaining"`
+	// Reran is set once the Config.RerunFailed runs have started, at run
+	// RerunFrom
+	Reran     []string `json:"reran,omitempty"`
+	RerunFrom int      `json:"rerun_from,omitempty"`
+	// Elapsed is how long the sweep had run, counted against MaxDuration
+	Elapsed time.Duration `json:"elapsed"`
+	Saved   time.Time     `json:"saved"`
+	// Tests tallies the completed runs per test
+	Tests   []Tally  `json:"tests"`
+	Results []Result `json:"results"`
+}
+
+// SeedRange is an inclusive range of seeds; it is empty when Last is below
+// First
+type SeedRange struct {
+	First int64 `json:"first"`
+	Last  int64 `json:"last"`
+}
+
+// Len returns the number of seeds in the range
+func (r SeedRange) Len() int {
+	if r.Last < r.First {
+		return 0
+	}
+	return int(r.Last - r.First + 1)
+}
+
+// Tally counts one test's outcomes in the completed runs of a checkpoint
+type Tally struct {
+	Package string `json:"package"`
+	Test    string `json:"test"`
+	Passed  int    `json:"passed"`
+	Failed  int    `json:"failed"`
+	Skipped int    `json:"skipped"`
+}
+
+// newCheckpoint records the progress of cfg's sweep after completed runs,
+// with lastRun the index of the last run the sweep may reach
+func newCheckpoint(cfg Config, completed, lastRun int, results []Result) *Checkpoint {
+	cp := &Checkpoint{
+		Packages:  cfg.Packages,
+		Run:       cfg.Run,
+		Seed:      cfg.Seed,
+		Runs:      cfg.Runs,
+		Args:      cfg.Args,
+		Completed: completed,
+		Remaining: SeedRange{First: cfg.Seed + int64(completed), Last: cfg.Seed + int64(lastRun)},
+		Saved:     time.Now(),
+		Results:   results,
+	}
+	for _, s := range Aggregate(completed, results).Tests {
+		cp.Tests = append(cp.Tests, Tally{Package: s.Package, Test: s.Test, Passed: s.Passed, Failed: s.Failed, Skipped: s.Skipped})
+	}
+	return cp
+}
+
+// Matches reports whether the checkpoint was saved by the sweep cfg
+// describes, with an error naming the first difference
+func (c *Checkpoint) Matches(cfg Config) error {
+	switch {
+	case !slices.Equal(c.Packages, cfg.Packages):
+		return fmt.Errorf("checkpoint is of packages %v, not %v", c.Packages, cfg.Packages)
+	case c.Run != cfg.Run:
+		return fmt.Errorf("checkpoint is of -run %q, not %q", c.Run, cfg.Run)
+	case c.Seed != cfg.Seed || c.Runs != cfg.Runs:
+		return fmt.Errorf("checkpoint is of %d runs from seed %d, not %d from seed %d", c.Runs, c.Seed, cfg.Runs, cfg.Seed)
+	case !slices.Equal(c.Args, cfg.Args):
+		return fmt.Errorf("checkpoint is of go test arguments %v, not %v", c.Args, cfg.Args)
+	}
+	return nil
+}
+
+// LoadCheckpoint reads the checkpoint at path, returning an error matching
+// fs.ErrNotExist when there is none
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("checkpoint %s: %w", path, err)
+	}
+	return &cp, nil
+}
+
+// Save writes the checkpoint to path, replacing the previous one atomically
+// so a kill mid-write leaves the previous checkpoint intact
+func (c *Checkpoint) Save(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".checkpoint-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// removeCheckpoint deletes the checkpoint of a finished sweep
+func removeCheckpoint(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
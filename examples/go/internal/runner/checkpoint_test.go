@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// interruptAfter returns a context cancelled once run has been observed
+func interruptAfter(run int) (context.Context, func(int, []Result)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return ctx, func(r int, _ []Result) {
+		if r == run {
+			cancel()
+		}
+	}
+}
+
+func TestDetectResumesFromCheckpoint(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	// An interval longer than the sweep leaves the save on failure to cover
+	// the last runs
+	cfg := Config{Dir: writeModule(t), Runs: 4, Seed: 10, Checkpoint: path, CheckpointInterval: time.Hour}
+	ctx, observe := interruptAfter(1)
+	cfg.Observe = observe
+	if _, err := Detect(ctx, cfg); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the interrupted sweep to fail with context.Canceled, got %v", err)
+	}
+
+	cp, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Completed != 2 || cp.Remaining != (SeedRange{First: 12, Last: 13}) || len(cp.Results) != 4 {
+		t.Fatalf("Expected 2 runs done and seeds 12-13 left, got %d done, %+v left, %d results", cp.Completed, cp.Remaining, len(cp.Results))
+	}
+	if want := (Tally{Package: "example.com/seeded", Test: "TestSeedParity", Passed: 1, Failed: 1}); len(cp.Tests) != 2 || cp.Tests[0] != want {
+		t.Errorf("Expected tally %+v, got %+v", want, cp.Tests)
+	}
+
+	var resumed []int
+	cfg.Resume = cp
+	cfg.Observe = func(run int, _ []Result) { resumed = append(resumed, run) }
+	report, err := Detect(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(resumed, []int{2, 3}) {
+		t.Errorf("Expected only runs 2 and 3 to run again, got %v", resumed)
+	}
+	if parity := report.Tests[0]; report.Runs != 4 || !slices.Equal(parity.FailingSeeds, []int64{11, 13}) {
+		t.Errorf("Expected 4 runs failing on seeds [11 13], got %d runs failing on %v", report.Runs, parity.FailingSeeds)
+	}
+	if _, err := LoadCheckpoint(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected the finished sweep's checkpoint removed, got %v", err)
+	}
+}
+
+func TestDetectResumesRerunFailed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	cfg := Config{Dir: writeModule(t), Runs: 2, Seed: 10, RerunFailed: 4, Checkpoint: path}
+	ctx, observe := interruptAfter(3)
+	cfg.Observe = observe
+	if _, err := Detect(ctx, cfg); err == nil {
+		t.Fatal("Expected the interrupted sweep to fail")
+	}
+	cp, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Completed != 4 || cp.RerunFrom != 2 || !slices.Equal(cp.Reran, []string{"TestSeedParity"}) || cp.Remaining.Len() != 2 {
+		t.Fatalf("Expected 4 runs done, 2 of them reruns of TestSeedParity, and 2 seeds left, got %+v", cp)
+	}
+
+	cfg.Resume, cfg.Observe = cp, nil
+	report, err := Detect(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parity, stable := report.Tests[0], report.Tests[1]; parity.Runs() != 6 || parity.Failed != 3 || stable.Runs() != 2 {
+		t.Errorf("Expected the reruns to finish for TestSeedParity only, got %+v, %+v", parity, stable)
+	}
+}
+
+func TestCheckpointMatches(t *testing.T) {
+	cfg := Config{Packages: []string{"./..."}, Runs: 10, Seed: 1, Run: "^TestA$"}
+	cp := newCheckpoint(cfg, 3, 9, nil)
+	if err := cp.Matches(cfg); err != nil {
+		t.Errorf("Expected the checkpoint to match its own sweep, got %v", err)
+	}
+	for name, other := range map[string]Config{
+		"packages": {Packages: []string{"./pkg"}, Runs: 10, Seed: 1, Run: "^TestA$"},
+		"run":      {Packages: []string{"./..."}, Runs: 10, Seed: 1},
+		"seed":     {Packages: []string{"./..."}, Runs: 10, Seed: 2, Run: "^TestA$"},
+		"args":     {Packages: []string{"./..."}, Runs: 10, Seed: 1, Run: "^TestA$", Args: []string{"-race"}},
+	} {
+		if err := cp.Matches(other); err == nil {
+			t.Errorf("%s: expected a mismatch", name)
+		}
+	}
+	if _, err := Detect(context.Background(), Config{Runs: 10, Seed: 5, Resume: cp}); err == nil {
+		t.Error("Expected Detect to refuse a checkpoint of another sweep")
+	}
+}
@@ -0,0 +1,174 @@
+package runner
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exitSignals maps the descriptions go test reports a test binary killed
+// by a signal with, as in "signal: killed", to the signal's name
+var exitSignals = map[string]string{
+	"aborted":                  "SIGABRT",
+	"bus error":                "SIGBUS",
+	"floating point exception": "SIGFPE",
+	"illegal instruction":      "SIGILL",
+	"interrupt":                "SIGINT",
+	"killed":                   "SIGKILL",
+	"quit":                     "SIGQUIT",
+	"segmentation fault":       "SIGSEGV",
+	"terminated":               "SIGTERM",
+	"trace/breakpoint trap":    "SIGTRAP",
+}
+
+// runtimeSignal matches the line the Go runtime opens the dump of a fatal
+// signal with, such as "SIGSEGV: segmentation violation"
+var runtimeSignal = regexp.MustCompile(`^(SIG[A-Z0-9]+): `)
+
+// panicSignal matches the line under a panic the runtime raised for a
+// signal, such as a nil dereference's "[signal SIGSEGV: segmentation
+// violation code=0x1 addr=0x0 pc=0x4f0c1a]"
+var panicSignal = regexp.MustCompile(`^\[signal (SIG[A-Z0-9]+)`)
+
+// exitStatus matches the line go test reports a test binary's exit status
+// with when the binary printed nothing else
+var exitStatus = regexp.MustCompile(`^exit status (\d+)$`)
+
+// runtimeExitCode is the status the Go runtime exits with after a fatal
+// error, a fatal signal or a panic no test recovered
+const runtimeExitCode = 2
+
+// isRuntimeCrash reports whether line opens the dump of a fatal error or
+// signal of the Go runtime
+func isRuntimeCrash(line string) bool {
+	return strings.HasPrefix(line, "fatal error: ") || runtimeSignal.MatchString(line)
+}
+
+// crashExit returns the signal a test binary died of and its exit status
+// from the output of the test it crashed in
+func crashExit(output string) (string, int) {
+	var signal string
+	var code int
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "signal: ") && signal == "":
+			desc := strings.TrimSuffix(strings.TrimPrefix(line, "signal: "), " (core dumped)")
+			if signal = exitSignals[desc]; signal == "" {
+				signal = desc
+			}
+		case runtimeSignal.MatchString(line) && signal == "":
+			signal = runtimeSignal.FindStringSubmatch(line)[1]
+			code = runtimeExitCode
+		case panicSignal.MatchString(line) && signal == "":
+			signal = panicSignal.FindStringSubmatch(line)[1]
+		case (strings.HasPrefix(line, "fatal error: ") || strings.HasPrefix(line, "panic: ")) && code == 0:
+			code = runtimeExitCode
+		case exitStatus.MatchString(line):
+			code, _ = strconv.Atoi(exitStatus.FindStringSubmatch(line)[1])
+		}
+	}
+	return signal, code
+}
+
+// UnknownExit counts the crashes in TestStats.Crashes whose output does not
+// say how the test binary ended
+const UnknownExit = "unknown exit"
+
+// Exit describes how the test binary of a Crash ended, such as "SIGKILL"
+// or "exit status 2", or returns "" when its output does not say
+func (r Result) Exit() string {
+	switch {
+	case r.Signal != "":
+		return r.Signal
+	case r.ExitCode != 0:
+		return fmt.Sprintf("exit status %d", r.ExitCode)
+	}
+	return ""
+}
+
+// blameCrashes counts the crash of a test binary against the top-level test
+// of its package that started last of those still running, whose part it
+// was most likely in, and drops the results of the other tests it
+// interrupted, which run again
+// A test that panicked reported its own failure before the binary exited,
+// so it is the one blamed and every test still running was interrupted
+// The binary died once, so the crashes kept share its signal and exit
+// status, whichever test's output reported them
+// It returns the kept results and the tests blamed; a package whose binary
+// go test's -timeout stopped is left as it is
+func blameCrashes(results []Result) ([]Result, []testKey) {
+	type crash struct {
+		culprit  string
+		start    time.Time
+		panicked bool
+		signal   string
+		exitCode int
+	}
+	crashes := make(map[string]*crash)
+	var order []string
+	timedOut := make(map[string]bool)
+	interrupted := make(map[testKey]bool)
+	for _, r := range results {
+		if r.Kind == Timeout {
+			timedOut[r.Package] = true
+		}
+		if r.Kind != Crash && r.Kind != Panic {
+			continue
+		}
+		c := crashes[r.Package]
+		if c == nil {
+			c = &crash{}
+			crashes[r.Package] = c
+			order = append(order, r.Package)
+		}
+		top, _, sub := strings.Cut(r.Test, "/")
+		switch {
+		case r.Kind == Panic:
+			if !c.panicked {
+				c.culprit, c.panicked = top, true
+			}
+		case !sub:
+			interrupted[testKey{r.Package, r.Test}] = true
+			// Tests abandoned together are in start order, so the later of
+			// two with the same start is the one that started last
+			if !c.panicked && (c.culprit == "" || !r.Start.Before(c.start)) {
+				c.culprit, c.start = r.Test, r.Start
+			}
+			fallthrough
+		default:
+			if c.signal == "" {
+				c.signal = r.Signal
+			}
+			if c.exitCode == 0 {
+				c.exitCode = r.ExitCode
+			}
+		}
+	}
+
+	var kept []Result
+	var blamed []testKey
+	for _, pkg := range order {
+		if c := crashes[pkg]; c.culprit != "" && !timedOut[pkg] {
+			blamed = append(blamed, testKey{pkg, c.culprit})
+		}
+	}
+	if len(blamed) == 0 {
+		return results, nil
+	}
+	for _, r := range results {
+		c := crashes[r.Package]
+		top, _, _ := strings.Cut(r.Test, "/")
+		switch {
+		case c == nil || c.culprit == "" || timedOut[r.Package]:
+		case top != c.culprit && interrupted[testKey{r.Package, top}]:
+			continue
+		case r.Kind == Crash:
+			r.Signal, r.ExitCode = c.signal, c.exitCode
+		}
+		kept = append(kept, r)
+	}
+	return kept, blamed
+}
@@ -0,0 +1,171 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCrashExit(t *testing.T) {
+	for _, tc := range []struct {
+		output string
+		signal string
+		code   int
+	}{
+		{"=== RUN   TestA\nsignal: killed\n", "SIGKILL", 0},
+		{"signal: segmentation fault (core dumped)\n", "SIGSEGV", 0},
+		{"signal: user defined signal 1\n", "user defined signal 1", 0},
+		{"SIGSEGV: segmentation violation\nPC=0x40ee0e m=0 sigcode=0\n\ngoroutine 7 gp=0x1 m=0 [running]:\n", "SIGSEGV", 2},
+		{"panic: runtime error: invalid memory address or nil pointer dereference\n[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4f0c1a]\n", "SIGSEGV", 2},
+		{"fatal error: runtime: out of memory\n\nruntime stack:\n", "", 2},
+		{"exit status 3\n", "", 3},
+		{"=== RUN   TestA\n", "", 0},
+	} {
+		signal, code := crashExit(tc.output)
+		if signal != tc.signal || code != tc.code {
+			t.Errorf("crashExit(%q) = %q, %d, expected %q, %d", tc.output, signal, code, tc.signal, tc.code)
+		}
+	}
+}
+
+func TestResultExit(t *testing.T) {
+	for _, tc := range []struct {
+		r    Result
+		want string
+	}{
+		{Result{Signal: "SIGKILL"}, "SIGKILL"},
+		{Result{Signal: "SIGSEGV", ExitCode: 2}, "SIGSEGV"},
+		{Result{ExitCode: 2}, "exit status 2"},
+		{Result{}, ""},
+	} {
+		if got := tc.r.Exit(); got != tc.want {
+			t.Errorf("Expected %+v to exit with %q, got %q", tc.r, tc.want, got)
+		}
+	}
+}
+
+func TestFailureMessagesStopAtRuntimeCrash(t *testing.T) {
+	output := "=== RUN   TestA\n    a_test.go:5: starting\nSIGSEGV: segmentation violation\nPC=0x40ee0e m=0 sigcode=0 addr=0x775d\n\nrax    0x0\n"
+	msgs := FailureMessages(output)
+	if len(msgs) != 2 || msgs[1] != "SIGSEGV: segmentation violation" {
+		t.Errorf("Expected the log line and the signal, got %q", msgs)
+	}
+}
+
+// parallelCrash is a go test -json stream in which TestP2 killed its test
+// binary while TestP1, its subtest and TestP2's subtest were still running,
+// after TestA had passed
+const parallelCrash = `{"Time":"2026-01-01T00:00:00Z","Action":"run","Package":"p","Test":"TestA"}
+{"Time":"2026-01-01T00:00:00Z","Action":"pass","Package":"p","Test":"TestA","Elapsed":0}
+{"Time":"2026-01-01T00:00:01Z","Action":"run","Package":"p","Test":"TestP1"}
+{"Time":"2026-01-01T00:00:01Z","Action":"pause","Package":"p","Test":"TestP1"}
+{"Time":"2026-01-01T00:00:02Z","Action":"run","Package":"p","Test":"TestP2"}
+{"Time":"2026-01-01T00:00:02Z","Action":"pause","Package":"p","Test":"TestP2"}
+{"Time":"2026-01-01T00:00:03Z","Action":"cont","Package":"p","Test":"TestP1"}
+{"Time":"2026-01-01T00:00:03Z","Action":"run","Package":"p","Test":"TestP1/sub"}
+{"Time":"2026-01-01T00:00:03Z","Action":"pass","Package":"p","Test":"TestP1/sub","Elapsed":0}
+{"Time":"2026-01-01T00:00:03Z","Action":"cont","Package":"p","Test":"TestP2"}
+{"Time":"2026-01-01T00:00:04Z","Action":"run","Package":"p","Test":"TestP2/sub"}
+{"Time":"2026-01-01T00:00:05Z","Action":"output","Package":"p","Test":"TestP2/sub","Output":"signal: killed\n"}
+{"Time":"2026-01-01T00:00:05Z","Action":"output","Package":"p","Output":"FAIL\tp\t5.0s\n"}
+{"Time":"2026-01-01T00:00:05Z","Action":"fail","Package":"p","Elapsed":5}
+{"Time":"2026-01-01T00:00:00Z","Action":"run","Package":"q","Test":"TestQ"}
+{"Time":"2026-01-01T00:00:00Z","Action":"pass","Package":"q","Test":"TestQ","Elapsed":0}
+`
+
+func TestBlameCrashes(t *testing.T) {
+	results, err := Parse(strings.NewReader(parallelCrash), 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, blamed := blameCrashes(results)
+	if len(blamed) != 1 || blamed[0] != (testKey{"p", "TestP2"}) {
+		t.Errorf("Expected the crash blamed on TestP2, got %v", blamed)
+	}
+	var got []string
+	for _, r := range kept {
+		got = append(got, r.Test+":"+string(r.Outcome)+":"+r.Exit())
+	}
+	want := "TestA:pass: TestP2:fail:SIGKILL TestP2/sub:fail:SIGKILL TestQ:pass:"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Expected %s, got %s", want, strings.Join(got, " "))
+	}
+}
+
+func TestBlameCrashesLeavesTimeouts(t *testing.T) {
+	results := []Result{
+		{Package: "p", Test: "TestHang", Outcome: Fail, Kind: Timeout},
+		{Package: "p", Test: "TestOther", Outcome: Fail, Kind: Crash},
+	}
+	kept, blamed := blameCrashes(results)
+	if len(kept) != 2 || len(blamed) != 0 {
+		t.Errorf("Expected a timed out package left as it is, got %+v and %v", kept, blamed)
+	}
+}
+
+func TestBlameCrashesOnPanic(t *testing.T) {
+	results := []Result{
+		{Package: "p", Test: "TestA", Outcome: Pass},
+		{Package: "p", Test: "TestPanics/sub", Outcome: Fail, Kind: Panic},
+		{Package: "p", Test: "TestPanics", Outcome: Fail, Kind: Panic},
+		{Package: "p", Test: "TestParallel", Outcome: Fail, Kind: Crash, ExitCode: 2},
+	}
+	kept, blamed := blameCrashes(results)
+	if len(blamed) != 1 || blamed[0] != (testKey{"p", "TestPanics"}) {
+		t.Errorf("Expected the panic blamed on TestPanics, got %v", blamed)
+	}
+	var got []string
+	for _, r := range kept {
+		got = append(got, r.Test)
+	}
+	if want := "TestA TestPanics/sub TestPanics"; strings.Join(got, " ") != want {
+		t.Errorf("Expected %s, got %s", want, strings.Join(got, " "))
+	}
+}
+
+// crashTests adds a test whose binary dies of a nil dereference in a
+// goroutine no test recovers, between two that pass
+const crashTests = `package seeded
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAFirst(t *testing.T) {}
+
+func TestBCrashes(t *testing.T) {
+	var p *int
+	go func() { *p = 1 }()
+	time.Sleep(time.Minute)
+}
+
+func TestCAfter(t *testing.T) {}
+`
+
+func TestRunOnceContinuesAfterCrash(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	dir := writeModule(t)
+	if err := os.WriteFile(filepath.Join(dir, "crash_test.go"), []byte(crashTests), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	results, err := RunOnce(context.Background(), Config{Dir: dir, Seed: 2, Run: "^Test[ABC]"}, 0)
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	outcomes := make(map[string]Result)
+	for _, r := range results {
+		outcomes[r.Test] = r
+	}
+	if len(results) != 3 || outcomes["TestAFirst"].Outcome != Pass || outcomes["TestCAfter"].Outcome != Pass {
+		t.Fatalf("Expected the tests around the crashing one to pass once each, got %+v", results)
+	}
+	crashed := outcomes["TestBCrashes"]
+	if crashed.Outcome != Fail || crashed.Kind != Crash || crashed.Signal != "SIGSEGV" || crashed.ExitCode != 2 {
+		t.Errorf("Expected TestBCrashes to crash with SIGSEGV and exit status 2, got %+v", crashed)
+	}
+}
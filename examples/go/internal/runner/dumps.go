@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/example/flaky-test-example/internal/protocol"
+)
+
+// goroutineHeader matches the first line of a goroutine in a stack dump,
+// such as "goroutine 7 [chan receive, 2 minutes]:" or, after a SIGQUIT,
+// "goroutine 7 gp=0xc000007a40 m=nil [select (no cases)]:"
+var goroutineHeader = regexp.MustCompile(`^goroutine \d+ (\S+=\S+ )*\[.*\]:$`)
+
+// GoroutineDump returns the goroutine stacks in the output of a hung run: a
+// test go test -timeout or PerTestTimeout killed, or one that failed through
+// flaky.WithTimeout; it returns "" for other runs
+func (r Result) GoroutineDump() string {
+	if r.Outcome != Fail || (r.Kind != Timeout && !strings.Contains(r.Output, protocol.GoroutineDumpHeader)) {
+		return ""
+	}
+	return goroutineDump(r.Output)
+}
+
+// goroutineDump cuts the stack dump out of output, from its first goroutine
+// to the last line indented at least as deeply, with that indentation and
+// go test's closing lines removed
+func goroutineDump(output string) string {
+	lines := strings.Split(output, "\n")
+	start := -1
+	var indent string
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if goroutineHeader.MatchString(trimmed) {
+			start, indent = i, line[:len(line)-len(trimmed)]
+			break
+		}
+	}
+	if start < 0 {
+		return ""
+	}
+	var dump []string
+	for _, line := range lines[start:] {
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, indent) {
+			break
+		}
+		dump = append(dump, strings.TrimPrefix(line, indent))
+	}
+	for len(dump) > 0 {
+		last := strings.TrimSpace(dump[len(dump)-1])
+		if last != "" && !isFramingLine(last) && !strings.HasPrefix(last, "exit status ") && !strings.HasPrefix(last, "FAIL\t") {
+			break
+		}
+		dump = dump[:len(dump)-1]
+	}
+	return strings.Join(dump, "\n") + "\n"
+}
+
+// WriteDumps writes the goroutine dump of every hung run in results to a
+// file of its own in dir, named after the package, test and seed, and
+// returns the files written
+func WriteDumps(dir string, results []Result) ([]string, error) {
+	var files []string
+	for _, r := range results {
+		dump := r.GoroutineDump()
+		if dump == "" {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return files, err
+		}
+		name := fmt.Sprintf("%s.%s.seed%d.txt", dumpFileName(r.Package), dumpFileName(r.Test), r.Seed)
+		path := filepath.Join(dir, name)
+		header := fmt.Sprintf("package: %s\ntest: %s\nrun: %d\nseed: %d\nkind: %s\n\n", r.Package, r.Test, r.Run, r.Seed, r.Kind)
+		if err := os.WriteFile(path, []byte(header+dump), 0o644); err != nil {
+			return files, err
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// dumpFileName replaces the characters of s that are unsafe in file names
+func dumpFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/protocol"
+)
+
+// hungOutput is the output of a test that failed through flaky.WithTimeout
+const hungOutput = `=== RUN   TestHangs
+    hang_test.go:12: TestHangs did not return within 1s; ` + protocol.GoroutineDumpHeader + `
+        
+        goroutine 7 [chan receive, 1 minutes]:
+        example.com/seeded.waitForever(...)
+        	/src/hang_test.go:8
+        
+        goroutine 1 [running]:
+        main.main()
+--- FAIL: TestHangs (1.00s)
+`
+
+func TestGoroutineDump(t *testing.T) {
+	r := Result{Test: "TestHangs", Outcome: Fail, Kind: Assertion, Output: hungOutput}
+	want := "goroutine 7 [chan receive, 1 minutes]:\nexample.com/seeded.waitForever(...)\n\t/src/hang_test.go:8\n\ngoroutine 1 [running]:\nmain.main()\n"
+	if got := r.GoroutineDump(); got != want {
+		t.Errorf("Expected the dedented dump:\n%q\ngot:\n%q", want, got)
+	}
+
+	timedOut := Result{Outcome: Fail, Kind: Timeout, Output: "panic: test timed out after 1s\n\trunning tests:\n\t\tTestHangs (1s)\n\ngoroutine 17 [running]:\ntesting.(*M).startAlarm.func1()\nFAIL\texample.com/seeded\t1.005s\n"}
+	if got := timedOut.GoroutineDump(); got != "goroutine 17 [running]:\ntesting.(*M).startAlarm.func1()\n" {
+		t.Errorf("Expected the dump of the go test -timeout panic, got %q", got)
+	}
+
+	// A panic's traceback is not a dump of a hung test
+	panicked := Result{Outcome: Fail, Kind: Panic, Output: "panic: boom\n\ngoroutine 7 [running]:\n"}
+	if got := panicked.GoroutineDump(); got != "" {
+		t.Errorf("Expected no dump for a panic, got %q", got)
+	}
+}
+
+func TestWriteDumps(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dumps")
+	results := []Result{
+		{Package: "example.com/seeded", Test: "TestHangs/sub", Run: 2, Seed: 12, Outcome: Fail, Kind: Assertion, Output: hungOutput},
+		{Package: "example.com/seeded", Test: "TestFails", Run: 2, Seed: 12, Outcome: Fail, Kind: Assertion, Output: "boom\n"},
+	}
+	files, err := WriteDumps(dir, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "example_com_seeded.TestHangs_sub.seed12.txt"); len(files) != 1 || files[0] != want {
+		t.Fatalf("Expected only %s, got %v", want, files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "package: example.com/seeded\ntest: TestHangs/sub\nrun: 2\nseed: 12\n") || !strings.Contains(string(data), "goroutine 7 [chan receive") {
+		t.Errorf("Unexpected dump file:\n%s", data)
+	}
+}
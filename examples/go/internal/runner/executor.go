@@ -0,0 +1,212 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/example/flaky-test-example/testevent"
+)
+
+// unit is one go test process of a run: a package, or a single top-level
+// test of it with Isolate
+type unit struct {
+	pkg string
+	run string
+}
+
+// units splits the run cfg describes into the processes runPool executes,
+// in package then source order
+func units(ctx context.Context, cfg Config) ([]unit, error) {
+	packages, err := listPackages(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	pattern := cfg.Run
+	if pattern == "" {
+		pattern = "."
+	}
+	var us []unit
+	for _, pkg := range packages {
+		if !cfg.Isolate {
+			us = append(us, unit{pkg: pkg, run: cfg.Run})
+			continue
+		}
+		one := cfg
+		one.Packages = []string{pkg}
+		tests, err := ListTests(ctx, one, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, test := range tests {
+			us = append(us, unit{pkg: pkg, run: RunPattern([]string{test})})
+		}
+	}
+	return us, nil
+}
+
+// runPool runs the units of a run on cfg.Parallel workers and returns their
+// results in unit order, so they read like a single in-process run whatever
+// order the processes finished in
+// The first unit to fail stops the others
+func runPool(parent context.Context, cfg Config, run int) ([]Result, error) {
+	us, err := units(parent, cfg)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	next := make(chan int, len(us))
+	for i := range us {
+		next <- i
+	}
+	close(next)
+	results := make([][]Result, len(us))
+	errs := make([]error, len(us))
+	var wg sync.WaitGroup
+	for w := 0; w < min(max(cfg.Parallel, 1), len(us)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if ctx.Err() != nil {
+					return
+				}
+				if results[i], errs[i] = runUnit(ctx, cfg, run, us[i]); errs[i] != nil {
+					cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := parent.Err(); err != nil {
+		return nil, fmt.Errorf("run %d: %w", run, err)
+	}
+	// The units stopped by the first failure report context.Canceled
+	err = nil
+	for _, e := range errs {
+		if e != nil && (err == nil || errors.Is(err, context.Canceled)) {
+			err = e
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	var all []Result
+	for _, r := range results {
+		all = append(all, r...)
+	}
+	return all, nil
+}
+
+// runUnit runs the tests u selects until each has reported, as runTests
+// does
+func runUnit(ctx context.Context, cfg Config, run int, u unit) ([]Result, error) {
+	cfg.Isolate = false
+	cfg.Packages = []string{u.pkg}
+	cfg.Run = u.run
+	return runTests(ctx, cfg, run, nil)
+}
+
+// watchdog collects the go test -json output of one process and, with a
+// timeout, kills the process once a top-level test has run that long
+type watchdog struct {
+	timeout time.Duration
+	kill    func()
+
+	mu       sync.Mutex
+	out      bytes.Buffer
+	scanned  int
+	timers   map[string]*time.Timer
+	finished map[string]bool
+	hung     string
+}
+
+func newWatchdog(timeout time.Duration, kill func()) *watchdog {
+	return &watchdog{timeout: timeout, kill: kill, timers: make(map[string]*time.Timer), finished: make(map[string]bool)}
+}
+
+// Write buffers p and starts or stops the timers of the tests in the events
+// it completes
+func (w *watchdog) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.out.Write(p)
+	if w.timeout <= 0 {
+		return len(p), nil
+	}
+	for {
+		rest := w.out.Bytes()[w.scanned:]
+		end := bytes.IndexByte(rest, '\n')
+		if end < 0 {
+			return len(p), nil
+		}
+		w.scanned += end + 1
+		var ev testevent.Event
+		if json.Unmarshal(rest[:end], &ev) != nil || ev.Test == "" {
+			continue
+		}
+		switch {
+		case ev.Action == testevent.Run:
+			if !strings.Contains(ev.Test, "/") {
+				test := ev.Test
+				w.timers[test] = time.AfterFunc(w.timeout, func() { w.expire(test) })
+			}
+		case ev.Done():
+			w.finished[ev.Test] = true
+			if t := w.timers[ev.Test]; t != nil {
+				t.Stop()
+				delete(w.timers, ev.Test)
+			}
+		}
+	}
+}
+
+// expire kills the process for test unless it finished or another test
+// already hung
+func (w *watchdog) expire(test string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.hung != "" || w.finished[test] {
+		return
+	}
+	w.hung = test
+	w.kill()
+}
+
+// stop cancels the pending timers and returns the test the process was
+// killed for, or ""
+func (w *watchdog) stop() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, t := range w.timers {
+		t.Stop()
+	}
+	return w.hung
+}
+
+// timedOut keeps the results of the top-level tests that finished before
+// the watchdog killed their process and fails the unfinished parts of hung
+// with kind Timeout; the other tests it interrupted are dropped to run again
+func (w *watchdog) timedOut(results []Result, hung string) []Result {
+	kept := results[:0]
+	for _, res := range results {
+		top, _, _ := strings.Cut(res.Test, "/")
+		switch {
+		case top == hung && !w.finished[res.Test]:
+			res.Kind = Timeout
+			res.Output += fmt.Sprintf("test killed after the per-test timeout of %v\n", w.timeout)
+		case top != hung && !w.finished[top]:
+			continue
+		}
+		kept = append(kept, res)
+	}
+	return kept
+}
@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// addPackage writes a package of tests into the module at dir
+func addPackage(t *testing.T, dir, name, tests string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name, name+"_test.go"), []byte(tests), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunOnceParallel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	dir := writeModule(t)
+	addPackage(t, dir, "other", "package other\n\nimport \"testing\"\n\nfunc TestOther(t *testing.T) {}\n")
+	results, err := RunOnce(context.Background(), Config{Dir: dir, Packages: []string{"./..."}, Seed: 1, Parallel: 2}, 0)
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Package+"."+r.Test)
+	}
+	want := "example.com/seeded.TestSeedParity example.com/seeded.TestStable example.com/seeded/other.TestOther"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Expected results in package order %s, got %v", want, got)
+	}
+}
+
+// hungTests adds a test that never returns between two that pass
+const hungTests = `package seeded
+
+import "testing"
+
+func TestAFirst(t *testing.T) {}
+
+func TestBHangs(t *testing.T) { select {} }
+
+func TestCAfter(t *testing.T) {}
+`
+
+func TestPerTestTimeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	dir := writeModule(t)
+	if err := os.WriteFile(filepath.Join(dir, "hung_test.go"), []byte(hungTests), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	results, err := RunOnce(context.Background(), Config{Dir: dir, Seed: 2, Run: "^Test[ABC]", PerTestTimeout: time.Second}, 0)
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Minute {
+		t.Errorf("Expected the hung test to be killed after a second, took %v", elapsed)
+	}
+	outcomes := make(map[string]Result)
+	for _, r := range results {
+		outcomes[r.Test] = r
+	}
+	if len(results) != 3 || outcomes["TestAFirst"].Outcome != Pass || outcomes["TestCAfter"].Outcome != Pass {
+		t.Fatalf("Expected the tests around the hung one to pass once each, got %+v", results)
+	}
+	hung := outcomes["TestBHangs"]
+	if hung.Outcome != Fail || hung.Kind != Timeout || !strings.Contains(hung.Output, "per-test timeout of 1s") {
+		t.Errorf("Expected TestBHangs to fail with a timeout, got %+v", hung)
+	}
+	if runtime.GOOS != "windows" && !strings.Contains(hung.GoroutineDump(), "seeded.TestBHangs(") {
+		t.Errorf("Expected the goroutine dump of the killed test binary, got %q", hung.GoroutineDump())
+	}
+}
@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CorpusEntry is one seed corpus entry of a fuzz target, which go test runs
+// without -fuzz as a subtest of the target, such as FuzzParse/seed#2
+type CorpusEntry struct {
+	Target string
+	// Name is seed#N for the Nth f.Add call from zero, or the name of the
+	// entry's file in the corpus directory
+	Name string
+}
+
+// ParseCorpusEntry returns the corpus entry a test name is, if it is one
+func ParseCorpusEntry(test string) (CorpusEntry, bool) {
+	target, name, ok := strings.Cut(test, "/")
+	if !ok || name == "" || strings.Contains(name, "/") || !isFuzzTarget(target) {
+		return CorpusEntry{}, false
+	}
+	return CorpusEntry{Target: target, Name: name}, true
+}
+
+// isFuzzTarget reports whether name is a fuzz target's, by the rule go test
+// finds them with: Fuzz followed by anything but a lower case letter
+func isFuzzTarget(name string) bool {
+	rest, ok := strings.CutPrefix(name, "Fuzz")
+	if !ok {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(rest)
+	return rest == "" || !unicode.IsLower(r)
+}
+
+// Source says where the entry is defined: the f.Add call of a seed#N entry,
+// or its file under the package's testdata/fuzz
+func (e CorpusEntry) Source() string {
+	if n, ok := strings.CutPrefix(e.Name, "seed#"); ok {
+		if i, err := strconv.Atoi(n); err == nil {
+			return fmt.Sprintf("f.Add call %d", i+1)
+		}
+	}
+	return path.Join("testdata", "fuzz", e.Target, e.Name)
+}
+
+// FlakyCorpus returns the stats of the corpus entries that both passed and
+// failed, in report order
+// A flaky entry is an input whose outcome depends on the seed rather than
+// on the input, which fuzzing cannot tell from a fixed bug
+func (r *Report) FlakyCorpus() []*TestStats {
+	var flaky []*TestStats
+	for _, s := range r.Tests {
+		if _, ok := ParseCorpusEntry(s.Test); ok && s.Flaky() {
+			flaky = append(flaky, s)
+		}
+	}
+	return flaky
+}
@@ -0,0 +1,40 @@
+package runner
+
+import "testing"
+
+func TestParseCorpusEntry(t *testing.T) {
+	for test, want := range map[string]string{
+		"FuzzParse/seed#2":         "f.Add call 3",
+		"FuzzParse/trailing":       "testdata/fuzz/FuzzParse/trailing",
+		"Fuzz/seed#0":              "f.Add call 1",
+		"Fuzz_Parse/seed#x":        "testdata/fuzz/Fuzz_Parse/seed#x",
+		"FuzzyMatch/seed#0":        "",
+		"TestParse/seed#0":         "",
+		"FuzzParse":                "",
+		"FuzzParse/nested/subcase": "",
+	} {
+		e, ok := ParseCorpusEntry(test)
+		if got := e.Source(); ok != (want != "") || ok && got != want {
+			t.Errorf("%s: expected %q, got %q, %v", test, want, got, ok)
+		}
+	}
+}
+
+func TestFlakyCorpus(t *testing.T) {
+	report := Aggregate(2, []Result{
+		{Package: "p", Test: "FuzzParse", Run: 0, Outcome: Fail},
+		{Package: "p", Test: "FuzzParse/seed#0", Run: 0, Outcome: Pass},
+		{Package: "p", Test: "FuzzParse/seed#1", Run: 0, Outcome: Fail},
+		{Package: "p", Test: "FuzzParse/stuck", Run: 0, Outcome: Fail},
+		{Package: "p", Test: "FuzzParse", Run: 1, Outcome: Fail},
+		{Package: "p", Test: "FuzzParse/seed#0", Run: 1, Outcome: Pass},
+		{Package: "p", Test: "FuzzParse/seed#1", Run: 1, Outcome: Pass},
+		{Package: "p", Test: "FuzzParse/stuck", Run: 1, Outcome: Fail},
+		{Package: "p", Test: "TestTable/case", Run: 0, Outcome: Pass},
+		{Package: "p", Test: "TestTable/case", Run: 1, Outcome: Fail},
+	})
+	flaky := report.FlakyCorpus()
+	if len(flaky) != 1 || flaky[0].Test != "FuzzParse/seed#1" {
+		t.Errorf("Expected only FuzzParse/seed#1, got %+v", flaky)
+	}
+}
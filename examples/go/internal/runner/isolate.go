@@ -0,0 +1,77 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// listPackages expands the package patterns of cfg to import paths
+func listPackages(ctx context.Context, cfg Config) ([]string, error) {
+	args := append([]string{"list"}, packagesOrDefault(cfg.Packages)...)
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = append(os.Environ(), cfg.Env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go %s: %w\n%s", strings.Join(args, " "), err, stderr.String())
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// StateDependence compares a test that failed in the in-process runs with
+// its runs in a process of its own under the same seeds
+type StateDependence struct {
+	Package  string
+	Test     string
+	Together *TestStats
+	Isolated *TestStats
+}
+
+// DetectIsolated reruns the tests that failed in together, each in its own
+// process, with the run count and seeds of together, and returns the tests
+// that failed together but passed every isolated run: those depend on state
+// or order shared with the rest of their package
+// Seeded failures repeat under the same seed in both modes, so only the
+// difference a shared process makes is left
+func DetectIsolated(ctx context.Context, cfg Config, together *Report) ([]StateDependence, error) {
+	failed := together.failedTests()
+	if len(failed) == 0 {
+		return nil, nil
+	}
+	cfg.Runs = together.Runs
+	cfg.Run = RunPattern(failed)
+	cfg.Isolate = true
+	cfg.Adaptive = nil
+	cfg.RerunFailed = 0
+	cfg.MaxDuration = 0
+	cfg.Observe = nil
+	isolated, err := Detect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return CompareIsolated(together, isolated), nil
+}
+
+// CompareIsolated returns the tests that failed at least once in together
+// and ran in isolated without failing, sorted by package and name
+func CompareIsolated(together, isolated *Report) []StateDependence {
+	byTest := make(map[testKey]*TestStats, len(isolated.Tests))
+	for _, s := range isolated.Tests {
+		byTest[testKey{s.Package, s.Test}] = s
+	}
+	var dependent []StateDependence
+	for _, s := range together.Tests {
+		alone := byTest[testKey{s.Package, s.Test}]
+		if s.Failed == 0 || alone == nil || alone.Passed == 0 || alone.Failed > 0 {
+			continue
+		}
+		dependent = append(dependent, StateDependence{Package: s.Package, Test: s.Test, Together: s, Isolated: alone})
+	}
+	return dependent
+}
@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// stateTests adds a test that only fails after another left a global set
+const stateTests = `package seeded
+
+import "testing"
+
+var dirty bool
+
+func TestAPollutes(t *testing.T) { dirty = true }
+
+func TestBNeedsClean(t *testing.T) {
+	if dirty {
+		t.Error("state left behind by TestAPollutes")
+	}
+}
+`
+
+func TestDetectIsolated(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	dir := writeModule(t)
+	if err := os.WriteFile(filepath.Join(dir, "state_test.go"), []byte(stateTests), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{Dir: dir, Runs: 2, Seed: 10}
+	together, err := Detect(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+
+	dependent, err := DetectIsolated(context.Background(), cfg, together)
+	if err != nil {
+		t.Fatalf("DetectIsolated failed: %v", err)
+	}
+	if len(dependent) != 1 || dependent[0].Test != "TestBNeedsClean" {
+		t.Fatalf("Expected only TestBNeedsClean to depend on state, got %+v", dependent)
+	}
+	d := dependent[0]
+	if d.Together.Failed != 2 || d.Isolated.Passed != 2 || d.Isolated.Failed != 0 {
+		t.Errorf("Expected 2 failures together and 2 isolated passes, got %+v and %+v", d.Together, d.Isolated)
+	}
+}
+
+func TestRunOnceIsolated(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	results, err := RunOnce(context.Background(), Config{Dir: writeModule(t), Seed: 1, Run: "Parity", Isolate: true}, 0)
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Test != "TestSeedParity" || results[0].Outcome != Fail || results[0].Seed != 1 {
+		t.Errorf("Expected TestSeedParity alone to fail under seed 1, got %+v", results)
+	}
+}
+
+func TestCompareIsolated(t *testing.T) {
+	together := Aggregate(2, []Result{
+		{Package: "p", Test: "TestShared", Outcome: Fail},
+		{Package: "p", Test: "TestShared", Outcome: Fail},
+		{Package: "p", Test: "TestSeeded", Outcome: Fail, Seed: 1},
+		{Package: "p", Test: "TestSeeded", Outcome: Pass, Seed: 2},
+		{Package: "p", Test: "TestStable", Outcome: Pass},
+		{Package: "p", Test: "TestSkipped", Outcome: Fail},
+	})
+	isolated := Aggregate(2, []Result{
+		{Package: "p", Test: "TestShared", Outcome: Pass},
+		{Package: "p", Test: "TestShared", Outcome: Pass},
+		{Package: "p", Test: "TestSeeded", Outcome: Fail, Seed: 1},
+		{Package: "p", Test: "TestSeeded", Outcome: Pass, Seed: 2},
+		{Package: "p", Test: "TestSkipped", Outcome: Skip},
+	})
+	got := CompareIsolated(together, isolated)
+	if len(got) != 1 || got[0].Test != "TestShared" {
+		t.Errorf("Expected only TestShared, got %+v", got)
+	}
+}
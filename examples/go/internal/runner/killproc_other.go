@@ -0,0 +1,18 @@
+//go:build !unix
+
+package runner
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// killProcessGroup leaves cmd as it is; without process groups the test
+// binary of a killed go test runs on until its own -timeout
+func killProcessGroup(cmd *exec.Cmd) {}
+
+// quitProcessGroup fails; without SIGQUIT the hung test binary is killed
+// without a goroutine dump
+func quitProcessGroup(cmd *exec.Cmd) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package runner
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroup starts cmd in a process group of its own and has its
+// context kill the whole group, test binary included
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// quitProcessGroup sends SIGQUIT to the process group of cmd, which makes
+// the test binary print every goroutine's stack and exit while go itself
+// waits for it
+func quitProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGQUIT)
+}
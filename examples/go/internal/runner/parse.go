@@ -0,0 +1,231 @@
+package runner
+
+import (
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/attach"
+	"github.com/example/flaky-test-example/internal/protocol"
+	"github.com/example/flaky-test-example/testevent"
+)
+
+// Outcome is the final action go test reported for a test
+type Outcome string
+
+const (
+	Pass = Outcome(testevent.Pass)
+	Fail = Outcome(testevent.Fail)
+	Skip = Outcome(testevent.Skip)
+)
+
+// FailureKind says how a failing run failed
+type FailureKind string
+
+const (
+	// Assertion is a test that reported its own failure, such as with t.Error
+	Assertion FailureKind = "assertion"
+	// Race is a failure the race detector reported
+	Race FailureKind = "race"
+	// Panic is a test that panicked or called runtime.Goexit; go test still
+	// reports it failed, but the test binary stops there
+	Panic FailureKind = "panic"
+	// Timeout is a test still running when go test's -timeout expired
+	Timeout FailureKind = "timeout"
+	// Crash is a test still running when the test binary exited, such as
+	// through os.Exit or log.Fatal, or died, such as of a segfault or the
+	// kernel's out-of-memory killer
+	Crash FailureKind = "crash"
+)
+
+// Result is one test's outcome in one run of the suite
+type Result struct {
+	Package  string
+	Test     string
+	Run      int
+	Seed     int64
+	Outcome  Outcome
+	Duration time.Duration
+	Output   string
+	// Kind is how a failing run failed, empty for other outcomes
+	Kind FailureKind
+	// Signal names the signal a Crash's test binary died of, such as
+	// "SIGSEGV" or "SIGKILL", and ExitCode its exit status, each empty or 0
+	// when the output does not say
+	Signal   string
+	ExitCode int
+	// Start is when go test reported the test starting, or zero when it
+	// did not
+	Start time.Time
+	// Meta holds the injected conditions the test logged with flaky.Report
+	Meta []protocol.FailureMeta
+	// Attachments are the stored outputs of a failing run when
+	// Config.Attachments is set: the test's own, then the stdout and stderr
+	// of its go test process
+	Attachments []attach.Ref
+}
+
+type testKey struct {
+	pkg, test string
+}
+
+// Parse reads a go test -json stream and returns a Result for every test that
+// reported pass, fail or skip
+// A test binary that dies mid-test reports nothing for the running tests, so
+// those get a failing Result of kind Timeout or Crash when their package ends
+// Lines that are not test2json events, such as build errors, are ignored
+func Parse(r io.Reader, run int, seed int64) ([]Result, error) {
+	runs, err := testevent.ReadAll(r)
+	results := make([]Result, 0, len(runs))
+	for _, tr := range runs {
+		res := Result{
+			Package:  tr.Package,
+			Test:     tr.Test,
+			Run:      run,
+			Seed:     seed,
+			Outcome:  Outcome(tr.Outcome),
+			Duration: tr.Elapsed,
+			Output:   tr.Output,
+			Start:    tr.Start,
+			Meta:     failureMeta(tr.Output),
+		}
+		switch {
+		case res.Outcome == Fail && tr.Finished:
+			res.Kind = failureKind(tr.Output)
+		case res.Outcome == Fail:
+			res.Kind = unfinishedKind(tr.Output)
+			if res.Kind == Crash {
+				res.Signal, res.ExitCode = crashExit(tr.Output)
+			}
+		}
+		results = append(results, res)
+	}
+	return results, err
+}
+
+// failureMeta returns the flaky.Report lines in output
+func failureMeta(output string) []protocol.FailureMeta {
+	if !strings.Contains(output, protocol.MetaLogPrefix) {
+		return nil
+	}
+	var metas []protocol.FailureMeta
+	for _, line := range strings.Split(output, "\n") {
+		if meta, ok := protocol.ParseMetaLine(line); ok {
+			metas = append(metas, meta)
+		}
+	}
+	return metas
+}
+
+// failureKind classifies the output of a test that reported failing
+func failureKind(output string) FailureKind {
+	switch {
+	case strings.Contains(output, raceWarning):
+		return Race
+	case panicLine(output) != "":
+		return Panic
+	}
+	return Assertion
+}
+
+// unfinishedKind classifies the output of a test that never reported an
+// outcome
+func unfinishedKind(output string) FailureKind {
+	if strings.HasPrefix(panicLine(output), timeoutPanic) {
+		return Timeout
+	}
+	return Crash
+}
+
+// timeoutPanic opens the panic go test -timeout raises
+const timeoutPanic = "panic: test timed out after "
+
+// panicLine returns the first line of a panic in output, or ""; the
+// traceback that follows it is dropped
+func panicLine(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "panic: ") {
+			return line
+		}
+	}
+	return ""
+}
+
+// raceWarning opens each report the race detector prints
+const raceWarning = "WARNING: DATA RACE"
+
+// raceAccess matches the access lines of a race report, such as
+// "Previous write at 0x00c000018b30 by goroutine 9:"
+var raceAccess = regexp.MustCompile(`^(?i)(previous )?(atomic )?(read|write) at 0x[0-9a-f]+ by `)
+
+// FailureMessages extracts the messages a test logged, dropping the framing
+// lines go test adds around them and the lines of flaky.Report
+// Each race detector report becomes a single message naming where the racing
+// accesses happened, so it reads the same whichever goroutines raced, and a
+// panic ends the messages with its first line
+func FailureMessages(output string) []string {
+	var messages []string
+	lines := strings.Split(output, "\n")
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		switch {
+		case strings.HasPrefix(lines[i], "panic: "):
+			// The traceback after a panic holds no further messages
+			msg, _, _ := strings.Cut(lines[i], " [recovered")
+			return append(messages, msg)
+		case isRuntimeCrash(lines[i]):
+			// Neither does the dump after a fatal error or signal
+			return append(messages, lines[i])
+		case trimmed == raceWarning:
+			var msg string
+			msg, i = raceMessage(lines, i+1)
+			messages = append(messages, msg)
+		case trimmed == "" || isFramingLine(trimmed):
+		case strings.Contains(trimmed, protocol.MetaLogPrefix):
+			// Structured metadata, collected into Result.Meta instead
+		default:
+			messages = append(messages, trimmed)
+		}
+	}
+	return messages
+}
+
+// raceMessage summarises the race report starting at lines[i] and returns
+// the index of its closing line
+func raceMessage(lines []string, i int) (string, int) {
+	var accesses []string
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if isRaceSeparator(line) {
+			break
+		}
+		if !raceAccess.MatchString(line) {
+			continue
+		}
+		access, _, _ := strings.Cut(line, " at 0x")
+		access = strings.ToLower(access)
+		// The access's innermost frame is a function line then its location
+		if i+2 < len(lines) {
+			loc, _, _ := strings.Cut(strings.TrimSpace(lines[i+2]), " ")
+			access += " at " + filepath.Base(loc)
+		}
+		accesses = append(accesses, access)
+	}
+	return "data race: " + strings.Join(accesses, ", "), i
+}
+
+// isRaceSeparator matches the rule of = signs around a race report
+func isRaceSeparator(line string) bool {
+	return line != "" && strings.Trim(line, "=") == ""
+}
+
+func isFramingLine(line string) bool {
+	for _, prefix := range []string{"=== RUN", "=== PAUSE", "=== CONT", "=== NAME", "--- PASS", "--- FAIL", "--- SKIP"} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return line == "PASS" || line == "FAIL" || isRaceSeparator(line)
+}
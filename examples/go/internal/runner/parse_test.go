@@ -0,0 +1,182 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleStream = `{"Action":"start","Package":"example/pkg"}
+{"Action":"run","Package":"example/pkg","Test":"TestA"}
+{"Action":"output","Package":"example/pkg","Test":"TestA","Output":"=== RUN   TestA\n"}
+{"Action":"output","Package":"example/pkg","Test":"TestA","Output":"    a_test.go:10: Random failure: got 0.812\n"}
+{"Action":"output","Package":"example/pkg","Test":"TestA","Output":"--- FAIL: TestA (0.01s)\n"}
+{"Action":"fail","Package":"example/pkg","Test":"TestA","Elapsed":0.01}
+# example/pkg [build noise that is not JSON]
+{"Time":"2024-05-01T10:00:00Z","Action":"run","Package":"example/pkg","Test":"TestB"}
+{"Action":"output","Package":"example/pkg","Test":"TestB","Output":"=== RUN   TestB\n"}
+{"Action":"pass","Package":"example/pkg","Test":"TestB","Elapsed":0.25}
+{"Action":"run","Package":"example/pkg","Test":"TestC"}
+{"Action":"skip","Package":"example/pkg","Test":"TestC","Elapsed":0}
+{"Action":"fail","Package":"example/pkg","Elapsed":0.3}
+`
+
+func TestParse(t *testing.T) {
+	results, err := Parse(strings.NewReader(sampleStream), 3, 45)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d: %+v", len(results), results)
+	}
+
+	a := results[0]
+	if a.Test != "TestA" || a.Outcome != Fail || a.Run != 3 || a.Seed != 45 {
+		t.Errorf("Unexpected first result: %+v", a)
+	}
+	if !strings.Contains(a.Output, "Random failure: got 0.812") {
+		t.Errorf("Expected failure output to be captured, got %q", a.Output)
+	}
+	if results[1].Outcome != Pass || results[1].Duration != 250*time.Millisecond {
+		t.Errorf("Unexpected second result: %+v", results[1])
+	}
+	if start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC); !results[1].Start.Equal(start) {
+		t.Errorf("Expected TestB to start at %v, got %v", start, results[1].Start)
+	}
+	if results[2].Outcome != Skip {
+		t.Errorf("Expected TestC to be skipped, got %+v", results[2])
+	}
+}
+
+func TestFailureMessages(t *testing.T) {
+	output := "=== RUN   TestA\n    a_test.go:10: first\n    a_test.go:11: second\n--- FAIL: TestA (0.00s)\n"
+	got := FailureMessages(output)
+	want := []string{"a_test.go:10: first", "a_test.go:11: second"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Message %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestParseCollectsFailureMeta(t *testing.T) {
+	meta := `{"test":"TestA","seed":7,"test_seed":99,"scenario":"DiskFull","params":{"failure_rate":0.2,"fault":"no-space"},"time":"2024-05-01T10:00:00Z"}`
+	stream := `{"Action":"output","Package":"p","Test":"TestA","Output":"    a_test.go:10: config corrupted\n"}
+{"Action":"output","Package":"p","Test":"TestA","Output":"    meta.go:58: flaky-meta: ` + strings.ReplaceAll(meta, `"`, `\"`) + `\n"}
+{"Action":"fail","Package":"p","Test":"TestA","Elapsed":0}
+`
+	results, err := Parse(strings.NewReader(stream), 0, 7)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(results) != 1 || len(results[0].Meta) != 1 {
+		t.Fatalf("Expected one result with metadata, got %+v", results)
+	}
+	m := results[0].Meta[0]
+	if m.Scenario != "DiskFull" || m.Seed != 7 || m.TestSeed != 99 || m.Params["fault"] != "no-space" {
+		t.Errorf("Unexpected metadata: %+v", m)
+	}
+	if msgs := FailureMessages(results[0].Output); len(msgs) != 1 || msgs[0] != "a_test.go:10: config corrupted" {
+		t.Errorf("Expected the metadata line left out of the messages, got %q", msgs)
+	}
+}
+
+// raceOutput is a race detector report as go test -race prints it
+const raceOutput = `=== RUN   TestRace
+==================
+WARNING: DATA RACE
+Read at 0x00c000018b30 by goroutine 8:
+  example/pkg.TestRace.func1()
+      /src/pkg/race_test.go:42 +0x11e
+
+Previous write at 0x00c000018b30 by goroutine 9:
+  example/pkg.TestRace.func1()
+      /src/pkg/race_test.go:43 +0x133
+
+Goroutine 8 (running) created at:
+  example/pkg.TestRace()
+      /src/pkg/race_test.go:36 +0x230
+==================
+    testing.go:1865: race detected during execution of test
+--- FAIL: TestRace (0.00s)
+`
+
+func TestFailureMessagesSummarisesRaceReports(t *testing.T) {
+	got := FailureMessages(raceOutput)
+	want := []string{
+		"data race: read at race_test.go:42, previous write at race_test.go:43",
+		"testing.go:1865: race detected during execution of test",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %q, got %q", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Message %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestParseMarksRaceFailures(t *testing.T) {
+	var stream strings.Builder
+	for _, line := range strings.SplitAfter(raceOutput, "\n") {
+		if line != "" {
+			fmt.Fprintf(&stream, `{"Action":"output","Package":"p","Test":"TestRace","Output":%q}`+"\n", line)
+		}
+	}
+	stream.WriteString(`{"Action":"fail","Package":"p","Test":"TestRace","Elapsed":0}` + "\n")
+	stream.WriteString(`{"Action":"output","Package":"p","Test":"TestA","Output":"    a_test.go:10: boom\n"}` + "\n")
+	stream.WriteString(`{"Action":"fail","Package":"p","Test":"TestA","Elapsed":0}` + "\n")
+
+	results, err := Parse(strings.NewReader(stream.String()), 0, 1)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Kind != Race || results[1].Kind != Assertion {
+		t.Errorf("Expected a race and an assertion failure, got %+v", results)
+	}
+}
+
+func TestParseClassifiesPanicsCrashesAndTimeouts(t *testing.T) {
+	stream := `{"Action":"run","Package":"p","Test":"TestPanic"}
+{"Action":"output","Package":"p","Test":"TestPanic","Output":"--- FAIL: TestPanic (0.00s)\n"}
+{"Action":"output","Package":"p","Test":"TestPanic","Output":"panic: boom [recovered, repanicked]\n"}
+{"Action":"output","Package":"p","Test":"TestPanic","Output":"\n"}
+{"Action":"output","Package":"p","Test":"TestPanic","Output":"goroutine 8 [running]:\n"}
+{"Action":"output","Package":"p","Test":"TestPanic","Output":"testing.tRunner.func1.2({0x6b41b8, 0x563580})\n"}
+{"Action":"fail","Package":"p","Test":"TestPanic","Elapsed":0}
+{"Action":"fail","Package":"p","Elapsed":0.01}
+{"Time":"2026-01-01T00:00:00Z","Action":"run","Package":"q","Test":"TestExit"}
+{"Action":"output","Package":"q","Test":"TestExit","Output":"=== RUN   TestExit\n"}
+{"Time":"2026-01-01T00:00:00.5Z","Action":"fail","Package":"q","Elapsed":0.5}
+{"Action":"run","Package":"r","Test":"TestHang"}
+{"Action":"output","Package":"r","Test":"TestHang","Output":"panic: test timed out after 1s\n"}
+{"Action":"output","Package":"r","Test":"TestHang","Output":"\trunning tests:\n"}
+{"Action":"output","Package":"r","Test":"TestHang","Output":"\t\tTestHang (1s)\n"}
+{"Action":"fail","Package":"r","Elapsed":1}
+{"Action":"run","Package":"s","Test":"TestKilled"}
+`
+	results, err := Parse(strings.NewReader(stream), 0, 1)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := map[string]FailureKind{"TestPanic": Panic, "TestExit": Crash, "TestHang": Timeout, "TestKilled": Crash}
+	if len(results) != len(want) {
+		t.Fatalf("Expected %d results, got %+v", len(want), results)
+	}
+	for _, r := range results {
+		if r.Outcome != Fail || r.Kind != want[r.Test] {
+			t.Errorf("Expected %s to fail with kind %s, got %s %s", r.Test, want[r.Test], r.Outcome, r.Kind)
+		}
+	}
+	if exit := results[1]; exit.Duration != 500*time.Millisecond {
+		t.Errorf("Expected TestExit to have run 500ms before the binary exited, got %v", exit.Duration)
+	}
+	if msgs := FailureMessages(results[0].Output); len(msgs) != 1 || msgs[0] != "panic: boom" {
+		t.Errorf("Expected only the panic line as a message, got %q", msgs)
+	}
+}
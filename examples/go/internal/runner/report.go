@@ -0,0 +1,239 @@
+package runner
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/attach"
+	"github.com/example/flaky-test-example/internal/fingerprint"
+	"github.com/example/flaky-test-example/internal/protocol"
+)
+
+// TestStats aggregates one test's results across runs
+type TestStats struct {
+	Package       string
+	Test          string
+	Passed        int
+	Failed        int
+	Skipped       int
+	TotalDuration time.Duration
+	// FailureMessages holds each distinct failure message in first-seen order
+	FailureMessages []string
+	// FailingSeeds holds the seed of every failing run in run order
+	FailingSeeds []int64
+	// Kinds counts the failing runs by how they failed
+	Kinds map[FailureKind]int
+	// Crashes counts the runs whose test binary crashed in the test by how
+	// it ended, as Result.Exit describes it or UnknownExit
+	Crashes map[string]int
+	// Meta holds what the failing runs logged with flaky.Report, in run
+	// order
+	Meta []protocol.FailureMeta
+	// SubtestFailures counts the failing runs in which one of the test's
+	// subtests failed too, so the failure belongs to the subtest
+	SubtestFailures int
+	// Attachments are the stored outputs of the failing runs, in run order,
+	// when Config.Attachments was set
+	Attachments []attach.Attachment
+}
+
+// Parent returns the name of the test a subtest runs under, or "" for a
+// top-level test
+func (s *TestStats) Parent() string {
+	i := strings.LastIndex(s.Test, "/")
+	if i < 0 {
+		return ""
+	}
+	return s.Test[:i]
+}
+
+// FailsThroughSubtests reports whether every failing run of the test had a
+// failing subtest, as a table-driven test with flaky cases does; the cases
+// are then what is flaky, and the test only by inheritance
+func (s *TestStats) FailsThroughSubtests() bool {
+	return s.Failed > 0 && s.SubtestFailures == s.Failed
+}
+
+// Runs returns the number of runs the test reported an outcome in
+func (s *TestStats) Runs() int {
+	return s.Passed + s.Failed + s.Skipped
+}
+
+// PassRate returns the fraction of non-skipped runs that passed
+func (s *TestStats) PassRate() float64 {
+	executed := s.Passed + s.Failed
+	if executed == 0 {
+		return 0
+	}
+	return float64(s.Passed) / float64(executed)
+}
+
+// MeanDuration returns the mean duration over all reported runs
+func (s *TestStats) MeanDuration() time.Duration {
+	if s.Runs() == 0 {
+		return 0
+	}
+	return s.TotalDuration / time.Duration(s.Runs())
+}
+
+// Flaky reports whether the test both passed and failed
+func (s *TestStats) Flaky() bool {
+	return s.Passed > 0 && s.Failed > 0
+}
+
+// Classification buckets a test by its outcomes across runs
+type Classification string
+
+const (
+	Stable  Classification = "stable"
+	Flaky   Classification = "flaky"
+	Failing Classification = "failing"
+	Skipped Classification = "skipped"
+)
+
+// Classify returns stable when every executed run passed, failing when every
+// one failed, flaky when both happened and skipped when none executed
+func (s *TestStats) Classify() Classification {
+	switch {
+	case s.Passed == 0 && s.Failed == 0:
+		return Skipped
+	case s.Failed == 0:
+		return Stable
+	case s.Passed == 0:
+		return Failing
+	default:
+		return Flaky
+	}
+}
+
+// FlakeRate returns the fraction of executed runs that failed
+func (s *TestStats) FlakeRate() float64 {
+	if s.Passed+s.Failed == 0 {
+		return 0
+	}
+	return 1 - s.PassRate()
+}
+
+// Report is the aggregated outcome of a detection sweep
+type Report struct {
+	Runs    int
+	Results []Result
+	Tests   []*TestStats
+	// Reran lists the top-level tests Config.RerunFailed ran again
+	Reran []string
+	// OutOfTime is set when Config.MaxDuration stopped the sweep early
+	OutOfTime bool
+	// Fingerprint is the environment Detect ran the sweep in, zero for a
+	// report built some other way
+	Fingerprint fingerprint.Fingerprint
+}
+
+// Aggregate builds a Report from raw results, with tests sorted by package
+// and name
+func Aggregate(runs int, results []Result) *Report {
+	// failedBelow holds the tests with a failing subtest in each run
+	type runTest struct {
+		testKey
+		run int
+	}
+	failedBelow := make(map[runTest]bool)
+	for _, r := range results {
+		if r.Outcome != Fail {
+			continue
+		}
+		for i := strings.LastIndex(r.Test, "/"); i > 0; i = strings.LastIndex(r.Test[:i], "/") {
+			failedBelow[runTest{testKey{r.Package, r.Test[:i]}, r.Run}] = true
+		}
+	}
+
+	byTest := make(map[testKey]*TestStats)
+	for _, r := range results {
+		key := testKey{r.Package, r.Test}
+		stats := byTest[key]
+		if stats == nil {
+			stats = &TestStats{Package: r.Package, Test: r.Test}
+			byTest[key] = stats
+		}
+		stats.TotalDuration += r.Duration
+		switch r.Outcome {
+		case Pass:
+			stats.Passed++
+		case Fail:
+			stats.Failed++
+			stats.FailingSeeds = append(stats.FailingSeeds, r.Seed)
+			if stats.Kinds == nil {
+				stats.Kinds = make(map[FailureKind]int)
+			}
+			stats.Kinds[r.Kind]++
+			if r.Kind == Crash {
+				if stats.Crashes == nil {
+					stats.Crashes = make(map[string]int)
+				}
+				exit := r.Exit()
+				if exit == "" {
+					exit = UnknownExit
+				}
+				stats.Crashes[exit]++
+			}
+			stats.Meta = append(stats.Meta, r.Meta...)
+			if len(r.Attachments) > 0 {
+				stats.Attachments = append(stats.Attachments, r.Attachment())
+			}
+			if failedBelow[runTest{key, r.Run}] {
+				stats.SubtestFailures++
+			}
+			for _, msg := range FailureMessages(r.Output) {
+				stats.addMessage(msg)
+			}
+		case Skip:
+			stats.Skipped++
+		}
+	}
+
+	report := &Report{Runs: runs, Results: results}
+	for _, stats := range byTest {
+		report.Tests = append(report.Tests, stats)
+	}
+	sort.Slice(report.Tests, func(i, j int) bool {
+		a, b := report.Tests[i], report.Tests[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		return a.Test < b.Test
+	})
+	return report
+}
+
+// BelowPassRate returns the executed tests whose pass rate is under threshold
+// A parent that only failed through subtests is left out when one of those
+// subtests is returned, so the flaky case is what gets quarantined rather
+// than the whole table
+func (r *Report) BelowPassRate(threshold float64) []*TestStats {
+	var below []*TestStats
+	for _, stats := range r.Tests {
+		if stats.Classify() != Skipped && stats.PassRate() < threshold {
+			below = append(below, stats)
+		}
+	}
+	var kept []*TestStats
+	for _, parent := range below {
+		if parent.FailsThroughSubtests() && slices.ContainsFunc(below, func(sub *TestStats) bool {
+			return sub.Package == parent.Package && strings.HasPrefix(sub.Test, parent.Test+"/")
+		}) {
+			continue
+		}
+		kept = append(kept, parent)
+	}
+	return kept
+}
+
+func (s *TestStats) addMessage(msg string) {
+	for _, existing := range s.FailureMessages {
+		if existing == msg {
+			return
+		}
+	}
+	s.FailureMessages = append(s.FailureMessages, msg)
+}
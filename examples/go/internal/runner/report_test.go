@@ -0,0 +1,140 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAggregate(t *testing.T) {
+	results := []Result{
+		{Package: "p", Test: "TestB", Run: 0, Seed: 1, Outcome: Pass, Duration: 10 * time.Millisecond},
+		{Package: "p", Test: "TestA", Run: 0, Seed: 1, Outcome: Fail, Duration: 20 * time.Millisecond, Output: "    x_test.go:1: boom\n"},
+		{Package: "p", Test: "TestA", Run: 1, Seed: 2, Outcome: Pass, Duration: 40 * time.Millisecond},
+		{Package: "p", Test: "TestA", Run: 2, Seed: 3, Outcome: Fail, Duration: 30 * time.Millisecond, Output: "    x_test.go:1: boom\n"},
+		{Package: "p", Test: "TestB", Run: 1, Seed: 2, Outcome: Skip},
+	}
+	report := Aggregate(3, results)
+
+	if len(report.Tests) != 2 || report.Tests[0].Test != "TestA" || report.Tests[1].Test != "TestB" {
+		t.Fatalf("Expected tests sorted as TestA, TestB, got %+v", report.Tests)
+	}
+
+	a := report.Tests[0]
+	if a.Runs() != 3 || a.Passed != 1 || a.Failed != 2 {
+		t.Errorf("Unexpected TestA tallies: %+v", a)
+	}
+	if rate := a.PassRate(); rate < 0.333 || rate > 0.334 {
+		t.Errorf("Expected TestA pass rate 1/3, got %v", rate)
+	}
+	if a.MeanDuration() != 30*time.Millisecond {
+		t.Errorf("Expected mean duration 30ms, got %v", a.MeanDuration())
+	}
+	if len(a.FailureMessages) != 1 || a.FailureMessages[0] != "x_test.go:1: boom" {
+		t.Errorf("Expected one distinct failure message, got %v", a.FailureMessages)
+	}
+	if len(a.FailingSeeds) != 2 || a.FailingSeeds[0] != 1 || a.FailingSeeds[1] != 3 {
+		t.Errorf("Expected failing seeds [1 3], got %v", a.FailingSeeds)
+	}
+	if !a.Flaky() {
+		t.Error("Expected TestA to be flaky")
+	}
+	if a.Kinds[Race] != 0 {
+		t.Errorf("Expected no race failures, got %d", a.Kinds[Race])
+	}
+
+	b := report.Tests[1]
+	if b.PassRate() != 1 || b.Skipped != 1 || b.Flaky() {
+		t.Errorf("Unexpected TestB stats: %+v", b)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	for _, tc := range []struct {
+		stats TestStats
+		want  Classification
+	}{
+		{TestStats{Passed: 5}, Stable},
+		{TestStats{Passed: 3, Failed: 2}, Flaky},
+		{TestStats{Failed: 5}, Failing},
+		{TestStats{Skipped: 5}, Skipped},
+	} {
+		if got := tc.stats.Classify(); got != tc.want {
+			t.Errorf("%+v: expected %s, got %s", tc.stats, tc.want, got)
+		}
+	}
+	if rate := (&TestStats{Passed: 3, Failed: 1}).FlakeRate(); rate != 0.25 {
+		t.Errorf("Expected flake rate 0.25, got %v", rate)
+	}
+}
+
+func TestBelowPassRate(t *testing.T) {
+	report := Aggregate(2, []Result{
+		{Package: "p", Test: "TestStable", Outcome: Pass},
+		{Package: "p", Test: "TestStable", Outcome: Pass},
+		{Package: "p", Test: "TestFlaky", Outcome: Pass},
+		{Package: "p", Test: "TestFlaky", Outcome: Fail},
+		{Package: "p", Test: "TestSkipped", Outcome: Skip},
+	})
+	below := report.BelowPassRate(0.9)
+	if len(below) != 1 || below[0].Test != "TestFlaky" {
+		t.Errorf("Expected only TestFlaky below 90%%, got %+v", below)
+	}
+}
+
+func TestAggregateSubtestFailures(t *testing.T) {
+	report := Aggregate(3, []Result{
+		{Package: "p", Test: "TestTable", Run: 0, Outcome: Fail},
+		{Package: "p", Test: "TestTable/a", Run: 0, Outcome: Pass},
+		{Package: "p", Test: "TestTable/b", Run: 0, Outcome: Fail},
+		{Package: "p", Test: "TestTable", Run: 1, Outcome: Pass},
+		{Package: "p", Test: "TestTable/a", Run: 1, Outcome: Pass},
+		{Package: "p", Test: "TestTable/b", Run: 1, Outcome: Pass},
+		// The parent's own cleanup failed in run 2
+		{Package: "p", Test: "TestOwn", Run: 2, Outcome: Fail},
+		{Package: "p", Test: "TestOwn/a", Run: 2, Outcome: Pass},
+		{Package: "p", Test: "TestOwn", Run: 1, Outcome: Pass},
+		{Package: "p", Test: "TestOwn/a", Run: 1, Outcome: Pass},
+	})
+	byName := make(map[string]*TestStats)
+	for _, s := range report.Tests {
+		byName[s.Test] = s
+	}
+	if s := byName["TestTable"]; s.SubtestFailures != 1 || !s.FailsThroughSubtests() {
+		t.Errorf("Expected TestTable to fail only through subtests, got %+v", s)
+	}
+	if s := byName["TestOwn"]; s.SubtestFailures != 0 || s.FailsThroughSubtests() {
+		t.Errorf("Expected TestOwn to fail on its own, got %+v", s)
+	}
+	if parent := byName["TestTable/b"].Parent(); parent != "TestTable" {
+		t.Errorf("Expected parent TestTable, got %q", parent)
+	}
+	if parent := byName["TestTable"].Parent(); parent != "" {
+		t.Errorf("Expected no parent for a top-level test, got %q", parent)
+	}
+
+	var below []string
+	for _, s := range report.BelowPassRate(0.9) {
+		below = append(below, s.Test)
+	}
+	if want := []string{"TestOwn", "TestTable/b"}; !reflect.DeepEqual(below, want) {
+		t.Errorf("Expected %v below 90%%, got %v", want, below)
+	}
+}
+
+func TestAggregateCountsFailureKinds(t *testing.T) {
+	report := Aggregate(4, []Result{
+		{Package: "p", Test: "TestA", Seed: 1, Outcome: Fail, Kind: Race},
+		{Package: "p", Test: "TestA", Seed: 2, Outcome: Fail, Kind: Crash, Signal: "SIGKILL"},
+		{Package: "p", Test: "TestA", Seed: 3, Outcome: Fail, Kind: Crash},
+		{Package: "p", Test: "TestA", Seed: 4, Outcome: Pass},
+	})
+	want := map[FailureKind]int{Race: 1, Crash: 2}
+	if got := report.Tests[0].Kinds; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected kinds %v, got %v", want, got)
+	}
+	wantCrashes := map[string]int{"SIGKILL": 1, UnknownExit: 1}
+	if got := report.Tests[0].Crashes; !reflect.DeepEqual(got, wantCrashes) {
+		t.Errorf("Expected crashes %v, got %v", wantCrashes, got)
+	}
+}
@@ -0,0 +1,458 @@
+// Package runner executes go test repeatedly and aggregates per-test outcomes
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/attach"
+	"github.com/example/flaky-test-example/internal/fingerprint"
+	"github.com/example/flaky-test-example/internal/protocol"
+	"github.com/example/flaky-test-example/stats"
+)
+
+// SeedEnv is the variable each run's seed is passed to the tests in
+const SeedEnv = protocol.SeedEnv
+
+// Config describes a detection sweep
+type Config struct {
+	// Packages are the package patterns passed to go test
+	Packages []string
+	// Runs is the number of times the suite is executed
+	Runs int
+	// Seed is the seed of the first run; run i uses Seed+i
+	Seed int64
+	// Run is an optional -run regex
+	Run string
+	// Dir is the directory go test runs in
+	Dir string
+	// Args are extra arguments passed to go test before the packages
+	Args []string
+	// Env holds additional KEY=VALUE pairs for every run
+	Env []string
+	// Adaptive, when set, keeps rerunning only the tests without a verdict;
+	// Runs is then the minimum number of runs
+	Adaptive *Adaptive
+	// RerunFailed, when positive, reruns only the tests that failed at least
+	// once this many more times after the other runs, tightening their flake
+	// rates without paying for the rest of the suite
+	RerunFailed int
+	// MaxDuration, when positive, stops starting runs once this much time
+	// has passed, leaving the tests without enough runs undecided
+	MaxDuration time.Duration
+	// Observe, when set, receives each run's results as soon as the run
+	// finishes
+	Observe func(run int, results []Result)
+	// Isolate runs every top-level test in a go test process of its own,
+	// Parallel at a time, so tests cannot share in-process state
+	Isolate bool
+	// Parallel is the number of go test processes a run keeps going at
+	// once; above 1 every package, or with Isolate every test, runs in a
+	// process of its own
+	Parallel int
+	// PerTestTimeout, when positive, kills the go test process of a package
+	// once a top-level test has run this long, fails that test with kind
+	// Timeout and runs the package's unfinished tests again without it, so
+	// a hung test costs its own result rather than the sweep
+	PerTestTimeout time.Duration
+	// Checkpoint, when set, is the file Detect saves its progress to after
+	// runs, at most every CheckpointInterval, and when it fails; it is
+	// removed once the sweep finishes
+	Checkpoint string
+	// CheckpointInterval is the least time between checkpoints; zero saves
+	// after every run
+	CheckpointInterval time.Duration
+	// Resume continues the sweep a checkpoint was saved from instead of
+	// starting it over
+	Resume *Checkpoint
+	// Attachments, when set, stores the output of every failing run, with
+	// the stdout and stderr of its go test process
+	Attachments *attach.Store
+}
+
+// Adaptive stops rerunning a test once its confidence interval is tight enough
+// for stats.Classify to call it stable, flaky or broken
+type Adaptive struct {
+	Confidence float64
+	Tolerance  float64
+	MaxRuns    int
+	// SPRT, when set, decides instead with a sequential probability ratio
+	// test, which drops stable tests after far fewer runs and keeps
+	// rerunning tests whose rate is close to the boundary
+	SPRT *stats.SPRT
+}
+
+// Verdict classifies a test from its runs so far
+func (a *Adaptive) Verdict(s *TestStats) stats.Verdict {
+	if a.SPRT != nil {
+		return a.SPRT.Decide(s.Passed, s.Failed)
+	}
+	return stats.Classify(stats.EstimateCounts(s.Passed, s.Failed, a.Confidence), a.Tolerance)
+}
+
+// Detect runs the suite cfg.Runs times and aggregates the results
+func Detect(ctx context.Context, cfg Config) (*Report, error) {
+	if cfg.Runs < 1 {
+		return nil, fmt.Errorf("runs must be at least 1, got %d", cfg.Runs)
+	}
+	if cfg.Adaptive != nil && cfg.Adaptive.SPRT != nil {
+		if err := cfg.Adaptive.SPRT.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	maxRuns := cfg.Runs
+	if cfg.Adaptive != nil && cfg.Adaptive.MaxRuns > maxRuns {
+		maxRuns = cfg.Adaptive.MaxRuns
+	}
+
+	if cfg.Resume != nil {
+		if err := cfg.Resume.Matches(cfg); err != nil {
+			return nil, err
+		}
+	}
+	sweep := cfg
+
+	start := time.Now()
+	outOfTime := false
+	run := 0
+	var results []Result
+	// reran is set once the RerunFailed runs start, at run rerunFrom
+	var reran []string
+	rerunFrom := 0
+	if cp := cfg.Resume; cp != nil {
+		start = start.Add(-cp.Elapsed)
+		run, results = cp.Completed, slices.Clone(cp.Results)
+		reran, rerunFrom = cp.Reran, cp.RerunFrom
+	}
+
+	var saved time.Time
+	// checkpoint saves the progress after completed runs, unless it was
+	// saved less than CheckpointInterval ago and force is false
+	checkpoint := func(completed int, force bool) error {
+		if sweep.Checkpoint == "" || (!force && time.Since(saved) < sweep.CheckpointInterval) {
+			return nil
+		}
+		lastRun := maxRuns - 1
+		if reran != nil {
+			lastRun = rerunFrom + cfg.RerunFailed - 1
+		} else if cfg.RerunFailed > 0 {
+			lastRun += cfg.RerunFailed
+		}
+		cp := newCheckpoint(sweep, completed, lastRun, results)
+		cp.Reran, cp.RerunFrom, cp.Elapsed = reran, rerunFrom, time.Since(start)
+		saved = time.Now()
+		return cp.Save(sweep.Checkpoint)
+	}
+	// fail saves what the runs so far found before returning err, so a
+	// cancelled sweep resumes after its last completed run
+	fail := func(completed int, err error) (*Report, error) {
+		if completed == 0 {
+			return nil, err
+		}
+		if cerr := checkpoint(completed, true); cerr != nil {
+			return nil, errors.Join(err, cerr)
+		}
+		return nil, err
+	}
+	// execute runs the suite once, reporting false without running it when
+	// the time budget is spent
+	execute := func(run int) (bool, error) {
+		if run > 0 && cfg.MaxDuration > 0 && time.Since(start) >= cfg.MaxDuration {
+			outOfTime = true
+			return false, nil
+		}
+		runResults, err := RunOnce(ctx, cfg, run)
+		if err != nil {
+			return false, err
+		}
+		results = append(results, runResults...)
+		if err := checkpoint(run+1, false); err != nil {
+			return false, err
+		}
+		if cfg.Observe != nil {
+			cfg.Observe(run, runResults)
+		}
+		return true, nil
+	}
+
+	if reran == nil {
+		decided := false
+		if cfg.Adaptive != nil && run > 0 && run >= cfg.Runs {
+			// Resumed past the minimum runs: narrow to the undecided tests
+			// as the run before the checkpoint did
+			undecided := cfg.Adaptive.undecided(Aggregate(run, results))
+			decided = len(undecided) == 0
+			cfg.Run = RunPattern(undecided)
+		}
+		for ; run < maxRuns && !decided; run++ {
+			if ok, err := execute(run); err != nil {
+				return fail(run, err)
+			} else if !ok {
+				break
+			}
+
+			if cfg.Adaptive != nil && run+1 >= cfg.Runs {
+				undecided := cfg.Adaptive.undecided(Aggregate(run+1, results))
+				if len(undecided) == 0 {
+					run++
+					break
+				}
+				cfg.Run = RunPattern(undecided)
+			}
+		}
+		if cfg.RerunFailed > 0 && !outOfTime {
+			reran, rerunFrom = Aggregate(run, results).failedTests(), run
+		}
+	}
+
+	if len(reran) > 0 {
+		cfg.Run = RunPattern(reran)
+		for end := rerunFrom + cfg.RerunFailed; run < end; run++ {
+			if ok, err := execute(run); err != nil {
+				return fail(run, err)
+			} else if !ok {
+				break
+			}
+		}
+	}
+	if sweep.Checkpoint != "" {
+		if err := removeCheckpoint(sweep.Checkpoint); err != nil {
+			return nil, err
+		}
+	}
+	report := Aggregate(run, results)
+	report.Reran = reran
+	report.OutOfTime = outOfTime
+	report.Fingerprint = fingerprint.Capture(cfg.Dir, cfg.Env)
+	return report, nil
+}
+
+// undecided returns the tests that still need runs to reach a verdict
+func (a *Adaptive) undecided(report *Report) []string {
+	var tests []string
+	for _, s := range report.Tests {
+		if s.Classify() == Skipped {
+			continue
+		}
+		if a.Verdict(s) == stats.Undecided {
+			tests = append(tests, s.Test)
+		}
+	}
+	return tests
+}
+
+// failedTests returns the top-level tests with at least one failure, which
+// includes the parents of failing subtests
+func (r *Report) failedTests() []string {
+	seen := make(map[string]bool)
+	var tests []string
+	for _, s := range r.Tests {
+		top, _, _ := strings.Cut(s.Test, "/")
+		if s.Failed > 0 && !seen[top] {
+			seen[top] = true
+			tests = append(tests, top)
+		}
+	}
+	sort.Strings(tests)
+	return tests
+}
+
+// RunPattern builds a -run regex selecting exactly the given top-level tests;
+// subtests select their top-level parent
+func RunPattern(tests []string) string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, test := range tests {
+		top, _, _ := strings.Cut(test, "/")
+		if !seen[top] {
+			seen[top] = true
+			names = append(names, regexp.QuoteMeta(top))
+		}
+	}
+	sort.Strings(names)
+	return "^(" + strings.Join(names, "|") + ")$"
+}
+
+// TestPattern builds a -run regex selecting exactly one test or subtest:
+// go test matches each /-separated element of a pattern against the matching
+// level of the test name, so every element is anchored on its own
+func TestPattern(test string) string {
+	elems := strings.Split(test, "/")
+	for i, elem := range elems {
+		elems[i] = "^" + regexp.QuoteMeta(elem) + "$"
+	}
+	return strings.Join(elems, "/")
+}
+
+// RunOnce executes go test -json once for the given run index
+// With cfg.Isolate it runs each selected test in its own process instead,
+// and with cfg.Parallel or cfg.PerTestTimeout each package
+func RunOnce(ctx context.Context, cfg Config, run int) ([]Result, error) {
+	if cfg.Isolate || cfg.Parallel > 1 || cfg.PerTestTimeout > 0 {
+		return runPool(ctx, cfg, run)
+	}
+	return runTests(ctx, cfg, run, nil)
+}
+
+// runTests runs the tests cfg selects, leaving out the top-level tests in
+// skip, until each has reported; when a test hangs past cfg.PerTestTimeout
+// or crashes its test binary, the tests of its package that had not
+// finished run again in a fresh process without it
+func runTests(ctx context.Context, cfg Config, run int, skip []string) ([]Result, error) {
+	results, stopped, err := runProcess(ctx, cfg, run, skip)
+	if err != nil {
+		return nil, err
+	}
+	for _, at := range stopped {
+		rest := cfg
+		rest.Packages = []string{at.pkg}
+		// results only hold the top-level tests that finished and the one
+		// the process stopped at
+		restSkip := append(slices.Clone(skip), at.test)
+		for _, r := range results {
+			if r.Package == at.pkg {
+				restSkip = append(restSkip, r.Test)
+			}
+		}
+		more, err := runTests(ctx, rest, run, restSkip)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, more...)
+	}
+	return results, nil
+}
+
+// quitGrace is how long a test binary sent SIGQUIT gets to print its
+// goroutines and exit before it is killed
+const quitGrace = 5 * time.Second
+
+// runProcess executes one go test -json process for the given run index,
+// leaving out the top-level tests in skip
+// It also returns the tests the process stopped at before the others of
+// their package finished: the one killed for running past
+// cfg.PerTestTimeout, or those blameCrashes counts a crashed binary against
+func runProcess(ctx context.Context, cfg Config, run int, skip []string) ([]Result, []testKey, error) {
+	seed := cfg.Seed + int64(run)
+	args := []string{"test", "-json", "-count=1"}
+	if cfg.Run != "" {
+		args = append(args, "-run", cfg.Run)
+	}
+	if len(skip) > 0 {
+		args = append(args, "-skip", RunPattern(skip))
+	}
+	args = append(args, cfg.Args...)
+	args = append(args, packagesOrDefault(cfg.Packages)...)
+
+	procCtx, kill := context.WithCancel(ctx)
+	defer kill()
+	cmd := exec.CommandContext(procCtx, "go", args...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = append(os.Environ(), cfg.Env...)
+	cmd.Env = append(cmd.Env, SeedEnv+"="+strconv.FormatInt(seed, 10))
+	// A hung test binary dumps its goroutines on SIGQUIT and exits; one
+	// that ignores it is killed a little later
+	stdout := newWatchdog(cfg.PerTestTimeout, func() {
+		if quitProcessGroup(cmd) != nil {
+			kill()
+			return
+		}
+		time.AfterFunc(quitGrace, kill)
+	})
+	var stderr bytes.Buffer
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if cfg.PerTestTimeout > 0 {
+		// Killing go alone would leave the hung test binary running and
+		// holding stdout open
+		killProcessGroup(cmd)
+		cmd.WaitDelay = time.Second
+	}
+
+	runErr := cmd.Run()
+	hung := stdout.stop()
+	// A killed go test may have reported some tests already; the run is
+	// incomplete either way
+	if err := ctx.Err(); err != nil {
+		return nil, nil, fmt.Errorf("run %d: %w", run, err)
+	}
+	stream := stdout.out.Bytes()
+	results, parseErr := Parse(bytes.NewReader(stream), run, seed)
+	if parseErr != nil {
+		return nil, nil, fmt.Errorf("run %d: parse go test output: %w", run, parseErr)
+	}
+	var stopped []testKey
+	if hung != "" {
+		results = stdout.timedOut(results, hung)
+		stopped = []testKey{{hungPackage(results, hung, cfg), hung}}
+	} else {
+		results, stopped = blameCrashes(results)
+	}
+	if cfg.Attachments != nil {
+		if err := attachFailures(cfg.Attachments, results, stream, stderr.Bytes()); err != nil {
+			return nil, nil, fmt.Errorf("run %d: store attachments: %w", run, err)
+		}
+	}
+	if hung != "" {
+		return results, stopped, nil
+	}
+
+	// go test exits non-zero when tests fail; that is only an error when no
+	// test reported an outcome (build failure, bad package pattern, ...)
+	var exitErr *exec.ExitError
+	if runErr != nil && (len(results) == 0 || !errors.As(runErr, &exitErr)) {
+		return nil, nil, fmt.Errorf("run %d: go %v: %w\n%s", run, args, runErr, stderr.String())
+	}
+	return results, stopped, nil
+}
+
+// hungPackage returns the package of the hung test, which the watchdog only
+// watches in processes of a single package
+func hungPackage(results []Result, hung string, cfg Config) string {
+	for _, r := range results {
+		if top, _, _ := strings.Cut(r.Test, "/"); top == hung {
+			return r.Package
+		}
+	}
+	return packagesOrDefault(cfg.Packages)[0]
+}
+
+// ListTests returns the top-level tests matching pattern with go test -list,
+// in source order, for the packages of cfg
+// Benchmarks, fuzz targets and examples are left out
+func ListTests(ctx context.Context, cfg Config, pattern string) ([]string, error) {
+	args := append([]string{"test", "-list", pattern}, packagesOrDefault(cfg.Packages)...)
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = append(os.Environ(), cfg.Env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go %s: %w\n%s%s", strings.Join(args, " "), err, out, stderr.String())
+	}
+	var tests []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "Test") {
+			tests = append(tests, line)
+		}
+	}
+	return tests, nil
+}
+
+func packagesOrDefault(packages []string) []string {
+	if len(packages) == 0 {
+		return []string{"."}
+	}
+	return packages
+}
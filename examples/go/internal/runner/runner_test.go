@@ -0,0 +1,204 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/stats"
+)
+
+// writeModule creates a throwaway module whose test fails on odd seeds
+func writeModule(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/seeded\n\ngo 1.22\n",
+		"seeded_test.go": `package seeded
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestSeedParity(t *testing.T) {
+	seed, _ := strconv.Atoi(os.Getenv("GO_TEST_SEED"))
+	if seed%2 == 1 {
+		t.Errorf("odd seed %d", seed)
+	}
+}
+
+func TestStable(t *testing.T) {}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDetect(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	var observed []int
+	observe := func(run int, results []Result) { observed = append(observed, len(results)) }
+	report, err := Detect(context.Background(), Config{Dir: writeModule(t), Runs: 4, Seed: 10, Observe: observe})
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	if len(observed) != 4 || observed[0] != 2 {
+		t.Errorf("Expected each of 4 runs to be observed with 2 results, got %v", observed)
+	}
+	if len(report.Tests) != 2 {
+		t.Fatalf("Expected 2 tests, got %+v", report.Tests)
+	}
+
+	parity, stable := report.Tests[0], report.Tests[1]
+	if parity.Test != "TestSeedParity" || parity.Passed != 2 || parity.Failed != 2 {
+		t.Errorf("Unexpected TestSeedParity stats: %+v", parity)
+	}
+	if len(parity.FailingSeeds) != 2 || parity.FailingSeeds[0] != 11 || parity.FailingSeeds[1] != 13 {
+		t.Errorf("Expected failing seeds [11 13], got %v", parity.FailingSeeds)
+	}
+	if stable.PassRate() != 1 || stable.Runs() != 4 {
+		t.Errorf("Unexpected TestStable stats: %+v", stable)
+	}
+	if report.Fingerprint.GOOS != runtime.GOOS || report.Fingerprint.GoVersion == "" {
+		t.Errorf("Expected the report to be fingerprinted, got %+v", report.Fingerprint)
+	}
+}
+
+func TestDetectReportsBuildFailure(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	dir := writeModule(t)
+	if err := os.WriteFile(filepath.Join(dir, "broken_test.go"), []byte("package seeded\n\nfunc broken( {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Detect(context.Background(), Config{Dir: dir, Runs: 1}); err == nil {
+		t.Error("Expected an error for a package that does not build")
+	}
+}
+
+func TestDetectRejectsZeroRuns(t *testing.T) {
+	if _, err := Detect(context.Background(), Config{Runs: 0}); err == nil {
+		t.Error("Expected an error for zero runs")
+	}
+}
+
+func TestRunPattern(t *testing.T) {
+	got := RunPattern([]string{"TestB", "TestA/case_1", "TestA/case_2", "TestC.x"})
+	if want := `^(TestA|TestB|TestC\.x)$`; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestTestPattern(t *testing.T) {
+	for test, want := range map[string]string{
+		"TestA":             `^TestA$`,
+		"TestA/case_1":      `^TestA$/^case_1$`,
+		"TestA/b.c/nested+": `^TestA$/^b\.c$/^nested\+$`,
+	} {
+		if got := TestPattern(test); got != want {
+			t.Errorf("%s: expected %s, got %s", test, want, got)
+		}
+	}
+}
+
+func TestDetectAdaptiveStopsDecidedTests(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	report, err := Detect(context.Background(), Config{
+		Dir:      writeModule(t),
+		Runs:     2,
+		Seed:     10,
+		Adaptive: &Adaptive{Confidence: 0.95, Tolerance: 0.5, MaxRuns: 20},
+	})
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	parity, stable := report.Tests[0], report.Tests[1]
+
+	// TestSeedParity fails on seed 11, so it is flaky after the minimum 2 runs;
+	// TestStable needs 0 failures in 6 runs for an upper bound below 0.5
+	if parity.Runs() != 2 {
+		t.Errorf("Expected flaky TestSeedParity to stop after 2 runs, got %d", parity.Runs())
+	}
+	if stable.Runs() != 6 || report.Runs != 6 {
+		t.Errorf("Expected TestStable to stop after 6 runs (report runs %d), got %d", report.Runs, stable.Runs())
+	}
+}
+
+func TestDetectRerunFailed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	dir := writeModule(t)
+	report, err := Detect(context.Background(), Config{Dir: dir, Runs: 2, Seed: 10, RerunFailed: 4})
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	parity, stable := report.Tests[0], report.Tests[1]
+
+	// Seed 11 fails TestSeedParity, so only it runs on seeds 12 to 15
+	if parity.Runs() != 6 || parity.Failed != 3 || stable.Runs() != 2 || report.Runs != 6 {
+		t.Errorf("Expected TestSeedParity 6 runs, 3 failed, and TestStable 2 runs of 6, got %+v, %+v, %d", parity, stable, report.Runs)
+	}
+	if len(report.Reran) != 1 || report.Reran[0] != "TestSeedParity" {
+		t.Errorf("Expected only TestSeedParity rerun, got %v", report.Reran)
+	}
+
+	// Seed 10 passes everything, so there is nothing to rerun
+	report, err = Detect(context.Background(), Config{Dir: dir, Runs: 1, Seed: 10, RerunFailed: 4})
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	if report.Runs != 1 || len(report.Reran) != 0 {
+		t.Errorf("Expected no reruns after a passing run, got %d runs, reran %v", report.Runs, report.Reran)
+	}
+}
+
+func TestDetectSPRT(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	adaptive := &Adaptive{MaxRuns: 30, SPRT: &stats.SPRT{P0: 0.05, P1: 0.2, Alpha: 0.05, Beta: 0.05}}
+	report, err := Detect(context.Background(), Config{Dir: writeModule(t), Runs: 1, Seed: 10, Adaptive: adaptive})
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	parity, stable := report.Tests[0], report.Tests[1]
+
+	// Each pass moves the log-likelihood ratio by log(0.8/0.95), so TestStable
+	// crosses log(0.05/0.95) after 18 passes; TestSeedParity alternates and
+	// crosses log(0.95/0.05) after 3 passes and 3 failures
+	if parity.Runs() != 6 || adaptive.Verdict(parity) != stats.Flaky {
+		t.Errorf("Expected TestSeedParity flaky after 6 runs, got %d runs", parity.Runs())
+	}
+	if stable.Runs() != 18 || adaptive.Verdict(stable) != stats.Stable {
+		t.Errorf("Expected TestStable stable after 18 runs, got %d runs", stable.Runs())
+	}
+}
+
+func TestDetectMaxDuration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	report, err := Detect(context.Background(), Config{Dir: writeModule(t), Runs: 5, Seed: 10, RerunFailed: 2, MaxDuration: time.Nanosecond})
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	// The first run always starts, so the report is never empty
+	if report.Runs != 1 || !report.OutOfTime {
+		t.Errorf("Expected the budget to stop the sweep after 1 run, got %d runs, out of time %v", report.Runs, report.OutOfTime)
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/example/flaky-test-example/internal/history"
+	"github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// maxResultsBody caps a POST /results payload
+const maxResultsBody = 64 << 20
+
+// Ingested is the response to POST /results
+type Ingested struct {
+	Session uint64 `json:"session"`
+	Runs    int    `json:"runs"`
+	Tests   int    `json:"tests"`
+	Results int    `json:"results"`
+}
+
+// ingest records the results of a run on another CI system as a history
+// session
+// The body is JUnit XML or a go test -json stream, picked by the format
+// query parameter or else the content type, then the first byte; commit,
+// source, host, goos and goarch query parameters describe the run
+func (s *Server) ingest(w http.ResponseWriter, r *http.Request) {
+	body := bufio.NewReader(http.MaxBytesReader(w, r.Body, maxResultsBody))
+	format, err := resultsFormat(r, body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var results []runner.Result
+	switch format {
+	case "junit":
+		results, err = report.ReadJUnit(body)
+	case "test2json":
+		results, err = runner.Parse(body, 0, 0)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("parse %s results: %w", format, err))
+		return
+	}
+	if len(results) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("no test results in the %s payload", format))
+		return
+	}
+
+	q := r.URL.Query()
+	rep := runner.Aggregate(attempts(results), results)
+	session := history.NewSession(rep, q.Get("commit"))
+	session.Source, session.Host = q.Get("source"), q.Get("host")
+	session.GOOS, session.GOARCH = q.Get("goos"), q.Get("goarch")
+	if err := s.record(session); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, Ingested{
+		Session: session.ID,
+		Runs:    session.Runs,
+		Tests:   len(rep.Tests),
+		Results: len(session.Results),
+	})
+}
+
+// resultsFormat returns "junit" or "test2json" for a POST /results request
+func resultsFormat(r *http.Request, body *bufio.Reader) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "junit", "test2json":
+		return format, nil
+	case "":
+	default:
+		return "", fmt.Errorf("unknown format %q, want junit or test2json", format)
+	}
+	if ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil {
+		switch ct {
+		case "application/xml", "text/xml":
+			return "junit", nil
+		case "application/json", "application/x-ndjson", "application/jsonl":
+			return "test2json", nil
+		}
+	}
+	// Sniff: JUnit starts with a tag, test2json with an event object
+	for {
+		b, err := body.Peek(1)
+		if err == io.EOF {
+			return "", fmt.Errorf("empty results payload")
+		}
+		if err != nil {
+			return "", err
+		}
+		if !bytes.ContainsAny(b, " \t\r\n") {
+			if b[0] == '<' {
+				return "junit", nil
+			}
+			return "test2json", nil
+		}
+		body.Discard(1)
+	}
+}
+
+// attempts is the most results any one test has, the runs of the session
+func attempts(results []runner.Result) int {
+	counts := make(map[[2]string]int)
+	most := 0
+	for _, r := range results {
+		k := [2]string{r.Package, r.Test}
+		counts[k]++
+		most = max(most, counts[k])
+	}
+	return most
+}
+
+// record adds session to the configured history; the database is opened for
+// each session so other flakectl commands can read it in between
+func (s *Server) record(session *history.Session) error {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	db, err := history.Open(s.cfg.History)
+	if err != nil {
+		return err
+	}
+	if err := db.Add(session); err != nil {
+		db.Close()
+		return fmt.Errorf("record results: %w", err)
+	}
+	return db.Close()
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/history"
+)
+
+// postResults pushes body to srv's /results and returns the status code
+func postResults(t *testing.T, srv *httptest.Server, query, contentType, body string) (int, Ingested) {
+	t.Helper()
+	resp, err := http.Post(srv.URL+"/results"+query, contentType, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var ing Ingested
+	json.NewDecoder(resp.Body).Decode(&ing)
+	return resp.StatusCode, ing
+}
+
+func TestServerIngestsResults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	srv := httptest.NewServer(New(Config{History: path}))
+	defer srv.Close()
+
+	junit := `<?xml version="1.0"?>
+<testsuites>
+  <testsuite name="api">
+    <testcase classname="api" name="TestCreate"/>
+    <testcase classname="api" name="TestList"><flakyFailure message="timeout"/><flakyFailure message="timeout"/></testcase>
+  </testsuite>
+</testsuites>`
+	code, ing := postResults(t, srv, "?commit=abc&source=jenkins&host=ci-7", "text/xml", junit)
+	if code != http.StatusCreated || ing.Session != 1 || ing.Runs != 3 || ing.Tests != 2 || ing.Results != 4 {
+		t.Errorf("Expected session 1 of 3 runs, 2 tests and 4 results, got %d %+v", code, ing)
+	}
+
+	events := strings.Join([]string{
+		`{"Action":"run","Package":"api","Test":"TestList"}`,
+		`{"Action":"output","Package":"api","Test":"TestList","Output":"    list_test.go:9: timeout\n"}`,
+		`{"Action":"fail","Package":"api","Test":"TestList","Elapsed":0.5}`,
+	}, "\n")
+	// No format or content type: sniffed from the first byte
+	if code, ing := postResults(t, srv, "?commit=abc", "", "\n"+events); code != http.StatusCreated || ing.Session != 2 || ing.Results != 1 {
+		t.Errorf("Expected session 2 with 1 result, got %d %+v", code, ing)
+	}
+
+	db, err := history.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	sessions, err := db.SessionsAt("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].Source != "jenkins" || sessions[0].Host != "ci-7" || sessions[1].Source != "" {
+		t.Fatalf("Expected 2 sessions at abc, the first from jenkins on ci-7, got %+v", sessions)
+	}
+	for _, s := range history.Report(sessions).Tests {
+		if s.Test == "TestList" && (s.Passed != 1 || s.Failed != 3) {
+			t.Errorf("Expected TestList to pass once and fail 3 times, got %+v", s)
+		}
+	}
+	if rec := sessions[1].Results[0]; rec.Message != "list_test.go:9: timeout" || rec.Duration != 500*time.Millisecond {
+		t.Errorf("Unexpected test2json record %+v", rec)
+	}
+}
+
+func TestServerRejectsBadResults(t *testing.T) {
+	srv := httptest.NewServer(New(Config{History: filepath.Join(t.TempDir(), "history.db")}))
+	defer srv.Close()
+	for _, tc := range []struct{ query, body string }{
+		{"?format=csv", "a,b"},
+		{"", ""},
+		{"?format=junit", "<testsuites"},
+		{"?format=test2json", "no events here"},
+	} {
+		if code, _ := postResults(t, srv, tc.query, "", tc.body); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q %q, got %d", tc.query, tc.body, code)
+		}
+	}
+
+	noHistory := httptest.NewServer(New(Config{}))
+	defer noHistory.Close()
+	if code, _ := postResults(t, noHistory, "", "text/xml", "<testsuites/>"); code == http.StatusCreated {
+		t.Error("Expected no /results endpoint without a history")
+	}
+}
@@ -0,0 +1,340 @@
+// Package server runs flake detection as a long-lived service: clients
+// submit detection jobs over HTTP, the server queues them, sweeps each with
+// the sweep worker pool and keeps its status and report for them to fetch
+// With a history configured, it also records results other CI systems push
+// The API has no authentication: anyone who can reach it can run go test
+// in the checkouts under the root
+//
+//	POST /jobs        submit a Request, returns the queued Job
+//	GET  /jobs        list every job, newest first, without reports
+//	GET  /jobs/{id}   the job's status and, once done, its report
+//	POST /results     record a JUnit XML or test2json payload in the history
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/sweep"
+)
+
+// DefaultRuns is the runs of a job that does not ask for a number
+const DefaultRuns = 100
+
+// DefaultKeep is the number of finished jobs a server keeps by default
+const DefaultKeep = 1000
+
+// Status is where a job is in its life
+type Status string
+
+const (
+	Queued  Status = "queued"
+	Running Status = "running"
+	Done    Status = "done"
+	Failed  Status = "failed"
+)
+
+// Request is the body of POST /jobs
+type Request struct {
+	// Repo is the checkout to test, relative to the server's Root; empty or
+	// "." is the root itself
+	Repo string `json:"repo,omitempty"`
+	// Package is the go test package pattern (default "./...")
+	Package string `json:"package,omitempty"`
+	// Runs is the number of seeds swept (default DefaultRuns)
+	Runs int `json:"runs,omitempty"`
+	// Seed is the first seed swept
+	Seed int64 `json:"seed,omitempty"`
+	// Run is an optional -run regex
+	Run string `json:"run,omitempty"`
+}
+
+// Job is a submitted request and, once it finished, its outcome
+type Job struct {
+	ID       string             `json:"id"`
+	Status   Status             `json:"status"`
+	Request  Request            `json:"request"`
+	Created  time.Time          `json:"created"`
+	Started  *time.Time         `json:"started,omitempty"`
+	Finished *time.Time         `json:"finished,omitempty"`
+	Error    string             `json:"error,omitempty"`
+	Report   *report.JSONReport `json:"report,omitempty"`
+}
+
+// Config describes a server
+type Config struct {
+	// Root is the directory job repos are resolved in; jobs cannot reach
+	// outside it
+	Root string
+	// Exec runs the shards of every job (default sweep.Local)
+	Exec sweep.Executor
+	// Jobs is the number of jobs swept at once (default 1); the rest wait
+	// in the queue
+	Jobs int
+	// Workers, ShardSize and Retries configure each job's sweep
+	Workers   int
+	ShardSize int
+	Retries   int
+	// Confidence is the level of the reports' intervals
+	Confidence float64
+	// MaxRuns caps the runs of one job; 0 means no cap
+	MaxRuns int
+	// Queue caps the jobs waiting to run (default 100); submissions beyond
+	// it are refused
+	Queue int
+	// Keep caps the finished jobs kept with their reports (default
+	// DefaultKeep); the oldest are forgotten first
+	Keep int
+	// History is the history database POST /results records sessions in;
+	// without one the endpoint is not served
+	History string
+}
+
+// Server queues and runs detection jobs; it is an http.Handler serving the
+// API and must be started with Run
+type Server struct {
+	cfg   Config
+	mux   *http.ServeMux
+	queue chan *Job
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+	// order holds the job IDs in submission order
+	order []string
+	next  int
+
+	historyMu sync.Mutex
+}
+
+// New returns a server for cfg
+func New(cfg Config) *Server {
+	if cfg.Exec == nil {
+		cfg.Exec = sweep.Local{}
+	}
+	if cfg.Jobs < 1 {
+		cfg.Jobs = 1
+	}
+	if cfg.Queue < 1 {
+		cfg.Queue = 100
+	}
+	if cfg.Keep < 1 {
+		cfg.Keep = DefaultKeep
+	}
+	if cfg.Confidence == 0 {
+		cfg.Confidence = 0.95
+	}
+	s := &Server{cfg: cfg, queue: make(chan *Job, cfg.Queue), jobs: make(map[string]*Job)}
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("POST /jobs", s.submit)
+	s.mux.HandleFunc("GET /jobs", s.list)
+	s.mux.HandleFunc("GET /jobs/{id}", s.get)
+	if cfg.History != "" {
+		s.mux.HandleFunc("POST /results", s.ingest)
+	}
+	return s
+}
+
+// Run sweeps queued jobs, cfg.Jobs at a time, until ctx is cancelled; a job
+// still running then fails with ctx's error
+func (s *Server) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < s.cfg.Jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-s.queue:
+					s.runJob(ctx, job)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Job returns a copy of the job with id
+func (s *Server) Job(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// Submit validates req and queues it as a new job
+func (s *Server) Submit(req Request) (Job, error) {
+	if req.Runs == 0 {
+		req.Runs = DefaultRuns
+	}
+	if req.Runs < 0 {
+		return Job{}, fmt.Errorf("runs must be positive, got %d", req.Runs)
+	}
+	if s.cfg.MaxRuns > 0 && req.Runs > s.cfg.MaxRuns {
+		return Job{}, fmt.Errorf("runs %d exceeds the server's limit of %d", req.Runs, s.cfg.MaxRuns)
+	}
+	if _, err := s.dir(req.Repo); err != nil {
+		return Job{}, err
+	}
+	// Both end up in go test's arguments, where a value such as
+	// "-toolexec=..." would be taken as a flag and run any command
+	if strings.HasPrefix(req.Package, "-") {
+		return Job{}, fmt.Errorf("package %q must be a package pattern, not a flag", req.Package)
+	}
+	if strings.HasPrefix(req.Run, "-") {
+		return Job{}, fmt.Errorf("run %q must be a test name pattern, not a flag", req.Run)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	job := &Job{ID: strconv.Itoa(s.next), Status: Queued, Request: req, Created: time.Now()}
+	select {
+	case s.queue <- job:
+	default:
+		s.next--
+		return Job{}, errQueueFull
+	}
+	s.jobs[job.ID] = job
+	s.order = append(s.order, job.ID)
+	return *job, nil
+}
+
+var errQueueFull = errors.New("job queue is full")
+
+// dir resolves a job's repo in the root, refusing paths that leave it
+func (s *Server) dir(repo string) (string, error) {
+	if repo != "" && !filepath.IsLocal(repo) {
+		return "", fmt.Errorf("repo %q must be a path inside the server's root", repo)
+	}
+	return filepath.Join(s.cfg.Root, repo), nil
+}
+
+func (s *Server) runJob(ctx context.Context, job *Job) {
+	s.update(job, func(j *Job) {
+		now := time.Now()
+		j.Status, j.Started = Running, &now
+	})
+	dir, _ := s.dir(job.Request.Repo)
+	cfg := sweep.Config{
+		Package:    job.Request.Package,
+		Dir:        dir,
+		Run:        job.Request.Run,
+		SeedStart:  job.Request.Seed,
+		SeedEnd:    job.Request.Seed + int64(job.Request.Runs) - 1,
+		Workers:    s.cfg.Workers,
+		ShardSize:  s.cfg.ShardSize,
+		Retries:    s.cfg.Retries,
+		Confidence: s.cfg.Confidence,
+	}
+	r, err := sweep.Run(ctx, cfg, s.cfg.Exec)
+	s.update(job, func(j *Job) {
+		now := time.Now()
+		j.Finished = &now
+		if err != nil {
+			j.Status, j.Error = Failed, err.Error()
+			return
+		}
+		j.Status, j.Report = Done, r
+	})
+}
+
+func (s *Server) update(job *Job, f func(*Job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(job)
+	s.evict()
+}
+
+// evict forgets the oldest finished jobs beyond cfg.Keep; queued and
+// running jobs are always kept
+// It is called with s.mu held
+func (s *Server) evict() {
+	finished := 0
+	for _, id := range s.order {
+		if st := s.jobs[id].Status; st == Done || st == Failed {
+			finished++
+		}
+	}
+	order := s.order[:0]
+	for _, id := range s.order {
+		if st := s.jobs[id].Status; finished > s.cfg.Keep && (st == Done || st == Failed) {
+			delete(s.jobs, id)
+			finished--
+			continue
+		}
+		order = append(order, id)
+	}
+	s.order = order
+}
+
+func (s *Server) submit(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("parse job request: %w", err))
+		return
+	}
+	job, err := s.Submit(req)
+	switch {
+	case errors.Is(err, errQueueFull):
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s *Server) list(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	jobs := make([]Job, 0, len(s.order))
+	for i := len(s.order) - 1; i >= 0; i-- {
+		job := *s.jobs[s.order[i]]
+		job.Report = nil
+		jobs = append(jobs, job)
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string][]Job{"jobs": jobs})
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.Job(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no job %q", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
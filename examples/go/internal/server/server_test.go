@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/sweep"
+)
+
+// countingExecutor reports every seed of a shard as a pass of TestA, and
+// fails shards of the package "broken"
+type countingExecutor struct {
+	dirs chan string
+}
+
+func (e *countingExecutor) Run(_ context.Context, cfg sweep.Config, s sweep.Shard) (*report.JSONReport, error) {
+	if e.dirs != nil {
+		e.dirs <- cfg.Dir
+	}
+	if cfg.Package == "broken" {
+		return nil, errors.New("build failed")
+	}
+	test := report.JSONTest{Package: cfg.Package, Test: "TestA", Runs: s.Runs(), Passed: s.Runs()}
+	return &report.JSONReport{Runs: s.Runs(), Tests: []report.JSONTest{test}}, nil
+}
+
+// post submits body to srv and decodes the response into v
+func post(t *testing.T, srv *httptest.Server, body string, v any) int {
+	t.Helper()
+	resp, err := http.Post(srv.URL+"/jobs", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+// waitFor polls GET /jobs/{id} until the job finished
+func waitFor(t *testing.T, srv *httptest.Server, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get(srv.URL + "/jobs/" + id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var job Job
+		err = json.NewDecoder(resp.Body).Decode(&job)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == Done || job.Status == Failed {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Job %s still %s after 5s", id, job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerRunsJobs(t *testing.T) {
+	s := New(Config{Root: "/src", Exec: &countingExecutor{}, Workers: 2, ShardSize: 10})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	var queued Job
+	if code := post(t, srv, `{"repo": "app", "package": "./pkg", "runs": 30, "seed": 5}`, &queued); code != http.StatusAccepted {
+		t.Fatalf("Expected 202 Accepted, got %d", code)
+	}
+	if queued.ID != "1" || queued.Status != Queued {
+		t.Errorf("Expected queued job 1, got %+v", queued)
+	}
+	job := waitFor(t, srv, queued.ID)
+	if job.Status != Done || job.Report == nil || job.Report.Runs != 30 || job.Report.Tests[0].Passed != 30 {
+		t.Fatalf("Unexpected finished job: %+v", job)
+	}
+	if job.Started == nil || job.Finished == nil {
+		t.Errorf("Expected start and finish times, got %+v", job)
+	}
+
+	var failed Job
+	post(t, srv, `{"package": "broken"}`, &failed)
+	if job := waitFor(t, srv, failed.ID); job.Status != Failed || !strings.Contains(job.Error, "build failed") {
+		t.Errorf("Expected job %s to fail with the shard's error, got %+v", failed.ID, job)
+	}
+
+	resp, err := http.Get(srv.URL + "/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var list struct{ Jobs []Job }
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Jobs) != 2 || list.Jobs[0].ID != "2" || list.Jobs[1].Report != nil {
+		t.Errorf("Expected both jobs newest first without reports, got %+v", list.Jobs)
+	}
+}
+
+func TestServerResolvesRepos(t *testing.T) {
+	exec := &countingExecutor{dirs: make(chan string, 1)}
+	s := New(Config{Root: "/src", Exec: exec})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	if _, err := s.Submit(Request{Repo: "team/app", Runs: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if dir := <-exec.dirs; dir != "/src/team/app" {
+		t.Errorf("Expected the job to run in /src/team/app, got %s", dir)
+	}
+	for _, repo := range []string{"../etc", "/etc", "app/../../etc"} {
+		if _, err := s.Submit(Request{Repo: repo}); err == nil {
+			t.Errorf("Expected repo %q outside the root to be refused", repo)
+		}
+	}
+}
+
+func TestServerForgetsOldJobs(t *testing.T) {
+	s := New(Config{Exec: &countingExecutor{}, Keep: 2})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	for i := 0; i < 3; i++ {
+		var job Job
+		post(t, srv, `{"runs": 1}`, &job)
+		waitFor(t, srv, job.ID)
+	}
+	if _, ok := s.Job("1"); ok {
+		t.Error("Expected the oldest finished job to be forgotten")
+	}
+	for _, id := range []string{"2", "3"} {
+		if _, ok := s.Job(id); !ok {
+			t.Errorf("Expected job %s to be kept", id)
+		}
+	}
+}
+
+func TestServerRejectsBadRequests(t *testing.T) {
+	s := New(Config{MaxRuns: 100, Queue: 1})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	for _, body := range []string{`{"runs": 1000}`, `{"runs": -1}`, `{"packages": "./..."}`, `{"package": "-toolexec=sh -c id"}`, `{"run": "-exec=sh"}`, `not json`} {
+		var res map[string]string
+		if code := post(t, srv, body, &res); code != http.StatusBadRequest || res["error"] == "" {
+			t.Errorf("Expected 400 with an error for %s, got %d %v", body, code, res)
+		}
+	}
+	// Run was never started, so the first job fills the queue
+	var job Job
+	post(t, srv, `{}`, &job)
+	var res map[string]string
+	if code := post(t, srv, `{}`, &res); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 once the queue is full, got %d %v", code, res)
+	}
+
+	resp, err := http.Get(srv.URL + "/jobs/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown job, got %d", resp.StatusCode)
+	}
+}
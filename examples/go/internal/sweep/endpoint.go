@@ -0,0 +1,130 @@
+package sweep
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/report"
+)
+
+// DefaultAPIBase is the RunPod serverless API
+const DefaultAPIBase = "https://api.runpod.ai/v2"
+
+// Endpoint runs shards as jobs on a serverless endpoint running cmd/worker
+type Endpoint struct {
+	// ID is the endpoint ID
+	ID     string
+	APIKey string
+	// APIBase overrides DefaultAPIBase
+	APIBase string
+	// PollInterval is the wait between status checks of a queued job
+	// (default 2s)
+	PollInterval time.Duration
+	Client       *http.Client
+}
+
+// jobInput mirrors the cmd/worker job payload
+type jobInput struct {
+	Package    string  `json:"package"`
+	Dir        string  `json:"dir,omitempty"`
+	Run        string  `json:"run,omitempty"`
+	SeedStart  int64   `json:"seed_start"`
+	SeedEnd    int64   `json:"seed_end"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Set        string  `json:"set,omitempty"`
+}
+
+// jobStatus is the runsync and status response
+type jobStatus struct {
+	ID     string             `json:"id"`
+	Status string             `json:"status"`
+	Output *report.JSONReport `json:"output"`
+	Error  string             `json:"error"`
+}
+
+// Run submits the shard with runsync and polls its status until it finishes
+func (e *Endpoint) Run(ctx context.Context, cfg Config, s Shard) (*report.JSONReport, error) {
+	body, err := json.Marshal(map[string]jobInput{"input": {
+		Package:    cfg.pkg(),
+		Dir:        cfg.Dir,
+		Run:        cfg.Run,
+		SeedStart:  s.SeedStart,
+		SeedEnd:    s.SeedEnd,
+		Confidence: cfg.Confidence,
+		Set:        cfg.Set,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	st, err := e.do(ctx, http.MethodPost, "/runsync", body)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch st.Status {
+		case "COMPLETED":
+			if st.Output == nil {
+				return nil, fmt.Errorf("job %s completed without output", st.ID)
+			}
+			return st.Output, nil
+		case "IN_QUEUE", "IN_PROGRESS":
+		default:
+			return nil, fmt.Errorf("job %s %s: %s", st.ID, strings.ToLower(st.Status), st.Error)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(e.pollInterval()):
+		}
+		if st, err = e.do(ctx, http.MethodGet, "/status/"+st.ID, nil); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (e *Endpoint) do(ctx context.Context, method, path string, body []byte) (*jobStatus, error) {
+	base := e.APIBase
+	if base == "" {
+		base = DefaultAPIBase
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(base, "/")+"/"+e.ID+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(data))
+	}
+	var st jobStatus
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return &st, nil
+}
+
+func (e *Endpoint) pollInterval() time.Duration {
+	if e.PollInterval == 0 {
+		return 2 * time.Second
+	}
+	return e.PollInterval
+}
@@ -0,0 +1,64 @@
+package sweep
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEndpointPollsUntilCompleted(t *testing.T) {
+	var input map[string]jobInput
+	var polls int
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/ep/runsync":
+			json.NewDecoder(r.Body).Decode(&input)
+			io.WriteString(w, `{"id": "job-1", "status": "IN_QUEUE"}`)
+		case "/ep/status/job-1":
+			polls++
+			if polls < 2 {
+				io.WriteString(w, `{"id": "job-1", "status": "IN_PROGRESS"}`)
+				return
+			}
+			io.WriteString(w, `{"id": "job-1", "status": "COMPLETED", "output": {"runs": 5, "tests": [{"test": "TestA", "runs": 5, "passed": 5}]}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	e := &Endpoint{ID: "ep", APIKey: "key", APIBase: api.URL, PollInterval: time.Millisecond}
+	r, err := e.Run(context.Background(), Config{Package: "./pkg", Run: "^TestA$"}, Shard{SeedStart: 10, SeedEnd: 14})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Runs != 5 || r.Tests[0].Test != "TestA" {
+		t.Errorf("Unexpected report: %+v", r)
+	}
+	want := jobInput{Package: "./pkg", Run: "^TestA$", SeedStart: 10, SeedEnd: 14}
+	if input["input"] != want {
+		t.Errorf("Expected job input %+v, got %+v", want, input["input"])
+	}
+}
+
+func TestEndpointReportsFailedJobs(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"id": "job-2", "status": "FAILED", "error": "runs must be between 1 and 1000"}`)
+	}))
+	defer api.Close()
+
+	e := &Endpoint{ID: "ep", APIBase: api.URL}
+	_, err := e.Run(context.Background(), Config{}, Shard{SeedStart: 0, SeedEnd: 0})
+	if err == nil || !strings.Contains(err.Error(), "job-2 failed: runs must be") {
+		t.Errorf("Expected the job error, got %v", err)
+	}
+}
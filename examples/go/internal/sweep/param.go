@@ -0,0 +1,181 @@
+package sweep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/internal/report"
+)
+
+// MaxParamValues caps the values one parameter takes, so a mistyped step
+// does not queue thousands of sweeps
+const MaxParamValues = 100
+
+// Param is a scenario field a parameter sweep varies and the values it takes
+type Param struct {
+	// Target and Field name the field as a flaky.Override does
+	Target string
+	Field  string
+	Values []string
+}
+
+// Name returns the field as target.field
+func (p Param) Name() string {
+	return p.Target + "." + p.Field
+}
+
+// ParseParam parses target.field=start:stop:step, an inclusive range of
+// numbers or durations, or target.field=a,b,c
+func ParseParam(s string) (Param, error) {
+	o, err := flaky.ParseOverride(s)
+	if err != nil {
+		return Param{}, err
+	}
+	p := Param{Target: o.Target, Field: o.Field}
+	if lo, rest, ok := strings.Cut(o.Value, ":"); ok {
+		hi, step, ok := strings.Cut(rest, ":")
+		if !ok {
+			return Param{}, fmt.Errorf("invalid range %q for %s, want start:stop:step", o.Value, p.Name())
+		}
+		if p.Values, err = parseRange(lo, hi, step); err != nil {
+			return Param{}, fmt.Errorf("%s: %w", p.Name(), err)
+		}
+		return p, nil
+	}
+	for _, v := range strings.Split(o.Value, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			return Param{}, fmt.Errorf("invalid values %q for %s, want comma-separated values", o.Value, p.Name())
+		}
+		p.Values = append(p.Values, v)
+	}
+	if len(p.Values) > MaxParamValues {
+		return Param{}, fmt.Errorf("%s takes %d values, more than %d", p.Name(), len(p.Values), MaxParamValues)
+	}
+	return p, nil
+}
+
+// parseRange expands start:stop:step into its values, numbers written with
+// as many decimals as the bounds and step have, durations as
+// time.Duration formats them
+func parseRange(lo, hi, step string) ([]string, error) {
+	var (
+		start, stop, inc float64
+		format           func(float64) string
+		numErr           error
+	)
+	if start, numErr = strconv.ParseFloat(lo, 64); numErr == nil {
+		if stop, numErr = strconv.ParseFloat(hi, 64); numErr == nil {
+			inc, numErr = strconv.ParseFloat(step, 64)
+		}
+		decimals := max(decimalPlaces(lo), decimalPlaces(hi), decimalPlaces(step))
+		format = func(v float64) string { return strconv.FormatFloat(v, 'f', decimals, 64) }
+	}
+	if numErr != nil {
+		d := make([]time.Duration, 3)
+		for i, s := range []string{lo, hi, step} {
+			var err error
+			if d[i], err = time.ParseDuration(s); err != nil {
+				return nil, fmt.Errorf("invalid range %s:%s:%s, want numbers or durations", lo, hi, step)
+			}
+		}
+		start, stop, inc = float64(d[0]), float64(d[1]), float64(d[2])
+		format = func(v float64) string { return time.Duration(math.Round(v)).String() }
+	}
+	if inc <= 0 || stop < start {
+		return nil, fmt.Errorf("invalid range %s:%s:%s, want start <= stop and a positive step", lo, hi, step)
+	}
+	// the epsilon keeps a stop that is a multiple of the step, such as 0.5
+	// after nine steps of 0.05, from being lost to rounding
+	n := int(math.Floor((stop-start)/inc+1e-9)) + 1
+	if n > MaxParamValues {
+		return nil, fmt.Errorf("range %s:%s:%s takes %d values, more than %d", lo, hi, step, n, MaxParamValues)
+	}
+	values := make([]string, n)
+	for i := range values {
+		values[i] = format(start + float64(i)*inc)
+	}
+	return values, nil
+}
+
+func decimalPlaces(s string) int {
+	if _, frac, ok := strings.Cut(s, "."); ok {
+		return len(frac)
+	}
+	return 0
+}
+
+// Grid returns every combination of the params' values, the last param
+// varying fastest
+func Grid(params []Param) [][]flaky.Override {
+	if len(params) == 0 {
+		return nil
+	}
+	grid := [][]flaky.Override{nil}
+	for _, p := range params {
+		var next [][]flaky.Override
+		for _, point := range grid {
+			for _, v := range p.Values {
+				o := flaky.Override{Target: p.Target, Field: p.Field, Value: v}
+				next = append(next, append(append([]flaky.Override(nil), point...), o))
+			}
+		}
+		grid = next
+	}
+	return grid
+}
+
+// Point is one combination of parameter values and the sweep run with them
+type Point struct {
+	Overrides []flaky.Override   `json:"-"`
+	Values    map[string]string  `json:"values"`
+	Report    *report.JSONReport `json:"report"`
+}
+
+// Label returns the point's values, as in network.failure_rate=0.2
+func (p Point) Label() string {
+	parts := make([]string, len(p.Overrides))
+	for i, o := range p.Overrides {
+		parts[i] = o.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// RunParams sweeps cfg's seed range once at every point of the params'
+// grid, with the point's values set on top of cfg.Set, and returns the
+// points in grid order
+// onPoint, when set, is called as each point finishes
+func RunParams(ctx context.Context, cfg Config, exec Executor, params []Param, onPoint func(Point)) ([]Point, error) {
+	grid := Grid(params)
+	if len(grid) == 0 {
+		return nil, errors.New("no parameters to sweep")
+	}
+	points := make([]Point, 0, len(grid))
+	for _, overrides := range grid {
+		p := Point{Overrides: overrides, Values: make(map[string]string, len(overrides))}
+		for _, o := range overrides {
+			p.Values[o.Target+"."+o.Field] = o.Value
+		}
+		pointCfg := cfg
+		if cfg.Set != "" {
+			pointCfg.Set = cfg.Set + "," + p.Label()
+		} else {
+			pointCfg.Set = p.Label()
+		}
+		r, err := Run(ctx, pointCfg, exec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.Label(), err)
+		}
+		p.Report = r
+		if onPoint != nil {
+			onPoint(p)
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
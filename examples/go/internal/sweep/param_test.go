@@ -0,0 +1,117 @@
+package sweep
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/internal/report"
+)
+
+func TestParseParam(t *testing.T) {
+	for s, want := range map[string][]string{
+		"network.failure_rate=0.05:0.5:0.05":        {"0.05", "0.10", "0.15", "0.20", "0.25", "0.30", "0.35", "0.40", "0.45", "0.50"},
+		"network.failure_rate=0:1:0.3":              {"0.0", "0.3", "0.6", "0.9"},
+		"TimingDependent.latency.max=1ms:3ms:500us": {"1ms", "1.5ms", "2ms", "2.5ms", "3ms"},
+		"RandomFailure.failure_rate=0.1, 0.5":       {"0.1", "0.5"},
+	} {
+		p, err := ParseParam(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if !reflect.DeepEqual(p.Values, want) {
+			t.Errorf("%s: expected %v, got %v", s, want, p.Values)
+		}
+	}
+	p, _ := ParseParam("TimingDependent.latency.max=1ms,2ms")
+	if p.Target != "TimingDependent" || p.Field != "latency.max" || p.Name() != "TimingDependent.latency.max" {
+		t.Errorf("Expected the nested field of TimingDependent, got %+v", p)
+	}
+	for _, s := range []string{
+		"network.failure_rate",
+		"network.failure_rate=0.5:0.1:0.1",
+		"network.failure_rate=0:1:0",
+		"network.failure_rate=0:1",
+		"network.failure_rate=0:1ms:1",
+		"network.failure_rate=0:1:0.001",
+		"network.failure_rate=0.1,,0.2",
+	} {
+		if _, err := ParseParam(s); err == nil {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestGrid(t *testing.T) {
+	grid := Grid([]Param{
+		{Target: "network", Field: "failure_rate", Values: []string{"0.1", "0.2"}},
+		{Target: "TimingDependent", Field: "timeout", Values: []string{"5ms", "10ms", "20ms"}},
+	})
+	if len(grid) != 6 {
+		t.Fatalf("Expected 6 points, got %d", len(grid))
+	}
+	want := []flaky.Override{{Target: "network", Field: "failure_rate", Value: "0.1"}, {Target: "TimingDependent", Field: "timeout", Value: "10ms"}}
+	if !reflect.DeepEqual(grid[1], want) {
+		t.Errorf("Expected the last param to vary fastest, got %v", grid[1])
+	}
+	if grid[0][1].Value != "5ms" || grid[3][0].Value != "0.2" {
+		t.Errorf("Expected independent points, got %v", grid)
+	}
+}
+
+// rateExecutor fails TestNetwork on the seeds below the failure rate it is
+// set to, out of every hundred
+type rateExecutor struct{ sets []string }
+
+func (e *rateExecutor) Run(_ context.Context, cfg Config, s Shard) (*report.JSONReport, error) {
+	e.sets = append(e.sets, cfg.Set)
+	_, value, _ := strings.Cut(cfg.Set, "network.failure_rate=")
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	test := report.JSONTest{Package: "p", Test: "TestNetwork", Runs: s.Runs()}
+	for seed := s.SeedStart; seed <= s.SeedEnd; seed++ {
+		if float64(seed%100) < rate*100 {
+			test.Failed++
+			test.FailingSeeds = append(test.FailingSeeds, seed)
+		} else {
+			test.Passed++
+		}
+	}
+	return &report.JSONReport{Runs: s.Runs(), Tests: []report.JSONTest{test}}, nil
+}
+
+func TestRunParams(t *testing.T) {
+	exec := &rateExecutor{}
+	p, err := ParseParam("network.failure_rate=0.1:0.3:0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var done int
+	points, err := RunParams(context.Background(), Config{SeedStart: 0, SeedEnd: 99, Set: "RandomFailure.failure_rate=0"}, exec, []Param{p}, func(Point) { done++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 3 || done != 3 {
+		t.Fatalf("Expected 3 points, got %d (%d callbacks)", len(points), done)
+	}
+	for i, want := range []float64{0.9, 0.8, 0.7} {
+		if got := points[i].Report.Tests[0].PassRate; got != want {
+			t.Errorf("%s: expected pass rate %v, got %v", points[i].Label(), want, got)
+		}
+	}
+	if points[1].Values["network.failure_rate"] != "0.2" {
+		t.Errorf("Expected the point's values, got %v", points[1].Values)
+	}
+	if exec.sets[0] != "RandomFailure.failure_rate=0,network.failure_rate=0.1" {
+		t.Errorf("Expected the point set on top of the sweep's overrides, got %q", exec.sets[0])
+	}
+	if _, err := RunParams(context.Background(), Config{}, exec, nil, nil); err == nil {
+		t.Error("Expected an error without parameters")
+	}
+}
@@ -0,0 +1,190 @@
+// Package sweep partitions a large seed range into shards, runs them in
+// parallel on local processes or serverless endpoints and merges the shard
+// reports into one
+package sweep
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// DefaultShardSize is the most seeds one shard runs, matching the run cap of
+// a serverless worker job
+const DefaultShardSize = 1000
+
+// Config describes a seed sweep
+type Config struct {
+	// Package is the go test package pattern (default "./...")
+	Package string
+	// Dir is the directory go test runs in
+	Dir string
+	// Run is an optional -run regex
+	Run string
+	// SeedStart and SeedEnd bound the inclusive seed range
+	SeedStart int64
+	SeedEnd   int64
+	// Workers is the number of shards run concurrently
+	Workers int
+	// ShardSize caps the seeds per shard (default DefaultShardSize)
+	ShardSize int
+	// Retries is how often a failed shard is retried before the sweep fails
+	Retries int
+	// Confidence is the level of the merged report's intervals
+	Confidence float64
+	// Set holds scenario overrides every run applies, as flaky.SetEnv takes
+	// them
+	Set string
+	// OnShard, when set, is called as each shard finishes; calls never
+	// overlap
+	OnShard func(s Shard, err error)
+}
+
+func (cfg Config) pkg() string {
+	if cfg.Package == "" {
+		return "./..."
+	}
+	return cfg.Package
+}
+
+// Shard is a contiguous, inclusive slice of the seed range
+type Shard struct {
+	Index     int
+	SeedStart int64
+	SeedEnd   int64
+}
+
+// Runs returns the number of seeds in the shard
+func (s Shard) Runs() int {
+	return int(s.SeedEnd - s.SeedStart + 1)
+}
+
+// Executor runs one shard somewhere and returns its report
+type Executor interface {
+	Run(ctx context.Context, cfg Config, s Shard) (*report.JSONReport, error)
+}
+
+// Partition splits [start, end] into n shards whose sizes differ by at most
+// one; n is capped at the number of seeds
+func Partition(start, end int64, n int) []Shard {
+	total := end - start + 1
+	if total <= 0 || n < 1 {
+		return nil
+	}
+	if int64(n) > total {
+		n = int(total)
+	}
+	shards := make([]Shard, n)
+	size, extra := total/int64(n), total%int64(n)
+	seed := start
+	for i := range shards {
+		count := size
+		if int64(i) < extra {
+			count++
+		}
+		shards[i] = Shard{Index: i, SeedStart: seed, SeedEnd: seed + count - 1}
+		seed += count
+	}
+	return shards
+}
+
+// Run sweeps cfg's seed range with exec and merges the shard reports
+func Run(ctx context.Context, cfg Config, exec Executor) (*report.JSONReport, error) {
+	if cfg.SeedEnd < cfg.SeedStart {
+		return nil, fmt.Errorf("seed range %d-%d is empty", cfg.SeedStart, cfg.SeedEnd)
+	}
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.ShardSize < 1 {
+		cfg.ShardSize = DefaultShardSize
+	}
+	if cfg.Confidence == 0 {
+		cfg.Confidence = 0.95
+	}
+	total := cfg.SeedEnd - cfg.SeedStart + 1
+	n := int((total + int64(cfg.ShardSize) - 1) / int64(cfg.ShardSize))
+	if n < cfg.Workers {
+		n = cfg.Workers
+	}
+	shards := Partition(cfg.SeedStart, cfg.SeedEnd, n)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	reports := make([]*report.JSONReport, len(shards))
+	work := make(chan Shard)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for w := 0; w < cfg.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range work {
+				r, err := runShard(ctx, cfg, exec, s)
+				mu.Lock()
+				if cfg.OnShard != nil {
+					cfg.OnShard(s, err)
+				}
+				if err != nil && firstErr == nil {
+					firstErr = fmt.Errorf("shard %d (seeds %d-%d): %w", s.Index, s.SeedStart, s.SeedEnd, err)
+					cancel()
+				}
+				reports[s.Index] = r
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, s := range shards {
+		select {
+		case work <- s:
+		case <-ctx.Done():
+		}
+	}
+	close(work)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return report.MergeJSON(cfg.Confidence, reports...), nil
+}
+
+func runShard(ctx context.Context, cfg Config, exec Executor, s Shard) (*report.JSONReport, error) {
+	var err error
+	for attempt := 0; attempt <= cfg.Retries; attempt++ {
+		var r *report.JSONReport
+		if r, err = exec.Run(ctx, cfg, s); err == nil {
+			return r, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	return nil, err
+}
+
+// Local runs shards as go test loops on this machine; each concurrent shard
+// is its own go test process
+type Local struct{}
+
+// Run detects flakes over the shard's seeds with the runner
+func (Local) Run(ctx context.Context, cfg Config, s Shard) (*report.JSONReport, error) {
+	rc := runner.Config{Packages: []string{cfg.pkg()}, Runs: s.Runs(), Seed: s.SeedStart, Run: cfg.Run, Dir: cfg.Dir}
+	if cfg.Set != "" {
+		rc.Env = []string{flaky.SetEnv + "=" + cfg.Set}
+	}
+	r, err := runner.Detect(ctx, rc)
+	if err != nil {
+		return nil, err
+	}
+	return report.NewJSONReport(r, cfg.Confidence, nil), nil
+}
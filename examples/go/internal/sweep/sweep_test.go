@@ -0,0 +1,96 @@
+package sweep
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/report"
+)
+
+func TestPartition(t *testing.T) {
+	shards := Partition(0, 9, 3)
+	want := []Shard{{0, 0, 3}, {1, 4, 6}, {2, 7, 9}}
+	if !reflect.DeepEqual(shards, want) {
+		t.Errorf("Expected %v, got %v", want, shards)
+	}
+	if got := Partition(5, 6, 10); len(got) != 2 {
+		t.Errorf("Expected shards capped at the seed count, got %v", got)
+	}
+	if got := Partition(5, 4, 2); got != nil {
+		t.Errorf("Expected no shards for an empty range, got %v", got)
+	}
+}
+
+// parityExecutor fails TestOdd on every odd seed and records the shards it ran
+type parityExecutor struct {
+	mu       sync.Mutex
+	shards   []Shard
+	failures map[int]int // shard index -> errors to return before succeeding
+}
+
+func (e *parityExecutor) Run(_ context.Context, _ Config, s Shard) (*report.JSONReport, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.failures[s.Index] > 0 {
+		e.failures[s.Index]--
+		return nil, errors.New("worker lost")
+	}
+	e.shards = append(e.shards, s)
+	test := report.JSONTest{Package: "p", Test: "TestOdd", Runs: s.Runs()}
+	for seed := s.SeedStart; seed <= s.SeedEnd; seed++ {
+		if seed%2 == 1 {
+			test.Failed++
+			test.FailingSeeds = append(test.FailingSeeds, seed)
+			test.Failures = []report.JSONFailure{{Message: "odd seed", Seeds: test.FailingSeeds}}
+		} else {
+			test.Passed++
+		}
+	}
+	return &report.JSONReport{Runs: s.Runs(), Tests: []report.JSONTest{test}}, nil
+}
+
+func TestRunMergesShards(t *testing.T) {
+	exec := &parityExecutor{failures: map[int]int{2: 1}}
+	var done int
+	r, err := Run(context.Background(), Config{
+		SeedStart: 0,
+		SeedEnd:   99,
+		Workers:   4,
+		ShardSize: 10,
+		Retries:   1,
+		OnShard:   func(Shard, error) { done++ },
+	}, exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exec.shards) != 10 || done != 10 {
+		t.Errorf("Expected 10 shards of 10 seeds, ran %d (%d callbacks)", len(exec.shards), done)
+	}
+	if r.Runs != 100 || len(r.Tests) != 1 {
+		t.Fatalf("Unexpected merged report: %+v", r)
+	}
+	odd := r.Tests[0]
+	if odd.Failed != 50 || odd.Classification != "flaky" || len(odd.Failures) != 1 || len(odd.Failures[0].Seeds) != 50 {
+		t.Errorf("Unexpected merged test: %+v", odd)
+	}
+	if odd.Failures[0].Seeds[0] != 1 || odd.Failures[0].Seeds[49] != 99 {
+		t.Errorf("Expected sorted seeds 1..99, got %v", odd.Failures[0].Seeds)
+	}
+}
+
+func TestRunFailsWhenRetriesExhausted(t *testing.T) {
+	exec := &parityExecutor{failures: map[int]int{1: 3}}
+	_, err := Run(context.Background(), Config{SeedStart: 0, SeedEnd: 19, Workers: 2, ShardSize: 10, Retries: 2}, exec)
+	if err == nil || err.Error() != "shard 1 (seeds 10-19): worker lost" {
+		t.Errorf("Expected shard 1 to fail the sweep, got %v", err)
+	}
+}
+
+func TestRunRejectsEmptyRange(t *testing.T) {
+	if _, err := Run(context.Background(), Config{SeedStart: 2, SeedEnd: 1}, &parityExecutor{}); err == nil {
+		t.Error("Expected an error for an empty seed range")
+	}
+}
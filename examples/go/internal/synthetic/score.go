@@ -0,0 +1,122 @@
+package synthetic
+
+import (
+	"math"
+
+	"github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// Score grades a flake report of a generated suite against its Truth, with
+// the flaky tests as the positives
+type Score struct {
+	// TruePositives are flaky tests reported flaky, FalsePositives stable or
+	// broken tests reported flaky and FalseNegatives flaky tests reported as
+	// anything else, or missing from the report
+	TruePositives  int
+	FalsePositives int
+	FalseNegatives int
+	// Missed names the false negatives and FalseAlarms the false positives,
+	// in suite order
+	Missed      []string
+	FalseAlarms []string
+	// BrokenFound counts the broken tests reported failing, of Broken
+	BrokenFound, Broken int
+	// RateError is the mean absolute difference between the reported and
+	// the true flake rates of the true positives
+	RateError float64
+	// CategoryHits counts the true positives whose most common reported
+	// failure category is the true one
+	CategoryHits int
+	// Runs totals the runs the report spent on the suite's tests, and
+	// RunsPerFlake divides them by the true positives found with them
+	Runs         int
+	RunsPerFlake float64
+}
+
+// Precision returns the share of the tests reported flaky that are, 1 when
+// none were reported
+func (s Score) Precision() float64 {
+	if s.TruePositives+s.FalsePositives == 0 {
+		return 1
+	}
+	return float64(s.TruePositives) / float64(s.TruePositives+s.FalsePositives)
+}
+
+// Recall returns the share of the flaky tests reported flaky, 1 when the
+// suite has none
+func (s Score) Recall() float64 {
+	if s.TruePositives+s.FalseNegatives == 0 {
+		return 1
+	}
+	return float64(s.TruePositives) / float64(s.TruePositives+s.FalseNegatives)
+}
+
+// F1 returns the harmonic mean of Precision and Recall
+func (s Score) F1() float64 {
+	p, r := s.Precision(), s.Recall()
+	if p+r == 0 {
+		return 0
+	}
+	return 2 * p * r / (p + r)
+}
+
+// Evaluate scores r against truth, matching tests by name; tests of r that
+// are not in truth are ignored
+func Evaluate(truth *Truth, r *report.JSONReport) Score {
+	reported := make(map[string]report.JSONTest, len(r.Tests))
+	for _, t := range r.Tests {
+		if t.Parent == "" {
+			reported[t.Test] = t
+		}
+	}
+
+	var s Score
+	var rateError float64
+	for _, want := range truth.Tests {
+		got, ok := reported[want.Test]
+		s.Runs += got.Runs
+		flagged := ok && got.Classification == string(runner.Flaky)
+		switch want.Classification {
+		case runner.Flaky:
+			if !flagged {
+				s.FalseNegatives++
+				s.Missed = append(s.Missed, want.Test)
+				continue
+			}
+			s.TruePositives++
+			rateError += math.Abs(got.FlakeRate - want.FlakeRate)
+			if topCategory(got.Categories) == want.Category {
+				s.CategoryHits++
+			}
+		case runner.Failing:
+			s.Broken++
+			if ok && got.Classification == string(runner.Failing) {
+				s.BrokenFound++
+			}
+			fallthrough
+		default:
+			if flagged {
+				s.FalsePositives++
+				s.FalseAlarms = append(s.FalseAlarms, want.Test)
+			}
+		}
+	}
+	if s.TruePositives > 0 {
+		s.RateError = rateError / float64(s.TruePositives)
+		s.RunsPerFlake = float64(s.Runs) / float64(s.TruePositives)
+	}
+	return s
+}
+
+// topCategory returns the category with the most runs, the first by name
+// on a tie
+func topCategory(counts map[string]int) string {
+	var top string
+	for c, n := range counts {
+		if n > counts[top] || n == counts[top] && c < top {
+			top = c
+		}
+	}
+	return top
+}
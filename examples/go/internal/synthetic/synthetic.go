@@ -0,0 +1,238 @@
+// Package synthetic generates Go test suites whose flaky tests, flake rates
+// and failure categories are known in advance, so a detector's verdicts can
+// be scored against the right answers
+//
+// Every generated test draws from a hash of GO_TEST_SEED and its name, so a
+// seed fails the same tests on every run and flaky tests reproduce with
+// flakectl reproduce like real ones
+package synthetic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// TruthFile is the name of the ground-truth file written next to a suite
+const TruthFile = "ground_truth.json"
+
+// Categories are the failure categories a flaky test can fail with, named
+// as report.DefaultClassifier names them
+var Categories = []string{report.CategoryAssertion, report.CategoryTimeout, report.CategoryNetwork}
+
+// messages are the failure messages of each category, matched by the
+// default classification rules
+var messages = map[string]string{
+	report.CategoryAssertion: "Expected %d items, got %d",
+	report.CategoryTimeout:   "timed out after %dms waiting for worker %d",
+	report.CategoryNetwork:   "dial tcp 10.0.0.%d:%d: connect: connection refused",
+}
+
+// Spec describes a suite to generate
+type Spec struct {
+	// Package is the package name (default "synthetic")
+	Package string
+	// Tests is the total number of tests, Flaky of them flaky and Broken of
+	// them failing on every run; the rest always pass
+	Tests  int
+	Flaky  int
+	Broken int
+	// Rates are the flake rates of the flaky tests, assigned in turn
+	Rates []float64
+	// Categories are the failure categories of the flaky tests, assigned in
+	// turn (default Categories)
+	Categories []string
+	// Seed picks which tests are flaky or broken
+	Seed int64
+}
+
+// Validate reports counts that do not fit, rates outside (0, 1) and
+// unknown categories
+func (s Spec) Validate() error {
+	if s.Tests <= 0 {
+		return fmt.Errorf("tests must be positive, got %d", s.Tests)
+	}
+	if s.Flaky < 0 || s.Broken < 0 || s.Flaky+s.Broken > s.Tests {
+		return fmt.Errorf("%d flaky and %d broken tests do not fit in %d", s.Flaky, s.Broken, s.Tests)
+	}
+	if s.Flaky > 0 && len(s.Rates) == 0 {
+		return fmt.Errorf("flaky tests need at least one rate")
+	}
+	for _, rate := range s.Rates {
+		if rate <= 0 || rate >= 1 {
+			return fmt.Errorf("flake rate %v outside (0, 1)", rate)
+		}
+	}
+	for _, c := range s.Categories {
+		if !slices.Contains(Categories, c) {
+			return fmt.Errorf("unknown category %q, want one of %s", c, strings.Join(Categories, ", "))
+		}
+	}
+	return nil
+}
+
+// Truth is the right answer for a generated suite
+type Truth struct {
+	Package string      `json:"package"`
+	Seed    int64       `json:"seed"`
+	Tests   []TruthTest `json:"tests"`
+}
+
+// TruthTest is one generated test and how it behaves, with the fields
+// named as in the JSON flake report
+type TruthTest struct {
+	Test           string                `json:"test"`
+	Classification runner.Classification `json:"classification"`
+	// FlakeRate is the chance any one seed fails the test: the drawn rate
+	// of a flaky test, 1 for a broken one and 0 for a stable one
+	FlakeRate float64 `json:"flake_rate"`
+	// Category is the category the test's failures are classified as
+	Category string `json:"category,omitempty"`
+}
+
+// Generate lays out the suite spec describes
+func Generate(spec Spec) (*Truth, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	if spec.Package == "" {
+		spec.Package = "synthetic"
+	}
+	categories := spec.Categories
+	if len(categories) == 0 {
+		categories = Categories
+	}
+
+	truth := &Truth{Package: spec.Package, Seed: spec.Seed, Tests: make([]TruthTest, spec.Tests)}
+	width := len(fmt.Sprint(spec.Tests - 1))
+	for i := range truth.Tests {
+		truth.Tests[i] = TruthTest{Test: fmt.Sprintf("TestSynthetic%0*d", width, i), Classification: runner.Stable}
+	}
+	// The first Flaky positions of a seeded permutation are flaky, the next
+	// Broken ones broken
+	rng := rand.New(rand.NewPCG(uint64(spec.Seed), 0))
+	order := rng.Perm(spec.Tests)
+	for n, i := range order[:spec.Flaky] {
+		t := &truth.Tests[i]
+		t.Classification = runner.Flaky
+		t.FlakeRate = spec.Rates[n%len(spec.Rates)]
+		t.Category = categories[n%len(categories)]
+	}
+	for n, i := range order[spec.Flaky : spec.Flaky+spec.Broken] {
+		t := &truth.Tests[i]
+		t.Classification = runner.Failing
+		t.FlakeRate = 1
+		t.Category = categories[n%len(categories)]
+	}
+	return truth, nil
+}
+
+// Write generates the suite spec describes into dir, as a doc.go, a
+// synthetic_test.go and the TruthFile, creating dir when missing
+func Write(dir string, spec Spec) (*Truth, error) {
+	truth, err := Generate(spec)
+	if err != nil {
+		return nil, err
+	}
+	src, err := truth.source()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(truth, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	doc := fmt.Sprintf("// Code generated by flakectl generate; DO NOT EDIT.\n\n"+
+		"// Package %s holds %d synthetic tests generated to benchmark flake\n"+
+		"// detectors; %s lists which are flaky, at what rate and how\n"+
+		"// they fail\n"+
+		"package %[1]s\n", truth.Package, len(truth.Tests), TruthFile)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	for name, content := range map[string][]byte{
+		"doc.go":            []byte(doc),
+		"synthetic_test.go": src,
+		TruthFile:           append(data, '\n'),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			return nil, err
+		}
+	}
+	return truth, nil
+}
+
+// source renders the suite's test file
+func (truth *Truth) source() ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `// Code generated by flakectl generate; DO NOT EDIT.
+
+package %s
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// draw returns the test's draw in [0, 1) for the run's GO_TEST_SEED, the
+// same on every run with that seed
+func draw(t *testing.T) float64 {
+	seed, _ := strconv.ParseInt(os.Getenv("GO_TEST_SEED"), 10, 64)
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%%d/%%s", seed, t.Name())
+	// Mix the hash, so neighbouring seeds draw independently
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return float64(x>>11) / (1 << 53)
+}
+`, truth.Package)
+
+	for i, t := range truth.Tests {
+		b.WriteString("\n")
+		msg := ""
+		if t.Category != "" {
+			msg = fmt.Sprintf("t.Errorf(%q, %d, %d)", messages[t.Category], 100+i, 99+i)
+		}
+		switch t.Classification {
+		case runner.Flaky:
+			fmt.Fprintf(&b, "// %s is flaky: fails %.4g%% of seeds, classified as %s\n", t.Test, 100*t.FlakeRate, t.Category)
+			fmt.Fprintf(&b, "func %s(t *testing.T) {\n\tif draw(t) < %v {\n\t\t%s\n\t}\n}\n", t.Test, t.FlakeRate, msg)
+		case runner.Failing:
+			fmt.Fprintf(&b, "// %s is broken: fails every seed, classified as %s\n", t.Test, t.Category)
+			fmt.Fprintf(&b, "func %s(t *testing.T) {\n\t%s\n}\n", t.Test, msg)
+		default:
+			fmt.Fprintf(&b, "// %s is stable\nfunc %[1]s(t *testing.T) {}\n", t.Test)
+		}
+	}
+	return format.Source(b.Bytes())
+}
+
+// LoadTruth reads the TruthFile of a generated suite
+func LoadTruth(path string) (*Truth, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var truth Truth
+	if err := json.Unmarshal(data, &truth); err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	return &truth, nil
+}
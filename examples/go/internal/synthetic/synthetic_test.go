@@ -0,0 +1,112 @@
+package synthetic
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestGenerate(t *testing.T) {
+	spec := Spec{Tests: 20, Flaky: 4, Broken: 2, Rates: []float64{0.1, 0.5}, Seed: 7}
+	truth, err := Generate(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truth.Package != "synthetic" || len(truth.Tests) != 20 || truth.Tests[3].Test != "TestSynthetic03" {
+		t.Fatalf("Unexpected suite: %+v", truth)
+	}
+	counts := make(map[runner.Classification]int)
+	rates := make(map[float64]int)
+	for _, tt := range truth.Tests {
+		counts[tt.Classification]++
+		if tt.Classification == runner.Flaky {
+			rates[tt.FlakeRate]++
+		}
+	}
+	if counts[runner.Flaky] != 4 || counts[runner.Failing] != 2 || counts[runner.Stable] != 14 {
+		t.Errorf("Expected 4 flaky, 2 broken and 14 stable tests, got %v", counts)
+	}
+	if rates[0.1] != 2 || rates[0.5] != 2 {
+		t.Errorf("Expected the rates assigned in turn, got %v", rates)
+	}
+
+	again, _ := Generate(spec)
+	if !reflect.DeepEqual(truth, again) {
+		t.Error("Expected the same seed to pick the same tests")
+	}
+}
+
+func TestSpecValidate(t *testing.T) {
+	for _, spec := range []Spec{
+		{Tests: 0},
+		{Tests: 5, Flaky: 4, Broken: 2, Rates: []float64{0.1}},
+		{Tests: 5, Flaky: 1},
+		{Tests: 5, Flaky: 1, Rates: []float64{1}},
+		{Tests: 5, Flaky: 1, Rates: []float64{0.1}, Categories: []string{"race"}},
+	} {
+		if err := spec.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", spec)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	truth := &Truth{Tests: []TruthTest{
+		{Test: "TestA", Classification: runner.Flaky, FlakeRate: 0.2, Category: report.CategoryTimeout},
+		{Test: "TestB", Classification: runner.Flaky, FlakeRate: 0.01, Category: report.CategoryNetwork},
+		{Test: "TestC", Classification: runner.Stable},
+		{Test: "TestD", Classification: runner.Failing, FlakeRate: 1, Category: report.CategoryAssertion},
+	}}
+	r := &report.JSONReport{Tests: []report.JSONTest{
+		{Test: "TestA", Classification: "flaky", Runs: 10, FlakeRate: 0.3, Categories: map[string]int{"timeout": 3}},
+		{Test: "TestB", Classification: "stable", Runs: 10},
+		{Test: "TestC", Classification: "flaky", Runs: 10, FlakeRate: 0.1},
+		{Test: "TestD", Classification: "failing", Runs: 10, FlakeRate: 1},
+	}}
+	s := Evaluate(truth, r)
+	if s.TruePositives != 1 || s.FalsePositives != 1 || s.FalseNegatives != 1 || s.BrokenFound != 1 || s.Broken != 1 {
+		t.Fatalf("Unexpected score: %+v", s)
+	}
+	if s.Precision() != 0.5 || s.Recall() != 0.5 || s.F1() != 0.5 {
+		t.Errorf("Expected precision, recall and F1 of 0.5, got %v %v %v", s.Precision(), s.Recall(), s.F1())
+	}
+	if s.Missed[0] != "TestB" || s.FalseAlarms[0] != "TestC" || s.CategoryHits != 1 {
+		t.Errorf("Unexpected misses: %+v", s)
+	}
+	if s.RateError < 0.0999 || s.RateError > 0.1001 || s.Runs != 40 || s.RunsPerFlake != 40 {
+		t.Errorf("Unexpected rate error or run budget: %+v", s)
+	}
+}
+
+func TestWriteRunsAndScores(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	dir := t.TempDir()
+	truth, err := Write(dir, Spec{Tests: 6, Flaky: 3, Broken: 1, Rates: []float64{0.5}, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/synthetic\n\ngo 1.22\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadTruth(filepath.Join(dir, TruthFile))
+	if err != nil || !reflect.DeepEqual(loaded, truth) {
+		t.Fatalf("Expected the ground truth written, got %+v, %v", loaded, err)
+	}
+
+	r, err := runner.Detect(context.Background(), runner.Config{Packages: []string{"./..."}, Dir: dir, Runs: 30, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Evaluate(truth, report.NewJSONReport(r, 0.95, nil))
+	// A 50% flake passes or fails 30 runs in a row once in 5e8 suites
+	if s.Precision() != 1 || s.Recall() != 1 || s.BrokenFound != 1 || s.CategoryHits != 3 {
+		t.Errorf("Expected every flaky and broken test found and categorized, got %+v", s)
+	}
+}
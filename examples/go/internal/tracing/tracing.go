@@ -0,0 +1,212 @@
+// Package tracing records detection runs as OpenTelemetry traces
+//
+// Each run of the suite is a trace: a root span covering the run's tests,
+// with a child span per test execution carrying its seed, outcome, duration
+// and failure class. Subtest spans are children of their parent test's
+// span. Exported over OTLP, the traces of a serverless worker share its
+// resource attributes with the infrastructure traces of the pod that ran
+// them
+package tracing
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/example/flaky-test-example/internal/report"
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// instrumentation names the tracer the spans are recorded with
+const instrumentation = "github.com/example/flaky-test-example/internal/tracing"
+
+// Span attribute keys; the test.case and test.suite keys follow the
+// OpenTelemetry semantic conventions for tests
+const (
+	SuiteName     = attribute.Key("test.suite.name")
+	CaseName      = attribute.Key("test.case.name")
+	CaseStatus    = attribute.Key("test.case.result.status")
+	Package       = attribute.Key("test.package")
+	Run           = attribute.Key("test.run")
+	Seed          = attribute.Key("test.seed")
+	Duration      = attribute.Key("test.duration")
+	FailureClass  = attribute.Key("test.failure.class")
+	FailureKind   = attribute.Key("test.failure.kind")
+	FailureReason = attribute.Key("test.failure.message")
+)
+
+// Enabled reports whether the standard OpenTelemetry variables name an
+// OTLP endpoint for traces
+func Enabled() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// NewProvider returns a tracer provider that batches spans to an OTLP/HTTP
+// collector configured by the OTEL_EXPORTER_OTLP_* variables, by default
+// http://localhost:4318; call Shutdown to flush it
+// The resource names service unless OTEL_SERVICE_NAME overrides it, and
+// carries the host, OTEL_RESOURCE_ATTRIBUTES and, on RunPod, the pod and
+// endpoint IDs
+func NewProvider(ctx context.Context, service string) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	attrs := []attribute.KeyValue{attribute.String("service.name", service)}
+	for key, env := range map[string]string{"runpod.pod_id": "RUNPOD_POD_ID", "runpod.endpoint_id": "RUNPOD_ENDPOINT_ID"} {
+		if v := os.Getenv(env); v != "" {
+			attrs = append(attrs, attribute.String(key, v))
+		}
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attrs...),
+		resource.WithHost(),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)), nil
+}
+
+// Recorder turns runs of the suite into traces
+type Recorder struct {
+	tracer     trace.Tracer
+	classifier *report.Classifier
+}
+
+// NewRecorder records spans with tp, classifying failures with c
+// (report.DefaultClassifier when nil)
+func NewRecorder(tp trace.TracerProvider, c *report.Classifier) *Recorder {
+	if c == nil {
+		c = report.DefaultClassifier
+	}
+	return &Recorder{tracer: tp.Tracer(instrumentation), classifier: c}
+}
+
+// Observer returns a runner.Config.Observe function recording every run of
+// cfg; a nil Recorder returns nil, which records nothing
+// A span in ctx becomes a link of each run's trace, not its parent
+// Each trace takes the seed its run's results ran with, so an observer kept
+// across sweeps that move cfg.Seed on, as a daemon's, still records it
+func (r *Recorder) Observer(ctx context.Context, cfg runner.Config) func(run int, results []runner.Result) {
+	if r == nil {
+		return nil
+	}
+	suite := strings.Join(cfg.Packages, " ")
+	if suite == "" {
+		suite = "."
+	}
+	return func(run int, results []runner.Result) {
+		seed := cfg.Seed + int64(run)
+		if len(results) > 0 {
+			seed = results[0].Seed
+		}
+		r.Record(ctx, suite, run, seed, results)
+	}
+}
+
+// Record records one run of suite as a trace
+// The root span runs from the first test's start to the last test's end,
+// so it leaves out building the test binary
+func (r *Recorder) Record(ctx context.Context, suite string, run int, seed int64, results []runner.Result) {
+	start, end := time.Now(), time.Time{}
+	for _, res := range results {
+		if !res.Start.IsZero() && res.Start.Before(start) {
+			start = res.Start
+		}
+	}
+	for _, res := range results {
+		end = later(end, spanStart(res, start).Add(res.Duration))
+	}
+	if end.Before(start) {
+		end = start
+	}
+
+	opts := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(SuiteName.String(suite), Run.Int(run), Seed.Int64(seed)),
+	}
+	if link := trace.LinkFromContext(ctx); link.SpanContext.IsValid() {
+		opts = append(opts, trace.WithLinks(link))
+	}
+	ctx, root := r.tracer.Start(ctx, "go test "+suite, opts...)
+
+	// Parents finish after their subtests, so start spans shallowest first
+	ordered := append([]runner.Result(nil), results...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return strings.Count(ordered[i].Test, "/") < strings.Count(ordered[j].Test, "/")
+	})
+	parents := make(map[[2]string]context.Context)
+	failed := false
+	for _, res := range ordered {
+		parent := ctx
+		if i := strings.LastIndex(res.Test, "/"); i > 0 {
+			if p, ok := parents[[2]string{res.Package, res.Test[:i]}]; ok {
+				parent = p
+			}
+		}
+		spanCtx := r.recordTest(parent, res, start)
+		parents[[2]string{res.Package, res.Test}] = spanCtx
+		failed = failed || res.Outcome == runner.Fail
+	}
+
+	if failed {
+		root.SetStatus(codes.Error, "tests failed")
+	}
+	root.End(trace.WithTimestamp(end))
+}
+
+// recordTest records one test execution under ctx and returns the context
+// of its span
+func (r *Recorder) recordTest(ctx context.Context, res runner.Result, runStart time.Time) context.Context {
+	start := spanStart(res, runStart)
+	attrs := []attribute.KeyValue{
+		CaseName.String(res.Test),
+		CaseStatus.String(string(res.Outcome)),
+		Package.String(res.Package),
+		Seed.Int64(res.Seed),
+		Duration.Float64(res.Duration.Seconds()),
+	}
+	ctx, span := r.tracer.Start(ctx, res.Test, trace.WithTimestamp(start), trace.WithAttributes(attrs...))
+	if res.Outcome == runner.Fail {
+		msg := "test failed"
+		if msgs := runner.FailureMessages(res.Output); len(msgs) > 0 {
+			msg = msgs[0]
+		}
+		span.SetAttributes(
+			FailureClass.String(r.classifier.Classify(res)),
+			FailureKind.String(string(res.Kind)),
+			FailureReason.String(msg),
+		)
+		span.SetStatus(codes.Error, msg)
+	}
+	span.End(trace.WithTimestamp(start.Add(res.Duration)))
+	return ctx
+}
+
+// spanStart is when res started, or runStart when go test did not say
+func spanStart(res runner.Result, runStart time.Time) time.Time {
+	if res.Start.IsZero() {
+		return runStart
+	}
+	return res.Start
+}
+
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
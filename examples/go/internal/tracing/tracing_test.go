@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func attr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestRecord(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	results := []runner.Result{
+		{Package: "p", Test: "TestA/sub", Seed: 7, Outcome: runner.Fail, Kind: runner.Assertion, Start: start.Add(time.Millisecond),
+			Duration: time.Millisecond, Output: "    a_test.go:9: dial tcp: connection refused\n"},
+		{Package: "p", Test: "TestA", Seed: 7, Outcome: runner.Fail, Kind: runner.Assertion, Start: start, Duration: 5 * time.Millisecond},
+		{Package: "p", Test: "TestB", Seed: 7, Outcome: runner.Pass, Start: start.Add(5 * time.Millisecond), Duration: 2 * time.Millisecond},
+	}
+	// The seed comes from the results, not a config a daemon has moved on
+	observe := NewRecorder(tp, nil).Observer(context.Background(), runner.Config{Packages: []string{"./..."}, Seed: 1})
+	observe(2, results)
+
+	ended := spans.Ended()
+	if len(ended) != 4 {
+		t.Fatalf("Expected 4 spans, got %d", len(ended))
+	}
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range ended {
+		byName[s.Name()] = s
+	}
+	root, a, sub, b := byName["go test ./..."], byName["TestA"], byName["TestA/sub"], byName["TestB"]
+	if root == nil || a == nil || sub == nil || b == nil {
+		t.Fatalf("Missing spans: %v", byName)
+	}
+	if root.Parent().IsValid() || attr(root, Seed).AsInt64() != 7 || attr(root, Run).AsInt64() != 2 {
+		t.Errorf("Expected a root span for run 2 with seed 7, got %+v", root.Attributes())
+	}
+	if !root.StartTime().Equal(start) || !root.EndTime().Equal(start.Add(7*time.Millisecond)) {
+		t.Errorf("Expected the root to span the tests, got %v to %v", root.StartTime(), root.EndTime())
+	}
+	if a.Parent().SpanID() != root.SpanContext().SpanID() || sub.Parent().SpanID() != a.SpanContext().SpanID() {
+		t.Error("Expected TestA under the root and TestA/sub under TestA")
+	}
+	if a.SpanContext().TraceID() != root.SpanContext().TraceID() {
+		t.Error("Expected one trace per run")
+	}
+	if attr(sub, FailureClass).AsString() != "network" || sub.Status().Code != codes.Error ||
+		sub.Status().Description != "a_test.go:9: dial tcp: connection refused" {
+		t.Errorf("Unexpected failing span: %+v %+v", sub.Attributes(), sub.Status())
+	}
+	if attr(b, CaseStatus).AsString() != "pass" || attr(b, Duration).AsFloat64() != 0.002 || b.Status().Code == codes.Error {
+		t.Errorf("Unexpected passing span: %+v", b.Attributes())
+	}
+}
+
+func TestNilRecorderObservesNothing(t *testing.T) {
+	var r *Recorder
+	if r.Observer(context.Background(), runner.Config{}) != nil {
+		t.Error("Expected a nil Recorder to return no observer")
+	}
+}
@@ -0,0 +1,402 @@
+// Package watch reruns the tests of changed packages and keeps a rolling
+// window of every test's outcomes
+//
+// The package directories are polled for changed Go files. A change reruns the
+// packages in that directory and every package whose code or tests import
+// them, a few times with fresh seeds, so the flake rate of a test being
+// fixed updates with each edit
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+// Config describes a watch session
+type Config struct {
+	// Packages are the package patterns watched (default ".")
+	Packages []string
+	// Dir is the directory go test runs in
+	Dir string
+	// Run is an optional -run regex
+	Run string
+	// Seed is the seed of the first run; every later run uses the next seed
+	Seed int64
+	// Runs is how many times affected packages run after a change
+	// (default 5)
+	Runs int
+	// Window is how many recent outcomes of each test are kept (default 20)
+	Window int
+	// Interval is how often the package directories are polled
+	// (default 500ms)
+	Interval time.Duration
+	// Clear redraws the screen on every update instead of appending to it
+	Clear bool
+	// Env holds additional KEY=VALUE pairs for every run
+	Env []string
+}
+
+func (cfg *Config) defaults() {
+	if len(cfg.Packages) == 0 {
+		cfg.Packages = []string{"."}
+	}
+	if cfg.Runs == 0 {
+		cfg.Runs = 5
+	}
+	if cfg.Window == 0 {
+		cfg.Window = 20
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = 500 * time.Millisecond
+	}
+}
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\x1b[H\x1b[2J"
+
+// Run watches until ctx is cancelled, writing the window of every test to w
+// after each run
+// Packages added after the watch starts are not picked up
+func Run(ctx context.Context, cfg Config, w io.Writer) error {
+	cfg.defaults()
+	if cfg.Runs < 1 || cfg.Window < 1 {
+		return fmt.Errorf("runs and window must be at least 1, got %d and %d", cfg.Runs, cfg.Window)
+	}
+	pkgs, err := listPackages(ctx, cfg.Dir, cfg.Packages)
+	if err != nil {
+		return err
+	}
+	dirs := make([]string, len(pkgs))
+	for i, p := range pkgs {
+		dirs[i] = p.Dir
+	}
+
+	snap := scan(dirs)
+	window := NewWindow(cfg.Window)
+	pending := Affected(pkgs, dirs)
+	remaining := cfg.Runs
+	reason := "started"
+	run := 0
+	for {
+		if remaining > 0 {
+			rcfg := runner.Config{Packages: pending, Run: cfg.Run, Dir: cfg.Dir, Seed: cfg.Seed, Env: cfg.Env}
+			results, err := runner.RunOnce(ctx, rcfg, run)
+			if ctx.Err() != nil {
+				return nil
+			}
+			status := fmt.Sprintf("run %d (seed %d) of %d package(s), %s", run+1, cfg.Seed+int64(run), len(pending), reason)
+			if err != nil {
+				// A broken build stops the batch until the next change
+				status = fmt.Sprintf("run %d failed, %s: %v", run+1, reason, firstLine(err.Error()))
+				remaining = 0
+			} else {
+				window.Add(results)
+				remaining--
+			}
+			run++
+			if cfg.Clear {
+				io.WriteString(w, clearScreen)
+			}
+			if err := Render(w, len(pkgs), status, window); err != nil {
+				return err
+			}
+		} else {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(cfg.Interval):
+			}
+		}
+
+		next := scan(dirs)
+		changed := changedFiles(snap, next)
+		snap = next
+		if len(changed) == 0 {
+			continue
+		}
+		changedDirs := make([]string, len(changed))
+		for i, path := range changed {
+			changedDirs[i] = packageDir(dirs, path)
+		}
+		pending = Affected(pkgs, changedDirs)
+		remaining = cfg.Runs
+		reason = fmt.Sprintf("after change to %s at %s", filepath.Base(changed[0]), time.Now().Format(time.TimeOnly))
+		if len(changed) > 1 {
+			reason = fmt.Sprintf("after changes to %s and %d more at %s", filepath.Base(changed[0]), len(changed)-1, time.Now().Format(time.TimeOnly))
+		}
+	}
+}
+
+// Package is a watched package
+type Package struct {
+	ImportPath string
+	Dir        string
+	// Imports holds the import paths of every package the package's code,
+	// directly or not, or its tests import
+	Imports map[string]bool
+}
+
+// listPackages resolves patterns with go list
+func listPackages(ctx context.Context, dir string, patterns []string) ([]Package, error) {
+	cmd := exec.CommandContext(ctx, "go", append([]string{"list", "-json=ImportPath,Dir,Deps,TestImports,XTestImports"}, patterns...)...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list %s: %w\n%s", strings.Join(patterns, " "), err, stderr.String())
+	}
+	var pkgs []Package
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for dec.More() {
+		var p struct {
+			ImportPath, Dir                 string
+			Deps, TestImports, XTestImports []string
+		}
+		if err := dec.Decode(&p); err != nil {
+			return nil, fmt.Errorf("go list: %w", err)
+		}
+		pkg := Package{ImportPath: p.ImportPath, Dir: p.Dir, Imports: make(map[string]bool)}
+		for _, list := range [][]string{p.Deps, p.TestImports, p.XTestImports} {
+			for _, path := range list {
+				pkg.Imports[path] = true
+			}
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+// Affected returns the import paths of the packages in dirs and of every
+// package that imports one of them, sorted
+func Affected(pkgs []Package, dirs []string) []string {
+	changed := make(map[string]bool)
+	for _, p := range pkgs {
+		for _, dir := range dirs {
+			if p.Dir == dir {
+				changed[p.ImportPath] = true
+			}
+		}
+	}
+	var affected []string
+	for _, p := range pkgs {
+		hit := changed[p.ImportPath]
+		for path := range changed {
+			hit = hit || p.Imports[path]
+		}
+		if hit {
+			affected = append(affected, p.ImportPath)
+		}
+	}
+	sort.Strings(affected)
+	return affected
+}
+
+// fileState is what a poll compares to notice a changed file
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// scan records the state of the Go files, go.mod and go.sum in dirs and of
+// every file in their testdata directories
+// Other files are left out, since tests often write reports such as
+// flaky-failures.json next to their code and would rerun themselves
+func scan(dirs []string) map[string]fileState {
+	files := make(map[string]fileState)
+	add := func(path string, d fs.DirEntry) {
+		if info, err := d.Info(); err == nil {
+			files[path] = fileState{info.ModTime(), info.Size()}
+		}
+	}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if name := e.Name(); !e.IsDir() && (strings.HasSuffix(name, ".go") || name == "go.mod" || name == "go.sum") {
+				add(filepath.Join(dir, name), e)
+			}
+		}
+		filepath.WalkDir(filepath.Join(dir, "testdata"), func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && !strings.HasPrefix(d.Name(), ".") {
+				add(path, d)
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+// changedFiles returns the files added, removed or modified between two
+// scans, sorted
+func changedFiles(before, after map[string]fileState) []string {
+	var changed []string
+	for path, state := range after {
+		if old, ok := before[path]; !ok || old != state {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// packageDir returns the directory among dirs that holds path, the deepest
+// one when several do
+func packageDir(dirs []string, path string) string {
+	best := ""
+	for _, dir := range dirs {
+		if (filepath.Dir(path) == dir || strings.HasPrefix(path, filepath.Join(dir, "testdata")+string(filepath.Separator))) && len(dir) > len(best) {
+			best = dir
+		}
+	}
+	return best
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// Window keeps the most recent outcomes of every test
+type Window struct {
+	size  int
+	tests map[[2]string]*TestWindow
+}
+
+// TestWindow is one test's recent outcomes
+type TestWindow struct {
+	Package string
+	Test    string
+	// Outcomes holds the most recent passes and failures, oldest first
+	Outcomes []runner.Outcome
+	// Total counts every pass and failure since the watch started
+	Total int
+	// LastFailure is the first message of the most recent failure
+	LastFailure string
+	// Previous is the window's flake rate before the latest outcome, or -1
+	// before the test's first outcome
+	Previous float64
+}
+
+// NewWindow keeps up to size outcomes per test
+func NewWindow(size int) *Window {
+	return &Window{size: size, tests: make(map[[2]string]*TestWindow)}
+}
+
+// Add records a run's results; skips are ignored
+func (w *Window) Add(results []runner.Result) {
+	for _, r := range results {
+		if r.Outcome == runner.Skip {
+			continue
+		}
+		key := [2]string{r.Package, r.Test}
+		t := w.tests[key]
+		if t == nil {
+			t = &TestWindow{Package: r.Package, Test: r.Test}
+			w.tests[key] = t
+		}
+		t.Previous = -1
+		if len(t.Outcomes) > 0 {
+			t.Previous = t.FlakeRate()
+		}
+		t.Outcomes = append(t.Outcomes, r.Outcome)
+		if len(t.Outcomes) > w.size {
+			t.Outcomes = t.Outcomes[len(t.Outcomes)-w.size:]
+		}
+		t.Total++
+		if r.Outcome == runner.Fail {
+			t.LastFailure = "(no message)"
+			if msgs := runner.FailureMessages(r.Output); len(msgs) > 0 {
+				t.LastFailure = msgs[0]
+			}
+		}
+	}
+}
+
+// Tests returns every test seen, sorted by package, then test name
+func (w *Window) Tests() []*TestWindow {
+	tests := make([]*TestWindow, 0, len(w.tests))
+	for _, t := range w.tests {
+		tests = append(tests, t)
+	}
+	sort.Slice(tests, func(i, j int) bool {
+		if tests[i].Package != tests[j].Package {
+			return tests[i].Package < tests[j].Package
+		}
+		return tests[i].Test < tests[j].Test
+	})
+	return tests
+}
+
+// FlakeRate returns the fraction of failures in the window
+func (t *TestWindow) FlakeRate() float64 {
+	if len(t.Outcomes) == 0 {
+		return 0
+	}
+	failed := 0
+	for _, o := range t.Outcomes {
+		if o == runner.Fail {
+			failed++
+		}
+	}
+	return float64(failed) / float64(len(t.Outcomes))
+}
+
+// Render writes a status line and the window of every test that has failed
+// since the watch started, followed by a count of the tests that never did
+func Render(w io.Writer, packages int, status string, window *Window) error {
+	fmt.Fprintf(w, "Watching %d package(s): %s\n\n", packages, status)
+	var passing int
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TEST\tFLAKE RATE\tCHANGE\tRECENT\tRUNS\tLAST FAILURE")
+	for _, t := range window.Tests() {
+		if t.LastFailure == "" {
+			passing++
+			continue
+		}
+		change := "-"
+		if t.Previous >= 0 && t.FlakeRate() != t.Previous {
+			change = fmt.Sprintf("%+.1f%%", (t.FlakeRate()-t.Previous)*100)
+		}
+		fmt.Fprintf(tw, "%s\t%.1f%%\t%s\t%s\t%d\t%s\n", t.Test, t.FlakeRate()*100, change, strip(t.Outcomes), t.Total, t.LastFailure)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n%d test(s) have not failed\n", passing)
+	return nil
+}
+
+// strip draws outcomes oldest first, a check for a pass and a cross for a
+// failure
+func strip(outcomes []runner.Outcome) string {
+	var sb strings.Builder
+	for _, o := range outcomes {
+		if o == runner.Fail {
+			sb.WriteRune('✗')
+		} else {
+			sb.WriteRune('✓')
+		}
+	}
+	return sb.String()
+}
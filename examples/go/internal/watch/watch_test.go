@@ -0,0 +1,158 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/runner"
+)
+
+func TestAffected(t *testing.T) {
+	pkgs := []Package{
+		{ImportPath: "m/a", Dir: "/m/a", Imports: map[string]bool{}},
+		{ImportPath: "m/b", Dir: "/m/b", Imports: map[string]bool{"m/a": true}},
+		{ImportPath: "m/c", Dir: "/m/c", Imports: map[string]bool{"fmt": true}},
+	}
+	if got := strings.Join(Affected(pkgs, []string{"/m/a"}), " "); got != "m/a m/b" {
+		t.Errorf("Expected a change to m/a to affect m/a and m/b, got %s", got)
+	}
+	if got := strings.Join(Affected(pkgs, []string{"/m/c"}), " "); got != "m/c" {
+		t.Errorf("Expected a change to m/c to affect only m/c, got %s", got)
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.go", "package a")
+	write("b.go", "package a")
+	write("testdata/input.txt", "1")
+	before := scan([]string{dir})
+
+	write("a.go", "package a // edited")
+	write(".a.go.swp", "editor state")
+	write("report.json", "written by the tests")
+	write("testdata/input.txt", "22")
+	if err := os.Remove(filepath.Join(dir, "b.go")); err != nil {
+		t.Fatal(err)
+	}
+	got := changedFiles(before, scan([]string{dir}))
+	want := []string{filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go"), filepath.Join(dir, "testdata", "input.txt")}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected changes %v, got %v", want, got)
+	}
+	if got := packageDir([]string{dir}, want[2]); got != dir {
+		t.Errorf("Expected testdata to belong to %s, got %q", dir, got)
+	}
+}
+
+func TestWindowKeepsRecentOutcomes(t *testing.T) {
+	w := NewWindow(3)
+	for _, outcome := range []runner.Outcome{runner.Fail, runner.Pass, runner.Fail, runner.Pass, runner.Skip} {
+		w.Add([]runner.Result{{Package: "p", Test: "TestA", Outcome: outcome, Output: "    a_test.go:3: boom\n"}})
+	}
+	tests := w.Tests()
+	if len(tests) != 1 {
+		t.Fatalf("Expected 1 test, got %d", len(tests))
+	}
+	a := tests[0]
+	if strip(a.Outcomes) != "✓✗✓" || a.Total != 4 || a.LastFailure != "a_test.go:3: boom" {
+		t.Errorf("Unexpected window: %+v", a)
+	}
+	if a.FlakeRate() != 1.0/3 || a.Previous != 2.0/3 {
+		t.Errorf("Expected the rate to drop from 2/3 to 1/3, got %v from %v", a.FlakeRate(), a.Previous)
+	}
+}
+
+func TestRender(t *testing.T) {
+	w := NewWindow(10)
+	w.Add([]runner.Result{
+		{Package: "p", Test: "TestA", Outcome: runner.Fail, Output: "    a_test.go:3: boom\n"},
+		{Package: "p", Test: "TestB", Outcome: runner.Pass},
+	})
+	w.Add([]runner.Result{{Package: "p", Test: "TestA", Outcome: runner.Pass}})
+	var out bytes.Buffer
+	if err := Render(&out, 2, "run 2", w); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Watching 2 package(s): run 2", "TestA  50.0%       -50.0%  ✗✓", "a_test.go:3: boom", "1 test(s) have not failed"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+}
+
+// syncBuffer lets the test read what Run writes from another goroutine
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+func TestRunRerunsAfterChange(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test in a subprocess")
+	}
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/watched\n\ngo 1.22\n")
+	write("w_test.go", "package watched\n\nimport \"testing\"\n\nfunc TestFails(t *testing.T) { t.Error(\"broken\") }\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, Config{Dir: dir, Runs: 1, Interval: 20 * time.Millisecond}, &out) }()
+
+	waitFor := func(s string) {
+		t.Helper()
+		deadline := time.Now().Add(30 * time.Second)
+		for !strings.Contains(out.String(), s) {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %q in:\n%s", s, out.String())
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	waitFor("TestFails  100.0%")
+	// The fix is picked up by the next poll
+	write("w_test.go", "package watched\n\nimport \"testing\"\n\nfunc TestFails(t *testing.T) {}\n")
+	waitFor("after change to w_test.go")
+	waitFor("TestFails  50.0%       -50.0%  ✗✓")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Expected Run to stop cleanly, got %v", err)
+	}
+}
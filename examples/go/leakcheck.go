@@ -0,0 +1,169 @@
+package flaky
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// DefaultLeakTimeout is how long VerifyNoLeaks waits for new goroutines to
+// exit before reporting them
+const DefaultLeakTimeout = time.Second
+
+// LeakOption configures VerifyNoLeaks
+type LeakOption func(*leakConfig)
+
+type leakConfig struct {
+	timeout time.Duration
+	ignore  []string
+}
+
+// WithLeakTimeout changes how long goroutines get to exit after the test
+func WithLeakTimeout(d time.Duration) LeakOption {
+	return func(c *leakConfig) {
+		c.timeout = d
+	}
+}
+
+// IgnoreTopFunction skips goroutines whose innermost frame is fn, such as
+// "internal/poll.runtime_pollWait" for a listener a test leaves to its
+// process
+func IgnoreTopFunction(fn string) LeakOption {
+	return func(c *leakConfig) {
+		c.ignore = append(c.ignore, fn)
+	}
+}
+
+// goroutine is one entry of a runtime.Stack dump
+type goroutine struct {
+	id    string
+	top   string
+	stack string
+}
+
+// VerifyNoLeaks snapshots the running goroutines and, when t finishes, fails
+// it with the stacks of goroutines it started that are still running
+// Goroutines belonging to other tests are ignored, but a parallel test's own
+// goroutines may still be running when t finishes, so call it from tests
+// that do not use t.Parallel
+func VerifyNoLeaks(t testing.TB, opts ...LeakOption) {
+	t.Helper()
+	cfg := leakConfig{timeout: DefaultLeakTimeout}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	before := goroutineIDs(goroutines())
+	// Registered first so a leak is logged and recorded like any failure
+	recordOnFailure(t, SeedFromEnv())
+	t.Cleanup(func() {
+		if leaked := waitForLeaks(before, cfg); len(leaked) > 0 {
+			t.Errorf("%s", describeLeaks(leaked))
+		}
+	})
+}
+
+// waitForLeaks polls until every goroutine started since before has exited
+// or cfg.timeout passes, returning the ones still running
+func waitForLeaks(before map[string]bool, cfg leakConfig) []goroutine {
+	deadline := time.Now().Add(cfg.timeout)
+	wait := time.Millisecond
+	for {
+		leaked := newGoroutines(before, goroutines(), cfg.ignore)
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			return leaked
+		}
+		time.Sleep(wait)
+		if wait < 100*time.Millisecond {
+			wait *= 2
+		}
+	}
+}
+
+// goroutines parses a dump of every goroutine; the first is the caller's
+func goroutines() []goroutine {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return parseGoroutines(string(buf[:n]))
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+func parseGoroutines(dump string) []goroutine {
+	var gs []goroutine
+	for _, block := range strings.Split(strings.TrimSpace(dump), "\n\n") {
+		header, frames, _ := strings.Cut(block, "\n")
+		id, ok := strings.CutPrefix(header, "goroutine ")
+		if !ok {
+			continue
+		}
+		id, _, _ = strings.Cut(id, " ")
+		top, _, _ := strings.Cut(frames, "\n")
+		if i := strings.LastIndex(top, "("); i > 0 {
+			top = top[:i]
+		}
+		gs = append(gs, goroutine{id: id, top: top, stack: block})
+	}
+	return gs
+}
+
+func goroutineIDs(gs []goroutine) map[string]bool {
+	ids := make(map[string]bool, len(gs))
+	for _, g := range gs {
+		ids[g.id] = true
+	}
+	return ids
+}
+
+// newGoroutines returns the goroutines of now that are not in before, leaving
+// out the caller, other tests and ignored top functions
+func newGoroutines(before map[string]bool, now []goroutine, ignore []string) []goroutine {
+	var leaked []goroutine
+	for i, g := range now {
+		switch {
+		case i == 0, before[g.id]:
+		case strings.Contains(g.stack, "testing.tRunner("):
+			// another test's goroutine, such as a parallel test still running
+		case containsString(ignore, g.top):
+		default:
+			leaked = append(leaked, g)
+		}
+	}
+	return leaked
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// describeLeaks formats leaked goroutines, grouped by top function so a loop
+// that leaks many identical goroutines reads as one entry
+func describeLeaks(leaked []goroutine) string {
+	byTop := make(map[string][]goroutine)
+	var tops []string
+	for _, g := range leaked {
+		if byTop[g.top] == nil {
+			tops = append(tops, g.top)
+		}
+		byTop[g.top] = append(byTop[g.top], g)
+	}
+	sort.Strings(tops)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d leaked goroutine(s):", len(leaked))
+	for _, top := range tops {
+		gs := byTop[top]
+		fmt.Fprintf(&b, "\n\n%d x %s\n%s", len(gs), top, gs[0].stack)
+	}
+	return b.String()
+}
@@ -0,0 +1,154 @@
+package flaky
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// fetch starts a worker and waits for its result unless the caller has
+// already given up, as it does when timedOut is true
+// results is unbuffered, so an abandoned worker blocks on its send forever
+func fetch(timedOut bool) (int, bool) {
+	results := make(chan int)
+	go func() { results <- 42 }()
+	if timedOut {
+		return 0, false
+	}
+	return <-results, true
+}
+
+// fetchFixed is fetch with room for the result, so the worker can always
+// finish
+func fetchFixed(timedOut bool) (int, bool) {
+	results := make(chan int, 1)
+	go func() { results <- 42 }()
+	if timedOut {
+		return 0, false
+	}
+	return <-results, true
+}
+
+// TestGoroutineLeak demonstrates a goroutine left blocked after its caller
+// gives up on it
+// This simulates a request that times out while its worker still holds an
+// unbuffered result channel nobody will read
+func TestGoroutineLeak(t *testing.T) {
+	VerifyNoLeaks(t, WithLeakTimeout(50*time.Millisecond))
+	inj := ForTest(t)
+	sc := scenario(t, "GoroutineLeak")
+
+	// Fails in cleanup with the worker's stack when the request timed out
+	if _, ok := fetch(inj.Float64() < sc.FailureRate); !ok {
+		t.Log(sc.Message)
+	}
+}
+
+// TestGoroutineLeakFixed is the reliable variant of TestGoroutineLeak
+// The buffered result channel lets the worker finish after a timeout
+func TestGoroutineLeakFixed(t *testing.T) {
+	VerifyNoLeaks(t)
+	inj := ForTest(t)
+	sc := scenario(t, "GoroutineLeak")
+
+	if _, ok := fetchFixed(inj.Float64() < sc.FailureRate); !ok {
+		t.Log(sc.Message)
+	}
+}
+
+func TestLeakIsRecorded(t *testing.T) {
+	t.Setenv(SeedEnv, "7")
+	tb := &failingTB{finishingTB: finishingTB{TB: t}}
+	// The check comes first in a test, so its cleanup is registered before
+	// the one ForTest adds
+	VerifyNoLeaks(tb, WithLeakTimeout(10*time.Millisecond))
+	ForTest(tb)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() { <-stop }()
+
+	// Other tests fail a TestCheckout of their own
+	dropRecords("TestCheckout")
+	tb.finish()
+	if !tb.failed {
+		t.Fatal("Expected the leak to fail the test")
+	}
+	if n := dropRecords("TestCheckout"); n != 1 {
+		t.Errorf("Expected the leak recorded as a failure once, got %d record(s)", n)
+	}
+}
+
+func TestNewGoroutinesFindsLeak(t *testing.T) {
+	before := goroutineIDs(goroutines())
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() { <-stop }()
+
+	leaked := waitForLeaks(before, leakConfig{timeout: 10 * time.Millisecond})
+	if len(leaked) != 1 {
+		t.Fatalf("Expected 1 leaked goroutine, got %d", len(leaked))
+	}
+	if !strings.Contains(leaked[0].stack, "TestNewGoroutinesFindsLeak") {
+		t.Errorf("Expected the leak's stack to name its creator, got:\n%s", leaked[0].stack)
+	}
+}
+
+func TestWaitForLeaksWaitsForExit(t *testing.T) {
+	before := goroutineIDs(goroutines())
+	go time.Sleep(20 * time.Millisecond)
+
+	if leaked := waitForLeaks(before, leakConfig{timeout: time.Second}); len(leaked) != 0 {
+		t.Errorf("Expected the sleeping goroutine to exit in time, got:\n%s", describeLeaks(leaked))
+	}
+}
+
+func TestIgnoreTopFunction(t *testing.T) {
+	before := goroutineIDs(goroutines())
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() { <-stop }()
+
+	cfg := leakConfig{timeout: 10 * time.Millisecond}
+	leaked := waitForLeaks(before, cfg)
+	if len(leaked) != 1 {
+		t.Fatalf("Expected 1 leaked goroutine, got %d", len(leaked))
+	}
+	IgnoreTopFunction(leaked[0].top)(&cfg)
+	if leaked := waitForLeaks(before, cfg); len(leaked) != 0 {
+		t.Errorf("Expected %s to be ignored, got %d leaks", cfg.ignore[0], len(leaked))
+	}
+}
+
+func TestParseGoroutines(t *testing.T) {
+	dump := `goroutine 7 [running]:
+main.current()
+	/src/main.go:10 +0x1d
+
+goroutine 12 [chan send, 3 minutes]:
+example.com/pkg.worker(0xc000012345)
+	/src/pkg/worker.go:21 +0x45
+created by example.com/pkg.start in goroutine 7
+	/src/pkg/worker.go:18 +0x6a
+`
+	gs := parseGoroutines(dump)
+	if len(gs) != 2 {
+		t.Fatalf("Expected 2 goroutines, got %d", len(gs))
+	}
+	if gs[1].id != "12" || gs[1].top != "example.com/pkg.worker" {
+		t.Errorf("Expected goroutine 12 in example.com/pkg.worker, got %s in %s", gs[1].id, gs[1].top)
+	}
+}
+
+func TestDescribeLeaksGroupsByTopFunction(t *testing.T) {
+	leaked := []goroutine{
+		{id: "3", top: "pkg.b", stack: "goroutine 3 [select]:\npkg.b()"},
+		{id: "1", top: "pkg.a", stack: "goroutine 1 [chan send]:\npkg.a()"},
+		{id: "2", top: "pkg.a", stack: "goroutine 2 [chan send]:\npkg.a()"},
+	}
+	want := "3 leaked goroutine(s):\n\n" +
+		"2 x pkg.a\ngoroutine 1 [chan send]:\npkg.a()\n\n" +
+		"1 x pkg.b\ngoroutine 3 [select]:\npkg.b()"
+	if got := describeLeaks(leaked); got != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,116 @@
+package flaky
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// commaDecimalLanguages write decimal fractions with a comma, as in 1234,50
+var commaDecimalLanguages = map[string]bool{
+	"de": true, "fr": true, "es": true, "it": true, "pt": true, "nl": true,
+	"ru": true, "pl": true, "sv": true, "da": true, "fi": true, "tr": true,
+}
+
+// numericLocale returns the locale numbers are formatted in, from LC_ALL,
+// LC_NUMERIC or LANG in the order POSIX gives them precedence
+func numericLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return "C"
+}
+
+// formatAmount formats an amount with two decimals the way a localized
+// formatter does, with the decimal separator of locale
+func formatAmount(amount float64, locale string) string {
+	s := strconv.FormatFloat(amount, 'f', 2, 64)
+	language, _, _ := strings.Cut(locale, "_")
+	if commaDecimalLanguages[strings.ToLower(language)] {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
+
+// TestLocalizedAmountRoundTrip demonstrates a locale-dependent format
+// An exporter writes an invoice amount with the host's decimal separator and
+// an importer parses it with strconv, so the test passes under LANG=C or
+// en_US and fails on every run under de_DE or fr_FR
+// (reproduce with LANG=de_DE.UTF-8 or flakectl matrix --lang C.UTF-8,de_DE.UTF-8)
+func TestLocalizedAmountRoundTrip(t *testing.T) {
+	locale := numericLocale()
+	exported := formatAmount(1234.5, locale)
+
+	amount, err := strconv.ParseFloat(exported, 64)
+	if err != nil || amount != 1234.5 {
+		t.Errorf("Amount exported under %s as %q did not parse back: %v", locale, exported, err)
+	}
+}
+
+// TestLocalizedAmountRoundTripFixed is the reliable variant of
+// TestLocalizedAmountRoundTrip
+// The amount travels in a locale-independent format, and only display
+// strings are localized
+func TestLocalizedAmountRoundTripFixed(t *testing.T) {
+	exported := strconv.FormatFloat(1234.5, 'f', 2, 64)
+
+	amount, err := strconv.ParseFloat(exported, 64)
+	if err != nil || amount != 1234.5 {
+		t.Errorf("Amount exported as %q did not parse back: %v", exported, err)
+	}
+	if shown := formatAmount(1234.5, "de_DE.UTF-8"); shown != "1234,50" {
+		t.Errorf("Expected the German display 1234,50, got %q", shown)
+	}
+}
+
+// lateEvent happened half an hour before midnight UTC
+var lateEvent = time.Date(2024, 3, 15, 23, 30, 0, 0, time.UTC)
+
+// TestLocalReportDay demonstrates a timezone-dependent format
+// A daily report files an event under the day its local time formats to, so
+// the test passes in UTC and west of it and fails on every run east of it,
+// where the event is already on the next day
+// (reproduce with TZ=Asia/Tokyo or flakectl matrix --tz UTC,Asia/Tokyo)
+func TestLocalReportDay(t *testing.T) {
+	day := lateEvent.Local().Format(time.DateOnly)
+
+	if day != "2024-03-15" {
+		t.Errorf("Event at %s filed under %s, expected 2024-03-15 (local timezone %s)", lateEvent, day, time.Local)
+	}
+}
+
+// TestLocalReportDayFixed is the reliable variant of TestLocalReportDay
+// Reports are bucketed in the timezone they are defined in, here UTC
+func TestLocalReportDayFixed(t *testing.T) {
+	day := lateEvent.UTC().Format(time.DateOnly)
+
+	if day != "2024-03-15" {
+		t.Errorf("Event at %s filed under %s, expected 2024-03-15", lateEvent, day)
+	}
+}
+
+// TestLocaleDependsOnLang verifies the localized amount differs between
+// locales while the fixed format does not
+func TestLocaleDependsOnLang(t *testing.T) {
+	for _, tt := range []struct{ lang, want string }{
+		{"C", "1234.50"},
+		{"en_US.UTF-8", "1234.50"},
+		{"de_DE.UTF-8", "1234,50"},
+		{"fr_FR", "1234,50"},
+	} {
+		t.Setenv("LC_ALL", "")
+		t.Setenv("LC_NUMERIC", "")
+		t.Setenv("LANG", tt.lang)
+		if got := formatAmount(1234.5, numericLocale()); got != tt.want {
+			t.Errorf("LANG=%s: expected %q, got %q", tt.lang, tt.want, got)
+		}
+	}
+	t.Setenv("LC_ALL", "de_DE.UTF-8")
+	if got := numericLocale(); got != "de_DE.UTF-8" {
+		t.Errorf("Expected LC_ALL to override LANG, got %s", got)
+	}
+}
@@ -0,0 +1,65 @@
+package flaky_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/flakylock"
+)
+
+// lockTimeout is how long TestConcurrentAccess waits for the resource lock,
+// shorter than any hold of the other process
+const lockTimeout = 10 * time.Millisecond
+
+// contendedLock returns the path of a lock file that another process takes
+// just before the test asks for it at the scenario's rate, holding it for
+// 20-100ms or a draw from the scenario's latency distribution
+func contendedLock(t *testing.T, sc flaky.Scenario) string {
+	path := filepath.Join(t.TempDir(), "resource.lock")
+	profile := flakylock.Profile{Held: sc.FailureRate, MinHold: 20 * time.Millisecond, MaxHold: 100 * time.Millisecond}
+	if sc.Latency != nil {
+		profile.HoldDistribution = sc.LatencyDistribution()
+	}
+	other := flakylock.New(flaky.ForTest(t), profile, path)
+	t.Cleanup(func() { other.Close() })
+	hold, err := other.Contend()
+	if err != nil {
+		t.Fatalf("Contending for %s: %v", path, err)
+	}
+	flaky.Report(t, sc.Meta(map[string]any{"hold": hold.String()}))
+	return path
+}
+
+// TestConcurrentAccess demonstrates taking a file lock another process shares
+// Fails 50% of the time by default, when the other process holds the lock
+// for longer than the test waits
+func TestConcurrentAccess(t *testing.T) {
+	sc := flaky.ScenarioForTest(t, "ConcurrentAccess")
+	path := contendedLock(t, sc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+	defer cancel()
+	lock, err := flakylock.Acquire(ctx, path)
+	if err != nil {
+		t.Fatalf("%s: %v", sc.Message, err)
+	}
+	lock.Unlock()
+}
+
+// TestConcurrentAccessFixed is the reliable variant of TestConcurrentAccess
+// It waits for the lock with a deadline well past the longest hold
+func TestConcurrentAccessFixed(t *testing.T) {
+	sc := flaky.ScenarioForTest(t, "ConcurrentAccess")
+	path := contendedLock(t, sc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	lock, err := flakylock.Acquire(ctx, path)
+	if err != nil {
+		t.Fatalf("%s: %v", sc.Message, err)
+	}
+	lock.Unlock()
+}
@@ -0,0 +1,18 @@
+package flaky
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	cleanup, err := IsolateTempDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := RecordFailures(m)
+	cleanup()
+	os.Exit(code)
+}
@@ -0,0 +1,93 @@
+package flaky
+
+import (
+	"runtime"
+	"runtime/debug"
+	"testing"
+	"unsafe"
+)
+
+// memoryChunk is the size of each allocation ApplyMemoryPressure retains
+const memoryChunk = 64 << 10
+
+// MemoryOption configures ApplyMemoryPressure
+type MemoryOption func(*memoryConfig)
+
+type memoryConfig struct {
+	gcPercent    int
+	setGCPercent bool
+}
+
+// WithGCPercent sets the garbage collector's target percentage, as GOGC
+// does, for the rest of the test
+// Low values such as 1 collect after every few hundred kilobytes allocated,
+// and every collection has to mark the whole retained heap
+func WithGCPercent(percent int) MemoryOption {
+	return func(c *memoryConfig) {
+		c.gcPercent, c.setGCPercent = percent, true
+	}
+}
+
+// MemoryPressure is memory a test holds on to so the garbage collector has
+// more to do
+type MemoryPressure struct {
+	retained [][]*byte
+	bytes    int
+	startGC  uint32
+}
+
+// ApplyMemoryPressure allocates about bytes of memory and retains it until
+// t finishes, restoring the GC percentage of WithGCPercent then too
+// The memory is made of pointers, so unlike a []byte of the same size every
+// collection has to scan it; each allocation of the test then risks a GC
+// assist paid for in latency
+// It collects once before returning, so no cycle is in progress as the test
+// starts
+func ApplyMemoryPressure(t testing.TB, bytes int, opts ...MemoryOption) *MemoryPressure {
+	t.Helper()
+	var cfg memoryConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	ptrSize := int(unsafe.Sizeof((*byte)(nil)))
+	target := new(byte)
+	p := &MemoryPressure{}
+	for p.bytes < bytes {
+		n := min(memoryChunk, bytes-p.bytes)
+		chunk := make([]*byte, (n+ptrSize-1)/ptrSize)
+		for i := range chunk {
+			chunk[i] = target
+		}
+		p.retained = append(p.retained, chunk)
+		p.bytes += len(chunk) * ptrSize
+	}
+	t.Cleanup(func() {
+		p.retained = nil
+	})
+	if cfg.setGCPercent {
+		old := debug.SetGCPercent(cfg.gcPercent)
+		t.Cleanup(func() { debug.SetGCPercent(old) })
+	}
+
+	runtime.GC()
+	p.startGC = numGC()
+	return p
+}
+
+// Retained returns how many bytes the pressure holds on to
+func (p *MemoryPressure) Retained() int {
+	return p.bytes
+}
+
+// GCCycles returns how many collections finished since ApplyMemoryPressure
+// returned
+func (p *MemoryPressure) GCCycles() int {
+	return int(numGC() - p.startGC)
+}
+
+func numGC() uint32 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.NumGC
+}
@@ -0,0 +1,129 @@
+package flaky
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+	"testing"
+	"time"
+)
+
+// memoryPressureEnv opts in to TestUnderMemoryPressure, whose failures come
+// from real garbage collection rather than the seed alone
+const memoryPressureEnv = "FLAKY_MEMORY_PRESSURE"
+
+// node is one entry of the index buildIndex returns
+type node struct {
+	key  *string
+	next *node
+}
+
+// indexSize is how many entries a request indexes
+const indexSize = 200_000
+
+// buildIndex allocates every entry of a request's index separately
+func buildIndex() *node {
+	var head *node
+	for i := 0; i < indexSize; i++ {
+		key := "k"
+		head = &node{key: &key, next: head}
+	}
+	return head
+}
+
+// buildIndexInto fills preallocated entries, so the request allocates
+// nothing the collector could make it pay for
+func buildIndexInto(nodes []node, key *string) *node {
+	var head *node
+	for i := range nodes {
+		nodes[i] = node{key: key, next: head}
+		head = &nodes[i]
+	}
+	return head
+}
+
+// applyPressure retains 128 MiB at GOGC=1 on the runs the seed picks
+func applyPressure(t *testing.T, sc Scenario) {
+	t.Helper()
+	if os.Getenv(memoryPressureEnv) == "" {
+		t.Skipf("set %s=1 to run under real GC pressure", memoryPressureEnv)
+	}
+	if ForTest(t).Float64() < sc.FailureRate {
+		p := ApplyMemoryPressure(t, 128<<20, WithGCPercent(1))
+		t.Cleanup(func() { t.Logf("%d GC cycles under pressure", p.GCCycles()) })
+	}
+}
+
+// TestUnderMemoryPressure demonstrates a latency budget that only holds
+// while the garbage collector has little to do
+// This simulates a request handler timed against an SLO in a process whose
+// heap is large and collected aggressively
+// Set FLAKY_MEMORY_PRESSURE=1 to run it; it then fails on the ~20% of seeds
+// that apply pressure, since each of its allocations is charged GC assists
+func TestUnderMemoryPressure(t *testing.T) {
+	sc := scenario(t, "MemoryPressure")
+	applyPressure(t, sc)
+
+	start := time.Now()
+	index := buildIndex()
+	if elapsed := time.Since(start); elapsed > time.Duration(sc.Timeout) {
+		t.Errorf("%s: took %v, budget %v", sc.Message, elapsed, time.Duration(sc.Timeout))
+	}
+	runtime.KeepAlive(index)
+}
+
+// TestUnderMemoryPressureFixed is the reliable variant of
+// TestUnderMemoryPressure
+// The entries are allocated before the timed section, which then allocates
+// nothing and triggers no collection
+func TestUnderMemoryPressureFixed(t *testing.T) {
+	sc := scenario(t, "MemoryPressure")
+	nodes := make([]node, indexSize)
+	key := "k"
+	applyPressure(t, sc)
+
+	start := time.Now()
+	index := buildIndexInto(nodes, &key)
+	if elapsed := time.Since(start); elapsed > time.Duration(sc.Timeout) {
+		t.Errorf("%s: took %v, budget %v", sc.Message, elapsed, time.Duration(sc.Timeout))
+	}
+	runtime.KeepAlive(index)
+}
+
+func TestApplyMemoryPressureRetains(t *testing.T) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	p := ApplyMemoryPressure(t, 4<<20+1)
+	runtime.ReadMemStats(&after)
+
+	if p.Retained() < 4<<20+1 || p.Retained() > 4<<20+8 {
+		t.Errorf("Expected 4 MiB and a pointer retained, got %d bytes", p.Retained())
+	}
+	// Other garbage is collected along the way, so allow some slack
+	if grown := int64(after.HeapAlloc) - int64(before.HeapAlloc); grown < 7<<19 {
+		t.Errorf("Expected the live heap to grow by about 4 MiB after a collection, got %d bytes", grown)
+	}
+	if n := p.GCCycles(); n != 0 {
+		t.Errorf("Expected no cycles counted yet, got %d", n)
+	}
+	runtime.GC()
+	if n := p.GCCycles(); n < 1 {
+		t.Errorf("Expected a forced collection to be counted, got %d", n)
+	}
+}
+
+func TestWithGCPercentRestores(t *testing.T) {
+	original := debug.SetGCPercent(100)
+	defer debug.SetGCPercent(original)
+
+	t.Run("pressure", func(t *testing.T) {
+		ApplyMemoryPressure(t, memoryChunk, WithGCPercent(5))
+		if got := debug.SetGCPercent(5); got != 5 {
+			t.Errorf("Expected GC percent 5 during the test, got %d", got)
+		}
+	})
+	if got := debug.SetGCPercent(100); got != 100 {
+		t.Errorf("Expected GC percent 100 restored after the test, got %d", got)
+	}
+}
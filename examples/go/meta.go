@@ -0,0 +1,66 @@
+package flaky
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/internal/protocol"
+)
+
+// MetaLogPrefix marks the structured line Report logs when a test fails
+const MetaLogPrefix = protocol.MetaLogPrefix
+
+// FailureMeta describes the injected conditions behind a failing test, so a
+// failure can be traced to them and not just to its error string
+type FailureMeta = protocol.FailureMeta
+
+// Meta returns FailureMeta naming the scenario with its settings, plus the
+// run's drawn values from params
+func (s Scenario) Meta(params map[string]any) FailureMeta {
+	all := map[string]any{"failure_rate": s.FailureRate}
+	if s.Latency != nil {
+		if d, err := s.Latency.Distribution(); err == nil && s.Latency.Type != "" {
+			all["latency"] = d.String()
+		} else {
+			all["latency_min"], all["latency_max"] = time.Duration(s.Latency.Min).String(), time.Duration(s.Latency.Max).String()
+		}
+	}
+	if s.Timeout != 0 {
+		all["timeout"] = time.Duration(s.Timeout).String()
+	}
+	if s.Injector != "" {
+		all["injector"] = s.Injector
+		if f, ok := LookupInjector(s.Injector); ok {
+			all["injector_description"] = f.Describe()
+		}
+	}
+	for k, v := range params {
+		all[k] = v
+	}
+	return FailureMeta{Scenario: s.Name, Params: all}
+}
+
+// Report attaches meta to t and, if t has failed by the time it finishes,
+// logs it as a MetaLogPrefix line of JSON for flakectl to collect
+// Test, Seed, TestSeed and Time are filled in; call Report once the
+// parameters are drawn, whether or not the test goes on to fail
+func Report(t testing.TB, meta FailureMeta) {
+	t.Helper()
+	suiteSeed := SeedFromEnv()
+	meta.Test, meta.Seed, meta.TestSeed = t.Name(), suiteSeed, SeedFor(suiteSeed, t.Name())
+	t.Cleanup(func() {
+		if !t.Failed() {
+			return
+		}
+		meta.Time = time.Now().UTC()
+		data, _ := json.Marshal(meta)
+		t.Log(MetaLogPrefix + string(data))
+	})
+}
+
+// ParseMetaLine returns the FailureMeta of a line of test output Report
+// logged, such as "    meta.go:58: flaky-meta: {...}"
+func ParseMetaLine(line string) (FailureMeta, bool) {
+	return protocol.ParseMetaLine(line)
+}
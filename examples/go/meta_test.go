@@ -0,0 +1,57 @@
+package flaky
+
+import (
+	"fmt"
+	"testing"
+)
+
+// finishingTB records logs and runs cleanups when finish is called, as a
+// test ending would
+type finishingTB struct {
+	testing.TB
+	failed   bool
+	logs     []string
+	cleanups []func()
+}
+
+func (f *finishingTB) Helper()           {}
+func (f *finishingTB) Name() string      { return "TestCheckout" }
+func (f *finishingTB) Failed() bool      { return f.failed }
+func (f *finishingTB) Log(args ...any)   { f.logs = append(f.logs, fmt.Sprint(args...)) }
+func (f *finishingTB) Cleanup(fn func()) { f.cleanups = append(f.cleanups, fn) }
+
+func (f *finishingTB) finish() {
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+}
+
+func TestReportLogsOnlyOnFailure(t *testing.T) {
+	t.Setenv(SeedEnv, "7")
+	sc := Scenario{Name: "DiskFull", FailureRate: 0.2}
+
+	passing := &finishingTB{TB: t}
+	Report(passing, sc.Meta(map[string]any{"fault": "no-space"}))
+	passing.finish()
+	if len(passing.logs) != 0 {
+		t.Errorf("Expected nothing logged for a passing test, got %q", passing.logs)
+	}
+
+	failing := &finishingTB{TB: t}
+	Report(failing, sc.Meta(map[string]any{"fault": "no-space"}))
+	failing.failed = true
+	failing.finish()
+	if len(failing.logs) != 1 {
+		t.Fatalf("Expected one metadata line, got %q", failing.logs)
+	}
+	meta, ok := ParseMetaLine("    meta.go:58: " + failing.logs[0])
+	if !ok {
+		t.Fatalf("Expected a parseable line, got %q", failing.logs[0])
+	}
+	if meta.Test != "TestCheckout" || meta.Seed != 7 || meta.TestSeed != SeedFor(7, "TestCheckout") || meta.Time.IsZero() {
+		t.Errorf("Expected the test, seeds and time filled in, got %+v", meta)
+	}
+	if meta.Scenario != "DiskFull" || meta.Params["failure_rate"] != 0.2 || meta.Params["fault"] != "no-space" {
+		t.Errorf("Expected the scenario settings and drawn fault, got %+v", meta)
+	}
+}
@@ -0,0 +1,125 @@
+package flaky
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/example/flaky-test-example/internal/protocol"
+)
+
+// SetEnv overrides scenario fields on top of the config file, preset and
+// selection, as a comma-separated list of overrides such as
+// "network.failure_rate=0.2,TimingDependent.latency.max=8ms"
+const SetEnv = protocol.SetEnv
+
+// ErrNoTarget is wrapped by Set's error for a target that names no scenario
+// or class of the registry
+var ErrNoTarget = errors.New("no scenario or class")
+
+// Override sets one field of the scenarios a target names
+type Override struct {
+	// Target is a scenario name, or a class naming every scenario of it
+	Target string
+	// Field is a config key, dotted for nested ones, such as failure_rate,
+	// timeout or latency.max
+	Field string
+	// Value is the field's value as written in a YAML config
+	Value string
+}
+
+// String returns the override as ParseOverride reads it
+func (o Override) String() string {
+	return o.Target + "." + o.Field + "=" + o.Value
+}
+
+// ParseOverride parses target.field=value
+func ParseOverride(s string) (Override, error) {
+	key, value, ok := strings.Cut(strings.TrimSpace(s), "=")
+	target, field, dotted := strings.Cut(key, ".")
+	if !ok || !dotted || target == "" || field == "" || value == "" {
+		return Override{}, fmt.Errorf("invalid override %q, want target.field=value", s)
+	}
+	return Override{Target: target, Field: field, Value: value}, nil
+}
+
+// ParseOverrides parses a comma-separated list of overrides, as SetEnv holds
+func ParseOverrides(s string) ([]Override, error) {
+	var overrides []Override
+	for _, part := range strings.Split(s, ",") {
+		o, err := ParseOverride(part)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, nil
+}
+
+// Set applies overrides in order to every scenario their target names, as a
+// config file entry with just that field would
+// A target that names no scenario or class, and a field that is not a
+// config key, are errors
+func (r *Registry) Set(overrides ...Override) error {
+	for _, o := range overrides {
+		names := r.targets(o.Target)
+		if len(names) == 0 {
+			return fmt.Errorf("override %s: %w %s", o, ErrNoTarget, o.Target)
+		}
+		var entries []func(*Scenario) error
+		for _, name := range names {
+			data, err := overrideYAML(name, o)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, func(s *Scenario) error {
+				dec := yaml.NewDecoder(bytes.NewReader(data))
+				dec.KnownFields(true)
+				return dec.Decode(s)
+			})
+		}
+		if err := r.mergeEntries(entries); err != nil {
+			return fmt.Errorf("override %s: %w", o, err)
+		}
+	}
+	return nil
+}
+
+// targets returns the scenario target names, or else the scenarios of the
+// class it names, sorted
+func (r *Registry) targets(target string) []string {
+	if _, ok := r.scenarios[target]; ok {
+		return []string{target}
+	}
+	var names []string
+	for name, s := range r.scenarios {
+		if s.Class != "" && strings.EqualFold(s.Class, target) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// overrideYAML renders the override as a config entry for the named
+// scenario, with the value left for YAML to resolve, so 0.2 is a number
+// and 8ms a duration
+func overrideYAML(name string, o Override) ([]byte, error) {
+	node := &yaml.Node{Kind: yaml.ScalarNode, Value: o.Value}
+	keys := strings.Split(o.Field, ".")
+	for i := len(keys) - 1; i >= 0; i-- {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: keys[i]}
+		content := []*yaml.Node{key, node}
+		if i == 0 {
+			nameKey := &yaml.Node{Kind: yaml.ScalarNode, Value: "name"}
+			nameValue := &yaml.Node{Kind: yaml.ScalarNode, Value: name, Style: yaml.DoubleQuotedStyle}
+			content = append([]*yaml.Node{nameKey, nameValue}, content...)
+		}
+		node = &yaml.Node{Kind: yaml.MappingNode, Content: content}
+	}
+	return yaml.Marshal(node)
+}
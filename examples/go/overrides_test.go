@@ -0,0 +1,89 @@
+package flaky
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseOverrides(t *testing.T) {
+	got, err := ParseOverrides("network.failure_rate=0.2, TimingDependent.latency.max=8ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Override{
+		{Target: "network", Field: "failure_rate", Value: "0.2"},
+		{Target: "TimingDependent", Field: "latency.max", Value: "8ms"},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if got[1].String() != "TimingDependent.latency.max=8ms" {
+		t.Errorf("Expected the override written back, got %s", got[1])
+	}
+	for _, s := range []string{"", "failure_rate=0.2", "network.failure_rate", "network.=0.2", "network.failure_rate="} {
+		if _, err := ParseOverrides(s); err == nil {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestRegistrySet(t *testing.T) {
+	r := DefaultScenarios()
+	err := r.Set(
+		Override{Target: "Network", Field: "failure_rate", Value: "0.35"},
+		Override{Target: "TimingDependent", Field: "latency.max", Value: "8ms"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range r.Names() {
+		s, _ := r.Get(name)
+		base, _ := DefaultScenarios().Get(name)
+		if s.Class == "network" && s.FailureRate != 0.35 {
+			t.Errorf("%s: expected the class override 0.35, got %v", name, s.FailureRate)
+		}
+		if s.Class != "network" && s.FailureRate != base.FailureRate {
+			t.Errorf("%s: expected %v left alone, got %v", name, base.FailureRate, s.FailureRate)
+		}
+	}
+	s, _ := r.Get("TimingDependent")
+	base, _ := DefaultScenarios().Get("TimingDependent")
+	if s.Latency.Max != Duration(8*time.Millisecond) || s.Latency.Min != base.Latency.Min {
+		t.Errorf("Expected only the latency max set to 8ms, got %+v", s.Latency)
+	}
+	if base.Latency.Max == Duration(8*time.Millisecond) {
+		t.Error("Expected the default latency left alone")
+	}
+
+	if err := DefaultScenarios().Set(Override{Target: "NoSuchScenario", Field: "failure_rate", Value: "0.1"}); !errors.Is(err, ErrNoTarget) {
+		t.Errorf("Expected ErrNoTarget, got %v", err)
+	}
+	for _, o := range []Override{
+		{Target: "NoSuchScenario", Field: "failure_rate", Value: "0.1"},
+		{Target: "RandomFailure", Field: "no_such_field", Value: "1"},
+		{Target: "RandomFailure", Field: "failure_rate", Value: "1.5"},
+		{Target: "RandomFailure", Field: "timeout", Value: "soon"},
+	} {
+		if err := DefaultScenarios().Set(o); err == nil {
+			t.Errorf("Expected %s to be rejected", o)
+		}
+	}
+}
+
+func TestScenariosFromEnvSet(t *testing.T) {
+	t.Setenv(ConfigEnv, writeConfig(t, "custom.yaml", "scenarios:\n  - name: RandomFailure\n    failure_rate: 0.9\n"))
+	t.Setenv(SetEnv, "RandomFailure.failure_rate=0.4")
+	r, err := ScenariosFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := r.Get("RandomFailure"); s.FailureRate != 0.4 {
+		t.Errorf("Expected FLAKY_SET to override the config file, got %v", s.FailureRate)
+	}
+
+	t.Setenv(SetEnv, "RandomFailure.failure_rate")
+	if _, err := ScenariosFromEnv(); err == nil {
+		t.Error("Expected an invalid FLAKY_SET to be rejected")
+	}
+}
@@ -0,0 +1,158 @@
+package flaky_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+)
+
+// parallelCases are the regions the parallel scenarios run a case for
+var parallelCases = []string{"us", "eu", "ap", "sa"}
+
+// slowRequest is how long a case that draws the slow path holds the shared
+// resource, long enough for the cases running beside it to get in between
+const slowRequest = 20 * time.Millisecond
+
+// regionConfig is a client setting that every test of a package reads
+type regionConfig struct {
+	mu     sync.Mutex
+	region string
+}
+
+func (c *regionConfig) Set(region string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.region = region
+}
+
+func (c *regionConfig) Get() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.region
+}
+
+// sharedRegion is the package-level config TestParallelSharedConfig's cases
+// all point at
+var sharedRegion regionConfig
+
+// requestCount counts the requests a client sent since its last Reset
+type requestCount struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (c *requestCount) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n = 0
+}
+
+func (c *requestCount) Inc() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n++
+}
+
+func (c *requestCount) Value() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+// sharedRequests is the package-level counter TestParallelSharedCounter's
+// cases all reset and count on
+var sharedRequests requestCount
+
+// slowPath reports whether a case draws the slow path, from its own seed
+func slowPath(t *testing.T, sc flaky.Scenario) bool {
+	return flaky.ForTest(t).Float64() < sc.FailureRate
+}
+
+// TestParallelSharedConfig demonstrates t.Parallel cases that set a
+// package-level config and read it back after a request
+// Never fails at -parallel 1; with more it fails when a case on the slow
+// path (50% by default) is overwritten by one running beside it
+func TestParallelSharedConfig(t *testing.T) {
+	for _, region := range parallelCases {
+		t.Run(region, func(t *testing.T) {
+			t.Parallel()
+			sc := flaky.ScenarioForTest(t, "ParallelSharedConfig")
+			sharedRegion.Set(region)
+			if slowPath(t, sc) {
+				time.Sleep(slowRequest)
+			}
+			if got := sharedRegion.Get(); got != region {
+				t.Errorf("%s: expected region %s, got %s", sc.Message, region, got)
+			}
+		})
+	}
+}
+
+// TestParallelSharedConfigFixed is the reliable variant of
+// TestParallelSharedConfig
+// Every case owns its config, so cases can run in parallel safely
+func TestParallelSharedConfigFixed(t *testing.T) {
+	for _, region := range parallelCases {
+		t.Run(region, func(t *testing.T) {
+			t.Parallel()
+			sc := flaky.ScenarioForTest(t, "ParallelSharedConfig")
+			var cfg regionConfig
+			cfg.Set(region)
+			if slowPath(t, sc) {
+				time.Sleep(slowRequest)
+			}
+			if got := cfg.Get(); got != region {
+				t.Errorf("%s: expected region %s, got %s", sc.Message, region, got)
+			}
+		})
+	}
+}
+
+// TestParallelSharedCounter demonstrates t.Parallel cases that reset a
+// package-level request counter and check how many requests they sent
+// Never fails at -parallel 1; with more it fails when the requests of a
+// case on the slow path (50% by default) interleave with another case's
+func TestParallelSharedCounter(t *testing.T) {
+	for _, region := range parallelCases {
+		t.Run(region, func(t *testing.T) {
+			t.Parallel()
+			sc := flaky.ScenarioForTest(t, "ParallelSharedCounter")
+			sharedRequests.Reset()
+			slow := slowPath(t, sc)
+			for i := 0; i < 3; i++ {
+				sharedRequests.Inc()
+				if slow {
+					time.Sleep(slowRequest / 3)
+				}
+			}
+			if got := sharedRequests.Value(); got != 3 {
+				t.Errorf("%s: expected 3 requests, got %d", sc.Message, got)
+			}
+		})
+	}
+}
+
+// TestParallelSharedCounterFixed is the reliable variant of
+// TestParallelSharedCounter
+// Every case counts on a counter of its own
+func TestParallelSharedCounterFixed(t *testing.T) {
+	for _, region := range parallelCases {
+		t.Run(region, func(t *testing.T) {
+			t.Parallel()
+			sc := flaky.ScenarioForTest(t, "ParallelSharedCounter")
+			var requests requestCount
+			slow := slowPath(t, sc)
+			for i := 0; i < 3; i++ {
+				requests.Inc()
+				if slow {
+					time.Sleep(slowRequest / 3)
+				}
+			}
+			if got := requests.Value(); got != 3 {
+				t.Errorf("%s: expected 3 requests, got %d", sc.Message, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package flaky_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/flakyio"
+)
+
+// frame encodes payload as a message of a length-prefixed protocol: two
+// big-endian length bytes, then the payload
+func frame(payload string) []byte {
+	buf := binary.BigEndian.AppendUint16(nil, uint16(len(payload)))
+	return append(buf, payload...)
+}
+
+// readFrameOnce parses a frame from a single Read, as if one Read returned
+// one message
+func readFrameOnce(r io.Reader) (string, error) {
+	buf := make([]byte, 512)
+	n, err := r.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	size := int(binary.BigEndian.Uint16(buf[:2]))
+	return string(buf[2:min(n, 2+size)]), nil
+}
+
+// readFrameFull parses a frame with io.ReadFull, reading until the header
+// and the payload are complete
+func readFrameFull(r io.Reader) (string, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return "", err
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+// checkFrame sends a frame over a connection that returns short reads at the
+// scenario's rate and checks the parser gets the whole payload back
+func checkFrame(t *testing.T, read func(io.Reader) (string, error)) {
+	sc := flaky.ScenarioForTest(t, "PartialRead")
+	want := `{"order":1042,"items":["coffee","bagel"],"total":7.25}`
+	conn := flakyio.NewReader(flaky.ForTest(t), flakyio.Profile{ShortRead: sc.FailureRate}, bytes.NewReader(frame(want)))
+	got, err := read(conn)
+	flaky.Report(t, sc.Meta(map[string]any{"faults": conn.Counts()}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("%s: expected %q, got %q", sc.Message, want, got)
+	}
+}
+
+// TestPartialRead demonstrates a parser that assumes one Read returns a whole
+// message
+// Fails 20% of the time by default, with the frame cut short; a local
+// buffer never returns a short read, so it only shows up on real
+// connections
+func TestPartialRead(t *testing.T) {
+	checkFrame(t, readFrameOnce)
+}
+
+// TestPartialReadFixed is the reliable variant of TestPartialRead
+// io.ReadFull keeps reading until the frame is complete
+func TestPartialReadFixed(t *testing.T) {
+	checkFrame(t, readFrameFull)
+}
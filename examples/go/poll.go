@@ -0,0 +1,138 @@
+package flaky
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/clock"
+)
+
+// PollLogPrefix marks the structured line Eventually and Consistently log
+// with how many polls they made
+const PollLogPrefix = "flaky-poll: "
+
+// PollResult describes one Eventually or Consistently assertion
+type PollResult struct {
+	Test    string        `json:"test"`
+	Kind    string        `json:"kind"` // "eventually" or "consistently"
+	OK      bool          `json:"ok"`
+	Polls   int           `json:"polls"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// PollOption configures Eventually and Consistently
+type PollOption func(*pollConfig)
+
+type pollConfig struct {
+	clk        clock.Clock
+	multiplier float64
+	max        time.Duration
+}
+
+// WithPollClock waits on c between polls and measures the timeout on it, so
+// a clock.FakeClock makes the wait instant and the poll count deterministic
+func WithPollClock(c clock.Clock) PollOption {
+	return func(cfg *pollConfig) {
+		cfg.clk = c
+	}
+}
+
+// WithPollBackoff multiplies the interval by multiplier after every poll, up
+// to max when max is positive
+// There is no jitter, so the poll times only depend on the arguments
+func WithPollBackoff(multiplier float64, max time.Duration) PollOption {
+	return func(cfg *pollConfig) {
+		cfg.multiplier, cfg.max = multiplier, max
+	}
+}
+
+// next returns the interval after interval
+func (cfg *pollConfig) next(interval time.Duration) time.Duration {
+	if cfg.multiplier <= 1 {
+		return interval
+	}
+	next := time.Duration(float64(interval) * cfg.multiplier)
+	if cfg.max > 0 && next > cfg.max {
+		return cfg.max
+	}
+	return next
+}
+
+func newPollConfig(interval time.Duration, opts []PollOption) pollConfig {
+	if interval <= 0 {
+		panic("flaky: polling needs a positive interval")
+	}
+	cfg := pollConfig{clk: clock.Real()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// Eventually polls condition every interval until it returns true and fails
+// t if it has not within timeout
+// The first poll is immediate and the last one falls exactly on the timeout,
+// so a condition that becomes true at the deadline still passes
+// It panics if interval is not positive
+func Eventually(t testing.TB, condition func() bool, timeout, interval time.Duration, opts ...PollOption) PollResult {
+	t.Helper()
+	cfg := newPollConfig(interval, opts)
+	result := PollResult{Test: t.Name(), Kind: "eventually"}
+	start := cfg.clk.Now()
+	for {
+		result.Polls++
+		if condition() {
+			result.OK = true
+			break
+		}
+		remaining := timeout - cfg.clk.Since(start)
+		if remaining <= 0 {
+			break
+		}
+		cfg.clk.Sleep(min(interval, remaining))
+		interval = cfg.next(interval)
+	}
+	result.Elapsed = cfg.clk.Since(start)
+	logPoll(t, result)
+	if !result.OK {
+		t.Errorf("Condition not met within %v (%d polls)", timeout, result.Polls)
+	}
+	return result
+}
+
+// Consistently polls condition every interval for duration and fails t the
+// first time it returns false
+// The first poll is immediate and the last one falls exactly on duration
+// It panics if interval is not positive
+func Consistently(t testing.TB, condition func() bool, duration, interval time.Duration, opts ...PollOption) PollResult {
+	t.Helper()
+	cfg := newPollConfig(interval, opts)
+	result := PollResult{Test: t.Name(), Kind: "consistently", OK: true}
+	start := cfg.clk.Now()
+	for {
+		result.Polls++
+		if !condition() {
+			result.OK = false
+			break
+		}
+		remaining := duration - cfg.clk.Since(start)
+		if remaining <= 0 {
+			break
+		}
+		cfg.clk.Sleep(min(interval, remaining))
+		interval = cfg.next(interval)
+	}
+	result.Elapsed = cfg.clk.Since(start)
+	logPoll(t, result)
+	if !result.OK {
+		t.Errorf("Condition became false after %v (poll %d)", result.Elapsed, result.Polls)
+	}
+	return result
+}
+
+func logPoll(t testing.TB, result PollResult) {
+	t.Helper()
+	data, _ := json.Marshal(result)
+	t.Log(PollLogPrefix + string(data))
+}
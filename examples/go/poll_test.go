@@ -0,0 +1,96 @@
+package flaky
+
+import (
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/clock"
+)
+
+func TestEventuallyCountsPolls(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	ready := clk.Now().Add(35 * time.Millisecond)
+
+	res := Eventually(t, func() bool { return !clk.Now().Before(ready) }, time.Second, 10*time.Millisecond, WithPollClock(clk))
+	if !res.OK || res.Polls != 5 || res.Elapsed != 40*time.Millisecond {
+		t.Errorf("Expected success on poll 5 after 40ms, got %+v", res)
+	}
+}
+
+func TestEventuallyFailsAtTimeout(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	rec := &recordingTB{TB: t}
+
+	res := Eventually(rec, func() bool { return false }, 25*time.Millisecond, 10*time.Millisecond, WithPollClock(clk))
+	// Polls at 0, 10ms, 20ms and the deadline
+	if res.OK || res.Polls != 4 || res.Elapsed != 25*time.Millisecond {
+		t.Errorf("Expected 4 polls ending at 25ms, got %+v", res)
+	}
+	if len(rec.failures) != 1 || rec.failures[0] != "Condition not met within 25ms (4 polls)" {
+		t.Errorf("Expected one timeout failure, got %v", rec.failures)
+	}
+}
+
+func TestEventuallyPassesAtDeadline(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	deadline := clk.Now().Add(25 * time.Millisecond)
+	rec := &recordingTB{TB: t}
+
+	res := Eventually(rec, func() bool { return !clk.Now().Before(deadline) }, 25*time.Millisecond, 10*time.Millisecond, WithPollClock(clk))
+	if !res.OK || len(rec.failures) != 0 {
+		t.Errorf("Expected the poll on the deadline to pass, got %+v and %v", res, rec.failures)
+	}
+}
+
+func TestEventuallyBackoff(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	var polls []time.Duration
+	start := clk.Now()
+	rec := &recordingTB{TB: t}
+
+	Eventually(rec, func() bool {
+		polls = append(polls, clk.Since(start))
+		return false
+	}, 100*time.Millisecond, 10*time.Millisecond, WithPollClock(clk), WithPollBackoff(2, 30*time.Millisecond))
+	want := []time.Duration{0, 10, 30, 60, 90, 100}
+	if len(polls) != len(want) {
+		t.Fatalf("Expected polls at %v ms, got %v", want, polls)
+	}
+	for i, w := range want {
+		if polls[i] != w*time.Millisecond {
+			t.Errorf("Poll %d: expected %v, got %v", i+1, w*time.Millisecond, polls[i])
+		}
+	}
+}
+
+func TestConsistentlyHolds(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+
+	res := Consistently(t, func() bool { return true }, 50*time.Millisecond, 10*time.Millisecond, WithPollClock(clk))
+	if !res.OK || res.Polls != 6 || res.Elapsed != 50*time.Millisecond {
+		t.Errorf("Expected 6 passing polls over 50ms, got %+v", res)
+	}
+}
+
+func TestConsistentlyFailsOnFirstFalse(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	broken := clk.Now().Add(15 * time.Millisecond)
+	rec := &recordingTB{TB: t}
+
+	res := Consistently(rec, func() bool { return clk.Now().Before(broken) }, time.Second, 10*time.Millisecond, WithPollClock(clk))
+	if res.OK || res.Polls != 3 {
+		t.Errorf("Expected failure on poll 3, got %+v", res)
+	}
+	if len(rec.failures) != 1 || rec.failures[0] != "Condition became false after 20ms (poll 3)" {
+		t.Errorf("Expected one failure, got %v", rec.failures)
+	}
+}
+
+func TestPollRejectsNonPositiveInterval(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a zero interval to panic")
+		}
+	}()
+	Eventually(t, func() bool { return true }, time.Second, 0)
+}
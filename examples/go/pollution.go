@@ -0,0 +1,223 @@
+package flaky
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/protocol"
+)
+
+// PollutionEnv, when set to 1, makes ForTest and Rand check every test that
+// calls them with VerifyNoPollution
+const PollutionEnv = protocol.PollutionEnv
+
+// PollutionOption configures VerifyNoPollution
+type PollutionOption func(*pollutionConfig)
+
+type pollutionConfig struct {
+	ignoreEnv  []string
+	ignoreTemp []string
+}
+
+// IgnoreEnv skips changes to the named environment variables
+func IgnoreEnv(names ...string) PollutionOption {
+	return func(c *pollutionConfig) {
+		c.ignoreEnv = append(c.ignoreEnv, names...)
+	}
+}
+
+// IgnoreTempFile skips temp directory entries whose name matches pattern, in
+// filepath.Match syntax, such as "go-build*"
+func IgnoreTempFile(pattern string) PollutionOption {
+	return func(c *pollutionConfig) {
+		c.ignoreTemp = append(c.ignoreTemp, pattern)
+	}
+}
+
+var globals struct {
+	mu   sync.Mutex
+	ptrs map[string]any
+}
+
+// RegisterGlobal adds the variable ptr points to to the package state
+// VerifyNoPollution compares, under name
+// Values are compared as formatted by %#v, so the contents of maps and
+// slices count but pointers are compared by address
+func RegisterGlobal(name string, ptr any) {
+	if v := reflect.ValueOf(ptr); v.Kind() != reflect.Pointer || v.IsNil() {
+		panic(fmt.Sprintf("flaky: RegisterGlobal(%q) needs a non-nil pointer, got %T", name, ptr))
+	}
+	globals.mu.Lock()
+	defer globals.mu.Unlock()
+	if globals.ptrs == nil {
+		globals.ptrs = make(map[string]any)
+	}
+	globals.ptrs[name] = ptr
+}
+
+// stateSnapshot is the shared state of the test binary at one moment
+type stateSnapshot struct {
+	env     map[string]string
+	temp    map[string]bool
+	wd      string
+	globals map[string]string
+}
+
+// VerifyNoPollution snapshots the environment, the entries of the temp
+// directory, the working directory and every RegisterGlobal variable and,
+// when t finishes, fails it with whatever it changed without restoring
+// Call it first in the test so the test's own t.Cleanup, t.Setenv and
+// t.TempDir restores happen before the check
+// This state is shared by the whole test binary, so use it in tests without
+// t.Parallel, and see IsolateTempDir for the temp files of other processes
+func VerifyNoPollution(t testing.TB, opts ...PollutionOption) {
+	t.Helper()
+	var cfg pollutionConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	before := snapshotState()
+	t.Cleanup(func() {
+		if changes := stateChanges(before, snapshotState(), cfg); len(changes) > 0 {
+			t.Errorf("%s", describePollution(changes))
+		}
+	})
+}
+
+// pollutionEnabled reports whether PollutionEnv asks for every test to be
+// checked
+func pollutionEnabled() bool {
+	return os.Getenv(PollutionEnv) == "1"
+}
+
+// IsolateTempDir points the temp directory of the test binary at a new
+// directory of its own, so VerifyNoPollution does not see files that test
+// binaries of other packages, run in parallel by go test ./..., create
+// Call it from TestMain and call the returned function after m.Run to
+// remove the directory
+func IsolateTempDir() (func(), error) {
+	dir, err := os.MkdirTemp("", "flaky-tmp-")
+	if err != nil {
+		return nil, err
+	}
+	env := "TMPDIR"
+	if runtime.GOOS == "windows" {
+		env = "TMP"
+	}
+	old, had := os.LookupEnv(env)
+	os.Setenv(env, dir)
+	return func() {
+		if had {
+			os.Setenv(env, old)
+		} else {
+			os.Unsetenv(env)
+		}
+		os.RemoveAll(dir)
+	}, nil
+}
+
+func snapshotState() stateSnapshot {
+	s := stateSnapshot{env: make(map[string]string), temp: make(map[string]bool), globals: make(map[string]string)}
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		s.env[key] = value
+	}
+	if entries, err := os.ReadDir(os.TempDir()); err == nil {
+		for _, e := range entries {
+			s.temp[e.Name()] = true
+		}
+	}
+	s.wd, _ = os.Getwd()
+	globals.mu.Lock()
+	defer globals.mu.Unlock()
+	for name, ptr := range globals.ptrs {
+		s.globals[name] = fmt.Sprintf("%#v", reflect.ValueOf(ptr).Elem().Interface())
+	}
+	return s
+}
+
+// stateChanges lists the differences between two snapshots, sorted within
+// each kind of state
+func stateChanges(before, after stateSnapshot, cfg pollutionConfig) []string {
+	var changes []string
+	for _, key := range unionKeys(before.env, after.env) {
+		old, hadOld := before.env[key]
+		now, hasNow := after.env[key]
+		if (old == now && hadOld == hasNow) || containsString(cfg.ignoreEnv, key) {
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("env %s: %s -> %s", key, envValue(old, hadOld), envValue(now, hasNow)))
+	}
+
+	var created, removed []string
+	for name := range after.temp {
+		if !before.temp[name] && !matchesAny(cfg.ignoreTemp, name) {
+			created = append(created, name)
+		}
+	}
+	for name := range before.temp {
+		if !after.temp[name] && !matchesAny(cfg.ignoreTemp, name) {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(created)
+	sort.Strings(removed)
+	for _, name := range created {
+		changes = append(changes, "temp file left behind: "+filepath.Join(os.TempDir(), name))
+	}
+	for _, name := range removed {
+		changes = append(changes, "temp file removed: "+filepath.Join(os.TempDir(), name))
+	}
+
+	if before.wd != after.wd {
+		changes = append(changes, fmt.Sprintf("working directory: %s -> %s", before.wd, after.wd))
+	}
+	for _, name := range unionKeys(before.globals, after.globals) {
+		if before.globals[name] != after.globals[name] {
+			changes = append(changes, fmt.Sprintf("global %s: %s -> %s", name, before.globals[name], after.globals[name]))
+		}
+	}
+	return changes
+}
+
+func envValue(value string, set bool) string {
+	if !set {
+		return "unset"
+	}
+	return fmt.Sprintf("%q", value)
+}
+
+func unionKeys(a, b map[string]string) []string {
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// describePollution formats the changes a test left behind
+func describePollution(changes []string) string {
+	return fmt.Sprintf("changed shared state without restoring it:\n  %s", strings.Join(changes, "\n  "))
+}
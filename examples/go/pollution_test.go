@@ -0,0 +1,250 @@
+package flaky
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// regionEnv is read by code that picks a storage region
+const regionEnv = "FLAKY_EXAMPLE_REGION"
+
+// withRegion overrides the region for the duration of fn
+// It restores the old value only when fn returns normally, so an early exit
+// such as a failed upload leaves the override in place
+func withRegion(region string, fn func() bool) {
+	old, had := os.LookupEnv(regionEnv)
+	os.Setenv(regionEnv, region)
+	if !fn() {
+		return
+	}
+	if had {
+		os.Setenv(regionEnv, old)
+	} else {
+		os.Unsetenv(regionEnv)
+	}
+}
+
+// TestEnvLeak demonstrates a test that changes an environment variable and
+// does not always restore it
+// Later tests in the binary would run against the wrong region
+func TestEnvLeak(t *testing.T) {
+	t.Cleanup(func() { os.Unsetenv(regionEnv) }) // after the check, so later tests start clean
+	VerifyNoPollution(t)
+	inj := ForTest(t)
+	sc := scenario(t, "EnvLeak")
+
+	// Fails in cleanup with the variable's change when the upload failed
+	aborted := inj.Float64() < sc.FailureRate
+	withRegion("eu-west-1", func() bool { return !aborted })
+	if aborted {
+		t.Log(sc.Message)
+	}
+}
+
+// TestEnvLeakFixed is the reliable variant of TestEnvLeak
+// t.Setenv restores the variable whatever path the test takes
+func TestEnvLeakFixed(t *testing.T) {
+	VerifyNoPollution(t)
+	inj := ForTest(t)
+	sc := scenario(t, "EnvLeak")
+
+	t.Setenv(regionEnv, "eu-west-1")
+	if inj.Float64() < sc.FailureRate {
+		t.Log(sc.Message)
+	}
+}
+
+// stageUpload writes data to a staging file and removes it once the upload
+// succeeds, leaving it behind when the upload is aborted
+func stageUpload(data string, aborted bool) (string, error) {
+	f, err := os.CreateTemp("", "flaky-upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		return f.Name(), err
+	}
+	if aborted {
+		return f.Name(), nil
+	}
+	return f.Name(), os.Remove(f.Name())
+}
+
+// TestTempFileLeak demonstrates a test whose code leaves a temp file behind
+// on one path
+// Tests that count or clean the temp directory later see the stray file
+func TestTempFileLeak(t *testing.T) {
+	var staged string
+	t.Cleanup(func() { os.Remove(staged) }) // after the check, so the scenario does not litter
+	VerifyNoPollution(t)
+	inj := ForTest(t)
+	sc := scenario(t, "TempFileLeak")
+
+	// Fails in cleanup with the file's path when the upload was aborted
+	aborted := inj.Float64() < sc.FailureRate
+	var err error
+	if staged, err = stageUpload("payload", aborted); err != nil {
+		t.Fatal(err)
+	}
+	if aborted {
+		t.Log(sc.Message)
+	}
+}
+
+// TestTempFileLeakFixed is the reliable variant of TestTempFileLeak
+// Staging under t.TempDir removes the file whatever path the test takes
+func TestTempFileLeakFixed(t *testing.T) {
+	VerifyNoPollution(t)
+	inj := ForTest(t)
+	sc := scenario(t, "TempFileLeak")
+
+	path := filepath.Join(t.TempDir(), "upload")
+	if err := os.WriteFile(path, []byte("payload"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if inj.Float64() < sc.FailureRate {
+		t.Log(sc.Message)
+	}
+}
+
+func TestPollutionCheckedOncePerTest(t *testing.T) {
+	t.Setenv(SeedEnv, "7")
+	t.Setenv(PollutionEnv, "1")
+	tb := &failingTB{finishingTB: finishingTB{TB: t}}
+	ForTest(tb)
+	Rand(tb)
+
+	os.Setenv("FLAKY_TEST_POLLUTED", "1")
+	// Other tests fail a TestCheckout of their own
+	dropRecords("TestCheckout")
+	tb.finish()
+	os.Unsetenv("FLAKY_TEST_POLLUTED")
+	dropRecords("TestCheckout")
+	reported := 0
+	for _, err := range tb.errs {
+		if strings.Contains(err, "FLAKY_TEST_POLLUTED") {
+			reported++
+		}
+	}
+	if reported != 1 {
+		t.Errorf("Expected the change reported once, got %q", tb.errs)
+	}
+}
+
+func TestStateChangesFindsEveryKind(t *testing.T) {
+	counter := map[string]int{"a": 1}
+	RegisterGlobal("pollution test counter", &counter)
+	t.Cleanup(func() {
+		globals.mu.Lock()
+		delete(globals.ptrs, "pollution test counter")
+		globals.mu.Unlock()
+	})
+	t.Setenv("FLAKY_EXAMPLE_CHANGED", "before")
+	t.Setenv("FLAKY_EXAMPLE_REMOVED", "gone")
+	os.Unsetenv("FLAKY_EXAMPLE_ADDED")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	before := snapshotState()
+
+	os.Setenv("FLAKY_EXAMPLE_CHANGED", "after")
+	os.Unsetenv("FLAKY_EXAMPLE_REMOVED")
+	os.Setenv("FLAKY_EXAMPLE_ADDED", "new")
+	defer os.Unsetenv("FLAKY_EXAMPLE_ADDED")
+	f, err := os.CreateTemp("", "flaky-state-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	counter["a"] = 2
+
+	got := stateChanges(before, snapshotState(), pollutionConfig{})
+	want := []string{
+		`env FLAKY_EXAMPLE_ADDED: unset -> "new"`,
+		`env FLAKY_EXAMPLE_CHANGED: "before" -> "after"`,
+		`env FLAKY_EXAMPLE_REMOVED: "gone" -> unset`,
+		"temp file left behind: " + f.Name(),
+		"working directory: " + wd + " -> " + dir,
+		`global pollution test counter: map[string]int{"a":1} -> map[string]int{"a":2}`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestStateChangesIgnores(t *testing.T) {
+	before := snapshotState()
+	t.Setenv("FLAKY_EXAMPLE_IGNORED", "x")
+	f, err := os.CreateTemp("", "flaky-ignored-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	cfg := pollutionConfig{}
+	IgnoreEnv("FLAKY_EXAMPLE_IGNORED")(&cfg)
+	IgnoreTempFile("flaky-ignored-*")(&cfg)
+	if changes := stateChanges(before, snapshotState(), cfg); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v", changes)
+	}
+}
+
+func TestStateChangesNoneWhenRestored(t *testing.T) {
+	before := snapshotState()
+	os.Setenv("FLAKY_EXAMPLE_RESTORED", "x")
+	os.Unsetenv("FLAKY_EXAMPLE_RESTORED")
+	old := defaultCurrency
+	defaultCurrency = "GBP"
+	defaultCurrency = old
+	if changes := stateChanges(before, snapshotState(), pollutionConfig{}); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v", changes)
+	}
+}
+
+func TestRegisterGlobalRejectsNonPointers(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected RegisterGlobal to panic on a non-pointer")
+		}
+	}()
+	RegisterGlobal("value", 1)
+}
+
+func TestIsolateTempDir(t *testing.T) {
+	shared := os.TempDir()
+	cleanup, err := IsolateTempDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	private := os.TempDir()
+	cleanup()
+	if private == shared || filepath.Dir(private) != shared {
+		t.Errorf("Expected a new directory inside %s, got %s", shared, private)
+	}
+	if _, err := os.Stat(private); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed, got %v", private, err)
+	}
+	if os.TempDir() != shared {
+		t.Errorf("Expected the temp dir to be %s again, got %s", shared, os.TempDir())
+	}
+}
+
+func TestDescribePollution(t *testing.T) {
+	got := describePollution([]string{`env A: unset -> "1"`, "working directory: /a -> /b"})
+	want := "changed shared state without restoring it:\n  env A: unset -> \"1\"\n  working directory: /a -> /b"
+	if got != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,76 @@
+package flaky_test
+
+import (
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/clock"
+)
+
+// batchWorker processes 10 jobs of 1ms each and checkpoints the jobs it
+// finished
+type batchWorker struct {
+	clk          *clock.FakeClock
+	done         int
+	checkpointed int
+	// drain checkpoints on SIGTERM; without it a preempted worker exits
+	// without saving its progress
+	drain bool
+}
+
+func (w *batchWorker) run(sigs <-chan os.Signal) {
+	for job := 0; job < 10; job++ {
+		select {
+		case <-sigs:
+			if w.drain {
+				w.checkpointed = w.done
+			}
+			return
+		default:
+		}
+		w.clk.Sleep(time.Millisecond)
+		w.done++
+	}
+	w.checkpointed = w.done
+}
+
+// checkCheckpoint runs w on a host that, at the scenario's rate, sends
+// SIGTERM partway through the batch, and checks no finished job is lost
+func checkCheckpoint(t *testing.T, w *batchWorker) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows cannot send SIGTERM")
+	}
+	sc := flaky.ScenarioForTest(t, "Preemption")
+	inj := flaky.ForTest(t)
+	w.clk = clock.NewFake(time.Now())
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	if inj.Float64() < sc.FailureRate {
+		inj.Preempt(t, syscall.SIGTERM, sc.LatencyDistribution(), flaky.WithPreemptClock(w.clk))
+	}
+
+	w.run(sigs)
+	if w.checkpointed != w.done {
+		t.Errorf("%s: checkpointed %d of %d finished jobs", sc.Message, w.checkpointed, w.done)
+	}
+}
+
+// TestPreemption demonstrates a batch worker on a spot or serverless host
+// that only saves its progress at the end of the batch
+// Fails 20% of the time by default, when SIGTERM arrives mid-batch
+func TestPreemption(t *testing.T) {
+	checkCheckpoint(t, &batchWorker{})
+}
+
+// TestPreemptionFixed is the reliable variant of TestPreemption
+// The worker handles SIGTERM by checkpointing the jobs it finished
+func TestPreemptionFixed(t *testing.T) {
+	checkCheckpoint(t, &batchWorker{drain: true})
+}
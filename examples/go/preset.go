@@ -0,0 +1,113 @@
+package flaky
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/example/flaky-test-example/internal/protocol"
+)
+
+// ProfileEnv names the preset applied to the scenarios, overriding
+// the profile of the config file
+const ProfileEnv = protocol.ProfileEnv
+
+// Preset is a named chaos profile: it scales every scenario's failure
+// rates and latencies and picks the scenario classes that run at all
+// Entries of the config file apply on top of it, so a rate set there is
+// used as written
+type Preset struct {
+	Name string
+	// RateScale multiplies FailureRate, the rates of Cases, the rate a
+	// Drift moves to and an Incident's failure rate, capped at 1
+	// FailAfterFail and an Incident's chance are kept, so bursts last as
+	// long as before and incidents hit the same runs
+	RateScale float64
+	// LatencyScale stretches the durations of every Latency; timeouts are
+	// kept, so timing scenarios fail more often above 1
+	LatencyScale float64
+	// Classes lists the enabled scenario classes, every class when empty
+	// Scenarios without a class are always enabled
+	Classes []string
+}
+
+// presets are the built-in chaos profiles
+var presets = map[string]Preset{
+	"local":    {Name: "local", RateScale: 0.5, LatencyScale: 1},
+	"ci-light": {Name: "ci-light", RateScale: 0.25, LatencyScale: 0.5, Classes: []string{"random", "timing", "network", "concurrency"}},
+	"ci-heavy": {Name: "ci-heavy", RateScale: 2, LatencyScale: 2},
+	"soak":     {Name: "soak", RateScale: 0.1, LatencyScale: 1.5},
+}
+
+// LookupPreset returns the built-in preset of the given name
+func LookupPreset(name string) (Preset, bool) {
+	p, ok := presets[name]
+	return p, ok
+}
+
+// PresetNames returns the built-in preset names in sorted order
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// presetByName is LookupPreset with an error naming the choices
+func presetByName(name string) (Preset, error) {
+	p, ok := LookupPreset(name)
+	if !ok {
+		return Preset{}, fmt.Errorf("unknown profile %q (want one of %s)", name, strings.Join(PresetNames(), ", "))
+	}
+	return p, nil
+}
+
+// Enables reports whether scenarios of class run under the preset
+func (p Preset) Enables(class string) bool {
+	return class == "" || len(p.Classes) == 0 || slices.Contains(p.Classes, class)
+}
+
+// apply returns s scaled to the preset
+func (p Preset) apply(s Scenario) Scenario {
+	s.FailureRate = scaleRate(s.FailureRate, p.RateScale)
+	if s.Drift != nil {
+		drift := *s.Drift
+		drift.To = scaleRate(drift.To, p.RateScale)
+		s.Drift = &drift
+	}
+	if s.Incident != nil {
+		incident := *s.Incident
+		incident.FailureRate = scaleRate(incident.FailureRate, p.RateScale)
+		s.Incident = &incident
+	}
+	if s.Cases != nil {
+		cases := make(map[string]float64, len(s.Cases))
+		for name, rate := range s.Cases {
+			cases[name] = scaleRate(rate, p.RateScale)
+		}
+		s.Cases = cases
+	}
+	if s.Latency != nil {
+		latency := s.Latency.scaled(p.LatencyScale)
+		s.Latency = &latency
+	}
+	return s
+}
+
+func scaleRate(rate, scale float64) float64 {
+	return math.Min(rate*scale, 1)
+}
+
+// scaled returns the latency with every duration multiplied by f; the shape
+// parameters Sigma and Alpha are unitless and kept
+func (l Latency) scaled(f float64) Latency {
+	scale := func(d Duration) Duration { return Duration(float64(d) * f) }
+	l.Min, l.Max, l.Step = scale(l.Min), scale(l.Max), scale(l.Step)
+	l.Mean, l.StdDev = scale(l.Mean), scale(l.StdDev)
+	l.Median = scale(l.Median)
+	return l
+}
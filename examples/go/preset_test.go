@@ -0,0 +1,131 @@
+package flaky
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPresetScalesScenarios(t *testing.T) {
+	heavy, ok := LookupPreset("ci-heavy")
+	if !ok {
+		t.Fatal("Expected a built-in ci-heavy preset")
+	}
+	r := DefaultScenarios()
+	r.applyPreset(heavy)
+	if r.Preset() != "ci-heavy" {
+		t.Errorf("Expected preset ci-heavy, got %q", r.Preset())
+	}
+
+	random, _ := r.Get("RandomFailure")
+	if math.Abs(random.FailureRate-0.6) > 1e-9 {
+		t.Errorf("Expected RandomFailure doubled to 0.6, got %v", random.FailureRate)
+	}
+	order, _ := r.Get("OrderDependency")
+	if order.FailureRate != 1 {
+		t.Errorf("Expected OrderDependency capped at 1, got %v", order.FailureRate)
+	}
+	table, _ := r.Get("TableDriven")
+	if table.Cases["shouting"] != 0.8 || table.Cases["lowercase"] != 0 {
+		t.Errorf("Expected case rates doubled, got %v", table.Cases)
+	}
+	if base, _ := DefaultScenarios().Get("TableDriven"); base.Cases["shouting"] != 0.4 {
+		t.Errorf("Expected the defaults left alone, got %v", base.Cases)
+	}
+	bursty, _ := r.Get("BurstyFailure")
+	if bursty.FailAfterFail != 0.8 {
+		t.Errorf("Expected burst lengths kept, got fail_after_fail %v", bursty.FailAfterFail)
+	}
+	drifting, _ := r.Get("DriftingFailure")
+	if drifting.Drift.To != 0.8 {
+		t.Errorf("Expected the drift target doubled to 0.8, got %v", drifting.Drift.To)
+	}
+	if base, _ := DefaultScenarios().Get("DriftingFailure"); base.Drift.To != 0.4 {
+		t.Errorf("Expected the default drift left alone, got %v", base.Drift.To)
+	}
+
+	timing, _ := r.Get("TimingDependent")
+	if timing.Latency.Min != Duration(2*time.Millisecond) || timing.Latency.Max != Duration(10*time.Millisecond) || timing.Latency.Step != Duration(2*time.Millisecond) {
+		t.Errorf("Expected latency stretched to 2ms-10ms in 2ms steps, got %+v", *timing.Latency)
+	}
+	if got := timing.EffectiveFailureRate(); math.Abs(got-0.6) > 1e-9 {
+		t.Errorf("Expected the 4ms timeout exceeded 60%% of the time, got %v", got)
+	}
+}
+
+func TestPresetDisablesClasses(t *testing.T) {
+	light, _ := LookupPreset("ci-light")
+	r := DefaultScenarios()
+	r.applyPreset(light)
+	for name, disabled := range map[string]bool{"NetworkSimulation": false, "ChannelRace": false, "DiskFull": true, "EndOfMonth": true} {
+		if s, _ := r.Get(name); s.Disabled != disabled {
+			t.Errorf("Expected %s (class %s) disabled=%v under ci-light", name, s.Class, disabled)
+		}
+	}
+	if rate := r.FailureRates()["DiskFull"]; rate != 0 {
+		t.Errorf("Expected a disabled scenario never to fail, got rate %v", rate)
+	}
+	if !light.Enables("") {
+		t.Error("Expected scenarios without a class always enabled")
+	}
+}
+
+func TestLoadScenariosProfile(t *testing.T) {
+	path := writeConfig(t, "flaky.yaml", `
+profile: ci-light
+scenarios:
+  - name: RandomFailure
+    failure_rate: 0.3
+  - name: DiskFull
+    class: network
+  - name: CheckoutTimeout
+    class: io
+    failure_rate: 0.1
+`)
+	r, err := LoadScenarios(path)
+	if err != nil {
+		t.Fatalf("LoadScenarios failed: %v", err)
+	}
+	if r.Preset() != "ci-light" {
+		t.Errorf("Expected the file's profile ci-light, got %q", r.Preset())
+	}
+	if s, _ := r.Get("RandomFailure"); s.FailureRate != 0.3 {
+		t.Errorf("Expected a rate set in the file used as written, got %v", s.FailureRate)
+	}
+	if s, _ := r.Get("NetworkSimulation"); math.Abs(s.FailureRate-0.05) > 1e-9 {
+		t.Errorf("Expected the default rate quartered to 0.05, got %v", s.FailureRate)
+	}
+	if s, _ := r.Get("DiskFull"); s.Disabled {
+		t.Error("Expected DiskFull enabled once the file moves it to an enabled class")
+	}
+	if s, _ := r.Get("CheckoutTimeout"); !s.Disabled {
+		t.Error("Expected a new scenario of a disabled class disabled")
+	}
+
+	t.Setenv(ConfigEnv, path)
+	t.Setenv(ProfileEnv, "ci-heavy")
+	r, err = ScenariosFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Preset() != "ci-heavy" {
+		t.Errorf("Expected FLAKY_PROFILE to override the file's profile, got %q", r.Preset())
+	}
+
+	t.Setenv(ConfigEnv, "")
+	t.Setenv(ProfileEnv, "soak")
+	if r, err := ScenariosFromEnv(); err != nil || r.Preset() != "soak" {
+		t.Errorf("Expected FLAKY_PROFILE applied without a config file, got %v, %v", r, err)
+	}
+}
+
+func TestLoadScenariosRejectsUnknownProfile(t *testing.T) {
+	if _, err := LoadScenarios(writeConfig(t, "flaky.yaml", "profile: chaos-monkey\n")); err == nil {
+		t.Error("Expected an unknown profile in the file to be rejected")
+	}
+	t.Setenv(ConfigEnv, "")
+	t.Setenv(ProfileEnv, "chaos-monkey")
+	if _, err := ScenariosFromEnv(); err == nil {
+		t.Error("Expected an unknown FLAKY_PROFILE to be rejected")
+	}
+}
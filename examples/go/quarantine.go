@@ -0,0 +1,47 @@
+package flaky
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/example/flaky-test-example/quarantine"
+)
+
+var quarantineCache struct {
+	mu    sync.Mutex
+	lists map[string]*quarantine.List
+}
+
+// SkipIfQuarantined skips t if it is listed in the quarantine file named by
+// FLAKY_QUARANTINE_FILE, or quarantine.txt in the package directory
+func SkipIfQuarantined(t testing.TB) {
+	t.Helper()
+	list, err := loadQuarantine(quarantine.File())
+	if err != nil {
+		t.Fatalf("Loading quarantine list: %v", err)
+	}
+	if entry, ok := list.Find(t.Name()); ok {
+		if entry.Reason != "" {
+			t.Skipf("Quarantined (%s): %s", entry.Test, entry.Reason)
+		}
+		t.Skipf("Quarantined (%s)", entry.Test)
+	}
+}
+
+// loadQuarantine loads each quarantine file once per test binary
+func loadQuarantine(path string) (*quarantine.List, error) {
+	quarantineCache.mu.Lock()
+	defer quarantineCache.mu.Unlock()
+	if list, ok := quarantineCache.lists[path]; ok {
+		return list, nil
+	}
+	list, err := quarantine.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if quarantineCache.lists == nil {
+		quarantineCache.lists = make(map[string]*quarantine.List)
+	}
+	quarantineCache.lists[path] = list
+	return list, nil
+}
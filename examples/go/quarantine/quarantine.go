@@ -0,0 +1,176 @@
+// Package quarantine manages the list of known-flaky tests that are skipped at runtime
+package quarantine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileEnv overrides where the quarantine list is read from
+const FileEnv = "FLAKY_QUARANTINE_FILE"
+
+// DefaultFile is the quarantine list used when FLAKY_QUARANTINE_FILE is unset
+const DefaultFile = "quarantine.txt"
+
+// Entry is one quarantined test
+type Entry struct {
+	Test   string `json:"test"`
+	Reason string `json:"reason,omitempty"`
+	// Added is when the test was quarantined, nil for entries added before
+	// the list kept track
+	Added *time.Time `json:"added,omitempty"`
+}
+
+// Age returns how long the test has been quarantined at now, or 0 when it
+// is not known
+func (e Entry) Age(now time.Time) time.Duration {
+	if e.Added == nil {
+		return 0
+	}
+	return now.Sub(*e.Added)
+}
+
+// List is a quarantine file
+// Files ending in .json hold a JSON array of entries; any other file holds one
+// test name per line, optionally followed by "# reason" and the day it was
+// added as "(added 2006-01-02)"
+type List struct {
+	Path    string
+	Entries []Entry
+}
+
+// File returns the quarantine path from FLAKY_QUARANTINE_FILE or the default
+func File() string {
+	if path := os.Getenv(FileEnv); path != "" {
+		return path
+	}
+	return DefaultFile
+}
+
+// Load reads the list at path; a missing file is an empty list
+func Load(path string) (*List, error) {
+	l := &List{Path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if isJSON(path) {
+		if err := json.Unmarshal(data, &l.Entries); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		return l, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		test, reason, _ := strings.Cut(line, "#")
+		e := Entry{Test: strings.TrimSpace(test), Reason: strings.TrimSpace(reason)}
+		if m := addedPattern.FindStringSubmatchIndex(e.Reason); m != nil {
+			day, err := time.Parse(time.DateOnly, e.Reason[m[2]:m[3]])
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %s: %w", path, e.Test, err)
+			}
+			e.Added, e.Reason = &day, strings.TrimSpace(e.Reason[:m[0]])
+		}
+		l.Entries = append(l.Entries, e)
+	}
+	return l, scanner.Err()
+}
+
+// addedPattern matches the day an entry of a text list was added, at the
+// end of its reason
+var addedPattern = regexp.MustCompile(`\(added (\d{4}-\d{2}-\d{2})\)$`)
+
+// Contains reports whether test, or a parent of a subtest, is quarantined
+func (l *List) Contains(test string) bool {
+	_, ok := l.Find(test)
+	return ok
+}
+
+// Find returns the entry quarantining test, or a parent of a subtest
+func (l *List) Find(test string) (Entry, bool) {
+	for _, e := range l.Entries {
+		if test == e.Test || strings.HasPrefix(test, e.Test+"/") {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Add quarantines test as of now, reporting false if it already was
+func (l *List) Add(test, reason string) bool {
+	for _, e := range l.Entries {
+		if e.Test == test {
+			return false
+		}
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	l.Entries = append(l.Entries, Entry{Test: test, Reason: reason, Added: &now})
+	sort.Slice(l.Entries, func(i, j int) bool { return l.Entries[i].Test < l.Entries[j].Test })
+	return true
+}
+
+// Remove releases test from quarantine, reporting false if it was not listed
+func (l *List) Remove(test string) bool {
+	for i, e := range l.Entries {
+		if e.Test == test {
+			l.Entries = append(l.Entries[:i], l.Entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Save writes the list back to its path in the format implied by the extension
+func (l *List) Save() error {
+	var buf bytes.Buffer
+	if isJSON(l.Path) {
+		entries := l.Entries
+		if entries == nil {
+			entries = []Entry{}
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	} else {
+		buf.WriteString("# Quarantined flaky tests, skipped by flaky.SkipIfQuarantined\n")
+		for _, e := range l.Entries {
+			buf.WriteString(e.Test)
+			if e.Reason != "" || e.Added != nil {
+				buf.WriteString(" #")
+			}
+			if e.Reason != "" {
+				buf.WriteString(" " + e.Reason)
+			}
+			if e.Added != nil {
+				buf.WriteString(" (added " + e.Added.UTC().Format(time.DateOnly) + ")")
+			}
+			buf.WriteByte('\n')
+		}
+	}
+	return os.WriteFile(l.Path, buf.Bytes(), 0o644)
+}
+
+func isJSON(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
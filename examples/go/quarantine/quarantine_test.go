@@ -0,0 +1,134 @@
+package quarantine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadMissingFileIsEmpty(t *testing.T) {
+	l, err := Load(filepath.Join(t.TempDir(), "quarantine.txt"))
+	if err != nil || len(l.Entries) != 0 {
+		t.Errorf("Expected an empty list, got %+v, %v", l, err)
+	}
+}
+
+func TestLoadText(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quarantine.txt")
+	content := "# known flakes\nTestRandomFailure # tracked in #42\n\nTestChannelRace\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Entries) != 2 || l.Entries[0].Reason != "tracked in #42" || l.Entries[1].Test != "TestChannelRace" {
+		t.Errorf("Unexpected entries: %+v", l.Entries)
+	}
+}
+
+func TestContainsMatchesSubtests(t *testing.T) {
+	l := &List{Entries: []Entry{{Test: "TestTable"}}}
+	for name, want := range map[string]bool{
+		"TestTable":        true,
+		"TestTable/case_1": true,
+		"TestTableOther":   false,
+		"TestOther":        false,
+	} {
+		if got := l.Contains(name); got != want {
+			t.Errorf("Contains(%q) = %v, expected %v", name, got, want)
+		}
+	}
+}
+
+func TestAddRemoveSaveRoundTrip(t *testing.T) {
+	for _, name := range []string{"quarantine.txt", "quarantine.json"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			l, _ := Load(path)
+			if !l.Add("TestB", "flaky 40%") || !l.Add("TestA", "") {
+				t.Fatal("Add reported an existing entry")
+			}
+			if l.Add("TestA", "again") {
+				t.Error("Add accepted a duplicate")
+			}
+			if err := l.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			reloaded, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(reloaded.Entries) != 2 || reloaded.Entries[0].Test != "TestA" || reloaded.Entries[1].Reason != "flaky 40%" {
+				t.Errorf("Unexpected entries after reload: %+v", reloaded.Entries)
+			}
+
+			if !reloaded.Remove("TestA") || reloaded.Remove("TestA") {
+				t.Error("Remove did not report membership correctly")
+			}
+			if err := reloaded.Save(); err != nil {
+				t.Fatal(err)
+			}
+			final, _ := Load(path)
+			if len(final.Entries) != 1 || final.Entries[0].Test != "TestB" {
+				t.Errorf("Unexpected entries after remove: %+v", final.Entries)
+			}
+		})
+	}
+}
+
+func TestAddedRoundTrip(t *testing.T) {
+	for _, name := range []string{"quarantine.txt", "quarantine.json"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			l, _ := Load(path)
+			l.Add("TestA", "")
+			l.Add("TestB", "flaky (mostly)")
+			l.Entries = append(l.Entries, Entry{Test: "TestOld", Reason: "from before"})
+			if err := l.Save(); err != nil {
+				t.Fatal(err)
+			}
+			reloaded, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			today := time.Now().UTC().Format(time.DateOnly)
+			for i, reason := range []string{"", "flaky (mostly)"} {
+				e := reloaded.Entries[i]
+				if e.Reason != reason || e.Added == nil || e.Added.Format(time.DateOnly) != today {
+					t.Errorf("Unexpected entry after reload: %+v", e)
+				}
+			}
+			if old := reloaded.Entries[2]; old.Added != nil || old.Reason != "from before" || old.Age(time.Now()) != 0 {
+				t.Errorf("Expected an entry without a day to keep none, got %+v", old)
+			}
+		})
+	}
+}
+
+func TestLoadTextAdded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quarantine.txt")
+	if err := os.WriteFile(path, []byte("TestA # tracked in #42 (added 2024-03-15)\nTestB # (added 2024-03-15)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	if a := l.Entries[0]; a.Reason != "tracked in #42" || !a.Added.Equal(added) || a.Age(added.Add(48*time.Hour)) != 48*time.Hour {
+		t.Errorf("Unexpected entry %+v", a)
+	}
+	if b := l.Entries[1]; b.Reason != "" || !b.Added.Equal(added) {
+		t.Errorf("Unexpected entry %+v", b)
+	}
+	if err := os.WriteFile(path, []byte("TestA # (added 2024-13-45)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Expected an invalid day to be rejected")
+	}
+}
@@ -0,0 +1,54 @@
+package flaky
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSkipIfQuarantined(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quarantine.txt")
+	if err := os.WriteFile(path, []byte("TestSkipIfQuarantined/listed # known flake\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FLAKY_QUARANTINE_FILE", path)
+
+	ranListed, ranOther := false, false
+	t.Run("listed", func(t *testing.T) {
+		SkipIfQuarantined(t)
+		ranListed = true
+	})
+	t.Run("other", func(t *testing.T) {
+		SkipIfQuarantined(t)
+		ranOther = true
+	})
+
+	if ranListed {
+		t.Error("Quarantined subtest was not skipped")
+	}
+	if !ranOther {
+		t.Error("Subtest that is not quarantined was skipped")
+	}
+}
+
+// TestQuarantineSkipsDemosWithoutScenario verifies the demos that draw from
+// ForTest without a scenario still skip when quarantined
+func TestQuarantineSkipsDemosWithoutScenario(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quarantine.txt")
+	list := "TestQuarantineSkipsDemosWithoutScenario/boundary\nTestQuarantineSkipsDemosWithoutScenario/map\n"
+	if err := os.WriteFile(path, []byte(list), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FLAKY_QUARANTINE_FILE", path)
+
+	for name, test := range map[string]func(*testing.T){"boundary": TestBoundaryCondition, "map": TestMapIteration} {
+		skipped := false
+		t.Run(name, func(t *testing.T) {
+			defer func() { skipped = t.Skipped() }()
+			test(t)
+		})
+		if !skipped {
+			t.Errorf("Expected the quarantined %s demo to be skipped", name)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package flaky_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/clock"
+	"github.com/example/flaky-test-example/flakyqueue"
+)
+
+// chargeConsumer charges the amount in every message it receives
+type chargeConsumer struct {
+	charged int
+	seen    map[string]bool // nil for a consumer that is not idempotent
+}
+
+func (c *chargeConsumer) consume(q *flakyqueue.Queue) {
+	for {
+		m, ok := q.Receive()
+		if !ok {
+			return
+		}
+		if c.seen == nil || !c.seen[m.ID] {
+			amount, _ := strconv.Atoi(m.Body)
+			c.charged += amount
+		}
+		if c.seen != nil {
+			c.seen[m.ID] = true
+		}
+		q.Ack(m)
+	}
+}
+
+// checkCharges publishes one payment to a queue that duplicates at the
+// scenario's rate and checks it is charged once
+func checkCharges(t *testing.T, c *chargeConsumer) {
+	sc := flaky.ScenarioForTest(t, "DuplicateDelivery")
+	q := flakyqueue.New(flaky.ForTest(t), flakyqueue.Profile{Duplicate: sc.FailureRate}, clock.NewFake(time.Now()))
+
+	q.Publish("100")
+	c.consume(q)
+	if c.charged != 100 {
+		t.Errorf("%s: charged %d for a payment of 100", sc.Message, c.charged)
+	}
+}
+
+// TestDuplicateDelivery demonstrates a consumer that assumes exactly-once
+// delivery from an at-least-once queue
+// Fails 20% of the time by default, when the payment is delivered twice
+func TestDuplicateDelivery(t *testing.T) {
+	checkCharges(t, &chargeConsumer{})
+}
+
+// TestDuplicateDeliveryFixed is the reliable variant of TestDuplicateDelivery
+// The consumer is idempotent: it skips message IDs it has already processed
+func TestDuplicateDeliveryFixed(t *testing.T) {
+	checkCharges(t, &chargeConsumer{seen: make(map[string]bool)})
+}
@@ -0,0 +1,51 @@
+package flaky
+
+import (
+	"os"
+	"sync"
+	"testing"
+)
+
+// dataRaceEnv opts in to TestDataRace, whose failures come from the race
+// detector rather than an assertion
+const dataRaceEnv = "FLAKY_DATA_RACE"
+
+// TestDataRace demonstrates a real data race that only -race reports
+// This simulates two workers bumping a shared counter, sometimes without
+// taking its lock
+// Set FLAKY_DATA_RACE=1 to run it; it then fails outright without -race, so
+// a pipeline that drops the flag is caught too
+func TestDataRace(t *testing.T) {
+	if os.Getenv(dataRaceEnv) == "" {
+		t.Skipf("set %s=1 to run a real data race", dataRaceEnv)
+	}
+	if !RaceEnabled {
+		t.Fatalf("%s is set but the race detector is off: run go test -race", dataRaceEnv)
+	}
+	inj := ForTest(t)
+	sc := scenario(t, "DataRace")
+	racy := inj.Float64() < sc.FailureRate
+
+	var (
+		mu      sync.Mutex
+		counter int
+		wg      sync.WaitGroup
+	)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !racy {
+				mu.Lock()
+				defer mu.Unlock()
+			}
+			counter++
+		}()
+	}
+	wg.Wait()
+
+	// Rarely fails on its own; the race detector fails every racy run
+	if counter != 2 {
+		t.Errorf("%s: counter is %d after 2 increments", sc.Message, counter)
+	}
+}
@@ -0,0 +1,139 @@
+package flaky
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/example/flaky-test-example/internal/protocol"
+)
+
+// SourceEnv selects the RNG algorithm behind ForTest, Rand and NewInjector
+const SourceEnv = protocol.SourceEnv
+
+// Source builds the math/rand/v2 source an injector or Rand draws from
+// Every generator is local to its test: the package never seeds or draws from
+// the global math/rand generators, so it cannot perturb randomness in the code
+// under test
+type Source func(seed int64) rand.Source
+
+// PCG is the default Source, a small and fast permuted congruential generator
+func PCG(seed int64) rand.Source {
+	return rand.NewPCG(uint64(seed), 0)
+}
+
+// ChaCha8 is a cryptographically strong Source whose seed fills the first
+// eight bytes of the key
+func ChaCha8(seed int64) rand.Source {
+	var key [32]byte
+	binary.LittleEndian.PutUint64(key[:], uint64(seed))
+	return rand.NewChaCha8(key)
+}
+
+// Sources maps the SourceEnv names to their Source
+var Sources = map[string]Source{
+	"pcg":     PCG,
+	"chacha8": ChaCha8,
+}
+
+// SourceFromEnv returns the Source named by FLAKY_RAND_SOURCE, or PCG when it
+// is unset
+// An unknown name is an error rather than PCG, so a typo does not run the
+// suite on a source other than the one asked for
+func SourceFromEnv() (Source, error) {
+	name := os.Getenv(SourceEnv)
+	if name == "" {
+		return PCG, nil
+	}
+	if src, ok := Sources[strings.ToLower(name)]; ok {
+		return src, nil
+	}
+	names := make([]string, 0, len(Sources))
+	for known := range Sources {
+		names = append(names, known)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("flaky: %s=%q, want one of %s", SourceEnv, name, strings.Join(names, ", "))
+}
+
+// sourceForTest returns SourceFromEnv, failing t when it names no Source
+func sourceForTest(t testing.TB) Source {
+	t.Helper()
+	src, err := SourceFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+// TestSeed derives a per-test seed from GO_TEST_SEED and the test's name, so
+// a test sees the same draws regardless of run order or parallelism
+func TestSeed(t testing.TB) int64 {
+	return SeedFor(SeedFromEnv(), t.Name())
+}
+
+// SeedFor derives the seed a test named name gets under the suite seed
+func SeedFor(suiteSeed int64, name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return suiteSeed ^ int64(h.Sum64())
+}
+
+// Rand returns a deterministic RNG for t seeded by TestSeed, drawing from the
+// Source named by FLAKY_RAND_SOURCE
+// The suite seed is logged and recorded if the test fails so the failure can
+// be reproduced
+func Rand(t testing.TB) *rand.Rand {
+	return rand.New(sourceForTest(t)(testSeed(t)))
+}
+
+// ForTest returns an Injector seeded by TestSeed
+// Expect reports the decisions of the injectors ForTest returned for t
+func ForTest(t testing.TB, opts ...Option) *Injector {
+	i := NewInjector(append([]Option{WithSeed(testSeed(t)), WithSource(sourceForTest(t))}, opts...)...)
+	trackInjector(t, i)
+	return i
+}
+
+// recordedTests holds the tests whose failure record is registered, and
+// pollutionTests those checked for pollution, so a test calling ForTest or
+// Rand again is still logged, recorded and checked once
+var recordedTests, pollutionTests sync.Map
+
+// testSeed returns TestSeed(t) and registers the failure log message and
+// record, and the pollution check when FLAKY_POLLUTION is 1
+func testSeed(t testing.TB) int64 {
+	t.Helper()
+	suiteSeed := SeedFromEnv()
+	recordOnFailure(t, suiteSeed)
+	if pollutionEnabled() {
+		if _, checked := pollutionTests.LoadOrStore(t, true); !checked {
+			t.Cleanup(func() { pollutionTests.Delete(t) })
+			// Registered after the failure record so its cleanup runs first
+			VerifyNoPollution(t)
+		}
+	}
+	return SeedFor(suiteSeed, t.Name())
+}
+
+// recordOnFailure registers the failure log message and record of t, once
+// Cleanups run last to first, so a check that can fail t in its cleanup
+// calls it before registering that cleanup
+func recordOnFailure(t testing.TB, suiteSeed int64) {
+	if _, registered := recordedTests.LoadOrStore(t, true); registered {
+		return
+	}
+	t.Cleanup(func() {
+		recordedTests.Delete(t)
+		if t.Failed() {
+			t.Logf("Reproduce with flakectl reproduce %s --seed %d", t.Name(), suiteSeed)
+			recordFailure(t, suiteSeed)
+		}
+	})
+}
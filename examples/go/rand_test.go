@@ -0,0 +1,156 @@
+package flaky
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// namedTB reports a fixed test name so the same "test" can be drawn for twice
+type namedTB struct {
+	testing.TB
+	name string
+}
+
+func (n namedTB) Name() string { return n.name }
+
+// firstDraws simulates running the named tests in order, recording each one's
+// first two draws
+func firstDraws(t *testing.T, names ...string) map[string][2]float64 {
+	draws := make(map[string][2]float64)
+	for _, name := range names {
+		r := Rand(namedTB{TB: t, name: name})
+		draws[name] = [2]float64{r.Float64(), r.Float64()}
+	}
+	return draws
+}
+
+// TestRandIndependentOfOrder verifies a test's draws do not depend on which
+// tests ran before it
+func TestRandIndependentOfOrder(t *testing.T) {
+	t.Setenv(SeedEnv, "12345")
+	forward := firstDraws(t, "TestAlpha", "TestBeta", "TestGamma")
+	reverse := firstDraws(t, "TestGamma", "TestBeta", "TestAlpha")
+	for name, draw := range forward {
+		if reverse[name] != draw {
+			t.Errorf("%s: draw %v in forward order, %v in reverse order", name, draw, reverse[name])
+		}
+	}
+}
+
+// TestRandDiffersByNameAndSeed verifies both the test name and suite seed matter
+func TestRandDiffersByNameAndSeed(t *testing.T) {
+	if SeedFor(12345, "TestA") == SeedFor(12345, "TestB") {
+		t.Error("Different test names derived the same seed")
+	}
+	if SeedFor(1, "TestA") == SeedFor(2, "TestA") {
+		t.Error("Different suite seeds derived the same seed")
+	}
+
+	t.Setenv(SeedEnv, "12345")
+	if got, want := TestSeed(t), SeedFor(12345, t.Name()); got != want {
+		t.Errorf("TestSeed returned %d, expected %d", got, want)
+	}
+}
+
+// TestForTestMatchesRand verifies ForTest and Rand draw the same sequence
+func TestForTestMatchesRand(t *testing.T) {
+	inj := ForTest(t)
+	r := Rand(t)
+	for i := 0; i < 10; i++ {
+		if a, b := inj.Float64(), r.Float64(); a != b {
+			t.Fatalf("Draw %d: ForTest gave %v, Rand gave %v", i, a, b)
+		}
+	}
+}
+
+// TestSourceFromEnv verifies FLAKY_RAND_SOURCE picks the algorithm, defaults
+// to PCG and rejects unknown names
+func TestSourceFromEnv(t *testing.T) {
+	first := func(src Source) uint64 { return src(12345).Uint64() }
+	for value, want := range map[string]Source{"": PCG, "pcg": PCG, "ChaCha8": ChaCha8} {
+		t.Setenv(SourceEnv, value)
+		src, err := SourceFromEnv()
+		if err != nil || first(src) != first(want) {
+			t.Errorf("%s=%q: got %v, expected the first draw %d", SourceEnv, value, err, first(want))
+		}
+	}
+	if first(PCG) == first(ChaCha8) {
+		t.Error("PCG and ChaCha8 produced the same first draw")
+	}
+
+	t.Setenv(SourceEnv, "mt19937")
+	if _, err := SourceFromEnv(); err == nil || !strings.Contains(err.Error(), "chacha8, pcg") {
+		t.Errorf("Expected an error listing the sources, got %v", err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected NewInjector to panic on an unknown source")
+			}
+		}()
+		NewInjector()
+	}()
+	NewInjector(WithSource(ChaCha8)) // WithSource stands in for the unknown name
+}
+
+// TestRandUsesSourceFromEnv verifies Rand and ForTest share the selected source
+func TestRandUsesSourceFromEnv(t *testing.T) {
+	t.Setenv(SeedEnv, "12345")
+	t.Setenv(SourceEnv, "chacha8")
+	want := ChaCha8(TestSeed(t)).Uint64()
+	if got := Rand(t).Uint64(); got != want {
+		t.Errorf("Expected Rand to draw %d from ChaCha8, got %d", want, got)
+	}
+	inj := ForTest(t)
+	if got, want := inj.Float64(), Rand(t).Float64(); got != want {
+		t.Errorf("Expected ForTest to draw %v like Rand, got %v", want, got)
+	}
+}
+
+// loggingTB records Logf too, on top of finishingTB
+type loggingTB struct {
+	finishingTB
+}
+
+func (l *loggingTB) Logf(format string, args ...any) {
+	l.logs = append(l.logs, fmt.Sprintf(format, args...))
+}
+
+// dropRecords removes the failure records of the named test from the
+// recorder, returning how many there were
+func dropRecords(name string) int {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	dropped := 0
+	kept := recorder.failures[:0]
+	for _, f := range recorder.failures {
+		if f.Test == name {
+			dropped++
+			continue
+		}
+		kept = append(kept, f)
+	}
+	recorder.failures = kept
+	return dropped
+}
+
+func TestFailureRecordedOncePerTest(t *testing.T) {
+	t.Setenv(SeedEnv, "7")
+	t.Setenv(PollutionEnv, "")
+	tb := &loggingTB{finishingTB{TB: t, failed: true}}
+	ForTest(tb)
+	ForTest(tb)
+	Rand(tb)
+
+	// Other tests fail a TestCheckout of their own
+	dropRecords("TestCheckout")
+	tb.finish()
+	recorded := dropRecords("TestCheckout")
+	if len(tb.logs) != 1 || recorded != 1 {
+		t.Errorf("Expected one reproduce line and one record, got %q and %d record(s)", tb.logs, recorded)
+	}
+	if _, ok := recordedTests.Load(tb); ok {
+		t.Error("Expected the test to be forgotten once it finished")
+	}
+}
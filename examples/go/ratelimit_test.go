@@ -0,0 +1,67 @@
+package flaky_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/clock"
+	"github.com/example/flaky-test-example/flakyhttp"
+)
+
+// pageAttempts is how often the clients of the rate limit scenario send one
+// page request before giving up
+const pageAttempts = 5
+
+// fetchPages fetches three pages from an API that allows a burst of three
+// requests per second and throttles at the scenario's rate on top; backoff
+// is called with every 429 before the request is sent again
+func fetchPages(t *testing.T, backoff func(fake *clock.FakeClock, resp *http.Response)) {
+	sc := flaky.ScenarioForTest(t, "RateLimit")
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	srv := flakyhttp.NewServer(flaky.ForTest(t), flakyhttp.ServerProfile{
+		Throttle:  sc.FailureRate,
+		RateLimit: flakyhttp.RateLimit{Burst: 3, Refill: time.Second, MaxRetryAfter: 3 * time.Second, Clock: fake},
+	}, nil)
+	defer srv.Close()
+
+	for page := 1; page <= 3; page++ {
+		status := 0
+		for attempt := 0; attempt < pageAttempts && status != http.StatusOK; attempt++ {
+			resp, err := http.Get(fmt.Sprintf("%s/items?page=%d", srv.URL, page))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if status = resp.StatusCode; status == http.StatusTooManyRequests {
+				backoff(fake, resp)
+			}
+		}
+		if status != http.StatusOK {
+			flaky.Report(t, sc.Meta(map[string]any{"faults": srv.Counts()}))
+			t.Fatalf("%s: page %d still got %d after %d attempts", sc.Message, page, status, pageAttempts)
+		}
+	}
+}
+
+// TestRateLimit demonstrates a client that retries throttled requests right
+// away
+// Fails ~27% of the time by default: every injected 429 spends a token of
+// the burst, so a later page finds the bucket empty and its immediate
+// retries are throttled too
+func TestRateLimit(t *testing.T) {
+	fetchPages(t, func(*clock.FakeClock, *http.Response) {})
+}
+
+// TestRateLimitFixed is the reliable variant of TestRateLimit
+// The client waits the Retry-After the server asks for, on a fake clock, so
+// the bucket has refilled before it tries again
+func TestRateLimitFixed(t *testing.T) {
+	fetchPages(t, func(fake *clock.FakeClock, resp *http.Response) {
+		if wait, ok := flakyhttp.RetryAfter(resp); ok {
+			fake.Sleep(wait)
+		}
+	})
+}
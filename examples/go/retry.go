@@ -0,0 +1,232 @@
+package flaky
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/backoff"
+	"github.com/example/flaky-test-example/retrypolicy"
+)
+
+// RetryLogPrefix marks the structured line Retry logs when a test flaky-passes
+const RetryLogPrefix = "flaky-retry: "
+
+// RetryResult describes how a retried test body behaved
+type RetryResult struct {
+	Test     string   `json:"test"`
+	Status   string   `json:"status"` // "pass", "flaky-pass" or "fail"
+	Attempts int      `json:"attempts"`
+	Failures []string `json:"failures,omitempty"`
+	Seed     int64    `json:"seed"`
+}
+
+// RetryOption configures Retry
+type RetryOption func(*retryConfig)
+
+type retryConfig struct {
+	backoff backoff.Policy
+	sleep   func(time.Duration)
+}
+
+// WithBackoff waits initial before the first retry, multiplying the wait by
+// multiplier after each retry up to max
+func WithBackoff(initial, max time.Duration, multiplier float64) RetryOption {
+	return func(c *retryConfig) {
+		c.backoff.Initial, c.backoff.Max, c.backoff.Multiplier = initial, max, multiplier
+	}
+}
+
+// WithJitter adds a seeded random extra wait of up to fraction of each backoff
+func WithJitter(fraction float64) RetryOption {
+	return func(c *retryConfig) {
+		c.backoff.Fraction = fraction
+	}
+}
+
+// WithRetrySleep replaces time.Sleep between attempts
+func WithRetrySleep(sleep func(time.Duration)) RetryOption {
+	return func(c *retryConfig) {
+		c.sleep = sleep
+	}
+}
+
+// NewBackoff returns the waits of p with the jitter drawn from t's seeded
+// RNG, so retry logic under test backs off the same way for the same seed
+func NewBackoff(t testing.TB, p backoff.Policy) *backoff.Backoff {
+	return p.New(Rand(t))
+}
+
+// Retry runs fn in subtests named attempt_1, attempt_2, ... until one passes or
+// attempts are exhausted
+// Failures of attempts that are retried are logged, not reported, so a body
+// that passes on a retry leaves t passing and logs a RetryLogPrefix line with
+// status "flaky-pass"
+// fn receives a testing.TB rather than *testing.T because a failure reported
+// on a real *testing.T cannot be retracted
+func Retry(t *testing.T, attempts int, fn func(t testing.TB), opts ...RetryOption) RetryResult {
+	t.Helper()
+	cfg := retryConfig{backoff: backoff.Policy{Multiplier: 2, Jitter: backoff.Proportional}, sleep: time.Sleep}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if attempts < 1 {
+		attempts = 1
+	}
+	// The jitter draws from a stream of its own, so it does not repeat the
+	// first draws of the test's own Rand; testSeed still records a failure
+	suiteSeed := SeedFromEnv()
+	testSeed(t)
+	waits := cfg.backoff.New(rand.New(sourceForTest(t)(SeedFor(suiteSeed, t.Name()+"/retry"))))
+
+	result := RetryResult{Test: t.Name(), Status: "fail", Seed: TestSeed(t)}
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			if wait := waits.Next(); wait > 0 {
+				cfg.sleep(wait)
+			}
+		}
+		result.Attempts = attempt
+		last := attempt == attempts
+
+		var rec *attemptTB
+		t.Run(fmt.Sprintf("attempt_%d", attempt), func(st *testing.T) {
+			rec = runAttempt(st, fn)
+			switch {
+			case rec.skipped:
+				st.SkipNow()
+			case rec.failed && last:
+				for _, msg := range rec.messages {
+					st.Error(msg)
+				}
+			case rec.failed:
+				for _, msg := range rec.messages {
+					st.Logf("attempt %d failed (will retry): %s", attempt, msg)
+				}
+			}
+		})
+		if rec.skipped {
+			return result
+		}
+		if !rec.failed {
+			result.Status = "pass"
+			if attempt > 1 {
+				result.Status = "flaky-pass"
+			}
+			break
+		}
+		result.Failures = append(result.Failures, rec.summary())
+	}
+
+	if result.Status != "pass" {
+		data, _ := json.Marshal(result)
+		t.Log(RetryLogPrefix + string(data))
+	}
+	return result
+}
+
+var retryPolicyCache struct {
+	mu       sync.Mutex
+	policies map[string]*retrypolicy.Policy
+}
+
+// RetryAttempts returns the attempts to give Retry for t under the retry
+// policy named by FLAKY_RETRY_POLICY, or retry-policy.json in the package
+// directory: one more than the retries of its rule, or fallback for a test
+// without one
+// flakectl recommend-retries writes the policy from measured flake rates
+func RetryAttempts(t testing.TB, fallback int) int {
+	t.Helper()
+	policy, err := loadRetryPolicy(retrypolicy.File())
+	if err != nil {
+		t.Fatalf("Loading retry policy: %v", err)
+	}
+	if rule, ok := policy.Find(t.Name()); ok {
+		return rule.Retries + 1
+	}
+	return fallback
+}
+
+// loadRetryPolicy loads each retry policy once per test binary
+func loadRetryPolicy(path string) (*retrypolicy.Policy, error) {
+	retryPolicyCache.mu.Lock()
+	defer retryPolicyCache.mu.Unlock()
+	if policy, ok := retryPolicyCache.policies[path]; ok {
+		return policy, nil
+	}
+	policy, err := retrypolicy.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if retryPolicyCache.policies == nil {
+		retryPolicyCache.policies = make(map[string]*retrypolicy.Policy)
+	}
+	retryPolicyCache.policies[path] = policy
+	return policy, nil
+}
+
+// runAttempt runs fn against a recording TB on its own goroutine so FailNow
+// only ends the attempt
+func runAttempt(t *testing.T, fn func(t testing.TB)) *attemptTB {
+	rec := &attemptTB{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(rec)
+	}()
+	<-done
+	return rec
+}
+
+// attemptTB records failures and skips instead of reporting them
+type attemptTB struct {
+	testing.TB
+	mu       sync.Mutex
+	failed   bool
+	skipped  bool
+	messages []string
+}
+
+func (a *attemptTB) record(msg string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.failed = true
+	if msg != "" {
+		a.messages = append(a.messages, msg)
+	}
+}
+
+func (a *attemptTB) summary() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.messages) == 0 {
+		return "failed"
+	}
+	return a.messages[0]
+}
+
+func (a *attemptTB) Fail()                             { a.record("") }
+func (a *attemptTB) Error(args ...any)                 { a.record(fmt.Sprint(args...)) }
+func (a *attemptTB) Errorf(format string, args ...any) { a.record(fmt.Sprintf(format, args...)) }
+func (a *attemptTB) FailNow()                          { a.record(""); runtime.Goexit() }
+func (a *attemptTB) Fatal(args ...any)                 { a.record(fmt.Sprint(args...)); runtime.Goexit() }
+func (a *attemptTB) Fatalf(format string, args ...any) {
+	a.record(fmt.Sprintf(format, args...))
+	runtime.Goexit()
+}
+func (a *attemptTB) SkipNow()                         { a.skip() }
+func (a *attemptTB) Skip(args ...any)                 { a.TB.Log(args...); a.skip() }
+func (a *attemptTB) Skipf(format string, args ...any) { a.TB.Logf(format, args...); a.skip() }
+func (a *attemptTB) Skipped() bool                    { a.mu.Lock(); defer a.mu.Unlock(); return a.skipped }
+func (a *attemptTB) Failed() bool                     { a.mu.Lock(); defer a.mu.Unlock(); return a.failed }
+
+func (a *attemptTB) skip() {
+	a.mu.Lock()
+	a.skipped = true
+	a.mu.Unlock()
+	runtime.Goexit()
+}
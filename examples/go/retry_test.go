@@ -0,0 +1,131 @@
+package flaky
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/backoff"
+)
+
+func TestRetryFlakyPass(t *testing.T) {
+	calls := 0
+	result := Retry(t, 3, func(t testing.TB) {
+		calls++
+		if calls < 2 {
+			t.Fatalf("transient failure %d", calls)
+		}
+	})
+
+	if result.Status != "flaky-pass" || result.Attempts != 2 || calls != 2 {
+		t.Errorf("Expected flaky-pass on attempt 2, got %+v after %d calls", result, calls)
+	}
+	if len(result.Failures) != 1 || result.Failures[0] != "transient failure 1" {
+		t.Errorf("Expected the first failure to be recorded, got %v", result.Failures)
+	}
+	if result.Seed != TestSeed(t) {
+		t.Errorf("Expected seed %d in metadata, got %d", TestSeed(t), result.Seed)
+	}
+}
+
+func TestRetryPassFirstAttempt(t *testing.T) {
+	result := Retry(t, 3, func(t testing.TB) {})
+	if result.Status != "pass" || result.Attempts != 1 {
+		t.Errorf("Expected pass on the first attempt, got %+v", result)
+	}
+}
+
+func TestRetryAttempts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry-policy.json")
+	policy := `{"target": 0.001, "max_retries": 5, "tests": [{"test": "TestRetryAttempts/listed", "flake_rate": 0.1, "retries": 2, "residual": 0.001}]}`
+	if err := os.WriteFile(path, []byte(policy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FLAKY_RETRY_POLICY", path)
+
+	t.Run("listed", func(t *testing.T) {
+		if got := RetryAttempts(t, 1); got != 3 {
+			t.Errorf("Expected 3 attempts from the policy, got %d", got)
+		}
+	})
+	t.Run("other", func(t *testing.T) {
+		if got := RetryAttempts(t, 1); got != 1 {
+			t.Errorf("Expected the fallback for a test without a rule, got %d", got)
+		}
+	})
+}
+
+func TestRetryBackoffAndJitter(t *testing.T) {
+	var waits []time.Duration
+	sleep := WithRetrySleep(func(d time.Duration) { waits = append(waits, d) })
+
+	Retry(t, 4, func(t testing.TB) {
+		if len(waits) < 3 {
+			t.Error("not yet")
+		}
+	}, WithBackoff(10*time.Millisecond, 25*time.Millisecond, 2), sleep)
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 25 * time.Millisecond}
+	if len(waits) != len(want) {
+		t.Fatalf("Expected waits %v, got %v", want, waits)
+	}
+	for i := range want {
+		if waits[i] != want[i] {
+			t.Errorf("Wait %d: expected %v, got %v", i, want[i], waits[i])
+		}
+	}
+
+	waits = nil
+	Retry(t, 2, func(t testing.TB) {
+		if len(waits) == 0 {
+			t.Error("not yet")
+		}
+	}, WithBackoff(10*time.Millisecond, 0, 2), WithJitter(0.5), sleep)
+	if len(waits) != 1 || waits[0] < 10*time.Millisecond || waits[0] > 15*time.Millisecond {
+		t.Errorf("Expected one jittered wait in [10ms, 15ms], got %v", waits)
+	}
+}
+
+// TestRetryExhaustedHelper only runs inside TestRetryExhausted's subprocess
+func TestRetryExhaustedHelper(t *testing.T) {
+	if os.Getenv("FLAKY_RETRY_HELPER") != "1" {
+		t.Skip("helper for TestRetryExhausted")
+	}
+	Retry(t, 2, func(t testing.TB) {
+		t.Error("always broken")
+	})
+}
+
+func TestRetryExhausted(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRetryExhaustedHelper$", "-test.v")
+	cmd.Env = append(os.Environ(), "FLAKY_RETRY_HELPER=1", FailuresFileEnv+"="+t.TempDir()+"/failures.json")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("Expected the helper to fail after exhausting retries:\n%s", out)
+	}
+	for _, want := range []string{
+		"--- PASS: TestRetryExhaustedHelper/attempt_1",
+		"attempt 1 failed (will retry): always broken",
+		"--- FAIL: TestRetryExhaustedHelper/attempt_2",
+		RetryLogPrefix + `{"test":"TestRetryExhaustedHelper","status":"fail","attempts":2`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(string(out), "Reproduce with flakectl reproduce TestRetryExhaustedHelper "); n != 1 {
+		t.Errorf("Expected the failure logged once, got %d times:\n%s", n, out)
+	}
+}
+
+func TestNewBackoffIsSeeded(t *testing.T) {
+	p := backoff.Policy{Initial: 10 * time.Millisecond, Max: time.Second, Jitter: backoff.Full}
+	a, b := NewBackoff(t, p), NewBackoff(t, p)
+	for retry := 0; retry < 5; retry++ {
+		if wa, wb := a.Next(), b.Next(); wa != wb {
+			t.Fatalf("Retry %d: expected the test's seed to give the same wait, got %v and %v", retry, wa, wb)
+		}
+	}
+}
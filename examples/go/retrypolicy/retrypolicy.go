@@ -0,0 +1,108 @@
+// Package retrypolicy holds per-test retry counts recommended from measured
+// flake rates, for flaky.RetryAttempts and CI retry wrappers to read
+package retrypolicy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// FileEnv overrides where the retry policy is read from
+const FileEnv = "FLAKY_RETRY_POLICY"
+
+// DefaultFile is the retry policy used when FLAKY_RETRY_POLICY is unset
+const DefaultFile = "retry-policy.json"
+
+// Rule is the retry count of one test
+type Rule struct {
+	Package string `json:"package,omitempty"`
+	Test    string `json:"test"`
+	// FlakeRate is the rate the retries were computed for
+	FlakeRate float64 `json:"flake_rate"`
+	Retries   int     `json:"retries"`
+	// Residual is the chance the test's flakes still fail CI after its
+	// retries, above the policy's target when Retries was capped
+	Residual float64 `json:"residual"`
+}
+
+// Policy is a retry policy file, a JSON object
+type Policy struct {
+	Path string `json:"-"`
+	// Target is the chance of a flake failing CI the retries aim for
+	Target float64 `json:"target"`
+	// MaxRetries caps every rule's retries
+	MaxRetries int    `json:"max_retries"`
+	Rules      []Rule `json:"tests"`
+}
+
+// File returns the policy path from FLAKY_RETRY_POLICY or the default
+func File() string {
+	if path := os.Getenv(FileEnv); path != "" {
+		return path
+	}
+	return DefaultFile
+}
+
+// Load reads the policy at path; a missing file is an empty policy
+func Load(path string) (*Policy, error) {
+	p := &Policy{Path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Save writes the policy to its path, rules sorted by test name
+func (p *Policy) Save() error {
+	sort.Slice(p.Rules, func(i, j int) bool { return p.Rules[i].Test < p.Rules[j].Test })
+	if p.Rules == nil {
+		p.Rules = []Rule{}
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.Path, append(data, '\n'), 0o644)
+}
+
+// Find returns the rule for test, or for the closest parent of a subtest
+func (p *Policy) Find(test string) (Rule, bool) {
+	var found Rule
+	ok := false
+	for _, r := range p.Rules {
+		if (test == r.Test || strings.HasPrefix(test, r.Test+"/")) && (!ok || len(r.Test) > len(found.Test)) {
+			found, ok = r, true
+		}
+	}
+	return found, ok
+}
+
+// Retries returns how many retries keep the chance that a test failing at
+// flakeRate fails every attempt at or below target, and that chance
+// A test that never flakes needs none, and one that always fails cannot be
+// retried into passing; the count is capped at maxRetries, leaving the
+// residual above target
+func Retries(flakeRate, target float64, maxRetries int) (int, float64) {
+	if flakeRate <= 0 {
+		return 0, 0
+	}
+	if flakeRate >= 1 {
+		return maxRetries, 1
+	}
+	attempts := int(math.Ceil(math.Log(target)/math.Log(flakeRate) - 1e-9))
+	retries := min(max(attempts-1, 0), maxRetries)
+	return retries, math.Pow(flakeRate, float64(retries+1))
+}
@@ -0,0 +1,64 @@
+package retrypolicy
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRetries(t *testing.T) {
+	for _, tc := range []struct {
+		rate     float64
+		want     int
+		residual float64
+	}{
+		{0, 0, 0},
+		{0.0005, 0, 0.0005},
+		{0.02, 1, 0.0004},
+		{0.1, 2, 0.001},
+		{0.3, 5, math.Pow(0.3, 6)},
+		{0.5, 5, math.Pow(0.5, 6)},
+		{1, 5, 1},
+	} {
+		got, residual := Retries(tc.rate, 0.001, 5)
+		if got != tc.want || math.Abs(residual-tc.residual) > 1e-12 {
+			t.Errorf("Retries(%v): expected %d retries leaving %v, got %d leaving %v", tc.rate, tc.want, tc.residual, got, residual)
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry-policy.json")
+	empty, err := Load(path)
+	if err != nil || len(empty.Rules) != 0 {
+		t.Fatalf("Expected an empty policy for a missing file, got %+v, %v", empty, err)
+	}
+
+	p := &Policy{Path: path, Target: 0.001, MaxRetries: 5, Rules: []Rule{
+		{Package: "p", Test: "TestB", FlakeRate: 0.1, Retries: 2, Residual: 0.001},
+		{Package: "p", Test: "TestA", FlakeRate: 0.02, Retries: 1, Residual: 0.0004},
+	}}
+	if err := p.Save(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, p) || got.Rules[0].Test != "TestA" {
+		t.Errorf("Expected %+v sorted by test, got %+v", p, got)
+	}
+}
+
+func TestFind(t *testing.T) {
+	p := &Policy{Rules: []Rule{{Test: "TestA", Retries: 1}, {Test: "TestA/slow", Retries: 3}}}
+	for test, want := range map[string]int{"TestA": 1, "TestA/fast": 1, "TestA/slow": 3, "TestA/slow/eu": 3} {
+		if r, ok := p.Find(test); !ok || r.Retries != want {
+			t.Errorf("Find(%q): expected %d retries, got %+v, %v", test, want, r, ok)
+		}
+	}
+	if _, ok := p.Find("TestAB"); ok {
+		t.Error("Expected no rule for a test that only shares a prefix")
+	}
+}
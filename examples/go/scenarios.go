@@ -0,0 +1,577 @@
+package flaky
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/example/flaky-test-example/distributions"
+	"github.com/example/flaky-test-example/internal/protocol"
+)
+
+// ConfigEnv points to a scenario config file overriding the defaults
+const ConfigEnv = protocol.ConfigEnv
+
+// DefaultConfigFile is loaded from the working directory when FLAKY_CONFIG is unset
+const DefaultConfigFile = "flaky.yaml"
+
+// Duration is a time.Duration written as a string such as "5ms" in config files
+type Duration time.Duration
+
+// UnmarshalText parses a duration string
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText formats the duration as a string
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Latency configures the distribution of an injected delay, uniform over
+// [Min, Max] unless Type names another
+type Latency struct {
+	// Type is "uniform" (the default), "normal", "lognormal", "exponential"
+	// or "pareto"
+	Type string   `json:"type,omitempty" yaml:"type,omitempty"`
+	Min  Duration `json:"min" yaml:"min"`
+	Max  Duration `json:"max" yaml:"max"`
+	// Step makes uniform latencies whole multiples of it above Min
+	Step Duration `json:"step,omitempty" yaml:"step,omitempty"`
+	// Mean is the mean of normal and exponential latencies, StdDev the
+	// standard deviation of normal ones
+	Mean   Duration `json:"mean,omitempty" yaml:"mean,omitempty"`
+	StdDev Duration `json:"stddev,omitempty" yaml:"stddev,omitempty"`
+	// Median and Sigma shape lognormal latencies
+	Median Duration `json:"median,omitempty" yaml:"median,omitempty"`
+	Sigma  float64  `json:"sigma,omitempty" yaml:"sigma,omitempty"`
+	// Alpha is the tail index of pareto latencies, which start at Min
+	Alpha float64 `json:"alpha,omitempty" yaml:"alpha,omitempty"`
+}
+
+// Distribution returns the configured distribution, or an error for an
+// unknown type or parameters it is undefined for
+func (l Latency) Distribution() (distributions.Distribution, error) {
+	var d distributions.Distribution
+	switch strings.ToLower(l.Type) {
+	case "", "uniform":
+		d = distributions.Uniform{Min: time.Duration(l.Min), Max: time.Duration(l.Max), Step: time.Duration(l.Step)}
+	case "normal":
+		d = distributions.Normal{Mean: time.Duration(l.Mean), StdDev: time.Duration(l.StdDev)}
+	case "lognormal":
+		d = distributions.LogNormal{Median: time.Duration(l.Median), Sigma: l.Sigma}
+	case "exponential":
+		d = distributions.Exponential{Mean: time.Duration(l.Mean)}
+	case "pareto":
+		d = distributions.Pareto{Min: time.Duration(l.Min), Alpha: l.Alpha}
+	default:
+		return nil, fmt.Errorf("unknown latency type %q", l.Type)
+	}
+	return d, d.Validate()
+}
+
+// LatencyDistribution returns the distribution of the scenario's delay, nil
+// without a Latency
+// Loaded scenarios are validated, so it only panics on an invalid Latency
+// set in code
+func (s Scenario) LatencyDistribution() distributions.Distribution {
+	if s.Latency == nil {
+		return nil
+	}
+	d, err := s.Latency.Distribution()
+	if err != nil {
+		panic(fmt.Sprintf("flaky: scenario %s: %v", s.Name, err))
+	}
+	return d
+}
+
+// Scenario configures one failure scenario
+type Scenario struct {
+	Name string `json:"name" yaml:"name"`
+	// FailureRate is the probability that one run of the scenario fails;
+	// for a calendar scenario it is the probability that the run starts at
+	// the hazard, which fails only TripRate of those runs
+	FailureRate float64 `json:"failure_rate" yaml:"failure_rate"`
+	// TripRate, when set, is the share of the runs at a calendar hazard
+	// that trip the bug, such as the 5 of 12 month ends before a shorter
+	// month for EndOfMonth
+	TripRate float64 `json:"trip_rate,omitempty" yaml:"trip_rate,omitempty"`
+	// FailAfterFail, when set, makes the scenario bursty: it is the
+	// probability of failing right after a failed run, and FailureRate the
+	// probability after a passing one
+	FailAfterFail float64 `json:"fail_after_fail,omitempty" yaml:"fail_after_fail,omitempty"`
+	// Drift, when set, changes FailureRate over the runs of a sweep; see
+	// AtRun
+	Drift *Drift `json:"drift,omitempty" yaml:"drift,omitempty"`
+	// Incident, when set, makes the scenario fail with the environment's
+	// health, in the same runs as other scenarios of its chance; see AtRun
+	Incident *Incident `json:"incident,omitempty" yaml:"incident,omitempty"`
+	// Latency is the injected delay range for timing scenarios
+	Latency *Latency `json:"latency,omitempty" yaml:"latency,omitempty"`
+	// Timeout fails a timing scenario whose delay exceeds it
+	Timeout Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// Load is the background CPU load Injector.ApplyLoad runs on the runs
+	// FailureRate picks
+	Load *Load `json:"load,omitempty" yaml:"load,omitempty"`
+	// Message prefixes the failure the scenario reports
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+	// Injector names the registered FaultInjector that decides the
+	// scenario's runs in place of the fields above
+	Injector string `json:"injector,omitempty" yaml:"injector,omitempty"`
+	// Cases overrides FailureRate for the cases of a table-driven scenario,
+	// by case name; see Case
+	Cases map[string]float64 `json:"cases,omitempty" yaml:"cases,omitempty"`
+	// Class groups the scenario with others of its kind, such as "timing"
+	// or "network", for presets to enable
+	Class string `json:"class,omitempty" yaml:"class,omitempty"`
+	// Disabled is set on scenarios whose class the preset does not enable
+	// or that the Selection leaves out; their tests skip
+	Disabled bool `json:"-" yaml:"-"`
+}
+
+// Case returns the scenario of one case of a table-driven test, named
+// Name/name and failing at the rate Cases gives the case, or at FailureRate
+// when it gives none
+// Draw from ForTest in the case's subtest, whose seed is derived from the
+// subtest's name, so each case fails independently of the others
+func (s Scenario) Case(name string) Scenario {
+	c := s
+	c.Name = s.Name + "/" + name
+	if rate, ok := s.Cases[name]; ok {
+		c.FailureRate = rate
+	}
+	c.Cases = nil
+	return c
+}
+
+// EffectiveFailureRate returns the probability of failing, derived from the
+// latency distribution and timeout for timing scenarios, the long-run rate for
+// bursty ones, the chance any case fails for table-driven ones and the
+// share of hazard runs that trip for calendar ones; an
+// Injector's rate is estimated from seeded decisions, a drifting or
+// incident-prone scenario's is the one at RunIndex, and a disabled scenario
+// never fails
+func (s Scenario) EffectiveFailureRate() float64 {
+	if s.Disabled {
+		return 0
+	}
+	s = s.AtRun(RunIndex())
+	if s.Injector != "" {
+		if f, ok := LookupInjector(s.Injector); ok {
+			return estimateRate(f)
+		}
+		return 1
+	}
+	if len(s.Cases) > 0 {
+		pass := 1.0
+		for _, rate := range s.Cases {
+			pass *= 1 - rate
+		}
+		return 1 - pass
+	}
+	if s.FailAfterFail > 0 {
+		return stationaryRate(s.FailureRate, s.FailAfterFail)
+	}
+	if s.TripRate > 0 {
+		return s.FailureRate * s.TripRate
+	}
+	if s.Latency == nil || s.Timeout <= 0 {
+		return s.FailureRate
+	}
+	d, err := s.Latency.Distribution()
+	if err != nil {
+		return s.FailureRate
+	}
+	return 1 - d.CDF(time.Duration(s.Timeout))
+}
+
+func (s Scenario) validate() error {
+	if s.Name == "" {
+		return errors.New("scenario without a name")
+	}
+	if s.FailureRate < 0 || s.FailureRate > 1 {
+		return fmt.Errorf("scenario %s: failure_rate %v outside [0, 1]", s.Name, s.FailureRate)
+	}
+	for name, rate := range s.Cases {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("scenario %s: failure_rate %v of case %s outside [0, 1]", s.Name, rate, name)
+		}
+	}
+	if s.FailAfterFail < 0 || s.FailAfterFail > 1 {
+		return fmt.Errorf("scenario %s: fail_after_fail %v outside [0, 1]", s.Name, s.FailAfterFail)
+	}
+	if s.TripRate < 0 || s.TripRate > 1 {
+		return fmt.Errorf("scenario %s: trip_rate %v outside [0, 1]", s.Name, s.TripRate)
+	}
+	if s.Drift != nil {
+		if err := s.Drift.Validate(); err != nil {
+			return fmt.Errorf("scenario %s: %w", s.Name, err)
+		}
+	}
+	if s.Incident != nil {
+		if err := s.Incident.Validate(); err != nil {
+			return fmt.Errorf("scenario %s: %w", s.Name, err)
+		}
+	}
+	if s.Latency != nil {
+		if _, err := s.Latency.Distribution(); err != nil {
+			return fmt.Errorf("scenario %s: latency: %w", s.Name, err)
+		}
+	}
+	if s.Load != nil {
+		if err := s.Load.Validate(); err != nil {
+			return fmt.Errorf("scenario %s: load: %w", s.Name, err)
+		}
+	}
+	if s.Injector != "" {
+		if _, ok := LookupInjector(s.Injector); !ok {
+			return fmt.Errorf("scenario %s: injector %s is not registered", s.Name, s.Injector)
+		}
+	}
+	return nil
+}
+
+// Registry holds the configured scenarios by name
+type Registry struct {
+	scenarios map[string]Scenario
+	preset    Preset
+	selection Selection
+}
+
+// DefaultScenarios returns the built-in rates of the example scenarios,
+// plus a scenario for every registered FaultInjector
+func DefaultScenarios() *Registry {
+	r := &Registry{scenarios: make(map[string]Scenario)}
+	for _, s := range []Scenario{
+		{Name: "RandomFailure", Class: "random", FailureRate: 0.3, Message: "Random failure"},
+		{Name: "TimingDependent", Class: "timing", Latency: &Latency{Min: Duration(time.Millisecond), Max: Duration(5 * time.Millisecond), Step: Duration(time.Millisecond)},
+			Timeout: Duration(4 * time.Millisecond), Message: "Operation too slow"},
+		// The rates of BoundaryCondition and MapIteration are those of their
+		// tests' draws, which presets and overrides do not change
+		{Name: "BoundaryCondition", Class: "boundary", FailureRate: 0.4},
+		{Name: "MapIteration", Class: "random", FailureRate: 2.0 / 3},
+		{Name: "OrderDependency", Class: "order", FailureRate: 0.5, Message: "Expected empty cache"},
+		{Name: "ConcurrentAccess", Class: "concurrency", FailureRate: 0.5, Message: "Resource is locked by another process"},
+		{Name: "NetworkSimulation", Class: "network", FailureRate: 0.2, Message: "Network request failed"},
+		{Name: "ChannelRace", Class: "concurrency", FailureRate: 0.5, Message: "Channel receive timeout - no value sent"},
+		{Name: "UnbufferedChannelSend", Class: "concurrency", FailureRate: 0.5, Message: "Value dropped: no receiver ready on unbuffered channel"},
+		{Name: "BurstyFailure", Class: "random", FailureRate: 0.05, FailAfterFail: 0.8, Message: "Node still unhealthy"},
+		{Name: "DriftingFailure", Class: "random", FailureRate: 0.02, Drift: &Drift{Type: "ramp", To: 0.4, Runs: 100},
+			Message: "Dependency degraded"},
+		{Name: "SharedDatabase", Class: "network", FailureRate: 0.02, Incident: &Incident{Chance: 0.1, FailureRate: 0.9},
+			Message: "Database unreachable"},
+		{Name: "SharedCache", Class: "network", FailureRate: 0.02, Incident: &Incident{Chance: 0.1, FailureRate: 0.8},
+			Message: "Cache unreachable"},
+		{Name: "MidnightRollover", Class: "clock", FailureRate: 0.1, Message: "Batch finished on a different day"},
+		{Name: "EndOfMonth", Class: "clock", FailureRate: 0.1, TripRate: 5.0 / 12, Message: "Next month skipped"},
+		{Name: "DSTTransition", Class: "clock", FailureRate: 0.1, Message: "A day is not 24 hours"},
+		{Name: "LeapSecond", Class: "clock", FailureRate: 0.1, Message: "Elapsed time went backwards"},
+		{Name: "GoroutineLeak", Class: "leak", FailureRate: 0.3, Message: "Request timed out; worker abandoned"},
+		{Name: "EnvLeak", Class: "leak", FailureRate: 0.3, Message: "Region override not restored"},
+		{Name: "TempFileLeak", Class: "leak", FailureRate: 0.3, Message: "Upload aborted before cleanup"},
+		{Name: "DiskFull", Class: "io", FailureRate: 0.2, Message: "Config corrupted by a failed save"},
+		{Name: "CacheUnwritable", Class: "io", FailureRate: 0.2, Message: "Result lost to an unwritable cache"},
+		{Name: "ReadAfterWrite", Class: "io", FailureRate: 0.3, Latency: &Latency{Min: Duration(10 * time.Millisecond), Max: Duration(100 * time.Millisecond)},
+			Message: "Read did not see the write"},
+		{Name: "LostUpdate", Class: "io", FailureRate: 0.3, Message: "Increment lost to a stale read"},
+		{Name: "DuplicateDelivery", Class: "io", FailureRate: 0.2, Message: "Payment charged twice"},
+		{Name: "PartialRead", Class: "network", FailureRate: 0.2, Message: "Frame parsed from a partial read"},
+		{Name: "RateLimit", Class: "network", FailureRate: 0.1, Message: "Request throttled with 429 Too Many Requests"},
+		{Name: "ContextCancellation", Class: "timing", FailureRate: 0.2, Latency: &Latency{Max: Duration(5 * time.Millisecond)},
+			Message: "Order saved twice after a late cancellation"},
+		{Name: "Preemption", Class: "process", FailureRate: 0.2, Latency: &Latency{Max: Duration(9 * time.Millisecond)},
+			Message: "Progress lost to a preemption"},
+		{Name: "TableDriven", Class: "order", FailureRate: 0.1, Cases: map[string]float64{"lowercase": 0, "title": 0.2, "shouting": 0.4},
+			Message: "Slug lowercased under a locale left over from another case"},
+		{Name: "ParallelSharedConfig", Class: "concurrency", FailureRate: 0.5, Message: "Shared config overwritten by a parallel test"},
+		{Name: "ParallelSharedCounter", Class: "concurrency", FailureRate: 0.5, Message: "Shared counter changed by a parallel test"},
+		{Name: "FlakyParser", Class: "environment", FailureRate: 0.3, Message: "Quoted comma split by the fast path"},
+		{Name: "ClockSkew", Class: "clock", FailureRate: 0.3, Message: "Token rejected across skewed clocks"},
+		{Name: "DataRace", Class: "concurrency", FailureRate: 0.5, Message: "Lost update"},
+		{Name: "MemoryVisibility", Class: "concurrency", FailureRate: 0.5, Latency: &Latency{Max: Duration(2 * time.Millisecond)},
+			Message: "Config read before it was filled in"},
+		{Name: "MemoryPressure", Class: "resource", FailureRate: 0.2, Timeout: Duration(100 * time.Millisecond),
+			Message: "Request missed its latency budget under GC pressure"},
+		{Name: "CPUThrottling", Class: "resource", FailureRate: 0.2, Timeout: Duration(5 * time.Millisecond),
+			Load: &Load{MinLevel: 1, MaxLevel: 2}, Message: "Heartbeat missed under CPU contention"},
+		{Name: "DeadlockSimulation", Class: "concurrency", FailureRate: 0.2, Message: "Transfers deadlocked"},
+		{Name: "Panic", Class: "process", FailureRate: 0.2},
+		{Name: "Goexit", Class: "process", FailureRate: 0.2, Message: "Giving up on this request"},
+		{Name: "ProcessExit", Class: "process", FailureRate: 0.2, Message: "Config value missing"},
+	} {
+		r.scenarios[s.Name] = s
+	}
+	for _, name := range InjectorNames() {
+		if f, ok := LookupInjector(name); ok {
+			r.scenarios[name] = Scenario{Name: name, Injector: name, Message: f.Describe()}
+		}
+	}
+	return r
+}
+
+// Get returns the named scenario
+func (r *Registry) Get(name string) (Scenario, bool) {
+	s, ok := r.scenarios[name]
+	return s, ok
+}
+
+// Preset returns the name of the preset applied to the scenarios, empty
+// when they use their configured numbers as they are
+func (r *Registry) Preset() string {
+	return r.preset.Name
+}
+
+// Selection returns the selection gating the scenarios
+func (r *Registry) Selection() Selection {
+	return r.selection
+}
+
+// DisabledBy explains why the named scenario is disabled, empty when it runs
+func (r *Registry) DisabledBy(name string) string {
+	s, ok := r.scenarios[name]
+	switch {
+	case !ok || !s.Disabled:
+		return ""
+	case !r.preset.Enables(s.Class):
+		return fmt.Sprintf("class %s is disabled by profile %s", s.Class, r.preset.Name)
+	default:
+		return "left out by the scenario selection"
+	}
+}
+
+// applyPreset scales every scenario to p
+func (r *Registry) applyPreset(p Preset) {
+	for name, s := range r.scenarios {
+		r.scenarios[name] = p.apply(s)
+	}
+	r.preset = p
+	r.gate()
+}
+
+// applySelection gates every scenario by sel
+func (r *Registry) applySelection(sel Selection) {
+	r.selection = sel
+	r.gate()
+}
+
+// gate disables the scenarios the preset or the selection leaves out
+func (r *Registry) gate() {
+	for name, s := range r.scenarios {
+		s.Disabled = !r.enables(s)
+		r.scenarios[name] = s
+	}
+}
+
+func (r *Registry) enables(s Scenario) bool {
+	return r.preset.Enables(s.Class) && r.selection.Selects(s)
+}
+
+// Names returns the scenario names in sorted order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.scenarios))
+	for name := range r.scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FailureRates returns every scenario's effective failure rate by name
+func (r *Registry) FailureRates() map[string]float64 {
+	rates := make(map[string]float64, len(r.scenarios))
+	for name, s := range r.scenarios {
+		rates[name] = s.EffectiveFailureRate()
+	}
+	return rates
+}
+
+// LoadScenarios reads a YAML or JSON scenario file on top of the defaults
+// Fields a file entry leaves out keep their default values; entries with new
+// names add scenarios
+// A top-level profile names a Preset applied to the defaults before the
+// entries, so their numbers are used as written, and select gates the
+// scenarios that run
+//
+//	profile: ci-light
+//	select:
+//	  include: [network, timing]
+//	  exclude: [TimingDependent]
+//	scenarios:
+//	  - name: NetworkSimulation
+//	    failure_rate: 0.05
+//	  - name: TimingDependent
+//	    latency: {min: 1ms, max: 10ms}
+//	    timeout: 8ms
+func LoadScenarios(path string) (*Registry, error) {
+	return loadScenarios(path, "", "")
+}
+
+// ScenariosFromEnv loads FLAKY_CONFIG, or flaky.yaml if present, or the
+// defaults, under the preset FLAKY_PROFILE names and the selection of
+// FLAKY_SCENARIOS if they are set, then applies the overrides of FLAKY_SET
+func ScenariosFromEnv() (*Registry, error) {
+	path := os.Getenv(ConfigEnv)
+	if path == "" {
+		if _, err := os.Stat(DefaultConfigFile); err == nil {
+			path = DefaultConfigFile
+		}
+	}
+	r, err := loadScenarios(path, os.Getenv(ProfileEnv), os.Getenv(ScenariosEnv))
+	if err != nil {
+		return nil, err
+	}
+	if set := os.Getenv(SetEnv); set != "" {
+		overrides, err := ParseOverrides(set)
+		if err == nil {
+			err = r.Set(overrides...)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", SetEnv, err)
+		}
+	}
+	return r, nil
+}
+
+// configFile is a parsed scenario file whose entries are decoded later, onto
+// the scenarios they override
+type configFile struct {
+	profile   string
+	selection Selection
+	entries   []func(*Scenario) error
+}
+
+// loadScenarios reads the file at path, if any, on top of the defaults
+// A non-empty preset or selection list replaces the file's profile or select
+func loadScenarios(path, preset, selection string) (*Registry, error) {
+	var file configFile
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if file, err = parseConfig(data, strings.EqualFold(filepath.Ext(path), ".json")); err != nil {
+			return nil, fmt.Errorf("load %s: %w", path, err)
+		}
+	}
+	if preset != "" {
+		if _, err := presetByName(preset); err != nil {
+			return nil, fmt.Errorf("%s: %w", ProfileEnv, err)
+		}
+		file.profile = preset
+	}
+	if selection != "" {
+		sel, err := ParseSelection(selection)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ScenariosEnv, err)
+		}
+		file.selection = sel
+	}
+
+	r := DefaultScenarios()
+	if err := r.merge(file); err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// parseConfig decodes the profile and select of a file and prepares a
+// decoder per entry
+func parseConfig(data []byte, isJSON bool) (configFile, error) {
+	var file configFile
+	if isJSON {
+		var raw struct {
+			Profile   string            `json:"profile"`
+			Select    Selection         `json:"select"`
+			Scenarios []json.RawMessage `json:"scenarios"`
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return file, err
+		}
+		file.profile, file.selection = raw.Profile, raw.Select
+		for _, entry := range raw.Scenarios {
+			entry := entry
+			file.entries = append(file.entries, func(s *Scenario) error { return json.Unmarshal(entry, s) })
+		}
+	} else {
+		var raw struct {
+			Profile   string      `yaml:"profile"`
+			Select    Selection   `yaml:"select"`
+			Scenarios []yaml.Node `yaml:"scenarios"`
+		}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return file, err
+		}
+		file.profile, file.selection = raw.Profile, raw.Select
+		for i := range raw.Scenarios {
+			node := &raw.Scenarios[i]
+			file.entries = append(file.entries, func(s *Scenario) error { return node.Decode(s) })
+		}
+	}
+	return file, file.selection.validate()
+}
+
+// merge applies the file's profile and select, then decodes each entry onto
+// a copy of the existing scenario of that name
+func (r *Registry) merge(file configFile) error {
+	if file.profile != "" {
+		p, err := presetByName(file.profile)
+		if err != nil {
+			return err
+		}
+		r.applyPreset(p)
+	}
+	r.applySelection(file.selection)
+	return r.mergeEntries(file.entries)
+}
+
+// mergeEntries decodes each entry onto a copy of the existing scenario of
+// its name, or a new one
+func (r *Registry) mergeEntries(entries []func(*Scenario) error) error {
+	for _, decode := range entries {
+		var probe Scenario
+		if err := decode(&probe); err != nil {
+			return err
+		}
+
+		s := r.scenarios[probe.Name]
+		if s.Latency != nil {
+			latency := *s.Latency
+			s.Latency = &latency
+		}
+		if s.Drift != nil {
+			drift := *s.Drift
+			s.Drift = &drift
+		}
+		if s.Incident != nil {
+			incident := *s.Incident
+			s.Incident = &incident
+		}
+		if s.Load != nil {
+			load := *s.Load
+			s.Load = &load
+		}
+		s.Cases = maps.Clone(s.Cases)
+		if err := decode(&s); err != nil {
+			return err
+		}
+		s.Disabled = !r.enables(s)
+		if err := s.validate(); err != nil {
+			return err
+		}
+		r.scenarios[s.Name] = s
+	}
+	return nil
+}
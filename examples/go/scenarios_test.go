@@ -0,0 +1,187 @@
+package flaky
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadScenariosYAMLOverridesDefaults(t *testing.T) {
+	path := writeConfig(t, "flaky.yaml", `
+scenarios:
+  - name: NetworkSimulation
+    failure_rate: 0.05
+  - name: TimingDependent
+    latency: {min: 2ms, max: 10ms}
+    timeout: 8ms
+  - name: CheckoutTimeout
+    failure_rate: 0.1
+    message: Checkout timed out
+`)
+	r, err := LoadScenarios(path)
+	if err != nil {
+		t.Fatalf("LoadScenarios failed: %v", err)
+	}
+
+	network, _ := r.Get("NetworkSimulation")
+	if network.FailureRate != 0.05 || network.Message != "Network request failed" {
+		t.Errorf("Expected overridden rate with default message, got %+v", network)
+	}
+
+	timing, _ := r.Get("TimingDependent")
+	if time.Duration(timing.Latency.Min) != 2*time.Millisecond || time.Duration(timing.Timeout) != 8*time.Millisecond {
+		t.Errorf("Unexpected timing scenario: %+v %+v", timing, timing.Latency)
+	}
+	// The default 1ms step is kept, so 9 and 10ms of 2ms to 10ms time out
+	if rate := timing.EffectiveFailureRate(); math.Abs(rate-2.0/9) > 1e-9 {
+		t.Errorf("Expected effective timing failure rate 2/9, got %v", rate)
+	}
+
+	if custom, ok := r.Get("CheckoutTimeout"); !ok || custom.FailureRate != 0.1 {
+		t.Errorf("Expected new scenario CheckoutTimeout, got %+v, %v", custom, ok)
+	}
+	if random, _ := r.Get("RandomFailure"); random.FailureRate != 0.3 {
+		t.Errorf("Expected untouched default rate 0.3, got %v", random.FailureRate)
+	}
+
+	defaults, _ := DefaultScenarios().Get("TimingDependent")
+	if time.Duration(defaults.Latency.Min) != time.Millisecond {
+		t.Error("Loading a config mutated the default latency")
+	}
+}
+
+func TestLoadScenariosJSON(t *testing.T) {
+	path := writeConfig(t, "flaky.json", `{"scenarios": [
+		{"name": "ConcurrentAccess", "failure_rate": 0},
+		{"name": "TimingDependent", "latency": {"max": "3ms"}}
+	]}`)
+	r, err := LoadScenarios(path)
+	if err != nil {
+		t.Fatalf("LoadScenarios failed: %v", err)
+	}
+	if s, _ := r.Get("ConcurrentAccess"); s.FailureRate != 0 {
+		t.Errorf("Expected ConcurrentAccess rate 0, got %v", s.FailureRate)
+	}
+	timing, _ := r.Get("TimingDependent")
+	if time.Duration(timing.Latency.Min) != time.Millisecond || time.Duration(timing.Latency.Max) != 3*time.Millisecond {
+		t.Errorf("Expected latency 1ms-3ms, got %+v", timing.Latency)
+	}
+	if rate := timing.EffectiveFailureRate(); rate != 0 {
+		t.Errorf("Expected no timeouts with max below the timeout, got %v", rate)
+	}
+}
+
+func TestLoadScenariosLatencyDistribution(t *testing.T) {
+	path := writeConfig(t, "flaky.yaml", `
+scenarios:
+  - name: TimingDependent
+    latency: {type: lognormal, median: 2ms, sigma: 0.5}
+    timeout: 4ms
+`)
+	r, err := LoadScenarios(path)
+	if err != nil {
+		t.Fatalf("LoadScenarios failed: %v", err)
+	}
+	timing, _ := r.Get("TimingDependent")
+	d := timing.LatencyDistribution()
+	if d.String() != "lognormal(median 2ms, sigma 0.5)" {
+		t.Errorf("Expected a lognormal latency, got %v", d)
+	}
+	// ln 2 / 0.5 = 1.386 standard deviations above the median
+	if rate := timing.EffectiveFailureRate(); math.Abs(rate-0.0829) > 0.001 {
+		t.Errorf("Expected about 8.3%% of draws above the timeout, got %v", rate)
+	}
+	if meta := timing.Meta(nil); meta.Params["latency"] != d.String() {
+		t.Errorf("Expected the distribution in the failure metadata, got %v", meta.Params)
+	}
+}
+
+func TestLoadScenariosCases(t *testing.T) {
+	r, err := LoadScenarios(writeConfig(t, "cases.yaml", "scenarios:\n  - name: TableDriven\n    cases:\n      title: 0.9\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := r.Get("TableDriven")
+	if c := s.Case("title"); c.Name != "TableDriven/title" || c.FailureRate != 0.9 {
+		t.Errorf("Expected TableDriven/title at 0.9, got %s at %v", c.Name, c.FailureRate)
+	}
+	if c := s.Case("shouting"); c.FailureRate != 0.4 {
+		t.Errorf("Expected the default rate 0.4 of the cases left alone, got %v", c.FailureRate)
+	}
+	if c := s.Case("unlisted"); c.FailureRate != s.FailureRate || c.Cases != nil {
+		t.Errorf("Expected an unlisted case at the scenario rate %v, got %+v", s.FailureRate, c)
+	}
+	if rate := s.EffectiveFailureRate(); math.Abs(rate-(1-0.1*0.6)) > 1e-9 {
+		t.Errorf("Expected the chance any case fails, got %v", rate)
+	}
+	if d, _ := DefaultScenarios().Get("TableDriven"); d.Cases["title"] != 0.2 {
+		t.Errorf("Expected the defaults untouched by the override, got %v", d.Cases)
+	}
+}
+
+func TestLoadScenariosRejectsInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"rate.yaml":     "scenarios:\n  - name: RandomFailure\n    failure_rate: 1.5\n",
+		"latency.yaml":  "scenarios:\n  - name: TimingDependent\n    latency: {min: 5ms, max: 1ms}\n",
+		"duration.yaml": "scenarios:\n  - name: TimingDependent\n    timeout: soon\n",
+		"noname.yaml":   "scenarios:\n  - failure_rate: 0.1\n",
+		"bursty.yaml":   "scenarios:\n  - name: BurstyFailure\n    fail_after_fail: 2\n",
+		"type.yaml":     "scenarios:\n  - name: TimingDependent\n    latency: {type: gamma}\n",
+		"sigma.yaml":    "scenarios:\n  - name: TimingDependent\n    latency: {type: lognormal, median: 2ms, sigma: -1}\n",
+		"cases.yaml":    "scenarios:\n  - name: TableDriven\n    cases: {title: -0.1}\n",
+		"load.yaml":     "scenarios:\n  - name: CPUThrottling\n    load: {min_level: 2, max_level: 1}\n",
+	} {
+		if _, err := LoadScenarios(writeConfig(t, name, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestScenariosFromEnv(t *testing.T) {
+	t.Setenv(ConfigEnv, writeConfig(t, "custom.yaml", "scenarios:\n  - name: RandomFailure\n    failure_rate: 0.9\n"))
+	r, err := ScenariosFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := r.Get("RandomFailure"); s.FailureRate != 0.9 {
+		t.Errorf("Expected FLAKY_CONFIG override 0.9, got %v", s.FailureRate)
+	}
+
+	t.Setenv(ConfigEnv, "")
+	if r, err := ScenariosFromEnv(); err != nil || len(r.Names()) != len(DefaultScenarios().Names()) {
+		t.Errorf("Expected defaults without FLAKY_CONFIG, got %v, %v", r, err)
+	}
+}
+
+// TestMergeLeavesEarlierCopiesAlone verifies an override of a nested field
+// decodes into a copy, so scenarios taken from the registry before it keep
+// their values
+func TestMergeLeavesEarlierCopiesAlone(t *testing.T) {
+	r := DefaultScenarios()
+	throttling, _ := r.Get("CPUThrottling")
+	timing, _ := r.Get("TimingDependent")
+	load, latency := *throttling.Load, *timing.Latency
+	if err := r.Set(
+		Override{Target: "CPUThrottling", Field: "load.max_level", Value: "5"},
+		Override{Target: "TimingDependent", Field: "latency.max", Value: "8ms"},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if *throttling.Load != load || *timing.Latency != latency {
+		t.Errorf("Expected earlier copies to keep load %+v and latency %+v, got %+v and %+v", load, latency, *throttling.Load, *timing.Latency)
+	}
+	if s, _ := r.Get("CPUThrottling"); s.Load.MaxLevel != 5 {
+		t.Errorf("Expected the override to apply, got load %+v", *s.Load)
+	}
+}
@@ -0,0 +1,24 @@
+package flaky
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/example/flaky-test-example/internal/protocol"
+)
+
+// SeedEnv is the environment variable the flaky test detector sets per run
+const SeedEnv = protocol.SeedEnv
+
+// DefaultSeed is used when SeedEnv is unset or invalid
+const DefaultSeed int64 = 42
+
+// SeedFromEnv returns the seed from GO_TEST_SEED, or DefaultSeed
+func SeedFromEnv() int64 {
+	if seedStr := os.Getenv(SeedEnv); seedStr != "" {
+		if seed, err := strconv.ParseInt(seedStr, 10, 64); err == nil {
+			return seed
+		}
+	}
+	return DefaultSeed
+}
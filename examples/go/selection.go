@@ -0,0 +1,79 @@
+package flaky
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/example/flaky-test-example/internal/protocol"
+)
+
+// ScenariosEnv selects the scenarios that run, overriding the select of the
+// config file: a comma-separated list of patterns to include, where a
+// leading ! excludes instead, such as network,timing or !clock
+const ScenariosEnv = protocol.ScenariosEnv
+
+// Selection gates which scenarios run by glob patterns matched against
+// their name or class, such as "network" or "Parallel*"
+// A scenario runs when no Exclude pattern matches it and Include is empty
+// or one of its patterns matches
+type Selection struct {
+	Include []string `json:"include,omitempty" yaml:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+}
+
+// ParseSelection parses a FLAKY_SCENARIOS list
+func ParseSelection(list string) (Selection, error) {
+	var sel Selection
+	for _, pattern := range strings.Split(list, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if exclude, ok := strings.CutPrefix(pattern, "!"); ok {
+			sel.Exclude = append(sel.Exclude, exclude)
+		} else if pattern != "" {
+			sel.Include = append(sel.Include, pattern)
+		}
+	}
+	return sel, sel.validate()
+}
+
+// Selects reports whether s runs under the selection
+func (sel Selection) Selects(s Scenario) bool {
+	for _, pattern := range sel.Exclude {
+		if matchScenario(pattern, s) {
+			return false
+		}
+	}
+	if len(sel.Include) == 0 {
+		return true
+	}
+	for _, pattern := range sel.Include {
+		if matchScenario(pattern, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchScenario reports whether pattern matches the name or class of s;
+// patterns are validated when the selection is loaded
+func matchScenario(pattern string, s Scenario) bool {
+	if ok, _ := path.Match(pattern, s.Name); ok {
+		return true
+	}
+	ok, _ := path.Match(pattern, s.Class)
+	return ok && s.Class != ""
+}
+
+func (sel Selection) validate() error {
+	for _, pattern := range slices.Concat(sel.Include, sel.Exclude) {
+		if pattern == "" {
+			return errors.New("empty scenario pattern")
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("scenario pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package flaky
+
+import "testing"
+
+func TestParseSelection(t *testing.T) {
+	sel, err := ParseSelection("network, timing,!TimingDependent,,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sel.Include) != 2 || sel.Include[1] != "timing" || len(sel.Exclude) != 1 || sel.Exclude[0] != "TimingDependent" {
+		t.Errorf("Expected two includes and one exclude, got %+v", sel)
+	}
+	for _, list := range []string{"net[work", "!"} {
+		if _, err := ParseSelection(list); err == nil {
+			t.Errorf("Expected %q to be rejected", list)
+		}
+	}
+}
+
+func TestSelectionSelects(t *testing.T) {
+	network := Scenario{Name: "NetworkSimulation", Class: "network"}
+	parallel := Scenario{Name: "ParallelSharedConfig", Class: "concurrency"}
+	unclassed := Scenario{Name: "SlowDisk"}
+	for _, tc := range []struct {
+		sel  Selection
+		s    Scenario
+		want bool
+	}{
+		{Selection{}, network, true},
+		{Selection{Include: []string{"network"}}, network, true},
+		{Selection{Include: []string{"network"}}, parallel, false},
+		{Selection{Include: []string{"Parallel*"}}, parallel, true},
+		{Selection{Include: []string{"*"}, Exclude: []string{"concurrency"}}, parallel, false},
+		{Selection{Exclude: []string{"concurrency"}}, network, true},
+		{Selection{Include: []string{"*"}}, unclassed, true},
+		{Selection{Include: []string{"network"}}, unclassed, false},
+	} {
+		if got := tc.sel.Selects(tc.s); got != tc.want {
+			t.Errorf("Expected %+v to select %s: %v, got %v", tc.sel, tc.s.Name, tc.want, got)
+		}
+	}
+}
+
+func TestLoadScenariosSelect(t *testing.T) {
+	path := writeConfig(t, "flaky.yaml", `
+profile: ci-light
+select:
+  include: [network, timing, DiskFull]
+  exclude: [ContextCancellation]
+`)
+	r, err := LoadScenarios(path)
+	if err != nil {
+		t.Fatalf("LoadScenarios failed: %v", err)
+	}
+	for name, disabled := range map[string]bool{"NetworkSimulation": false, "TimingDependent": false, "ContextCancellation": true, "ChannelRace": true, "DiskFull": true} {
+		if s, _ := r.Get(name); s.Disabled != disabled {
+			t.Errorf("Expected %s disabled=%v, got %v", name, disabled, s.Disabled)
+		}
+	}
+	if got := r.DisabledBy("DiskFull"); got != "class io is disabled by profile ci-light" {
+		t.Errorf("Expected the preset to explain DiskFull, got %q", got)
+	}
+	if got := r.DisabledBy("ChannelRace"); got != "left out by the scenario selection" {
+		t.Errorf("Expected the selection to explain ChannelRace, got %q", got)
+	}
+	if got := r.DisabledBy("NetworkSimulation"); got != "" {
+		t.Errorf("Expected no reason for an enabled scenario, got %q", got)
+	}
+
+	t.Setenv(ConfigEnv, path)
+	t.Setenv(ScenariosEnv, "!timing")
+	r, err = ScenariosFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := r.Get("ChannelRace"); s.Disabled {
+		t.Error("Expected FLAKY_SCENARIOS to replace the file's select")
+	}
+	if s, _ := r.Get("TimingDependent"); !s.Disabled {
+		t.Error("Expected FLAKY_SCENARIOS to exclude the timing class")
+	}
+
+	t.Setenv(ScenariosEnv, "net[work")
+	if _, err := ScenariosFromEnv(); err == nil {
+		t.Error("Expected a malformed FLAKY_SCENARIOS pattern to be rejected")
+	}
+	if _, err := LoadScenarios(writeConfig(t, "bad.yaml", "select:\n  exclude: ['[']\n")); err == nil {
+		t.Error("Expected a malformed select pattern to be rejected")
+	}
+}
+
+// TestSelectionDisablesDrawRateDemos verifies the demos whose rate comes from
+// their own draw are in the registry, so a selection turns them off
+func TestSelectionDisablesDrawRateDemos(t *testing.T) {
+	r, err := loadScenarios("", "", "!boundary,!MapIteration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"BoundaryCondition", "MapIteration"} {
+		if s, ok := r.Get(name); !ok || !s.Disabled {
+			t.Errorf("Expected %s to be registered and left out, got %+v", name, s)
+		}
+	}
+}
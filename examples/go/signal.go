@@ -0,0 +1,168 @@
+package flaky
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/clock"
+	"github.com/example/flaky-test-example/distributions"
+)
+
+// selfSignalTimeout bounds how long delivery to the test process waits for
+// the signal to arrive
+const selfSignalTimeout = 5 * time.Second
+
+// PreemptOption configures Preempt
+type PreemptOption func(*preemptConfig)
+
+type preemptConfig struct {
+	process *os.Process
+	clock   clock.Clock
+}
+
+// WithPreemptProcess signals p, such as a child process under test, instead
+// of the test process
+func WithPreemptProcess(p *os.Process) PreemptOption {
+	return func(c *preemptConfig) {
+		c.process = p
+	}
+}
+
+// WithPreemptClock times the signal on c instead of the real clock
+// With a clock.FakeClock the signal is delivered by the Sleep or Advance
+// that reaches the drawn point, before it returns
+func WithPreemptClock(c clock.Clock) PreemptOption {
+	return func(cfg *preemptConfig) {
+		cfg.clock = c
+	}
+}
+
+// Preemption is a signal scheduled for delivery at a seeded point, as a spot
+// or serverless host sends SIGTERM before reclaiming a worker
+type Preemption struct {
+	Signal os.Signal
+	// After is the drawn delay from Preempt to the signal
+	After time.Duration
+
+	timer     clock.Timer
+	delivered chan struct{}
+	mu        sync.Mutex
+	err       error
+	// stopped is set once Stop cancelled the signal before it was sent
+	stopped bool
+}
+
+// Preempt sends sig to the test process, or the process of
+// WithPreemptProcess, a delay drawn from after from now
+// Signalling the test process keeps it alive: sig is caught until t finishes,
+// so it ends the test binary only if the code under test asks for that
+// itself; delivery returns once every channel registered with signal.Notify
+// for sig has been sent it
+// The signal is cancelled if t finishes first, and a failed delivery fails t
+func (i *Injector) Preempt(t testing.TB, sig os.Signal, after distributions.Distribution, opts ...PreemptOption) *Preemption {
+	t.Helper()
+	cfg := preemptConfig{clock: clock.Real()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := after.Validate(); err != nil {
+		t.Fatalf("flaky: preempt delay: %v", err)
+	}
+
+	p := &Preemption{Signal: sig, After: i.Draw(after), delivered: make(chan struct{})}
+	var caught chan os.Signal
+	if cfg.process == nil {
+		caught = make(chan os.Signal, 1)
+		signal.Notify(caught, sig)
+	}
+	t.Cleanup(func() {
+		// A signal whose timer already fired may still be on its way; with
+		// caught stopped first, the default action of sig would end the
+		// whole test binary
+		if !p.Stop() && !p.cancelled() {
+			<-p.delivered
+		}
+		if caught != nil {
+			signal.Stop(caught)
+		}
+		if err := p.Err(); err != nil {
+			t.Errorf("%v", err)
+		}
+	})
+	p.timer = cfg.clock.AfterFunc(p.After, func() {
+		p.deliver(cfg.process, caught)
+	})
+	return p
+}
+
+// deliver sends the signal, waiting until caught receives it when signalling
+// the test process
+func (p *Preemption) deliver(process *os.Process, caught chan os.Signal) {
+	defer close(p.delivered)
+	target := process
+	if target == nil {
+		self, err := os.FindProcess(os.Getpid())
+		if err != nil {
+			p.fail(err)
+			return
+		}
+		target = self
+	}
+	if err := target.Signal(p.Signal); err != nil {
+		p.fail(err)
+		return
+	}
+	if caught == nil {
+		return
+	}
+	select {
+	case <-caught:
+	case <-time.After(selfSignalTimeout):
+		p.fail(errors.New("signal not received"))
+		return
+	}
+	// signal.Notify takes the lock the runtime holds while it fans a signal
+	// out, so once it returns every other registered channel has been sent it
+	signal.Notify(caught, p.Signal)
+}
+
+func (p *Preemption) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = fmt.Errorf("flaky: preempt with %v after %v: %w", p.Signal, p.After, err)
+}
+
+// Delivered is closed once the signal has been sent, or failed to be
+func (p *Preemption) Delivered() <-chan struct{} {
+	return p.delivered
+}
+
+// Err returns the error delivering the signal, if any
+func (p *Preemption) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// Stop cancels the signal, reporting whether it had not been sent yet
+func (p *Preemption) Stop() bool {
+	if !p.timer.Stop() {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = true
+	return true
+}
+
+// cancelled reports whether Stop cancelled the signal
+func (p *Preemption) cancelled() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stopped
+}
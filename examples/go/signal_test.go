@@ -0,0 +1,123 @@
+package flaky
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/clock"
+	"github.com/example/flaky-test-example/distributions"
+)
+
+func requireSignals(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows cannot send SIGTERM")
+	}
+}
+
+func TestPreemptOnFakeClock(t *testing.T) {
+	requireSignals(t)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	clk := clock.NewFake(time.Unix(0, 0))
+	p := NewInjector(WithSeed(1)).Preempt(t, syscall.SIGTERM, distributions.Constant(5*time.Millisecond), WithPreemptClock(clk))
+
+	clk.Sleep(4 * time.Millisecond)
+	select {
+	case <-sigs:
+		t.Fatal("Signal delivered early")
+	default:
+	}
+	clk.Sleep(time.Millisecond)
+	select {
+	case <-sigs:
+	default:
+		t.Fatal("Expected the signal by the time Sleep returned")
+	}
+	<-p.Delivered()
+	if p.After != 5*time.Millisecond || p.Err() != nil {
+		t.Errorf("Expected a delivery after 5ms, got %v (%v)", p.After, p.Err())
+	}
+}
+
+func TestPreemptIsSeeded(t *testing.T) {
+	requireSignals(t)
+	after := distributions.Uniform{Max: time.Second}
+	clk := clock.NewFake(time.Unix(0, 0))
+	a := NewInjector(WithSeed(3)).Preempt(t, syscall.SIGTERM, after, WithPreemptClock(clk))
+	b := NewInjector(WithSeed(3)).Preempt(t, syscall.SIGTERM, after, WithPreemptClock(clk))
+	if a.After != b.After {
+		t.Errorf("Expected one delay for one seed, got %v and %v", a.After, b.After)
+	}
+	if !a.Stop() || !b.Stop() || clk.Pending() != 0 {
+		t.Error("Expected Stop to cancel both pending signals")
+	}
+}
+
+func TestPreemptCleanupWaitsForDelivery(t *testing.T) {
+	requireSignals(t)
+	// Each subtest ends while its signal may be on its way; were caught
+	// stopped before delivery, SIGTERM would end the test binary
+	for i := 0; i < 200; i++ {
+		t.Run("", func(t *testing.T) {
+			NewInjector(WithSeed(int64(i))).Preempt(t, syscall.SIGTERM, distributions.Uniform{Max: 100 * time.Microsecond})
+		})
+	}
+}
+
+// TestPreemptHelper only runs inside TestPreemptChildProcess's subprocess
+// It drains its work and exits cleanly on SIGTERM
+func TestPreemptHelper(t *testing.T) {
+	if os.Getenv("FLAKY_PREEMPT_HELPER") != "1" {
+		t.Skip("helper for TestPreemptChildProcess")
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM)
+	os.Stdout.WriteString("ready\n")
+	select {
+	case <-sigs:
+		os.Stdout.WriteString("drained\n")
+	case <-time.After(10 * time.Second):
+		t.Fatal("no SIGTERM")
+	}
+}
+
+func TestPreemptChildProcess(t *testing.T) {
+	requireSignals(t)
+	cmd := exec.Command(os.Args[0], "-test.run=^TestPreemptHelper$")
+	cmd.Env = append(os.Environ(), "FLAKY_PREEMPT_HELPER=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	lines := bufio.NewScanner(stdout)
+	if !lines.Scan() || lines.Text() != "ready" {
+		t.Fatalf("Expected the helper to start, got %q", lines.Text())
+	}
+
+	p := NewInjector(WithSeed(1)).Preempt(t, syscall.SIGTERM, distributions.Uniform{Max: 10 * time.Millisecond},
+		WithPreemptProcess(cmd.Process))
+	var rest strings.Builder
+	for lines.Scan() {
+		rest.WriteString(lines.Text() + "\n")
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("Expected the helper to exit cleanly, got %v:\n%s", err, rest.String())
+	}
+	<-p.Delivered()
+	if !strings.Contains(rest.String(), "drained") || p.Err() != nil {
+		t.Errorf("Expected the helper to drain on SIGTERM, got %q (%v)", rest.String(), p.Err())
+	}
+}
@@ -0,0 +1,59 @@
+package flaky
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/example/flaky-test-example/clock"
+)
+
+// SkewProfile configures the clocks SkewedClocks hands to simulated
+// components
+type SkewProfile struct {
+	// Unsynced is the probability that a component's clock is off at all
+	Unsynced float64
+	// MaxSkew bounds the uniformly drawn offset of an unsynced clock, either
+	// way from true time
+	MaxSkew time.Duration
+	// MaxDrift bounds the uniformly drawn rate an unsynced clock gains or
+	// loses, in seconds per second
+	MaxDrift float64
+}
+
+// Validate reports a rate outside [0, 1], a negative skew and a drift
+// outside [0, 1)
+func (p SkewProfile) Validate() error {
+	if p.Unsynced < 0 || p.Unsynced > 1 || math.IsNaN(p.Unsynced) {
+		return fmt.Errorf("flaky: unsynced rate %v outside [0, 1]", p.Unsynced)
+	}
+	if p.MaxSkew < 0 {
+		return fmt.Errorf("flaky: negative max skew %v", p.MaxSkew)
+	}
+	if p.MaxDrift < 0 || p.MaxDrift >= 1 || math.IsNaN(p.MaxDrift) {
+		return fmt.Errorf("flaky: max drift %v outside [0, 1)", p.MaxDrift)
+	}
+	return nil
+}
+
+// SkewedClocks returns n clocks reading ref, one per simulated component
+// Each is off with probability p.Unsynced, by a skew and a drift drawn from
+// the injector, so a seed reproduces which components disagree and by how
+// much; the others read ref exactly
+// It panics if the profile is invalid
+func (i *Injector) SkewedClocks(ref *clock.FakeClock, n int, p SkewProfile) []*clock.SkewedClock {
+	if err := p.Validate(); err != nil {
+		panic(err)
+	}
+	clocks := make([]*clock.SkewedClock, n)
+	for c := range clocks {
+		var skew time.Duration
+		var drift float64
+		if i.Float64() < p.Unsynced {
+			skew = time.Duration((2*i.Float64() - 1) * float64(p.MaxSkew))
+			drift = (2*i.Float64() - 1) * p.MaxDrift
+		}
+		clocks[c] = clock.NewSkewed(ref, skew, drift)
+	}
+	return clocks
+}
@@ -0,0 +1,51 @@
+package flaky
+
+import (
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/clock"
+)
+
+// TestSkewedClocksFollowProfile verifies clocks stay within the profile's
+// bounds, that synced ones read true time and that a seed reproduces them
+func TestSkewedClocksFollowProfile(t *testing.T) {
+	profile := SkewProfile{Unsynced: 0.5, MaxSkew: time.Second, MaxDrift: 0.01}
+	ref := clock.NewFake(time.Unix(0, 0))
+	clocks := NewInjector(WithSeed(7)).SkewedClocks(ref, 200, profile)
+	again := NewInjector(WithSeed(7)).SkewedClocks(ref, 200, profile)
+	ref.Advance(10 * time.Second)
+
+	synced := 0
+	for i, c := range clocks {
+		offset := c.Offset()
+		if offset == 0 {
+			synced++
+		}
+		if limit := profile.MaxSkew + 100*time.Millisecond; offset < -limit || offset > limit {
+			t.Errorf("Expected clock %d within %v of true time, got %v", i, limit, offset)
+		}
+		if got := again[i].Offset(); got != offset {
+			t.Errorf("Expected the same seed to skew clock %d by %v, got %v", i, offset, got)
+		}
+	}
+	if synced < 70 || synced > 130 {
+		t.Errorf("Expected about half of 200 clocks synced, got %d", synced)
+	}
+}
+
+// TestSkewProfileValidate verifies invalid profiles are rejected
+func TestSkewProfileValidate(t *testing.T) {
+	for _, p := range []SkewProfile{
+		{Unsynced: 1.5},
+		{Unsynced: 0.5, MaxSkew: -time.Second},
+		{Unsynced: 0.5, MaxDrift: 1},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", p)
+		}
+	}
+	if err := (SkewProfile{Unsynced: 1, MaxSkew: time.Second, MaxDrift: 0.5}).Validate(); err != nil {
+		t.Errorf("Expected a valid profile, got %v", err)
+	}
+}
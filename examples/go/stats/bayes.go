@@ -0,0 +1,64 @@
+package stats
+
+import "fmt"
+
+// Beta is a beta distribution over a flake rate, the conjugate prior of
+// pass/fail outcomes: Alpha counts failures and Beta passes, each plus
+// the prior's share
+type Beta struct {
+	Alpha, Beta float64
+}
+
+// Jeffreys is the uninformative Beta(0.5, 0.5) prior, what a test without
+// history starts from
+var Jeffreys = Beta{Alpha: 0.5, Beta: 0.5}
+
+// PriorFromHistory returns the prior a new sweep of a test starts from,
+// given its earlier outcomes: Jeffreys updated with them, then scaled down
+// to be worth at most weight runs
+// The scaling keeps the historical rate as the prior's mean, so history of
+// thousands of runs still informs a sweep rather than outvoting it; a
+// weight of zero or less ignores history
+func PriorFromHistory(passes, failures int, weight float64) Beta {
+	if weight <= 0 {
+		return Jeffreys
+	}
+	prior := Jeffreys.Update(passes, failures)
+	if n := prior.Alpha + prior.Beta; n > weight {
+		prior.Alpha *= weight / n
+		prior.Beta *= weight / n
+	}
+	return prior
+}
+
+// Update returns the posterior after observing the outcomes
+func (b Beta) Update(passes, failures int) Beta {
+	return Beta{Alpha: b.Alpha + float64(failures), Beta: b.Beta + float64(passes)}
+}
+
+// Weight returns how many runs the distribution is worth, Alpha + Beta
+func (b Beta) Weight() float64 {
+	return b.Alpha + b.Beta
+}
+
+// Mean returns the expected flake rate
+func (b Beta) Mean() float64 {
+	return b.Alpha / (b.Alpha + b.Beta)
+}
+
+// CredibleInterval returns the equal-tailed interval holding the flake rate
+// with probability confidence
+func (b Beta) CredibleInterval(confidence float64) Interval {
+	tail := (1 - confidence) / 2
+	return Interval{Lower: betaQuantile(tail, b.Alpha, b.Beta), Upper: betaQuantile(1-tail, b.Alpha, b.Beta)}
+}
+
+// ProbAbove returns the probability that the flake rate exceeds rate, such
+// as the tolerance a stable test stays under
+func (b Beta) ProbAbove(rate float64) float64 {
+	return 1 - regIncBeta(rate, b.Alpha, b.Beta)
+}
+
+func (b Beta) String() string {
+	return fmt.Sprintf("Beta(%.3g, %.3g)", b.Alpha, b.Beta)
+}
@@ -0,0 +1,411 @@
+// Package stats estimates flake rates with confidence intervals and
+// summarizes the spread of benchmark measurements
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Interval is a two-sided confidence interval for a rate
+type Interval struct {
+	Lower, Upper float64
+}
+
+// Width returns Upper - Lower
+func (i Interval) Width() float64 {
+	return i.Upper - i.Lower
+}
+
+func (i Interval) String() string {
+	return fmt.Sprintf("[%.3f, %.3f]", i.Lower, i.Upper)
+}
+
+// Estimate is a flake-rate estimate from a series of runs
+type Estimate struct {
+	Runs       int
+	Failures   int
+	Confidence float64
+	// Rate is the observed fraction of failing runs
+	Rate float64
+	// Wilson is the Wilson score interval, tight and well behaved for small samples
+	Wilson Interval
+	// ClopperPearson is the exact (conservative) binomial interval
+	ClopperPearson Interval
+}
+
+// EstimateFlakeRate estimates the failure rate of a test from its run
+// outcomes, where true means the run passed
+func EstimateFlakeRate(results []bool, confidence float64) Estimate {
+	failures := 0
+	for _, passed := range results {
+		if !passed {
+			failures++
+		}
+	}
+	return EstimateCounts(len(results)-failures, failures, confidence)
+}
+
+// EstimateCounts is EstimateFlakeRate for pre-tallied outcomes
+func EstimateCounts(passes, failures int, confidence float64) Estimate {
+	n := passes + failures
+	est := Estimate{
+		Runs:           n,
+		Failures:       failures,
+		Confidence:     confidence,
+		Wilson:         WilsonInterval(failures, n, confidence),
+		ClopperPearson: ClopperPearsonInterval(failures, n, confidence),
+	}
+	if n > 0 {
+		est.Rate = float64(failures) / float64(n)
+	}
+	return est
+}
+
+// zScore returns the two-sided standard normal quantile for confidence
+func zScore(confidence float64) float64 {
+	return math.Sqrt2 * math.Erfinv(confidence)
+}
+
+// WilsonInterval returns the Wilson score interval for k failures in n runs
+func WilsonInterval(k, n int, confidence float64) Interval {
+	if n == 0 {
+		return Interval{0, 1}
+	}
+	z := zScore(confidence)
+	p := float64(k) / float64(n)
+	nf := float64(n)
+	denom := 1 + z*z/nf
+	center := (p + z*z/(2*nf)) / denom
+	half := z * math.Sqrt(p*(1-p)/nf+z*z/(4*nf*nf)) / denom
+	return Interval{Lower: math.Max(0, center-half), Upper: math.Min(1, center+half)}
+}
+
+// ClopperPearsonInterval returns the exact binomial interval for k failures in n runs
+func ClopperPearsonInterval(k, n int, confidence float64) Interval {
+	if n == 0 {
+		return Interval{0, 1}
+	}
+	alpha := 1 - confidence
+	iv := Interval{Lower: 0, Upper: 1}
+	if k > 0 {
+		iv.Lower = betaQuantile(alpha/2, float64(k), float64(n-k+1))
+	}
+	if k < n {
+		iv.Upper = betaQuantile(1-alpha/2, float64(k+1), float64(n-k))
+	}
+	return iv
+}
+
+// betaQuantile inverts the regularized incomplete beta function by bisection
+func betaQuantile(p, a, b float64) float64 {
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 100; i++ {
+		mid := (lo + hi) / 2
+		if regIncBeta(mid, a, b) < p {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// regIncBeta returns the regularized incomplete beta function I_x(a, b)
+func regIncBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	lgab, _ := math.Lgamma(a + b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaFraction(x, a, b) / a
+	}
+	return 1 - front*betaFraction(1-x, b, a)/b
+}
+
+// betaFraction evaluates the incomplete beta continued fraction (modified Lentz)
+func betaFraction(x, a, b float64) float64 {
+	const tiny = 1e-300
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= 300; m++ {
+		mf := float64(m)
+		num := mf * (b - mf) * x / ((a + 2*mf - 1) * (a + 2*mf))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		num = -(a + mf) * (a + b + mf) * x / ((a + 2*mf) * (a + 2*mf + 1))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < 1e-12 {
+			break
+		}
+	}
+	return h
+}
+
+// Verdict is a classification reached at the estimate's confidence
+type Verdict string
+
+const (
+	Undecided Verdict = "undecided"
+	Stable    Verdict = "stable"
+	Flaky     Verdict = "flaky"
+	Broken    Verdict = "broken"
+)
+
+// Classify decides whether more runs are needed
+// A test that both passed and failed is flaky; one that never failed is
+// stable once the Clopper-Pearson upper bound drops below tolerance; one that
+// never passed is broken once the lower bound rises above 1 - tolerance
+func Classify(est Estimate, tolerance float64) Verdict {
+	switch {
+	case est.Failures > 0 && est.Failures < est.Runs:
+		return Flaky
+	case est.Runs > 0 && est.Failures == 0 && est.ClopperPearson.Upper < tolerance:
+		return Stable
+	case est.Runs > 0 && est.Failures == est.Runs && est.ClopperPearson.Lower > 1-tolerance:
+		return Broken
+	default:
+		return Undecided
+	}
+}
+
+// SPRT is Wald's sequential probability ratio test of a flake rate of P0
+// (stable) against one of P1 (flaky), deciding after as few runs as the
+// outcomes allow
+// Alpha is the probability of calling a P0 test flaky and Beta that of
+// calling a P1 test stable; rates between P0 and P1 can go either way
+type SPRT struct {
+	P0, P1      float64
+	Alpha, Beta float64
+}
+
+// Validate reports rates outside 0 < P0 < P1 < 1 and error rates outside
+// (0, 0.5)
+func (s SPRT) Validate() error {
+	if !(s.P0 > 0 && s.P0 < s.P1 && s.P1 < 1) {
+		return fmt.Errorf("sprt: need 0 < p0 < p1 < 1, got p0 %v, p1 %v", s.P0, s.P1)
+	}
+	for _, e := range []float64{s.Alpha, s.Beta} {
+		if e <= 0 || e >= 0.5 {
+			return fmt.Errorf("sprt: error rate %v outside (0, 0.5)", e)
+		}
+	}
+	return nil
+}
+
+// LogLikelihoodRatio returns the log of how much likelier the outcomes are
+// under P1 than under P0
+func (s SPRT) LogLikelihoodRatio(passes, failures int) float64 {
+	return float64(failures)*math.Log(s.P1/s.P0) + float64(passes)*math.Log((1-s.P1)/(1-s.P0))
+}
+
+// Decide returns Stable once the outcomes favour P0 past Wald's lower bound,
+// Flaky once they favour P1 past the upper bound, or Broken instead if the
+// test never passed, and Undecided in between
+func (s SPRT) Decide(passes, failures int) Verdict {
+	llr := s.LogLikelihoodRatio(passes, failures)
+	switch {
+	case llr >= math.Log((1-s.Beta)/s.Alpha):
+		if passes == 0 {
+			return Broken
+		}
+		return Flaky
+	case llr <= math.Log(s.Beta/(1-s.Alpha)):
+		return Stable
+	default:
+		return Undecided
+	}
+}
+
+// FisherExact returns the two-sided p-value of Fisher's exact test that k1
+// failures in n1 runs and k2 failures in n2 runs share one failure rate
+// Small p-values mean the rates differ; with no runs on either side there is
+// no evidence and the p-value is 1
+func FisherExact(k1, n1, k2, n2 int) float64 {
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+	// Given the total failures k, the failures among the n1 runs follow a
+	// hypergeometric distribution; sum every table no likelier than the
+	// observed one
+	k := k1 + k2
+	logP := func(x int) float64 {
+		return logChoose(n1, x) + logChoose(n2, k-x) - logChoose(n1+n2, k)
+	}
+	observed := logP(k1)
+	p := 0.0
+	for x := max(0, k-n2); x <= min(n1, k); x++ {
+		if lp := logP(x); lp <= observed+1e-7 {
+			p += math.Exp(lp)
+		}
+	}
+	return math.Min(p, 1)
+}
+
+// logChoose returns the natural log of the binomial coefficient n choose k
+func logChoose(n, k int) float64 {
+	a, _ := math.Lgamma(float64(n + 1))
+	b, _ := math.Lgamma(float64(k + 1))
+	c, _ := math.Lgamma(float64(n - k + 1))
+	return a - b - c
+}
+
+// Spread summarizes repeated measurements of one quantity, such as the ns/op
+// of a benchmark across runs
+type Spread struct {
+	N    int
+	Mean float64
+	// StdDev is the sample standard deviation, 0 for fewer than 2 samples
+	StdDev float64
+	// CV is the coefficient of variation StdDev/Mean, comparable across
+	// benchmarks of different speeds
+	CV       float64
+	Min, Max float64
+}
+
+// Describe returns the spread of samples
+func Describe(samples []float64) Spread {
+	sp := Spread{N: len(samples)}
+	if sp.N == 0 {
+		return sp
+	}
+	sp.Min, sp.Max = samples[0], samples[0]
+	for _, x := range samples {
+		sp.Mean += x
+		sp.Min, sp.Max = math.Min(sp.Min, x), math.Max(sp.Max, x)
+	}
+	sp.Mean /= float64(sp.N)
+	if sp.N > 1 {
+		var ss float64
+		for _, x := range samples {
+			ss += (x - sp.Mean) * (x - sp.Mean)
+		}
+		sp.StdDev = math.Sqrt(ss / float64(sp.N-1))
+	}
+	if sp.Mean != 0 {
+		sp.CV = sp.StdDev / math.Abs(sp.Mean)
+	}
+	return sp
+}
+
+// Outliers returns the indexes of the samples outside Tukey's fences, more
+// than 1.5 interquartile ranges beyond the first or third quartile
+// Fewer than 4 samples have no meaningful quartiles, so none are outliers
+func Outliers(samples []float64) []int {
+	if len(samples) < 4 {
+		return nil
+	}
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+	low, high := q1-1.5*(q3-q1), q3+1.5*(q3-q1)
+	var out []int
+	for i, x := range samples {
+		if x < low || x > high {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// quantile linearly interpolates the q-th quantile of sorted samples
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[i]
+	}
+	return sorted[i] + (pos-float64(i))*(sorted[i+1]-sorted[i])
+}
+
+// Quantile returns the q-th quantile of samples, linearly interpolated, or 0
+// without samples
+func Quantile(samples []float64, q float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	return quantile(sorted, q)
+}
+
+// MannWhitney returns the one-sided p-value of the Mann-Whitney U test that
+// the samples of y tend to be larger than those of x, from the normal
+// approximation with a correction for ties
+// It makes no assumption about the shape of either distribution, which suits
+// skewed measurements such as durations; small p-values mean y is larger,
+// and with no samples on either side there is no evidence and it is 1
+func MannWhitney(x, y []float64) float64 {
+	n1, n2 := float64(len(x)), float64(len(y))
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+	type sample struct {
+		v  float64
+		iy bool
+	}
+	all := make([]sample, 0, len(x)+len(y))
+	for _, v := range x {
+		all = append(all, sample{v, false})
+	}
+	for _, v := range y {
+		all = append(all, sample{v, true})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+	// Ranks of y, with tied samples sharing the mean of their ranks
+	var rankY, ties float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].iy {
+				rankY += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+	u := rankY - n2*(n2+1)/2
+	n := n1 + n2
+	variance := n1 * n2 / 12 * (n + 1 - ties/(n*(n-1)))
+	if variance <= 0 {
+		return 1
+	}
+	// The half is a continuity correction
+	z := (u - n1*n2/2 - 0.5) / math.Sqrt(variance)
+	return 0.5 * math.Erfc(z/math.Sqrt2)
+}
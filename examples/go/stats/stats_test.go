@@ -0,0 +1,249 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+func approx(t *testing.T, name string, got, want, tol float64) {
+	t.Helper()
+	if math.Abs(got-want) > tol {
+		t.Errorf("%s: expected %.4f, got %.4f", name, want, got)
+	}
+}
+
+func TestEstimateFlakeRate(t *testing.T) {
+	results := []bool{true, false, true, true, false, true, true, true, true, true}
+	est := EstimateFlakeRate(results, 0.95)
+	if est.Runs != 10 || est.Failures != 2 || est.Rate != 0.2 {
+		t.Errorf("Unexpected estimate: %+v", est)
+	}
+	if !(est.Wilson.Lower < 0.2 && 0.2 < est.Wilson.Upper) {
+		t.Errorf("Wilson interval %v does not contain the observed rate", est.Wilson)
+	}
+}
+
+// Reference values computed with scipy.stats (binomtest / proportion_confint)
+func TestWilsonInterval(t *testing.T) {
+	iv := WilsonInterval(2, 10, 0.95)
+	approx(t, "lower", iv.Lower, 0.0567, 5e-4)
+	approx(t, "upper", iv.Upper, 0.5098, 5e-4)
+
+	iv = WilsonInterval(0, 20, 0.95)
+	approx(t, "zero lower", iv.Lower, 0, 1e-9)
+	approx(t, "zero upper", iv.Upper, 0.1611, 5e-4)
+}
+
+func TestClopperPearsonInterval(t *testing.T) {
+	iv := ClopperPearsonInterval(2, 10, 0.95)
+	approx(t, "lower", iv.Lower, 0.0252, 5e-4)
+	approx(t, "upper", iv.Upper, 0.5561, 5e-4)
+
+	// 0 of n has the closed form upper bound 1 - (alpha/2)^(1/n)
+	iv = ClopperPearsonInterval(0, 30, 0.95)
+	approx(t, "zero upper", iv.Upper, 1-math.Pow(0.025, 1.0/30), 1e-6)
+	if iv.Lower != 0 {
+		t.Errorf("Expected lower bound 0 for no failures, got %v", iv.Lower)
+	}
+
+	iv = ClopperPearsonInterval(30, 30, 0.95)
+	approx(t, "all lower", iv.Lower, math.Pow(0.025, 1.0/30), 1e-6)
+	if iv.Upper != 1 {
+		t.Errorf("Expected upper bound 1 for all failures, got %v", iv.Upper)
+	}
+}
+
+func TestIntervalsWithoutRuns(t *testing.T) {
+	est := EstimateCounts(0, 0, 0.95)
+	if est.Wilson != (Interval{0, 1}) || est.ClopperPearson != (Interval{0, 1}) {
+		t.Errorf("Expected [0, 1] intervals without runs, got %+v", est)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	for _, tc := range []struct {
+		passes, failures int
+		want             Verdict
+	}{
+		{passes: 5, failures: 0, want: Undecided},
+		{passes: 100, failures: 0, want: Stable},
+		{passes: 9, failures: 1, want: Flaky},
+		{passes: 0, failures: 5, want: Undecided},
+		{passes: 0, failures: 100, want: Broken},
+		{passes: 0, failures: 0, want: Undecided},
+	} {
+		est := EstimateCounts(tc.passes, tc.failures, 0.95)
+		if got := Classify(est, 0.05); got != tc.want {
+			t.Errorf("%d passes, %d failures: expected %s, got %s", tc.passes, tc.failures, tc.want, got)
+		}
+	}
+}
+
+// Reference values computed with scipy.stats.fisher_exact
+func TestSPRT(t *testing.T) {
+	s := SPRT{P0: 0.05, P1: 0.2, Alpha: 0.05, Beta: 0.05}
+	for _, tc := range []struct {
+		passes, failures int
+		want             Verdict
+	}{
+		{0, 0, Undecided},
+		// Each pass moves the ratio by log(0.8/0.95), so 18 passes reach log(0.05/0.95)
+		{17, 0, Undecided},
+		{18, 0, Stable},
+		// Each failure moves it by log(4); 3 failures cross log(19)
+		{0, 2, Undecided},
+		{0, 3, Broken},
+		{2, 3, Flaky},
+		// One failure in 40 runs is within the stable rate
+		{39, 1, Stable},
+		{10, 1, Undecided},
+	} {
+		if got := s.Decide(tc.passes, tc.failures); got != tc.want {
+			t.Errorf("Decide(%d passes, %d failures) = %s, expected %s (LLR %.3f)",
+				tc.passes, tc.failures, got, tc.want, s.LogLikelihoodRatio(tc.passes, tc.failures))
+		}
+	}
+}
+
+func TestSPRTValidate(t *testing.T) {
+	for _, s := range []SPRT{
+		{P0: 0, P1: 0.2, Alpha: 0.05, Beta: 0.05},
+		{P0: 0.2, P1: 0.1, Alpha: 0.05, Beta: 0.05},
+		{P0: 0.05, P1: 1, Alpha: 0.05, Beta: 0.05},
+		{P0: 0.05, P1: 0.2, Alpha: 0, Beta: 0.05},
+		{P0: 0.05, P1: 0.2, Alpha: 0.05, Beta: 0.5},
+	} {
+		if err := s.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", s)
+		}
+	}
+	if err := (SPRT{P0: 0.05, P1: 0.2, Alpha: 0.05, Beta: 0.1}).Validate(); err != nil {
+		t.Errorf("Expected a valid test, got %v", err)
+	}
+}
+
+func TestFisherExact(t *testing.T) {
+	approx(t, "8/10 vs 1/6", FisherExact(8, 10, 1, 6), 0.0350, 5e-4)
+	approx(t, "1/10 vs 11/14", FisherExact(1, 10, 11, 14), 0.0028, 5e-4)
+	approx(t, "equal rates", FisherExact(2, 10, 2, 10), 1, 1e-9)
+	approx(t, "no failures", FisherExact(0, 20, 0, 20), 1, 1e-9)
+	approx(t, "no runs", FisherExact(0, 0, 3, 10), 1, 1e-9)
+}
+
+func TestDescribe(t *testing.T) {
+	sp := Describe([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+	if sp.N != 8 || sp.Min != 2 || sp.Max != 9 {
+		t.Errorf("Unexpected spread: %+v", sp)
+	}
+	approx(t, "mean", sp.Mean, 5, 1e-9)
+	approx(t, "sample stddev", sp.StdDev, math.Sqrt(32.0/7), 1e-9)
+	approx(t, "cv", sp.CV, math.Sqrt(32.0/7)/5, 1e-9)
+
+	if one := Describe([]float64{3}); one.StdDev != 0 || one.CV != 0 || one.Mean != 3 {
+		t.Errorf("Expected no spread for one sample, got %+v", one)
+	}
+	if none := Describe(nil); none.N != 0 || none.Mean != 0 {
+		t.Errorf("Expected an empty spread, got %+v", none)
+	}
+}
+
+func TestOutliers(t *testing.T) {
+	if got := Outliers([]float64{10, 11, 10, 12, 11, 10, 30}); len(got) != 1 || got[0] != 6 {
+		t.Errorf("Expected sample 6 to be the only outlier, got %v", got)
+	}
+	if got := Outliers([]float64{10, 11, 12, 13, 14}); len(got) != 0 {
+		t.Errorf("Expected no outliers in an even spread, got %v", got)
+	}
+	if got := Outliers([]float64{1, 100, 1}); got != nil {
+		t.Errorf("Expected no outliers from 3 samples, got %v", got)
+	}
+}
+
+func TestBetaUpdate(t *testing.T) {
+	post := Jeffreys.Update(8, 2)
+	if post.Alpha != 2.5 || post.Beta != 8.5 || post.Weight() != 11 {
+		t.Fatalf("Expected Beta(2.5, 8.5), got %v", post)
+	}
+	if math.Abs(post.Mean()-2.5/11) > 1e-12 {
+		t.Errorf("Expected mean %v, got %v", 2.5/11, post.Mean())
+	}
+	iv := post.CredibleInterval(0.95)
+	if iv.Lower >= post.Mean() || iv.Upper <= post.Mean() {
+		t.Errorf("Expected the interval %v to hold the mean %v", iv, post.Mean())
+	}
+	// The tails hold 2.5% each
+	if p := post.ProbAbove(iv.Upper); math.Abs(p-0.025) > 1e-6 {
+		t.Errorf("Expected 2.5%% above the upper bound, got %v", p)
+	}
+	if p := post.ProbAbove(iv.Lower); math.Abs(p-0.975) > 1e-6 {
+		t.Errorf("Expected 97.5%% above the lower bound, got %v", p)
+	}
+}
+
+func TestPriorFromHistory(t *testing.T) {
+	prior := PriorFromHistory(360, 40, 50)
+	if math.Abs(prior.Weight()-50) > 1e-9 {
+		t.Errorf("Expected a prior worth 50 runs, got %v", prior.Weight())
+	}
+	if want := 40.5 / 401; math.Abs(prior.Mean()-want) > 1e-12 {
+		t.Errorf("Expected the historical rate %v as the mean, got %v", want, prior.Mean())
+	}
+	if short := PriorFromHistory(9, 1, 50); short != Jeffreys.Update(9, 1) {
+		t.Errorf("Expected short history to be used as is, got %v", short)
+	}
+	if none := PriorFromHistory(360, 40, 0); none != Jeffreys {
+		t.Errorf("Expected weight 0 to ignore history, got %v", none)
+	}
+}
+
+func TestPosteriorSeparatesStableFromChronicallyFlaky(t *testing.T) {
+	// One failure in 5 runs, for a test with 400 clean runs behind it and
+	// for one that has failed 10% of the time
+	stable := PriorFromHistory(400, 0, 50).Update(4, 1)
+	flaky := PriorFromHistory(360, 40, 50).Update(4, 1)
+	if stable.Mean() > 0.03 || flaky.Mean() < 0.09 {
+		t.Errorf("Expected posterior means near 2%% and 10%%, got %v and %v", stable.Mean(), flaky.Mean())
+	}
+	if p := stable.ProbAbove(0.05); p > 0.2 {
+		t.Errorf("Expected the stable test to be unlikely above 5%%, got %v", p)
+	}
+	if p := flaky.ProbAbove(0.05); p < 0.9 {
+		t.Errorf("Expected the flaky test to be likely above 5%%, got %v", p)
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	samples := []float64{5, 1, 4, 2, 3}
+	for q, want := range map[float64]float64{0: 1, 0.5: 3, 0.95: 4.8, 1: 5} {
+		if got := Quantile(samples, q); math.Abs(got-want) > 1e-9 {
+			t.Errorf("Quantile(%v) = %v, want %v", q, got, want)
+		}
+	}
+	if Quantile(nil, 0.5) != 0 {
+		t.Error("Expected no samples to have quantile 0")
+	}
+}
+
+func TestMannWhitney(t *testing.T) {
+	var low, high, same []float64
+	for i := 0; i < 20; i++ {
+		low = append(low, float64(i))
+		high = append(high, float64(i+15))
+		same = append(same, float64(i))
+	}
+	if p := MannWhitney(low, high); p > 0.001 {
+		t.Errorf("Expected larger samples to be significant, got p=%v", p)
+	}
+	if p := MannWhitney(high, low); p < 0.99 {
+		t.Errorf("Expected smaller samples not to be, got p=%v", p)
+	}
+	if p := MannWhitney(low, same); p < 0.4 || p > 0.6 {
+		t.Errorf("Expected identical samples to give p near 0.5, got %v", p)
+	}
+	if p := MannWhitney([]float64{1, 1, 1}, []float64{1, 1}); p != 1 {
+		t.Errorf("Expected all-tied samples to give no evidence, got %v", p)
+	}
+	if p := MannWhitney(nil, high); p != 1 {
+		t.Errorf("Expected no samples to give no evidence, got %v", p)
+	}
+}
@@ -0,0 +1,84 @@
+package flaky_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/clock"
+	"github.com/example/flaky-test-example/flakystore"
+)
+
+// newReplicatedStore returns a store whose writes lag at the scenario's rate
+// and latency distribution, on a fake clock
+func newReplicatedStore(t *testing.T, sc flaky.Scenario) (*flakystore.Store, *clock.FakeClock) {
+	profile := flakystore.Profile{Stale: sc.FailureRate, MinLag: 10 * time.Millisecond, MaxLag: 100 * time.Millisecond}
+	if sc.Latency != nil {
+		profile.LagDistribution = sc.LatencyDistribution()
+	}
+	clk := clock.NewFake(time.Now())
+	return flakystore.New(flaky.ForTest(t), profile, clk), clk
+}
+
+// TestReadAfterWrite demonstrates reading a record straight after creating it
+// Fails 30% of the time by default, when the write has not replicated yet
+func TestReadAfterWrite(t *testing.T) {
+	sc := flaky.ScenarioForTest(t, "ReadAfterWrite")
+	store, _ := newReplicatedStore(t, sc)
+
+	store.Put("user/42", "alice")
+	if got, ok := store.Get("user/42"); !ok || got != "alice" {
+		t.Errorf("%s: got %q, %v", sc.Message, got, ok)
+	}
+}
+
+// TestReadAfterWriteFixed is the reliable variant of TestReadAfterWrite
+// It polls until the write is visible, with a deadline well past the lag
+func TestReadAfterWriteFixed(t *testing.T) {
+	sc := flaky.ScenarioForTest(t, "ReadAfterWrite")
+	store, clk := newReplicatedStore(t, sc)
+
+	store.Put("user/42", "alice")
+	flaky.Eventually(t, func() bool {
+		got, ok := store.Get("user/42")
+		return ok && got == "alice"
+	}, time.Second, 10*time.Millisecond, flaky.WithPollClock(clk))
+}
+
+// increment adds one to the counter at key, reading it with get
+func increment(store *flakystore.Store, get func(string) (string, bool), key string) {
+	n := 0
+	if v, ok := get(key); ok {
+		n, _ = strconv.Atoi(v)
+	}
+	store.Put(key, strconv.Itoa(n+1))
+}
+
+// TestLostUpdate demonstrates a read-modify-write on an eventually
+// consistent read
+// Fails 30% of the time by default: the second increment reads before the
+// first one replicated and overwrites it
+func TestLostUpdate(t *testing.T) {
+	sc := flaky.ScenarioForTest(t, "LostUpdate")
+	store, _ := newReplicatedStore(t, sc)
+
+	increment(store, store.Get, "views")
+	increment(store, store.Get, "views")
+	if got, _ := store.GetLatest("views"); got != "2" {
+		t.Errorf("%s: expected 2 views, got %s", sc.Message, got)
+	}
+}
+
+// TestLostUpdateFixed is the reliable variant of TestLostUpdate
+// The read of a read-modify-write must be strongly consistent
+func TestLostUpdateFixed(t *testing.T) {
+	sc := flaky.ScenarioForTest(t, "LostUpdate")
+	store, _ := newReplicatedStore(t, sc)
+
+	increment(store, store.GetLatest, "views")
+	increment(store, store.GetLatest, "views")
+	if got, _ := store.GetLatest("views"); got != "2" {
+		t.Errorf("%s: expected 2 views, got %s", sc.Message, got)
+	}
+}
@@ -0,0 +1,73 @@
+package flaky
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// GreenStreakDistribution returns, for k = 0..maxStreak, the probability of
+// getting exactly k consecutive all-pass runs before the first failing run
+// Streaks longer than maxStreak are not included, so the result sums to less than 1
+func GreenStreakDistribution(cfg FlakyConfig, maxStreak int) []float64 {
+	if maxStreak < 0 {
+		return nil
+	}
+	p := cfg.AllPassProbability()
+	dist := make([]float64, maxStreak+1)
+	for k := range dist {
+		dist[k] = math.Pow(p, float64(k)) * (1 - p)
+	}
+	return dist
+}
+
+// TestGreenStreakDistributionMatchesSimulation compares the distribution against
+// streak lengths measured from simulated run sequences
+func TestGreenStreakDistributionMatchesSimulation(t *testing.T) {
+	cfg := FlakyConfig{FailureRates: map[string]float64{
+		"RandomFailure":     0.05,
+		"NetworkSimulation": 0.1,
+		"TimingDependent":   0.02,
+	}}
+	const maxStreak = 10
+	const sequences = 50000
+
+	r := rand.New(PCG(1))
+	counts := make([]int, maxStreak+1)
+	for i := 0; i < sequences; i++ {
+		streak := 0
+		for len(simulateRun(r, cfg)) == 0 {
+			streak++
+		}
+		if streak <= maxStreak {
+			counts[streak]++
+		}
+	}
+
+	dist := GreenStreakDistribution(cfg, maxStreak)
+	for k, want := range dist {
+		got := float64(counts[k]) / sequences
+		if math.Abs(got-want) > 0.01 {
+			t.Errorf("streak %d: empirical %.4f, expected %.4f", k, got, want)
+		}
+	}
+}
+
+// TestGreenStreakDistributionEdgeCases covers deterministic suites
+func TestGreenStreakDistributionEdgeCases(t *testing.T) {
+	alwaysFails := FlakyConfig{FailureRates: map[string]float64{"ChannelRace": 1}}
+	if dist := GreenStreakDistribution(alwaysFails, 3); dist[0] != 1 || dist[1] != 0 {
+		t.Errorf("Expected all mass at streak 0, got %v", dist)
+	}
+
+	neverFails := FlakyConfig{FailureRates: map[string]float64{"ChannelRace": 0}}
+	for k, p := range GreenStreakDistribution(neverFails, 3) {
+		if p != 0 {
+			t.Errorf("streak %d: expected 0 for a suite that never fails, got %v", k, p)
+		}
+	}
+
+	if dist := GreenStreakDistribution(DefaultFlakyConfig(), -1); dist != nil {
+		t.Errorf("Expected nil for negative maxStreak, got %v", dist)
+	}
+}
@@ -0,0 +1,148 @@
+package flaky
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/distributions"
+)
+
+// nearMissMargin is the fraction of a scenario's draw range within which a
+// passing draw is logged as a near miss
+const nearMissMargin = 0.05
+
+// thresholdCheck describes a scenario that fails when a random draw crosses a threshold
+type thresholdCheck struct {
+	name      string
+	threshold float64
+	span      float64 // width of the draw range, used to scale margins
+	failAbove bool    // fails when draw > threshold, otherwise when draw <= threshold
+}
+
+// Threshold-based scenarios; other scenarios are not eligible for strict mode
+var (
+	randomFailureThreshold = thresholdCheck{name: "RandomFailure", threshold: 0.7, span: 1, failAbove: true}
+	timingThreshold        = thresholdCheck{name: "TimingDependent", threshold: 4, span: 4, failAbove: true}
+	boundaryThreshold      = thresholdCheck{name: "BoundaryCondition", threshold: 100, span: 4, failAbove: true}
+	networkThreshold       = thresholdCheck{name: "NetworkSimulation", threshold: 0.2, span: 1, failAbove: false}
+)
+
+// at returns the check with its threshold moved, as configured scenarios do
+func (c thresholdCheck) at(threshold float64) thresholdCheck {
+	c.threshold = threshold
+	return c
+}
+
+// scaled returns a timing check for a configured timeout and delay range,
+// both measured in milliseconds
+func (c thresholdCheck) scaled(timeout, span time.Duration) thresholdCheck {
+	c.threshold = float64(timeout) / float64(time.Millisecond)
+	c.span = float64(span) / float64(time.Millisecond)
+	return c
+}
+
+// delaySpan is the width of a latency distribution's draw range for scaling
+// margins: the whole range of a uniform one, the central 98% of others
+func delaySpan(d distributions.Distribution) time.Duration {
+	if u, ok := d.(distributions.Uniform); ok {
+		return u.Max - u.Min
+	}
+	return d.Quantile(0.99) - d.Quantile(0.01)
+}
+
+// crossed reports whether draw fails the scenario's own threshold
+func (c thresholdCheck) crossed(draw float64) bool {
+	if c.failAbove {
+		return draw > c.threshold
+	}
+	return draw <= c.threshold
+}
+
+// withinMargin reports whether a passing draw lies within margin (a fraction
+// of the draw range) of the threshold
+func (c thresholdCheck) withinMargin(draw, margin float64) bool {
+	if margin <= 0 || c.crossed(draw) {
+		return false
+	}
+	return math.Abs(draw-c.threshold) <= margin*c.span
+}
+
+// strictMargin reads FLAKY_STRICT_MARGIN, returning 0 (strict mode off) when
+// unset or invalid
+func strictMargin() float64 {
+	margin, err := strconv.ParseFloat(os.Getenv("FLAKY_STRICT_MARGIN"), 64)
+	if err != nil || margin < 0 {
+		return 0
+	}
+	return margin
+}
+
+// strictFailure returns an error when draw passed but lies within margin of the threshold
+func strictFailure(c thresholdCheck, draw, margin float64) error {
+	if !c.withinMargin(draw, margin) {
+		return nil
+	}
+	return fmt.Errorf("Strict mode: %s draw %.3f within margin %.2f of threshold %.3f",
+		c.name, draw, margin, c.threshold)
+}
+
+// checkNearMiss logs passing draws close to the threshold and, in strict mode,
+// fails them so fragile-but-passing runs show up as red
+func checkNearMiss(t *testing.T, c thresholdCheck, draw float64) {
+	t.Helper()
+	if c.withinMargin(draw, nearMissMargin) {
+		t.Logf("Near miss: %s draw %.3f close to threshold %.3f", c.name, draw, c.threshold)
+	}
+	if err := strictFailure(c, draw, strictMargin()); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestStrictMarginFailsNearMiss verifies a draw within the margin fails in strict mode
+func TestStrictMarginFailsNearMiss(t *testing.T) {
+	t.Setenv("FLAKY_STRICT_MARGIN", "0.05")
+
+	if err := strictFailure(randomFailureThreshold, 0.68, strictMargin()); err == nil {
+		t.Error("Expected draw 0.68 to fail within margin 0.05 of 0.7")
+	}
+	if err := strictFailure(networkThreshold, 0.22, strictMargin()); err == nil {
+		t.Error("Expected draw 0.22 to fail within margin 0.05 of 0.2")
+	}
+	if err := strictFailure(boundaryThreshold, 100, strictMargin()); err == nil {
+		t.Error("Expected value 100 to fail at the boundary threshold")
+	}
+}
+
+// TestStrictMarginPassesOutsideMargin verifies draws outside the margin still pass
+func TestStrictMarginPassesOutsideMargin(t *testing.T) {
+	t.Setenv("FLAKY_STRICT_MARGIN", "0.05")
+
+	if err := strictFailure(randomFailureThreshold, 0.5, strictMargin()); err != nil {
+		t.Errorf("Unexpected strict failure: %v", err)
+	}
+	if err := strictFailure(boundaryThreshold, 99, strictMargin()); err != nil {
+		t.Errorf("Unexpected strict failure: %v", err)
+	}
+}
+
+// TestStrictModeOffPassesNearMiss verifies near misses pass when strict mode is off
+func TestStrictModeOffPassesNearMiss(t *testing.T) {
+	for _, value := range []string{"", "0", "-1", "not-a-number"} {
+		t.Setenv("FLAKY_STRICT_MARGIN", value)
+		if err := strictFailure(randomFailureThreshold, 0.68, strictMargin()); err != nil {
+			t.Errorf("FLAKY_STRICT_MARGIN=%q: unexpected strict failure: %v", value, err)
+		}
+	}
+}
+
+// TestStrictMarginIgnoresCrossedDraws verifies draws that already fail are not
+// reported a second time by strict mode
+func TestStrictMarginIgnoresCrossedDraws(t *testing.T) {
+	if err := strictFailure(randomFailureThreshold, 0.71, 0.05); err != nil {
+		t.Errorf("Unexpected strict failure for crossed draw: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package flaky_test
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+
+	flaky "github.com/example/flaky-test-example"
+)
+
+// slugCases are the cases of TestTableDriven; only the ones with an upper
+// case I can go wrong under a Turkish locale
+var slugCases = []struct {
+	name, title, want string
+}{
+	{"lowercase", "is it flaky", "is-it-flaky"},
+	{"title", "Is It Flaky", "is-it-flaky"},
+	{"shouting", "IS IT FLAKY", "is-it-flaky"},
+}
+
+// slugify lowercases title under locale and joins its words with hyphens
+func slugify(title, locale string) string {
+	lower := strings.ToLower(title)
+	if locale == "tr" {
+		lower = strings.ToLowerSpecial(unicode.TurkishCase, title)
+	}
+	return strings.Join(strings.Fields(lower), "-")
+}
+
+// TestTableDriven demonstrates a table-driven test whose cases read a locale
+// cached by whichever test ran before them
+// Each case draws from its own seed and fails at its own rate: never for
+// lowercase, 20% of the time for title and 40% for shouting by default
+func TestTableDriven(t *testing.T) {
+	for _, tc := range slugCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sc := flaky.ScenarioForTest(t, "TableDriven").Case(tc.name)
+			locale := "en"
+			if flaky.ForTest(t).Float64() < sc.FailureRate {
+				locale = "tr"
+			}
+			if got := slugify(tc.title, locale); got != tc.want {
+				t.Errorf("%s: expected %q, got %q under locale %s", sc.Message, tc.want, got, locale)
+			}
+		})
+	}
+}
+
+// TestTableDrivenFixed is the reliable variant of TestTableDriven
+// Every case sets the locale it expects instead of inheriting one
+func TestTableDrivenFixed(t *testing.T) {
+	for _, tc := range slugCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sc := flaky.ScenarioForTest(t, "TableDriven").Case(tc.name)
+			if got := slugify(tc.title, "en"); got != tc.want {
+				t.Errorf("%s: expected %q, got %q", sc.Message, tc.want, got)
+			}
+		})
+	}
+}
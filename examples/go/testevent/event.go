@@ -0,0 +1,98 @@
+// Package testevent reads the go test -json stream, as written by test2json,
+// and follows every test through it
+// A Decoder yields the events, a Tracker turns them into one TestRun per
+// run of a test, and Summarize aggregates the runs of each test
+//
+//	runs, err := testevent.ReadAll(stdout) // of go test -json ./...
+//	for _, s := range testevent.Summarize(runs) {
+//	    fmt.Printf("%s: %d of %d failed, mean %v\n", s.Test, s.Failed, s.Runs(), s.MeanDuration())
+//	}
+package testevent
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Action is what an event reports
+type Action string
+
+const (
+	Start  Action = "start"
+	Run    Action = "run"
+	Pause  Action = "pause"
+	Cont   Action = "cont"
+	Pass   Action = "pass"
+	Bench  Action = "bench"
+	Fail   Action = "fail"
+	Output Action = "output"
+	Skip   Action = "skip"
+)
+
+// Event is one line of go test -json output
+// Test is empty for the events of a package as a whole
+type Event struct {
+	Time    time.Time `json:",omitempty"`
+	Action  Action
+	Package string  `json:",omitempty"`
+	Test    string  `json:",omitempty"`
+	Elapsed float64 `json:",omitempty"`
+	Output  string  `json:",omitempty"`
+}
+
+// Done reports whether the event gives an outcome: pass, fail or skip
+func (e Event) Done() bool {
+	return e.Action == Pass || e.Action == Fail || e.Action == Skip
+}
+
+// Duration returns Elapsed, which go test reports in seconds
+func (e Event) Duration() time.Duration {
+	return time.Duration(e.Elapsed * float64(time.Second))
+}
+
+// Decoder reads events from a go test -json stream
+type Decoder struct {
+	scanner *bufio.Scanner
+}
+
+// NewDecoder returns a Decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return &Decoder{scanner: scanner}
+}
+
+// Next returns the next event, or io.EOF at the end of the stream
+// Lines that are not events, such as build errors, are skipped
+func (d *Decoder) Next() (Event, error) {
+	for d.scanner.Scan() {
+		var ev Event
+		if err := json.Unmarshal(d.scanner.Bytes(), &ev); err == nil && ev.Action != "" {
+			return ev, nil
+		}
+	}
+	if err := d.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+// ReadAll decodes r to the end and returns the runs of every test in it,
+// in the order they finished
+func ReadAll(r io.Reader) ([]TestRun, error) {
+	dec := NewDecoder(r)
+	tracker := NewTracker()
+	var runs []TestRun
+	for {
+		ev, err := dec.Next()
+		if err == io.EOF {
+			return append(runs, tracker.Close()...), nil
+		}
+		if err != nil {
+			return append(runs, tracker.Close()...), err
+		}
+		runs = append(runs, tracker.Handle(ev)...)
+	}
+}
@@ -0,0 +1,68 @@
+package testevent
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+const stream = `{"Action":"start","Package":"example/pkg"}
+{"Time":"2024-05-01T10:00:00Z","Action":"run","Package":"example/pkg","Test":"TestA"}
+{"Action":"output","Package":"example/pkg","Test":"TestA","Output":"    a_test.go:10: got 0.812\n"}
+{"Action":"fail","Package":"example/pkg","Test":"TestA","Elapsed":0.01}
+# example/pkg [build noise that is not JSON]
+{"Time":"2024-05-01T10:00:01Z","Action":"run","Package":"example/pkg","Test":"TestB"}
+{"Action":"pass","Package":"example/pkg","Test":"TestB","Elapsed":0.25}
+{"Time":"2024-05-01T10:00:02Z","Action":"run","Package":"example/pkg","Test":"TestC"}
+{"Action":"output","Package":"example/pkg","Test":"TestC","Output":"panic: test timed out after 1s\n"}
+{"Time":"2024-05-01T10:00:03Z","Action":"fail","Package":"example/pkg","Elapsed":3}
+`
+
+func TestDecoderSkipsNonEvents(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(stream))
+	var actions []Action
+	for {
+		ev, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		actions = append(actions, ev.Action)
+	}
+	if len(actions) != 9 || actions[0] != Start || actions[8] != Fail {
+		t.Errorf("Expected the 9 events without the build noise, got %v", actions)
+	}
+}
+
+func TestEventDuration(t *testing.T) {
+	if got := (Event{Elapsed: 0.25}).Duration(); got != 250*time.Millisecond {
+		t.Errorf("Expected 250ms, got %v", got)
+	}
+	if (Event{Action: Output}).Done() || !(Event{Action: Skip}).Done() {
+		t.Error("Expected only pass, fail and skip to be done")
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	runs, err := ReadAll(strings.NewReader(stream))
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if len(runs) != 3 {
+		t.Fatalf("Expected 3 runs, got %+v", runs)
+	}
+	a, b, c := runs[0], runs[1], runs[2]
+	if a.Test != "TestA" || a.Outcome != Fail || !a.Finished || a.Output != "    a_test.go:10: got 0.812\n" {
+		t.Errorf("Unexpected TestA run: %+v", a)
+	}
+	if b.Outcome != Pass || b.Elapsed != 250*time.Millisecond || !b.Start.Equal(time.Date(2024, 5, 1, 10, 0, 1, 0, time.UTC)) {
+		t.Errorf("Unexpected TestB run: %+v", b)
+	}
+	if c.Outcome != Fail || c.Finished || c.Elapsed != time.Second {
+		t.Errorf("Expected TestC failed unfinished after 1s when its package ended, got %+v", c)
+	}
+}
@@ -0,0 +1,74 @@
+package testevent
+
+import (
+	"sort"
+	"time"
+)
+
+// Summary aggregates the runs of one test
+type Summary struct {
+	Package string
+	Test    string
+	Passed  int
+	Failed  int
+	Skipped int
+	// Unfinished counts the failed runs that never reported an outcome
+	Unfinished int
+	// Total and Max are the summed and the longest run time
+	Total, Max time.Duration
+	// FailureOutputs holds the output of every failed run, in run order
+	FailureOutputs []string
+}
+
+// Runs returns the number of runs
+func (s Summary) Runs() int {
+	return s.Passed + s.Failed + s.Skipped
+}
+
+// MeanDuration returns the mean run time, zero without runs
+func (s Summary) MeanDuration() time.Duration {
+	if s.Runs() == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Runs())
+}
+
+// Summarize aggregates runs by test, sorted by package, then test name
+func Summarize(runs []TestRun) []Summary {
+	byTest := make(map[Key]*Summary)
+	for _, run := range runs {
+		key := Key{run.Package, run.Test}
+		s := byTest[key]
+		if s == nil {
+			s = &Summary{Package: run.Package, Test: run.Test}
+			byTest[key] = s
+		}
+		switch run.Outcome {
+		case Pass:
+			s.Passed++
+		case Skip:
+			s.Skipped++
+		default:
+			s.Failed++
+			s.FailureOutputs = append(s.FailureOutputs, run.Output)
+			if !run.Finished {
+				s.Unfinished++
+			}
+		}
+		s.Total += run.Elapsed
+		s.Max = max(s.Max, run.Elapsed)
+	}
+
+	summaries := make([]Summary, 0, len(byTest))
+	for _, s := range byTest {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		return a.Test < b.Test
+	})
+	return summaries
+}
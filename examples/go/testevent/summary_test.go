@@ -0,0 +1,36 @@
+package testevent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	runs := []TestRun{
+		{Package: "p", Test: "TestB", Outcome: Pass, Elapsed: time.Second, Finished: true},
+		{Package: "p", Test: "TestA", Outcome: Fail, Elapsed: 3 * time.Second, Output: "first", Finished: true},
+		{Package: "p", Test: "TestA", Outcome: Pass, Elapsed: time.Second, Finished: true},
+		{Package: "p", Test: "TestA", Outcome: Fail, Output: "crashed"},
+		{Package: "p", Test: "TestB", Outcome: Skip, Finished: true},
+	}
+	summaries := Summarize(runs)
+	if len(summaries) != 2 || summaries[0].Test != "TestA" {
+		t.Fatalf("Expected TestA then TestB, got %+v", summaries)
+	}
+	a := summaries[0]
+	if a.Runs() != 3 || a.Passed != 1 || a.Failed != 2 || a.Unfinished != 1 {
+		t.Errorf("Unexpected TestA counts: %+v", a)
+	}
+	if a.Total != 4*time.Second || a.Max != 3*time.Second || a.MeanDuration() != 4*time.Second/3 {
+		t.Errorf("Unexpected TestA durations: %+v", a)
+	}
+	if len(a.FailureOutputs) != 2 || a.FailureOutputs[1] != "crashed" {
+		t.Errorf("Expected both failure outputs in order, got %q", a.FailureOutputs)
+	}
+	if b := summaries[1]; b.Skipped != 1 || b.MeanDuration() != time.Second/2 {
+		t.Errorf("Unexpected TestB summary: %+v", b)
+	}
+	if (Summary{}).MeanDuration() != 0 {
+		t.Error("Expected a zero mean without runs")
+	}
+}
@@ -0,0 +1,180 @@
+package testevent
+
+import (
+	"strings"
+	"time"
+)
+
+// State is where a test is in its run
+type State int
+
+const (
+	// NotStarted is the state of a test the stream has not run yet
+	NotStarted State = iota
+	Running
+	// Paused is a t.Parallel test waiting for the tests before it
+	Paused
+	Passed
+	Failed
+	Skipped
+)
+
+var stateNames = [...]string{"not started", "running", "paused", "passed", "failed", "skipped"}
+
+func (s State) String() string {
+	if s < 0 || int(s) >= len(stateNames) {
+		return "unknown"
+	}
+	return stateNames[s]
+}
+
+// TestRun is one run of a test, from its run event to its outcome
+type TestRun struct {
+	Package string
+	Test    string
+	// Outcome is Pass, Fail or Skip
+	Outcome Action
+	// Start is when the test started, zero when the stream did not say
+	Start   time.Time
+	Elapsed time.Duration
+	Output  string
+	// Finished is false for a test whose binary never reported an outcome,
+	// such as one running when it panicked, timed out or exited; its
+	// Outcome is Fail
+	Finished bool
+}
+
+// Key identifies a test across packages
+type Key struct {
+	Package, Test string
+}
+
+// Tracker follows each test of a stream through its states and collects
+// its output
+// It is not safe for concurrent use
+type Tracker struct {
+	states  map[Key]State
+	started map[Key]time.Time
+	outputs map[Key]*strings.Builder
+	running []Key
+}
+
+// NewTracker returns a Tracker with no tests started
+func NewTracker() *Tracker {
+	return &Tracker{
+		states:  make(map[Key]State),
+		started: make(map[Key]time.Time),
+		outputs: make(map[Key]*strings.Builder),
+	}
+}
+
+// State returns the state of a test
+func (t *Tracker) State(pkg, test string) State {
+	return t.states[Key{pkg, test}]
+}
+
+// Running returns the tests started and not finished, in start order;
+// paused tests count as running
+func (t *Tracker) Running() []Key {
+	var running []Key
+	seen := make(map[Key]bool)
+	for _, key := range t.running {
+		if !t.finished(key) && !seen[key] {
+			seen[key] = true
+			running = append(running, key)
+		}
+	}
+	return running
+}
+
+func (t *Tracker) finished(key Key) bool {
+	state := t.states[key]
+	return state != Running && state != Paused
+}
+
+// Handle moves ev's test to its next state and returns the runs ev ends:
+// the test's own on its outcome, and the unfinished tests of a package when
+// the package ends, since a binary that dies mid-test reports nothing more
+// for them
+func (t *Tracker) Handle(ev Event) []TestRun {
+	if ev.Test == "" {
+		if ev.Action == Pass || ev.Action == Fail {
+			return t.abandon(ev.Package, ev.Time)
+		}
+		return nil
+	}
+	key := Key{ev.Package, ev.Test}
+	switch ev.Action {
+	case Run:
+		t.states[key] = Running
+		t.started[key] = ev.Time
+		t.running = append(t.running, key)
+	case Pause:
+		t.states[key] = Paused
+	case Cont:
+		t.states[key] = Running
+	case Output:
+		if t.outputs[key] == nil {
+			t.outputs[key] = &strings.Builder{}
+		}
+		t.outputs[key].WriteString(ev.Output)
+	case Pass, Fail, Skip:
+		return []TestRun{t.finish(key, ev.Action, ev.Duration(), true)}
+	}
+	return nil
+}
+
+// Close fails every test still running, as at the end of a stream cut
+// short, and returns their runs
+func (t *Tracker) Close() []TestRun {
+	return t.abandon("", time.Time{})
+}
+
+// finish records the outcome of a test and returns its run
+func (t *Tracker) finish(key Key, outcome Action, elapsed time.Duration, finished bool) TestRun {
+	run := TestRun{
+		Package:  key.Package,
+		Test:     key.Test,
+		Outcome:  outcome,
+		Start:    t.started[key],
+		Elapsed:  elapsed,
+		Finished: finished,
+	}
+	if b := t.outputs[key]; b != nil {
+		run.Output = b.String()
+	}
+	switch outcome {
+	case Pass:
+		t.states[key] = Passed
+	case Skip:
+		t.states[key] = Skipped
+	default:
+		t.states[key] = Failed
+	}
+	delete(t.outputs, key)
+	delete(t.started, key)
+	return run
+}
+
+// abandon fails the unfinished tests of pkg, or of every package when pkg
+// is empty, timing them up to at unless it is zero
+func (t *Tracker) abandon(pkg string, at time.Time) []TestRun {
+	var runs []TestRun
+	remaining := t.running[:0]
+	for _, key := range t.running {
+		if t.finished(key) {
+			continue
+		}
+		if pkg != "" && key.Package != pkg {
+			remaining = append(remaining, key)
+			continue
+		}
+		var elapsed time.Duration
+		if !at.IsZero() {
+			elapsed = at.Sub(t.started[key])
+		}
+		runs = append(runs, t.finish(key, Fail, elapsed, false))
+	}
+	t.running = remaining
+	return runs
+}
@@ -0,0 +1,56 @@
+package testevent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrackerStates(t *testing.T) {
+	tracker := NewTracker()
+	handle := func(action Action, test string) []TestRun {
+		return tracker.Handle(Event{Action: action, Package: "p", Test: test})
+	}
+	handle(Run, "TestA")
+	handle(Run, "TestA/sub")
+	handle(Pause, "TestA/sub")
+	if got := tracker.State("p", "TestA/sub"); got != Paused {
+		t.Errorf("Expected a paused subtest, got %v", got)
+	}
+	if got := len(tracker.Running()); got != 2 {
+		t.Errorf("Expected paused tests to count as running, got %d", got)
+	}
+	handle(Cont, "TestA/sub")
+	handle(Output, "TestA/sub")
+	if runs := handle(Skip, "TestA/sub"); len(runs) != 1 || runs[0].Outcome != Skip {
+		t.Errorf("Expected a skipped run, got %+v", runs)
+	}
+	handle(Pass, "TestA")
+	if got := tracker.State("p", "TestA"); got != Passed || got.String() != "passed" {
+		t.Errorf("Expected TestA passed, got %v", got)
+	}
+	if got := tracker.State("p", "TestB"); got != NotStarted {
+		t.Errorf("Expected TestB not started, got %v", got)
+	}
+	if running := tracker.Running(); len(running) != 0 {
+		t.Errorf("Expected nothing running, got %v", running)
+	}
+}
+
+func TestTrackerAbandonsOnlyTheEndingPackage(t *testing.T) {
+	tracker := NewTracker()
+	start := time.Unix(0, 0)
+	tracker.Handle(Event{Time: start, Action: Run, Package: "a", Test: "TestA"})
+	tracker.Handle(Event{Time: start, Action: Run, Package: "b", Test: "TestB"})
+
+	runs := tracker.Handle(Event{Time: start.Add(2 * time.Second), Action: Fail, Package: "a"})
+	if len(runs) != 1 || runs[0].Test != "TestA" || runs[0].Finished || runs[0].Elapsed != 2*time.Second {
+		t.Errorf("Expected only TestA abandoned after 2s, got %+v", runs)
+	}
+	if got := tracker.State("b", "TestB"); got != Running {
+		t.Errorf("Expected TestB still running, got %v", got)
+	}
+	runs = tracker.Close()
+	if len(runs) != 1 || runs[0].Test != "TestB" || runs[0].Outcome != Fail || runs[0].Elapsed != 0 {
+		t.Errorf("Expected Close to fail TestB untimed, got %+v", runs)
+	}
+}
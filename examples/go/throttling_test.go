@@ -0,0 +1,59 @@
+package flaky_test
+
+import (
+	"testing"
+	"time"
+
+	flaky "github.com/example/flaky-test-example"
+	"github.com/example/flaky-test-example/clock"
+)
+
+// heartbeatInterval is how often the heartbeats of TestCPUThrottling beat
+const heartbeatInterval = time.Millisecond
+
+// heartbeats is how many beats a heartbeat check waits through
+const heartbeats = 20
+
+// longestGap sleeps through beats heartbeats on c and returns the longest
+// time between two of them, as a liveness monitor measures it
+func longestGap(c clock.Clock, beats int) time.Duration {
+	var longest time.Duration
+	last := c.Now()
+	for i := 0; i < beats; i++ {
+		c.Sleep(heartbeatInterval)
+		now := c.Now()
+		longest = max(longest, now.Sub(last))
+		last = now
+	}
+	return longest
+}
+
+// TestCPUThrottling demonstrates a liveness deadline that only holds while
+// the test has the CPU to itself
+// This simulates a heartbeat checked against wall time on a shared
+// serverless host whose neighbours keep every CPU busy
+// The ~20% of seeds that apply load run 1 to 2 busy goroutines per
+// GOMAXPROCS; a heartbeat then waits up to the scheduler's 10ms time slice
+// to run again, past the 5ms deadline
+func TestCPUThrottling(t *testing.T) {
+	sc := flaky.ScenarioForTest(t, "CPUThrottling")
+	load := flaky.ForTest(t).ApplyLoad(t, sc)
+
+	if gap := longestGap(clock.Real(), heartbeats); gap > time.Duration(sc.Timeout) {
+		t.Errorf("%s: %v between heartbeats beside %d busy goroutine(s), deadline %v",
+			sc.Message, gap, load.Workers(), time.Duration(sc.Timeout))
+	}
+}
+
+// TestCPUThrottlingFixed is the reliable variant of TestCPUThrottling
+// The heartbeat runs on a fake clock, so the deadline is checked against
+// the time the code waited for rather than when the scheduler ran it
+func TestCPUThrottlingFixed(t *testing.T) {
+	sc := flaky.ScenarioForTest(t, "CPUThrottling")
+	load := flaky.ForTest(t).ApplyLoad(t, sc)
+
+	if gap := longestGap(clock.NewFake(time.Unix(0, 0)), heartbeats); gap > time.Duration(sc.Timeout) {
+		t.Errorf("%s: %v between heartbeats beside %d busy goroutine(s), deadline %v",
+			sc.Message, gap, load.Workers(), time.Duration(sc.Timeout))
+	}
+}
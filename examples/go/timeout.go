@@ -0,0 +1,44 @@
+package flaky
+
+import (
+	"testing"
+	"time"
+
+	"github.com/example/flaky-test-example/deadlock"
+	"github.com/example/flaky-test-example/internal/protocol"
+)
+
+// GoroutineDumpHeader precedes the goroutine dump in WithTimeout's failure,
+// which flakectl detect looks for to save the dump
+const GoroutineDumpHeader = protocol.GoroutineDumpHeader
+
+// WithTimeout runs fn and fails t with a dump of every goroutine's stack if
+// fn has not returned within d; it reports whether fn returned in time
+// Unlike deadlock.Watch, which bounds the whole test, only fn is timed, so a
+// test can give each step its own deadline
+// fn runs on a goroutine of its own, so it must fail t with t.Error rather
+// than t.Fatal; its panics are re-raised in the caller
+// A hung fn cannot be stopped from outside and keeps running once
+// WithTimeout has returned
+func WithTimeout(t testing.TB, d time.Duration, fn func()) bool {
+	t.Helper()
+	done := make(chan struct{})
+	var panicked any
+	go func() {
+		defer close(done)
+		defer func() { panicked = recover() }()
+		fn()
+	}()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-done:
+		if panicked != nil {
+			panic(panicked)
+		}
+		return true
+	case <-timer.C:
+		t.Errorf("%s did not return within %v; %s\n\n%s", t.Name(), d, GoroutineDumpHeader, deadlock.Stacks())
+		return false
+	}
+}
@@ -0,0 +1,47 @@
+package flaky
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func waitForever(release <-chan struct{}) {
+	<-release
+}
+
+func TestWithTimeoutDumpsGoroutines(t *testing.T) {
+	rec := &recordingTB{TB: t}
+	release := make(chan struct{})
+	defer close(release)
+
+	if WithTimeout(rec, 10*time.Millisecond, func() { waitForever(release) }) {
+		t.Fatal("Expected the hung call to time out")
+	}
+	if len(rec.failures) != 1 {
+		t.Fatalf("Expected one failure, got %v", rec.failures)
+	}
+	for _, want := range []string{t.Name() + " did not return within 10ms", GoroutineDumpHeader, ".waitForever(", "[chan receive"} {
+		if !strings.Contains(rec.failures[0], want) {
+			t.Errorf("Expected the failure to contain %q:\n%s", want, rec.failures[0])
+		}
+	}
+}
+
+func TestWithTimeoutReturnsInTime(t *testing.T) {
+	rec := &recordingTB{TB: t}
+	ran := false
+	if !WithTimeout(rec, time.Second, func() { ran = true }) || !ran || len(rec.failures) != 0 {
+		t.Errorf("Expected fn to run and pass, got ran=%v, failures %v", ran, rec.failures)
+	}
+}
+
+func TestWithTimeoutReraisesPanic(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("Expected the panic of fn, got %v", r)
+		}
+	}()
+	WithTimeout(t, time.Second, func() { panic("boom") })
+	t.Error("Expected WithTimeout to panic")
+}
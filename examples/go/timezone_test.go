@@ -0,0 +1,101 @@
+package flaky
+
+import (
+	"os"
+	"testing"
+	"time"
+	_ "time/tzdata" // make zone lookups independent of the host's zoneinfo
+)
+
+// timestampLayout has no zone information, so the parse location decides the instant
+const timestampLayout = "2006-01-02 15:04:05"
+
+// naiveTimestamp was written by a service running in UTC
+const naiveTimestamp = "2024-03-15 12:00:00"
+
+var expectedInstant = time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+// TestNaiveTimestampParse demonstrates the time.Local pitfall
+// The timestamp is interpreted in the machine's local timezone, so the test
+// only passes where that happens to be UTC (reproduce with TZ=Asia/Tokyo)
+func TestNaiveTimestampParse(t *testing.T) {
+	parsed, err := parseNaive(naiveTimestamp, time.Local)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	// Fails when the local timezone is not UTC
+	if !parsed.Equal(expectedInstant) {
+		t.Errorf("Parsed %s as %s, expected %s (local timezone %s)",
+			naiveTimestamp, parsed.UTC(), expectedInstant, time.Local)
+	}
+}
+
+// TestExplicitLocationParse is the reliable variant of TestNaiveTimestampParse
+// The timestamp is parsed in the location it was written in
+func TestExplicitLocationParse(t *testing.T) {
+	parsed, err := parseExplicit(naiveTimestamp)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if !parsed.Equal(expectedInstant) {
+		t.Errorf("Parsed %s as %s, expected %s", naiveTimestamp, parsed, expectedInstant)
+	}
+}
+
+// parseNaive parses a zone-less timestamp in local, the way code relying on
+// time.Local does
+func parseNaive(value string, local *time.Location) (time.Time, error) {
+	return time.ParseInLocation(timestampLayout, value, local)
+}
+
+// parseExplicit parses a zone-less timestamp in the zone it was produced in
+func parseExplicit(value string) (time.Time, error) {
+	return time.ParseInLocation(timestampLayout, value, time.UTC)
+}
+
+// localFromTZ resolves the location the runtime would use as time.Local for
+// the current TZ value; time.Local itself is only read once at startup
+func localFromTZ(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(os.Getenv("TZ"))
+	if err != nil {
+		t.Fatalf("Unknown TZ %q: %v", os.Getenv("TZ"), err)
+	}
+	return loc
+}
+
+// TestNaiveParseDependsOnTZ verifies the naive parse disagrees across timezones
+func TestNaiveParseDependsOnTZ(t *testing.T) {
+	results := make(map[string]time.Time)
+	for _, zone := range []string{"UTC", "Asia/Tokyo"} {
+		t.Setenv("TZ", zone)
+		parsed, err := parseNaive(naiveTimestamp, localFromTZ(t))
+		if err != nil {
+			t.Fatalf("TZ=%s: parse failed: %v", zone, err)
+		}
+		results[zone] = parsed
+	}
+
+	if !results["UTC"].Equal(expectedInstant) {
+		t.Errorf("TZ=UTC: expected %s, got %s", expectedInstant, results["UTC"])
+	}
+	if results["Asia/Tokyo"].Equal(results["UTC"]) {
+		t.Errorf("Expected naive parse to differ between UTC and Asia/Tokyo, both gave %s", results["UTC"])
+	}
+}
+
+// TestExplicitParseIgnoresTZ verifies the explicit-location parse is stable across timezones
+func TestExplicitParseIgnoresTZ(t *testing.T) {
+	for _, zone := range []string{"UTC", "Asia/Tokyo"} {
+		t.Setenv("TZ", zone)
+		parsed, err := parseExplicit(naiveTimestamp)
+		if err != nil {
+			t.Fatalf("TZ=%s: parse failed: %v", zone, err)
+		}
+		if !parsed.Equal(expectedInstant) {
+			t.Errorf("TZ=%s: expected %s, got %s", zone, expectedInstant, parsed)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package flaky
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryVisibilityEnv opts in to TestMemoryVisibility, whose outcome comes
+// from real goroutine scheduling rather than the seed alone
+const memoryVisibilityEnv = "FLAKY_MEMORY_VISIBILITY"
+
+// serverConfig is filled in by a loader and read by request handlers
+type serverConfig struct {
+	Limit int
+}
+
+// publishConfig runs a loader that hands a config to a handler without a
+// channel or lock and returns the limit the handler saw
+// The loader takes fill to load the limit and the handler arrives after
+// arrive, both in time.Sleep, so the timers and the scheduler decide the
+// close calls
+// On a racy run the loader publishes the pointer before filling the config
+// in and the handler polls a plain variable, so a handler that arrives
+// before the fill sees a zero limit; otherwise the config is filled in,
+// then published through an atomic pointer
+func publishConfig(racy bool, fill, arrive time.Duration) int {
+	var (
+		plain  *serverConfig
+		safe   atomic.Pointer[serverConfig]
+		seen   int
+		wg     sync.WaitGroup
+		loaded = func() *serverConfig {
+			if racy {
+				return plain
+			}
+			return safe.Load()
+		}
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		cfg := &serverConfig{}
+		if racy {
+			plain = cfg
+		}
+		time.Sleep(fill)
+		cfg.Limit = 100
+		if !racy {
+			safe.Store(cfg)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		time.Sleep(arrive)
+		cfg := loaded()
+		for cfg == nil {
+			runtime.Gosched()
+			cfg = loaded()
+		}
+		seen = cfg.Limit
+	}()
+	wg.Wait()
+	return seen
+}
+
+// TestMemoryVisibility demonstrates a real memory-visibility flake
+// This simulates a config loader that, on a fast path the seed takes half
+// the time, publishes its config before filling it in, while a handler
+// polls for it without synchronization
+// The seed picks the racy runs and when the fill and the handler happen;
+// about half the racy runs fail, and the real timers and scheduler decide
+// the close ones, so a seed does not always replay
+// Set FLAKY_MEMORY_VISIBILITY=1 to run it; under -race every racy run fails
+// with a race report, including the ones that saw the right limit
+func TestMemoryVisibility(t *testing.T) {
+	if os.Getenv(memoryVisibilityEnv) == "" {
+		t.Skipf("set %s=1 to run a real memory-visibility race", memoryVisibilityEnv)
+	}
+	inj := ForTest(t)
+	sc := scenario(t, "MemoryVisibility")
+	racy := inj.Float64() < sc.FailureRate
+	fill, arrive := inj.Draw(sc.LatencyDistribution()), inj.Draw(sc.LatencyDistribution())
+	Report(t, sc.Meta(map[string]any{"racy": racy, "fill": fill.String(), "arrive": arrive.String()}))
+
+	if limit := publishConfig(racy, fill, arrive); limit != 100 {
+		t.Errorf("%s: handler arriving after %v saw limit %d, filled after %v", sc.Message, arrive, limit, fill)
+	}
+}
+
+// TestMemoryVisibilityFixed is the reliable variant of TestMemoryVisibility
+// The config is filled in before an atomic store publishes it, so the
+// handler's atomic load sees every write made before the store
+func TestMemoryVisibilityFixed(t *testing.T) {
+	inj := ForTest(t)
+	sc := scenario(t, "MemoryVisibility")
+	fill, arrive := inj.Draw(sc.LatencyDistribution()), inj.Draw(sc.LatencyDistribution())
+
+	if limit := publishConfig(false, fill, arrive); limit != 100 {
+		t.Errorf("%s: handler arriving after %v saw limit %d, filled after %v", sc.Message, arrive, limit, fill)
+	}
+}